@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/response"
+	"github.com/gin-gonic/gin"
+)
+
+// PruneScalingEvents 立即清理超过 metrics_retention_days 的扩缩容事件
+func PruneScalingEvents(c *gin.Context) {
+	pruned, err := redundancy_keeper.PruneNow(c.Request.Context())
+	if errors.Is(err, redundancy_keeper.ErrRetentionNotConfigured) {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse(err.Error()))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.MkFailedResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.MkSuccessResponse(gin.H{"pruned": pruned}))
+}
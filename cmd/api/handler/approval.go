@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/request"
+	"github.com/galaxy-future/cudgx/internal/response"
+	"github.com/gin-gonic/gin"
+)
+
+// ListPendingApprovals 查询扩缩容确认记录，默认查询等待确认的记录
+func ListPendingApprovals(c *gin.Context) {
+	status := c.DefaultQuery("status", consts.ApprovalStatusPending)
+	approvals, err := redundancy_keeper.ListPendingApprovals(status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.MkFailedResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.MkSuccessResponse(approvals))
+}
+
+// ApprovePendingAction 确认超过 review_threshold 的扩缩容
+func ApprovePendingAction(c *gin.Context) {
+	reviewPendingAction(c, redundancy_keeper.ApprovePendingAction)
+}
+
+// RejectPendingAction 拒绝超过 review_threshold 的扩缩容
+func RejectPendingAction(c *gin.Context) {
+	reviewPendingAction(c, redundancy_keeper.RejectPendingAction)
+}
+
+func reviewPendingAction(c *gin.Context, review func(approvalID int64, approver string) error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse("未指定确认记录id"))
+		return
+	}
+	req := request.ReviewPendingActionRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse(response.ParamError))
+		return
+	}
+	approver, ok := reviewApprover(c, req.Approver)
+	if !ok {
+		return
+	}
+	if err := review(id, approver); err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.MkSuccessResponse(nil))
+}
+
+//reviewApprover 确认人取认证中间件写入的用户，body 中的 approver 必须为空或与其相同；没有配置认证时使用 body 中的 approver
+func reviewApprover(c *gin.Context, bodyApprover string) (string, bool) {
+	user := c.GetString(gin.AuthUserKey)
+	if user == "" {
+		if bodyApprover == "" {
+			c.JSON(http.StatusBadRequest, response.MkFailedResponse(response.ParamError))
+			return "", false
+		}
+		return bodyApprover, true
+	}
+	if bodyApprover != "" && bodyApprover != user {
+		c.JSON(http.StatusForbidden, response.MkFailedResponse("approver 与认证用户不一致"))
+		return "", false
+	}
+	return user, true
+}
@@ -0,0 +1,151 @@
+package handler_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/galaxy-future/cudgx/cmd/api/handler"
+	"github.com/galaxy-future/cudgx/internal/predict/auth"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/gin-gonic/gin"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+//singleApprovalStore 只保存一条确认记录
+type singleApprovalStore struct {
+	lock     sync.Mutex
+	approval model.PendingApproval
+}
+
+func (store *singleApprovalStore) CreatePendingApproval(approval *model.PendingApproval) error {
+	return errors.New("not supported")
+}
+
+func (store *singleApprovalStore) GetPendingApprovalById(id int64) (*model.PendingApproval, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if id != store.approval.Id {
+		return nil, errors.New("record not found")
+	}
+	copied := store.approval
+	return &copied, nil
+}
+
+func (store *singleApprovalStore) GetOpenPendingApprovalByRuleId(ruleID int64) (*model.PendingApproval, error) {
+	return nil, nil
+}
+
+func (store *singleApprovalStore) ListPendingApprovalsByStatus(status string) ([]*model.PendingApproval, error) {
+	return nil, nil
+}
+
+func (store *singleApprovalStore) TransitPendingApprovalStatus(id int64, from, to, approver, message string) (bool, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if id != store.approval.Id || store.approval.Status != from {
+		return false, nil
+	}
+	store.approval.Status = to
+	store.approval.Approver = approver
+	return true, nil
+}
+
+func (store *singleApprovalStore) current() model.PendingApproval {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	return store.approval
+}
+
+var _ = ginkgo.Describe("ApprovePendingAction", func() {
+	var issuer *httptest.Server
+	var key *rsa.PrivateKey
+	var store *singleApprovalStore
+
+	sign := func() string {
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, (&jose.SignerOptions{}).WithHeader("kid", "test-key"))
+		gomega.Expect(err).To(gomega.BeNil())
+		token, err := jwt.Signed(signer).Claims(map[string]interface{}{
+			"iss":   issuer.URL,
+			"aud":   "cudgx",
+			"sub":   "user-1",
+			"email": "ops@galaxy-future.com",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}).CompactSerialize()
+		gomega.Expect(err).To(gomega.BeNil())
+		return token
+	}
+	approve := func(engine *gin.Engine, token, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/cudgx/approvals/1/approve", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w.Code
+	}
+	newEngine := func(withAuth bool) *gin.Engine {
+		engine := gin.New()
+		if withAuth {
+			middleware, err := auth.Middleware(&config.OIDCConfig{OIDCIssuerURL: issuer.URL, OIDCClientID: "cudgx"})
+			gomega.Expect(err).To(gomega.BeNil())
+			engine.Use(middleware)
+		}
+		engine.POST("/api/v1/cudgx/approvals/:id/approve", handler.ApprovePendingAction)
+		return engine
+	}
+
+	ginkgo.BeforeEach(func() {
+		var err error
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+		gomega.Expect(err).To(gomega.BeNil())
+		mux := http.NewServeMux()
+		issuer = httptest.NewServer(mux)
+		mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode(map[string]string{"issuer": issuer.URL, "jwks_uri": issuer.URL + "/keys"})
+		})
+		mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "test-key", Algorithm: "RS256", Use: "sig"}}})
+		})
+
+		store = &singleApprovalStore{approval: model.PendingApproval{Id: 1, RuleId: 7, Status: consts.ApprovalStatusPending, ExpireAt: time.Now().Add(time.Hour).Unix()}}
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithApprovalStore(store),
+		)).To(gomega.Succeed())
+	})
+
+	ginkgo.AfterEach(func() {
+		issuer.Close()
+	})
+
+	ginkgo.It("rejects a body approver that differs from the authenticated user", func() {
+		gomega.Expect(approve(newEngine(true), sign(), `{"approver":"mallory"}`)).To(gomega.Equal(http.StatusForbidden))
+		gomega.Expect(store.current().Status).To(gomega.Equal(consts.ApprovalStatusPending))
+	})
+
+	ginkgo.It("records the authenticated user as the approver", func() {
+		gomega.Expect(approve(newEngine(true), sign(), "")).To(gomega.Equal(http.StatusOK))
+		gomega.Expect(store.current().Status).To(gomega.Equal(consts.ApprovalStatusApproved))
+		gomega.Expect(store.current().Approver).To(gomega.Equal("ops@galaxy-future.com"))
+	})
+
+	ginkgo.It("requires a body approver without authentication", func() {
+		engine := newEngine(false)
+		gomega.Expect(approve(engine, "", "")).To(gomega.Equal(http.StatusBadRequest))
+		gomega.Expect(approve(engine, "", `{"approver":"alice"}`)).To(gomega.Equal(http.StatusOK))
+		gomega.Expect(store.current().Approver).To(gomega.Equal("alice"))
+	})
+})
@@ -0,0 +1,19 @@
+package handler
+
+import (
+	"net/http"
+
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/response"
+	"github.com/gin-gonic/gin"
+)
+
+// GetCostSummary 查询各服务因扩缩容带来的每小时成本变化
+func GetCostSummary(c *gin.Context) {
+	summary, err := redundancy_keeper.GetCostSummary()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.MkFailedResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.MkSuccessResponse(summary))
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/galaxy-future/cudgx/internal/predict/custommetrics"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/gin-gonic/gin"
+)
+
+var customMetricsAdapter = custommetrics.NewCustomMetricsAdapter(redundancy_keeper.LatestRedundancies)
+
+// ListCustomMetrics Kubernetes Custom Metrics API 指标发现
+func ListCustomMetrics(c *gin.Context) {
+	c.JSON(http.StatusOK, customMetricsAdapter.ListAllMetrics())
+}
+
+// GetCustomMetric Kubernetes Custom Metrics API 查询冗余度，只支持 pods/* 查询所有服务集群
+func GetCustomMetric(c *gin.Context) {
+	if c.Param("pod") != "*" {
+		c.JSON(http.StatusNotFound, custommetrics.NewStatus(http.StatusNotFound, "only pods/* is supported, cudgx reports redundancy per service cluster"))
+		return
+	}
+	selector, err := custommetrics.ParseSelector(c.Query("labelSelector"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, custommetrics.NewStatus(http.StatusBadRequest, err.Error()))
+		return
+	}
+	list, err := customMetricsAdapter.GetMetricBySelector(c.Param("namespace"), "pods", c.Param("metric"), selector)
+	if errors.Is(err, custommetrics.ErrMetricNotFound) {
+		c.JSON(http.StatusNotFound, custommetrics.NewStatus(http.StatusNotFound, err.Error()))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, custommetrics.NewStatus(http.StatusInternalServerError, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
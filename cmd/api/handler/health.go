@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/gin-gonic/gin"
+)
+
+// Liveness 存活探针，最近一次成功调度超过阈值时返回503，不访问数据库
+func Liveness(c *gin.Context) {
+	if !redundancy_keeper.IsAlive() {
+		c.String(http.StatusServiceUnavailable, "redundancy keeper is not alive")
+		return
+	}
+	c.String(http.StatusOK, "alive")
+}
+
+// Readiness 就绪探针，首次加载规则且调度触发后返回200，不访问数据库
+func Readiness(c *gin.Context) {
+	if !redundancy_keeper.IsReady() {
+		c.String(http.StatusServiceUnavailable, "redundancy keeper is not ready")
+		return
+	}
+	c.String(http.StatusOK, "ready")
+}
+
+// DBHealth 数据库连接池检查，所有连接都在使用中时返回503，不执行查询
+func DBHealth(c *gin.Context) {
+	stats, err := clients.GetDBStats()
+	if err != nil {
+		c.String(http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if clients.IsDBPoolExhausted(stats) {
+		c.String(http.StatusServiceUnavailable, fmt.Sprintf("database connection pool is exhausted, %d of %d connections in use", stats.InUse, stats.MaxOpenConnections))
+		return
+	}
+	c.String(http.StatusOK, "ok")
+}
@@ -1,15 +1,23 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/galaxy-future/cudgx/common/logger"
+	"github.com/galaxy-future/cudgx/internal/predict/benchmark"
 	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
 	"github.com/galaxy-future/cudgx/internal/predict/service"
 	"github.com/galaxy-future/cudgx/internal/request"
 	"github.com/galaxy-future/cudgx/internal/response"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 // GetPredictRule 获取扩缩容规则
@@ -59,11 +67,25 @@ func CreatePredictRule(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, response.MkFailedResponse(response.MetricNameError))
 		return
 	}
-	err := service.CreatePredictRule(&req)
+	predictRule, err := service.CreatePredictRule(&req)
+	if errors.Is(err, model.ErrRuleExists) {
+		c.JSON(http.StatusConflict, response.MkFailedResponse(err.Error()))
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, response.MkFailedResponse(err.Error()))
 		return
 	}
+	if predictRule.BenchmarkQps == 0 {
+		// 规则已创建，校准失败时 keeper 跳过该规则，可以之后再次校准
+		if _, err := benchmark.CalibrateRule(c.Request.Context(), predictRule); err != nil {
+			logger.GetLogger().Warn("calibrate benchmark qps of new rule failed", zap.Int64("rule_id", predictRule.Id), zap.Error(err))
+			c.JSON(http.StatusOK, response.MkSuccessResponse(gin.H{"calibration_error": err.Error()}))
+			return
+		}
+		c.JSON(http.StatusOK, response.MkSuccessResponse(gin.H{"benchmark_qps": predictRule.BenchmarkQps}))
+		return
+	}
 	c.JSON(http.StatusOK, response.MkSuccessResponse(nil))
 }
 
@@ -79,7 +101,7 @@ func UpdatePredictRule(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, response.MkFailedResponse(response.MetricNameError))
 		return
 	}
-	err := service.UpdatePredictRuleById(&req)
+	err := service.UpdatePredictRuleById(&req, requestUser(c))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, response.MkFailedResponse(err.Error()))
 		return
@@ -95,8 +117,12 @@ func BatchDeletePredictRule(c *gin.Context) {
 		return
 	}
 	err := service.DeletePredictRuleById(&req)
+	if errors.Is(err, model.ErrRuleMustBeDisabledFirst) || errors.Is(err, model.ErrRuleIsScheduling) {
+		c.JSON(http.StatusConflict, response.MkFailedResponse(err.Error()))
+		return
+	}
 	if err != nil {
-		response.MkFailedResponse(err.Error())
+		c.JSON(http.StatusInternalServerError, response.MkFailedResponse(err.Error()))
 		return
 	}
 	c.JSON(http.StatusOK, response.MkSuccessResponse(nil))
@@ -131,6 +157,142 @@ func ListPredictRules(c *gin.Context) {
 	}))
 }
 
+// ListPredictRulesByMetric 查询使用指定指标的所有扩缩容规则
+func ListPredictRulesByMetric(c *gin.Context) {
+	metricName := c.Query("metric_name")
+	if metricName == "" {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse("指标名称不能为空"))
+		return
+	}
+	predictRules, err := service.ListPredictRulesByMetric(metricName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.MkFailedResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.MkSuccessResponse(predictRules))
+}
+
+// ListMetricNames 查询已启用的扩缩容规则使用的所有指标名称
+func ListMetricNames(c *gin.Context) {
+	metricNames, err := service.ListEnabledMetricNames()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.MkFailedResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.MkSuccessResponse(metricNames))
+}
+
+// ClonePredictRule 复制扩缩容规则到新的服务集群
+func ClonePredictRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse("未指定规则id"))
+		return
+	}
+	req := request.ClonePredictRuleRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse(response.ParamError))
+		return
+	}
+	predictRule, err := service.CloneRule(id, req.ServiceName, req.ClusterName)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.MkSuccessResponse(predictRule))
+}
+
+// BulkUpdatePredictRules 在同一个事务中批量修改扩缩容规则，任何一个规则失败时全部回滚
+func BulkUpdatePredictRules(c *gin.Context) {
+	req := request.BulkUpdateRulesRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse(response.ParamError))
+		return
+	}
+	result, err := service.BulkUpdateRules(req.Updates, req.DryRun, requestUser(c))
+	if errors.Is(err, model.ErrBulkUpdateRolledBack) {
+		resp := response.MkFailedResponse(err.Error())
+		resp.Data = result
+		c.JSON(http.StatusBadRequest, resp)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.MkSuccessResponse(result))
+}
+
+// ExportRulesForMigration 按服务、集群、指标、状态和 id 筛选扩缩容规则，导出为可直接导入其他实例的 JSON 数组
+func ExportRulesForMigration(c *gin.Context) {
+	filter := model.RuleFilter{
+		ServiceName:        c.Query("service_name"),
+		ClusterName:        c.Query("cluster_name"),
+		MetricName:         c.Query("metric_name"),
+		Status:             c.Query("status"),
+		MetricBackendAlias: c.Query("metric_backend_alias"),
+	}
+	if ids := c.Query("ids"); ids != "" {
+		for _, idStr := range strings.Split(ids, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, response.MkFailedResponse("规则id格式错误"))
+				return
+			}
+			filter.Ids = append(filter.Ids, id)
+		}
+	}
+	data, err := service.ExportRulesForMigration(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.MkFailedResponse(err.Error()))
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// ImportRulesFromMigration 导入其他实例导出的扩缩容规则，disable_source=true 时导入后禁用来源实例上的规则
+func ImportRulesFromMigration(c *gin.Context) {
+	disableSource := false
+	if c.Query("disable_source") != "" {
+		var err error
+		disableSource, err = strconv.ParseBool(c.Query("disable_source"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.MkFailedResponse(response.ParamError))
+			return
+		}
+	}
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse(response.ParamError))
+		return
+	}
+	result, err := service.ImportRulesFromMigration(data, disableSource)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.MkSuccessResponse(result))
+}
+
+// CalibratePredictRule 根据最近一小时的指标和当前实例数校准规则的 benchmark_qps
+func CalibratePredictRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse("未指定规则id"))
+		return
+	}
+	predictRule, err := service.GetPredictRuleById(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse(err.Error()))
+		return
+	}
+	if _, err := benchmark.CalibrateRule(c.Request.Context(), predictRule); err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.MkSuccessResponse(predictRule))
+}
+
 // EnablePredictRule 启用扩缩容规则
 func EnablePredictRule(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -143,6 +305,9 @@ func EnablePredictRule(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, response.MkFailedResponse(err.Error()))
 		return
 	}
+	// 启用时连续失败次数已清零
+	errorCount := 0
+	redundancy_keeper.PublishRuleStatus(&redundancy_keeper.RuleStatus{RuleId: id, Status: consts.RuleStatusEnable, ErrorCount: &errorCount, Timestamp: time.Now().Unix()})
 	c.JSON(http.StatusOK, response.MkSuccessResponse(nil))
 }
 
@@ -158,9 +323,41 @@ func DisablePredictRule(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, response.MkFailedResponse(err.Error()))
 		return
 	}
+	redundancy_keeper.PublishRuleStatus(&redundancy_keeper.RuleStatus{RuleId: id, Status: consts.RuleStatusDisable, Timestamp: time.Now().Unix()})
 	c.JSON(http.StatusOK, response.MkSuccessResponse(nil))
 }
 
+// ListRuleChangelog 查询扩缩容规则的修改记录
+func ListRuleChangelog(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse("未指定规则id"))
+		return
+	}
+	limit := consts.DefaultRuleChangelogLimit
+	if c.Query("limit") != "" {
+		limit, err = strconv.Atoi(c.Query("limit"))
+		if err != nil || limit < 1 || limit > consts.MaxRuleChangelogLimit {
+			c.JSON(http.StatusBadRequest, response.MkFailedResponse(fmt.Sprintf("limit 必须在1到%d之间", consts.MaxRuleChangelogLimit)))
+			return
+		}
+	}
+	changes, err := service.ListRuleChangelog(id, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.MkSuccessResponse(changes))
+}
+
+//requestUser 发起请求的用户，优先使用认证中间件（如 gin.BasicAuth）写入的用户，没有认证时使用 X-User 请求头
+func requestUser(c *gin.Context) string {
+	if user := c.GetString(gin.AuthUserKey); user != "" {
+		return user
+	}
+	return c.GetHeader("X-User")
+}
+
 func getPager(c *gin.Context) (pageNumber int, pageSize int, err error) {
 	pageNumber, err = strconv.Atoi(c.Query("page_number"))
 	if err != nil {
@@ -178,3 +375,62 @@ func getPager(c *gin.Context) (pageNumber int, pageSize int, err error) {
 	}
 	return pageNumber, pageSize, nil
 }
+
+// SetPredictRuleOverride 临时覆盖扩缩容规则参数
+func SetPredictRuleOverride(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse("未指定规则id"))
+		return
+	}
+	override := redundancy_keeper.RuleOverride{}
+	if err := c.ShouldBindJSON(&override); err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse(response.ParamError))
+		return
+	}
+	rule, err := service.GetPredictRuleById(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse(err.Error()))
+		return
+	}
+	err = redundancy_keeper.SetRuleOverride(rule, override)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.MkSuccessResponse(nil))
+}
+
+// ClearPredictRuleOverride 清除扩缩容规则的临时覆盖
+func ClearPredictRuleOverride(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse("未指定规则id"))
+		return
+	}
+	err = redundancy_keeper.ClearRuleOverride(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.MkSuccessResponse(nil))
+}
+
+// ExplainPredictRule 查询扩缩容规则最近一次执行的原因说明
+func ExplainPredictRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse("未指定规则id"))
+		return
+	}
+	result, err := redundancy_keeper.Explain(id)
+	if errors.Is(err, redundancy_keeper.ErrRuleNotEvaluated) {
+		c.JSON(http.StatusNotFound, response.MkFailedResponse(err.Error()))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.MkFailedResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.MkSuccessResponse(result))
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/response"
+	"github.com/gin-gonic/gin"
+)
+
+//defaultReportDuration 未指定 start 时报告覆盖的时长
+const defaultReportDuration = 7 * 24 * time.Hour
+
+// GetRedundancyReport 查询服务集群一段时间内的冗余度报告
+func GetRedundancyReport(c *gin.Context) {
+	end := time.Now()
+	if value := c.Query("end"); value != "" {
+		timestamp, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.MkFailedResponse("end 必须是时间戳"))
+			return
+		}
+		end = time.Unix(timestamp, 0)
+	}
+	start := end.Add(-defaultReportDuration)
+	if value := c.Query("start"); value != "" {
+		timestamp, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.MkFailedResponse("start 必须是时间戳"))
+			return
+		}
+		start = time.Unix(timestamp, 0)
+	}
+	if !start.Before(end) {
+		c.JSON(http.StatusBadRequest, response.MkFailedResponse("start 必须早于 end"))
+		return
+	}
+
+	report, err := redundancy_keeper.GenerateRedundancyReport(c.Param("service"), c.Param("cluster"), start, end)
+	if errors.Is(err, redundancy_keeper.ErrRuleNotFound) {
+		c.JSON(http.StatusNotFound, response.MkFailedResponse(err.Error()))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.MkFailedResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.MkSuccessResponse(report))
+}
@@ -0,0 +1,19 @@
+package handler
+
+import (
+	"net/http"
+
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/response"
+	"github.com/gin-gonic/gin"
+)
+
+// GetKeeperStats 查询 keeper 的运行统计，不依赖 Prometheus
+func GetKeeperStats(c *gin.Context) {
+	stats, err := redundancy_keeper.GetStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.MkFailedResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.MkSuccessResponse(stats))
+}
@@ -0,0 +1,19 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/galaxy-future/cudgx/internal/response"
+	"github.com/gin-gonic/gin"
+)
+
+// GetServiceTopology 查询服务的上下游服务
+func GetServiceTopology(c *gin.Context) {
+	topology, err := service.GetServiceTopology(c.Request.Context(), c.Param("service"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.MkFailedResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.MkSuccessResponse(topology))
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/logger"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+)
+
+//watchPingInterval WebSocket 连接发送 ping 的间隔
+const watchPingInterval = 30 * time.Second
+
+const (
+	//ruleStatusMessageInitial 连接建立后推送的所有规则的完整状态
+	ruleStatusMessageInitial = "initial"
+	//ruleStatusMessagePatch 单条规则状态的增量
+	ruleStatusMessagePatch = "patch"
+)
+
+//ruleStatusMessage 推送给 WatchRules 连接的消息
+type ruleStatusMessage struct {
+	Type  string                          `json:"type"`
+	Rules []*redundancy_keeper.RuleStatus `json:"rules,omitempty"`
+	Rule  *redundancy_keeper.RuleStatus   `json:"rule,omitempty"`
+}
+
+// WatchRules 升级为 WebSocket，先推送所有规则的完整状态，之后在扩缩容、规则禁用、连续失败次数变化时推送增量
+func WatchRules(c *gin.Context) {
+	updates, cancel, err := redundancy_keeper.WatchRuleStatus()
+	if errors.Is(err, redundancy_keeper.ErrTooManyWatchers) {
+		c.JSON(http.StatusServiceUnavailable, response.MkFailedResponse(err.Error()))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.MkFailedResponse(err.Error()))
+		return
+	}
+	defer cancel()
+	rules, err := redundancy_keeper.ListRuleStatus()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.MkFailedResponse(err.Error()))
+		return
+	}
+	// 不设置 Handshake，允许没有 Origin 的非浏览器客户端
+	server := websocket.Server{Handler: func(ws *websocket.Conn) {
+		serveRuleStatus(ws, rules, updates)
+	}}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+//serveRuleStatus 推送规则状态直到连接断开或停止监听
+func serveRuleStatus(ws *websocket.Conn, rules []*redundancy_keeper.RuleStatus, updates <-chan *redundancy_keeper.RuleStatus) {
+	defer ws.Close()
+	var writeLock sync.Mutex
+	send := func(message *ruleStatusMessage) error {
+		writeLock.Lock()
+		defer writeLock.Unlock()
+		return websocket.JSON.Send(ws, message)
+	}
+	ping := func() error {
+		writeLock.Lock()
+		defer writeLock.Unlock()
+		ws.PayloadType = websocket.PingFrame
+		defer func() { ws.PayloadType = websocket.TextFrame }()
+		_, err := ws.Write(nil)
+		return err
+	}
+
+	if err := send(&ruleStatusMessage{Type: ruleStatusMessageInitial, Rules: rules}); err != nil {
+		return
+	}
+	// 读取并丢弃客户端的消息，连接关闭时结束；ping/pong 帧由 websocket 包处理
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		var discard string
+		for {
+			if err := websocket.Message.Receive(ws, &discard); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(watchPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case status, ok := <-updates:
+			if !ok {
+				return
+			}
+			if err := send(&ruleStatusMessage{Type: ruleStatusMessagePatch, Rule: status}); err != nil {
+				logger.GetLogger().Debug("send rule status failed", zap.Error(err))
+				return
+			}
+		case <-ticker.C:
+			if err := ping(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -2,22 +2,30 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"net"
+	"os"
 
 	"github.com/galaxy-future/cudgx/cmd/api/handler"
 	"github.com/galaxy-future/cudgx/common/logger"
 	"github.com/galaxy-future/cudgx/common/victoriametrics"
 	"github.com/galaxy-future/cudgx/internal/predict"
+	"github.com/galaxy-future/cudgx/internal/predict/auth"
 	"github.com/galaxy-future/cudgx/internal/predict/config"
 	"github.com/galaxy-future/cudgx/internal/predict/query"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 )
 
 var (
 	configFile = flag.String("gf.cudgx.api.config", "conf/api.json", "api configure file")
 	serverBind = flag.String("gf.cudgx.api.bind", "0.0.0.0:19003", "server bind address default(0.0.0.0:19003)")
+	profile    = flag.String("gf.cudgx.api.profile", "", "pprof listen address, same as param.profiling_addr")
+	once       = flag.Bool("gf.cudgx.api.once", false, "run the redundancy keeper once and exit, same as param.run_once")
+	noAuth     = flag.Bool("gf.cudgx.api.disable-auth", false, "disable oidc authentication of the management api, for development only")
 )
 
 func main() {
@@ -30,27 +38,68 @@ func main() {
 	if err != nil {
 		panic("load theConfig file falied : " + err.Error())
 	}
+	if theConfig.Predict == nil {
+		theConfig.Predict = &config.Param{}
+	}
+	if *once {
+		theConfig.Predict.RunOnce = true
+	}
+	if *profile != "" {
+		theConfig.Predict.ProfilingAddr = *profile
+	}
 
 	if err := predict.InitializeByConfig(theConfig); err != nil {
 		panic(err)
 	}
+	if err := predict.StartProfiling(context.Background(), theConfig.Predict); err != nil {
+		panic(err)
+	}
 
 	reader := victoriametrics.NewReader(theConfig.VictoriaMetrics)
 	query.Reader = reader
+	backend, err := service.NewMetricBackendByParam(theConfig.Predict, reader)
+	if err != nil {
+		panic(err)
+	}
+	service.SetMetricBackend(backend)
+	service.SetMigrationSourceURL(theConfig.Predict.ApprovalBaseURL)
+
+	if theConfig.Predict.RunOnce {
+		runOnce()
+	}
 
 	go predict.StartRedundancyKeeper(context.Background())
+	go predict.StartBenchmarkLearner(context.Background())
+	go predict.StartServiceDiscovery(context.Background())
+	go predict.StartApprovalExecutor(context.Background())
+	go predict.StartScalingEventPruner(context.Background())
+	predict.WatchConfig(context.Background(), *configFile)
+	predict.WatchRemoteConfig(context.Background(), theConfig.Predict)
 
 	r := gin.New()
 	if gin.IsDebugging() {
 		r.Use(gin.Logger())
 	}
 	r.Use(gin.Recovery())
+	if *noAuth {
+		logger.GetLogger().Warn("oidc authentication is disabled by flag")
+	} else if theConfig.Auth != nil && theConfig.Auth.OIDCIssuerURL != "" {
+		authMiddleware, err := auth.Middleware(theConfig.Auth)
+		if err != nil {
+			panic(err)
+		}
+		r.Use(authMiddleware)
+	}
 	r.GET("/", func(context *gin.Context) {
 		context.String(200, "success")
 	})
 	r.GET("/ping", func(context *gin.Context) {
 		context.String(200, "cudgx/api-service is running")
 	})
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	r.GET("/healthz/live", handler.Liveness)
+	r.GET("/healthz/ready", handler.Readiness)
+	r.GET("/healthz/db", handler.DBHealth)
 	//redundancyGroup := r.Group("/api/v1/query/redundancy")
 	//{
 	//	redundancyGroup.GET("/qps_average", handler.QueryRedundancyByQPS)
@@ -65,6 +114,33 @@ func main() {
 		metricGroup.GET("/load/:metric_name", handler.QueryTotalMetric)
 	}
 
+	r.GET("/api/v1/cudgx/cost-summary", handler.GetCostSummary)
+	r.GET("/api/v1/cudgx/keeper/stats", handler.GetKeeperStats)
+	r.POST("/api/v1/cudgx/admin/prune", handler.PruneScalingEvents)
+	r.GET("/api/v1/cudgx/rules", handler.ListPredictRulesByMetric)
+	r.GET("/api/v1/cudgx/rules/watch", handler.WatchRules)
+	r.GET("/api/v1/cudgx/rules/export", handler.ExportRulesForMigration)
+	r.POST("/api/v1/cudgx/rules/import", handler.ImportRulesFromMigration)
+	r.PATCH("/api/v1/cudgx/rules/bulk", handler.BulkUpdatePredictRules)
+	r.GET("/api/v1/cudgx/rules/:id/explain", handler.ExplainPredictRule)
+	r.GET("/api/v1/cudgx/rules/:id/changelog", handler.ListRuleChangelog)
+	r.POST("/api/v1/cudgx/rules/:id/clone", handler.ClonePredictRule)
+	r.POST("/api/v1/cudgx/rules/:id/calibrate", handler.CalibratePredictRule)
+	r.POST("/api/v1/cudgx/rules/:id/override", handler.SetPredictRuleOverride)
+	r.DELETE("/api/v1/cudgx/rules/:id/override", handler.ClearPredictRuleOverride)
+	r.GET("/api/v1/cudgx/approvals", handler.ListPendingApprovals)
+	r.POST("/api/v1/cudgx/approvals/:id/approve", handler.ApprovePendingAction)
+	r.POST("/api/v1/cudgx/approvals/:id/reject", handler.RejectPendingAction)
+
+	customMetricsApi := r.Group("/apis/custom.metrics.k8s.io/v1beta1")
+	{
+		customMetricsApi.GET("", handler.ListCustomMetrics)
+		customMetricsApi.GET("/namespaces/:namespace/pods/:pod/:metric", handler.GetCustomMetric)
+	}
+	r.GET("/api/v1/cudgx/metrics", handler.ListMetricNames)
+	r.GET("/api/v1/cudgx/services/:service/clusters/:cluster/report", handler.GetRedundancyReport)
+	r.GET("/api/v1/cudgx/services/:service/topology", handler.GetServiceTopology)
+
 	predictApiV1 := r.Group("/api/v1/cudgx/predict")
 	rulePath := predictApiV1.Group("/rule")
 	{
@@ -89,3 +165,13 @@ func main() {
 		panic("server start failed")
 	}
 }
+
+//runOnce 执行一轮调度，向标准输出打印统计后退出，有规则执行失败时退出码为1
+func runOnce() {
+	summary := predict.StartRedundancyKeeper(context.Background())
+	if err := json.NewEncoder(os.Stdout).Encode(summary); err != nil {
+		logger.GetLogger().Error("print schedule summary failed", zap.Error(err))
+	}
+	_ = logger.GetLogger().Sync()
+	os.Exit(summary.ExitCode())
+}
@@ -101,9 +101,9 @@ func RemoteWrite(c *gin.Context) {
 		labels := make(map[string]string, len(ts.Labels))
 		for _, label := range ts.Labels {
 			labels[label.Name] = label.Value
-			if label.Name == "ip" && ip == ""{
+			if label.Name == "ip" && ip == "" {
 				ip = label.Value
-				service, err := clients.GetServiceByIp(ip)
+				service, err := clients.GetServiceByIp(c.Request.Context(), ip)
 				if err != nil {
 					logger.GetLogger().Sugar().Errorf("GetServiceByIp failed")
 					continue
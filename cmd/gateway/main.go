@@ -8,6 +8,7 @@ import (
 	"github.com/galaxy-future/cudgx/common/logger"
 	"github.com/galaxy-future/cudgx/internal/gateway"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 )
 
@@ -35,6 +36,7 @@ func main() {
 		c.String(200, "success")
 	})
 	r.GET("/ping", handler.HandlerPing)
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 	r.POST("/v1/monitoring/:service/:metric", handler.HandlerMonitoringMessageBatch)
 	r.POST("/v1/streaming/:service/:metric", handler.HandlerStreamingMessageBatch)
 	r.POST("/v1/prom/remote/write", handler.RemoteWrite)
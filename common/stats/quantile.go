@@ -0,0 +1,24 @@
+package stats
+
+import "math"
+
+//Quantile 计算已升序排序序列的q分位数(0 <= q <= 1)，位置不是整数时在相邻两个值之间线性插值；
+//序列为空或q越界时返回NaN
+func Quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 || q < 0 || q > 1 || math.IsNaN(q) {
+		return math.NaN()
+	}
+	position := q * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	upper := int(math.Ceil(position))
+	if lower == upper || sorted[lower] == sorted[upper] {
+		return sorted[lower]
+	}
+	fraction := position - float64(lower)
+	return sorted[lower] + (sorted[upper]-sorted[lower])*fraction
+}
+
+//Median 计算已升序排序序列的中位数
+func Median(sorted []float64) float64 {
+	return Quantile(sorted, 0.5)
+}
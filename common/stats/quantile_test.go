@@ -0,0 +1,46 @@
+package stats_test
+
+import (
+	"math"
+
+	"github.com/galaxy-future/cudgx/common/stats"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Quantile", func() {
+	table.DescribeTable("interpolates between adjacent values",
+		func(sorted []float64, q float64, expected float64) {
+			gomega.Expect(stats.Quantile(sorted, q)).To(gomega.BeNumerically("~", expected, 1e-9))
+		},
+		table.Entry("single element median", []float64{7}, 0.5, 7.0),
+		table.Entry("single element min", []float64{7}, 0.0, 7.0),
+		table.Entry("single element max", []float64{7}, 1.0, 7.0),
+		table.Entry("odd length median", []float64{1, 2, 3, 4, 5}, 0.5, 3.0),
+		table.Entry("even length median", []float64{1, 2, 3, 4}, 0.5, 2.5),
+		table.Entry("even length median uneven gap", []float64{1, 2, 10, 20}, 0.5, 6.0),
+		table.Entry("min", []float64{1, 2, 3, 4}, 0.0, 1.0),
+		table.Entry("max", []float64{1, 2, 3, 4}, 1.0, 4.0),
+		table.Entry("first quartile", []float64{1, 2, 3, 4, 5}, 0.25, 2.0),
+		table.Entry("non integer position", []float64{10, 20, 30, 40}, 0.9, 37.0),
+		table.Entry("duplicated values", []float64{2, 2, 2, 2}, 0.3, 2.0),
+	)
+
+	ginkgo.It("keeps infinity between two infinite values", func() {
+		gomega.Expect(math.IsInf(stats.Quantile([]float64{1, math.Inf(1), math.Inf(1)}, 0.75), 1)).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("returns NaN for an empty slice", func() {
+		gomega.Expect(math.IsNaN(stats.Quantile(nil, 0.5))).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("returns NaN when q is out of range", func() {
+		gomega.Expect(math.IsNaN(stats.Quantile([]float64{1, 2}, -0.1))).To(gomega.BeTrue())
+		gomega.Expect(math.IsNaN(stats.Quantile([]float64{1, 2}, 1.1))).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("Median is the 0.5 quantile", func() {
+		gomega.Expect(stats.Median([]float64{1, 3, 5, 7})).To(gomega.Equal(4.0))
+	})
+})
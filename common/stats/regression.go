@@ -0,0 +1,30 @@
+package stats
+
+import "math"
+
+//LinearRegression 用最小二乘法拟合直线 y = slope*x + intercept；x 全部相同时 slope 为0、intercept 为y的平均值，
+//序列为空或长度不同时返回NaN
+func LinearRegression(x, y []float64) (slope, intercept float64) {
+	if len(x) == 0 || len(x) != len(y) {
+		return math.NaN(), math.NaN()
+	}
+	n := float64(len(x))
+	var sumX, sumY float64
+	for i := range x {
+		sumX += x[i]
+		sumY += y[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+	// 先减去平均值，x 为时间戳时避免平方和损失精度
+	var covariance, variance float64
+	for i := range x {
+		dx := x[i] - meanX
+		covariance += dx * (y[i] - meanY)
+		variance += dx * dx
+	}
+	if variance == 0 {
+		return 0, meanY
+	}
+	slope = covariance / variance
+	return slope, meanY - slope*meanX
+}
@@ -0,0 +1,45 @@
+package stats_test
+
+import (
+	"math"
+
+	"github.com/galaxy-future/cudgx/common/stats"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("LinearRegression", func() {
+	ginkgo.It("fits points on a line", func() {
+		slope, intercept := stats.LinearRegression([]float64{0, 1, 2, 3}, []float64{1, 3, 5, 7})
+		gomega.Expect(slope).To(gomega.BeNumerically("~", 2, 1e-9))
+		gomega.Expect(intercept).To(gomega.BeNumerically("~", 1, 1e-9))
+	})
+
+	ginkgo.It("extrapolates timestamps without losing precision", func() {
+		x := []float64{1640000000, 1640000010, 1640000020, 1640000030}
+		y := []float64{2, 1.9, 1.8, 1.7}
+		slope, intercept := stats.LinearRegression(x, y)
+		gomega.Expect(slope).To(gomega.BeNumerically("~", -0.01, 1e-9))
+		gomega.Expect(slope*1640000090 + intercept).To(gomega.BeNumerically("~", 1.1, 1e-6))
+	})
+
+	ginkgo.It("fits noisy points with least squares", func() {
+		slope, intercept := stats.LinearRegression([]float64{0, 1, 2}, []float64{0, 2, 1})
+		gomega.Expect(slope).To(gomega.BeNumerically("~", 0.5, 1e-9))
+		gomega.Expect(intercept).To(gomega.BeNumerically("~", 0.5, 1e-9))
+	})
+
+	ginkgo.It("returns the mean when x does not vary", func() {
+		slope, intercept := stats.LinearRegression([]float64{5, 5}, []float64{1, 3})
+		gomega.Expect(slope).To(gomega.Equal(0.0))
+		gomega.Expect(intercept).To(gomega.Equal(2.0))
+	})
+
+	ginkgo.It("returns NaN for invalid input", func() {
+		slope, intercept := stats.LinearRegression(nil, nil)
+		gomega.Expect(math.IsNaN(slope)).To(gomega.BeTrue())
+		gomega.Expect(math.IsNaN(intercept)).To(gomega.BeTrue())
+		slope, _ = stats.LinearRegression([]float64{1, 2}, []float64{1})
+		gomega.Expect(math.IsNaN(slope)).To(gomega.BeTrue())
+	})
+})
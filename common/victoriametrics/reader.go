@@ -1,6 +1,7 @@
 package victoriametrics
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -15,30 +16,56 @@ import (
 type Reader struct {
 	Client *http.Client `json:"client"`
 	VmUrl  string       `json:"vm_url"`
+	// BearerToken 不为空时请求带 Authorization: Bearer
+	BearerToken string `json:"bearer_token"`
+	// Username 不为空时请求使用 basic auth
+	Username string `json:"username"`
+	Password string `json:"password"`
 }
 
 // NewReader 新建VictoriaMetrics Reader
 func NewReader(config *Config) *Reader {
 	cli := createHTTPClient(config)
 	return &Reader{
-		Client: cli,
-		VmUrl:  config.Reader.VmUrl,
+		Client:      cli,
+		VmUrl:       config.Reader.VmUrl,
+		BearerToken: config.Reader.BearerToken,
+		Username:    config.Reader.Username,
+		Password:    config.Reader.Password,
 	}
 }
 
 // QueryRange Prometheus query_range API
 func (r Reader) QueryRange(query string, start, end int64, step time.Duration) (*Response, error) {
+	return r.QueryRangeWithContext(context.Background(), query, start, end, step)
+}
+
+// QueryRangeWithContext Prometheus query_range API，ctx 取消或超时时中断请求
+func (r Reader) QueryRangeWithContext(ctx context.Context, query string, start, end int64, step time.Duration) (*Response, error) {
+	return r.QueryRangeWithParams(ctx, query, start, end, step, nil)
+}
+
+// QueryRangeWithParams Prometheus query_range API，params 为后端特有的额外查询参数
+func (r Reader) QueryRangeWithParams(ctx context.Context, query string, start, end int64, step time.Duration, params url.Values) (*Response, error) {
 	u := url.Values{}
+	for key, values := range params {
+		u[key] = values
+	}
 	u.Set("query", query)
 	u.Set("start", strconv.FormatInt(start, 10))
 	u.Set("end", strconv.FormatInt(end, 10))
 	u.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
 
-	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/api/v1/query_range", r.VmUrl), strings.NewReader(u.Encode()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/api/v1/query_range", r.VmUrl), strings.NewReader(u.Encode()))
 	if err != nil {
-		return nil, nil
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if r.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+r.BearerToken)
+	} else if r.Username != "" {
+		req.SetBasicAuth(r.Username, r.Password)
+	}
 	resp, err := r.Client.Do(req)
 	if err != nil {
 		return nil, err
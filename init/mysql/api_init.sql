@@ -1,5 +1,7 @@
 use cudgx;
 
+DROP TABLE IF EXISTS `pending_approvals`;
+DROP TABLE IF EXISTS `scaling_events`;
 DROP TABLE IF EXISTS `predict_rules`;
 CREATE TABLE `predict_rules`
 (
@@ -14,9 +16,116 @@ CREATE TABLE `predict_rules`
     `min_instance_count` INT(11) NOT NULL,
     `max_instance_count` INT(11) NOT NULL,
     `execute_ratio`      INT(11) NOT NULL,
+    `use_gradual_expand` TINYINT(1) NOT NULL DEFAULT 0,
+    `gradual_batch_size` INT(11) NOT NULL DEFAULT 0,
+    `recovery_threshold` DOUBLE NOT NULL DEFAULT 0,
+    `metric_query_mode`  VARCHAR(32) NOT NULL DEFAULT 'raw',
+    `recording_rule_metric_name` VARCHAR(255) NOT NULL DEFAULT '',
+    `min_qps_threshold`  DOUBLE NOT NULL DEFAULT 0,
+    `cloned_from_rule_id` INT(11) NOT NULL DEFAULT 0,
+    `use_expand_and_wait` TINYINT(1) NOT NULL DEFAULT 0,
+    `readiness_timeout_seconds` INT(11) NOT NULL DEFAULT 0,
+    `metric_scope`       VARCHAR(32) NOT NULL DEFAULT 'service',
+    `error_count`        INT(11) NOT NULL DEFAULT 0,
+    `error_message`      VARCHAR(1024) NOT NULL DEFAULT '',
+    `max_rate_of_change_percent` DOUBLE NOT NULL DEFAULT 0,
+    `benchmark_auto_learn` TINYINT(1) NOT NULL DEFAULT 0,
+    `benchmark_auto_learn_threshold_pct` DOUBLE NOT NULL DEFAULT 0,
+    `allowed_service_pattern` VARCHAR(255) NOT NULL DEFAULT '',
+    `allowed_cluster_pattern` VARCHAR(255) NOT NULL DEFAULT '',
+    `green_blue_mode`    TINYINT(1) NOT NULL DEFAULT 0,
+    `color_metric_label` VARCHAR(100) NOT NULL DEFAULT '',
+    `forecast_horizon_seconds` INT(11) NOT NULL DEFAULT 0,
+    `shrink_preference`  VARCHAR(32) NOT NULL DEFAULT 'default',
+    `metric_aggregation_window_seconds` INT(11) NOT NULL DEFAULT 0,
+    `calibrated_at`      DATETIME NULL DEFAULT NULL,
+    `last_scaled_at`     DATETIME NULL DEFAULT NULL,
+    `auto_discovered`    TINYINT(1) NOT NULL DEFAULT 0,
+    `review_required`    TINYINT(1) NOT NULL DEFAULT 0,
+    `review_threshold`   INT(11) NOT NULL DEFAULT 0,
+    `multi_cluster_mode` TINYINT(1) NOT NULL DEFAULT 0,
+    `max_shrink_percent` DOUBLE NOT NULL DEFAULT 0,
+    `max_expand_percent` DOUBLE NOT NULL DEFAULT 0,
+    `depends_on_rule_id` BIGINT DEFAULT NULL,
+    `alert_on_high_redundancy` DOUBLE NOT NULL DEFAULT 0,
+    `alert_cooldown_minutes` INT(11) NOT NULL DEFAULT 0,
+    `instance_count_multiplier_schedule` JSON DEFAULT NULL,
+    `traffic_split_source` VARCHAR(64) NOT NULL DEFAULT '',
+    `scale_up_policy`    VARCHAR(32) NOT NULL DEFAULT 'immediate',
+    `scale_down_policy`  VARCHAR(32) NOT NULL DEFAULT 'immediate',
+    `consecutive_ticks_required` INT(11) NOT NULL DEFAULT 0,
+    `cluster_type`       VARCHAR(32) NOT NULL DEFAULT 'schedulx',
+    `healthy_instances_only` TINYINT(1) NOT NULL DEFAULT 0,
+    `metric_backend_alias` VARCHAR(64) NOT NULL DEFAULT '',
+    `metric_missing_action` VARCHAR(32) NOT NULL DEFAULT '',
+    `notify_on_creation` TINYINT(1) NOT NULL DEFAULT 0,
+    `notify_on_deletion` TINYINT(1) NOT NULL DEFAULT 0,
+    `verify_shrink_after_seconds` INT NOT NULL DEFAULT 0,
+    `check_quota_before_expand` TINYINT(1) NOT NULL DEFAULT 0,
+    `shrink_consecutive_ticks` INT NOT NULL DEFAULT 0,
+    `max_scale_up_absolute` INT NOT NULL DEFAULT 0,
+    `max_scale_down_absolute` INT NOT NULL DEFAULT 0,
     `status`             VARCHAR(50)  NOT NULL DEFAULT 'enable',
     `created_time`       INT(11) NOT NULL,
+    `deleted_at`         DATETIME NULL DEFAULT NULL,
+    -- 未删除时为1，软删除后为 NULL，使唯一索引只约束未删除的规则
+    `not_deleted`        TINYINT(1) AS (IF(`deleted_at` IS NULL, 1, NULL)) STORED,
     PRIMARY KEY (`id`) USING BTREE,
-    UNIQUE INDEX `uniq_name` (`name`) USING BTREE,
-    UNIQUE INDEX `uniq_cname_sname_mname` (`service_name`, `cluster_name`, `metric_name`) USING BTREE
-) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
\ No newline at end of file
+    UNIQUE INDEX `uniq_name` (`name`, `not_deleted`) USING BTREE,
+    UNIQUE INDEX `uniq_sname_cname` (`service_name`, `cluster_name`, `not_deleted`) USING BTREE,
+    INDEX `idx_deleted_at` (`deleted_at`) USING BTREE,
+    INDEX `idx_mname` (`metric_name`) USING BTREE,
+    INDEX `idx_status_mname` (`status`, `metric_name`) USING BTREE
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+CREATE TABLE `scaling_events`
+(
+    `id`                      INT(11) NOT NULL AUTO_INCREMENT,
+    `rule_id`                 INT(11) NULL,
+    `service_name`            VARCHAR(255) NOT NULL,
+    `cluster_name`            VARCHAR(255) NOT NULL,
+    `action`                  VARCHAR(50)  NOT NULL,
+    `count`                   INT(11) NOT NULL,
+    `instance_count`          INT(11) NOT NULL,
+    `redundancy`              DOUBLE NOT NULL DEFAULT 0,
+    `estimated_cost_per_hour` DOUBLE NOT NULL DEFAULT 0,
+    `timestamp`               INT(11) NOT NULL,
+    PRIMARY KEY (`id`) USING BTREE,
+    INDEX `idx_sname_cname_timestamp` (`service_name`, `cluster_name`, `timestamp`) USING BTREE,
+    CONSTRAINT `fk_rule_id` FOREIGN KEY (`rule_id`) REFERENCES `predict_rules` (`id`) ON DELETE SET NULL
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+CREATE TABLE `pending_approvals`
+(
+    `id`             INT(11) NOT NULL AUTO_INCREMENT,
+    `rule_id`        INT(11) NOT NULL,
+    `service_name`   VARCHAR(255) NOT NULL,
+    `cluster_name`   VARCHAR(255) NOT NULL,
+    `action`         VARCHAR(50)  NOT NULL,
+    `count`          INT(11) NOT NULL,
+    `instance_count` INT(11) NOT NULL,
+    `redundancy`     DOUBLE NOT NULL DEFAULT 0,
+    `status`         VARCHAR(32)  NOT NULL,
+    `approver`       VARCHAR(255) NOT NULL DEFAULT '',
+    `message`        VARCHAR(1024) NOT NULL DEFAULT '',
+    `expire_at`      INT(11) NOT NULL,
+    `created_time`   INT(11) NOT NULL,
+    `updated_time`   INT(11) NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`) USING BTREE,
+    INDEX `idx_status_rule_id` (`status`, `rule_id`) USING BTREE,
+    CONSTRAINT `fk_approval_rule_id` FOREIGN KEY (`rule_id`) REFERENCES `predict_rules` (`id`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+CREATE TABLE `rule_changelogs`
+(
+    `id`         INT(11) NOT NULL AUTO_INCREMENT,
+    `rule_id`    INT(11) NULL,
+    `changed_by` VARCHAR(255) NOT NULL DEFAULT '',
+    `changed_at` INT(11) NOT NULL,
+    `field_name` VARCHAR(255) NOT NULL,
+    `old_value`  TEXT NOT NULL,
+    `new_value`  TEXT NOT NULL,
+    PRIMARY KEY (`id`) USING BTREE,
+    INDEX `idx_rule_id` (`rule_id`) USING BTREE,
+    CONSTRAINT `fk_changelog_rule_id` FOREIGN KEY (`rule_id`) REFERENCES `predict_rules` (`id`) ON DELETE SET NULL
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
@@ -0,0 +1,72 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+//activeColorCacheTTL 蓝绿部署当前生效颜色的缓存时间
+const activeColorCacheTTL = 30 * time.Second
+
+var activeColorCache = newLRUCache(scheduleCacheSize)
+
+type GetActiveColorResponse struct {
+	Code int64            `json:"code"`
+	Msg  string           `json:"msg"`
+	Data ServiceColorData `json:"data"`
+}
+
+type ServiceColorData struct {
+	ServiceName string `json:"service_name"`
+	ActiveColor string `json:"active_color"`
+}
+
+type activeColorCacheEntry struct {
+	color    string
+	expireAt time.Time
+}
+
+// GetActiveColor 查询蓝绿部署的服务当前生效的颜色，结果缓存30秒
+func GetActiveColor(serviceName string) (string, error) {
+	return GetActiveColorWithContext(context.Background(), serviceName)
+}
+
+// GetActiveColorWithContext 查询蓝绿部署的服务当前生效的颜色，ctx 中的 request id 会随请求发送
+func GetActiveColorWithContext(ctx context.Context, serviceName string) (string, error) {
+	if serviceName == "" {
+		return "", fmt.Errorf("服务名称不能为空")
+	}
+	if value, ok := activeColorCache.Get(serviceName); ok {
+		if entry := value.(activeColorCacheEntry); time.Now().Before(entry.expireAt) {
+			return entry.color, nil
+		}
+		activeColorCache.Remove(serviceName)
+	}
+	resp, err := schedulxGet(ctx, fmt.Sprintf("%s/api/v1/schedulx/service/active_color?service_name=%s", schedulxClient.ServerAddress, serviceName))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	respData, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	var response GetActiveColorResponse
+	err = json.Unmarshal(respData, &response)
+	if err != nil {
+		return "", err
+	}
+	if response.Code != http.StatusOK {
+		err = fmt.Errorf("http code:%v | msg:%v", response.Code, response.Msg)
+		return "", err
+	}
+	if response.Data.ActiveColor == "" {
+		return "", fmt.Errorf("服务 %s 没有生效的颜色", serviceName)
+	}
+	activeColorCache.Add(serviceName, activeColorCacheEntry{color: response.Data.ActiveColor, expireAt: time.Now().Add(activeColorCacheTTL)})
+	return response.Data.ActiveColor, nil
+}
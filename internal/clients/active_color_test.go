@@ -0,0 +1,49 @@
+package clients_test
+
+import (
+	"net/http"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("GetActiveColor", func() {
+	var stopSchedulx func()
+	var requests int
+
+	ginkgo.BeforeEach(func() {
+		requests = 0
+		_, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v1/schedulx/service/active_color":
+				requests++
+				if r.URL.Query().Get("service_name") == "gf.cudgx.none" {
+					_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":{"service_name":"gf.cudgx.none"}}`))
+					return
+				}
+				_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":{"service_name":"gf.cudgx.color","active_color":"blue"}}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		stopSchedulx()
+	})
+
+	ginkgo.It("caches the active color", func() {
+		for i := 0; i < 3; i++ {
+			color, err := clients.GetActiveColor("gf.cudgx.color")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(color).To(gomega.Equal("blue"))
+		}
+		gomega.Expect(requests).To(gomega.Equal(1))
+	})
+
+	ginkgo.It("fails without an active color", func() {
+		_, err := clients.GetActiveColor("gf.cudgx.none")
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+})
@@ -0,0 +1,74 @@
+package clients
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//ipCacheSize ip->服务 缓存容量
+const ipCacheSize = 1000
+
+//defaultCacheStatsRefreshDuration 未设置时缓存大小指标的刷新周期，与默认调度周期一致
+const defaultCacheStatsRefreshDuration = 60 * time.Second
+
+//cacheStatsRefreshDuration 缓存大小指标刷新周期，由 SetCacheStatsRefreshDuration 设置为调度周期
+var cacheStatsRefreshDuration atomic.Int64
+
+var (
+	ipCacheHitsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "cudgx_ip_cache_hits_total",
+		Help: "Number of GetServiceByIp lookups served from the LRU cache.",
+	})
+	ipCacheMissesCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "cudgx_ip_cache_misses_total",
+		Help: "Number of GetServiceByIp lookups that fell through to schedulx.",
+	})
+	ipCacheSizeGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cudgx_ip_cache_size",
+		Help: "Number of entries in the GetServiceByIp LRU cache.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(ipCacheHitsCounter, ipCacheMissesCounter, ipCacheSizeGauge)
+}
+
+//CacheStats ip->服务 缓存统计
+type CacheStats struct {
+	//Len 当前缓存条目数
+	Len int `json:"len"`
+	//Cap 缓存容量
+	Cap int `json:"cap"`
+}
+
+//GetCacheStats 获取 GetServiceByIp 缓存统计
+func GetCacheStats() CacheStats {
+	return CacheStats{
+		Len: cache.Len(),
+		Cap: ipCacheSize,
+	}
+}
+
+//SetCacheStatsRefreshDuration 设置缓存大小指标的刷新周期，不大于0时使用默认的60秒；下一次刷新后生效
+func SetCacheStatsRefreshDuration(duration time.Duration) {
+	cacheStatsRefreshDuration.Store(int64(duration))
+}
+
+func cacheStatsRefreshInterval() time.Duration {
+	if duration := time.Duration(cacheStatsRefreshDuration.Load()); duration > 0 {
+		return duration
+	}
+	return defaultCacheStatsRefreshDuration
+}
+
+func refreshCacheSizeGauge() interface{} {
+	go func() {
+		for {
+			ipCacheSizeGauge.Set(float64(cache.Len()))
+			time.Sleep(cacheStatsRefreshInterval())
+		}
+	}()
+	return nil
+}
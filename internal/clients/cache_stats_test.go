@@ -0,0 +1,73 @@
+package clients_test
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func counterValue(name string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	gomega.Expect(err).To(gomega.BeNil())
+	for _, family := range families {
+		if family.GetName() == name {
+			return family.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+var _ = ginkgo.Describe("GetServiceByIp cache", func() {
+	var stopSchedulx func()
+	var requests int
+
+	ginkgo.BeforeEach(func() {
+		requests = 0
+		_, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":{"service_name":"gf.cudgx.pi","cluster_name":"default"}}`))
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		stopSchedulx()
+	})
+
+	ginkgo.It("counts hits and misses", func() {
+		hits := counterValue("cudgx_ip_cache_hits_total")
+		misses := counterValue("cudgx_ip_cache_misses_total")
+
+		data, err := clients.GetServiceByIp(context.Background(), "10.0.0.201")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(data.ServiceName).To(gomega.Equal("gf.cudgx.pi"))
+		gomega.Expect(counterValue("cudgx_ip_cache_misses_total")).To(gomega.Equal(misses + 1))
+		gomega.Expect(counterValue("cudgx_ip_cache_hits_total")).To(gomega.Equal(hits))
+
+		_, err = clients.GetServiceByIp(context.Background(), "10.0.0.201")
+		gomega.Expect(err).To(gomega.BeNil())
+		_, err = clients.GetServiceByIp(context.Background(), "10.0.0.201")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(counterValue("cudgx_ip_cache_hits_total")).To(gomega.Equal(hits + 2))
+		gomega.Expect(counterValue("cudgx_ip_cache_misses_total")).To(gomega.Equal(misses + 1))
+		gomega.Expect(requests).To(gomega.Equal(1))
+
+		stats := clients.GetCacheStats()
+		gomega.Expect(stats.Len).To(gomega.BeNumerically(">=", 1))
+		gomega.Expect(stats.Cap).To(gomega.Equal(1000))
+	})
+
+	ginkgo.It("reports whether the result came from the cache", func() {
+		_, fromCache, err := clients.GetServiceByIpWithCacheStatus(context.Background(), "10.0.0.202")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(fromCache).To(gomega.BeFalse())
+
+		_, fromCache, err = clients.GetServiceByIpWithCacheStatus(context.Background(), "10.0.0.202")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(fromCache).To(gomega.BeTrue())
+		gomega.Expect(requests).To(gomega.Equal(1))
+	})
+})
@@ -0,0 +1,84 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+)
+
+var (
+	cloudQuotaCache = newLRUCache(scheduleCacheSize)
+	cloudQuotaTTL   atomic.Int64
+)
+
+func init() {
+	cloudQuotaTTL.Store(int64(consts.DefaultQuotaCacheTTL))
+}
+
+type GetCloudQuotaResponse struct {
+	Code int64      `json:"code"`
+	Msg  string     `json:"msg"`
+	Data CloudQuota `json:"data"`
+}
+
+type CloudQuota struct {
+	//QuotaRemaining 集群所在云账号还能创建的实例数
+	QuotaRemaining int `json:"quota_remaining"`
+}
+
+type cloudQuotaCacheEntry struct {
+	remaining int
+	expireAt  time.Time
+}
+
+//SetQuotaCacheTTL 设置云厂商剩余配额的缓存时间，不大于0时不缓存
+func SetQuotaCacheTTL(ttl time.Duration) {
+	cloudQuotaTTL.Store(int64(ttl))
+}
+
+// GetCloudQuotaRemaining 获取集群所在云账号剩余可创建的实例数，结果按集群缓存 SetQuotaCacheTTL 设置的时间，默认60秒；
+// 通过本包扩容成功后缓存立即失效
+func GetCloudQuotaRemaining(ctx context.Context, clusterName string) (int, error) {
+	if clusterName == "" {
+		return 0, fmt.Errorf("集群名称不能为空")
+	}
+	if value, ok := cloudQuotaCache.Get(clusterName); ok {
+		if entry := value.(cloudQuotaCacheEntry); time.Now().Before(entry.expireAt) {
+			return entry.remaining, nil
+		}
+		cloudQuotaCache.Remove(clusterName)
+	}
+	resp, err := schedulxGet(ctx, fmt.Sprintf("%s/api/v1/schedulx/cluster/quota?service_cluster_name=%s", schedulxClient.ServerAddress, clusterName))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	respData, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	var response GetCloudQuotaResponse
+	err = json.Unmarshal(respData, &response)
+	if err != nil {
+		return 0, err
+	}
+	if response.Code != http.StatusOK {
+		err = fmt.Errorf("http code:%v | msg:%v", response.Code, response.Msg)
+		return 0, err
+	}
+	if ttl := time.Duration(cloudQuotaTTL.Load()); ttl > 0 {
+		cloudQuotaCache.Add(clusterName, cloudQuotaCacheEntry{remaining: response.Data.QuotaRemaining, expireAt: time.Now().Add(ttl)})
+	}
+	return response.Data.QuotaRemaining, nil
+}
+
+//invalidateCloudQuotaCache 扩容后剩余配额已减少，清除缓存的配额
+func invalidateCloudQuotaCache(clusterName string) {
+	cloudQuotaCache.Remove(clusterName)
+}
@@ -0,0 +1,80 @@
+package clients_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("GetCloudQuotaRemaining", func() {
+	var stopSchedulx func()
+	var queries []string
+	var remaining int
+
+	ginkgo.BeforeEach(func() {
+		queries = nil
+		remaining = 8
+		_, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v1/schedulx/cluster/quota":
+				queries = append(queries, r.URL.RawQuery)
+				_, _ = fmt.Fprintf(w, `{"code":200,"msg":"success","data":{"quota_remaining":%d}}`, remaining)
+			case "/api/v1/schedulx/service/expand":
+				remaining -= 2
+				_, _ = w.Write([]byte(`{"code":200,"msg":"success"}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		clients.SetQuotaCacheTTL(consts.DefaultQuotaCacheTTL)
+		stopSchedulx()
+	})
+
+	ginkgo.It("caches the remaining quota per cluster until an expand succeeds", func() {
+		for i := 0; i < 2; i++ {
+			quota, err := clients.GetCloudQuotaRemaining(context.Background(), "quota-cached")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(quota).To(gomega.Equal(8))
+		}
+		gomega.Expect(queries).To(gomega.Equal([]string{"service_cluster_name=quota-cached"}))
+
+		gomega.Expect(clients.ExpandService("gf.cudgx.pi", "quota-cached", 2, "")).To(gomega.BeNil())
+		quota, err := clients.GetCloudQuotaRemaining(context.Background(), "quota-cached")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(quota).To(gomega.Equal(6))
+		gomega.Expect(queries).To(gomega.HaveLen(2))
+	})
+
+	ginkgo.It("does not cache when the ttl is not positive", func() {
+		clients.SetQuotaCacheTTL(0)
+		for i := 0; i < 2; i++ {
+			_, err := clients.GetCloudQuotaRemaining(context.Background(), "quota-uncached")
+			gomega.Expect(err).To(gomega.BeNil())
+		}
+		gomega.Expect(queries).To(gomega.HaveLen(2))
+	})
+
+	ginkgo.It("expires the cached quota after the ttl", func() {
+		clients.SetQuotaCacheTTL(10 * time.Millisecond)
+		_, err := clients.GetCloudQuotaRemaining(context.Background(), "quota-expired")
+		gomega.Expect(err).To(gomega.BeNil())
+		time.Sleep(20 * time.Millisecond)
+		_, err = clients.GetCloudQuotaRemaining(context.Background(), "quota-expired")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(queries).To(gomega.HaveLen(2))
+	})
+
+	ginkgo.It("requires a cluster name", func() {
+		_, err := clients.GetCloudQuotaRemaining(context.Background(), "")
+		gomega.Expect(err).NotTo(gomega.BeNil())
+	})
+})
@@ -0,0 +1,55 @@
+package clients_test
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("GetServiceInstanceCountByCluster", func() {
+	var stopSchedulx func()
+	var queries []string
+
+	ginkgo.BeforeEach(func() {
+		queries = nil
+		_, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v1/schedulx/instance/count":
+				queries = append(queries, r.URL.RawQuery)
+				_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":{"service_cluster_list":[` +
+					`{"service_cluster_name":"prod-us","instance_count":10},` +
+					`{"service_cluster_name":"prod-eu","instance_count":30},` +
+					`{"service_cluster_name":"canary","instance_count":1}]}}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		stopSchedulx()
+		gomega.Expect(clients.SetNamePatterns("", "")).To(gomega.BeNil())
+	})
+
+	ginkgo.It("returns the instance count of every cluster", func() {
+		counts, err := clients.GetServiceInstanceCountByCluster(context.Background(), "gf.cudgx.pi")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(counts).To(gomega.Equal(map[string]int{"prod-us": 10, "prod-eu": 30, "canary": 1}))
+		gomega.Expect(queries).To(gomega.Equal([]string{"service_name=gf.cudgx.pi&service_cluster_name="}))
+	})
+
+	ginkgo.It("leaves out clusters not matching the allowed pattern", func() {
+		gomega.Expect(clients.SetNamePatterns("", "^prod-")).To(gomega.BeNil())
+		counts, err := clients.GetServiceInstanceCountByCluster(context.Background(), "gf.cudgx.pi")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(counts).To(gomega.Equal(map[string]int{"prod-us": 10, "prod-eu": 30}))
+
+		gomega.Expect(clients.SetNamePatterns("^gf\\.other", "")).To(gomega.BeNil())
+		_, err = clients.GetServiceInstanceCountByCluster(context.Background(), "gf.cudgx.pi")
+		gomega.Expect(err).NotTo(gomega.BeNil())
+		gomega.Expect(queries).To(gomega.HaveLen(1))
+	})
+})
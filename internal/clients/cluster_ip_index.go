@@ -0,0 +1,143 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+//ClusterInfo ip 所属的服务集群，IPs 为 GetServiceByIp 缓存中属于同一集群的 ip
+type ClusterInfo struct {
+	ServiceName string   `json:"service_name"`
+	ClusterName string   `json:"cluster_name"`
+	IPs         []string `json:"ips"`
+}
+
+//clusterIPIndex GetServiceByIp 缓存的二级索引 集群名 -> ip，缓存淘汰或清空时同步删除
+type clusterIPIndex struct {
+	lock sync.Mutex
+	//clusterIPMap 集群名 -> 该集群已缓存的 ip
+	clusterIPMap map[string]map[string]struct{}
+	//clusterServices 集群名 -> 服务名
+	clusterServices map[string]string
+	//ipClusters ip -> 集群名，ip 被分配到其他集群时从旧集群中删除
+	ipClusters map[string]string
+}
+
+var ipIndex = &clusterIPIndex{
+	clusterIPMap:    make(map[string]map[string]struct{}),
+	clusterServices: make(map[string]string),
+	ipClusters:      make(map[string]string),
+}
+
+//newIPCache GetServiceByIp 的缓存，淘汰的 ip 同时从 ipIndex 中删除
+func newIPCache(size int) *LRUCache {
+	l, err := lru.NewWithEvict(size, func(key interface{}, value interface{}) {
+		if ip, ok := key.(string); ok {
+			ipIndex.remove(ip)
+		}
+	})
+	if err != nil {
+		return nil
+	}
+	return &LRUCache{Cache: l}
+}
+
+//addServiceByIpCache 写入 GetServiceByIp 缓存并更新索引；先更新索引，写入前被清空的 ip 不会留在索引中
+func addServiceByIpCache(ip string, data GetServiceByIpData) {
+	ipIndex.add(ip, data)
+	cache.Add(ip, data)
+}
+
+func (index *clusterIPIndex) add(ip string, data GetServiceByIpData) {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+	if previous, ok := index.ipClusters[ip]; ok && previous != data.ClusterName {
+		index.removeLocked(ip)
+	}
+	ips, ok := index.clusterIPMap[data.ClusterName]
+	if !ok {
+		ips = make(map[string]struct{})
+		index.clusterIPMap[data.ClusterName] = ips
+	}
+	ips[ip] = struct{}{}
+	index.clusterServices[data.ClusterName] = data.ServiceName
+	index.ipClusters[ip] = data.ClusterName
+}
+
+func (index *clusterIPIndex) remove(ip string) {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+	index.removeLocked(ip)
+}
+
+func (index *clusterIPIndex) removeLocked(ip string) {
+	clusterName, ok := index.ipClusters[ip]
+	if !ok {
+		return
+	}
+	delete(index.ipClusters, ip)
+	ips := index.clusterIPMap[clusterName]
+	delete(ips, ip)
+	if len(ips) == 0 {
+		delete(index.clusterIPMap, clusterName)
+		delete(index.clusterServices, clusterName)
+	}
+}
+
+func (index *clusterIPIndex) ips(clusterName string) []string {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+	ips := make([]string, 0, len(index.clusterIPMap[clusterName]))
+	for ip := range index.clusterIPMap[clusterName] {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+func (index *clusterIPIndex) serviceName(clusterName string) (string, bool) {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+	serviceName, ok := index.clusterServices[clusterName]
+	return serviceName, ok
+}
+
+// GetClusterByIp 通过 ip 获取所属的服务集群以及缓存中同一集群的所有 ip，查询方式与 GetServiceByIp 相同
+func GetClusterByIp(ctx context.Context, ip string) (ClusterInfo, error) {
+	data, err := GetServiceByIp(ctx, ip)
+	if err != nil {
+		return ClusterInfo{}, err
+	}
+	return ClusterInfo{ServiceName: data.ServiceName, ClusterName: data.ClusterName, IPs: GetAllIPsForCluster(data.ClusterName)}, nil
+}
+
+// GetAllIPsForCluster GetServiceByIp 缓存中属于该集群的 ip，按字典序排列，不请求 schedulx
+func GetAllIPsForCluster(clusterName string) []string {
+	return ipIndex.ips(clusterName)
+}
+
+// WarmCacheForCluster 用一次实例列表请求把集群所有实例的 ip 写入 GetServiceByIp 缓存，返回写入的 ip 数；
+// 服务名从缓存中获取，集群还没有 ip 被缓存时返回错误
+func WarmCacheForCluster(ctx context.Context, clusterName string) (int, error) {
+	serviceName, ok := ipIndex.serviceName(clusterName)
+	if !ok {
+		return 0, fmt.Errorf("no ip of cluster %s is cached", clusterName)
+	}
+	instances, err := GetServiceInstanceList(ctx, serviceName, clusterName)
+	if err != nil {
+		return 0, err
+	}
+	var warmed int
+	for _, instance := range instances {
+		if instance.IP == "" {
+			continue
+		}
+		addServiceByIpCache(instance.IP, GetServiceByIpData{ServiceName: serviceName, ClusterName: clusterName})
+		warmed++
+	}
+	return warmed, nil
+}
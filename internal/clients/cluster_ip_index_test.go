@@ -0,0 +1,95 @@
+package clients_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("ClusterIPIndex", func() {
+	var stopSchedulx func()
+	var lock sync.Mutex
+	var ipLookups, listLookups int
+
+	ginkgo.BeforeEach(func() {
+		ipLookups, listLookups = 0, 0
+		_, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			defer lock.Unlock()
+			switch r.URL.Path {
+			case "/api/v1/schedulx/instance/service":
+				ipLookups++
+				cluster := "index-a"
+				switch ip := r.URL.Query().Get("ip_inner"); {
+				case ip == "10.3.1.9":
+					cluster = "index-b"
+				case strings.HasPrefix(ip, "10.3.2."):
+					cluster = "index-warm"
+				}
+				_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":{"service_name":"gf.cudgx.index","cluster_name":"` + cluster + `"}}`))
+			case "/api/v1/schedulx/instance/list":
+				listLookups++
+				_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":{"instance_list":[` +
+					`{"ip_inner":"10.3.2.1","instance_id":"i-1"},{"ip_inner":"10.3.2.2","instance_id":"i-2"},{"ip_inner":"10.3.2.3","instance_id":"i-3"}]}}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		stopSchedulx()
+	})
+
+	lookups := func() (int, int) {
+		lock.Lock()
+		defer lock.Unlock()
+		return ipLookups, listLookups
+	}
+
+	ginkgo.It("indexes cached ips by cluster", func() {
+		info, err := clients.GetClusterByIp(context.Background(), "10.3.1.2")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(info.ServiceName).To(gomega.Equal("gf.cudgx.index"))
+		gomega.Expect(info.ClusterName).To(gomega.Equal("index-a"))
+		gomega.Expect(info.IPs).To(gomega.Equal([]string{"10.3.1.2"}))
+
+		_, err = clients.GetServiceByIp(context.Background(), "10.3.1.1")
+		gomega.Expect(err).To(gomega.BeNil())
+		_, err = clients.GetServiceByIp(context.Background(), "10.3.1.9")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(clients.GetAllIPsForCluster("index-a")).To(gomega.Equal([]string{"10.3.1.1", "10.3.1.2"}))
+		gomega.Expect(clients.GetAllIPsForCluster("index-b")).To(gomega.Equal([]string{"10.3.1.9"}))
+		gomega.Expect(clients.GetAllIPsForCluster("index-unknown")).To(gomega.BeEmpty())
+
+		info, err = clients.GetClusterByIp(context.Background(), "10.3.1.1")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(info.IPs).To(gomega.Equal([]string{"10.3.1.1", "10.3.1.2"}))
+		ips, _ := lookups()
+		gomega.Expect(ips).To(gomega.Equal(3))
+	})
+
+	ginkgo.It("warms every instance of a known cluster with one instance list call", func() {
+		_, err := clients.WarmCacheForCluster(context.Background(), "index-warm")
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("no ip of cluster index-warm")))
+
+		_, err = clients.GetServiceByIp(context.Background(), "10.3.2.1")
+		gomega.Expect(err).To(gomega.BeNil())
+		warmed, err := clients.WarmCacheForCluster(context.Background(), "index-warm")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(warmed).To(gomega.Equal(3))
+		gomega.Expect(clients.GetAllIPsForCluster("index-warm")).To(gomega.Equal([]string{"10.3.2.1", "10.3.2.2", "10.3.2.3"}))
+
+		_, fromCache, err := clients.GetServiceByIpWithCacheStatus(context.Background(), "10.3.2.3")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(fromCache).To(gomega.BeTrue())
+		ips, lists := lookups()
+		gomega.Expect(ips).To(gomega.Equal(1))
+		gomega.Expect(lists).To(gomega.Equal(1))
+	})
+})
@@ -0,0 +1,53 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//cudgxClient 调用其他 cudgx 实例管理接口的客户端
+var cudgxClient = &http.Client{Timeout: 5 * time.Second}
+
+//CudgxResponse cudgx 管理接口的响应
+type CudgxResponse struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// DisableRemotePredictRule 调用 baseURL 对应的 cudgx 实例禁用规则，迁移规则后用于禁用来源实例上的规则
+func DisableRemotePredictRule(ctx context.Context, baseURL string, ruleID int64) error {
+	if baseURL == "" {
+		return fmt.Errorf("cudgx 地址不能为空")
+	}
+	url := fmt.Sprintf("%s/api/v1/cudgx/predict/rule/%d/disable", strings.TrimRight(baseURL, "/"), ruleID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return err
+	}
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		req.Header.Set(RequestIDHeader, requestID)
+	}
+	resp, err := cudgxClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respData, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var response CudgxResponse
+	if err := json.Unmarshal(respData, &response); err != nil {
+		return fmt.Errorf("disable rule %d on %s failed, http status %d", ruleID, baseURL, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK || response.Status != "success" {
+		return fmt.Errorf("disable rule %d on %s failed, %s", ruleID, baseURL, response.Message)
+	}
+	return nil
+}
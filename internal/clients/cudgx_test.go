@@ -0,0 +1,48 @@
+package clients_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("DisableRemotePredictRule", func() {
+	var server *httptest.Server
+	var disabled []string
+
+	ginkgo.BeforeEach(func() {
+		disabled = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			switch r.URL.Path {
+			case "/api/v1/cudgx/predict/rule/7/disable":
+				disabled = append(disabled, r.URL.Path)
+				_, _ = w.Write([]byte(`{"status":"success","message":"","data":null}`))
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"status":"failed","message":"record not found","data":null}`))
+			}
+		}))
+	})
+
+	ginkgo.AfterEach(func() {
+		server.Close()
+	})
+
+	ginkgo.It("disables the rule on the remote instance", func() {
+		gomega.Expect(clients.DisableRemotePredictRule(context.Background(), server.URL+"/", 7)).To(gomega.Succeed())
+		gomega.Expect(disabled).To(gomega.Equal([]string{"/api/v1/cudgx/predict/rule/7/disable"}))
+	})
+
+	ginkgo.It("returns the message of a failed response", func() {
+		err := clients.DisableRemotePredictRule(context.Background(), server.URL, 8)
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("record not found")))
+	})
+})
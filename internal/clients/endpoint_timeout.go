@@ -0,0 +1,73 @@
+package clients
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//WithEndpointTimeouts 按请求路径前缀设置超时时间，例如 "/api/v1/schedulx/instance/service"，多个前缀匹配时使用最长的；
+//只能缩短 http.Client 的超时时间，未匹配的请求仍使用 http.Client 的超时时间
+func WithEndpointTimeouts(timeouts map[string]time.Duration) ClientOption {
+	return func(c *Client) {
+		for prefix, timeout := range timeouts {
+			if prefix == "" || timeout <= 0 {
+				continue
+			}
+			if c.EndpointTimeouts == nil {
+				c.EndpointTimeouts = make(map[string]time.Duration)
+			}
+			c.EndpointTimeouts[prefix] = timeout
+		}
+	}
+}
+
+//endpointTimeoutRoundTripper 给匹配 timeouts 前缀的请求加上超时时间
+type endpointTimeoutRoundTripper struct {
+	r        http.RoundTripper
+	timeouts map[string]time.Duration
+}
+
+func (e endpointTimeoutRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	timeout, ok := e.endpointTimeout(r.URL.Path)
+	if !ok {
+		return e.r.RoundTrip(r)
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	resp, err := e.r.RoundTrip(r.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// 读完响应体之前不能取消 ctx
+	resp.Body = cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+//endpointTimeout 最长匹配前缀的超时时间
+func (e endpointTimeoutRoundTripper) endpointTimeout(path string) (time.Duration, bool) {
+	var matched string
+	for prefix := range e.timeouts {
+		if strings.HasPrefix(path, prefix) && len(prefix) > len(matched) {
+			matched = prefix
+		}
+	}
+	if matched == "" {
+		return 0, false
+	}
+	return e.timeouts[matched], true
+}
+
+//cancelOnCloseBody 关闭响应体时取消请求的 ctx
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,46 @@
+package clients_test
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("ExpandService capacity", func() {
+	var stopSchedulx func()
+	var status int
+	var body string
+
+	ginkgo.BeforeEach(func() {
+		clients.RecentIdempotencyKeys.Purge()
+		_, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		stopSchedulx()
+	})
+
+	ginkgo.It("returns ErrCapacityUnavailable for http 429 or code 429", func() {
+		status, body = http.StatusTooManyRequests, `too many`
+		err := clients.ExpandService("gf.cudgx.pi", "default", 8, "")
+		gomega.Expect(errors.Is(err, clients.ErrCapacityUnavailable)).To(gomega.BeTrue())
+
+		status, body = http.StatusOK, `{"code":429,"msg":"no capacity"}`
+		err = clients.ExpandService("gf.cudgx.pi", "default", 8, "")
+		gomega.Expect(errors.Is(err, clients.ErrCapacityUnavailable)).To(gomega.BeTrue())
+		gomega.Expect(err.Error()).To(gomega.ContainSubstring("no capacity"))
+	})
+
+	ginkgo.It("keeps other failures as plain errors", func() {
+		status, body = http.StatusOK, `{"code":500,"msg":"internal error"}`
+		err := clients.ExpandService("gf.cudgx.pi", "default", 8, "")
+		gomega.Expect(err).NotTo(gomega.BeNil())
+		gomega.Expect(errors.Is(err, clients.ErrCapacityUnavailable)).To(gomega.BeFalse())
+	})
+})
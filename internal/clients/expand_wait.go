@@ -0,0 +1,79 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+//ExpandReadinessPollInterval ExpandServiceAndWait 轮询实例数的间隔
+var ExpandReadinessPollInterval = 5 * time.Second
+
+//ErrExpandNotReady 扩容请求已成功，但在 ctx 结束前实例没有就绪
+var ErrExpandNotReady = errors.New("expanded instances are not ready")
+
+var expandReadinessHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "cudgx_expand_readiness_seconds",
+	Help:    "Time from a successful expand request until the readiness check passed.",
+	Buckets: []float64{5, 10, 30, 60, 120, 300, 600},
+}, []string{"service", "cluster"})
+
+func init() {
+	prometheus.MustRegister(expandReadinessHistogram)
+}
+
+//ReadinessCheck 根据当前实例数判断扩容的实例是否就绪
+type ReadinessCheck func(ctx context.Context, currentCount int) bool
+
+//InstanceCountReadinessCheck 实例数达到 baseCount+count 时视为就绪
+func InstanceCountReadinessCheck(baseCount, count int) ReadinessCheck {
+	return func(ctx context.Context, currentCount int) bool {
+		return currentCount >= baseCount+count
+	}
+}
+
+//ExpandServiceAndWait 扩容服务集群并轮询实例数直到 readinessCheck 返回 true，
+//readinessCheck 为空时等待实例数增加 count；ctx 结束前未就绪时返回 ErrExpandNotReady
+func ExpandServiceAndWait(ctx context.Context, serviceName, clusterName string, count int, readinessCheck ReadinessCheck, idempotencyKey string) error {
+	if err := validateParams(serviceName, clusterName, count); err != nil {
+		return err
+	}
+	if readinessCheck == nil {
+		baseCount, err := getServiceInstanceCountUncached(ctx, serviceName, clusterName)
+		if err != nil {
+			return err
+		}
+		readinessCheck = InstanceCountReadinessCheck(baseCount, count)
+	}
+	if err := ExpandServiceWithContext(ctx, serviceName, clusterName, count, idempotencyKey); err != nil {
+		return err
+	}
+
+	begin := time.Now()
+	ticker := time.NewTicker(ExpandReadinessPollInterval)
+	defer ticker.Stop()
+	for {
+		currentCount, err := getServiceInstanceCountUncached(ctx, serviceName, clusterName)
+		if err == nil && readinessCheck(ctx, currentCount) {
+			elapsed := time.Since(begin)
+			expandReadinessHistogram.WithLabelValues(serviceName, clusterName).Observe(elapsed.Seconds())
+			logger.GetLogger().Info("expanded instances are ready", zap.String("service_name", serviceName),
+				zap.String("service_cluster", clusterName), zap.Int("count", count), zap.Duration("elapsed", elapsed))
+			return nil
+		}
+		if err != nil {
+			logger.GetLogger().Warn("query instance count while waiting for readiness failed", zap.String("service_name", serviceName),
+				zap.String("service_cluster", clusterName), zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w after %s, %v", ErrExpandNotReady, time.Since(begin), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package clients_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("ExpandServiceAndWait", func() {
+	var stopSchedulx func()
+	var lock sync.Mutex
+	var instanceCount, pending, countQueries int
+
+	ginkgo.BeforeEach(func() {
+		instanceCount, pending, countQueries = 3, 0, 0
+		clients.ExpandReadinessPollInterval = 10 * time.Millisecond
+		_, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			defer lock.Unlock()
+			switch r.URL.Path {
+			case "/api/v1/schedulx/service/expand":
+				pending = 2
+				_, _ = w.Write([]byte(`{"code":200,"msg":"success"}`))
+			case "/api/v1/schedulx/instance/count":
+				// 扩容后的实例逐个就绪
+				countQueries++
+				if pending > 0 && countQueries > 2 {
+					instanceCount++
+					pending--
+				}
+				_, _ = fmt.Fprintf(w, `{"code":200,"msg":"success","data":{"service_cluster_list":[{"instance_count":%d}]}}`, instanceCount)
+			}
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		clients.ExpandReadinessPollInterval = 5 * time.Second
+		stopSchedulx()
+	})
+
+	ginkgo.It("waits until the instance count has grown by count", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err := clients.ExpandServiceAndWait(ctx, "gf.cudgx.pi", "default", 2, nil, "")
+		gomega.Expect(err).To(gomega.BeNil())
+		lock.Lock()
+		defer lock.Unlock()
+		gomega.Expect(instanceCount).To(gomega.Equal(5))
+	})
+
+	ginkgo.It("uses a custom readiness check", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		var seen []int
+		err := clients.ExpandServiceAndWait(ctx, "gf.cudgx.pi", "default", 2, func(ctx context.Context, currentCount int) bool {
+			seen = append(seen, currentCount)
+			return currentCount >= 4
+		}, "")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(seen[len(seen)-1]).To(gomega.Equal(4))
+	})
+
+	ginkgo.It("returns ErrExpandNotReady when the context ends first", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err := clients.ExpandServiceAndWait(ctx, "gf.cudgx.pi", "default", 2, func(ctx context.Context, currentCount int) bool {
+			return false
+		}, "")
+		gomega.Expect(errors.Is(err, clients.ErrExpandNotReady)).To(gomega.BeTrue())
+	})
+})
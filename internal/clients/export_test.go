@@ -0,0 +1,13 @@
+package clients
+
+//UseXclientServer 把 bridgx 和 schedulx 客户端都指向 serverAddress，返回恢复原客户端的函数；只在测试中使用，期间不能并发请求
+func UseXclientServer(serverAddress string) (restore func()) {
+	bridgx, schedulx := bridgxClient, schedulxClient
+	bridgxClient = NewBridgxClient(serverAddress)
+	schedulxClient = NewSchedulxClient(serverAddress)
+	instanceCountCache.Purge()
+	return func() {
+		bridgxClient, schedulxClient = bridgx, schedulx
+		instanceCountCache.Purge()
+	}
+}
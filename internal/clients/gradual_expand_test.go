@@ -0,0 +1,80 @@
+package clients_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("GradualExpandService", func() {
+	var stopSchedulx func()
+	var lock sync.Mutex
+	var instanceCount int
+	var batches []int
+	// ignoreExpand 为 true 时扩容请求成功但实例数不增加
+	var ignoreExpand bool
+
+	ginkgo.BeforeEach(func() {
+		instanceCount, batches, ignoreExpand = 3, nil, false
+		_, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			defer lock.Unlock()
+			switch r.URL.Path {
+			case "/api/v1/schedulx/service/expand":
+				count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+				batches = append(batches, count)
+				if !ignoreExpand {
+					instanceCount += count
+				}
+				_, _ = w.Write([]byte(`{"code":200,"msg":"success"}`))
+			case "/api/v1/schedulx/instance/count":
+				_, _ = fmt.Fprintf(w, `{"code":200,"msg":"success","data":{"service_cluster_list":[{"instance_count":%d}]}}`, instanceCount)
+			}
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		stopSchedulx()
+	})
+
+	ginkgo.It("expands in batches of batchSize", func() {
+		err := clients.GradualExpandService(context.Background(), "gf.cudgx.pi", "default", 5, 2, time.Millisecond, "")
+		gomega.Expect(err).To(gomega.BeNil())
+		lock.Lock()
+		defer lock.Unlock()
+		gomega.Expect(batches).To(gomega.Equal([]int{2, 2, 1}))
+		gomega.Expect(instanceCount).To(gomega.Equal(8))
+	})
+
+	ginkgo.It("counts the batch already requested when the context ends while waiting", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		err := clients.GradualExpandService(ctx, "gf.cudgx.pi", "default", 5, 2, time.Minute, "")
+		var partialErr *clients.PartialExpandError
+		gomega.Expect(errors.As(err, &partialErr)).To(gomega.BeTrue())
+		gomega.Expect(partialErr.Expanded).To(gomega.Equal(2))
+		gomega.Expect(errors.Is(err, context.DeadlineExceeded)).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("counts the batch already requested when the instance count does not grow", func() {
+		lock.Lock()
+		ignoreExpand = true
+		lock.Unlock()
+		err := clients.GradualExpandService(context.Background(), "gf.cudgx.pi", "default", 5, 2, time.Millisecond, "")
+		var partialErr *clients.PartialExpandError
+		gomega.Expect(errors.As(err, &partialErr)).To(gomega.BeTrue())
+		gomega.Expect(partialErr.Expanded).To(gomega.Equal(2))
+		gomega.Expect(partialErr.Err.Error()).To(gomega.Equal("instance count 3 less than expected 5"))
+		lock.Lock()
+		defer lock.Unlock()
+		gomega.Expect(batches).To(gomega.Equal([]int{2}))
+	})
+})
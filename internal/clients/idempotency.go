@@ -0,0 +1,44 @@
+package clients
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+//IdempotencyKeyHeader 扩缩容请求携带幂等 key 的请求头，schedulx 支持时据此去重
+const IdempotencyKeyHeader = "X-Idempotency-Key"
+
+const (
+	//idempotencyKeyCacheSize 最近发送过的幂等 key 缓存容量
+	idempotencyKeyCacheSize = 1000
+	//idempotencyKeyTTL 相同 key 在该时间内只发送一次
+	idempotencyKeyTTL = 60 * time.Second
+)
+
+//ErrDuplicateRequest 最近已发送过相同幂等 key 的扩缩容请求
+var ErrDuplicateRequest = errors.New("duplicate request with the same idempotency key")
+
+var (
+	//RecentIdempotencyKeys 最近发送过的幂等 key 及发送时间
+	RecentIdempotencyKeys = newLRUCache(idempotencyKeyCacheSize).Cache
+	idempotencyLock       sync.Mutex
+)
+
+//checkIdempotencyKey key 在 idempotencyKeyTTL 内已发送过时返回 ErrDuplicateRequest，否则记录本次发送；
+//请求失败后也不会移除，网络超时但服务端已处理时避免重试造成重复扩缩容
+func checkIdempotencyKey(idempotencyKey string) error {
+	if idempotencyKey == "" {
+		return nil
+	}
+	idempotencyLock.Lock()
+	defer idempotencyLock.Unlock()
+	now := time.Now()
+	if value, ok := RecentIdempotencyKeys.Get(idempotencyKey); ok {
+		if sentAt, _ := value.(time.Time); now.Sub(sentAt) < idempotencyKeyTTL {
+			return ErrDuplicateRequest
+		}
+	}
+	RecentIdempotencyKeys.Add(idempotencyKey, now)
+	return nil
+}
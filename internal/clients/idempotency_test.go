@@ -0,0 +1,40 @@
+package clients_test
+
+import (
+	"net/http"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Idempotency key", func() {
+	var stopSchedulx func()
+	var keys []string
+
+	ginkgo.BeforeEach(func() {
+		keys = nil
+		clients.RecentIdempotencyKeys.Purge()
+		_, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			keys = append(keys, r.Header.Get(clients.IdempotencyKeyHeader))
+			_, _ = w.Write([]byte(`{"code":200,"msg":"success"}`))
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		stopSchedulx()
+	})
+
+	ginkgo.It("sends the key and refuses to resend it", func() {
+		gomega.Expect(clients.ExpandService("gf.cudgx.pi", "default", 2, "key-1")).To(gomega.BeNil())
+		gomega.Expect(clients.ExpandService("gf.cudgx.pi", "default", 2, "key-1")).To(gomega.MatchError(clients.ErrDuplicateRequest))
+		gomega.Expect(clients.ShrinkService("gf.cudgx.pi", "default", 2, "key-2", clients.ShrinkOptions{})).To(gomega.BeNil())
+		gomega.Expect(keys).To(gomega.Equal([]string{"key-1", "key-2"}))
+	})
+
+	ginkgo.It("does not deduplicate requests without a key", func() {
+		gomega.Expect(clients.ShrinkService("gf.cudgx.pi", "default", 1, "", clients.ShrinkOptions{})).To(gomega.BeNil())
+		gomega.Expect(clients.ShrinkService("gf.cudgx.pi", "default", 1, "", clients.ShrinkOptions{})).To(gomega.BeNil())
+		gomega.Expect(keys).To(gomega.Equal([]string{"", ""}))
+	})
+})
@@ -0,0 +1,64 @@
+package clients
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	instanceCountCache = newLRUCache(scheduleCacheSize)
+	//instanceCountCacheTTL 为0时不缓存，keeper 启动后设置为调度周期的0.9倍
+	instanceCountCacheTTL atomic.Int64
+
+	instanceCountCacheHitsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "cudgx_instance_count_cache_hits_total",
+		Help: "Number of GetServiceInstanceCount lookups served from the cache.",
+	})
+	instanceCountCacheMissesCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "cudgx_instance_count_cache_misses_total",
+		Help: "Number of GetServiceInstanceCount lookups that fell through to schedulx.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(instanceCountCacheHitsCounter, instanceCountCacheMissesCounter)
+}
+
+type instanceCountCacheEntry struct {
+	count    int
+	expireAt time.Time
+}
+
+//SetInstanceCountCacheTTL 设置服务集群实例数的缓存时间，不大于0时不缓存；通过本包扩缩容成功后缓存立即失效
+func SetInstanceCountCacheTTL(ttl time.Duration) {
+	instanceCountCacheTTL.Store(int64(ttl))
+}
+
+//cachedInstanceCount 未过期的缓存实例数，没有开启缓存时总是返回 false 且不计入命中率
+func cachedInstanceCount(key ServiceClusterPair) (int, bool) {
+	if instanceCountCacheTTL.Load() <= 0 {
+		return 0, false
+	}
+	if value, ok := instanceCountCache.Get(key); ok {
+		if entry := value.(instanceCountCacheEntry); time.Now().Before(entry.expireAt) {
+			instanceCountCacheHitsCounter.Inc()
+			return entry.count, true
+		}
+		instanceCountCache.Remove(key)
+	}
+	instanceCountCacheMissesCounter.Inc()
+	return 0, false
+}
+
+func cacheInstanceCount(key ServiceClusterPair, count int) {
+	if ttl := time.Duration(instanceCountCacheTTL.Load()); ttl > 0 {
+		instanceCountCache.Add(key, instanceCountCacheEntry{count: count, expireAt: time.Now().Add(ttl)})
+	}
+}
+
+//invalidateInstanceCountCache 扩缩容后实例数已变化，清除缓存的实例数
+func invalidateInstanceCountCache(serviceName, clusterName string) {
+	instanceCountCache.Remove(ServiceClusterPair{ServiceName: serviceName, ClusterName: clusterName})
+}
@@ -0,0 +1,102 @@
+package clients_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("GetServiceInstanceCount cache", func() {
+	var stopSchedulx func()
+	var countRequests int
+	var instanceCount int
+	//pending 扩容后尚未就绪的实例数，每次查询实例数就绪一个；为 nil 时扩容的实例立即就绪
+	var pending *int
+
+	ginkgo.BeforeEach(func() {
+		countRequests = 0
+		instanceCount = 10
+		pending = nil
+		_, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v1/schedulx/instance/count":
+				countRequests++
+				if pending != nil && *pending > 0 {
+					instanceCount++
+					*pending--
+				}
+				_, _ = fmt.Fprintf(w, `{"code":200,"msg":"success","data":{"service_cluster_list":[{"service_cluster_name":"default","instance_count":%d}]}}`, instanceCount)
+			case "/api/v1/schedulx/service/expand":
+				if pending != nil {
+					*pending += 2
+				} else {
+					instanceCount += 2
+				}
+				_, _ = w.Write([]byte(`{"code":200,"msg":"success"}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		})
+		clients.SetInstanceCountCacheTTL(time.Minute)
+	})
+
+	ginkgo.AfterEach(func() {
+		clients.SetInstanceCountCacheTTL(0)
+		stopSchedulx()
+	})
+
+	ginkgo.It("serves repeated lookups from the cache and counts hits", func() {
+		hits := counterValue("cudgx_instance_count_cache_hits_total")
+		misses := counterValue("cudgx_instance_count_cache_misses_total")
+		for i := 0; i < 3; i++ {
+			count, err := clients.GetServiceInstanceCount("gf.cudgx.pi", "default")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(count).To(gomega.Equal(10))
+		}
+		gomega.Expect(countRequests).To(gomega.Equal(1))
+		gomega.Expect(counterValue("cudgx_instance_count_cache_hits_total")).To(gomega.Equal(hits + 2))
+		gomega.Expect(counterValue("cudgx_instance_count_cache_misses_total")).To(gomega.Equal(misses + 1))
+	})
+
+	ginkgo.It("invalidates the cached count after a successful expand", func() {
+		_, err := clients.GetServiceInstanceCount("gf.cudgx.pi", "default")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(clients.ExpandService("gf.cudgx.pi", "default", 2, "")).To(gomega.BeNil())
+		count, err := clients.GetServiceInstanceCount("gf.cudgx.pi", "default")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(count).To(gomega.Equal(12))
+		gomega.Expect(countRequests).To(gomega.Equal(2))
+	})
+
+	ginkgo.It("polls the real count while waiting for expanded instances", func() {
+		clients.ExpandReadinessPollInterval = 10 * time.Millisecond
+		defer func() { clients.ExpandReadinessPollInterval = 5 * time.Second }()
+		pending = new(int)
+		_, err := clients.GetServiceInstanceCount("gf.cudgx.pi", "default")
+		gomega.Expect(err).To(gomega.BeNil())
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		var seen []int
+		err = clients.ExpandServiceAndWait(ctx, "gf.cudgx.pi", "default", 2, func(ctx context.Context, currentCount int) bool {
+			seen = append(seen, currentCount)
+			return currentCount >= 12
+		}, "")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(seen).To(gomega.Equal([]int{11, 12}))
+	})
+
+	ginkgo.It("does not cache when the ttl is not positive", func() {
+		clients.SetInstanceCountCacheTTL(0)
+		for i := 0; i < 2; i++ {
+			_, err := clients.GetServiceInstanceCount("gf.cudgx.pi", "default")
+			gomega.Expect(err).To(gomega.BeNil())
+		}
+		gomega.Expect(countRequests).To(gomega.Equal(2))
+	})
+})
@@ -0,0 +1,81 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+//instanceListCacheTTL 实例列表的缓存时间，扩缩容成功后立即失效
+const instanceListCacheTTL = 10 * time.Second
+
+var instanceListCache = newLRUCache(scheduleCacheSize)
+
+type GetServiceInstanceListResponse struct {
+	Code int64               `json:"code"`
+	Msg  string              `json:"msg"`
+	Data ServiceInstanceList `json:"data"`
+}
+
+type ServiceInstanceList struct {
+	InstanceList []*ServiceInstance `json:"instance_list"`
+}
+
+type instanceListCacheEntry struct {
+	instances []InstanceInfo
+	expireAt  time.Time
+}
+
+// GetServiceInstanceList 获取该服务集群运行中实例的 ip、id、状态和创建时间，结果按服务集群缓存10秒，
+// 通过本包扩缩容成功后缓存立即失效；ctx 中的 request id 会随请求发送
+func GetServiceInstanceList(ctx context.Context, serviceName, clusterName string) ([]InstanceInfo, error) {
+	if err := validateNames(serviceName, clusterName); err != nil {
+		return nil, err
+	}
+	key := ServiceClusterPair{ServiceName: serviceName, ClusterName: clusterName}
+	if value, ok := instanceListCache.Get(key); ok {
+		if entry := value.(instanceListCacheEntry); time.Now().Before(entry.expireAt) {
+			return append([]InstanceInfo(nil), entry.instances...), nil
+		}
+		instanceListCache.Remove(key)
+	}
+	resp, err := schedulxGet(ctx, fmt.Sprintf("%s/api/v1/schedulx/instance/list?service_name=%s&service_cluster_name=%s", schedulxClient.ServerAddress, serviceName, clusterName))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respData, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var response GetServiceInstanceListResponse
+	err = json.Unmarshal(respData, &response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Code != http.StatusOK {
+		err = fmt.Errorf("http code:%v | msg:%v", response.Code, response.Msg)
+		return nil, err
+	}
+	var instances []InstanceInfo
+	for _, instance := range response.Data.InstanceList {
+		if instance == nil || instance.InstanceId == "" {
+			continue
+		}
+		info := InstanceInfo{IP: instance.IpInner, InstanceID: instance.InstanceId, Status: instance.Status}
+		if instance.CreateAt > 0 {
+			info.CreatedAt = time.Unix(instance.CreateAt, 0)
+		}
+		instances = append(instances, info)
+	}
+	instanceListCache.Add(key, instanceListCacheEntry{instances: instances, expireAt: time.Now().Add(instanceListCacheTTL)})
+	return append([]InstanceInfo(nil), instances...), nil
+}
+
+//invalidateInstanceListCache 扩缩容后实例列表已变化，清除缓存的列表
+func invalidateInstanceListCache(serviceName, clusterName string) {
+	instanceListCache.Remove(ServiceClusterPair{ServiceName: serviceName, ClusterName: clusterName})
+}
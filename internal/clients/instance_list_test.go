@@ -0,0 +1,58 @@
+package clients_test
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("GetServiceInstanceList", func() {
+	var stopSchedulx func()
+	var listQueries []string
+
+	ginkgo.BeforeEach(func() {
+		listQueries = nil
+		_, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v1/schedulx/instance/list":
+				listQueries = append(listQueries, r.URL.RawQuery)
+				_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":{"instance_list":[{"instance_id":"i-1","ip_inner":"10.0.0.1","status":"running"}]}}`))
+			default:
+				_, _ = w.Write([]byte(`{"code":200,"msg":"success"}`))
+			}
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		stopSchedulx()
+	})
+
+	ginkgo.It("caches the list per service cluster", func() {
+		for i := 0; i < 2; i++ {
+			instances, err := clients.GetServiceInstanceList(context.Background(), "gf.cudgx.list", "cached")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(instances).To(gomega.Equal([]clients.InstanceInfo{{IP: "10.0.0.1", InstanceID: "i-1", Status: "running"}}))
+		}
+		_, err := clients.GetServiceInstanceList(context.Background(), "gf.cudgx.list", "other")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(listQueries).To(gomega.Equal([]string{
+			"service_name=gf.cudgx.list&service_cluster_name=cached",
+			"service_name=gf.cudgx.list&service_cluster_name=other",
+		}))
+	})
+
+	ginkgo.It("invalidates the cache after a successful expand or shrink", func() {
+		_, err := clients.GetServiceInstanceList(context.Background(), "gf.cudgx.list", "scaled")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(clients.ExpandService("gf.cudgx.list", "scaled", 1, "")).To(gomega.Succeed())
+		_, err = clients.GetServiceInstanceList(context.Background(), "gf.cudgx.list", "scaled")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(clients.ShrinkService("gf.cudgx.list", "scaled", 1, "", clients.ShrinkOptions{})).To(gomega.Succeed())
+		_, err = clients.GetServiceInstanceList(context.Background(), "gf.cudgx.list", "scaled")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(listQueries).To(gomega.HaveLen(3))
+	})
+})
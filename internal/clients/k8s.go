@@ -0,0 +1,256 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	//K8sResourceDeployments Deployment 的资源名
+	K8sResourceDeployments = "deployments"
+	//K8sResourceStatefulSets StatefulSet 的资源名
+	K8sResourceStatefulSets = "statefulsets"
+)
+
+//ErrK8sClientNotInitialized 没有配置 k8s_api_server_address
+var ErrK8sClientNotInitialized = errors.New("kubernetes client is not initialized")
+
+var k8sClient *K8sClient
+
+//K8sClient 调用 Kubernetes API Server 的 scale 子资源
+type K8sClient struct {
+	ServerAddress string
+	//BearerTokenFile 访问 API Server 的 token 文件，每次请求时读取，以支持 ServiceAccount token 轮换
+	BearerTokenFile string
+	HttpClient      *http.Client
+}
+
+//K8sScale autoscaling/v1 Scale，只包含扩缩容需要的字段
+type K8sScale struct {
+	APIVersion string         `json:"apiVersion"`
+	Kind       string         `json:"kind"`
+	Metadata   K8sObjectMeta  `json:"metadata"`
+	Spec       K8sScaleSpec   `json:"spec"`
+	Status     K8sScaleStatus `json:"status"`
+}
+
+type K8sObjectMeta struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	//ResourceVersion 更新时携带，副本数被其他人修改过时 API Server 返回409
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type K8sScaleSpec struct {
+	Replicas int `json:"replicas"`
+}
+
+type K8sScaleStatus struct {
+	Replicas int    `json:"replicas"`
+	Selector string `json:"selector,omitempty"`
+}
+
+//k8sStatus API Server 返回的错误
+type k8sStatus struct {
+	Message string `json:"message"`
+	Reason  string `json:"reason"`
+}
+
+//InitializeK8sClient 初始化 Kubernetes 客户端，serverAddress 为空时不初始化，k8s 类型集群的规则执行时报错；
+//caFile 为空时使用系统根证书
+func InitializeK8sClient(serverAddress, bearerTokenFile, caFile string) error {
+	if serverAddress == "" {
+		k8sClient = nil
+		return nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caFile != "" {
+		ca, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("read kubernetes ca file failed, %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return fmt.Errorf("no certificate found in kubernetes ca file %s", caFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	k8sClient = &K8sClient{
+		ServerAddress:   strings.TrimRight(serverAddress, "/"),
+		BearerTokenFile: bearerTokenFile,
+		HttpClient:      &http.Client{Timeout: 5 * time.Second, Transport: transport},
+	}
+	return nil
+}
+
+//ParseK8sClusterName 解析 k8s 类型集群的名称 namespace/name
+func ParseK8sClusterName(clusterName string) (string, string, error) {
+	parts := strings.Split(clusterName, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("k8s 集群名称 %s 格式错误，应为 namespace/name", clusterName)
+	}
+	return parts[0], parts[1], nil
+}
+
+// GetK8sScale 查询 Deployment/StatefulSet 的 scale 子资源，clusterName 为 namespace/name
+func GetK8sScale(ctx context.Context, resource, clusterName string) (*K8sScale, error) {
+	return k8sDoScale(ctx, http.MethodGet, resource, clusterName, nil)
+}
+
+// UpdateK8sScale 更新 Deployment/StatefulSet 的副本数，scale 需要是 GetK8sScale 的返回值，
+// 其中的 resourceVersion 保证查询后副本数没有被修改过
+func UpdateK8sScale(ctx context.Context, resource, clusterName string, scale *K8sScale) (*K8sScale, error) {
+	return k8sDoScale(ctx, http.MethodPut, resource, clusterName, scale)
+}
+
+// ChangeK8sReplicas 在当前期望副本数的基础上增加 delta 个副本，delta 为负数时缩容，最少缩容到0个
+func ChangeK8sReplicas(ctx context.Context, resource, clusterName string, delta int) error {
+	scale, err := GetK8sScale(ctx, resource, clusterName)
+	if err != nil {
+		return err
+	}
+	replicas := scale.Spec.Replicas + delta
+	if replicas < 0 {
+		replicas = 0
+	}
+	logger.GetLogger().Info("change kubernetes replicas", zap.String("resource", resource), zap.String("cluster", clusterName),
+		zap.Int("from", scale.Spec.Replicas), zap.Int("to", replicas))
+	scale.Spec.Replicas = replicas
+	_, err = UpdateK8sScale(ctx, resource, clusterName, scale)
+	return err
+}
+
+//ExpandK8sAndWait 增加 count 个副本并轮询 status.replicas 直到达到新的期望副本数；ctx 结束前未就绪时返回 ErrExpandNotReady
+func ExpandK8sAndWait(ctx context.Context, resource, clusterName string, count int) error {
+	scale, err := GetK8sScale(ctx, resource, clusterName)
+	if err != nil {
+		return err
+	}
+	target := scale.Spec.Replicas + count
+	scale.Spec.Replicas = target
+	if _, err := UpdateK8sScale(ctx, resource, clusterName, scale); err != nil {
+		return err
+	}
+	begin := time.Now()
+	ticker := time.NewTicker(ExpandReadinessPollInterval)
+	defer ticker.Stop()
+	for {
+		current, err := GetK8sScale(ctx, resource, clusterName)
+		if err == nil && current.Status.Replicas >= target {
+			logger.GetLogger().Info("expanded kubernetes replicas are ready", zap.String("resource", resource),
+				zap.String("cluster", clusterName), zap.Int("count", count), zap.Duration("elapsed", time.Since(begin)))
+			return nil
+		}
+		if err != nil {
+			logger.GetLogger().Warn("query kubernetes scale while waiting for readiness failed", zap.String("resource", resource),
+				zap.String("cluster", clusterName), zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w after %s, %v", ErrExpandNotReady, time.Since(begin), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+//GradualExpandK8s 分批增加副本，每批后等待 batchInterval 并校验 status.replicas，中途失败时返回 PartialExpandError
+func GradualExpandK8s(ctx context.Context, resource, clusterName string, totalCount int, batchSize int, batchInterval time.Duration) error {
+	if batchSize <= 0 || batchSize > totalCount {
+		batchSize = totalCount
+	}
+	expanded := 0
+	for expanded < totalCount {
+		count := batchSize
+		if totalCount-expanded < count {
+			count = totalCount - expanded
+		}
+		scale, err := GetK8sScale(ctx, resource, clusterName)
+		if err != nil {
+			return &PartialExpandError{Expanded: expanded, Err: err}
+		}
+		target := scale.Spec.Replicas + count
+		scale.Spec.Replicas = target
+		if _, err := UpdateK8sScale(ctx, resource, clusterName, scale); err != nil {
+			return &PartialExpandError{Expanded: expanded, Err: err}
+		}
+		select {
+		case <-ctx.Done():
+			return &PartialExpandError{Expanded: expanded, Err: ctx.Err()}
+		case <-time.After(batchInterval):
+		}
+		current, err := GetK8sScale(ctx, resource, clusterName)
+		if err != nil {
+			return &PartialExpandError{Expanded: expanded, Err: err}
+		}
+		if current.Status.Replicas < target {
+			return &PartialExpandError{Expanded: expanded,
+				Err: fmt.Errorf("replicas %d less than expected %d", current.Status.Replicas, target)}
+		}
+		expanded += count
+	}
+	return nil
+}
+
+//k8sDoScale 发送 scale 子资源请求，body 为空时不发送请求体
+func k8sDoScale(ctx context.Context, method, resource, clusterName string, body *K8sScale) (*K8sScale, error) {
+	if k8sClient == nil {
+		return nil, ErrK8sClientNotInitialized
+	}
+	namespace, name, err := ParseK8sClusterName(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/apis/apps/v1/namespaces/%s/%s/%s/scale", k8sClient.ServerAddress, namespace, resource, name)
+	var reqBody []byte
+	if body != nil {
+		if reqBody, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if k8sClient.BearerTokenFile != "" {
+		token, err := ioutil.ReadFile(k8sClient.BearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("read kubernetes token file failed, %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := k8sClient.HttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respData, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var status k8sStatus
+		_ = json.Unmarshal(respData, &status)
+		return nil, fmt.Errorf("%s %s scale %s failed, http status %d, %s", method, resource, clusterName, resp.StatusCode, status.Message)
+	}
+	var scale K8sScale
+	if err := json.Unmarshal(respData, &scale); err != nil {
+		return nil, err
+	}
+	return &scale, nil
+}
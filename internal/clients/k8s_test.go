@@ -0,0 +1,75 @@
+package clients_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("K8sClient", func() {
+	var server *httptest.Server
+	var replicas int
+	var authorizations []string
+
+	ginkgo.BeforeEach(func() {
+		replicas = 3
+		authorizations = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorizations = append(authorizations, r.Header.Get("Authorization"))
+			if r.URL.Path != "/apis/apps/v1/namespaces/prod/statefulsets/db/scale" {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"kind":"Status","message":"statefulsets.apps \"db\" not found","reason":"NotFound"}`))
+				return
+			}
+			if r.Method == http.MethodPut {
+				var scale clients.K8sScale
+				gomega.Expect(json.NewDecoder(r.Body).Decode(&scale)).To(gomega.Succeed())
+				replicas = scale.Spec.Replicas
+			}
+			_ = json.NewEncoder(w).Encode(clients.K8sScale{
+				Metadata: clients.K8sObjectMeta{Name: "db", Namespace: "prod", ResourceVersion: "1"},
+				Spec:     clients.K8sScaleSpec{Replicas: replicas},
+				Status:   clients.K8sScaleStatus{Replicas: replicas},
+			})
+		}))
+		dir, err := ioutil.TempDir("", "cudgx-k8s")
+		gomega.Expect(err).To(gomega.BeNil())
+		tokenFile := filepath.Join(dir, "token")
+		gomega.Expect(ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600)).To(gomega.Succeed())
+		gomega.Expect(clients.InitializeK8sClient(server.URL, tokenFile, "")).To(gomega.Succeed())
+	})
+
+	ginkgo.AfterEach(func() {
+		server.Close()
+		gomega.Expect(clients.InitializeK8sClient("", "", "")).To(gomega.Succeed())
+	})
+
+	ginkgo.It("changes replicas through the scale subresource", func() {
+		gomega.Expect(clients.ChangeK8sReplicas(context.Background(), clients.K8sResourceStatefulSets, "prod/db", 2)).To(gomega.Succeed())
+		gomega.Expect(replicas).To(gomega.Equal(5))
+		gomega.Expect(clients.ChangeK8sReplicas(context.Background(), clients.K8sResourceStatefulSets, "prod/db", -10)).To(gomega.Succeed())
+		gomega.Expect(replicas).To(gomega.Equal(0))
+		gomega.Expect(authorizations).To(gomega.HaveLen(4))
+		gomega.Expect(authorizations).To(gomega.ConsistOf("Bearer secret", "Bearer secret", "Bearer secret", "Bearer secret"))
+	})
+
+	ginkgo.It("returns the message of the api server on failure", func() {
+		_, err := clients.GetK8sScale(context.Background(), clients.K8sResourceDeployments, "prod/db")
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("not found")))
+	})
+
+	ginkgo.It("rejects cluster names that are not namespace/name", func() {
+		_, _, err := clients.ParseK8sClusterName("prod")
+		gomega.Expect(err).NotTo(gomega.BeNil())
+		namespace, name, err := clients.ParseK8sClusterName("prod/db")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect([]string{namespace, name}).To(gomega.Equal([]string{"prod", "db"}))
+	})
+})
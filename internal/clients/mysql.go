@@ -1,8 +1,13 @@
 package clients
 
 import (
+	"database/sql"
+	"errors"
+	"time"
+
 	"github.com/galaxy-future/cudgx/common/logger"
 	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -10,12 +15,95 @@ import (
 
 var DBClient *gorm.DB
 
-func InitDBClient(config *config.Database) error {
+//defaultMaxIdleConns database/sql 在 SetMaxIdleConns 未设置时保留的空闲连接数
+const defaultMaxIdleConns = 2
+
+var dbOpenConnectionsGauge = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+	Name: "cudgx_db_open_connections",
+	Help: "Number of established connections to the database, both in use and idle.",
+}, func() float64 {
+	stats, err := GetDBStats()
+	if err != nil {
+		return 0
+	}
+	return float64(stats.OpenConnections)
+})
+
+func init() {
+	prometheus.MustRegister(dbOpenConnectionsGauge)
+}
+
+//DBPoolConfig 数据库连接池配置，不大于0的字段使用 database/sql 的默认值
+type DBPoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+//Apply 设置 db 的连接池
+func (pool DBPoolConfig) Apply(db *sql.DB) {
+	if pool.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	}
+	if pool.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	}
+}
+
+//fields 实际生效的连接池配置，0表示不限制
+func (pool DBPoolConfig) fields() []zap.Field {
+	maxIdleConns := pool.MaxIdleConns
+	if maxIdleConns <= 0 {
+		maxIdleConns = defaultMaxIdleConns
+	}
+	if pool.MaxOpenConns > 0 && maxIdleConns > pool.MaxOpenConns {
+		maxIdleConns = pool.MaxOpenConns
+	}
+	return []zap.Field{
+		zap.Int("max_open_conns", pool.MaxOpenConns),
+		zap.Int("max_idle_conns", maxIdleConns),
+		zap.Duration("conn_max_lifetime", pool.ConnMaxLifetime),
+		zap.Duration("conn_max_idle_time", pool.ConnMaxIdleTime),
+	}
+}
+
+func InitDBClient(config *config.Database, pool DBPoolConfig) error {
 	db, err := gorm.Open(mysql.Open(config.Dsn), &gorm.Config{})
 	if err != nil {
 		logger.GetLogger().Error("InitDBClient err", zap.Error(err))
 		return err
 	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		logger.GetLogger().Error("InitDBClient get sql.DB err", zap.Error(err))
+		return err
+	}
+	pool.Apply(sqlDB)
+	logger.GetLogger().Info("database connection pool", pool.fields()...)
 	DBClient = db
 	return nil
 }
+
+//GetDBStats DBClient 连接池的统计
+func GetDBStats() (sql.DBStats, error) {
+	if DBClient == nil {
+		return sql.DBStats{}, errors.New("database client is not initialized")
+	}
+	sqlDB, err := DBClient.DB()
+	if err != nil {
+		return sql.DBStats{}, err
+	}
+	return sqlDB.Stats(), nil
+}
+
+//IsDBPoolExhausted 设置了 MaxOpenConns 且所有连接都在使用中，新的查询需要等待连接释放
+func IsDBPoolExhausted(stats sql.DBStats) bool {
+	return stats.MaxOpenConnections > 0 && stats.InUse >= stats.MaxOpenConnections
+}
@@ -0,0 +1,52 @@
+package clients_test
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+var _ = ginkgo.Describe("DB connection pool", func() {
+	ginkgo.It("applies the configured pool settings", func() {
+		// sql.Open 不建立连接，只检查连接池配置
+		db, err := sql.Open("mysql", "gf:db@tcp(127.0.0.1:3336)/cudgx")
+		gomega.Expect(err).To(gomega.BeNil())
+		defer db.Close()
+		clients.DBPoolConfig{MaxOpenConns: 20, MaxIdleConns: 5, ConnMaxLifetime: time.Hour}.Apply(db)
+		gomega.Expect(db.Stats().MaxOpenConnections).To(gomega.Equal(20))
+
+		clients.DBPoolConfig{}.Apply(db)
+		gomega.Expect(db.Stats().MaxOpenConnections).To(gomega.Equal(20))
+	})
+
+	ginkgo.It("reports the stats of DBClient", func() {
+		previous := clients.DBClient
+		defer func() { clients.DBClient = previous }()
+		clients.DBClient = nil
+		_, err := clients.GetDBStats()
+		gomega.Expect(err).NotTo(gomega.BeNil())
+
+		db, err := gorm.Open(mysql.New(mysql.Config{DSN: "gf:db@tcp(127.0.0.1:3336)/cudgx", SkipInitializeWithVersion: true}), &gorm.Config{DisableAutomaticPing: true})
+		gomega.Expect(err).To(gomega.BeNil())
+		sqlDB, err := db.DB()
+		gomega.Expect(err).To(gomega.BeNil())
+		defer sqlDB.Close()
+		clients.DBPoolConfig{MaxOpenConns: 3}.Apply(sqlDB)
+		clients.DBClient = db
+		stats, err := clients.GetDBStats()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(stats.MaxOpenConnections).To(gomega.Equal(3))
+		gomega.Expect(clients.IsDBPoolExhausted(stats)).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("is exhausted only when every connection is in use", func() {
+		gomega.Expect(clients.IsDBPoolExhausted(sql.DBStats{MaxOpenConnections: 3, OpenConnections: 3, InUse: 3})).To(gomega.BeTrue())
+		gomega.Expect(clients.IsDBPoolExhausted(sql.DBStats{MaxOpenConnections: 3, OpenConnections: 3, InUse: 1, Idle: 2})).To(gomega.BeFalse())
+		gomega.Expect(clients.IsDBPoolExhausted(sql.DBStats{OpenConnections: 100, InUse: 100})).To(gomega.BeFalse())
+	})
+})
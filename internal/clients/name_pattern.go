@@ -0,0 +1,61 @@
+package clients
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+//namePatterns 允许的服务/集群名称正则，为空表示不限制
+var namePatterns struct {
+	lock    sync.RWMutex
+	service *regexp.Regexp
+	cluster *regexp.Regexp
+}
+
+//SetNamePatterns 编译并设置允许的服务/集群名称正则，为空表示不限制；正则匹配名称的子串，需要完全匹配时使用 ^...$
+func SetNamePatterns(servicePattern, clusterPattern string) error {
+	service, err := compileNamePattern(servicePattern)
+	if err != nil {
+		return fmt.Errorf("allowed service pattern %q is invalid : %w", servicePattern, err)
+	}
+	cluster, err := compileNamePattern(clusterPattern)
+	if err != nil {
+		return fmt.Errorf("allowed cluster pattern %q is invalid : %w", clusterPattern, err)
+	}
+	namePatterns.lock.Lock()
+	defer namePatterns.lock.Unlock()
+	namePatterns.service = service
+	namePatterns.cluster = cluster
+	return nil
+}
+
+func compileNamePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+//MatchNamePatterns 校验服务/集群名称是否匹配 SetNamePatterns 设置的正则
+func MatchNamePatterns(serviceName, clusterName string) error {
+	if err := matchServiceNamePattern(serviceName); err != nil {
+		return err
+	}
+	namePatterns.lock.RLock()
+	defer namePatterns.lock.RUnlock()
+	if namePatterns.cluster != nil && !namePatterns.cluster.MatchString(clusterName) {
+		return fmt.Errorf("集群名称 %s 不匹配允许的正则 %s", clusterName, namePatterns.cluster)
+	}
+	return nil
+}
+
+//matchServiceNamePattern 只校验服务名称，用于查询服务的所有集群
+func matchServiceNamePattern(serviceName string) error {
+	namePatterns.lock.RLock()
+	defer namePatterns.lock.RUnlock()
+	if namePatterns.service != nil && !namePatterns.service.MatchString(serviceName) {
+		return fmt.Errorf("服务名称 %s 不匹配允许的正则 %s", serviceName, namePatterns.service)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package clients_test
+
+import (
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("NamePatterns", func() {
+	ginkgo.AfterEach(func() {
+		gomega.Expect(clients.SetNamePatterns("", "")).To(gomega.Succeed())
+	})
+
+	ginkgo.It("allows every name without patterns", func() {
+		gomega.Expect(clients.MatchNamePatterns("svc_prod", "default")).To(gomega.Succeed())
+	})
+
+	ginkgo.It("rejects names not matching the patterns", func() {
+		gomega.Expect(clients.SetNamePatterns("^svc-[a-z]+$", "^prod-")).To(gomega.Succeed())
+		gomega.Expect(clients.MatchNamePatterns("svc-prod", "prod-bj")).To(gomega.Succeed())
+
+		err := clients.MatchNamePatterns("svc_prod", "prod-bj")
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(err.Error()).To(gomega.ContainSubstring("svc_prod"))
+		gomega.Expect(clients.MatchNamePatterns("svc-prod", "test-bj")).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("rejects invalid patterns", func() {
+		gomega.Expect(clients.SetNamePatterns("svc-(", "")).NotTo(gomega.Succeed())
+	})
+})
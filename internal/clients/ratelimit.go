@@ -0,0 +1,39 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+//serviceByIpMaxWait GetServiceByIp 等待令牌的最长时间
+const serviceByIpMaxWait = time.Second
+
+//ErrRateLimited 请求 schedulx 的频率超过限制
+var ErrRateLimited = errors.New("schedulx request rate limited")
+
+//serviceByIpLimiter 限制 GetServiceByIp 缓存未命中时请求 schedulx 的频率，默认不限制
+var serviceByIpLimiter = rate.NewLimiter(rate.Inf, 0)
+
+//SetServiceByIpRateLimit 设置 GetServiceByIp 请求 schedulx 的每秒请求数和突发请求数，rps 不大于0时不限制
+func SetServiceByIpRateLimit(rps float64, burst int) {
+	if rps <= 0 {
+		serviceByIpLimiter.SetLimit(rate.Inf)
+		return
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	serviceByIpLimiter.SetBurst(burst)
+	serviceByIpLimiter.SetLimit(rate.Limit(rps))
+}
+
+//waitServiceByIpToken 获取令牌，ctx 结束前无法获取时返回 ErrRateLimited
+func waitServiceByIpToken(ctx context.Context) error {
+	if err := serviceByIpLimiter.Wait(ctx); err != nil {
+		return ErrRateLimited
+	}
+	return nil
+}
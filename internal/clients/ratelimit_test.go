@@ -0,0 +1,61 @@
+package clients_test
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("GetServiceByIp rate limit", func() {
+	var stopSchedulx func()
+	var requests int
+
+	ginkgo.BeforeEach(func() {
+		requests = 0
+		_, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":{"service_name":"gf.cudgx.pi","cluster_name":"default"}}`))
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		clients.SetServiceByIpRateLimit(0, 0)
+		stopSchedulx()
+	})
+
+	ginkgo.It("rejects lookups once the burst is exhausted", func() {
+		clients.SetServiceByIpRateLimit(0.001, 2)
+		for _, ip := range []string{"10.1.0.1", "10.1.0.2"} {
+			data, err := clients.GetServiceByIp(context.Background(), ip)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(data.ServiceName).To(gomega.Equal("gf.cudgx.pi"))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := clients.GetServiceByIp(ctx, "10.1.0.3")
+		gomega.Expect(err).To(gomega.Equal(clients.ErrRateLimited))
+		gomega.Expect(requests).To(gomega.Equal(2))
+	})
+
+	ginkgo.It("serves cached ips without a token", func() {
+		clients.SetServiceByIpRateLimit(0.001, 1)
+		_, err := clients.GetServiceByIp(context.Background(), "10.1.1.1")
+		gomega.Expect(err).To(gomega.BeNil())
+		_, err = clients.GetServiceByIp(context.Background(), "10.1.1.1")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(requests).To(gomega.Equal(1))
+	})
+
+	ginkgo.It("does not limit lookups by default", func() {
+		for _, ip := range []string{"10.1.2.1", "10.1.2.2", "10.1.2.3"} {
+			_, err := clients.GetServiceByIp(context.Background(), ip)
+			gomega.Expect(err).To(gomega.BeNil())
+		}
+		gomega.Expect(requests).To(gomega.Equal(3))
+	})
+})
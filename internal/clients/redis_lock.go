@@ -0,0 +1,87 @@
+package clients
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//redisLockRetryInterval 锁被占用时重试 SET NX 的间隔
+const redisLockRetryInterval = 50 * time.Millisecond
+
+//redisUnlockScript 只有 value 与加锁时的 token 相同才删除，避免锁过期后删除其他实例新加的锁
+var redisUnlockScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`)
+
+//redisExtendScript 只有 value 与加锁时的 token 相同才延长过期时间
+var redisExtendScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`)
+
+//ErrLockNotAcquired ctx 结束前锁一直被其他实例持有
+var ErrLockNotAcquired = errors.New("distributed lock not acquired")
+
+//ErrLockNotHeld 锁已过期或已被其他实例持有，无法续期
+var ErrLockNotHeld = errors.New("distributed lock is no longer held")
+
+//RedisDistributedLock 用 SET NX PX 实现的 Redis 分布式锁，value 为随机 token，只有持有者能释放和续期
+type RedisDistributedLock struct {
+	client *redis.Client
+}
+
+//NewRedisDistributedLock 新建连接 addr 的 Redis 分布式锁，多次加锁复用连接池
+func NewRedisDistributedLock(addr string) (*RedisDistributedLock, error) {
+	if addr == "" {
+		return nil, fmt.Errorf("redis addr can not be empty")
+	}
+	return &RedisDistributedLock{client: redis.NewClient(&redis.Options{Addr: addr})}, nil
+}
+
+//Lock 加锁 key，ttl 后自动过期；锁被占用时每隔50ms重试，ctx 结束时返回 ErrLockNotAcquired。返回的 token 用于 Unlock 和 Extend
+func (lock *RedisDistributedLock) Lock(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return "", err
+	}
+	for {
+		ok, err := lock.client.SetNX(ctx, key, token, ttl).Result()
+		if err != nil && ctx.Err() == nil {
+			return "", err
+		}
+		if err == nil && ok {
+			return token, nil
+		}
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("%w , key:%s", ErrLockNotAcquired, key)
+		case <-time.After(redisLockRetryInterval):
+		}
+	}
+}
+
+//Extend 把 Lock 加的锁的过期时间重置为 ttl，锁已过期或被其他实例持有时返回 ErrLockNotHeld
+func (lock *RedisDistributedLock) Extend(ctx context.Context, key, token string, ttl time.Duration) error {
+	extended, err := redisExtendScript.Run(ctx, lock.client, []string{key}, token, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if extended == 0 {
+		return fmt.Errorf("%w , key:%s", ErrLockNotHeld, key)
+	}
+	return nil
+}
+
+//Unlock 释放 Lock 加的锁，锁已过期或被其他实例持有时不做修改
+func (lock *RedisDistributedLock) Unlock(ctx context.Context, key, token string) error {
+	return redisUnlockScript.Run(ctx, lock.client, []string{key}, token).Err()
+}
+
+func newLockToken() (string, error) {
+	data := make([]byte, 16)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
@@ -0,0 +1,103 @@
+package clients_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("RedisDistributedLock", func() {
+	var server *miniredis.Miniredis
+	var lock *clients.RedisDistributedLock
+
+	ginkgo.BeforeEach(func() {
+		var err error
+		server, err = miniredis.Run()
+		gomega.Expect(err).To(gomega.BeNil())
+		lock, err = clients.NewRedisDistributedLock(server.Addr())
+		gomega.Expect(err).To(gomega.BeNil())
+	})
+
+	ginkgo.AfterEach(func() {
+		server.Close()
+	})
+
+	ginkgo.It("rejects an empty address", func() {
+		_, err := clients.NewRedisDistributedLock("")
+		gomega.Expect(err).NotTo(gomega.BeNil())
+	})
+
+	ginkgo.It("sets the key to the returned token and deletes it on unlock", func() {
+		token, err := lock.Lock(context.Background(), "cudgx:lock:a/b", 15*time.Second)
+		gomega.Expect(err).To(gomega.BeNil())
+		value, err := server.Get("cudgx:lock:a/b")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(value).To(gomega.Equal(token))
+		gomega.Expect(server.TTL("cudgx:lock:a/b")).To(gomega.Equal(15 * time.Second))
+
+		gomega.Expect(lock.Unlock(context.Background(), "cudgx:lock:a/b", token)).To(gomega.BeNil())
+		gomega.Expect(server.Exists("cudgx:lock:a/b")).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("gives up when the lock is held until the context is done", func() {
+		_, err := lock.Lock(context.Background(), "cudgx:lock:held", 15*time.Second)
+		gomega.Expect(err).To(gomega.BeNil())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		_, err = lock.Lock(ctx, "cudgx:lock:held", 15*time.Second)
+		gomega.Expect(errors.Is(err, clients.ErrLockNotAcquired)).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("acquires the lock once the holder releases it while waiting", func() {
+		token, err := lock.Lock(context.Background(), "cudgx:lock:released", 15*time.Second)
+		gomega.Expect(err).To(gomega.BeNil())
+		time.AfterFunc(100*time.Millisecond, func() {
+			_ = lock.Unlock(context.Background(), "cudgx:lock:released", token)
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err = lock.Lock(ctx, "cudgx:lock:released", 15*time.Second)
+		gomega.Expect(err).To(gomega.BeNil())
+	})
+
+	ginkgo.It("does not release a lock taken over by another holder after expiry", func() {
+		stale, err := lock.Lock(context.Background(), "cudgx:lock:expired", 15*time.Second)
+		gomega.Expect(err).To(gomega.BeNil())
+		server.FastForward(15 * time.Second)
+		current, err := lock.Lock(context.Background(), "cudgx:lock:expired", 15*time.Second)
+		gomega.Expect(err).To(gomega.BeNil())
+
+		gomega.Expect(lock.Unlock(context.Background(), "cudgx:lock:expired", stale)).To(gomega.BeNil())
+		value, err := server.Get("cudgx:lock:expired")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(value).To(gomega.Equal(current))
+	})
+
+	ginkgo.It("extends the ttl only while the token still holds the lock", func() {
+		token, err := lock.Lock(context.Background(), "cudgx:lock:extend", 15*time.Second)
+		gomega.Expect(err).To(gomega.BeNil())
+		server.FastForward(10 * time.Second)
+		gomega.Expect(lock.Extend(context.Background(), "cudgx:lock:extend", token, 15*time.Second)).To(gomega.Succeed())
+		gomega.Expect(server.TTL("cudgx:lock:extend")).To(gomega.Equal(15 * time.Second))
+
+		server.FastForward(15 * time.Second)
+		err = lock.Extend(context.Background(), "cudgx:lock:extend", token, 15*time.Second)
+		gomega.Expect(errors.Is(err, clients.ErrLockNotHeld)).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("returns connection errors without waiting for the context", func() {
+		server.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := lock.Lock(ctx, "cudgx:lock:down", 15*time.Second)
+		gomega.Expect(err).NotTo(gomega.BeNil())
+		gomega.Expect(errors.Is(err, clients.ErrLockNotAcquired)).To(gomega.BeFalse())
+	})
+})
@@ -0,0 +1,19 @@
+package clients
+
+import "context"
+
+//RequestIDHeader 调用 schedulx 时携带 request id 的请求头
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+//WithRequestID 将 request id 放入 ctx，通过 schedulx 客户端发送的请求都会带上该 id
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+//RequestIDFromContext 从 ctx 中读取 request id，不存在时返回空字符串
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
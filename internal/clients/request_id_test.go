@@ -0,0 +1,43 @@
+package clients_test
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Request ID", func() {
+	var stopSchedulx func()
+	var requestIDs []string
+
+	ginkgo.BeforeEach(func() {
+		requestIDs = nil
+		_, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			requestIDs = append(requestIDs, r.Header.Get(clients.RequestIDHeader))
+			_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":{"scheduling":false,"service_cluster_list":[{"instance_count":3}]}}`))
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		stopSchedulx()
+	})
+
+	ginkgo.It("sends the request id from the context to schedulx", func() {
+		ctx := clients.WithRequestID(context.Background(), "tick-1")
+		_, err := clients.CanServiceScheduleWithContext(ctx, "gf.cudgx.pi", "default")
+		gomega.Expect(err).To(gomega.BeNil())
+		count, err := clients.GetServiceInstanceCountWithContext(ctx, "gf.cudgx.pi", "default")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(count).To(gomega.Equal(3))
+		gomega.Expect(requestIDs).To(gomega.Equal([]string{"tick-1", "tick-1"}))
+	})
+
+	ginkgo.It("omits the header without a request id", func() {
+		_, err := clients.CanServiceSchedule("gf.cudgx.pi", "default")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(requestIDs).To(gomega.Equal([]string{""}))
+	})
+})
@@ -1,12 +1,15 @@
 package clients
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"golang.org/x/sync/singleflight"
 	"io/ioutil"
 	"net/http"
-	"sync"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/galaxy-future/cudgx/common/logger"
@@ -20,8 +23,9 @@ type LRUCache struct {
 }
 
 var (
-	cache = newLRUCache(1000)
+	cache = newIPCache(ipCacheSize)
 	_     = cleanCachePer3Mins()
+	_     = refreshCacheSizeGauge()
 	sf    singleflight.Group
 )
 
@@ -33,14 +37,8 @@ func newLRUCache(size int) *LRUCache {
 	return &LRUCache{Cache: l}
 }
 
-func NewSchedulxClient(serverAddress string) *Client {
-	return &Client{
-		ServerAddress: serverAddress,
-		HttpClient: &http.Client{
-			Timeout:   5000 * time.Millisecond,
-			Transport: XclientRoundTripper{r: http.DefaultTransport},
-		},
-	}
+func NewSchedulxClient(serverAddress string, opts ...ClientOption) *Client {
+	return newSchedulxClient(serverAddress, XclientRoundTripper{r: http.DefaultTransport}, opts...)
 }
 
 type XclientRoundTripper struct {
@@ -53,6 +51,9 @@ func (x XclientRoundTripper) RoundTrip(r *http.Request) (*http.Response, error)
 		return nil, err
 	}
 	r.Header.Add("Authorization", "Bearer: "+token)
+	if requestID := RequestIDFromContext(r.Context()); requestID != "" {
+		r.Header.Set(RequestIDHeader, requestID)
+	}
 	return x.r.RoundTrip(r)
 }
 
@@ -64,10 +65,15 @@ type SchedulxResponse struct {
 
 // CanServiceSchedule 判断该服务集群是否可以调度
 func CanServiceSchedule(serviceName, clusterName string) (bool, error) {
+	return CanServiceScheduleWithContext(context.Background(), serviceName, clusterName)
+}
+
+// CanServiceScheduleWithContext 判断该服务集群是否可以调度，ctx 中的 request id 会随请求发送
+func CanServiceScheduleWithContext(ctx context.Context, serviceName, clusterName string) (bool, error) {
 	if err := validateNames(serviceName, clusterName); err != nil {
 		return false, err
 	}
-	resp, err := schedulxClient.HttpClient.Get(fmt.Sprintf("%s/api/v1/schedulx/service/scheduling?service_name=%s&service_cluster_name=%s", schedulxClient.ServerAddress, serviceName, clusterName))
+	resp, err := schedulxGet(ctx, fmt.Sprintf("%s/api/v1/schedulx/service/scheduling?service_name=%s&service_cluster_name=%s", schedulxClient.ServerAddress, serviceName, clusterName))
 	if err != nil {
 		return false, err
 	}
@@ -91,40 +97,128 @@ func CanServiceSchedule(serviceName, clusterName string) (bool, error) {
 
 // GetServiceInstanceCount 获取该服务集群运行中的实例数
 func GetServiceInstanceCount(serviceName, clusterName string) (int, error) {
-	if err := validateNames(serviceName, clusterName); err != nil {
+	return GetServiceInstanceCountWithContext(context.Background(), serviceName, clusterName)
+}
+
+// GetServiceInstanceCountWithContext 获取该服务集群运行中的实例数，ctx 中的 request id 会随请求发送；
+// 结果按 SetInstanceCountCacheTTL 设置的时间缓存，通过本包扩缩容成功后缓存立即失效
+func GetServiceInstanceCountWithContext(ctx context.Context, serviceName, clusterName string) (int, error) {
+	key := ServiceClusterPair{ServiceName: serviceName, ClusterName: clusterName}
+	if count, ok := cachedInstanceCount(key); ok {
+		return count, nil
+	}
+	instanceCount, err := getServiceInstanceCountUncached(ctx, serviceName, clusterName)
+	if err != nil {
 		return 0, err
 	}
-	resp, err := schedulxClient.HttpClient.Get(fmt.Sprintf("%s/api/v1/schedulx/instance/count?service_name=%s&service_cluster_name=%s", schedulxClient.ServerAddress, serviceName, clusterName))
+	cacheInstanceCount(key, instanceCount)
+	return instanceCount, nil
+}
+
+// getServiceInstanceCountUncached 不经过缓存查询服务集群运行中的实例数，用于扩缩容后轮询实例数变化
+func getServiceInstanceCountUncached(ctx context.Context, serviceName, clusterName string) (int, error) {
+	serviceClusters, err := getServiceClusterInstances(ctx, serviceName, clusterName)
 	if err != nil {
 		return 0, err
 	}
 	var instanceCount int
+	for _, sc := range serviceClusters {
+		instanceCount += sc.InstanceCount
+	}
+	return instanceCount, nil
+}
+
+// GetServiceInstanceIpsWithContext 获取该服务集群运行中实例的内网 ip
+func GetServiceInstanceIpsWithContext(ctx context.Context, serviceName, clusterName string) ([]string, error) {
+	serviceClusters, err := getServiceClusterInstances(ctx, serviceName, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	var ips []string
+	for _, sc := range serviceClusters {
+		for _, instance := range sc.InstanceList {
+			if instance != nil && instance.IpInner != "" {
+				ips = append(ips, instance.IpInner)
+			}
+		}
+	}
+	return ips, nil
+}
+
+// GetServiceInstanceCountByCluster 获取服务所有集群运行中的实例数，key 为集群名称
+func GetServiceInstanceCountByCluster(ctx context.Context, serviceName string) (map[string]int, error) {
+	if serviceName == "" {
+		return nil, fmt.Errorf("服务名称不能为空")
+	}
+	if err := matchServiceNamePattern(serviceName); err != nil {
+		return nil, err
+	}
+	serviceClusters, err := requestServiceClusterInstances(ctx, serviceName, "")
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(serviceClusters))
+	for _, sc := range serviceClusters {
+		if sc == nil || sc.ServiceClusterName == "" {
+			continue
+		}
+		// 集群名称不匹配允许的正则时不参与调度
+		if MatchNamePatterns(serviceName, sc.ServiceClusterName) != nil {
+			continue
+		}
+		counts[sc.ServiceClusterName] += sc.InstanceCount
+	}
+	return counts, nil
+}
+
+// getServiceClusterInstances 查询服务集群运行中的实例
+func getServiceClusterInstances(ctx context.Context, serviceName, clusterName string) ([]*ServiceClusterInstanceCount, error) {
+	if err := validateNames(serviceName, clusterName); err != nil {
+		return nil, err
+	}
+	return requestServiceClusterInstances(ctx, serviceName, clusterName)
+}
+
+//requestServiceClusterInstances clusterName 为空时返回服务的所有集群
+func requestServiceClusterInstances(ctx context.Context, serviceName, clusterName string) ([]*ServiceClusterInstanceCount, error) {
+	resp, err := schedulxGet(ctx, fmt.Sprintf("%s/api/v1/schedulx/instance/count?service_name=%s&service_cluster_name=%s", schedulxClient.ServerAddress, serviceName, clusterName))
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
 	respData, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	var response GetServiceClusterInstanceResponse
 	err = json.Unmarshal(respData, &response)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	if response.Code != http.StatusOK {
 		err = fmt.Errorf("http code:%v | msg:%v", response.Code, response.Msg)
-		return 0, err
-	}
-	for _, sc := range response.Data.ServiceClusterList {
-		instanceCount += sc.InstanceCount
+		return nil, err
 	}
-	return instanceCount, nil
+	return response.Data.ServiceClusterList, nil
+}
+
+//ErrCapacityUnavailable schedulx 返回429，没有足够的资源扩容请求的实例数，减少实例数后可能成功
+var ErrCapacityUnavailable = errors.New("schedulx capacity unavailable")
+
+// ExpandService 扩容服务集群，idempotencyKey 不为空时60秒内不会重复发送相同 key 的请求
+func ExpandService(serviceName, clusterName string, count int, idempotencyKey string) error {
+	return ExpandServiceWithContext(context.Background(), serviceName, clusterName, count, idempotencyKey)
 }
 
-// ExpandService 扩容服务集群
-func ExpandService(serviceName, clusterName string, count int) error {
+// ExpandServiceWithContext 扩容服务集群，ctx 中的 request id 会随请求发送
+func ExpandServiceWithContext(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
 	if err := validateParams(serviceName, clusterName, count); err != nil {
 		return err
 	}
-	resp, err := schedulxClient.HttpClient.Get(fmt.Sprintf("%s/api/v1/schedulx/service/expand?service_name=%s&service_cluster=%s&count=%d&exec_type=auto", schedulxClient.ServerAddress, serviceName, clusterName, count))
+	if err := checkIdempotencyKey(idempotencyKey); err != nil {
+		return err
+	}
+	resp, err := schedulxGetWithIdempotencyKey(ctx, schedulxClient.ExpandClient, fmt.Sprintf("%s/api/v1/schedulx/service/expand?service_name=%s&service_cluster=%s&count=%d&exec_type=auto", schedulxClient.ServerAddress, serviceName, clusterName, count), idempotencyKey)
 	if err != nil {
 		return err
 	}
@@ -135,6 +229,9 @@ func ExpandService(serviceName, clusterName string, count int) error {
 	}
 	var response ExpandAndShrinkResponse
 	err = json.Unmarshal(respData, &response)
+	if resp.StatusCode == http.StatusTooManyRequests || (err == nil && response.Code == http.StatusTooManyRequests) {
+		return fmt.Errorf("%w , count:%d | msg:%v", ErrCapacityUnavailable, count, response.Msg)
+	}
 	if err != nil {
 		return err
 	}
@@ -142,16 +239,53 @@ func ExpandService(serviceName, clusterName string, count int) error {
 		err = fmt.Errorf("http code:%v | msg:%v", response.Code, response.Msg)
 		return err
 	}
+	invalidateScheduleCache(serviceName, clusterName)
+	invalidateInstanceListCache(serviceName, clusterName)
+	invalidateInstanceCountCache(serviceName, clusterName)
+	invalidateCloudQuotaCache(clusterName)
 	logger.GetLogger().Info(consts.SchedulxExpandSuccess, zap.String("service_name", serviceName), zap.String("service_cluster", clusterName), zap.Int("count", count))
 	return nil
 }
 
-// ShrinkService 缩容服务集群
-func ShrinkService(serviceName, clusterName string, count int) error {
+// ShrinkOptions 缩容时选择实例的方式
+type ShrinkOptions struct {
+	//Preference 缩容实例选择方式，为空或 default 时由 schedulx 决定
+	Preference string
+	//InstanceIps 指定要缩容的实例内网 ip，lowest_qps 时由调用方按 QPS 选出
+	InstanceIps []string
+	//PreferredInstanceIDs 优先缩容的实例 id，数量不足 count 时其余实例由 schedulx 按 Preference 选择
+	PreferredInstanceIDs []string
+}
+
+//query 转换为缩容请求的查询参数，default 且没有优先缩容的实例时为空
+func (opts ShrinkOptions) query() string {
+	var query string
+	if opts.Preference != "" && opts.Preference != consts.ShrinkPreferenceDefault {
+		query = "&shrink_preference=" + url.QueryEscape(opts.Preference)
+		if len(opts.InstanceIps) > 0 {
+			query += "&instance_ips=" + url.QueryEscape(strings.Join(opts.InstanceIps, ","))
+		}
+	}
+	if len(opts.PreferredInstanceIDs) > 0 {
+		query += "&preferred_instance_ids=" + url.QueryEscape(strings.Join(opts.PreferredInstanceIDs, ","))
+	}
+	return query
+}
+
+// ShrinkService 缩容服务集群，idempotencyKey 不为空时60秒内不会重复发送相同 key 的请求
+func ShrinkService(serviceName, clusterName string, count int, idempotencyKey string, opts ShrinkOptions) error {
+	return ShrinkServiceWithContext(context.Background(), serviceName, clusterName, count, idempotencyKey, opts)
+}
+
+// ShrinkServiceWithContext 缩容服务集群，ctx 中的 request id 会随请求发送
+func ShrinkServiceWithContext(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string, opts ShrinkOptions) error {
 	if err := validateParams(serviceName, clusterName, count); err != nil {
 		return err
 	}
-	resp, err := schedulxClient.HttpClient.Get(fmt.Sprintf("%s/api/v1/schedulx/service/shrink?service_name=%s&service_cluster=%s&count=%d&exec_type=auto", schedulxClient.ServerAddress, serviceName, clusterName, count))
+	if err := checkIdempotencyKey(idempotencyKey); err != nil {
+		return err
+	}
+	resp, err := schedulxGetWithIdempotencyKey(ctx, schedulxClient.ShrinkClient, fmt.Sprintf("%s/api/v1/schedulx/service/shrink?service_name=%s&service_cluster=%s&count=%d&exec_type=auto%s", schedulxClient.ServerAddress, serviceName, clusterName, count, opts.query()), idempotencyKey)
 	if err != nil {
 		return err
 	}
@@ -169,10 +303,30 @@ func ShrinkService(serviceName, clusterName string, count int) error {
 		err = fmt.Errorf("http code:%v | msg:%v", response.Code, response.Msg)
 		return err
 	}
+	invalidateScheduleCache(serviceName, clusterName)
+	invalidateInstanceListCache(serviceName, clusterName)
+	invalidateInstanceCountCache(serviceName, clusterName)
 	logger.GetLogger().Info(consts.SchedulxShrinkSuccess, zap.String("service_name", serviceName), zap.String("service_cluster", clusterName), zap.Int("count", count))
 	return nil
 }
 
+// schedulxGet 使用 ctx 发送 GET 请求
+func schedulxGet(ctx context.Context, url string) (*http.Response, error) {
+	return schedulxGetWithIdempotencyKey(ctx, schedulxClient.HttpClient, url, "")
+}
+
+// schedulxGetWithIdempotencyKey 使用 ctx 和 httpClient 发送 GET 请求，idempotencyKey 不为空时放入请求头
+func schedulxGetWithIdempotencyKey(ctx context.Context, httpClient *http.Client, url string, idempotencyKey string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if idempotencyKey != "" {
+		req.Header.Set(IdempotencyKeyHeader, idempotencyKey)
+	}
+	return httpClient.Do(req)
+}
+
 // validateParams 参数校验
 func validateParams(serviceName, clusterName string, instanceCount int) error {
 	if err := validateNames(serviceName, clusterName); err != nil {
@@ -191,7 +345,7 @@ func validateNames(serviceName, clusterName string) error {
 	if clusterName == "" {
 		return fmt.Errorf("集群名称不能为空")
 	}
-	return nil
+	return MatchNamePatterns(serviceName, clusterName)
 }
 
 type localServiceIpCache struct {
@@ -200,8 +354,8 @@ type localServiceIpCache struct {
 }
 
 // doGetServiceByIp 通过 ip 获取服务名称.
-func doGetServiceByIp(ip string) (GetServiceByIpData, error) {
-	resp, err := schedulxClient.HttpClient.Get(fmt.Sprintf("%s/api/v1/schedulx/instance/service?ip_inner=%s", schedulxClient.ServerAddress, ip))
+func doGetServiceByIp(ctx context.Context, ip string) (GetServiceByIpData, error) {
+	resp, err := schedulxGet(ctx, fmt.Sprintf("%s/api/v1/schedulx/instance/service?ip_inner=%s", schedulxClient.ServerAddress, ip))
 	if err != nil {
 		return GetServiceByIpData{}, err
 	}
@@ -223,39 +377,97 @@ func doGetServiceByIp(ip string) (GetServiceByIpData, error) {
 	return response.Data, nil
 }
 
-func GetServiceByIp(ip string) (GetServiceByIpData, error) {
+// GetServiceByIp 通过 ip 获取服务名称，缓存未命中时受 SetServiceByIpRateLimit 限流，最多等待 serviceByIpMaxWait；ctx 结束时立即返回 ctx.Err()
+func GetServiceByIp(ctx context.Context, ip string) (GetServiceByIpData, error) {
+	data, _, err := GetServiceByIpWithCacheStatus(ctx, ip)
+	return data, err
+}
+
+// GetServiceByIpWithCacheStatus 通过 ip 获取服务名称，fromCache 表示结果是否来自缓存，为 false 时刚刚请求过 schedulx
+func GetServiceByIpWithCacheStatus(ctx context.Context, ip string) (data GetServiceByIpData, fromCache bool, err error) {
 	srv, ok := cache.Get(ip)
 	if ok {
+		ipCacheHitsCounter.Inc()
 		d, _ := srv.(GetServiceByIpData)
-		return d, nil
+		return d, true, nil
 	}
+	ipCacheMissesCounter.Inc()
 
-	data, err, _ := sf.Do(ip, func() (interface{}, error) {
-		res, err := doGetServiceByIp(ip)
-		if err != nil {
-			return nil, err
-		}
-		cache.Add(ip, res)
-		return res, nil
-	})
+	d, err := doGetServiceByIpShared(ctx, ip)
 	if err != nil {
-		return GetServiceByIpData{}, err
+		return GetServiceByIpData{}, false, err
 	}
-	d, _ := data.(GetServiceByIpData)
-	return d, nil
+	return d, false, nil
 }
 
 func cleanCachePer3Mins() interface{} {
 	go func() {
-		var l sync.Mutex
 		for {
-			l.Lock()
-			func() {
-				defer l.Unlock()
-				cache.Cache, _ = lru.New(1000)
-			}()
 			time.Sleep(time.Minute * 3)
+			// lru.Cache 内部加锁，原地清空避免替换指针时与读取方竞争
+			cache.Purge()
 		}
 	}()
 	return nil
 }
+
+//PartialExpandError 分批扩容中途失败，Expanded 为已成功扩容的实例数
+type PartialExpandError struct {
+	Expanded int
+	Err      error
+}
+
+func (e *PartialExpandError) Error() string {
+	return fmt.Sprintf("gradual expand aborted after %d instances expanded, %v", e.Expanded, e.Err)
+}
+
+func (e *PartialExpandError) Unwrap() error {
+	return e.Err
+}
+
+// GradualExpandService 分批扩容服务集群，每批扩容后等待batchInterval并校验实例数
+// idempotencyKey 不为空时每批使用 key 加批次序号作为该批的 key
+func GradualExpandService(ctx context.Context, serviceName, clusterName string, totalCount int, batchSize int, batchInterval time.Duration, idempotencyKey string) error {
+	if err := validateParams(serviceName, clusterName, totalCount); err != nil {
+		return err
+	}
+	if batchSize <= 0 || batchSize > totalCount {
+		batchSize = totalCount
+	}
+	baseCount, err := getServiceInstanceCountUncached(ctx, serviceName, clusterName)
+	if err != nil {
+		return err
+	}
+	expanded := 0
+	for batch := 0; expanded < totalCount; batch++ {
+		count := batchSize
+		if totalCount-expanded < count {
+			count = totalCount - expanded
+		}
+		batchKey := ""
+		if idempotencyKey != "" {
+			batchKey = fmt.Sprintf("%s-%d", idempotencyKey, batch)
+		}
+		if err := ExpandServiceWithContext(ctx, serviceName, clusterName, count, batchKey); err != nil {
+			return &PartialExpandError{Expanded: expanded, Err: err}
+		}
+		// 请求成功后 schedulx 已经开始扩容，之后的等待或校验失败也计入已扩容的实例数
+		expanded += count
+		select {
+		case <-ctx.Done():
+			return &PartialExpandError{Expanded: expanded, Err: ctx.Err()}
+		case <-time.After(batchInterval):
+		}
+		currentCount, err := getServiceInstanceCountUncached(ctx, serviceName, clusterName)
+		if err != nil {
+			return &PartialExpandError{Expanded: expanded, Err: err}
+		}
+		if currentCount < baseCount+expanded {
+			return &PartialExpandError{Expanded: expanded,
+				Err: fmt.Errorf("instance count %d less than expected %d", currentCount, baseCount+expanded)}
+		}
+		logger.GetLogger().Info("gradual expand batch finished", zap.String("service_name", serviceName),
+			zap.String("service_cluster", clusterName), zap.Int("expanded", expanded), zap.Int("total", totalCount))
+	}
+	return nil
+}
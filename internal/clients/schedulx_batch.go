@@ -0,0 +1,201 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+//scheduleCacheSize 服务调度状态缓存容量
+const scheduleCacheSize = 1000
+
+//defaultScheduleCacheTTL 默认调度周期(60s)的一半
+const defaultScheduleCacheTTL = 30 * time.Second
+
+var (
+	scheduleCache    = newLRUCache(scheduleCacheSize)
+	scheduleCacheTTL atomic.Int64
+	//canScheduleSF 合并同一服务集群并发的调度状态查询，key 为 服务名/集群名
+	canScheduleSF singleflight.Group
+
+	errBatchScheduleUnsupported = errors.New("schedulx does not support batch schedule query")
+)
+
+func init() {
+	scheduleCacheTTL.Store(int64(defaultScheduleCacheTTL))
+}
+
+//ServiceClusterPair 服务名与集群名
+type ServiceClusterPair struct {
+	ServiceName string `json:"service_name"`
+	ClusterName string `json:"service_cluster_name"`
+}
+
+type batchServiceScheduleRequest struct {
+	ServiceClusterList []ServiceClusterPair `json:"service_cluster_list"`
+}
+
+type BatchServiceScheduleResponse struct {
+	Code int64                    `json:"code"`
+	Msg  string                   `json:"msg"`
+	Data BatchServiceScheduleList `json:"data"`
+}
+
+type BatchServiceScheduleList struct {
+	ServiceClusterList []*ServiceSchedule `json:"service_cluster_list"`
+}
+
+type scheduleCacheEntry struct {
+	canSchedule bool
+	expireAt    time.Time
+}
+
+//SetScheduleCacheTTL 设置服务调度状态的缓存时间，不大于0时不缓存
+func SetScheduleCacheTTL(ttl time.Duration) {
+	scheduleCacheTTL.Store(int64(ttl))
+}
+
+//BatchCanServiceSchedule 一次请求判断多个服务集群是否可以调度，schedulx 不支持批量接口时逐个查询
+func BatchCanServiceSchedule(pairs []ServiceClusterPair) (map[ServiceClusterPair]bool, error) {
+	return BatchCanServiceScheduleWithContext(context.Background(), pairs)
+}
+
+//BatchCanServiceScheduleWithContext 一次请求判断多个服务集群是否可以调度，ctx 中的 request id 会随请求发送
+func BatchCanServiceScheduleWithContext(ctx context.Context, pairs []ServiceClusterPair) (map[ServiceClusterPair]bool, error) {
+	result := make(map[ServiceClusterPair]bool, len(pairs))
+	var missed []ServiceClusterPair
+	now := time.Now()
+	for _, pair := range pairs {
+		if err := validateNames(pair.ServiceName, pair.ClusterName); err != nil {
+			return nil, err
+		}
+		if _, ok := result[pair]; ok {
+			continue
+		}
+		if value, ok := scheduleCache.Get(pair); ok {
+			entry := value.(scheduleCacheEntry)
+			if now.Before(entry.expireAt) {
+				result[pair] = entry.canSchedule
+				continue
+			}
+			scheduleCache.Remove(pair)
+		}
+		missed = append(missed, pair)
+	}
+	if len(missed) == 0 {
+		return result, nil
+	}
+
+	fetched, err := doBatchCanServiceSchedule(ctx, missed)
+	if err == errBatchScheduleUnsupported {
+		fetched, err = sequentialCanServiceSchedule(ctx, missed)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ttl := time.Duration(scheduleCacheTTL.Load())
+	for pair, canSchedule := range fetched {
+		result[pair] = canSchedule
+		if ttl > 0 {
+			scheduleCache.Add(pair, scheduleCacheEntry{canSchedule: canSchedule, expireAt: now.Add(ttl)})
+		}
+	}
+	return result, nil
+}
+
+//CanServiceScheduleShared 判断服务集群是否可以调度，先查缓存；同一服务集群的并发查询共用一次请求，
+//结果按 SetScheduleCacheTTL 设置的时间缓存。ctx 结束时只有当前调用方返回，共用的请求不受影响
+func CanServiceScheduleShared(ctx context.Context, serviceName, clusterName string) (bool, error) {
+	pair := ServiceClusterPair{ServiceName: serviceName, ClusterName: clusterName}
+	results := canScheduleSF.DoChan(serviceName+"/"+clusterName, func() (interface{}, error) {
+		result, err := BatchCanServiceScheduleWithContext(context.WithoutCancel(ctx), []ServiceClusterPair{pair})
+		if err != nil {
+			return false, err
+		}
+		return result[pair], nil
+	})
+	select {
+	case result := <-results:
+		if result.Err != nil {
+			return false, result.Err
+		}
+		return result.Val.(bool), nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+//invalidateScheduleCache 扩缩容后服务进入调度中，清除缓存的状态
+func invalidateScheduleCache(serviceName, clusterName string) {
+	scheduleCache.Remove(ServiceClusterPair{ServiceName: serviceName, ClusterName: clusterName})
+}
+
+func doBatchCanServiceSchedule(ctx context.Context, pairs []ServiceClusterPair) (map[ServiceClusterPair]bool, error) {
+	data, err := json.Marshal(batchServiceScheduleRequest{ServiceClusterList: pairs})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/api/v1/schedulx/service/scheduling/batch", schedulxClient.ServerAddress), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := schedulxClient.HttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errBatchScheduleUnsupported
+	}
+	respData, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var response BatchServiceScheduleResponse
+	err = json.Unmarshal(respData, &response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Code == http.StatusNotFound {
+		return nil, errBatchScheduleUnsupported
+	}
+	if response.Code != http.StatusOK {
+		err = fmt.Errorf("http code:%v | msg:%v", response.Code, response.Msg)
+		return nil, err
+	}
+	result := make(map[ServiceClusterPair]bool, len(pairs))
+	for _, schedule := range response.Data.ServiceClusterList {
+		if schedule == nil {
+			continue
+		}
+		result[ServiceClusterPair{ServiceName: schedule.ServiceName, ClusterName: schedule.ServiceClusterName}] = !schedule.Scheduling
+	}
+	for _, pair := range pairs {
+		if _, ok := result[pair]; !ok {
+			return nil, fmt.Errorf("batch schedule response missing service %s cluster %s", pair.ServiceName, pair.ClusterName)
+		}
+	}
+	return result, nil
+}
+
+func sequentialCanServiceSchedule(ctx context.Context, pairs []ServiceClusterPair) (map[ServiceClusterPair]bool, error) {
+	result := make(map[ServiceClusterPair]bool, len(pairs))
+	for _, pair := range pairs {
+		canSchedule, err := CanServiceScheduleWithContext(ctx, pair.ServiceName, pair.ClusterName)
+		if err != nil {
+			return nil, err
+		}
+		result[pair] = canSchedule
+	}
+	return result, nil
+}
@@ -0,0 +1,141 @@
+package clients_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("BatchCanServiceSchedule", func() {
+	var stopSchedulx func()
+	var batchRequests, singleRequests int
+	var batchSupported bool
+	var delay time.Duration
+	pairs := []clients.ServiceClusterPair{
+		{ServiceName: "gf.cudgx.a", ClusterName: "default"},
+		{ServiceName: "gf.cudgx.b", ClusterName: "default"},
+	}
+
+	ginkgo.BeforeEach(func() {
+		batchRequests, singleRequests = 0, 0
+		delay = 0
+		_, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v1/schedulx/service/scheduling/batch":
+				batchRequests++
+				time.Sleep(delay)
+				if !batchSupported {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				// 只返回请求的服务集群，服务名以 b 或 d 结尾的在调度中
+				var request struct {
+					ServiceClusterList []clients.ServiceClusterPair `json:"service_cluster_list"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&request)
+				var list []string
+				for _, pair := range request.ServiceClusterList {
+					scheduling := strings.HasSuffix(pair.ServiceName, "b") || strings.HasSuffix(pair.ServiceName, "d")
+					list = append(list, fmt.Sprintf(`{"scheduling":%t,"service_name":"%s","service_cluster_name":"%s"}`, scheduling, pair.ServiceName, pair.ClusterName))
+				}
+				_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":{"service_cluster_list":[` + strings.Join(list, ",") + `]}}`))
+			case "/api/v1/schedulx/service/scheduling":
+				singleRequests++
+				scheduling := r.URL.Query().Get("service_name") == "gf.cudgx.b"
+				if scheduling {
+					_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":{"scheduling":true}}`))
+					return
+				}
+				_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":{"scheduling":false}}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		})
+		clients.SetScheduleCacheTTL(0)
+	})
+
+	ginkgo.AfterEach(func() {
+		stopSchedulx()
+		clients.SetScheduleCacheTTL(30 * time.Second)
+	})
+
+	ginkgo.It("queries all pairs in one request", func() {
+		batchSupported = true
+		result, err := clients.BatchCanServiceSchedule(pairs)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result[pairs[0]]).To(gomega.BeTrue())
+		gomega.Expect(result[pairs[1]]).To(gomega.BeFalse())
+		gomega.Expect(batchRequests).To(gomega.Equal(1))
+		gomega.Expect(singleRequests).To(gomega.Equal(0))
+	})
+
+	ginkgo.It("falls back to sequential queries on 404", func() {
+		batchSupported = false
+		result, err := clients.BatchCanServiceSchedule(pairs)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result[pairs[0]]).To(gomega.BeTrue())
+		gomega.Expect(result[pairs[1]]).To(gomega.BeFalse())
+		gomega.Expect(singleRequests).To(gomega.Equal(2))
+	})
+
+	ginkgo.It("serves cached pairs until the ttl expires", func() {
+		batchSupported = true
+		clients.SetScheduleCacheTTL(100 * time.Millisecond)
+		_, err := clients.BatchCanServiceSchedule(pairs)
+		gomega.Expect(err).To(gomega.BeNil())
+		result, err := clients.BatchCanServiceSchedule(pairs[:1])
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result[pairs[0]]).To(gomega.BeTrue())
+		gomega.Expect(batchRequests).To(gomega.Equal(1))
+
+		time.Sleep(150 * time.Millisecond)
+		_, err = clients.BatchCanServiceSchedule(pairs)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(batchRequests).To(gomega.Equal(2))
+	})
+
+	// 以下用例使用其他用例没有查询过的服务集群，避免命中缓存
+	ginkgo.It("shares one request between concurrent queries of the same pair", func() {
+		batchSupported = true
+		delay = 100 * time.Millisecond
+		var wg sync.WaitGroup
+		results := make([]bool, 5)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				defer ginkgo.GinkgoRecover()
+				canSchedule, err := clients.CanServiceScheduleShared(context.Background(), "gf.cudgx.c", "default")
+				gomega.Expect(err).To(gomega.BeNil())
+				results[i] = canSchedule
+			}(i)
+		}
+		wg.Wait()
+		gomega.Expect(results).To(gomega.Equal([]bool{true, true, true, true, true}))
+		gomega.Expect(batchRequests).To(gomega.Equal(1))
+	})
+
+	ginkgo.It("returns when the caller's context is done without cancelling the shared request", func() {
+		batchSupported = true
+		delay = 100 * time.Millisecond
+		clients.SetScheduleCacheTTL(time.Minute)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := clients.CanServiceScheduleShared(ctx, "gf.cudgx.d", "default")
+		gomega.Expect(err).To(gomega.MatchError(context.DeadlineExceeded))
+
+		time.Sleep(150 * time.Millisecond)
+		canSchedule, err := clients.CanServiceScheduleShared(context.Background(), "gf.cudgx.d", "default")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(canSchedule).To(gomega.BeFalse())
+		gomega.Expect(batchRequests).To(gomega.Equal(1))
+	})
+})
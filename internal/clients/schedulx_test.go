@@ -21,7 +21,7 @@ var _ = ginkgo.Describe("Xclient", func() {
 			can, err := clients.CanServiceSchedule("gf.cudgx.pi", "gf.cudgx.pi")
 			gomega.Expect(err).To(gomega.BeNil())
 			if can {
-				err := clients.ExpandService("gf.cudgx.pi", "gf.cudgx.pi", 1)
+				err := clients.ExpandService("gf.cudgx.pi", "gf.cudgx.pi", 1, "")
 				gomega.Expect(err).To(gomega.BeNil())
 			}
 		})
@@ -29,7 +29,7 @@ var _ = ginkgo.Describe("Xclient", func() {
 			can, err := clients.CanServiceSchedule("gf.cudgx.pi", "gf.cudgx.pi")
 			gomega.Expect(err).To(gomega.BeNil())
 			if can {
-				err := clients.ShrinkService("gf.cudgx.pi", "gf.cudgx.pi", 1)
+				err := clients.ShrinkService("gf.cudgx.pi", "gf.cudgx.pi", 1, "", clients.ShrinkOptions{})
 				gomega.Expect(err).To(gomega.BeNil())
 			}
 		})
@@ -0,0 +1,103 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+//errServiceByIpCallCanceled 共享的 GetServiceByIp 请求因所有调用方都已放弃而被取消
+var errServiceByIpCallCanceled = errors.New("shared service by ip call canceled")
+
+//serviceByIpCall 同一个 ip 正在进行的 schedulx 请求，所有等待的调用方都放弃后取消请求
+type serviceByIpCall struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
+}
+
+var (
+	serviceByIpCallsLock sync.Mutex
+	serviceByIpCalls     = map[string]*serviceByIpCall{}
+)
+
+//doGetServiceByIpShared 合并同一个 ip 的并发请求；ctx 结束时只有当前调用方返回，其它调用方仍会拿到结果
+func doGetServiceByIpShared(ctx context.Context, ip string) (GetServiceByIpData, error) {
+	for {
+		call, results := joinServiceByIpCall(ctx, ip)
+		select {
+		case result := <-results:
+			leaveServiceByIpCall(ip, call)
+			// 加入的是一个已被其它调用方取消的请求，重新发起
+			if errors.Is(result.Err, errServiceByIpCallCanceled) && ctx.Err() == nil {
+				continue
+			}
+			if result.Err != nil {
+				return GetServiceByIpData{}, result.Err
+			}
+			data, _ := result.Val.(GetServiceByIpData)
+			return data, nil
+		case <-ctx.Done():
+			leaveServiceByIpCall(ip, call)
+			return GetServiceByIpData{}, ctx.Err()
+		}
+	}
+}
+
+//joinServiceByIpCall 登记为 ip 请求的等待方，没有进行中的请求时以 ctx 的值（不含取消）发起新请求
+func joinServiceByIpCall(ctx context.Context, ip string) (*serviceByIpCall, <-chan singleflight.Result) {
+	serviceByIpCallsLock.Lock()
+	defer serviceByIpCallsLock.Unlock()
+	call, ok := serviceByIpCalls[ip]
+	if !ok {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &serviceByIpCall{ctx: callCtx, cancel: cancel}
+		serviceByIpCalls[ip] = call
+	}
+	call.waiters++
+	results := sf.DoChan(ip, func() (interface{}, error) {
+		res, err := fetchServiceByIp(call.ctx, ip)
+		serviceByIpCallsLock.Lock()
+		if serviceByIpCalls[ip] == call {
+			delete(serviceByIpCalls, ip)
+		}
+		serviceByIpCallsLock.Unlock()
+		if err != nil && call.ctx.Err() != nil {
+			return nil, errServiceByIpCallCanceled
+		}
+		return res, err
+	})
+	return call, results
+}
+
+//leaveServiceByIpCall 取消登记，最后一个等待方离开时取消请求
+func leaveServiceByIpCall(ip string, call *serviceByIpCall) {
+	serviceByIpCallsLock.Lock()
+	defer serviceByIpCallsLock.Unlock()
+	call.waiters--
+	if call.waiters > 0 {
+		return
+	}
+	call.cancel()
+	if serviceByIpCalls[ip] == call {
+		delete(serviceByIpCalls, ip)
+	}
+}
+
+//fetchServiceByIp 获取令牌后请求 schedulx 并写入缓存，等待令牌最多 serviceByIpMaxWait
+func fetchServiceByIp(ctx context.Context, ip string) (GetServiceByIpData, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, serviceByIpMaxWait)
+	err := waitServiceByIpToken(waitCtx)
+	cancel()
+	if err != nil {
+		return GetServiceByIpData{}, err
+	}
+	res, err := doGetServiceByIp(ctx, ip)
+	if err != nil {
+		return GetServiceByIpData{}, err
+	}
+	addServiceByIpCache(ip, res)
+	return res, nil
+}
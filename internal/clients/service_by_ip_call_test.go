@@ -0,0 +1,87 @@
+package clients_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("GetServiceByIp context", func() {
+	var stopSchedulx func()
+	var lock sync.Mutex
+	var requests, canceled int
+	var release chan struct{}
+
+	ginkgo.BeforeEach(func() {
+		requests, canceled = 0, 0
+		release = make(chan struct{})
+		_, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			requests++
+			lock.Unlock()
+			select {
+			case <-release:
+			case <-r.Context().Done():
+				lock.Lock()
+				canceled++
+				lock.Unlock()
+				return
+			}
+			_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":{"service_name":"gf.cudgx.pi","cluster_name":"default"}}`))
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		stopSchedulx()
+	})
+
+	counts := func() (int, int) {
+		lock.Lock()
+		defer lock.Unlock()
+		return requests, canceled
+	}
+
+	ginkgo.It("returns once the context is done without failing other callers", func() {
+		done := make(chan error)
+		go func() {
+			data, err := clients.GetServiceByIp(context.Background(), "10.2.0.1")
+			if err == nil {
+				gomega.Expect(data.ServiceName).To(gomega.Equal("gf.cudgx.pi"))
+			}
+			done <- err
+		}()
+		gomega.Eventually(func() int { r, _ := counts(); return r }).Should(gomega.Equal(1))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := clients.GetServiceByIp(ctx, "10.2.0.1")
+		gomega.Expect(err).To(gomega.Equal(context.DeadlineExceeded))
+
+		close(release)
+		gomega.Expect(<-done).To(gomega.BeNil())
+		requests, canceled := counts()
+		gomega.Expect(requests).To(gomega.Equal(1))
+		gomega.Expect(canceled).To(gomega.Equal(0))
+	})
+
+	ginkgo.It("cancels the request once every caller is gone", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			gomega.Eventually(func() int { r, _ := counts(); return r }).Should(gomega.Equal(1))
+			cancel()
+		}()
+		_, err := clients.GetServiceByIp(ctx, "10.2.0.2")
+		gomega.Expect(err).To(gomega.Equal(context.Canceled))
+		gomega.Eventually(func() int { _, c := counts(); return c }).Should(gomega.Equal(1))
+
+		close(release)
+		data, err := clients.GetServiceByIp(context.Background(), "10.2.0.2")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(data.ClusterName).To(gomega.Equal("default"))
+	})
+})
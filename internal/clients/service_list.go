@@ -0,0 +1,42 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+type ListAvailableServicesResponse struct {
+	Code int64                 `json:"code"`
+	Msg  string                `json:"msg"`
+	Data AvailableServicesData `json:"data"`
+}
+
+type AvailableServicesData struct {
+	ServiceClusterList []ServiceClusterPair `json:"service_cluster_list"`
+}
+
+// ListAvailableServices 查询 schedulx 中所有服务集群，ctx 中的 request id 会随请求发送
+func ListAvailableServices(ctx context.Context) ([]ServiceClusterPair, error) {
+	resp, err := schedulxGet(ctx, fmt.Sprintf("%s/api/v1/schedulx/service/cluster/list", schedulxClient.ServerAddress))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respData, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var response ListAvailableServicesResponse
+	err = json.Unmarshal(respData, &response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Code != http.StatusOK {
+		err = fmt.Errorf("http code:%v | msg:%v", response.Code, response.Msg)
+		return nil, err
+	}
+	return response.Data.ServiceClusterList, nil
+}
@@ -0,0 +1,52 @@
+package clients_test
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("ListAvailableServices", func() {
+	var stopSchedulx func()
+	var code int
+
+	ginkgo.BeforeEach(func() {
+		code = http.StatusOK
+		_, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v1/schedulx/service/cluster/list":
+				if code != http.StatusOK {
+					_, _ = w.Write([]byte(`{"code":500,"msg":"internal error"}`))
+					return
+				}
+				_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":{"service_cluster_list":[` +
+					`{"service_name":"gf.cudgx.a","service_cluster_name":"default"},` +
+					`{"service_name":"gf.cudgx.b","service_cluster_name":"canary"}]}}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		stopSchedulx()
+	})
+
+	ginkgo.It("lists every service cluster", func() {
+		pairs, err := clients.ListAvailableServices(context.Background())
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(pairs).To(gomega.Equal([]clients.ServiceClusterPair{
+			{ServiceName: "gf.cudgx.a", ClusterName: "default"},
+			{ServiceName: "gf.cudgx.b", ClusterName: "canary"},
+		}))
+	})
+
+	ginkgo.It("returns the schedulx error", func() {
+		code = http.StatusInternalServerError
+		_, err := clients.ListAvailableServices(context.Background())
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("internal error")))
+	})
+})
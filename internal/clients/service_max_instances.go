@@ -0,0 +1,64 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+//serviceMaxInstancesCacheTTL schedulx 实例数上限的缓存时间，上限由云厂商配额决定，变化不频繁
+const serviceMaxInstancesCacheTTL = 5 * time.Minute
+
+var serviceMaxInstancesCache = newLRUCache(scheduleCacheSize)
+
+type GetServiceMaxInstancesResponse struct {
+	Code int64               `json:"code"`
+	Msg  string              `json:"msg"`
+	Data ServiceMaxInstances `json:"data"`
+}
+
+type ServiceMaxInstances struct {
+	MaxInstances int `json:"max_instances"`
+}
+
+type serviceMaxInstancesCacheEntry struct {
+	maxInstances int
+	expireAt     time.Time
+}
+
+// GetServiceMaxInstances 获取 schedulx 对该服务集群的实例数硬上限，0表示没有上限；结果按服务集群缓存5分钟
+func GetServiceMaxInstances(ctx context.Context, serviceName, clusterName string) (int, error) {
+	if err := validateNames(serviceName, clusterName); err != nil {
+		return 0, err
+	}
+	key := ServiceClusterPair{ServiceName: serviceName, ClusterName: clusterName}
+	if value, ok := serviceMaxInstancesCache.Get(key); ok {
+		if entry := value.(serviceMaxInstancesCacheEntry); time.Now().Before(entry.expireAt) {
+			return entry.maxInstances, nil
+		}
+		serviceMaxInstancesCache.Remove(key)
+	}
+	resp, err := schedulxGet(ctx, fmt.Sprintf("%s/api/v1/schedulx/service/max_instances?service_name=%s&service_cluster_name=%s", schedulxClient.ServerAddress, serviceName, clusterName))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	respData, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	var response GetServiceMaxInstancesResponse
+	err = json.Unmarshal(respData, &response)
+	if err != nil {
+		return 0, err
+	}
+	if response.Code != http.StatusOK {
+		err = fmt.Errorf("http code:%v | msg:%v", response.Code, response.Msg)
+		return 0, err
+	}
+	serviceMaxInstancesCache.Add(key, serviceMaxInstancesCacheEntry{maxInstances: response.Data.MaxInstances, expireAt: time.Now().Add(serviceMaxInstancesCacheTTL)})
+	return response.Data.MaxInstances, nil
+}
@@ -0,0 +1,51 @@
+package clients_test
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("GetServiceMaxInstances", func() {
+	var stopSchedulx func()
+	var queries []string
+
+	ginkgo.BeforeEach(func() {
+		queries = nil
+		_, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v1/schedulx/service/max_instances":
+				queries = append(queries, r.URL.RawQuery)
+				if r.URL.Query().Get("service_cluster_name") == "broken" {
+					_, _ = w.Write([]byte(`{"code":500,"msg":"quota service unavailable"}`))
+					return
+				}
+				_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":{"max_instances":40}}`))
+			}
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		stopSchedulx()
+	})
+
+	ginkgo.It("caches the hard cap per service cluster", func() {
+		for i := 0; i < 2; i++ {
+			maxInstances, err := clients.GetServiceMaxInstances(context.Background(), "gf.cudgx.quota", "cached")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(maxInstances).To(gomega.Equal(40))
+		}
+		gomega.Expect(queries).To(gomega.Equal([]string{"service_name=gf.cudgx.quota&service_cluster_name=cached"}))
+	})
+
+	ginkgo.It("does not cache failures", func() {
+		for i := 0; i < 2; i++ {
+			_, err := clients.GetServiceMaxInstances(context.Background(), "gf.cudgx.quota", "broken")
+			gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("quota service unavailable")))
+		}
+		gomega.Expect(queries).To(gomega.HaveLen(2))
+	})
+})
@@ -0,0 +1,71 @@
+package clients_test
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("ShrinkOptions", func() {
+	var stopSchedulx func()
+	var queries []string
+
+	ginkgo.BeforeEach(func() {
+		queries = nil
+		_, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/v1/schedulx/instance/list" {
+				_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":{"instance_list":[` +
+					`{"instance_id":"i-1","ip_inner":"10.0.0.1","status":"running","create_at":1640000000},{"instance_id":"i-2","ip_inner":"10.0.0.2"},{"ip_inner":"10.0.0.3"}]}}`))
+				return
+			}
+			queries = append(queries, r.URL.RawQuery)
+			_, _ = w.Write([]byte(`{"code":200,"msg":"success"}`))
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		stopSchedulx()
+	})
+
+	ginkgo.It("omits shrink_preference by default", func() {
+		gomega.Expect(clients.ShrinkService("gf.cudgx.pi", "default", 1, "", clients.ShrinkOptions{})).To(gomega.BeNil())
+		gomega.Expect(clients.ShrinkService("gf.cudgx.pi", "default", 1, "", clients.ShrinkOptions{Preference: consts.ShrinkPreferenceDefault})).To(gomega.BeNil())
+		gomega.Expect(queries).To(gomega.HaveLen(2))
+		for _, query := range queries {
+			gomega.Expect(query).NotTo(gomega.ContainSubstring("shrink_preference"))
+		}
+	})
+
+	ginkgo.It("sends the preference and the selected instances", func() {
+		gomega.Expect(clients.ShrinkService("gf.cudgx.pi", "default", 1, "", clients.ShrinkOptions{Preference: consts.ShrinkPreferenceOldest})).To(gomega.BeNil())
+		gomega.Expect(clients.ShrinkService("gf.cudgx.pi", "default", 2, "", clients.ShrinkOptions{
+			Preference:  consts.ShrinkPreferenceLowestQps,
+			InstanceIps: []string{"10.0.0.1", "10.0.0.2"},
+		})).To(gomega.BeNil())
+		gomega.Expect(queries).To(gomega.Equal([]string{
+			"service_name=gf.cudgx.pi&service_cluster=default&count=1&exec_type=auto&shrink_preference=oldest",
+			"service_name=gf.cudgx.pi&service_cluster=default&count=2&exec_type=auto&shrink_preference=lowest_qps&instance_ips=10.0.0.1%2C10.0.0.2",
+		}))
+	})
+
+	ginkgo.It("sends preferred instance ids even with the default preference", func() {
+		gomega.Expect(clients.ShrinkService("gf.cudgx.pi", "default", 2, "", clients.ShrinkOptions{PreferredInstanceIDs: []string{"i-2", "i-1"}})).To(gomega.BeNil())
+		gomega.Expect(queries).To(gomega.Equal([]string{
+			"service_name=gf.cudgx.pi&service_cluster=default&count=2&exec_type=auto&preferred_instance_ids=i-2%2Ci-1",
+		}))
+	})
+
+	ginkgo.It("lists running instances with their creation time", func() {
+		instances, err := clients.GetServiceInstanceList(context.Background(), "gf.cudgx.pi", "default")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(instances).To(gomega.Equal([]clients.InstanceInfo{
+			{IP: "10.0.0.1", InstanceID: "i-1", Status: "running", CreatedAt: time.Unix(1640000000, 0)},
+			{IP: "10.0.0.2", InstanceID: "i-2"},
+		}))
+	})
+})
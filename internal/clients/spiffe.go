@@ -0,0 +1,108 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/logger"
+	"github.com/spiffe/go-spiffe/v2/spiffetls/tlsconfig"
+	"github.com/spiffe/go-spiffe/v2/svid/jwtsvid"
+	"github.com/spiffe/go-spiffe/v2/workloadapi"
+)
+
+//spiffeJWTAudience 请求 schedulx 时使用的 JWT-SVID audience
+const spiffeJWTAudience = "schedulx"
+
+//spiffeInitTimeout 等待 SPIFFE Workload API 返回第一个 SVID 的时间
+const spiffeInitTimeout = 10 * time.Second
+
+//SPIFFERoundTripper 使用 JWT-SVID 作为 Bearer token 请求 schedulx
+type SPIFFERoundTripper struct {
+	jwtSource *workloadapi.JWTSource
+	r         http.RoundTripper
+}
+
+func (s SPIFFERoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	svid, err := s.jwtSource.FetchJWTSVID(r.Context(), jwtsvid.Params{Audience: spiffeJWTAudience})
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwt svid failed , %w", err)
+	}
+	r.Header.Add("Authorization", "Bearer: "+svid.Marshal())
+	if requestID := RequestIDFromContext(r.Context()); requestID != "" {
+		r.Header.Set(RequestIDHeader, requestID)
+	}
+	return s.r.RoundTrip(r)
+}
+
+// NewSchedulxClientWithSPIFFE 新建使用 SPIFFE 工作负载身份的 schedulx 客户端：X509-SVID 用于 mTLS，JWT-SVID 作为 Bearer token。
+// socketPath 为 Workload API 的 unix socket 路径，也可以是 unix:// 或 tcp:// 地址；证书轮换后关闭空闲连接，新连接使用新证书
+func NewSchedulxClientWithSPIFFE(serverAddress, socketPath string, opts ...ClientOption) (*Client, error) {
+	if socketPath == "" {
+		return nil, errors.New("spiffe socket path can not be empty")
+	}
+	addr := socketPath
+	if !strings.Contains(addr, "://") {
+		addr = "unix://" + addr
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), spiffeInitTimeout)
+	defer cancel()
+	clientOptions := workloadapi.WithClientOptions(workloadapi.WithAddr(addr))
+	x509Source, err := workloadapi.NewX509Source(ctx, clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("create x509 source from %s failed , %w", addr, err)
+	}
+	jwtSource, err := workloadapi.NewJWTSource(ctx, clientOptions)
+	if err != nil {
+		_ = x509Source.Close()
+		return nil, fmt.Errorf("create jwt source from %s failed , %w", addr, err)
+	}
+	svid, err := x509Source.GetX509SVID()
+	if err != nil {
+		_ = x509Source.Close()
+		_ = jwtSource.Close()
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// 只信任同一信任域的 schedulx
+	transport.TLSClientConfig = tlsconfig.MTLSClientConfig(x509Source, x509Source, tlsconfig.AuthorizeMemberOf(svid.ID.TrustDomain()))
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	go closeIdleConnectionsOnRotate(watchCtx, x509Source, transport)
+
+	client := newSchedulxClient(serverAddress, SPIFFERoundTripper{jwtSource: jwtSource, r: transport}, opts...)
+	client.closeFn = func() error {
+		stopWatch()
+		return errors.Join(x509Source.Close(), jwtSource.Close())
+	}
+	return client, nil
+}
+
+//closeIdleConnectionsOnRotate Workload API 推送新的 SVID 后关闭空闲连接，避免继续使用旧证书建立的连接
+func closeIdleConnectionsOnRotate(ctx context.Context, x509Source *workloadapi.X509Source, transport *http.Transport) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-x509Source.Updated():
+			logger.GetLogger().Info("spiffe x509 svid rotated, closing idle schedulx connections")
+			transport.CloseIdleConnections()
+		}
+	}
+}
+
+// InitializeSchedulxClientWithSPIFFE 使用 SPIFFE 工作负载身份初始化 schedulx 客户端
+func InitializeSchedulxClientWithSPIFFE(schedulxServerAddress, socketPath string, opts ...ClientOption) error {
+	client, err := NewSchedulxClientWithSPIFFE(schedulxServerAddress, socketPath, opts...)
+	if err != nil {
+		return err
+	}
+	if schedulxClient != nil {
+		_ = schedulxClient.Close()
+	}
+	schedulxClient = client
+	return nil
+}
@@ -0,0 +1,23 @@
+package clients_test
+
+import (
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("NewSchedulxClientWithSPIFFE", func() {
+	ginkgo.It("requires a socket path", func() {
+		_, err := clients.NewSchedulxClientWithSPIFFE("http://10.16.23.96:9090", "")
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("rejects addresses other than unix and tcp", func() {
+		_, err := clients.NewSchedulxClientWithSPIFFE("http://10.16.23.96:9090", "http://127.0.0.1:8081")
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("create x509 source")))
+	})
+
+	ginkgo.It("closes clients without SPIFFE", func() {
+		gomega.Expect(clients.NewSchedulxClient("http://10.16.23.96:9090").Close()).To(gomega.BeNil())
+	})
+})
@@ -0,0 +1,134 @@
+//Package testutil 模拟 bridgx 登录和 schedulx 接口，扩缩容只修改内存中的实例数，用于不依赖真实 schedulx 的基准和集成测试
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+)
+
+//SchedulxServer 模拟的 bridgx/schedulx 服务，bridgx 和 schedulx 客户端都指向 URL 即可使用
+type SchedulxServer struct {
+	*httptest.Server
+	initialCount int
+	scalingCalls atomic.Int64
+
+	lock   sync.Mutex
+	counts map[clients.ServiceClusterPair]int
+}
+
+//NewSchedulxServer 启动模拟服务，没有扩缩容过的服务集群有 initialCount 个实例且都不在调度中
+func NewSchedulxServer(initialCount int) *SchedulxServer {
+	server := &SchedulxServer{initialCount: initialCount, counts: make(map[clients.ServiceClusterPair]int)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/user/login", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{"code": http.StatusOK, "msg": "success", "data": "token"})
+	})
+	mux.HandleFunc("/api/v1/schedulx/service/scheduling", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, clients.GetServiceScheduleResponse{Code: http.StatusOK, Data: clients.ServiceSchedule{
+			ServiceName:        r.URL.Query().Get("service_name"),
+			ServiceClusterName: r.URL.Query().Get("service_cluster_name"),
+		}})
+	})
+	mux.HandleFunc("/api/v1/schedulx/service/scheduling/batch", server.batchSchedule)
+	mux.HandleFunc("/api/v1/schedulx/instance/count", server.instanceCount)
+	mux.HandleFunc("/api/v1/schedulx/instance/list", server.instanceList)
+	mux.HandleFunc("/api/v1/schedulx/service/max_instances", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, clients.GetServiceMaxInstancesResponse{Code: http.StatusOK})
+	})
+	mux.HandleFunc("/api/v1/schedulx/service/expand", func(w http.ResponseWriter, r *http.Request) {
+		server.scale(w, r, 1)
+	})
+	mux.HandleFunc("/api/v1/schedulx/service/shrink", func(w http.ResponseWriter, r *http.Request) {
+		server.scale(w, r, -1)
+	})
+	server.Server = httptest.NewServer(mux)
+	return server
+}
+
+//InstanceCount 服务集群当前的实例数
+func (server *SchedulxServer) InstanceCount(serviceName, clusterName string) int {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	return server.count(clients.ServiceClusterPair{ServiceName: serviceName, ClusterName: clusterName})
+}
+
+//ScalingCalls 收到的扩容和缩容请求数
+func (server *SchedulxServer) ScalingCalls() int64 {
+	return server.scalingCalls.Load()
+}
+
+//count 调用方需要持有 lock
+func (server *SchedulxServer) count(pair clients.ServiceClusterPair) int {
+	if count, ok := server.counts[pair]; ok {
+		return count
+	}
+	return server.initialCount
+}
+
+func (server *SchedulxServer) batchSchedule(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ServiceClusterList []clients.ServiceClusterPair `json:"service_cluster_list"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	response := clients.BatchServiceScheduleResponse{Code: http.StatusOK}
+	for _, pair := range request.ServiceClusterList {
+		response.Data.ServiceClusterList = append(response.Data.ServiceClusterList, &clients.ServiceSchedule{ServiceName: pair.ServiceName, ServiceClusterName: pair.ClusterName})
+	}
+	writeJSON(w, response)
+}
+
+func (server *SchedulxServer) instanceCount(w http.ResponseWriter, r *http.Request) {
+	clusterName := r.URL.Query().Get("service_cluster_name")
+	count := server.InstanceCount(r.URL.Query().Get("service_name"), clusterName)
+	writeJSON(w, clients.GetServiceClusterInstanceResponse{Code: http.StatusOK, Data: clients.ServiceClusterInstanceCountList{
+		ServiceClusterList: []*clients.ServiceClusterInstanceCount{{ServiceClusterName: clusterName, InstanceCount: count, InstanceList: instances(count)}},
+	}})
+}
+
+func (server *SchedulxServer) instanceList(w http.ResponseWriter, r *http.Request) {
+	count := server.InstanceCount(r.URL.Query().Get("service_name"), r.URL.Query().Get("service_cluster_name"))
+	writeJSON(w, clients.GetServiceInstanceListResponse{Code: http.StatusOK, Data: clients.ServiceInstanceList{InstanceList: instances(count)}})
+}
+
+//scale sign 为1时扩容，为-1时缩容，实例数不小于0
+func (server *SchedulxServer) scale(w http.ResponseWriter, r *http.Request, sign int) {
+	count, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil || count <= 0 {
+		writeJSON(w, clients.ExpandAndShrinkResponse{Code: http.StatusBadRequest, Msg: "invalid count"})
+		return
+	}
+	server.scalingCalls.Add(1)
+	pair := clients.ServiceClusterPair{ServiceName: r.URL.Query().Get("service_name"), ClusterName: r.URL.Query().Get("service_cluster")}
+	server.lock.Lock()
+	server.counts[pair] = max(server.count(pair)+sign*count, 0)
+	server.lock.Unlock()
+	writeJSON(w, clients.ExpandAndShrinkResponse{Code: http.StatusOK, Msg: "success"})
+}
+
+//instances count 个健康的实例
+func instances(count int) []*clients.ServiceInstance {
+	list := make([]*clients.ServiceInstance, 0, count)
+	for i := 0; i < count; i++ {
+		list = append(list, &clients.ServiceInstance{
+			InstanceId: fmt.Sprintf("i-%d", i),
+			IpInner:    fmt.Sprintf("10.0.%d.%d", i/256, i%256),
+			Status:     "healthy",
+		})
+	}
+	return list
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,62 @@
+package clients_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("SchedulxTimeout", func() {
+	var server *httptest.Server
+	var stopSchedulx func()
+
+	ginkgo.BeforeEach(func() {
+		server, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/service/expand") || r.URL.Path == "/api/v1/schedulx/instance/service" {
+				time.Sleep(100 * time.Millisecond)
+			}
+			_, _ = w.Write([]byte(`{"code":200,"msg":"success"}`))
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		stopSchedulx()
+	})
+
+	ginkgo.It("applies the expand timeout only to expand requests", func() {
+		clients.InitializeSchedulxClient(server.URL, clients.WithExpandTimeout(20*time.Millisecond))
+		err := clients.ExpandService("gf.cudgx.pi", "default", 1, "")
+		gomega.Expect(err).NotTo(gomega.BeNil())
+		gomega.Expect(err.Error()).To(gomega.ContainSubstring("Timeout"))
+		gomega.Expect(clients.ShrinkService("gf.cudgx.pi", "default", 1, "", clients.ShrinkOptions{})).To(gomega.BeNil())
+	})
+
+	ginkgo.It("keeps the default timeout when not configured", func() {
+		client := clients.NewSchedulxClient(server.URL, clients.WithExpandTimeout(0), clients.WithShrinkTimeout(2*time.Second))
+		gomega.Expect(client.ExpandClient.Timeout).To(gomega.Equal(5 * time.Second))
+		gomega.Expect(client.ShrinkClient.Timeout).To(gomega.Equal(2 * time.Second))
+		gomega.Expect(client.HttpClient.Timeout).To(gomega.Equal(5 * time.Second))
+	})
+
+	ginkgo.It("applies endpoint timeouts by the longest matching path prefix", func() {
+		clients.InitializeSchedulxClient(server.URL, clients.WithEndpointTimeouts(map[string]time.Duration{
+			"/api/v1/schedulx":                  time.Second,
+			"/api/v1/schedulx/instance/service": 20 * time.Millisecond,
+		}))
+		_, err := clients.GetServiceByIp(context.Background(), "10.9.9.9")
+		gomega.Expect(err).NotTo(gomega.BeNil())
+		gomega.Expect(err.Error()).To(gomega.ContainSubstring("deadline exceeded"))
+		gomega.Expect(clients.ExpandService("gf.cudgx.pi", "default", 1, "")).To(gomega.BeNil())
+	})
+
+	ginkgo.It("ignores empty endpoint timeouts", func() {
+		client := clients.NewSchedulxClient(server.URL, clients.WithEndpointTimeouts(map[string]time.Duration{"": time.Second, "/api": 0}))
+		gomega.Expect(client.EndpointTimeouts).To(gomega.BeEmpty())
+	})
+})
@@ -0,0 +1,68 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+//topologyCacheTTL 服务上下游依赖关系的缓存时间，依赖关系很少变化
+const topologyCacheTTL = 5 * time.Minute
+
+var topologyCache = newLRUCache(scheduleCacheSize)
+
+type GetServiceTopologyResponse struct {
+	Code int64           `json:"code"`
+	Msg  string          `json:"msg"`
+	Data ServiceTopology `json:"data"`
+}
+
+//ServiceTopology 服务的上下游依赖关系，UpstreamServices 调用本服务，DownstreamServices 被本服务调用
+type ServiceTopology struct {
+	ServiceName        string   `json:"service_name"`
+	UpstreamServices   []string `json:"upstream_services"`
+	DownstreamServices []string `json:"downstream_services"`
+}
+
+type topologyCacheEntry struct {
+	topology ServiceTopology
+	expireAt time.Time
+}
+
+// GetServiceTopology 查询服务的上下游服务，结果缓存5分钟，ctx 中的 request id 会随请求发送
+func GetServiceTopology(ctx context.Context, serviceName string) (ServiceTopology, error) {
+	if serviceName == "" {
+		return ServiceTopology{}, fmt.Errorf("服务名称不能为空")
+	}
+	if value, ok := topologyCache.Get(serviceName); ok {
+		if entry := value.(topologyCacheEntry); time.Now().Before(entry.expireAt) {
+			return entry.topology, nil
+		}
+		topologyCache.Remove(serviceName)
+	}
+	resp, err := schedulxGet(ctx, fmt.Sprintf("%s/api/v1/schedulx/service/topology?service_name=%s", schedulxClient.ServerAddress, serviceName))
+	if err != nil {
+		return ServiceTopology{}, err
+	}
+	defer resp.Body.Close()
+	respData, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return ServiceTopology{}, err
+	}
+	var response GetServiceTopologyResponse
+	err = json.Unmarshal(respData, &response)
+	if err != nil {
+		return ServiceTopology{}, err
+	}
+	if response.Code != http.StatusOK {
+		err = fmt.Errorf("http code:%v | msg:%v", response.Code, response.Msg)
+		return ServiceTopology{}, err
+	}
+	topology := response.Data
+	topology.ServiceName = serviceName
+	topologyCache.Add(serviceName, topologyCacheEntry{topology: topology, expireAt: time.Now().Add(topologyCacheTTL)})
+	return topology, nil
+}
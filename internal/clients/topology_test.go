@@ -0,0 +1,61 @@
+package clients_test
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("GetServiceTopology", func() {
+	var stopSchedulx func()
+	var queries []string
+
+	ginkgo.BeforeEach(func() {
+		queries = nil
+		_, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v1/schedulx/service/topology":
+				queries = append(queries, r.URL.RawQuery)
+				if r.URL.Query().Get("service_name") == "gf.cudgx.missing" {
+					_, _ = w.Write([]byte(`{"code":404,"msg":"service not found"}`))
+					return
+				}
+				_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":{` +
+					`"upstream_services":["gf.cudgx.gateway"],"downstream_services":["gf.cudgx.db","gf.cudgx.cache"]}}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		stopSchedulx()
+	})
+
+	ginkgo.It("returns and caches the upstream and downstream services", func() {
+		for i := 0; i < 2; i++ {
+			topology, err := clients.GetServiceTopology(context.Background(), "gf.cudgx.topology")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(topology).To(gomega.Equal(clients.ServiceTopology{
+				ServiceName:        "gf.cudgx.topology",
+				UpstreamServices:   []string{"gf.cudgx.gateway"},
+				DownstreamServices: []string{"gf.cudgx.db", "gf.cudgx.cache"},
+			}))
+		}
+		gomega.Expect(queries).To(gomega.Equal([]string{"service_name=gf.cudgx.topology"}))
+	})
+
+	ginkgo.It("does not cache failed queries", func() {
+		for i := 0; i < 2; i++ {
+			_, err := clients.GetServiceTopology(context.Background(), "gf.cudgx.missing")
+			gomega.Expect(err).To(gomega.MatchError("http code:404 | msg:service not found"))
+		}
+		gomega.Expect(queries).To(gomega.HaveLen(2))
+
+		_, err := clients.GetServiceTopology(context.Background(), "")
+		gomega.Expect(err).NotTo(gomega.BeNil())
+	})
+})
@@ -0,0 +1,84 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+//trafficSplitCacheTTL 蓝绿部署流量比例的缓存时间
+const trafficSplitCacheTTL = 30 * time.Second
+
+var trafficSplitCache = newLRUCache(scheduleCacheSize)
+
+type GetTrafficSplitResponse struct {
+	Code int64            `json:"code"`
+	Msg  string           `json:"msg"`
+	Data TrafficSplitData `json:"data"`
+}
+
+type TrafficSplitData struct {
+	ServiceName string `json:"service_name"`
+	//TrafficSplit 颜色 -> 流量百分比，例如 {"blue":70,"green":30}
+	TrafficSplit map[string]float64 `json:"traffic_split"`
+}
+
+type trafficSplitCacheEntry struct {
+	split    map[string]float64
+	expireAt time.Time
+}
+
+// GetTrafficSplit 查询蓝绿部署的服务各颜色的流量百分比，结果缓存30秒，ctx 中的 request id 会随请求发送
+func GetTrafficSplit(ctx context.Context, serviceName string) (map[string]float64, error) {
+	if serviceName == "" {
+		return nil, fmt.Errorf("服务名称不能为空")
+	}
+	if value, ok := trafficSplitCache.Get(serviceName); ok {
+		if entry := value.(trafficSplitCacheEntry); time.Now().Before(entry.expireAt) {
+			return copyTrafficSplit(entry.split), nil
+		}
+		trafficSplitCache.Remove(serviceName)
+	}
+	resp, err := schedulxGet(ctx, fmt.Sprintf("%s/api/v1/schedulx/service/traffic-split?service_name=%s", schedulxClient.ServerAddress, serviceName))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respData, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var response GetTrafficSplitResponse
+	err = json.Unmarshal(respData, &response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Code != http.StatusOK {
+		err = fmt.Errorf("http code:%v | msg:%v", response.Code, response.Msg)
+		return nil, err
+	}
+	var total float64
+	for color, percentage := range response.Data.TrafficSplit {
+		if percentage < 0 || percentage > 100 {
+			return nil, fmt.Errorf("服务 %s 颜色 %s 的流量比例 %.2f 不合法", serviceName, color, percentage)
+		}
+		total += percentage
+	}
+	if total <= 0 {
+		return nil, fmt.Errorf("服务 %s 没有流量比例", serviceName)
+	}
+	trafficSplitCache.Add(serviceName, trafficSplitCacheEntry{split: response.Data.TrafficSplit, expireAt: time.Now().Add(trafficSplitCacheTTL)})
+	return copyTrafficSplit(response.Data.TrafficSplit), nil
+}
+
+//copyTrafficSplit 返回缓存的副本，避免调用方修改缓存
+func copyTrafficSplit(split map[string]float64) map[string]float64 {
+	copied := make(map[string]float64, len(split))
+	for color, percentage := range split {
+		copied[color] = percentage
+	}
+	return copied
+}
@@ -0,0 +1,56 @@
+package clients_test
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("GetTrafficSplit", func() {
+	var stopSchedulx func()
+	var queries []string
+
+	ginkgo.BeforeEach(func() {
+		queries = nil
+		_, stopSchedulx = startFakeSchedulx(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v1/schedulx/service/traffic-split":
+				queries = append(queries, r.URL.RawQuery)
+				switch r.URL.Query().Get("service_name") {
+				case "gf.cudgx.nosplit":
+					_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":{"traffic_split":{}}}`))
+				case "gf.cudgx.badsplit":
+					_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":{"traffic_split":{"blue":120}}}`))
+				default:
+					_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":{"traffic_split":{"blue":70,"green":30}}}`))
+				}
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		stopSchedulx()
+	})
+
+	ginkgo.It("returns and caches the traffic percentage of each color", func() {
+		for i := 0; i < 2; i++ {
+			split, err := clients.GetTrafficSplit(context.Background(), "gf.cudgx.split")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(split).To(gomega.Equal(map[string]float64{"blue": 70, "green": 30}))
+			split["blue"] = 0
+		}
+		gomega.Expect(queries).To(gomega.Equal([]string{"service_name=gf.cudgx.split"}))
+	})
+
+	ginkgo.It("rejects empty or out of range splits", func() {
+		_, err := clients.GetTrafficSplit(context.Background(), "gf.cudgx.nosplit")
+		gomega.Expect(err).NotTo(gomega.BeNil())
+		_, err = clients.GetTrafficSplit(context.Background(), "gf.cudgx.badsplit")
+		gomega.Expect(err).NotTo(gomega.BeNil())
+	})
+})
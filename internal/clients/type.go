@@ -1,5 +1,7 @@
 package clients
 
+import "time"
+
 type ExpandAndShrinkResponse struct {
 	Code int64  `json:"code"`
 	Msg  string `json:"msg"`
@@ -25,6 +27,26 @@ type ServiceClusterInstanceCount struct {
 	ServiceClusterId   int64  `json:"service_cluster_id"`
 	ServiceClusterName string `json:"service_cluster_name"`
 	InstanceCount      int    `json:"instance_count"`
+	//InstanceList 运行中的实例，schedulx 未返回时为空
+	InstanceList []*ServiceInstance `json:"instance_list"`
+}
+
+type ServiceInstance struct {
+	InstanceId string `json:"instance_id"`
+	IpInner    string `json:"ip_inner"`
+	//Status 实例状态，只有 /api/v1/schedulx/instance/list 返回
+	Status string `json:"status"`
+	//CreateAt 实例创建时间的 unix 秒数，schedulx 未返回时为0
+	CreateAt int64 `json:"create_at"`
+}
+
+//InstanceInfo 运行中实例的信息
+type InstanceInfo struct {
+	IP         string
+	InstanceID string
+	Status     string
+	//CreatedAt 实例创建时间，schedulx 未返回时为零值
+	CreatedAt time.Time
 }
 
 type ServiceSchedule struct {
@@ -1,18 +1,81 @@
 package clients
 
-import "net/http"
+import (
+	"net/http"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+)
 
 var bridgxClient, schedulxClient *Client
 
 type Client struct {
 	ServerAddress string
 	HttpClient    *http.Client
+	//ExpandClient/ShrinkClient 扩容和缩容请求使用的客户端，与 HttpClient 共用 Transport，只有超时时间不同
+	ExpandClient *http.Client
+	ShrinkClient *http.Client
+	//EndpointTimeouts 请求路径前缀 -> 超时时间，参见 WithEndpointTimeouts
+	EndpointTimeouts map[string]time.Duration
+	//closeFn 释放客户端持有的资源，例如 SPIFFE Workload API 连接
+	closeFn func() error
+}
+
+//Close 释放客户端持有的资源
+func (c *Client) Close() error {
+	if c.closeFn == nil {
+		return nil
+	}
+	return c.closeFn()
+}
+
+//ClientOption schedulx 客户端的可选配置
+type ClientOption func(c *Client)
+
+//WithExpandTimeout 扩容请求的超时时间，不大于0时使用默认的5秒
+func WithExpandTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		if timeout > 0 {
+			c.ExpandClient.Timeout = timeout
+		}
+	}
+}
+
+//WithShrinkTimeout 缩容请求的超时时间，不大于0时使用默认的5秒
+func WithShrinkTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		if timeout > 0 {
+			c.ShrinkClient.Timeout = timeout
+		}
+	}
+}
+
+//newSchedulxClient 使用 transport 新建 schedulx 客户端，扩容、缩容和其它请求默认都是5秒超时
+func newSchedulxClient(serverAddress string, transport http.RoundTripper, opts ...ClientOption) *Client {
+	c := &Client{
+		ServerAddress: serverAddress,
+		HttpClient:    &http.Client{Timeout: consts.DefaultSchedulxTimeoutMs * time.Millisecond, Transport: transport},
+		ExpandClient:  &http.Client{Timeout: consts.DefaultSchedulxTimeoutMs * time.Millisecond, Transport: transport},
+		ShrinkClient:  &http.Client{Timeout: consts.DefaultSchedulxTimeoutMs * time.Millisecond, Transport: transport},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if len(c.EndpointTimeouts) > 0 {
+		transport = endpointTimeoutRoundTripper{r: transport, timeouts: c.EndpointTimeouts}
+		c.HttpClient.Transport = transport
+		c.ExpandClient.Transport = transport
+		c.ShrinkClient.Transport = transport
+	}
+	return c
 }
 
 func InitializeBridgxClient(bridgxServerAddress string) {
 	bridgxClient = NewBridgxClient(bridgxServerAddress)
 }
 
-func InitializeSchedulxClient(schedulxServerAddress string) {
-	schedulxClient = NewSchedulxClient(schedulxServerAddress)
+func InitializeSchedulxClient(schedulxServerAddress string, opts ...ClientOption) {
+	schedulxClient = NewSchedulxClient(schedulxServerAddress, opts...)
+	// 缓存的实例数来自原来的 schedulx
+	instanceCountCache.Purge()
 }
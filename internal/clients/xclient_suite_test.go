@@ -1,6 +1,8 @@
 package clients_test
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/galaxy-future/cudgx/internal/clients"
@@ -17,3 +19,20 @@ var _ = ginkgo.BeforeSuite(func() {
 	clients.InitializeBridgxClient("http://bridgx-api.internal.galaxy-future.org")
 	clients.InitializeSchedulxClient("http://10.16.23.96:9090")
 })
+
+//startFakeSchedulx 启动模拟的 bridgx/schedulx 服务并把两个客户端都指向它，/user/login 总是登录成功，其他请求交给 handler；
+//返回的 stop 关闭服务并恢复之前的客户端
+func startFakeSchedulx(handler http.HandlerFunc) (server *httptest.Server, stop func()) {
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/user/login" {
+			_, _ = w.Write([]byte(`{"code":200,"msg":"success","data":"token"}`))
+			return
+		}
+		handler(w, r)
+	}))
+	restore := clients.UseXclientServer(server.URL)
+	return server, func() {
+		server.Close()
+		restore()
+	}
+}
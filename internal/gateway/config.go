@@ -32,6 +32,12 @@ type Config struct {
 type Xclient struct {
 	BridgxServerAddress   string `json:"bridgx_server_address"`
 	SchedulxServerAddress string `json:"schedulx_server_address"`
+	//ServiceByIpRPSLimit 按 ip 查询服务名时每秒最多请求 schedulx 的次数，0 表示不限制
+	ServiceByIpRPSLimit float64 `json:"service_by_ip_rps_limit"`
+	//ServiceByIpBurst 按 ip 查询服务名时允许的突发请求数
+	ServiceByIpBurst int `json:"service_by_ip_burst"`
+	//SPIFFESocketPath SPIFFE Workload API 的 socket 路径，不为空时使用工作负载身份访问 schedulx（mTLS + JWT-SVID），不再使用 bridgx 登录 token
+	SPIFFESocketPath string `json:"spiffe_socket_path"`
 }
 
 type MessageRouteConfig struct {
@@ -57,7 +63,14 @@ func GetGateway() *Gateway {
 func Init(configFilename string) (err error) {
 	g, err = NewFromConfigFile(configFilename)
 	clients.InitializeBridgxClient(g.entriesConfig.Xclient.BridgxServerAddress)
-	clients.InitializeSchedulxClient(g.entriesConfig.Xclient.SchedulxServerAddress)
+	if socketPath := g.entriesConfig.Xclient.SPIFFESocketPath; socketPath != "" {
+		if err = clients.InitializeSchedulxClientWithSPIFFE(g.entriesConfig.Xclient.SchedulxServerAddress, socketPath); err != nil {
+			return
+		}
+	} else {
+		clients.InitializeSchedulxClient(g.entriesConfig.Xclient.SchedulxServerAddress)
+	}
+	clients.SetServiceByIpRateLimit(g.entriesConfig.Xclient.ServiceByIpRPSLimit, g.entriesConfig.Xclient.ServiceByIpBurst)
 	return
 }
 
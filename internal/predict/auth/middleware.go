@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/galaxy-future/cudgx/common/logger"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type claimsContextKey struct{}
+
+//WithClaims 把 token 中的用户信息写入 ctx
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsContextKey{}, claims)
+}
+
+//ClaimsFromContext Middleware 写入请求 ctx 的用户信息，没有认证的请求返回 false
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(*Claims)
+	return claims, ok
+}
+
+//isWriteMethod 需要 RequiredGroups 授权的请求方法
+func isWriteMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch:
+		return true
+	}
+	return false
+}
+
+//exemptPaths 不需要认证的探针和监控接口，kubelet 和 Prometheus 不带 OIDC token
+var exemptPaths = map[string]bool{
+	"/healthz/live":  true,
+	"/healthz/ready": true,
+	"/metrics":       true,
+	"/ping":          true,
+}
+
+//exemptPathPrefix custom metrics API 由 kube-apiserver 聚合调用，不带 OIDC token
+const exemptPathPrefix = "/apis/custom.metrics.k8s.io/"
+
+//isExemptPath 请求路径是否不需要认证
+func isExemptPath(path string) bool {
+	return exemptPaths[path] || strings.HasPrefix(path, exemptPathPrefix)
+}
+
+//Middleware 校验 Authorization: Bearer <token>，写接口需要 RequiredGroups 之一，GET 接口只在配置 ReadScope 时需要 token；
+//认证通过后用户信息写入请求 ctx，并以 gin.AuthUserKey 作为修改记录的用户。探针、/metrics 和 custom metrics API 不认证
+func Middleware(config *config.OIDCConfig) (gin.HandlerFunc, error) {
+	if config == nil || config.OIDCIssuerURL == "" {
+		return nil, errors.New("oidc_issuer_url is required")
+	}
+	if config.OIDCClientID == "" {
+		return nil, errors.New("oidc_client_id is required")
+	}
+	verifier := NewVerifier(config.OIDCIssuerURL, config.OIDCClientID)
+	return middleware(verifier, config.RequiredGroups, config.ReadScope), nil
+}
+
+func middleware(verifier *Verifier, requiredGroups []string, readScope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isExemptPath(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		write := isWriteMethod(c.Request.Method)
+		if !write && readScope == "" {
+			c.Next()
+			return
+		}
+		rawToken := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+		if rawToken == "" || rawToken == c.GetHeader("Authorization") {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, response.MkFailedResponse("缺少 Authorization: Bearer token"))
+			return
+		}
+		claims, err := verifier.Verify(c.Request.Context(), rawToken)
+		if err != nil {
+			if !errors.Is(err, ErrTokenInvalid) {
+				logger.GetLogger().Error("verify oidc token failed", zap.Error(err))
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, response.MkFailedResponse("查询 OIDC 公钥失败"))
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, response.MkFailedResponse("token 校验失败"))
+			return
+		}
+		if write && len(requiredGroups) > 0 && !claims.InGroups(requiredGroups) {
+			c.AbortWithStatusJSON(http.StatusForbidden, response.MkFailedResponse("用户不在有权限修改的组中"))
+			return
+		}
+		if !write && !claims.HasScope(readScope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, response.MkFailedResponse("token 缺少 scope "+readScope))
+			return
+		}
+		c.Request = c.Request.WithContext(WithClaims(c.Request.Context(), claims))
+		c.Set(gin.AuthUserKey, claims.User())
+		c.Next()
+	}
+}
@@ -0,0 +1,156 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/predict/auth"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/gin-gonic/gin"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	"github.com/onsi/gomega"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+var _ = ginkgo.Describe("Middleware", func() {
+	var issuer *httptest.Server
+	var key *rsa.PrivateKey
+	var engine *gin.Engine
+	var user string
+
+	sign := func(claims map[string]interface{}) string {
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, (&jose.SignerOptions{}).WithHeader("kid", "test-key"))
+		gomega.Expect(err).To(gomega.BeNil())
+		token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+		gomega.Expect(err).To(gomega.BeNil())
+		return token
+	}
+	validClaims := func(groups ...string) map[string]interface{} {
+		return map[string]interface{}{
+			"iss":    issuer.URL,
+			"aud":    "cudgx",
+			"sub":    "user-1",
+			"email":  "ops@galaxy-future.com",
+			"groups": groups,
+			"scope":  "openid cudgx.read",
+			"exp":    time.Now().Add(time.Hour).Unix(),
+		}
+	}
+	requestPath := func(method, path, token string) int {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w.Code
+	}
+	request := func(method, token string) int {
+		return requestPath(method, "/rules", token)
+	}
+	newEngine := func(oidcConfig *config.OIDCConfig) {
+		middleware, err := auth.Middleware(oidcConfig)
+		gomega.Expect(err).To(gomega.BeNil())
+		engine = gin.New()
+		engine.Use(middleware)
+		handle := func(c *gin.Context) {
+			user = c.GetString(gin.AuthUserKey)
+			if claims, ok := auth.ClaimsFromContext(c.Request.Context()); ok {
+				gomega.Expect(claims.Subject).To(gomega.Equal("user-1"))
+			}
+			c.Status(http.StatusOK)
+		}
+		engine.GET("/rules", handle)
+		engine.POST("/rules", handle)
+		engine.GET("/ping", handle)
+		engine.GET("/metrics", handle)
+		engine.GET("/healthz/live", handle)
+		engine.GET("/healthz/ready", handle)
+		engine.GET("/healthz/db", handle)
+		engine.GET("/apis/custom.metrics.k8s.io/v1beta1", handle)
+		engine.GET("/apis/custom.metrics.k8s.io/v1beta1/namespaces/:namespace/pods/:pod/:metric", handle)
+	}
+
+	ginkgo.BeforeEach(func() {
+		var err error
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+		gomega.Expect(err).To(gomega.BeNil())
+		user = ""
+		mux := http.NewServeMux()
+		issuer = httptest.NewServer(mux)
+		mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode(map[string]string{"issuer": issuer.URL, "jwks_uri": issuer.URL + "/keys"})
+		})
+		mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "test-key", Algorithm: "RS256", Use: "sig"}}})
+		})
+		newEngine(&config.OIDCConfig{OIDCIssuerURL: issuer.URL, OIDCClientID: "cudgx", RequiredGroups: []string{"cudgx-admin"}})
+	})
+
+	ginkgo.AfterEach(func() {
+		issuer.Close()
+	})
+
+	ginkgo.It("leaves GET endpoints unauthenticated without read_scope", func() {
+		gomega.Expect(request(http.MethodGet, "")).To(gomega.Equal(http.StatusOK))
+	})
+
+	ginkgo.It("requires a valid token for write endpoints", func() {
+		gomega.Expect(request(http.MethodPost, "")).To(gomega.Equal(http.StatusUnauthorized))
+
+		expired := validClaims("cudgx-admin")
+		expired["exp"] = time.Now().Add(-time.Hour).Unix()
+		gomega.Expect(request(http.MethodPost, sign(expired))).To(gomega.Equal(http.StatusUnauthorized))
+
+		otherAudience := validClaims("cudgx-admin")
+		otherAudience["aud"] = "other"
+		gomega.Expect(request(http.MethodPost, sign(otherAudience))).To(gomega.Equal(http.StatusUnauthorized))
+	})
+
+	ginkgo.It("authorizes write endpoints by group and records the user", func() {
+		gomega.Expect(request(http.MethodPost, sign(validClaims("viewer")))).To(gomega.Equal(http.StatusForbidden))
+		gomega.Expect(request(http.MethodPost, sign(validClaims("viewer", "cudgx-admin")))).To(gomega.Equal(http.StatusOK))
+		gomega.Expect(user).To(gomega.Equal("ops@galaxy-future.com"))
+	})
+
+	ginkgo.It("requires read_scope for GET endpoints when configured", func() {
+		newEngine(&config.OIDCConfig{OIDCIssuerURL: issuer.URL, OIDCClientID: "cudgx", ReadScope: "cudgx.read"})
+		gomega.Expect(request(http.MethodGet, "")).To(gomega.Equal(http.StatusUnauthorized))
+		gomega.Expect(request(http.MethodGet, sign(validClaims()))).To(gomega.Equal(http.StatusOK))
+
+		noScope := validClaims()
+		delete(noScope, "scope")
+		gomega.Expect(request(http.MethodGet, sign(noScope))).To(gomega.Equal(http.StatusForbidden))
+	})
+
+	ginkgo.It("rejects an incomplete config", func() {
+		_, err := auth.Middleware(&config.OIDCConfig{OIDCIssuerURL: issuer.URL})
+		gomega.Expect(err).NotTo(gomega.BeNil())
+	})
+
+	table.DescribeTable("leaves probes, /metrics and the custom metrics API unauthenticated when read_scope is set",
+		func(path string) {
+			newEngine(&config.OIDCConfig{OIDCIssuerURL: issuer.URL, OIDCClientID: "cudgx", ReadScope: "cudgx.read"})
+			gomega.Expect(requestPath(http.MethodGet, path, "")).To(gomega.Equal(http.StatusOK))
+			gomega.Expect(user).To(gomega.BeEmpty())
+		},
+		table.Entry("liveness probe", "/healthz/live"),
+		table.Entry("readiness probe", "/healthz/ready"),
+		table.Entry("prometheus metrics", "/metrics"),
+		table.Entry("ping", "/ping"),
+		table.Entry("custom metrics discovery", "/apis/custom.metrics.k8s.io/v1beta1"),
+		table.Entry("custom metrics value", "/apis/custom.metrics.k8s.io/v1beta1/namespaces/default/pods/web-0/qps"),
+	)
+
+	ginkgo.It("still authenticates other paths under the same prefixes", func() {
+		newEngine(&config.OIDCConfig{OIDCIssuerURL: issuer.URL, OIDCClientID: "cudgx", ReadScope: "cudgx.read"})
+		gomega.Expect(requestPath(http.MethodGet, "/healthz/db", "")).To(gomega.Equal(http.StatusUnauthorized))
+		gomega.Expect(requestPath(http.MethodGet, "/rules", "")).To(gomega.Equal(http.StatusUnauthorized))
+	})
+})
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+const (
+	//discoveryPath OIDC 提供方的配置地址，相对于 issuer
+	discoveryPath = "/.well-known/openid-configuration"
+	//keysRefreshInterval token 的 kid 不在缓存的公钥中时，最多每隔这么久重新拉取一次 jwks，避免伪造的 kid 打满提供方
+	keysRefreshInterval = time.Minute
+	//clockSkew 校验 exp/nbf/iat 时允许的时钟偏差
+	clockSkew = 30 * time.Second
+)
+
+//ErrTokenInvalid token 签名、issuer、audience 或有效期校验失败
+var ErrTokenInvalid = errors.New("token is invalid")
+
+//Claims 从 token 中取出的用户信息
+type Claims struct {
+	Subject string   `json:"sub"`
+	Email   string   `json:"email"`
+	Groups  []string `json:"groups"`
+	//Scopes token 的 scope，按空格拆分
+	Scopes []string `json:"-"`
+}
+
+//User 修改记录中的用户，优先使用 email
+func (claims *Claims) User() string {
+	if claims.Email != "" {
+		return claims.Email
+	}
+	return claims.Subject
+}
+
+//InGroups claims 的 groups 是否包含 groups 中的任意一个
+func (claims *Claims) InGroups(groups []string) bool {
+	for _, group := range groups {
+		for _, claimed := range claims.Groups {
+			if claimed == group {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//HasScope claims 的 scope 是否包含 scope
+func (claims *Claims) HasScope(scope string) bool {
+	for _, claimed := range claims.Scopes {
+		if claimed == scope {
+			return true
+		}
+	}
+	return false
+}
+
+//Verifier 用 OIDC 提供方的 jwks 校验 token，公钥在第一次校验时拉取并缓存
+type Verifier struct {
+	issuer     string
+	clientID   string
+	httpClient *http.Client
+	now        func() time.Time
+
+	lock      sync.Mutex
+	jwksURI   string
+	keys      *jose.JSONWebKeySet
+	fetchedAt time.Time
+}
+
+//NewVerifier 校验 issuer 为 issuerURL、aud 包含 clientID 的 token
+func NewVerifier(issuerURL, clientID string) *Verifier {
+	return &Verifier{
+		issuer:     issuerURL,
+		clientID:   clientID,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		now:        time.Now,
+	}
+}
+
+//Verify 校验 rawToken 的签名、issuer、audience 和有效期，返回 token 中的用户信息
+func (verifier *Verifier) Verify(ctx context.Context, rawToken string) (*Claims, error) {
+	token, err := jwt.ParseSigned(rawToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w , %v", ErrTokenInvalid, err)
+	}
+	if len(token.Headers) != 1 || token.Headers[0].KeyID == "" {
+		return nil, fmt.Errorf("%w , token has no kid", ErrTokenInvalid)
+	}
+	key, err := verifier.key(ctx, token.Headers[0].KeyID)
+	if err != nil {
+		return nil, err
+	}
+	var standard jwt.Claims
+	var claims Claims
+	var extra struct {
+		Scope string `json:"scope"`
+	}
+	if err := token.Claims(key, &standard, &claims, &extra); err != nil {
+		return nil, fmt.Errorf("%w , %v", ErrTokenInvalid, err)
+	}
+	expected := jwt.Expected{Issuer: verifier.issuer, Audience: jwt.Audience{verifier.clientID}, Time: verifier.now()}
+	if err := standard.ValidateWithLeeway(expected, clockSkew); err != nil {
+		return nil, fmt.Errorf("%w , %v", ErrTokenInvalid, err)
+	}
+	claims.Scopes = strings.Fields(extra.Scope)
+	return &claims, nil
+}
+
+//key kid 对应的公钥，缓存中没有时重新拉取 jwks，提供方轮换公钥后不需要重启
+func (verifier *Verifier) key(ctx context.Context, kid string) (*jose.JSONWebKey, error) {
+	verifier.lock.Lock()
+	defer verifier.lock.Unlock()
+	if verifier.keys != nil {
+		if keys := verifier.keys.Key(kid); len(keys) > 0 {
+			return &keys[0], nil
+		}
+		if verifier.now().Sub(verifier.fetchedAt) < keysRefreshInterval {
+			return nil, fmt.Errorf("%w , unknown kid %s", ErrTokenInvalid, kid)
+		}
+	}
+	keys, err := verifier.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	verifier.keys = keys
+	verifier.fetchedAt = verifier.now()
+	if keys := verifier.keys.Key(kid); len(keys) > 0 {
+		return &keys[0], nil
+	}
+	return nil, fmt.Errorf("%w , unknown kid %s", ErrTokenInvalid, kid)
+}
+
+//fetchKeys 拉取 jwks，jwks_uri 只从提供方配置中获取一次
+func (verifier *Verifier) fetchKeys(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	if verifier.jwksURI == "" {
+		var discovery struct {
+			Issuer  string `json:"issuer"`
+			JwksURI string `json:"jwks_uri"`
+		}
+		if err := verifier.getJSON(ctx, strings.TrimSuffix(verifier.issuer, "/")+discoveryPath, &discovery); err != nil {
+			return nil, fmt.Errorf("query oidc configuration failed , %w", err)
+		}
+		if discovery.Issuer != verifier.issuer {
+			return nil, fmt.Errorf("oidc configuration issuer %s does not match %s", discovery.Issuer, verifier.issuer)
+		}
+		if discovery.JwksURI == "" {
+			return nil, errors.New("oidc configuration has no jwks_uri")
+		}
+		verifier.jwksURI = discovery.JwksURI
+	}
+	var keys jose.JSONWebKeySet
+	if err := verifier.getJSON(ctx, verifier.jwksURI, &keys); err != nil {
+		return nil, fmt.Errorf("query oidc jwks failed , %w", err)
+	}
+	return &keys, nil
+}
+
+func (verifier *Verifier) getJSON(ctx context.Context, url string, dest interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := verifier.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(dest)
+}
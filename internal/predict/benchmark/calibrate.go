@@ -0,0 +1,114 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/logger"
+	"github.com/galaxy-future/cudgx/common/stats"
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"go.uber.org/zap"
+)
+
+//ErrNoCalibrationSamples 最近一小时没有可用于校准的指标
+var ErrNoCalibrationSamples = errors.New("no metric samples to calibrate benchmark qps")
+
+//CalibrationStore 保存校准得到的 benchmark_qps
+type CalibrationStore interface {
+	UpdatePredictRuleCalibration(ruleID int64, benchmarkQps int, calibratedAt time.Time) error
+}
+
+//InstanceCounter 查询服务集群当前的实例数
+type InstanceCounter func(ctx context.Context, serviceName, clusterName string) (int, error)
+
+//Calibrator 根据最近一小时的指标和当前实例数校准规则的 benchmark_qps
+type Calibrator struct {
+	backend        service.MetricBackend
+	countInstances InstanceCounter
+	store          CalibrationStore
+	now            func() time.Time
+	logger         *zap.Logger
+}
+
+//NewCalibrator 新建 Calibrator，backend 为空时使用 service.DefaultMetricBackend，countInstances 为空时查询 schedulx，store 为空时使用数据库
+func NewCalibrator(backend service.MetricBackend, countInstances InstanceCounter, store CalibrationStore) *Calibrator {
+	if backend == nil {
+		backend = service.DefaultMetricBackend
+	}
+	if countInstances == nil {
+		countInstances = clients.GetServiceInstanceCountWithContext
+	}
+	if store == nil {
+		store = modelStore{}
+	}
+	return &Calibrator{
+		backend:        backend,
+		countInstances: countInstances,
+		store:          store,
+		now:            time.Now,
+		logger:         logger.GetLogger(),
+	}
+}
+
+//CalibrateRule 以 benchmark=1 查询最近一小时的冗余度还原每个实例的平均指标，取中位数作为 benchmark_qps 并保存到规则，
+//即按当前负载冗余度为1校准；查询结果已经按实例数平均，当前实例数用于确认服务集群有运行中的实例
+func (calibrator *Calibrator) CalibrateRule(ctx context.Context, rule *model.PredictRule) (float64, error) {
+	if rule.MetricScope == consts.MetricScopeInstance {
+		return 0, fmt.Errorf("calibrate benchmark qps of metric_scope %s is not supported", rule.MetricScope)
+	}
+	now := calibrator.now()
+	series, err := calibrator.backend.QueryRedundancy(ctx, rule.ServiceName, rule.ClusterName, rule.QueryMetricName(), rule.MetricQueryMode, 1,
+		now.Add(-consts.BenchmarkCalibrateLookback).Unix(), now.Unix(), consts.DefaultTrimmedSecond)
+	if err != nil {
+		return 0, fmt.Errorf("query metric failed , %w", err)
+	}
+	var values []float64
+	for _, cluster := range series.Clusters {
+		if cluster.ClusterName != rule.ClusterName {
+			continue
+		}
+		for _, value := range cluster.Values {
+			// benchmark 为1时冗余度为 1/平均指标
+			if value > 0 && !math.IsInf(value, 0) {
+				values = append(values, 1/value)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return 0, ErrNoCalibrationSamples
+	}
+	instanceCount, err := calibrator.countInstances(ctx, rule.ServiceName, rule.ClusterName)
+	if err != nil {
+		return 0, fmt.Errorf("query service instance count failed , %w", err)
+	}
+	if instanceCount <= 0 {
+		return 0, fmt.Errorf("service %s cluster %s has no running instance", rule.ServiceName, rule.ClusterName)
+	}
+	sort.Float64s(values)
+	benchmark := stats.Median(values)
+	benchmarkQps := int(math.Round(benchmark))
+	if benchmarkQps <= 0 {
+		return benchmark, fmt.Errorf("calibrated benchmark qps %.2f rounds to 0", benchmark)
+	}
+	if err := calibrator.store.UpdatePredictRuleCalibration(rule.Id, benchmarkQps, now); err != nil {
+		return benchmark, err
+	}
+	calibrator.logger.Info("benchmark qps calibrated", zap.Int64("rule_id", rule.Id), zap.String("service", rule.ServiceName),
+		zap.String("cluster", rule.ClusterName), zap.Int("old_benchmark_qps", rule.BenchmarkQps), zap.Int("new_benchmark_qps", benchmarkQps),
+		zap.Int("instance_count", instanceCount), zap.Int("samples", len(values)))
+	rule.BenchmarkQps = benchmarkQps
+	rule.CalibratedAt = &now
+	return benchmark, nil
+}
+
+//CalibrateRule 使用默认的指标后端、schedulx 和数据库校准规则的 benchmark_qps
+func CalibrateRule(ctx context.Context, rule *model.PredictRule) (float64, error) {
+	return NewCalibrator(nil, nil, nil).CalibrateRule(ctx, rule)
+}
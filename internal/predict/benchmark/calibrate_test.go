@@ -0,0 +1,81 @@
+package benchmark_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/predict/benchmark"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//calibrationStore 在内存中记录校准结果
+type calibrationStore struct {
+	benchmarkQps map[int64]int
+	calibratedAt map[int64]time.Time
+}
+
+func (store *calibrationStore) UpdatePredictRuleCalibration(ruleID int64, benchmarkQps int, calibratedAt time.Time) error {
+	store.benchmarkQps[ruleID] = benchmarkQps
+	store.calibratedAt[ruleID] = calibratedAt
+	return nil
+}
+
+//redundancyValues 返回集群的冗余度，benchmark 为1时每个值为 1/实例平均指标
+func redundancyValues(values ...float64) service.MetricBackend {
+	return service.MetricBackendFunc(func(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+		gomega.Expect(benchmark).To(gomega.Equal(1.0))
+		gomega.Expect(end - begin).To(gomega.Equal(int64(3600)))
+		cluster := &service.ClusterRedundancySeries{ClusterName: clusterName}
+		for i, value := range values {
+			cluster.Timestamps = append(cluster.Timestamps, begin+int64(i))
+			cluster.Values = append(cluster.Values, value)
+		}
+		return &service.RedundancySeries{ServiceName: serviceName, MetricName: metricName, Clusters: []*service.ClusterRedundancySeries{cluster}}, nil
+	})
+}
+
+func instanceCount(count int) benchmark.InstanceCounter {
+	return func(ctx context.Context, serviceName, clusterName string) (int, error) {
+		return count, nil
+	}
+}
+
+var _ = ginkgo.Describe("Calibrator", func() {
+	var store *calibrationStore
+	var rule *model.PredictRule
+
+	ginkgo.BeforeEach(func() {
+		store = &calibrationStore{benchmarkQps: map[int64]int{}, calibratedAt: map[int64]time.Time{}}
+		rule = &model.PredictRule{Id: 1, ServiceName: "calibrate", ClusterName: "default", MetricName: "qps"}
+	})
+
+	ginkgo.It("uses the median metric of one instance", func() {
+		// 实例平均QPS 为 50、200、100
+		calibrator := benchmark.NewCalibrator(redundancyValues(0.02, 0.005, 0.01), instanceCount(4), store)
+		calibrated, err := calibrator.CalibrateRule(context.Background(), rule)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(calibrated).To(gomega.BeNumerically("~", 100, 1e-9))
+		gomega.Expect(store.benchmarkQps).To(gomega.Equal(map[int64]int{1: 100}))
+		gomega.Expect(rule.BenchmarkQps).To(gomega.Equal(100))
+		gomega.Expect(rule.CalibratedAt).NotTo(gomega.BeNil())
+		gomega.Expect(store.calibratedAt[1]).To(gomega.Equal(*rule.CalibratedAt))
+	})
+
+	ginkgo.It("fails without metric samples", func() {
+		calibrator := benchmark.NewCalibrator(redundancyValues(), instanceCount(4), store)
+		_, err := calibrator.CalibrateRule(context.Background(), rule)
+		gomega.Expect(errors.Is(err, benchmark.ErrNoCalibrationSamples)).To(gomega.BeTrue())
+		gomega.Expect(store.benchmarkQps).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("fails without running instances", func() {
+		calibrator := benchmark.NewCalibrator(redundancyValues(0.01), instanceCount(0), store)
+		_, err := calibrator.CalibrateRule(context.Background(), rule)
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("no running instance")))
+		gomega.Expect(rule.BenchmarkQps).To(gomega.Equal(0))
+	})
+})
@@ -0,0 +1,140 @@
+package benchmark
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/logger"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"go.uber.org/zap"
+)
+
+//Store 读取规则和扩缩容事件并更新 benchmark_qps
+type Store interface {
+	ListAllPredictRules() ([]*model.PredictRule, error)
+	ListScalingEvents(serviceName, clusterName string, begin, end int64) ([]*model.ScalingEvent, error)
+	UpdatePredictRuleBenchmarkQps(ruleID int64, benchmarkQps int) error
+}
+
+//modelStore 从数据库读取和更新
+type modelStore struct{}
+
+func (modelStore) ListAllPredictRules() ([]*model.PredictRule, error) {
+	return model.ListAllPredictRules()
+}
+
+func (modelStore) ListScalingEvents(serviceName, clusterName string, begin, end int64) ([]*model.ScalingEvent, error) {
+	return model.ListScalingEvents(serviceName, clusterName, begin, end)
+}
+
+func (modelStore) UpdatePredictRuleBenchmarkQps(ruleID int64, benchmarkQps int) error {
+	return model.UpdatePredictRuleBenchmarkQps(ruleID, benchmarkQps)
+}
+
+func (modelStore) UpdatePredictRuleCalibration(ruleID int64, benchmarkQps int, calibratedAt time.Time) error {
+	return model.UpdatePredictRuleCalibration(ruleID, benchmarkQps, calibratedAt)
+}
+
+//BenchmarkLearner 根据最近的扩缩容事件学习单实例的基准QPS，更新开启 benchmark_auto_learn 的规则
+type BenchmarkLearner struct {
+	interval time.Duration
+	store    Store
+	now      func() time.Time
+	logger   *zap.Logger
+}
+
+//NewBenchmarkLearner 新建 BenchmarkLearner，interval 不大于0时每周学习一次，store 为空时使用数据库
+func NewBenchmarkLearner(interval time.Duration, store Store) *BenchmarkLearner {
+	if interval <= 0 {
+		interval = consts.DefaultBenchmarkLearnInterval
+	}
+	if store == nil {
+		store = modelStore{}
+	}
+	return &BenchmarkLearner{
+		interval: interval,
+		store:    store,
+		now:      time.Now,
+		logger:   logger.GetLogger(),
+	}
+}
+
+//Start 每隔 interval 学习一次，直到 ctx 结束
+func (learner *BenchmarkLearner) Start(ctx context.Context) {
+	ticker := time.NewTicker(learner.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := learner.LearnAll(); err != nil {
+				learner.logger.Error("learn benchmark qps failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+//LearnAll 学习所有开启 benchmark_auto_learn 的规则，单条规则失败不影响其它规则
+func (learner *BenchmarkLearner) LearnAll() error {
+	rules, err := learner.store.ListAllPredictRules()
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if !rule.BenchmarkAutoLearn {
+			continue
+		}
+		if _, _, err := learner.Learn(rule); err != nil {
+			learner.logger.Error("learn benchmark qps of rule failed", zap.Int64("rule_id", rule.Id), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+//Learn 取最近7天冗余度在 [BenchmarkLearnMinRedundancy, BenchmarkLearnMaxRedundancy] 内的扩缩容事件，
+//以 QPS/实例数 的平均值作为学习到的基准QPS；与当前值相差超过 benchmark_auto_learn_threshold_pct 时更新规则。
+//事件中没有原始QPS，QPS 按 benchmark_qps*实例数/冗余度 还原；没有可用事件时 learned 为0
+func (learner *BenchmarkLearner) Learn(rule *model.PredictRule) (learned int, updated bool, err error) {
+	if rule.BenchmarkQps <= 0 {
+		return 0, false, nil
+	}
+	end := learner.now()
+	events, err := learner.store.ListScalingEvents(rule.ServiceName, rule.ClusterName, end.Add(-consts.DefaultBenchmarkLearnLookback).Unix(), end.Unix())
+	if err != nil {
+		return 0, false, err
+	}
+	var sum float64
+	var count int
+	for _, e := range events {
+		if e.RuleId != rule.Id || e.InstanceCount <= 0 ||
+			e.Redundancy < consts.BenchmarkLearnMinRedundancy || e.Redundancy > consts.BenchmarkLearnMaxRedundancy {
+			continue
+		}
+		qps := float64(rule.BenchmarkQps) * float64(e.InstanceCount) / e.Redundancy
+		sum += qps / float64(e.InstanceCount)
+		count++
+	}
+	if count == 0 {
+		return 0, false, nil
+	}
+	learned = int(math.Round(sum / float64(count)))
+
+	threshold := rule.BenchmarkAutoLearnThresholdPct
+	if threshold <= 0 {
+		threshold = consts.DefaultBenchmarkAutoLearnThresholdPct
+	}
+	diffPct := math.Abs(float64(learned-rule.BenchmarkQps)) / float64(rule.BenchmarkQps) * 100
+	if diffPct <= threshold {
+		return learned, false, nil
+	}
+	if err := learner.store.UpdatePredictRuleBenchmarkQps(rule.Id, learned); err != nil {
+		return learned, false, err
+	}
+	learner.logger.Info("benchmark qps auto updated", zap.Int64("rule_id", rule.Id), zap.String("service", rule.ServiceName),
+		zap.String("cluster", rule.ClusterName), zap.Int("old_benchmark_qps", rule.BenchmarkQps), zap.Int("new_benchmark_qps", learned),
+		zap.Int("samples", count))
+	return learned, true, nil
+}
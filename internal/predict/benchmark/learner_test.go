@@ -0,0 +1,77 @@
+package benchmark_test
+
+import (
+	"github.com/galaxy-future/cudgx/internal/predict/benchmark"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//memoryStore 在内存中保存规则和扩缩容事件
+type memoryStore struct {
+	rules   []*model.PredictRule
+	events  []*model.ScalingEvent
+	updated map[int64]int
+}
+
+func (store *memoryStore) ListAllPredictRules() ([]*model.PredictRule, error) {
+	return store.rules, nil
+}
+
+func (store *memoryStore) ListScalingEvents(serviceName, clusterName string, begin, end int64) ([]*model.ScalingEvent, error) {
+	return store.events, nil
+}
+
+func (store *memoryStore) UpdatePredictRuleBenchmarkQps(ruleID int64, benchmarkQps int) error {
+	store.updated[ruleID] = benchmarkQps
+	return nil
+}
+
+var _ = ginkgo.Describe("BenchmarkLearner", func() {
+	var store *memoryStore
+	var rule *model.PredictRule
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:                 1,
+			ServiceName:        "learn",
+			ClusterName:        "default",
+			BenchmarkQps:       100,
+			BenchmarkAutoLearn: true,
+		}
+		store = &memoryStore{rules: []*model.PredictRule{rule}, updated: map[int64]int{}}
+	})
+
+	ginkgo.It("updates benchmark qps from near optimal events", func() {
+		store.events = []*model.ScalingEvent{
+			{RuleId: 1, InstanceCount: 10, Redundancy: 0.5},
+			{RuleId: 1, InstanceCount: 20, Redundancy: 0.5},
+			// 冗余度不在区间内、其它规则和实例数为0的事件不参与学习
+			{RuleId: 1, InstanceCount: 10, Redundancy: 1.5},
+			{RuleId: 2, InstanceCount: 10, Redundancy: 0.5},
+			{RuleId: 1, InstanceCount: 0, Redundancy: 0.5},
+		}
+		learned, updated, err := benchmark.NewBenchmarkLearner(0, store).Learn(rule)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(updated).To(gomega.BeTrue())
+		gomega.Expect(learned).To(gomega.Equal(200))
+		gomega.Expect(store.updated).To(gomega.HaveKeyWithValue(int64(1), 200))
+	})
+
+	ginkgo.It("keeps benchmark qps within the threshold", func() {
+		rule.BenchmarkAutoLearnThresholdPct = 90
+		store.events = []*model.ScalingEvent{{RuleId: 1, InstanceCount: 10, Redundancy: 0.55}}
+		learned, updated, err := benchmark.NewBenchmarkLearner(0, store).Learn(rule)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(learned).To(gomega.Equal(182))
+		gomega.Expect(updated).To(gomega.BeFalse())
+		gomega.Expect(store.updated).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("skips rules without benchmark_auto_learn", func() {
+		store.events = []*model.ScalingEvent{{RuleId: 1, InstanceCount: 10, Redundancy: 0.5}}
+		rule.BenchmarkAutoLearn = false
+		gomega.Expect(benchmark.NewBenchmarkLearner(0, store).LearnAll()).To(gomega.Succeed())
+		gomega.Expect(store.updated).To(gomega.BeEmpty())
+	})
+})
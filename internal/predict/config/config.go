@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -9,6 +10,9 @@ import (
 	"github.com/galaxy-future/cudgx/common/clickhouse"
 	"github.com/galaxy-future/cudgx/common/types"
 	"github.com/galaxy-future/cudgx/common/victoriametrics"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/tracing"
 )
 
 //Config 预测器配置
@@ -23,12 +27,77 @@ type Config struct {
 	Xclient *Xclient `json:"xclient"`
 	//VictoriaMetrics 连接配置
 	VictoriaMetrics *victoriametrics.Config `json:"victoria_metrics"`
+	//Datadog 扩缩容事件推送配置
+	Datadog *event.DatadogConfig `json:"datadog"`
+	//Webhook 扩缩容事件推送配置
+	Webhook *event.WebhookConfig `json:"webhook"`
+	//Slack 扩缩容事件通知配置
+	Slack *event.SlackConfig `json:"slack"`
+	//Cost 扩缩容成本估算配置
+	Cost *Cost `json:"cost"`
+	//ServiceDiscovery 服务自动发现配置，为空时不自动创建规则
+	ServiceDiscovery *ServiceDiscoveryConfig `json:"service_discovery"`
+	//Auth 管理接口的 OIDC 认证配置，为空时不认证
+	Auth *OIDCConfig `json:"auth"`
+	//Tracing 调度链路追踪配置，为空时不上报 span
+	Tracing *tracing.Config `json:"tracing"`
 }
 
-//Xclient bridgx/schedulx连接配置
+//ServiceDiscoveryConfig 服务自动发现配置，定期为名称匹配的新服务集群按模板规则创建规则
+type ServiceDiscoveryConfig struct {
+	//NamePattern 需要自动创建规则的服务名称正则，不能为空
+	NamePattern string `json:"name_pattern"`
+	//TemplateID 模板规则ID，新规则复制模板规则的扩缩容策略和状态
+	TemplateID int64 `json:"template_id"`
+	//DiscoveryInterval 查询 schedulx 服务列表的周期，默认10分钟
+	DiscoveryInterval types.Duration `json:"discovery_interval"`
+	//DisabledServicePatterns 不自动创建规则的服务名称正则，运行中可通过 DisableAutoDiscovery 追加
+	DisabledServicePatterns []string `json:"disabled_service_patterns"`
+}
+
+//OIDCConfig 管理接口的 OIDC 认证配置
+type OIDCConfig struct {
+	//OIDCIssuerURL OIDC 提供方的 issuer，从 issuer + /.well-known/openid-configuration 获取公钥地址，为空时不认证
+	OIDCIssuerURL string `json:"oidc_issuer_url"`
+	//OIDCClientID token 的 aud 必须包含 client id
+	OIDCClientID string `json:"oidc_client_id"`
+	//RequiredGroups 写接口（POST/PUT/DELETE/PATCH）要求 token 的 groups 至少包含其中一个，为空时只要求 token 有效
+	RequiredGroups []string `json:"required_groups"`
+	//ReadScope 不为空时 GET 接口也需要 token，且 scope 包含 ReadScope；为空时 GET 接口不认证
+	ReadScope string `json:"read_scope"`
+}
+
+//MetricBackendConfig 按别名配置的指标后端，例如各团队独立的 Prometheus
+type MetricBackendConfig struct {
+	//Backend 后端类型，prometheus/victoriametrics，默认prometheus
+	Backend string `json:"backend"`
+	//URL Prometheus API 地址，不能为空
+	URL string `json:"url"`
+	//BearerToken 不为空时请求带 Authorization: Bearer
+	BearerToken string `json:"bearer_token"`
+	//Username 不为空时请求使用 basic auth
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+//Cost 扩缩容成本估算配置
+type Cost struct {
+	//CostPerInstanceHour 集群名 -> 单实例每小时成本
+	CostPerInstanceHour map[string]float64 `json:"cost_per_instance_hour"`
+}
+
+//Xclient bridgx/schedulx/Kubernetes连接配置
 type Xclient struct {
 	BridgxServerAddress   string `json:"bridgx_server_address"`
 	SchedulxServerAddress string `json:"schedulx_server_address"`
+	//SPIFFESocketPath SPIFFE Workload API 的 socket 路径，不为空时使用工作负载身份访问 schedulx（mTLS + JWT-SVID），不再使用 bridgx 登录 token
+	SPIFFESocketPath string `json:"spiffe_socket_path"`
+	//K8sAPIServerAddress Kubernetes API Server 地址，cluster_type 为 k8s_deployment/k8s_statefulset 的规则需要，为空时这些规则执行失败
+	K8sAPIServerAddress string `json:"k8s_api_server_address"`
+	//K8sBearerTokenFile 访问 API Server 的 token 文件，集群内运行时一般为 /var/run/secrets/kubernetes.io/serviceaccount/token
+	K8sBearerTokenFile string `json:"k8s_bearer_token_file"`
+	//K8sCAFile API Server 的 CA 证书，为空时使用系统根证书
+	K8sCAFile string `json:"k8s_ca_file"`
 }
 
 //Param 是Predict过程中使用到的多个可调参数
@@ -37,12 +106,193 @@ type Param struct {
 	RunDuration types.Duration `json:"run_duration"`
 	//RuleConcurrency 并行运行规则数量
 	RuleConcurrency int `json:"rule_concurrency"`
+	//RuleConcurrencyPerService 同一服务的多个集群规则最多并行运行的数量，0表示只受 RuleConcurrency 限制
+	RuleConcurrencyPerService int `json:"rule_concurrency_per_service"`
 	//MinimalSampleCount 参与判断中最少的指标点数，
 	MinimalSampleCount int `json:"minimal_sample_count"`
 	//LookbackDuration 回查多久
 	LookbackDuration types.Duration `json:"lookback_duration"`
 	//MetricSendDuration z指标传输所需时间，在这段时间内的指标是不准确的
 	MetricSendDuration types.Duration `json:"metric_send_duration"`
+	//OutlierRemovalMethod 计算冗余度中位数前剔除异常值的方法，none/iqr/zscore，默认none
+	OutlierRemovalMethod string `json:"outlier_removal_method"`
+	//MetricQueryTimeout 单次查询冗余度的超时时间，默认5s
+	MetricQueryTimeout types.Duration `json:"metric_query_timeout"`
+	//PerRuleDeadline 单条规则一次执行的最长时间，超时后取消规则中的指标查询和 schedulx 请求，避免卡住的规则一直占用 rule_concurrency，默认30s；
+	//use_expand_and_wait 和 use_gradual_expand 的规则在此基础上加上等待实例就绪和分批扩容的时间
+	PerRuleDeadline types.Duration `json:"per_rule_deadline"`
+	//ScheduleCacheTTL 服务集群调度状态的缓存时间，默认为调度周期的一半
+	ScheduleCacheTTL types.Duration `json:"schedule_cache_ttl"`
+	//QuotaCacheTTL check_quota_before_expand 查询的云厂商剩余配额的缓存时间，默认60s
+	QuotaCacheTTL types.Duration `json:"quota_cache_ttl"`
+	//LivenessThresholdMultiplier 超过多少个调度周期没有成功调度时存活探针失败，默认2
+	LivenessThresholdMultiplier float64 `json:"liveness_threshold_multiplier"`
+	//BackoffScheduleDuration 调度耗时过长时自动拉长调度周期，耗时恢复后再逐步缩短
+	BackoffScheduleDuration bool `json:"backoff_schedule_duration"`
+	//MinScheduleDuration 自动调整时调度周期的下限，默认为 run_duration
+	MinScheduleDuration types.Duration `json:"min_schedule_duration"`
+	//MaxScheduleDuration 自动调整时调度周期的上限，默认为 run_duration 的8倍
+	MaxScheduleDuration types.Duration `json:"max_schedule_duration"`
+	//AdaptiveConcurrency 根据调度耗时自动调整 rule_concurrency，耗时低于调度周期的一半时加1，超过调度周期的80%时减1
+	AdaptiveConcurrency bool `json:"adaptive_concurrency"`
+	//AdaptiveConcurrencyMin 自动调整时并发数的下限，默认1
+	AdaptiveConcurrencyMin int `json:"adaptive_concurrency_min"`
+	//AdaptiveConcurrencyMax 自动调整时并发数的上限，默认为 rule_concurrency 的4倍
+	AdaptiveConcurrencyMax int `json:"adaptive_concurrency_max"`
+	//Backend 冗余度指标后端，prometheus/victoriametrics，默认prometheus，修改后需重启生效
+	Backend string `json:"backend"`
+	//MetricBackends metric_backend_alias -> 指标后端配置，规则设置别名时查询对应的后端，未设置时使用 backend 和 victoria_metrics 配置的主后端，修改后需重启生效
+	MetricBackends map[string]MetricBackendConfig `json:"metric_backends"`
+	//MultiBackendAliases 不为空时主指标后端同时查询 backend 配置的后端和 metric_backends 中这些别名的后端，返回最先成功的结果，修改后需重启生效
+	MultiBackendAliases []string `json:"multi_backend_aliases"`
+	//ErrorThresholdForDisable 规则连续失败多少次后自动置为 error 状态，0表示不自动禁用
+	ErrorThresholdForDisable int `json:"error_threshold_for_disable"`
+	//ProfilingAddr 不为空时在该地址上提供 net/http/pprof，例如 127.0.0.1:6060
+	ProfilingAddr string `json:"profiling_addr"`
+	//ProfileOutputPath 不为空时收到 SIGUSR1 采集一次 CPU profile 并写入该文件
+	ProfileOutputPath string `json:"profile_output_path"`
+	//ProfileDuration 收到 SIGUSR1 后采集 CPU profile 的时长，默认30s
+	ProfileDuration types.Duration `json:"profile_duration"`
+	//BenchmarkLearnInterval 根据扩缩容事件学习 benchmark_qps 的周期，默认7天，只对开启 benchmark_auto_learn 的规则生效
+	BenchmarkLearnInterval types.Duration `json:"benchmark_learn_interval"`
+	//AllowedServicePattern 允许调度的服务名称正则，为空表示不限制，需要完全匹配时使用 ^...$，修改后需重启生效
+	AllowedServicePattern string `json:"allowed_service_pattern"`
+	//AllowedClusterPattern 允许调度的集群名称正则，为空表示不限制，修改后需重启生效
+	AllowedClusterPattern string `json:"allowed_cluster_pattern"`
+	//ExpandTimeoutMs 请求 schedulx 扩容的超时时间（毫秒），默认5000，修改后需重启生效
+	ExpandTimeoutMs int `json:"expand_timeout_ms"`
+	//ShrinkTimeoutMs 请求 schedulx 缩容的超时时间（毫秒），默认5000，修改后需重启生效。缩容通常比扩容快，可以设置得更短
+	ShrinkTimeoutMs int `json:"shrink_timeout_ms"`
+	//EndpointTimeoutsMs 按 schedulx 请求路径前缀设置的超时时间（毫秒），例如 {"/api/v1/schedulx/instance/service": 1000}，
+	//避免慢接口占用扩缩容的时间；只能比 expand_timeout_ms、shrink_timeout_ms 和默认的5000更短，修改后需重启生效
+	EndpointTimeoutsMs map[string]int `json:"endpoint_timeouts_ms"`
+	//RequireWarmCache 为 true 时，服务实例的 GetServiceByIp 缓存未命中则跳过本轮调度，避免刚清空缓存时基于刚查询到的服务信息做决策
+	RequireWarmCache bool `json:"require_warm_cache"`
+	//StickyShrinkEnabled 为 true 时，缩容优先选择最近扩容加入的实例，这些实例缓存的状态较少，缩容代价更低
+	StickyShrinkEnabled bool `json:"sticky_shrink_enabled"`
+	//ApprovalTimeoutMinutes 开启 review_required 的规则发起的人工确认多少分钟内有效，默认60，修改后需重启生效
+	ApprovalTimeoutMinutes int `json:"approval_timeout_minutes"`
+	//ApprovalBaseURL 本服务的访问地址，配置后确认通知中附带确认和拒绝接口的地址，导出迁移规则时记录为 source_url，修改后需重启生效
+	ApprovalBaseURL string `json:"approval_base_url"`
+	//MaxWatchConnections 最多同时通过 WebSocket 监听规则状态的连接数，默认100
+	MaxWatchConnections int `json:"max_watch_connections"`
+	//MetricsRetentionDays scaling_events 表中扩缩容事件的保留天数，0表示不清理，修改后需重启生效
+	MetricsRetentionDays int `json:"metrics_retention_days"`
+	//PruneInterval 清理过期扩缩容事件的周期，默认1天，修改后需重启生效
+	PruneInterval types.Duration `json:"prune_interval"`
+	//DBMaxOpenConns 数据库最多打开的连接数，0表示不限制，修改后需重启生效
+	DBMaxOpenConns int `json:"db_max_open_conns"`
+	//DBMaxIdleConns 数据库连接池保留的空闲连接数，默认2，修改后需重启生效
+	DBMaxIdleConns int `json:"db_max_idle_conns"`
+	//DBConnMaxLifetime 数据库连接最长的复用时间，默认不限制，修改后需重启生效
+	DBConnMaxLifetime types.Duration `json:"db_conn_max_lifetime"`
+	//DBConnMaxIdleTime 数据库连接最长的空闲时间，默认不限制，修改后需重启生效
+	DBConnMaxIdleTime types.Duration `json:"db_conn_max_idle_time"`
+	//DistributedLockBackend 多实例高可用部署时互斥执行同一服务集群规则的分布式锁，redis，为空时不加锁，修改后需重启生效
+	DistributedLockBackend string `json:"distributed_lock_backend"`
+	//RedisAddr distributed_lock_backend 为 redis 时 Redis 的地址，例如 127.0.0.1:6379
+	RedisAddr string `json:"redis_addr"`
+	//RemoteConfigURL 不为空时定期 GET 该地址获取调度参数的覆盖值，支持 run_duration、lookback_duration、metric_send_duration、
+	//minimal_sample_count 和 rule_concurrency，拉取失败时沿用最近一次成功获取的值，修改后需重启生效
+	RemoteConfigURL string `json:"remote_config_url"`
+	//RemoteConfigRefreshInterval 拉取远程调度参数的周期，默认60s，修改后需重启生效
+	RemoteConfigRefreshInterval types.Duration `json:"remote_config_refresh_interval"`
+	//DebugLogMetricValues 为 true 时每条规则在 DEBUG 日志中输出参与计算中位数的冗余度（按时间顺序和排序后各最多100个）以及中位数的位置，
+	//日志级别高于 DEBUG 时不输出
+	DebugLogMetricValues bool `json:"debug_log_metric_values"`
+	//RunOnce 只执行一轮调度，输出统计后退出，用于以 CronJob 方式部署
+	RunOnce bool `json:"run_once"`
+}
+
+//Validate 检查 redundancy keeper 运行所必需的参数，返回所有为空或不合法的字段；
+//predict.ValidateParam 在此之外还检查配置文件中字段之间的约束
+func (param *Param) Validate() error {
+	if param == nil {
+		return errors.New("param is required")
+	}
+	var errs []error
+	// RunOnce 只执行一轮，不需要调度周期
+	if param.RunDuration.Duration < 0 || (param.RunDuration.Duration == 0 && !param.RunOnce) {
+		errs = append(errs, fmt.Errorf("run_duration should be positive unless run_once is set, got %s", param.RunDuration.Duration))
+	}
+	if param.RuleConcurrency <= 0 {
+		errs = append(errs, fmt.Errorf("rule_concurrency should be positive, got %d", param.RuleConcurrency))
+	}
+	if param.RuleConcurrencyPerService < 0 {
+		errs = append(errs, fmt.Errorf("rule_concurrency_per_service can not be negative, got %d", param.RuleConcurrencyPerService))
+	}
+	if param.AdaptiveConcurrencyMin < 0 || param.AdaptiveConcurrencyMax < 0 {
+		errs = append(errs, fmt.Errorf("adaptive_concurrency_min and adaptive_concurrency_max can not be negative, got %d and %d", param.AdaptiveConcurrencyMin, param.AdaptiveConcurrencyMax))
+	} else if param.AdaptiveConcurrencyMax > 0 && param.AdaptiveConcurrencyMin > param.AdaptiveConcurrencyMax {
+		errs = append(errs, fmt.Errorf("adaptive_concurrency_min %d is greater than adaptive_concurrency_max %d", param.AdaptiveConcurrencyMin, param.AdaptiveConcurrencyMax))
+	}
+	if param.MinimalSampleCount <= 0 {
+		errs = append(errs, fmt.Errorf("minimal_sample_count should be positive, got %d", param.MinimalSampleCount))
+	}
+	for name, duration := range map[string]types.Duration{
+		"lookback_duration":              param.LookbackDuration,
+		"metric_send_duration":           param.MetricSendDuration,
+		"metric_query_timeout":           param.MetricQueryTimeout,
+		"schedule_cache_ttl":             param.ScheduleCacheTTL,
+		"quota_cache_ttl":                param.QuotaCacheTTL,
+		"min_schedule_duration":          param.MinScheduleDuration,
+		"max_schedule_duration":          param.MaxScheduleDuration,
+		"prune_interval":                 param.PruneInterval,
+		"per_rule_deadline":              param.PerRuleDeadline,
+		"remote_config_refresh_interval": param.RemoteConfigRefreshInterval,
+		"db_conn_max_lifetime":           param.DBConnMaxLifetime,
+		"db_conn_max_idle_time":          param.DBConnMaxIdleTime,
+	} {
+		if duration.Duration < 0 {
+			errs = append(errs, fmt.Errorf("%s can not be negative, got %s", name, duration.Duration))
+		}
+	}
+	if param.LivenessThresholdMultiplier < 0 {
+		errs = append(errs, fmt.Errorf("liveness_threshold_multiplier can not be negative, got %v", param.LivenessThresholdMultiplier))
+	}
+	if param.ErrorThresholdForDisable < 0 {
+		errs = append(errs, fmt.Errorf("error_threshold_for_disable can not be negative, got %d", param.ErrorThresholdForDisable))
+	}
+	if param.ApprovalTimeoutMinutes < 0 {
+		errs = append(errs, fmt.Errorf("approval_timeout_minutes can not be negative, got %d", param.ApprovalTimeoutMinutes))
+	}
+	if param.MaxWatchConnections < 0 {
+		errs = append(errs, fmt.Errorf("max_watch_connections can not be negative, got %d", param.MaxWatchConnections))
+	}
+	if param.DBMaxOpenConns < 0 || param.DBMaxIdleConns < 0 {
+		errs = append(errs, fmt.Errorf("db_max_open_conns and db_max_idle_conns can not be negative, got %d and %d", param.DBMaxOpenConns, param.DBMaxIdleConns))
+	}
+	if param.MetricsRetentionDays < 0 {
+		errs = append(errs, fmt.Errorf("metrics_retention_days can not be negative, got %d", param.MetricsRetentionDays))
+	}
+	switch param.DistributedLockBackend {
+	case "":
+	case consts.DistributedLockBackendRedis:
+		if param.RedisAddr == "" {
+			errs = append(errs, errors.New("redis_addr is required when distributed_lock_backend is redis"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("distributed_lock_backend should be redis or empty, got %s", param.DistributedLockBackend))
+	}
+	for alias, backend := range param.MetricBackends {
+		if alias == "" {
+			errs = append(errs, errors.New("metric_backends alias can not be empty"))
+		}
+		if backend.URL == "" {
+			errs = append(errs, fmt.Errorf("metric_backends %s url is required", alias))
+		}
+		switch backend.Backend {
+		case "", consts.MetricBackendPrometheus, consts.MetricBackendVictoriaMetrics:
+		default:
+			errs = append(errs, fmt.Errorf("metric_backends %s has unknown backend %s", alias, backend.Backend))
+		}
+	}
+	for _, alias := range param.MultiBackendAliases {
+		if _, ok := param.MetricBackends[alias]; !ok {
+			errs = append(errs, fmt.Errorf("multi_backend_aliases %s is not configured in metric_backends", alias))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 //LoadConfig 从文件中加载配置
@@ -12,6 +12,62 @@ const DefaultRuleConcurrency = 10
 const DefaultTrimmedSecond = 1
 const TrimmedSecond = 5
 const StepDuration = time.Second * 1
+const GradualExpandBatchInterval = 30 * time.Second
+const DefaultMetricQueryTimeout = 5 * time.Second
+const DefaultLivenessThresholdMultiplier = 2
+const DefaultMaxScheduleDurationMultiplier = 8
+const DefaultLookbackDuration = time.Minute
+const DefaultMetricSendDuration = 5 * time.Second
+const DefaultReadinessTimeout = 60 * time.Second
+const DefaultProfileDuration = 30 * time.Second
+const DefaultMaxWatchConnections = 100
+const DefaultBenchmarkLearnInterval = 7 * 24 * time.Hour
+const DefaultBenchmarkLearnLookback = 7 * 24 * time.Hour
+const DefaultBenchmarkAutoLearnThresholdPct = 10
+const DefaultDiscoveryInterval = 10 * time.Minute
+const DefaultSchedulxTimeoutMs = 5000
+
+//DefaultPerRuleDeadline 单条规则一次执行的默认最长时间
+const DefaultPerRuleDeadline = 30 * time.Second
+
+//DefaultQuotaCacheTTL 云厂商剩余配额的默认缓存时间
+const DefaultQuotaCacheTTL = 60 * time.Second
+
+//DefaultRemoteConfigRefreshInterval 拉取远程调度参数的默认周期
+const DefaultRemoteConfigRefreshInterval = 60 * time.Second
+
+const DefaultApprovalTimeoutMinutes = 60
+
+//DefaultAlertCooldownMinutes 规则未设置 alert_cooldown_minutes 时两次冗余度过高告警的最小间隔
+const DefaultAlertCooldownMinutes = 60
+
+//DefaultPruneInterval 清理过期扩缩容事件的默认周期
+const DefaultPruneInterval = 24 * time.Hour
+
+//PruneBatchSize 清理扩缩容事件时每次删除的最大行数，PruneBatchInterval 为两次删除之间的间隔，避免长时间锁表
+const PruneBatchSize = 1000
+const PruneBatchInterval = 100 * time.Millisecond
+
+//ApprovalCheckInterval 检查已确认和已过期的待确认扩缩容的间隔
+const ApprovalCheckInterval = 30 * time.Second
+
+//MaxBulkUpdateRules 一次批量更新最多修改的规则数，避免事务过大
+const MaxBulkUpdateRules = 100
+
+//DefaultRuleChangelogLimit 查询规则修改记录时默认返回的条数
+const DefaultRuleChangelogLimit = 50
+
+//MaxRuleChangelogLimit 查询规则修改记录时最多返回的条数
+const MaxRuleChangelogLimit = 500
+
+//BenchmarkCalibrateLookback 校准 benchmark_qps 时查询指标的时间范围
+const BenchmarkCalibrateLookback = time.Hour
+
+//BenchmarkLearnMinRedundancy/BenchmarkLearnMaxRedundancy 学习基准QPS时视为接近最优的冗余度区间
+const (
+	BenchmarkLearnMinRedundancy = 0.45
+	BenchmarkLearnMaxRedundancy = 0.55
+)
 
 const (
 	SchedulxExpandSuccess = "调用schedulx扩容接口成功："
@@ -26,6 +82,23 @@ const (
 const (
 	RuleStatusEnable  = "enable"
 	RuleStatusDisable = "disable"
+	//RuleStatusDraft 草稿，不参与调度，启用后生效
+	RuleStatusDraft = "draft"
+	//RuleStatusError 连续失败次数达到 error_threshold_for_disable 后被自动禁用，修复后重新启用
+	RuleStatusError = "error"
+)
+
+const (
+	//ApprovalStatusPending 等待人工确认
+	ApprovalStatusPending = "pending"
+	//ApprovalStatusApproved 已确认，等待后台执行
+	ApprovalStatusApproved = "approved"
+	ApprovalStatusRejected = "rejected"
+	//ApprovalStatusExpired 超过 approval_timeout_minutes 仍未确认
+	ApprovalStatusExpired  = "expired"
+	ApprovalStatusExecuted = "executed"
+	//ApprovalStatusFailed 执行前重新检查不通过或执行失败，原因记录在 message
+	ApprovalStatusFailed = "failed"
 )
 
 const (
@@ -33,3 +106,86 @@ const (
 	MetricNameLoad          = "load"
 	MetricNameInstanceCount = "insanceCount"
 )
+
+const (
+	OutlierRemovalNone   = "none"
+	OutlierRemovalIQR    = "iqr"
+	OutlierRemovalZScore = "zscore"
+)
+
+const (
+	MetricQueryModeRaw           = "raw"
+	MetricQueryModeRecordingRule = "recording_rule"
+)
+
+const (
+	//MetricScopeService 指标带 serviceName/clusterName label，按服务集群查询
+	MetricScopeService = "service"
+	//MetricScopeInstance 指标只带 instance label，按服务集群的实例 ip 查询
+	MetricScopeInstance = "instance"
+)
+
+const (
+	//ShrinkPreferenceDefault 由 schedulx 决定缩容哪些实例
+	ShrinkPreferenceDefault = "default"
+	//ShrinkPreferenceOldest 缩容运行时间最长的实例
+	ShrinkPreferenceOldest = "oldest"
+	//ShrinkPreferenceLowestQps 缩容 QPS 最低的实例
+	ShrinkPreferenceLowestQps = "lowest_qps"
+)
+
+const (
+	//ScalePolicyImmediate 冗余度超出范围时立即扩缩容
+	ScalePolicyImmediate = "immediate"
+	//ScalePolicySmoothed 按冗余度的指数移动平均判断是否超出范围
+	ScalePolicySmoothed = "smoothed"
+	//ScalePolicyConsecutive 冗余度连续 consecutive_ticks_required 轮超出范围才扩缩容
+	ScalePolicyConsecutive = "consecutive"
+)
+
+const (
+	//ClusterTypeSchedulx 通过 schedulx 扩缩容，cluster_name 为 schedulx 的服务集群
+	ClusterTypeSchedulx = "schedulx"
+	//ClusterTypeK8sDeployment 通过 Kubernetes Deployment 的 scale 子资源扩缩容，cluster_name 为 namespace/name
+	ClusterTypeK8sDeployment = "k8s_deployment"
+	//ClusterTypeK8sStatefulSet 通过 Kubernetes StatefulSet 的 scale 子资源扩缩容，cluster_name 为 namespace/name
+	ClusterTypeK8sStatefulSet = "k8s_statefulset"
+)
+
+const (
+	//MetricMissingActionSkip 没有指标数据时跳过本轮
+	MetricMissingActionSkip = "skip"
+	//MetricMissingActionScaleToMin 没有指标数据时缩容到 min_instance_count，适合离线服务
+	MetricMissingActionScaleToMin = "scale_to_min"
+	//MetricMissingActionScaleToMax 没有指标数据时扩容到 max_instance_count，适合核心服务
+	MetricMissingActionScaleToMax = "scale_to_max"
+	//MetricMissingActionAlertOnly 没有指标数据时只发送告警
+	MetricMissingActionAlertOnly = "alert_only"
+)
+
+//ScalePolicyEMAAlpha smoothed 策略中本轮冗余度的权重
+const ScalePolicyEMAAlpha = 0.3
+
+//TrafficSplitSourceSchedulx 从 schedulx 的 /api/v1/schedulx/service/traffic-split 查询蓝绿集群的流量比例
+const TrafficSplitSourceSchedulx = "schedulx"
+
+const (
+	MetricBackendPrometheus      = "prometheus"
+	MetricBackendVictoriaMetrics = "victoriametrics"
+)
+
+//DistributedLockBackendRedis 多个 keeper 实例通过 Redis 的 SET NX 互斥执行同一服务集群的规则
+const DistributedLockBackendRedis = "redis"
+
+const (
+	//DistributedLockTTL 分布式锁的过期时间，持有锁的实例异常退出后最多这么久其他实例可以接手
+	DistributedLockTTL = 15 * time.Second
+	//DistributedLockWait 获取分布式锁最多等待的时间，超时后本轮跳过该规则
+	DistributedLockWait = time.Second
+)
+
+//InstanceStatusHealthy schedulx 实例列表中健康检查通过的实例状态，healthy_instances_only 的规则只用这些实例的指标
+const InstanceStatusHealthy = "healthy"
+
+//MinPartialExpand schedulx 容量不足时每次把扩容数减半重试，减到该值仍失败时放弃
+const MinPartialExpand = 1
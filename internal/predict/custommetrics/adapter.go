@@ -0,0 +1,207 @@
+package custommetrics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+)
+
+//GroupVersion Kubernetes Custom Metrics API 的版本
+const GroupVersion = "custom.metrics.k8s.io/v1beta1"
+
+//RedundancyMetricName 对外暴露的冗余度指标名称
+const RedundancyMetricName = "cudgx_redundancy"
+
+const (
+	//LabelServiceName 按服务名过滤的 label
+	LabelServiceName = "service_name"
+	//LabelClusterName 按集群名过滤的 label
+	LabelClusterName = "cluster_name"
+)
+
+//ErrMetricNotFound 请求的指标不存在
+var ErrMetricNotFound = errors.New("metric not found")
+
+//resourceKinds 请求的资源类型对应的 DescribedObject Kind
+var resourceKinds = map[string]string{
+	"pods":     "Pod",
+	"services": "Service",
+}
+
+//RedundancySource 返回每条规则最近一次计算出冗余度的执行记录，见 redundancy_keeper.LatestRedundancies
+type RedundancySource func() ([]*redundancy_keeper.RuleTrace, error)
+
+//ObjectReference 指标所描述的对象
+type ObjectReference struct {
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	APIVersion string `json:"apiVersion"`
+}
+
+//MetricValue 单个对象的指标值
+type MetricValue struct {
+	DescribedObject ObjectReference `json:"describedObject"`
+	MetricName      string          `json:"metricName"`
+	Timestamp       string          `json:"timestamp"`
+	Value           string          `json:"value"`
+}
+
+type ListMeta struct {
+	SelfLink string `json:"selfLink,omitempty"`
+}
+
+//MetricValueList 指标值列表，对应 custom.metrics.k8s.io/v1beta1 MetricValueList
+type MetricValueList struct {
+	Kind       string        `json:"kind"`
+	APIVersion string        `json:"apiVersion"`
+	Metadata   ListMeta      `json:"metadata"`
+	Items      []MetricValue `json:"items"`
+}
+
+type APIResource struct {
+	Name         string   `json:"name"`
+	SingularName string   `json:"singularName"`
+	Namespaced   bool     `json:"namespaced"`
+	Kind         string   `json:"kind"`
+	Verbs        []string `json:"verbs"`
+}
+
+//APIResourceList 支持的指标列表，供 kubectl 和 HPA 发现
+type APIResourceList struct {
+	Kind         string        `json:"kind"`
+	APIVersion   string        `json:"apiVersion"`
+	GroupVersion string        `json:"groupVersion"`
+	Resources    []APIResource `json:"resources"`
+}
+
+//Status 请求失败时返回的 Kubernetes Status
+type Status struct {
+	Kind       string `json:"kind"`
+	APIVersion string `json:"apiVersion"`
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+	Reason     string `json:"reason"`
+	Code       int    `json:"code"`
+}
+
+//NewStatus 新建失败的 Status
+func NewStatus(code int, message string) *Status {
+	return &Status{
+		Kind:       "Status",
+		APIVersion: "v1",
+		Status:     "Failure",
+		Message:    message,
+		Reason:     strings.ReplaceAll(http.StatusText(code), " ", ""),
+		Code:       code,
+	}
+}
+
+//CustomMetricsAdapter 将 cudgx 计算出的冗余度通过 Kubernetes Custom Metrics API 暴露给 kubectl 和 HPA。
+//cudgx 只按服务集群计算冗余度，不感知 pod，因此每个服务集群返回一个指标值，Kind 为请求的资源类型，
+//可以通过 service_name/cluster_name label 过滤
+type CustomMetricsAdapter struct {
+	source RedundancySource
+}
+
+//NewCustomMetricsAdapter 新建 CustomMetricsAdapter
+func NewCustomMetricsAdapter(source RedundancySource) *CustomMetricsAdapter {
+	return &CustomMetricsAdapter{source: source}
+}
+
+//ListAllMetrics 返回支持的指标
+func (adapter *CustomMetricsAdapter) ListAllMetrics() *APIResourceList {
+	return &APIResourceList{
+		Kind:         "APIResourceList",
+		APIVersion:   "v1",
+		GroupVersion: GroupVersion,
+		Resources: []APIResource{{
+			Name:       "pods/" + RedundancyMetricName,
+			Namespaced: true,
+			Kind:       "MetricValueList",
+			Verbs:      []string{"get"},
+		}},
+	}
+}
+
+//GetMetricBySelector 返回满足 selector 的所有服务集群最近一次计算的冗余度，resource 为请求的资源类型，例如 pods；
+//冗余度不是有限值的服务集群不返回
+func (adapter *CustomMetricsAdapter) GetMetricBySelector(namespace, resource, metricName string, selector map[string]string) (*MetricValueList, error) {
+	if metricName != RedundancyMetricName {
+		return nil, fmt.Errorf("%w: %s", ErrMetricNotFound, metricName)
+	}
+	kind, ok := resourceKinds[resource]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s/%s", ErrMetricNotFound, resource, metricName)
+	}
+	traces, err := adapter.source()
+	if err != nil {
+		return nil, err
+	}
+	list := &MetricValueList{
+		Kind:       "MetricValueList",
+		APIVersion: GroupVersion,
+		Metadata:   ListMeta{SelfLink: fmt.Sprintf("/apis/%s/namespaces/%s/%s/%%2A/%s", GroupVersion, namespace, resource, metricName)},
+		Items:      []MetricValue{},
+	}
+	for _, trace := range traces {
+		if trace.Redundancy == nil || math.IsNaN(*trace.Redundancy) || math.IsInf(*trace.Redundancy, 0) || !matchSelector(trace, selector) {
+			continue
+		}
+		list.Items = append(list.Items, MetricValue{
+			DescribedObject: ObjectReference{
+				Kind:       kind,
+				Namespace:  namespace,
+				Name:       trace.ServiceName,
+				APIVersion: "/v1",
+			},
+			MetricName: metricName,
+			Timestamp:  time.Unix(trace.Timestamp, 0).UTC().Format(time.RFC3339),
+			Value:      formatQuantity(*trace.Redundancy),
+		})
+	}
+	return list, nil
+}
+
+//ParseSelector 解析 labelSelector，只支持 k=v 或 k==v 并以逗号分隔
+func ParseSelector(labelSelector string) (map[string]string, error) {
+	selector := make(map[string]string)
+	if strings.TrimSpace(labelSelector) == "" {
+		return selector, nil
+	}
+	for _, requirement := range strings.Split(labelSelector, ",") {
+		requirement = strings.TrimSpace(requirement)
+		key, value, ok := strings.Cut(requirement, "=")
+		if !ok || strings.ContainsAny(key, "!<>") {
+			return nil, fmt.Errorf("unsupported label selector : %s", requirement)
+		}
+		value = strings.TrimPrefix(value, "=")
+		selector[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return selector, nil
+}
+
+//matchSelector 只按 service_name/cluster_name 过滤，HPA 会带上 pod 的 label，其他 label 忽略
+func matchSelector(trace *redundancy_keeper.RuleTrace, selector map[string]string) bool {
+	if value, ok := selector[LabelServiceName]; ok && trace.ServiceName != value {
+		return false
+	}
+	if value, ok := selector[LabelClusterName]; ok && trace.ClusterName != value {
+		return false
+	}
+	return true
+}
+
+//maxQuantityMilli int64 能表示的最大千分位数值，超出时截断，避免转换溢出
+const maxQuantityMilli = math.MaxInt64 / 1000
+
+//formatQuantity 按 Kubernetes Quantity 格式输出，保留到千分位，例如 0.85 输出为 850m；value 需要是有限值
+func formatQuantity(value float64) string {
+	value = math.Max(math.Min(value, maxQuantityMilli), -maxQuantityMilli)
+	return fmt.Sprintf("%dm", int64(math.Round(value*1000)))
+}
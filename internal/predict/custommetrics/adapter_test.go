@@ -0,0 +1,73 @@
+package custommetrics_test
+
+import (
+	"errors"
+	"math"
+
+	"github.com/galaxy-future/cudgx/internal/predict/custommetrics"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+func redundancyOf(value float64) *float64 {
+	return &value
+}
+
+var _ = ginkgo.Describe("CustomMetricsAdapter", func() {
+	adapter := custommetrics.NewCustomMetricsAdapter(func() ([]*redundancy_keeper.RuleTrace, error) {
+		return []*redundancy_keeper.RuleTrace{
+			{RuleId: 1, ServiceName: "gf.cudgx.pi", ClusterName: "default", Timestamp: 1640695149, Redundancy: redundancyOf(0.85)},
+			{RuleId: 2, ServiceName: "gf.cudgx.pi", ClusterName: "backup", Timestamp: 1640695149, Redundancy: redundancyOf(2.5)},
+			{RuleId: 3, ServiceName: "gf.cudgx.web", ClusterName: "default", Timestamp: 1640695149},
+			{RuleId: 4, ServiceName: "gf.cudgx.idle", ClusterName: "default", Timestamp: 1640695149, Redundancy: redundancyOf(math.Inf(1))},
+			{RuleId: 5, ServiceName: "gf.cudgx.nan", ClusterName: "default", Timestamp: 1640695149, Redundancy: redundancyOf(math.NaN())},
+		}, nil
+	})
+
+	ginkgo.It("returns the last redundancy of every service cluster", func() {
+		list, err := adapter.GetMetricBySelector("default", "pods", custommetrics.RedundancyMetricName, nil)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(list.Kind).To(gomega.Equal("MetricValueList"))
+		gomega.Expect(list.Items).To(gomega.HaveLen(2))
+		gomega.Expect(list.Items[0].DescribedObject.Name).To(gomega.Equal("gf.cudgx.pi"))
+		gomega.Expect(list.Items[0].DescribedObject.Kind).To(gomega.Equal("Pod"))
+		gomega.Expect(list.Items[0].Value).To(gomega.Equal("850m"))
+		gomega.Expect(list.Items[0].Timestamp).To(gomega.Equal("2021-12-28T12:39:09Z"))
+		gomega.Expect(list.Items[1].Value).To(gomega.Equal("2500m"))
+	})
+
+	ginkgo.It("filters by label selector", func() {
+		selector, err := custommetrics.ParseSelector("service_name=gf.cudgx.pi,cluster_name==backup")
+		gomega.Expect(err).To(gomega.BeNil())
+		list, err := adapter.GetMetricBySelector("default", "pods", custommetrics.RedundancyMetricName, selector)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(list.Items).To(gomega.HaveLen(1))
+		gomega.Expect(list.Items[0].Value).To(gomega.Equal("2500m"))
+	})
+
+	ginkgo.It("ignores labels other than service_name and cluster_name", func() {
+		selector, err := custommetrics.ParseSelector("app=gf-cudgx-pi,service_name=gf.cudgx.pi,cluster_name=default")
+		gomega.Expect(err).To(gomega.BeNil())
+		list, err := adapter.GetMetricBySelector("default", "pods", custommetrics.RedundancyMetricName, selector)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(list.Items).To(gomega.HaveLen(1))
+		gomega.Expect(list.Items[0].Value).To(gomega.Equal("850m"))
+	})
+
+	ginkgo.It("describes the object with the requested resource kind", func() {
+		list, err := adapter.GetMetricBySelector("default", "services", custommetrics.RedundancyMetricName, nil)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(list.Items[0].DescribedObject.Kind).To(gomega.Equal("Service"))
+		gomega.Expect(list.Metadata.SelfLink).To(gomega.ContainSubstring("/services/"))
+		_, err = adapter.GetMetricBySelector("default", "nodes", custommetrics.RedundancyMetricName, nil)
+		gomega.Expect(errors.Is(err, custommetrics.ErrMetricNotFound)).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("rejects unsupported selectors and metrics", func() {
+		_, err := custommetrics.ParseSelector("service_name!=gf.cudgx.pi")
+		gomega.Expect(err).NotTo(gomega.BeNil())
+		_, err = adapter.GetMetricBySelector("default", "pods", "qps", nil)
+		gomega.Expect(errors.Is(err, custommetrics.ErrMetricNotFound)).To(gomega.BeTrue())
+	})
+})
@@ -0,0 +1,244 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/logger"
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"go.uber.org/zap"
+)
+
+var serviceDiscovery *ServiceDiscovery
+
+//Store 读取已有规则并按模板规则创建新规则
+type Store interface {
+	ListAllPredictRules() ([]*model.PredictRule, error)
+	ListDeletedRules(since time.Time) ([]*model.PredictRule, error)
+	CreateRuleFromTemplate(templateRuleID int64, serviceName, clusterName string) (*model.PredictRule, error)
+}
+
+//modelStore 从数据库读取和创建
+type modelStore struct{}
+
+func (modelStore) ListAllPredictRules() ([]*model.PredictRule, error) {
+	return model.ListAllPredictRules()
+}
+
+func (modelStore) ListDeletedRules(since time.Time) ([]*model.PredictRule, error) {
+	return model.ListDeletedRules(since)
+}
+
+func (modelStore) CreateRuleFromTemplate(templateRuleID int64, serviceName, clusterName string) (*model.PredictRule, error) {
+	return service.CreateRuleFromTemplate(templateRuleID, serviceName, clusterName)
+}
+
+//Notifier 自动创建规则后发送通知
+type Notifier interface {
+	NotifyRuleDiscovered(ctx context.Context, e *event.RuleDiscoveredEvent) error
+}
+
+//ServiceDiscovery 定期查询 schedulx 中的服务集群，为名称匹配且还没有规则的服务集群按模板规则创建规则
+type ServiceDiscovery struct {
+	namePattern  *regexp.Regexp
+	templateID   int64
+	interval     time.Duration
+	listServices func(ctx context.Context) ([]clients.ServiceClusterPair, error)
+	store        Store
+	notifier     Notifier
+	logger       *zap.Logger
+
+	lock     sync.RWMutex
+	disabled []*regexp.Regexp
+}
+
+//Option ServiceDiscovery 的可选配置
+type Option func(discovery *ServiceDiscovery)
+
+//WithServiceLister 指定查询服务集群的方式，为空时查询 schedulx
+func WithServiceLister(listServices func(ctx context.Context) ([]clients.ServiceClusterPair, error)) Option {
+	return func(discovery *ServiceDiscovery) {
+		if listServices != nil {
+			discovery.listServices = listServices
+		}
+	}
+}
+
+//WithStore 指定读取和创建规则的方式，为空时使用数据库
+func WithStore(store Store) Option {
+	return func(discovery *ServiceDiscovery) {
+		if store != nil {
+			discovery.store = store
+		}
+	}
+}
+
+//WithNotifier 指定创建规则后的通知方式，不指定时不发送通知
+func WithNotifier(notifier Notifier) Option {
+	return func(discovery *ServiceDiscovery) {
+		discovery.notifier = notifier
+	}
+}
+
+//NewServiceDiscovery 根据配置新建 ServiceDiscovery
+func NewServiceDiscovery(discoveryConfig *config.ServiceDiscoveryConfig, opts ...Option) (*ServiceDiscovery, error) {
+	if discoveryConfig.NamePattern == "" {
+		return nil, errors.New("service discovery name pattern can not be empty")
+	}
+	namePattern, err := regexp.Compile(discoveryConfig.NamePattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service discovery name pattern %q , %w", discoveryConfig.NamePattern, err)
+	}
+	if discoveryConfig.TemplateID <= 0 {
+		return nil, errors.New("service discovery template id should be greater than 0")
+	}
+	interval := discoveryConfig.DiscoveryInterval.Duration
+	if interval < 0 {
+		return nil, errors.New("service discovery interval can not be negative")
+	}
+	if interval == 0 {
+		interval = consts.DefaultDiscoveryInterval
+	}
+	discovery := &ServiceDiscovery{
+		namePattern:  namePattern,
+		templateID:   discoveryConfig.TemplateID,
+		interval:     interval,
+		listServices: clients.ListAvailableServices,
+		store:        modelStore{},
+		logger:       logger.GetLogger(),
+	}
+	for _, opt := range opts {
+		opt(discovery)
+	}
+	for _, pattern := range discoveryConfig.DisabledServicePatterns {
+		if err := discovery.DisableAutoDiscovery(pattern); err != nil {
+			return nil, err
+		}
+	}
+	return discovery, nil
+}
+
+//Start 立即执行一次自动发现，之后每隔 interval 执行一次，直到 ctx 结束
+func (discovery *ServiceDiscovery) Start(ctx context.Context) {
+	ticker := time.NewTicker(discovery.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := discovery.Discover(ctx); err != nil {
+			discovery.logger.Error("discover services failed", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//Discover 执行一次自动发现，返回本次创建的规则；单个服务集群创建失败不影响其它服务集群。
+//软删除的规则也视为已存在，删除自动创建的规则后不会再次创建
+func (discovery *ServiceDiscovery) Discover(ctx context.Context) ([]*model.PredictRule, error) {
+	pairs, err := discovery.listServices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list available services failed , %w", err)
+	}
+	rules, err := discovery.store.ListAllPredictRules()
+	if err != nil {
+		return nil, err
+	}
+	deletedRules, err := discovery.store.ListDeletedRules(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[clients.ServiceClusterPair]bool, len(rules)+len(deletedRules))
+	for _, rule := range append(rules, deletedRules...) {
+		existing[clients.ServiceClusterPair{ServiceName: rule.ServiceName, ClusterName: rule.ClusterName}] = true
+	}
+
+	var created []*model.PredictRule
+	for _, pair := range pairs {
+		if existing[pair] || !discovery.namePattern.MatchString(pair.ServiceName) || discovery.isDisabled(pair.ServiceName) {
+			continue
+		}
+		if err := clients.MatchNamePatterns(pair.ServiceName, pair.ClusterName); err != nil {
+			continue
+		}
+		rule, err := discovery.store.CreateRuleFromTemplate(discovery.templateID, pair.ServiceName, pair.ClusterName)
+		if err != nil {
+			discovery.logger.Error("create rule for discovered service failed", zap.String("service", pair.ServiceName),
+				zap.String("cluster", pair.ClusterName), zap.Int64("template_id", discovery.templateID), zap.Error(err))
+			continue
+		}
+		existing[pair] = true
+		created = append(created, rule)
+		discovery.logger.Info("rule created for discovered service", zap.String("service", pair.ServiceName),
+			zap.String("cluster", pair.ClusterName), zap.Int64("rule_id", rule.Id), zap.Int64("template_id", discovery.templateID))
+		if discovery.notifier != nil {
+			e := &event.RuleDiscoveredEvent{RuleId: rule.Id, ServiceName: rule.ServiceName, ClusterName: rule.ClusterName, TemplateRuleId: discovery.templateID}
+			if err := discovery.notifier.NotifyRuleDiscovered(ctx, e); err != nil {
+				discovery.logger.Error("notify discovered rule failed", zap.Int64("rule_id", rule.Id), zap.Error(err))
+			}
+		}
+	}
+	return created, nil
+}
+
+//DisableAutoDiscovery 名称匹配 servicePattern 的服务不再自动创建规则，已创建的规则不受影响
+func (discovery *ServiceDiscovery) DisableAutoDiscovery(servicePattern string) error {
+	if servicePattern == "" {
+		return errors.New("service pattern can not be empty")
+	}
+	pattern, err := regexp.Compile(servicePattern)
+	if err != nil {
+		return fmt.Errorf("invalid service pattern %q , %w", servicePattern, err)
+	}
+	discovery.lock.Lock()
+	discovery.disabled = append(discovery.disabled, pattern)
+	discovery.lock.Unlock()
+	return nil
+}
+
+func (discovery *ServiceDiscovery) isDisabled(serviceName string) bool {
+	discovery.lock.RLock()
+	defer discovery.lock.RUnlock()
+	for _, pattern := range discovery.disabled {
+		if pattern.MatchString(serviceName) {
+			return true
+		}
+	}
+	return false
+}
+
+//InitServiceDiscovery 根据配置初始化服务自动发现
+func InitServiceDiscovery(discoveryConfig *config.ServiceDiscoveryConfig, opts ...Option) error {
+	discovery, err := NewServiceDiscovery(discoveryConfig, opts...)
+	if err != nil {
+		return err
+	}
+	serviceDiscovery = discovery
+	return nil
+}
+
+//Start 启动服务自动发现，未配置时直接返回
+func Start(ctx context.Context) {
+	if serviceDiscovery == nil {
+		return
+	}
+	serviceDiscovery.Start(ctx)
+}
+
+//DisableAutoDiscovery 名称匹配 servicePattern 的服务不再自动创建规则
+func DisableAutoDiscovery(servicePattern string) error {
+	if serviceDiscovery == nil {
+		return errors.New("service discovery is not initialized")
+	}
+	return serviceDiscovery.DisableAutoDiscovery(servicePattern)
+}
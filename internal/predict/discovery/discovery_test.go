@@ -0,0 +1,146 @@
+package discovery_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/discovery"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//memoryStore 在内存中保存规则，CreateRuleFromTemplate 对 failService 返回错误
+type memoryStore struct {
+	rules        []*model.PredictRule
+	deletedRules []*model.PredictRule
+	failService  string
+}
+
+func (store *memoryStore) ListAllPredictRules() ([]*model.PredictRule, error) {
+	return store.rules, nil
+}
+
+func (store *memoryStore) ListDeletedRules(since time.Time) ([]*model.PredictRule, error) {
+	return store.deletedRules, nil
+}
+
+func (store *memoryStore) CreateRuleFromTemplate(templateRuleID int64, serviceName, clusterName string) (*model.PredictRule, error) {
+	if serviceName == store.failService {
+		return nil, errors.New("create failed")
+	}
+	rule := &model.PredictRule{
+		Id:               int64(100 + len(store.rules)),
+		ServiceName:      serviceName,
+		ClusterName:      clusterName,
+		ClonedFromRuleID: templateRuleID,
+		AutoDiscovered:   true,
+	}
+	store.rules = append(store.rules, rule)
+	return rule, nil
+}
+
+//recordingNotifier 记录收到的通知
+type recordingNotifier struct {
+	events []*event.RuleDiscoveredEvent
+}
+
+func (notifier *recordingNotifier) NotifyRuleDiscovered(ctx context.Context, e *event.RuleDiscoveredEvent) error {
+	notifier.events = append(notifier.events, e)
+	return nil
+}
+
+var _ = ginkgo.Describe("ServiceDiscovery", func() {
+	var store *memoryStore
+	var notifier *recordingNotifier
+	pairs := []clients.ServiceClusterPair{
+		{ServiceName: "gf.cudgx.template", ClusterName: "default"},
+		{ServiceName: "gf.cudgx.new", ClusterName: "default"},
+		{ServiceName: "gf.cudgx.new", ClusterName: "canary"},
+		{ServiceName: "gf.cudgx.removed", ClusterName: "default"},
+		{ServiceName: "gf.other.new", ClusterName: "default"},
+	}
+
+	ginkgo.BeforeEach(func() {
+		store = &memoryStore{
+			rules:        []*model.PredictRule{{Id: 1, ServiceName: "gf.cudgx.template", ClusterName: "default"}},
+			deletedRules: []*model.PredictRule{{Id: 2, ServiceName: "gf.cudgx.removed", ClusterName: "default"}},
+		}
+		notifier = &recordingNotifier{}
+	})
+
+	newDiscovery := func(discoveryConfig *config.ServiceDiscoveryConfig) *discovery.ServiceDiscovery {
+		d, err := discovery.NewServiceDiscovery(discoveryConfig,
+			discovery.WithServiceLister(func(ctx context.Context) ([]clients.ServiceClusterPair, error) { return pairs, nil }),
+			discovery.WithStore(store),
+			discovery.WithNotifier(notifier),
+		)
+		gomega.Expect(err).To(gomega.BeNil())
+		return d
+	}
+
+	ginkgo.It("creates rules for new service clusters matching the pattern", func() {
+		d := newDiscovery(&config.ServiceDiscoveryConfig{NamePattern: `^gf\.cudgx\.`, TemplateID: 1})
+		created, err := d.Discover(context.Background())
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(created).To(gomega.HaveLen(2))
+		gomega.Expect(created[0].ServiceName).To(gomega.Equal("gf.cudgx.new"))
+		gomega.Expect(created[0].ClusterName).To(gomega.Equal("default"))
+		gomega.Expect(created[1].ClusterName).To(gomega.Equal("canary"))
+		gomega.Expect(created[0].AutoDiscovered).To(gomega.BeTrue())
+		gomega.Expect(created[0].ClonedFromRuleID).To(gomega.Equal(int64(1)))
+
+		gomega.Expect(notifier.events).To(gomega.HaveLen(2))
+		gomega.Expect(notifier.events[0].RuleId).To(gomega.Equal(created[0].Id))
+		gomega.Expect(notifier.events[0].TemplateRuleId).To(gomega.Equal(int64(1)))
+
+		created, err = d.Discover(context.Background())
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(created).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("skips services disabled for auto discovery", func() {
+		d := newDiscovery(&config.ServiceDiscoveryConfig{NamePattern: `^gf\.`, TemplateID: 1, DisabledServicePatterns: []string{`^gf\.other\.`}})
+		gomega.Expect(d.DisableAutoDiscovery(`\.new$`)).To(gomega.BeNil())
+		created, err := d.Discover(context.Background())
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(created).To(gomega.BeEmpty())
+		gomega.Expect(notifier.events).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("keeps discovering after a rule fails to be created", func() {
+		store.failService = "gf.cudgx.new"
+		otherPairs := append(pairs, clients.ServiceClusterPair{ServiceName: "gf.cudgx.later", ClusterName: "default"})
+		d, err := discovery.NewServiceDiscovery(&config.ServiceDiscoveryConfig{NamePattern: `^gf\.cudgx\.`, TemplateID: 1},
+			discovery.WithServiceLister(func(ctx context.Context) ([]clients.ServiceClusterPair, error) { return otherPairs, nil }),
+			discovery.WithStore(store),
+		)
+		gomega.Expect(err).To(gomega.BeNil())
+		created, err := d.Discover(context.Background())
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(created).To(gomega.HaveLen(1))
+		gomega.Expect(created[0].ServiceName).To(gomega.Equal("gf.cudgx.later"))
+	})
+
+	ginkgo.It("validates the config", func() {
+		_, err := discovery.NewServiceDiscovery(&config.ServiceDiscoveryConfig{TemplateID: 1})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		_, err = discovery.NewServiceDiscovery(&config.ServiceDiscoveryConfig{NamePattern: "(", TemplateID: 1})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		_, err = discovery.NewServiceDiscovery(&config.ServiceDiscoveryConfig{NamePattern: "gf"})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		_, err = discovery.NewServiceDiscovery(&config.ServiceDiscoveryConfig{NamePattern: "gf", TemplateID: 1, DisabledServicePatterns: []string{"("}})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("requires initialization before DisableAutoDiscovery", func() {
+		gomega.Expect(discovery.DisableAutoDiscovery("gf")).To(gomega.HaveOccurred())
+		gomega.Expect(discovery.InitServiceDiscovery(&config.ServiceDiscoveryConfig{NamePattern: "gf", TemplateID: 1})).To(gomega.BeNil())
+		gomega.Expect(discovery.DisableAutoDiscovery("gf")).To(gomega.BeNil())
+		gomega.Expect(discovery.DisableAutoDiscovery("(")).To(gomega.HaveOccurred())
+	})
+})
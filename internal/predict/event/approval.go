@@ -0,0 +1,23 @@
+package event
+
+//ActionApprovalRequested 扩缩容等待人工确认通知的 action
+const ActionApprovalRequested = "approval_requested"
+
+//ApprovalRequestedEvent 扩缩容超过规则的 review_threshold，等待人工确认
+type ApprovalRequestedEvent struct {
+	ApprovalId  int64  `json:"approval_id"`
+	RuleId      int64  `json:"rule_id"`
+	ServiceName string `json:"service_name"`
+	ClusterName string `json:"cluster_name"`
+	//ScaleAction 等待确认的动作，ActionScaleUp 或 ActionScaleDown
+	ScaleAction   string  `json:"scale_action"`
+	Count         int     `json:"count"`
+	InstanceCount int     `json:"instance_count"`
+	Redundancy    float64 `json:"redundancy"`
+	//ExpireAt 超过该时间仍未确认则不再执行
+	ExpireAt int64 `json:"expire_at"`
+	//ApproveURL/RejectURL 确认和拒绝接口的地址，需要带认证 token POST，不能作为链接直接打开；未配置 approval_base_url 时为空
+	ApproveURL string `json:"approve_url,omitempty"`
+	RejectURL  string `json:"reject_url,omitempty"`
+	Timestamp  int64  `json:"timestamp"`
+}
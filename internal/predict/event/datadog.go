@@ -0,0 +1,120 @@
+package event
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DatadogRegionUS = "US"
+	DatadogRegionEU = "EU"
+)
+
+var datadogEndpoints = map[string]string{
+	DatadogRegionUS: "https://api.datadoghq.com",
+	DatadogRegionEU: "https://api.datadoghq.eu",
+}
+
+//DatadogConfig Datadog Events API 配置
+type DatadogConfig struct {
+	APIKey string `json:"api_key"`
+	//Region 站点区域，US/EU，默认US
+	Region string `json:"region"`
+	//Tags 附加在每个事件上的标签
+	Tags []string `json:"tags"`
+	//Endpoint 自定义API地址，为空时根据Region选择
+	Endpoint string `json:"endpoint"`
+}
+
+//DatadogEventPublisher 将扩缩容事件发送到 Datadog Events API
+type DatadogEventPublisher struct {
+	apiKey     string
+	endpoint   string
+	tags       []string
+	httpClient *http.Client
+}
+
+type datadogEvent struct {
+	Title     string   `json:"title"`
+	Text      string   `json:"text"`
+	Tags      []string `json:"tags"`
+	AlertType string   `json:"alert_type"`
+}
+
+//NewDatadogEventPublisher 新建 DatadogEventPublisher
+func NewDatadogEventPublisher(config *DatadogConfig) (*DatadogEventPublisher, error) {
+	if config.APIKey == "" {
+		return nil, fmt.Errorf("datadog api key can not be empty")
+	}
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		region := strings.ToUpper(config.Region)
+		if region == "" {
+			region = DatadogRegionUS
+		}
+		var ok bool
+		endpoint, ok = datadogEndpoints[region]
+		if !ok {
+			return nil, fmt.Errorf("unknown datadog region : %s", config.Region)
+		}
+	}
+	return &DatadogEventPublisher{
+		apiKey:   config.APIKey,
+		endpoint: strings.TrimRight(endpoint, "/"),
+		tags:     config.Tags,
+		httpClient: &http.Client{
+			Timeout: 5000 * time.Millisecond,
+		},
+	}, nil
+}
+
+//Publish 实现 EventPublisher 接口
+func (p *DatadogEventPublisher) Publish(ctx context.Context, e *ScalingEvent) error {
+	body := datadogEvent{
+		Title: fmt.Sprintf("cudgx %s %s/%s", e.Action, e.ServiceName, e.ClusterName),
+		Text: fmt.Sprintf("%s %s/%s by %d instances, current instance count: %d, redundancy: %.2f",
+			e.Action, e.ServiceName, e.ClusterName, e.Count, e.InstanceCount, e.Redundancy),
+		Tags: append([]string{
+			"service:" + e.ServiceName,
+			"cluster:" + e.ClusterName,
+			"action:" + e.Action,
+			fmt.Sprintf("count:%d", e.Count),
+		}, p.tags...),
+		AlertType: datadogAlertType(e.Action),
+	}
+	data, err := json.Marshal(&body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+"/api/v1/events", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("DD-API-KEY", p.apiKey)
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respData, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("http code:%d | body:%s", resp.StatusCode, respData)
+	}
+	return nil
+}
+
+//datadogAlertType Datadog 不支持 critical，使用 error 代替
+func datadogAlertType(action string) string {
+	alertType := AlertType(action)
+	if alertType == AlertTypeCritical {
+		return "error"
+	}
+	return alertType
+}
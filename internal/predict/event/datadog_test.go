@@ -0,0 +1,69 @@
+package event_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("DatadogEventPublisher", func() {
+	var server *httptest.Server
+	var received map[string]interface{}
+	var apiKey, path string
+
+	ginkgo.BeforeEach(func() {
+		received = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiKey = r.Header.Get("DD-API-KEY")
+			path = r.URL.Path
+			data, _ := ioutil.ReadAll(r.Body)
+			_ = json.Unmarshal(data, &received)
+			w.WriteHeader(http.StatusAccepted)
+		}))
+	})
+
+	ginkgo.AfterEach(func() {
+		server.Close()
+	})
+
+	ginkgo.It("sends scale event", func() {
+		publisher, err := event.NewDatadogEventPublisher(&event.DatadogConfig{
+			APIKey:   "test-key",
+			Tags:     []string{"env:test"},
+			Endpoint: server.URL,
+		})
+		gomega.Expect(err).To(gomega.BeNil())
+		err = publisher.Publish(context.Background(), &event.ScalingEvent{
+			ServiceName: "gf.cudgx.pi",
+			ClusterName: "default",
+			Action:      event.ActionScaleUp,
+			Count:       3,
+		})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(apiKey).To(gomega.Equal("test-key"))
+		gomega.Expect(path).To(gomega.Equal("/api/v1/events"))
+		gomega.Expect(received["alert_type"]).To(gomega.Equal("info"))
+		gomega.Expect(received["title"]).To(gomega.ContainSubstring("gf.cudgx.pi/default"))
+		gomega.Expect(received["tags"]).To(gomega.ConsistOf("service:gf.cudgx.pi", "cluster:default",
+			"action:scale_up", "count:3", "env:test"))
+	})
+
+	ginkgo.It("uses warning for emergency scale", func() {
+		publisher, err := event.NewDatadogEventPublisher(&event.DatadogConfig{APIKey: "test-key", Endpoint: server.URL})
+		gomega.Expect(err).To(gomega.BeNil())
+		err = publisher.Publish(context.Background(), &event.ScalingEvent{Action: event.ActionEmergencyScaleUp})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(received["alert_type"]).To(gomega.Equal("warning"))
+	})
+
+	ginkgo.It("rejects unknown region", func() {
+		_, err := event.NewDatadogEventPublisher(&event.DatadogConfig{APIKey: "test-key", Region: "CN"})
+		gomega.Expect(err).NotTo(gomega.BeNil())
+	})
+})
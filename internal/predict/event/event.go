@@ -0,0 +1,122 @@
+package event
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	//ActionScaleUp 扩容
+	ActionScaleUp = "scale_up"
+	//ActionScaleDown 缩容
+	ActionScaleDown = "scale_down"
+	//ActionEmergencyScaleUp 紧急扩容
+	ActionEmergencyScaleUp = "emergency_scale_up"
+	//ActionRecoveryScaleUp 服务不可用时进入恢复模式，直接扩容到最大实例数
+	ActionRecoveryScaleUp = "recovery_scale_up"
+)
+
+const (
+	AlertTypeInfo     = "info"
+	AlertTypeWarning  = "warning"
+	AlertTypeCritical = "critical"
+)
+
+const (
+	defaultQueueSize      = 1024
+	defaultPublishTimeout = 5 * time.Second
+)
+
+//ScalingEvent 扩缩容事件
+type ScalingEvent struct {
+	RuleId      int64  `json:"rule_id"`
+	ServiceName string `json:"service_name"`
+	ClusterName string `json:"cluster_name"`
+	//Action 动作类型，参见 Action* 常量
+	Action string `json:"action"`
+	//Count 本次变更的实例数
+	Count int `json:"count"`
+	//InstanceCount 变更前的实例数
+	InstanceCount int `json:"instance_count"`
+	//Redundancy 触发本次变更的冗余度
+	Redundancy float64 `json:"redundancy"`
+	//EstimatedCostPerHour 本次变更带来的每小时成本变化，缩容为负数，未配置成本时为0
+	EstimatedCostPerHour float64 `json:"estimated_cost_per_hour"`
+	Timestamp            int64   `json:"timestamp"`
+}
+
+//EventPublisher 事件发布者，负责将扩缩容事件投递到外部系统
+type EventPublisher interface {
+	Publish(ctx context.Context, e *ScalingEvent) error
+}
+
+//AlertType 返回事件对应的告警级别
+func AlertType(action string) string {
+	switch action {
+	case ActionEmergencyScaleUp:
+		return AlertTypeWarning
+	case ActionRecoveryScaleUp:
+		return AlertTypeCritical
+	default:
+		return AlertTypeInfo
+	}
+}
+
+var dispatcher = newEventDispatcher(defaultQueueSize)
+
+type eventDispatcher struct {
+	publishers []EventPublisher
+	lock       sync.RWMutex
+	events     chan *ScalingEvent
+	once       sync.Once
+}
+
+func newEventDispatcher(queueSize int) *eventDispatcher {
+	return &eventDispatcher{
+		events: make(chan *ScalingEvent, queueSize),
+	}
+}
+
+//Register 注册事件发布者
+func Register(publisher EventPublisher) {
+	dispatcher.lock.Lock()
+	dispatcher.publishers = append(dispatcher.publishers, publisher)
+	dispatcher.lock.Unlock()
+	dispatcher.once.Do(func() {
+		go dispatcher.run()
+	})
+}
+
+//Publish 异步发布事件，队列已满时丢弃，不会阻塞调用方
+func Publish(e *ScalingEvent) {
+	if e.Timestamp == 0 {
+		e.Timestamp = time.Now().Unix()
+	}
+	select {
+	case dispatcher.events <- e:
+	default:
+		logger.GetLogger().Warn("event queue is full, event dropped", zap.String("service", e.ServiceName),
+			zap.String("cluster", e.ClusterName), zap.String("action", e.Action))
+	}
+}
+
+func (d *eventDispatcher) run() {
+	for e := range d.events {
+		d.lock.RLock()
+		publishers := d.publishers
+		d.lock.RUnlock()
+		for _, publisher := range publishers {
+			ctx, cancel := context.WithTimeout(context.Background(), defaultPublishTimeout)
+			err := publisher.Publish(ctx, e)
+			cancel()
+			if err != nil {
+				logger.GetLogger().Error("failed to publish event", zap.String("service", e.ServiceName),
+					zap.String("cluster", e.ClusterName), zap.String("action", e.Action), zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package event
+
+//ActionHighRedundancy 冗余度过高通知的 action
+const ActionHighRedundancy = "high_redundancy"
+
+//HighRedundancyEvent 服务集群的冗余度超过规则的 alert_on_high_redundancy，服务可能在紧急扩容后没有缩容
+type HighRedundancyEvent struct {
+	RuleId        int64   `json:"rule_id"`
+	ServiceName   string  `json:"service_name"`
+	ClusterName   string  `json:"cluster_name"`
+	Redundancy    float64 `json:"redundancy"`
+	Threshold     float64 `json:"threshold"`
+	InstanceCount int     `json:"instance_count"`
+	Timestamp     int64   `json:"timestamp"`
+}
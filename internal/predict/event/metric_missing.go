@@ -0,0 +1,14 @@
+package event
+
+//ActionMetricMissing 没有指标数据通知的 action
+const ActionMetricMissing = "metric_missing"
+
+//MetricMissingEvent 规则的 metric_missing_action 为 alert_only 时，回查窗口内没有查询到服务集群的指标数据
+type MetricMissingEvent struct {
+	RuleId        int64  `json:"rule_id"`
+	ServiceName   string `json:"service_name"`
+	ClusterName   string `json:"cluster_name"`
+	MetricName    string `json:"metric_name"`
+	InstanceCount int    `json:"instance_count"`
+	Timestamp     int64  `json:"timestamp"`
+}
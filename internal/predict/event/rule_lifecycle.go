@@ -0,0 +1,20 @@
+package event
+
+const (
+	//ActionRuleEnabled 规则启用通知的 action
+	ActionRuleEnabled = "rule_enabled"
+	//ActionRuleDeleted 规则删除通知的 action
+	ActionRuleDeleted = "rule_deleted"
+)
+
+//RuleLifecycleEvent 开启 notify_on_creation 的规则被启用，或开启 notify_on_deletion 的规则被删除
+type RuleLifecycleEvent struct {
+	//Action ActionRuleEnabled 或 ActionRuleDeleted
+	Action      string `json:"action"`
+	RuleId      int64  `json:"rule_id"`
+	ServiceName string `json:"service_name"`
+	ClusterName string `json:"cluster_name"`
+	//Rule 规则的完整配置，event 不依赖 model，由调用方传入
+	Rule      interface{} `json:"rule"`
+	Timestamp int64       `json:"timestamp"`
+}
@@ -0,0 +1,19 @@
+package event
+
+//ActionShrinkVerificationFailed 缩容校验失败通知的 action
+const ActionShrinkVerificationFailed = "shrink_verification_failed"
+
+//ShrinkVerificationFailedEvent schedulx 返回缩容成功，但 verify_shrink_after_seconds 之后实例数减少不到缩容数的一半，
+//可能是实例受保护策略限制没有被释放
+type ShrinkVerificationFailedEvent struct {
+	RuleId      int64  `json:"rule_id"`
+	ServiceName string `json:"service_name"`
+	ClusterName string `json:"cluster_name"`
+	//Count 请求缩容的实例数
+	Count int `json:"count"`
+	//InstanceCountBefore 缩容前的实例数
+	InstanceCountBefore int `json:"instance_count_before"`
+	//InstanceCountAfter 校验时查询到的实例数
+	InstanceCountAfter int   `json:"instance_count_after"`
+	Timestamp          int64 `json:"timestamp"`
+}
@@ -0,0 +1,206 @@
+package event
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//SlackConfig Slack incoming webhook 配置
+type SlackConfig struct {
+	WebhookURL string `json:"webhook_url"`
+	//Channel 覆盖 webhook 默认频道，可为空
+	Channel   string `json:"channel"`
+	Username  string `json:"username"`
+	IconEmoji string `json:"icon_emoji"`
+	//GrafanaBaseURL Grafana 看板地址，配置后消息中附带看板链接
+	GrafanaBaseURL string `json:"grafana_base_url"`
+}
+
+//SlackNotifier 将扩缩容事件以 Block Kit 消息发送到 Slack
+type SlackNotifier struct {
+	config     SlackConfig
+	httpClient *http.Client
+}
+
+type slackMessage struct {
+	Channel   string       `json:"channel,omitempty"`
+	Username  string       `json:"username,omitempty"`
+	IconEmoji string       `json:"icon_emoji,omitempty"`
+	Text      string       `json:"text"`
+	Blocks    []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type   string       `json:"type"`
+	Text   *slackText   `json:"text,omitempty"`
+	Fields []*slackText `json:"fields,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+//NewSlackNotifier 新建 SlackNotifier
+func NewSlackNotifier(config *SlackConfig) (*SlackNotifier, error) {
+	if config.WebhookURL == "" {
+		return nil, fmt.Errorf("slack webhook url can not be empty")
+	}
+	return &SlackNotifier{
+		config: *config,
+		httpClient: &http.Client{
+			Timeout: 5000 * time.Millisecond,
+		},
+	}, nil
+}
+
+//Publish 实现 EventPublisher 接口
+func (n *SlackNotifier) Publish(ctx context.Context, e *ScalingEvent) error {
+	return n.post(ctx, n.buildMessage(e))
+}
+
+//NotifyApprovalRequested 通知扩缩容等待人工确认，消息中附带确认和拒绝接口的地址
+func (n *SlackNotifier) NotifyApprovalRequested(ctx context.Context, e *ApprovalRequestedEvent) error {
+	return n.post(ctx, n.buildApprovalMessage(e))
+}
+
+//NotifyRuleLifecycle 通知规则被启用或删除，Slack 消息只包含规则的服务集群，完整配置见 webhook 通知
+func (n *SlackNotifier) NotifyRuleLifecycle(ctx context.Context, e *RuleLifecycleEvent) error {
+	return n.post(ctx, n.buildRuleLifecycleMessage(e))
+}
+
+func (n *SlackNotifier) post(ctx context.Context, message *slackMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.config.WebhookURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respData, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("http code:%d | body:%s", resp.StatusCode, respData)
+	}
+	return nil
+}
+
+func (n *SlackNotifier) buildMessage(e *ScalingEvent) *slackMessage {
+	title := fmt.Sprintf("cudgx %s %s/%s", e.Action, e.ServiceName, e.ClusterName)
+	blocks := []slackBlock{
+		{
+			Type: "header",
+			Text: &slackText{Type: "plain_text", Text: title},
+		},
+		{
+			Type: "section",
+			Fields: []*slackText{
+				{Type: "mrkdwn", Text: "*Service*\n" + e.ServiceName},
+				{Type: "mrkdwn", Text: "*Cluster*\n" + e.ClusterName},
+				{Type: "mrkdwn", Text: "*Action*\n" + e.Action},
+				{Type: "mrkdwn", Text: fmt.Sprintf("*Count*\n%d", e.Count)},
+				{Type: "mrkdwn", Text: fmt.Sprintf("*Instance Count*\n%d", e.InstanceCount)},
+				{Type: "mrkdwn", Text: fmt.Sprintf("*Redundancy*\n%.2f", e.Redundancy)},
+			},
+		},
+	}
+	if n.config.GrafanaBaseURL != "" {
+		blocks = append(blocks, slackBlock{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: fmt.Sprintf("<%s|Grafana dashboard>", n.grafanaLink(e))},
+		})
+	}
+	return &slackMessage{
+		Channel:   n.config.Channel,
+		Username:  n.config.Username,
+		IconEmoji: n.config.IconEmoji,
+		Text:      title,
+		Blocks:    blocks,
+	}
+}
+
+func (n *SlackNotifier) buildApprovalMessage(e *ApprovalRequestedEvent) *slackMessage {
+	title := fmt.Sprintf("cudgx %s of %d instances on %s/%s needs approval", e.ScaleAction, e.Count, e.ServiceName, e.ClusterName)
+	blocks := []slackBlock{
+		{
+			Type: "header",
+			Text: &slackText{Type: "plain_text", Text: title},
+		},
+		{
+			Type: "section",
+			Fields: []*slackText{
+				{Type: "mrkdwn", Text: fmt.Sprintf("*Approval*\n%d", e.ApprovalId)},
+				{Type: "mrkdwn", Text: "*Action*\n" + e.ScaleAction},
+				{Type: "mrkdwn", Text: fmt.Sprintf("*Count*\n%d", e.Count)},
+				{Type: "mrkdwn", Text: fmt.Sprintf("*Instance Count*\n%d", e.InstanceCount)},
+				{Type: "mrkdwn", Text: fmt.Sprintf("*Redundancy*\n%.2f", e.Redundancy)},
+				{Type: "mrkdwn", Text: "*Expire At*\n" + time.Unix(e.ExpireAt, 0).Format(time.RFC3339)},
+			},
+		},
+	}
+	if e.ApproveURL != "" && e.RejectURL != "" {
+		blocks = append(blocks, slackBlock{
+			Type: "section",
+			// 确认接口只接受带认证 token 的 POST，不能作为链接直接打开
+			Text: &slackText{Type: "mrkdwn", Text: fmt.Sprintf("Approve or reject with an authenticated POST to `%s` or `%s`", e.ApproveURL, e.RejectURL)},
+		})
+	}
+	return &slackMessage{
+		Channel:   n.config.Channel,
+		Username:  n.config.Username,
+		IconEmoji: n.config.IconEmoji,
+		Text:      title,
+		Blocks:    blocks,
+	}
+}
+
+func (n *SlackNotifier) buildRuleLifecycleMessage(e *RuleLifecycleEvent) *slackMessage {
+	title := fmt.Sprintf("cudgx %s %s/%s", e.Action, e.ServiceName, e.ClusterName)
+	blocks := []slackBlock{
+		{
+			Type: "header",
+			Text: &slackText{Type: "plain_text", Text: title},
+		},
+		{
+			Type: "section",
+			Fields: []*slackText{
+				{Type: "mrkdwn", Text: fmt.Sprintf("*Rule*\n%d", e.RuleId)},
+				{Type: "mrkdwn", Text: "*Action*\n" + e.Action},
+				{Type: "mrkdwn", Text: "*Service*\n" + e.ServiceName},
+				{Type: "mrkdwn", Text: "*Cluster*\n" + e.ClusterName},
+			},
+		},
+	}
+	return &slackMessage{
+		Channel:   n.config.Channel,
+		Username:  n.config.Username,
+		IconEmoji: n.config.IconEmoji,
+		Text:      title,
+		Blocks:    blocks,
+	}
+}
+
+//grafanaLink 看板链接，通过 var-service/var-cluster 变量定位到服务集群
+func (n *SlackNotifier) grafanaLink(e *ScalingEvent) string {
+	params := url.Values{}
+	params.Set("var-service", e.ServiceName)
+	params.Set("var-cluster", e.ClusterName)
+	separator := "?"
+	if strings.Contains(n.config.GrafanaBaseURL, "?") {
+		separator = "&"
+	}
+	return n.config.GrafanaBaseURL + separator + params.Encode()
+}
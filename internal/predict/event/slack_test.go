@@ -0,0 +1,93 @@
+package event_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("SlackNotifier", func() {
+	var server *httptest.Server
+	var received map[string]interface{}
+	var statusCode int
+
+	ginkgo.BeforeEach(func() {
+		received = nil
+		statusCode = http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, _ := ioutil.ReadAll(r.Body)
+			_ = json.Unmarshal(data, &received)
+			w.WriteHeader(statusCode)
+		}))
+	})
+
+	ginkgo.AfterEach(func() {
+		server.Close()
+	})
+
+	scaleEvent := &event.ScalingEvent{
+		ServiceName:   "gf.cudgx.pi",
+		ClusterName:   "default",
+		Action:        event.ActionScaleUp,
+		Count:         3,
+		InstanceCount: 10,
+		Redundancy:    1.25,
+	}
+
+	ginkgo.It("sends a block kit message", func() {
+		notifier, err := event.NewSlackNotifier(&event.SlackConfig{
+			WebhookURL:     server.URL,
+			Channel:        "#cudgx",
+			Username:       "cudgx",
+			IconEmoji:      ":rocket:",
+			GrafanaBaseURL: "https://grafana.example.com/d/cudgx",
+		})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(notifier.Publish(context.Background(), scaleEvent)).To(gomega.BeNil())
+
+		gomega.Expect(received["channel"]).To(gomega.Equal("#cudgx"))
+		gomega.Expect(received["username"]).To(gomega.Equal("cudgx"))
+		gomega.Expect(received["icon_emoji"]).To(gomega.Equal(":rocket:"))
+		blocks := received["blocks"].([]interface{})
+		gomega.Expect(blocks).To(gomega.HaveLen(3))
+		data, _ := json.Marshal(blocks)
+		gomega.Expect(string(data)).To(gomega.ContainSubstring("gf.cudgx.pi"))
+		gomega.Expect(string(data)).To(gomega.ContainSubstring("*Redundancy*\\n1.25"))
+		gomega.Expect(string(data)).To(gomega.ContainSubstring("https://grafana.example.com/d/cudgx?var-cluster=default\\u0026var-service=gf.cudgx.pi"))
+	})
+
+	ginkgo.It("omits the dashboard link without grafana", func() {
+		notifier, err := event.NewSlackNotifier(&event.SlackConfig{WebhookURL: server.URL})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(notifier.Publish(context.Background(), scaleEvent)).To(gomega.BeNil())
+		gomega.Expect(received).NotTo(gomega.HaveKey("channel"))
+		gomega.Expect(received["blocks"]).To(gomega.HaveLen(2))
+	})
+
+	ginkgo.It("sends rule lifecycle notifications", func() {
+		notifier, err := event.NewSlackNotifier(&event.SlackConfig{WebhookURL: server.URL})
+		gomega.Expect(err).To(gomega.BeNil())
+		e := &event.RuleLifecycleEvent{Action: event.ActionRuleEnabled, RuleId: 7, ServiceName: "gf.cudgx.pi", ClusterName: "default"}
+		gomega.Expect(notifier.NotifyRuleLifecycle(context.Background(), e)).To(gomega.BeNil())
+		gomega.Expect(received["text"]).To(gomega.Equal("cudgx rule_enabled gf.cudgx.pi/default"))
+		gomega.Expect(received["blocks"]).To(gomega.HaveLen(2))
+	})
+
+	ginkgo.It("returns an error when slack rejects the message", func() {
+		statusCode = http.StatusBadRequest
+		notifier, err := event.NewSlackNotifier(&event.SlackConfig{WebhookURL: server.URL})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(notifier.Publish(context.Background(), scaleEvent)).NotTo(gomega.BeNil())
+	})
+
+	ginkgo.It("requires a webhook url", func() {
+		_, err := event.NewSlackNotifier(&event.SlackConfig{})
+		gomega.Expect(err).NotTo(gomega.BeNil())
+	})
+})
@@ -0,0 +1,163 @@
+package event
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+//WebhookConfig 通用webhook配置
+type WebhookConfig struct {
+	URL string `json:"url"`
+}
+
+//WebhookPublisher 将扩缩容事件以JSON形式POST到指定URL
+type WebhookPublisher struct {
+	url        string
+	httpClient *http.Client
+}
+
+type webhookPayload struct {
+	*ScalingEvent
+	AlertType string `json:"alert_type"`
+}
+
+//RuleDiscoveredEvent 服务自动发现为新的服务集群创建了规则
+type RuleDiscoveredEvent struct {
+	RuleId      int64  `json:"rule_id"`
+	ServiceName string `json:"service_name"`
+	ClusterName string `json:"cluster_name"`
+	//TemplateRuleId 创建规则时使用的模板规则
+	TemplateRuleId int64 `json:"template_rule_id"`
+	Timestamp      int64 `json:"timestamp"`
+}
+
+//ActionRuleDiscovered 自动发现规则通知的 action
+const ActionRuleDiscovered = "rule_discovered"
+
+type ruleDiscoveredPayload struct {
+	*RuleDiscoveredEvent
+	Action    string `json:"action"`
+	AlertType string `json:"alert_type"`
+}
+
+//NewWebhookPublisher 新建 WebhookPublisher
+func NewWebhookPublisher(config *WebhookConfig) (*WebhookPublisher, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("webhook url can not be empty")
+	}
+	return &WebhookPublisher{
+		url: config.URL,
+		httpClient: &http.Client{
+			Timeout: 5000 * time.Millisecond,
+		},
+	}, nil
+}
+
+//Publish 实现 EventPublisher 接口
+func (p *WebhookPublisher) Publish(ctx context.Context, e *ScalingEvent) error {
+	return p.post(ctx, &webhookPayload{ScalingEvent: e, AlertType: AlertType(e.Action)})
+}
+
+//NotifyRuleDiscovered 通知服务自动发现创建了新规则
+func (p *WebhookPublisher) NotifyRuleDiscovered(ctx context.Context, e *RuleDiscoveredEvent) error {
+	if e.Timestamp == 0 {
+		e.Timestamp = time.Now().Unix()
+	}
+	return p.post(ctx, &ruleDiscoveredPayload{RuleDiscoveredEvent: e, Action: ActionRuleDiscovered, AlertType: AlertTypeInfo})
+}
+
+type approvalRequestedPayload struct {
+	*ApprovalRequestedEvent
+	Action    string `json:"action"`
+	AlertType string `json:"alert_type"`
+}
+
+//NotifyApprovalRequested 通知扩缩容等待人工确认
+func (p *WebhookPublisher) NotifyApprovalRequested(ctx context.Context, e *ApprovalRequestedEvent) error {
+	if e.Timestamp == 0 {
+		e.Timestamp = time.Now().Unix()
+	}
+	return p.post(ctx, &approvalRequestedPayload{ApprovalRequestedEvent: e, Action: ActionApprovalRequested, AlertType: AlertTypeWarning})
+}
+
+type highRedundancyPayload struct {
+	*HighRedundancyEvent
+	Action    string `json:"action"`
+	AlertType string `json:"alert_type"`
+}
+
+//NotifyHighRedundancy 通知服务集群的冗余度过高
+func (p *WebhookPublisher) NotifyHighRedundancy(ctx context.Context, e *HighRedundancyEvent) error {
+	if e.Timestamp == 0 {
+		e.Timestamp = time.Now().Unix()
+	}
+	return p.post(ctx, &highRedundancyPayload{HighRedundancyEvent: e, Action: ActionHighRedundancy, AlertType: AlertTypeWarning})
+}
+
+type metricMissingPayload struct {
+	*MetricMissingEvent
+	Action    string `json:"action"`
+	AlertType string `json:"alert_type"`
+}
+
+//NotifyMetricMissing 通知服务集群没有指标数据
+func (p *WebhookPublisher) NotifyMetricMissing(ctx context.Context, e *MetricMissingEvent) error {
+	if e.Timestamp == 0 {
+		e.Timestamp = time.Now().Unix()
+	}
+	return p.post(ctx, &metricMissingPayload{MetricMissingEvent: e, Action: ActionMetricMissing, AlertType: AlertTypeWarning})
+}
+
+type shrinkVerificationFailedPayload struct {
+	*ShrinkVerificationFailedEvent
+	Action    string `json:"action"`
+	AlertType string `json:"alert_type"`
+}
+
+//NotifyShrinkVerificationFailed 通知缩容成功后实例数没有减少
+func (p *WebhookPublisher) NotifyShrinkVerificationFailed(ctx context.Context, e *ShrinkVerificationFailedEvent) error {
+	if e.Timestamp == 0 {
+		e.Timestamp = time.Now().Unix()
+	}
+	return p.post(ctx, &shrinkVerificationFailedPayload{ShrinkVerificationFailedEvent: e, Action: ActionShrinkVerificationFailed, AlertType: AlertTypeWarning})
+}
+
+type ruleLifecyclePayload struct {
+	*RuleLifecycleEvent
+	AlertType string `json:"alert_type"`
+}
+
+//NotifyRuleLifecycle 通知规则被启用或删除，payload 中附带规则的完整配置
+func (p *WebhookPublisher) NotifyRuleLifecycle(ctx context.Context, e *RuleLifecycleEvent) error {
+	if e.Timestamp == 0 {
+		e.Timestamp = time.Now().Unix()
+	}
+	return p.post(ctx, &ruleLifecyclePayload{RuleLifecycleEvent: e, AlertType: AlertTypeInfo})
+}
+
+func (p *WebhookPublisher) post(ctx context.Context, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respData, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("http code:%d | body:%s", resp.StatusCode, respData)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package event_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("WebhookPublisher", func() {
+	var server *httptest.Server
+	var received map[string]interface{}
+
+	ginkgo.BeforeEach(func() {
+		received = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, _ := ioutil.ReadAll(r.Body)
+			_ = json.Unmarshal(data, &received)
+		}))
+	})
+
+	ginkgo.AfterEach(func() {
+		server.Close()
+	})
+
+	ginkgo.It("posts discovered rules", func() {
+		publisher, err := event.NewWebhookPublisher(&event.WebhookConfig{URL: server.URL})
+		gomega.Expect(err).To(gomega.BeNil())
+		err = publisher.NotifyRuleDiscovered(context.Background(), &event.RuleDiscoveredEvent{
+			RuleId:         7,
+			ServiceName:    "gf.cudgx.new",
+			ClusterName:    "default",
+			TemplateRuleId: 1,
+		})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(received["action"]).To(gomega.Equal(event.ActionRuleDiscovered))
+		gomega.Expect(received["alert_type"]).To(gomega.Equal(event.AlertTypeInfo))
+		gomega.Expect(received["rule_id"]).To(gomega.BeNumerically("==", 7))
+		gomega.Expect(received["service_name"]).To(gomega.Equal("gf.cudgx.new"))
+		gomega.Expect(received["template_rule_id"]).To(gomega.BeNumerically("==", 1))
+		gomega.Expect(received["timestamp"]).To(gomega.BeNumerically(">", 0))
+	})
+
+	ginkgo.It("posts approval requests", func() {
+		publisher, err := event.NewWebhookPublisher(&event.WebhookConfig{URL: server.URL})
+		gomega.Expect(err).To(gomega.BeNil())
+		err = publisher.NotifyApprovalRequested(context.Background(), &event.ApprovalRequestedEvent{
+			ApprovalId:  3,
+			RuleId:      7,
+			ScaleAction: event.ActionScaleUp,
+			Count:       25,
+			ApproveURL:  "http://cudgx-api/api/v1/cudgx/approvals/3/approve",
+		})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(received["action"]).To(gomega.Equal(event.ActionApprovalRequested))
+		gomega.Expect(received["alert_type"]).To(gomega.Equal(event.AlertTypeWarning))
+		gomega.Expect(received["scale_action"]).To(gomega.Equal(event.ActionScaleUp))
+		gomega.Expect(received["approve_url"]).To(gomega.Equal("http://cudgx-api/api/v1/cudgx/approvals/3/approve"))
+	})
+
+	ginkgo.It("posts high redundancy alerts", func() {
+		publisher, err := event.NewWebhookPublisher(&event.WebhookConfig{URL: server.URL})
+		gomega.Expect(err).To(gomega.BeNil())
+		err = publisher.NotifyHighRedundancy(context.Background(), &event.HighRedundancyEvent{
+			RuleId:     7,
+			Redundancy: 3.5,
+			Threshold:  3,
+		})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(received["action"]).To(gomega.Equal(event.ActionHighRedundancy))
+		gomega.Expect(received["alert_type"]).To(gomega.Equal(event.AlertTypeWarning))
+		gomega.Expect(received["redundancy"]).To(gomega.BeNumerically("==", 3.5))
+		gomega.Expect(received["timestamp"]).To(gomega.BeNumerically(">", 0))
+	})
+
+	ginkgo.It("posts metric missing alerts", func() {
+		publisher, err := event.NewWebhookPublisher(&event.WebhookConfig{URL: server.URL})
+		gomega.Expect(err).To(gomega.BeNil())
+		err = publisher.NotifyMetricMissing(context.Background(), &event.MetricMissingEvent{RuleId: 7, MetricName: "qps"})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(received["action"]).To(gomega.Equal(event.ActionMetricMissing))
+		gomega.Expect(received["alert_type"]).To(gomega.Equal(event.AlertTypeWarning))
+		gomega.Expect(received["metric_name"]).To(gomega.Equal("qps"))
+		gomega.Expect(received["timestamp"]).To(gomega.BeNumerically(">", 0))
+	})
+
+	ginkgo.It("posts shrink verification failures", func() {
+		publisher, err := event.NewWebhookPublisher(&event.WebhookConfig{URL: server.URL})
+		gomega.Expect(err).To(gomega.BeNil())
+		err = publisher.NotifyShrinkVerificationFailed(context.Background(), &event.ShrinkVerificationFailedEvent{RuleId: 7, Count: 4, InstanceCountBefore: 10, InstanceCountAfter: 10})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(received["action"]).To(gomega.Equal(event.ActionShrinkVerificationFailed))
+		gomega.Expect(received["alert_type"]).To(gomega.Equal(event.AlertTypeWarning))
+		gomega.Expect(received["instance_count_after"]).To(gomega.Equal(float64(10)))
+		gomega.Expect(received["timestamp"]).To(gomega.BeNumerically(">", 0))
+	})
+
+	ginkgo.It("posts rule lifecycle notifications with the rule config", func() {
+		publisher, err := event.NewWebhookPublisher(&event.WebhookConfig{URL: server.URL})
+		gomega.Expect(err).To(gomega.BeNil())
+		err = publisher.NotifyRuleLifecycle(context.Background(), &event.RuleLifecycleEvent{
+			Action:      event.ActionRuleDeleted,
+			RuleId:      7,
+			ServiceName: "gf.cudgx.pi",
+			Rule:        map[string]interface{}{"min_redundancy": 150},
+		})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(received["action"]).To(gomega.Equal(event.ActionRuleDeleted))
+		gomega.Expect(received["alert_type"]).To(gomega.Equal(event.AlertTypeInfo))
+		gomega.Expect(received["rule"]).To(gomega.Equal(map[string]interface{}{"min_redundancy": float64(150)}))
+		gomega.Expect(received["timestamp"]).To(gomega.BeNumerically(">", 0))
+	})
+})
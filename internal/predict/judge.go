@@ -2,13 +2,22 @@ package predict
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	"github.com/galaxy-future/cudgx/common/logger"
 	"github.com/galaxy-future/cudgx/common/types"
 	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/benchmark"
 	"github.com/galaxy-future/cudgx/internal/predict/config"
 	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/discovery"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
 	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/galaxy-future/cudgx/internal/predict/tracing"
+	"go.uber.org/zap"
 )
 
 var predictor *Predictor
@@ -23,35 +32,217 @@ func InitializeByConfig(theConfig *config.Config) error {
 	if theConfig.Predict == nil {
 		theConfig.Predict = &config.Param{}
 	}
-	if theConfig.Predict.MinimalSampleCount == 0 {
-		theConfig.Predict.MinimalSampleCount = consts.DefaultPredictMinCount
-	}
-	if theConfig.Predict.RunDuration.Duration == 0 {
-		theConfig.Predict.RunDuration = types.Duration{Duration: 60 * time.Second}
-	}
-	if theConfig.Predict.RuleConcurrency == 0 {
-		theConfig.Predict.RuleConcurrency = consts.DefaultRuleConcurrency
-	}
-	if theConfig.Predict.LookbackDuration.Duration == 0 {
-		theConfig.Predict.LookbackDuration = types.Duration{Duration: time.Minute}
+	if err := normalizeParam(theConfig.Predict); err != nil {
+		return err
 	}
-	if theConfig.Predict.MetricSendDuration.Duration == 0 {
-		theConfig.Predict.MetricSendDuration = types.Duration{Duration: 5 * time.Second}
+	if err := ValidateParam(theConfig.Predict); err != nil {
+		logger.GetLogger().Fatal("invalid predict param", zap.Error(err))
 	}
 
 	predictor = &Predictor{
 		config: theConfig.Predict,
 	}
-	err := clients.InitDBClient(theConfig.Database)
+	err := clients.InitDBClient(theConfig.Database, clients.DBPoolConfig{
+		MaxOpenConns:    theConfig.Predict.DBMaxOpenConns,
+		MaxIdleConns:    theConfig.Predict.DBMaxIdleConns,
+		ConnMaxLifetime: theConfig.Predict.DBConnMaxLifetime.Duration,
+		ConnMaxIdleTime: theConfig.Predict.DBConnMaxIdleTime.Duration,
+	})
 	if err != nil {
 		return err
 	}
 	clients.InitializeBridgxClient(theConfig.Xclient.BridgxServerAddress)
-	clients.InitializeSchedulxClient(theConfig.Xclient.SchedulxServerAddress)
-	redundancy_keeper.InitRedundancyKeeper(theConfig.Predict)
+	schedulxOpts := []clients.ClientOption{
+		clients.WithExpandTimeout(time.Duration(theConfig.Predict.ExpandTimeoutMs) * time.Millisecond),
+		clients.WithShrinkTimeout(time.Duration(theConfig.Predict.ShrinkTimeoutMs) * time.Millisecond),
+	}
+	if len(theConfig.Predict.EndpointTimeoutsMs) > 0 {
+		endpointTimeouts := make(map[string]time.Duration, len(theConfig.Predict.EndpointTimeoutsMs))
+		for prefix, timeoutMs := range theConfig.Predict.EndpointTimeoutsMs {
+			endpointTimeouts[prefix] = time.Duration(timeoutMs) * time.Millisecond
+		}
+		schedulxOpts = append(schedulxOpts, clients.WithEndpointTimeouts(endpointTimeouts))
+	}
+	if socketPath := theConfig.Xclient.SPIFFESocketPath; socketPath != "" {
+		if err := clients.InitializeSchedulxClientWithSPIFFE(theConfig.Xclient.SchedulxServerAddress, socketPath, schedulxOpts...); err != nil {
+			return err
+		}
+	} else {
+		clients.InitializeSchedulxClient(theConfig.Xclient.SchedulxServerAddress, schedulxOpts...)
+	}
+	if err := clients.InitializeK8sClient(theConfig.Xclient.K8sAPIServerAddress, theConfig.Xclient.K8sBearerTokenFile, theConfig.Xclient.K8sCAFile); err != nil {
+		return err
+	}
+	if err := clients.SetNamePatterns(theConfig.Predict.AllowedServicePattern, theConfig.Predict.AllowedClusterPattern); err != nil {
+		return err
+	}
+	logger.GetLogger().Info("allowed name patterns", zap.String("service", theConfig.Predict.AllowedServicePattern),
+		zap.String("cluster", theConfig.Predict.AllowedClusterPattern))
+	// 扩缩容事件总是保存到数据库，用于生成历史冗余度报告
+	event.Register(model.NewScalingEventPublisher())
+	if theConfig.Datadog != nil {
+		publisher, err := event.NewDatadogEventPublisher(theConfig.Datadog)
+		if err != nil {
+			return err
+		}
+		event.Register(publisher)
+	}
+	var webhookPublisher *event.WebhookPublisher
+	if theConfig.Webhook != nil {
+		webhookPublisher, err = event.NewWebhookPublisher(theConfig.Webhook)
+		if err != nil {
+			return err
+		}
+		event.Register(webhookPublisher)
+	}
+	var opts []redundancy_keeper.Option
+	if webhookPublisher != nil {
+		opts = append(opts, redundancy_keeper.WithApprovalNotifier(webhookPublisher))
+		opts = append(opts, redundancy_keeper.WithHighRedundancyNotifier(webhookPublisher))
+		opts = append(opts, redundancy_keeper.WithMetricMissingNotifier(webhookPublisher))
+		opts = append(opts, redundancy_keeper.WithShrinkVerificationNotifier(webhookPublisher))
+		service.AddRuleLifecycleNotifier(webhookPublisher)
+	}
+	if theConfig.Slack != nil {
+		notifier, err := event.NewSlackNotifier(theConfig.Slack)
+		if err != nil {
+			return err
+		}
+		event.Register(notifier)
+		opts = append(opts, redundancy_keeper.WithApprovalNotifier(notifier))
+		service.AddRuleLifecycleNotifier(notifier)
+	}
+	if theConfig.Cost != nil {
+		opts = append(opts, redundancy_keeper.WithCostEstimator(redundancy_keeper.NewStaticCostEstimator(theConfig.Cost.CostPerInstanceHour)))
+	}
+	if theConfig.Tracing != nil {
+		provider, err := tracing.NewTracerProviderByConfig(theConfig.Tracing)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, redundancy_keeper.WithTracerProvider(provider))
+	}
+	if err := redundancy_keeper.InitRedundancyKeeper(theConfig.Predict, opts...); err != nil {
+		return err
+	}
+	if theConfig.ServiceDiscovery != nil {
+		var discoveryOpts []discovery.Option
+		if webhookPublisher != nil {
+			discoveryOpts = append(discoveryOpts, discovery.WithNotifier(webhookPublisher))
+		}
+		if err := discovery.InitServiceDiscovery(theConfig.ServiceDiscovery, discoveryOpts...); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func StartRedundancyKeeper(ctx context.Context) {
-	redundancy_keeper.Start(ctx)
+//StartRedundancyKeeper 启动冗余度调度，run_once 模式下执行一轮后返回本轮的统计
+func StartRedundancyKeeper(ctx context.Context) *redundancy_keeper.ScheduleSummary {
+	return redundancy_keeper.Start(ctx)
+}
+
+//StartApprovalExecutor 定期执行人工确认后的扩缩容，直到 ctx 结束
+func StartApprovalExecutor(ctx context.Context) {
+	redundancy_keeper.StartApprovalExecutor(ctx)
+}
+
+//StartScalingEventPruner 按 prune_interval 周期清理超过 metrics_retention_days 的扩缩容事件，直到 ctx 结束
+func StartScalingEventPruner(ctx context.Context) {
+	redundancy_keeper.StartScalingEventPruner(ctx)
+}
+
+//StartBenchmarkLearner 按 benchmark_learn_interval 周期学习开启 benchmark_auto_learn 的规则的 benchmark_qps，直到 ctx 结束
+func StartBenchmarkLearner(ctx context.Context) {
+	benchmark.NewBenchmarkLearner(predictor.config.BenchmarkLearnInterval.Duration, nil).Start(ctx)
+}
+
+//StartServiceDiscovery 按 service_discovery 配置定期为新服务集群自动创建规则，未配置时直接返回
+func StartServiceDiscovery(ctx context.Context) {
+	discovery.Start(ctx)
+}
+
+//normalizeParam 校验调度参数并填充默认值
+func normalizeParam(param *config.Param) error {
+	if param.RunDuration.Duration < 0 || param.LookbackDuration.Duration < 0 || param.MetricSendDuration.Duration < 0 || param.MetricQueryTimeout.Duration < 0 || param.ScheduleCacheTTL.Duration < 0 ||
+		param.MinScheduleDuration.Duration < 0 || param.MaxScheduleDuration.Duration < 0 || param.ProfileDuration.Duration < 0 ||
+		param.BenchmarkLearnInterval.Duration < 0 || param.PruneInterval.Duration < 0 {
+		return fmt.Errorf("durations can not be negative")
+	}
+	if param.RuleConcurrency < 0 || param.RuleConcurrencyPerService < 0 || param.MinimalSampleCount < 0 || param.LivenessThresholdMultiplier < 0 ||
+		param.ErrorThresholdForDisable < 0 || param.MaxWatchConnections < 0 || param.MetricsRetentionDays < 0 {
+		return fmt.Errorf("rule concurrency, minimal sample count, liveness threshold multiplier, error threshold, max watch connections and metrics retention days can not be negative")
+	}
+	if param.ExpandTimeoutMs < 0 || param.ShrinkTimeoutMs < 0 || param.ApprovalTimeoutMinutes < 0 {
+		return fmt.Errorf("expand timeout, shrink timeout and approval timeout can not be negative")
+	}
+	if param.ApprovalTimeoutMinutes == 0 {
+		param.ApprovalTimeoutMinutes = consts.DefaultApprovalTimeoutMinutes
+	}
+	if param.ExpandTimeoutMs == 0 {
+		param.ExpandTimeoutMs = consts.DefaultSchedulxTimeoutMs
+	}
+	if param.ShrinkTimeoutMs == 0 {
+		param.ShrinkTimeoutMs = consts.DefaultSchedulxTimeoutMs
+	}
+	if param.MinimalSampleCount == 0 {
+		param.MinimalSampleCount = consts.DefaultPredictMinCount
+	}
+	if param.RunDuration.Duration == 0 {
+		param.RunDuration = types.Duration{Duration: 60 * time.Second}
+	}
+	if param.RuleConcurrency == 0 {
+		param.RuleConcurrency = consts.DefaultRuleConcurrency
+	}
+	if param.LookbackDuration.Duration == 0 {
+		param.LookbackDuration = types.Duration{Duration: consts.DefaultLookbackDuration}
+	}
+	if param.MetricSendDuration.Duration == 0 {
+		param.MetricSendDuration = types.Duration{Duration: consts.DefaultMetricSendDuration}
+	}
+	if param.MetricQueryTimeout.Duration == 0 {
+		param.MetricQueryTimeout = types.Duration{Duration: consts.DefaultMetricQueryTimeout}
+	}
+	if param.MaxWatchConnections == 0 {
+		param.MaxWatchConnections = consts.DefaultMaxWatchConnections
+	}
+	if param.PruneInterval.Duration == 0 {
+		param.PruneInterval = types.Duration{Duration: consts.DefaultPruneInterval}
+	}
+	if param.BenchmarkLearnInterval.Duration == 0 {
+		param.BenchmarkLearnInterval = types.Duration{Duration: consts.DefaultBenchmarkLearnInterval}
+	}
+	if param.ProfileOutputPath != "" && param.ProfileDuration.Duration == 0 {
+		param.ProfileDuration = types.Duration{Duration: consts.DefaultProfileDuration}
+	}
+	if param.LivenessThresholdMultiplier == 0 {
+		param.LivenessThresholdMultiplier = consts.DefaultLivenessThresholdMultiplier
+	}
+	if param.BackoffScheduleDuration {
+		if param.MinScheduleDuration.Duration == 0 {
+			param.MinScheduleDuration = param.RunDuration
+		}
+		if param.MaxScheduleDuration.Duration == 0 {
+			param.MaxScheduleDuration = types.Duration{Duration: param.RunDuration.Duration * consts.DefaultMaxScheduleDurationMultiplier}
+		}
+		if param.MinScheduleDuration.Duration > param.RunDuration.Duration || param.RunDuration.Duration > param.MaxScheduleDuration.Duration {
+			return fmt.Errorf("run duration %s should be between min schedule duration %s and max schedule duration %s",
+				param.RunDuration.Duration, param.MinScheduleDuration.Duration, param.MaxScheduleDuration.Duration)
+		}
+	}
+	switch param.Backend {
+	case "":
+		param.Backend = consts.MetricBackendPrometheus
+	case consts.MetricBackendPrometheus, consts.MetricBackendVictoriaMetrics:
+	default:
+		return fmt.Errorf("unknown metric backend : %s", param.Backend)
+	}
+	switch param.OutlierRemovalMethod {
+	case "":
+		param.OutlierRemovalMethod = consts.OutlierRemovalNone
+	case consts.OutlierRemovalNone, consts.OutlierRemovalIQR, consts.OutlierRemovalZScore:
+	default:
+		return fmt.Errorf("unknown outlier removal method : %s", param.OutlierRemovalMethod)
+	}
+	return nil
 }
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+//InstanceCountMultiplier 定时放大规则的最小、最大实例数，StartCron 和 EndCron 为5段的 cron 表达式，
+//下一次 EndCron 早于下一次 StartCron 时视为处于该时间段内；倍数为0时不修改对应的实例数
+type InstanceCountMultiplier struct {
+	StartCron     string  `json:"start_cron"`
+	EndCron       string  `json:"end_cron"`
+	MinMultiplier float64 `json:"min_multiplier"`
+	MaxMultiplier float64 `json:"max_multiplier"`
+}
+
+//InstanceCountMultipliers 以 JSON 保存在数据库中
+type InstanceCountMultipliers []InstanceCountMultiplier
+
+func (multipliers InstanceCountMultipliers) Value() (driver.Value, error) {
+	if len(multipliers) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(multipliers)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (multipliers *InstanceCountMultipliers) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*multipliers = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported instance count multipliers type %T", value)
+	}
+	return json.Unmarshal(data, multipliers)
+}
+
+//validate 检查 cron 表达式和倍数
+func (multipliers InstanceCountMultipliers) validate() error {
+	for i, multiplier := range multipliers {
+		if _, err := cron.ParseStandard(multiplier.StartCron); err != nil {
+			return fmt.Errorf("第%d个定时实例数倍数的 start_cron %q 不合法: %w", i+1, multiplier.StartCron, err)
+		}
+		if _, err := cron.ParseStandard(multiplier.EndCron); err != nil {
+			return fmt.Errorf("第%d个定时实例数倍数的 end_cron %q 不合法: %w", i+1, multiplier.EndCron, err)
+		}
+		if multiplier.MinMultiplier < 0 || multiplier.MaxMultiplier < 0 {
+			return fmt.Errorf("第%d个定时实例数倍数不能小于0", i+1)
+		}
+	}
+	return nil
+}
+
+//Active 查询 now 时处于时间段内的最大倍数，重叠的时间段取最大的倍数；没有处于时间段内的配置或 cron 表达式不合法时 ok 为 false
+func (multipliers InstanceCountMultipliers) Active(now time.Time) (minMultiplier, maxMultiplier float64, ok bool) {
+	for _, multiplier := range multipliers {
+		start, err := cron.ParseStandard(multiplier.StartCron)
+		if err != nil {
+			continue
+		}
+		end, err := cron.ParseStandard(multiplier.EndCron)
+		if err != nil {
+			continue
+		}
+		if !end.Next(now).Before(start.Next(now)) {
+			continue
+		}
+		ok = true
+		if multiplier.MinMultiplier > minMultiplier {
+			minMultiplier = multiplier.MinMultiplier
+		}
+		if multiplier.MaxMultiplier > maxMultiplier {
+			maxMultiplier = multiplier.MaxMultiplier
+		}
+	}
+	return minMultiplier, maxMultiplier, ok
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/logger"
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+//PendingApproval 超过规则 review_threshold 的扩缩容，人工确认后由 keeper 执行
+type PendingApproval struct {
+	Id          int64  `json:"id"`
+	RuleId      int64  `json:"rule_id"`
+	ServiceName string `json:"service_name"`
+	ClusterName string `json:"cluster_name"`
+	//Action event.ActionScaleUp 或 event.ActionScaleDown
+	Action string `json:"action"`
+	Count  int    `json:"count"`
+	//InstanceCount 发起确认时的实例数
+	InstanceCount int     `json:"instance_count"`
+	Redundancy    float64 `json:"redundancy"`
+	//Status 参见 consts.ApprovalStatus* 常量
+	Status   string `json:"status"`
+	Approver string `json:"approver"`
+	//Message 执行失败或重新检查不通过的原因
+	Message     string `json:"message"`
+	ExpireAt    int64  `json:"expire_at"`
+	CreatedTime int64  `json:"created_time"`
+	UpdatedTime int64  `json:"updated_time"`
+}
+
+func (PendingApproval) TableName() string {
+	return "pending_approvals"
+}
+
+func CreatePendingApproval(approval *PendingApproval) error {
+	if err := clients.DBClient.Create(approval).Error; err != nil {
+		logger.GetLogger().Error("CreatePendingApproval from db", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func GetPendingApprovalById(id int64) (*PendingApproval, error) {
+	var approval PendingApproval
+	if err := clients.DBClient.Where("id = ?", id).First(&approval).Error; err != nil {
+		logger.GetLogger().Error("GetPendingApprovalById from db", zap.Error(err))
+		return nil, err
+	}
+	return &approval, nil
+}
+
+//GetOpenPendingApprovalByRuleId 查询规则等待确认或已确认未执行的记录，没有时返回 nil
+func GetOpenPendingApprovalByRuleId(ruleID int64) (*PendingApproval, error) {
+	var approval PendingApproval
+	err := clients.DBClient.Where("rule_id = ? and status IN ?", ruleID, []string{consts.ApprovalStatusPending, consts.ApprovalStatusApproved}).
+		Order("id desc").First(&approval).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.GetLogger().Error("GetOpenPendingApprovalByRuleId from db", zap.Error(err))
+		return nil, err
+	}
+	return &approval, nil
+}
+
+//ListPendingApprovalsByStatus 按创建顺序查询指定状态的记录
+func ListPendingApprovalsByStatus(status string) ([]*PendingApproval, error) {
+	var approvals []*PendingApproval
+	if err := clients.DBClient.Where("status = ?", status).Order("id").Find(&approvals).Error; err != nil {
+		logger.GetLogger().Error("ListPendingApprovalsByStatus from db", zap.Error(err))
+		return nil, err
+	}
+	return approvals, nil
+}
+
+//TransitPendingApprovalStatus 只有当前状态为 from 时才改为 to，返回是否修改成功；多个 keeper 同时执行时只有一个能成功
+func TransitPendingApprovalStatus(id int64, from, to, approver, message string) (bool, error) {
+	updates := map[string]interface{}{
+		"status":       to,
+		"message":      truncateErrorMessage(message),
+		"updated_time": time.Now().Unix(),
+	}
+	if approver != "" {
+		updates["approver"] = approver
+	}
+	result := clients.DBClient.Model(&PendingApproval{}).Where("id = ? and status = ?", id, from).Updates(updates)
+	if result.Error != nil {
+		logger.GetLogger().Error("TransitPendingApprovalStatus from write db", zap.Error(result.Error))
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
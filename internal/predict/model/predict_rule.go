@@ -1,88 +1,459 @@
 package model
 
 import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
 	"github.com/galaxy-future/cudgx/common/logger"
 	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/go-sql-driver/mysql"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+//ErrRuleMustBeDisabledFirst 只能删除已禁用或草稿状态的规则
+var ErrRuleMustBeDisabledFirst = errors.New("rule must be disabled before deleting")
+
+//ErrRuleIsScheduling 规则正在被 keeper 调度，稍后再删除
+var ErrRuleIsScheduling = errors.New("rule is being scheduled")
+
+//ErrRuleExists 服务集群已存在未删除的规则，由 (service_name, cluster_name) 唯一索引保证
+var ErrRuleExists = errors.New("rule of the service cluster already exists")
+
+//mysqlErrDuplicateEntry 违反唯一索引时 MySQL 返回的错误码
+const mysqlErrDuplicateEntry = 1062
+
+//translateDuplicateError 把违反唯一索引的错误转换为 ErrRuleExists
+func translateDuplicateError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry {
+		return fmt.Errorf("%w, %s", ErrRuleExists, mysqlErr.Message)
+	}
+	return err
+}
+
+//ruleActiveChecker 判断 keeper 当前是否正在执行规则，由 redundancy keeper 初始化时设置
+var ruleActiveChecker func(ruleID int64) bool
+
+//SetRuleActiveChecker 设置判断规则是否正在执行的函数，供 SafeDeleteRule 使用
+func SetRuleActiveChecker(checker func(ruleID int64) bool) {
+	ruleActiveChecker = checker
+}
+
 type PredictRule struct {
-	Id               int64  `json:"id"`
-	Name             string `json:"name"`
-	ServiceName      string `json:"service_name"`
-	ClusterName      string `json:"cluster_name"`
-	MetricName       string `json:"metric_name"`
-	BenchmarkQps     int    `json:"benchmark_qps"`
-	MinRedundancy    int    `json:"min_redundancy"`
-	MaxRedundancy    int    `json:"max_redundancy"`
-	MinInstanceCount int    `json:"min_instance_count"`
-	MaxInstanceCount int    `json:"max_instance_count"`
-	ExecuteRatio     int    `json:"execute_ratio"`
-	Status           string `json:"status"`
-	CreatedTime      int64  `json:"created_time"`
+	Id                              int64                    `json:"id"`
+	Name                            string                   `json:"name"`
+	ServiceName                     string                   `json:"service_name"`
+	ClusterName                     string                   `json:"cluster_name"`
+	MetricName                      string                   `json:"metric_name"`
+	BenchmarkQps                    int                      `json:"benchmark_qps"`
+	MinRedundancy                   int                      `json:"min_redundancy"`
+	MaxRedundancy                   int                      `json:"max_redundancy"`
+	MinInstanceCount                int                      `json:"min_instance_count"`
+	MaxInstanceCount                int                      `json:"max_instance_count"`
+	ExecuteRatio                    int                      `json:"execute_ratio"`
+	UseGradualExpand                bool                     `json:"use_gradual_expand"`
+	GradualBatchSize                int                      `json:"gradual_batch_size"`
+	RecoveryThreshold               float64                  `json:"recovery_threshold"`
+	MetricQueryMode                 string                   `json:"metric_query_mode"`
+	RecordingRuleMetricName         string                   `json:"recording_rule_metric_name"`
+	MinQPSThreshold                 float64                  `json:"min_qps_threshold"`
+	ClonedFromRuleID                int64                    `json:"cloned_from_rule_id"`
+	UseExpandAndWait                bool                     `json:"use_expand_and_wait"`
+	ReadinessTimeoutSeconds         int                      `json:"readiness_timeout_seconds"`
+	MetricScope                     string                   `json:"metric_scope"`
+	ErrorCount                      int                      `json:"error_count"`
+	ErrorMessage                    string                   `json:"error_message"`
+	MaxRateOfChangePercent          float64                  `json:"max_rate_of_change_percent"`
+	BenchmarkAutoLearn              bool                     `json:"benchmark_auto_learn"`
+	BenchmarkAutoLearnThresholdPct  float64                  `json:"benchmark_auto_learn_threshold_pct"`
+	AllowedServicePattern           string                   `json:"allowed_service_pattern"`
+	AllowedClusterPattern           string                   `json:"allowed_cluster_pattern"`
+	GreenBlueMode                   bool                     `json:"green_blue_mode"`
+	ColorMetricLabel                string                   `json:"color_metric_label"`
+	ForecastHorizonSeconds          int                      `json:"forecast_horizon_seconds"`
+	ShrinkPreference                string                   `json:"shrink_preference"`
+	MetricAggregationWindowSeconds  int                      `json:"metric_aggregation_window_seconds"`
+	AutoDiscovered                  bool                     `json:"auto_discovered"`
+	ReviewRequired                  bool                     `json:"review_required"`
+	ReviewThreshold                 int                      `json:"review_threshold"`
+	MultiClusterMode                bool                     `json:"multi_cluster_mode"`
+	MaxShrinkPercent                float64                  `json:"max_shrink_percent"`
+	MaxExpandPercent                float64                  `json:"max_expand_percent"`
+	DependsOnRuleID                 *int64                   `json:"depends_on_rule_id"`
+	AlertOnHighRedundancy           float64                  `json:"alert_on_high_redundancy"`
+	AlertCooldownMinutes            int                      `json:"alert_cooldown_minutes"`
+	InstanceCountMultiplierSchedule InstanceCountMultipliers `json:"instance_count_multiplier_schedule"`
+	TrafficSplitSource              string                   `json:"traffic_split_source"`
+	ScaleUpPolicy                   string                   `json:"scale_up_policy"`
+	ScaleDownPolicy                 string                   `json:"scale_down_policy"`
+	ConsecutiveTicksRequired        int                      `json:"consecutive_ticks_required"`
+	ClusterType                     string                   `json:"cluster_type"`
+	HealthyInstancesOnly            bool                     `json:"healthy_instances_only"`
+	MetricBackendAlias              string                   `json:"metric_backend_alias"`
+	MetricMissingAction             string                   `json:"metric_missing_action"`
+	NotifyOnCreation                bool                     `json:"notify_on_creation"`
+	NotifyOnDeletion                bool                     `json:"notify_on_deletion"`
+	VerifyShrinkAfterSeconds        int                      `json:"verify_shrink_after_seconds"`
+	CheckQuotaBeforeExpand          bool                     `json:"check_quota_before_expand"`
+	ShrinkConsecutiveTicks          int                      `json:"shrink_consecutive_ticks"`
+	MaxScaleUpAbsolute              int                      `json:"max_scale_up_absolute"`
+	MaxScaleDownAbsolute            int                      `json:"max_scale_down_absolute"`
+	Status                          string                   `json:"status"`
+	CreatedTime                     int64                    `json:"created_time"`
+	//CalibratedAt 最近一次根据指标校准 benchmark_qps 的时间，未校准过为空
+	CalibratedAt *time.Time `json:"calibrated_at,omitempty"`
+	//LastScaledAt 最近一次扩缩容成功的时间，与扩缩容事件在同一个事务中写入，从未扩缩容过为空
+	LastScaledAt *time.Time `json:"last_scaled_at,omitempty"`
+	//DeletedAt 软删除时间，为空表示未删除；已删除的规则不出现在查询结果中，扩缩容事件仍然保留
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 func (PredictRule) TableName() string {
 	return "predict_rules"
 }
 
+//notDeleted 只查询未软删除的规则
+func notDeleted(db *gorm.DB) *gorm.DB {
+	return db.Where("deleted_at IS NULL")
+}
+
+//QueryMetricName 查询冗余度使用的指标名称，recording_rule 模式下为记录规则名称
+func (rule *PredictRule) QueryMetricName() string {
+	if rule.MetricQueryMode == consts.MetricQueryModeRecordingRule && rule.RecordingRuleMetricName != "" {
+		return rule.RecordingRuleMetricName
+	}
+	return rule.MetricName
+}
+
+//Validate 校验规则的服务/集群名称，名称需要同时匹配全局的 allowed_service_pattern/allowed_cluster_pattern
+//和规则自己的 AllowedServicePattern/AllowedClusterPattern；k8s 类型集群的名称需要是 namespace/name；配置了 DependsOnRuleID 时检查依赖链中没有环
+func (rule *PredictRule) Validate() error {
+	if rule.ServiceName == "" {
+		return fmt.Errorf("服务名称不能为空")
+	}
+	if rule.ClusterName == "" {
+		return fmt.Errorf("集群名称不能为空")
+	}
+	if err := clients.MatchNamePatterns(rule.ServiceName, rule.ClusterName); err != nil {
+		return err
+	}
+	if err := matchNamePattern("服务", rule.ServiceName, rule.AllowedServicePattern); err != nil {
+		return err
+	}
+	if err := matchNamePattern("集群", rule.ClusterName, rule.AllowedClusterPattern); err != nil {
+		return err
+	}
+	if rule.ClusterType == consts.ClusterTypeK8sDeployment || rule.ClusterType == consts.ClusterTypeK8sStatefulSet {
+		if _, _, err := clients.ParseK8sClusterName(rule.ClusterName); err != nil {
+			return err
+		}
+	}
+	if rule.HealthyInstancesOnly && rule.MetricScope != consts.MetricScopeInstance {
+		return fmt.Errorf("healthy_instances_only 需要 metric_scope 为 %s", consts.MetricScopeInstance)
+	}
+	switch rule.MetricMissingAction {
+	case "", consts.MetricMissingActionSkip, consts.MetricMissingActionScaleToMin, consts.MetricMissingActionScaleToMax, consts.MetricMissingActionAlertOnly:
+	default:
+		return fmt.Errorf("metric_missing_action 只能为 %s/%s/%s/%s", consts.MetricMissingActionSkip, consts.MetricMissingActionScaleToMin,
+			consts.MetricMissingActionScaleToMax, consts.MetricMissingActionAlertOnly)
+	}
+	if rule.VerifyShrinkAfterSeconds < 0 {
+		return fmt.Errorf("verify_shrink_after_seconds 不能小于0")
+	}
+	if rule.ShrinkConsecutiveTicks < 0 {
+		return fmt.Errorf("shrink_consecutive_ticks 不能小于0")
+	}
+	if rule.MaxScaleUpAbsolute < 0 || rule.MaxScaleDownAbsolute < 0 {
+		return fmt.Errorf("max_scale_up_absolute 和 max_scale_down_absolute 不能小于0")
+	}
+	if err := rule.InstanceCountMultiplierSchedule.validate(); err != nil {
+		return err
+	}
+	return rule.validateDependency()
+}
+
+//validateDependency 沿 DependsOnRuleID 查询依赖链，依赖的规则必须存在且依赖链不能回到本规则或形成环
+func (rule *PredictRule) validateDependency() error {
+	if rule.DependsOnRuleID == nil {
+		return nil
+	}
+	visited := make(map[int64]bool)
+	if rule.Id != 0 {
+		visited[rule.Id] = true
+	}
+	dependsOn := *rule.DependsOnRuleID
+	for {
+		if visited[dependsOn] {
+			return fmt.Errorf("规则依赖链存在环，规则 %d 被重复依赖", dependsOn)
+		}
+		visited[dependsOn] = true
+		dependency, err := GetPredictRuleById(dependsOn)
+		if err != nil {
+			return fmt.Errorf("依赖的规则 %d 不存在: %w", dependsOn, err)
+		}
+		if dependency.DependsOnRuleID == nil {
+			return nil
+		}
+		dependsOn = *dependency.DependsOnRuleID
+	}
+}
+
+func matchNamePattern(kind, name, pattern string) error {
+	if pattern == "" {
+		return nil
+	}
+	matched, err := regexp.MatchString(pattern, name)
+	if err != nil {
+		return fmt.Errorf("%s名称正则 %s 不合法: %w", kind, pattern, err)
+	}
+	if !matched {
+		return fmt.Errorf("%s名称 %s 不匹配允许的正则 %s", kind, name, pattern)
+	}
+	return nil
+}
+
+//CreatePredictRule 创建规则，服务集群已存在规则时返回 ErrRuleExists
 func CreatePredictRule(predictRule *PredictRule) error {
 	if err := clients.DBClient.Create(predictRule).Error; err != nil {
 		logger.GetLogger().Error("CreatePredictRule from db", zap.Error(err))
+		return translateDuplicateError(err)
+	}
+	return nil
+}
+
+//SafeDeleteRule 删除已禁用或草稿状态、且当前没有被 keeper 执行的规则
+func SafeDeleteRule(ruleID int64) error {
+	return SafeDeleteRules([]int64{ruleID})
+}
+
+//SafeDeleteRules 在同一个事务中锁定并软删除规则，任一规则未禁用或正在执行时都不删除；
+//不存在或已删除的规则被忽略
+func SafeDeleteRules(ids []int64) error {
+	err := clients.DBClient.Transaction(func(tx *gorm.DB) error {
+		var predictRules []*PredictRule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(notDeleted).Where("id IN ?", ids).Find(&predictRules).Error; err != nil {
+			return err
+		}
+		for _, rule := range predictRules {
+			if rule.Status != consts.RuleStatusDisable && rule.Status != consts.RuleStatusDraft && rule.Status != consts.RuleStatusError {
+				return fmt.Errorf("%w, rule %d is %s", ErrRuleMustBeDisabledFirst, rule.Id, rule.Status)
+			}
+			if ruleActiveChecker != nil && ruleActiveChecker(rule.Id) {
+				return fmt.Errorf("%w, rule %d", ErrRuleIsScheduling, rule.Id)
+			}
+		}
+		if len(predictRules) == 0 {
+			return nil
+		}
+		return tx.Model(&PredictRule{}).Scopes(notDeleted).Where("id IN ?", ids).Update("deleted_at", time.Now()).Error
+	})
+	if err != nil {
+		logger.GetLogger().Error("SafeDeleteRules from db", zap.Int64s("ids", ids), zap.Error(err))
 		return err
 	}
 	return nil
 }
 
-func DeletePredictRuleById(ids []int64) error {
-	if err := clients.DBClient.Delete(&PredictRule{}, ids).Error; err != nil {
-		logger.GetLogger().Error("DeletePredictRuleById from db", zap.Error(err))
+//ListDeletedRules 查询 since 之后软删除的规则，最近删除的在前
+func ListDeletedRules(since time.Time) ([]*PredictRule, error) {
+	var predictRules []*PredictRule
+	if err := clients.DBClient.Where("deleted_at >= ?", since).Order("deleted_at desc").Find(&predictRules).Error; err != nil {
+		logger.GetLogger().Error("ListDeletedRules from db", zap.Error(err))
+		return nil, err
+	}
+	return predictRules, nil
+}
+
+//PurgeDeletedRules 物理删除软删除超过 olderThan 的规则及其待确认记录；扩缩容事件和修改记录作为历史保留，
+//外键 ON DELETE SET NULL 把它们的 rule_id 置空
+func PurgeDeletedRules(olderThan time.Duration) error {
+	err := clients.DBClient.Transaction(func(tx *gorm.DB) error {
+		var ids []int64
+		if err := tx.Model(&PredictRule{}).Where("deleted_at < ?", time.Now().Add(-olderThan)).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Where("rule_id IN ?", ids).Delete(&PendingApproval{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&PredictRule{}, ids).Error
+	})
+	if err != nil {
+		logger.GetLogger().Error("PurgeDeletedRules from db", zap.Duration("older_than", olderThan), zap.Error(err))
 		return err
 	}
 	return nil
 }
 
-func UpdatePredictRule(predictRule *PredictRule) error {
-	updateMap := map[string]interface{}{
-		"name":               predictRule.Name,
-		"service_name":       predictRule.ServiceName,
-		"cluster_name":       predictRule.ClusterName,
-		"metric_name":        predictRule.MetricName,
-		"benchmark_qps":      predictRule.BenchmarkQps,
-		"min_redundancy":     predictRule.MinRedundancy,
-		"max_redundancy":     predictRule.MaxRedundancy,
-		"min_instance_count": predictRule.MinInstanceCount,
-		"max_instance_count": predictRule.MaxInstanceCount,
-		"execute_ratio":      predictRule.ExecuteRatio,
-		"status":             predictRule.Status,
-	}
-	if err := clients.DBClient.Model(&PredictRule{}).Where("id", predictRule.Id).Updates(updateMap).Error; err != nil {
+//UpdatePredictRule 更新规则，并在同一个事务中按字段记录修改前后的值，changedBy 为发起修改的用户；
+//修改后的服务集群已存在其他规则时返回 ErrRuleExists
+func UpdatePredictRule(predictRule *PredictRule, changedBy string) error {
+	err := clients.DBClient.Transaction(func(tx *gorm.DB) error {
+		var before PredictRule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(notDeleted).Where("id = ?", predictRule.Id).First(&before).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&PredictRule{}).Where("id", predictRule.Id).Updates(updateColumns(predictRule)).Error; err != nil {
+			return err
+		}
+		return createRuleChanges(tx, ruleChanges(&before, predictRule, changedBy, time.Now()))
+	})
+	if err != nil {
 		logger.GetLogger().Error("UpdatePredictRule from db", zap.Error(err))
-		return err
+		return translateDuplicateError(err)
 	}
 	return nil
 }
 
+//updateColumns 更新规则时写入的列
+func updateColumns(predictRule *PredictRule) map[string]interface{} {
+	return map[string]interface{}{
+		"name":                               predictRule.Name,
+		"service_name":                       predictRule.ServiceName,
+		"cluster_name":                       predictRule.ClusterName,
+		"metric_name":                        predictRule.MetricName,
+		"benchmark_qps":                      predictRule.BenchmarkQps,
+		"min_redundancy":                     predictRule.MinRedundancy,
+		"max_redundancy":                     predictRule.MaxRedundancy,
+		"min_instance_count":                 predictRule.MinInstanceCount,
+		"max_instance_count":                 predictRule.MaxInstanceCount,
+		"execute_ratio":                      predictRule.ExecuteRatio,
+		"use_gradual_expand":                 predictRule.UseGradualExpand,
+		"gradual_batch_size":                 predictRule.GradualBatchSize,
+		"recovery_threshold":                 predictRule.RecoveryThreshold,
+		"metric_query_mode":                  predictRule.MetricQueryMode,
+		"recording_rule_metric_name":         predictRule.RecordingRuleMetricName,
+		"min_qps_threshold":                  predictRule.MinQPSThreshold,
+		"use_expand_and_wait":                predictRule.UseExpandAndWait,
+		"readiness_timeout_seconds":          predictRule.ReadinessTimeoutSeconds,
+		"metric_scope":                       predictRule.MetricScope,
+		"max_rate_of_change_percent":         predictRule.MaxRateOfChangePercent,
+		"benchmark_auto_learn":               predictRule.BenchmarkAutoLearn,
+		"benchmark_auto_learn_threshold_pct": predictRule.BenchmarkAutoLearnThresholdPct,
+		"allowed_service_pattern":            predictRule.AllowedServicePattern,
+		"allowed_cluster_pattern":            predictRule.AllowedClusterPattern,
+		"green_blue_mode":                    predictRule.GreenBlueMode,
+		"color_metric_label":                 predictRule.ColorMetricLabel,
+		"forecast_horizon_seconds":           predictRule.ForecastHorizonSeconds,
+		"shrink_preference":                  predictRule.ShrinkPreference,
+		"metric_aggregation_window_seconds":  predictRule.MetricAggregationWindowSeconds,
+		"review_required":                    predictRule.ReviewRequired,
+		"review_threshold":                   predictRule.ReviewThreshold,
+		"multi_cluster_mode":                 predictRule.MultiClusterMode,
+		"max_shrink_percent":                 predictRule.MaxShrinkPercent,
+		"max_expand_percent":                 predictRule.MaxExpandPercent,
+		"depends_on_rule_id":                 predictRule.DependsOnRuleID,
+		"alert_on_high_redundancy":           predictRule.AlertOnHighRedundancy,
+		"alert_cooldown_minutes":             predictRule.AlertCooldownMinutes,
+		"instance_count_multiplier_schedule": predictRule.InstanceCountMultiplierSchedule,
+		"traffic_split_source":               predictRule.TrafficSplitSource,
+		"scale_up_policy":                    predictRule.ScaleUpPolicy,
+		"scale_down_policy":                  predictRule.ScaleDownPolicy,
+		"consecutive_ticks_required":         predictRule.ConsecutiveTicksRequired,
+		"cluster_type":                       predictRule.ClusterType,
+		"healthy_instances_only":             predictRule.HealthyInstancesOnly,
+		"metric_backend_alias":               predictRule.MetricBackendAlias,
+		"metric_missing_action":              predictRule.MetricMissingAction,
+		"notify_on_creation":                 predictRule.NotifyOnCreation,
+		"notify_on_deletion":                 predictRule.NotifyOnDeletion,
+		"verify_shrink_after_seconds":        predictRule.VerifyShrinkAfterSeconds,
+		"check_quota_before_expand":          predictRule.CheckQuotaBeforeExpand,
+		"shrink_consecutive_ticks":           predictRule.ShrinkConsecutiveTicks,
+		"max_scale_up_absolute":              predictRule.MaxScaleUpAbsolute,
+		"max_scale_down_absolute":            predictRule.MaxScaleDownAbsolute,
+		"status":                             predictRule.Status,
+	}
+}
+
+//IsUpdatableColumn 判断 column 是否可以通过更新规则修改
+func IsUpdatableColumn(column string) bool {
+	_, ok := updateColumns(&PredictRule{})[column]
+	return ok
+}
+
+//ErrBulkUpdateRolledBack 批量更新中有规则更新失败，全部修改已回滚
+var ErrBulkUpdateRolledBack = errors.New("bulk update rolled back")
+
+//errBulkUpdateDryRun 试运行时回滚事务
+var errBulkUpdateDryRun = errors.New("bulk update dry run")
+
+//BulkUpdatePredictRules 在同一个事务中加锁读取 ids 对应的规则，依次调用 update 修改规则后写回。
+//不存在的规则或 update 返回错误的规则记录在 failures 中，有失败时回滚全部修改并返回 ErrBulkUpdateRolledBack；dryRun 为 true 时总是回滚。
+//修改记录的 changed_by 为 changedBy
+func BulkUpdatePredictRules(ids []int64, update func(predictRule *PredictRule) error, dryRun bool, changedBy string) (failures map[int64]error, err error) {
+	failures = make(map[int64]error)
+	err = clients.DBClient.Transaction(func(tx *gorm.DB) error {
+		var predictRules []*PredictRule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(notDeleted).Where("id IN ?", ids).Find(&predictRules).Error; err != nil {
+			return err
+		}
+		byID := make(map[int64]*PredictRule, len(predictRules))
+		for _, predictRule := range predictRules {
+			byID[predictRule.Id] = predictRule
+		}
+		for _, id := range ids {
+			predictRule, ok := byID[id]
+			if !ok {
+				failures[id] = gorm.ErrRecordNotFound
+				continue
+			}
+			before := *predictRule
+			if err := update(predictRule); err != nil {
+				failures[id] = err
+				continue
+			}
+			if err := tx.Model(&PredictRule{}).Where("id", id).Updates(updateColumns(predictRule)).Error; err != nil {
+				return err
+			}
+			if err := createRuleChanges(tx, ruleChanges(&before, predictRule, changedBy, time.Now())); err != nil {
+				return err
+			}
+		}
+		if len(failures) > 0 {
+			return ErrBulkUpdateRolledBack
+		}
+		if dryRun {
+			return errBulkUpdateDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errBulkUpdateDryRun) {
+		return failures, nil
+	}
+	if err != nil && !errors.Is(err, ErrBulkUpdateRolledBack) {
+		logger.GetLogger().Error("BulkUpdatePredictRules from db", zap.Int64s("ids", ids), zap.Error(err))
+	}
+	return failures, err
+}
+
 func GetPredictRuleById(id int64) (*PredictRule, error) {
 	var predictRule PredictRule
-	if err := clients.DBClient.Where("id = ?", id).First(&predictRule).Error; err != nil {
+	if err := clients.DBClient.Scopes(notDeleted).Where("id = ?", id).First(&predictRule).Error; err != nil {
 		logger.GetLogger().Error("GetPredictRuleById from db", zap.Error(err))
 		return nil, err
 	}
 	return &predictRule, nil
 }
 
-func GetPredictRuleByServiceNameAndClusterName(serviceName, clusterName string) (*PredictRule, error) {
+//GetPredictRuleByServiceCluster 按 (service_name, cluster_name) 唯一索引查询服务集群的规则，不存在时返回 gorm.ErrRecordNotFound
+func GetPredictRuleByServiceCluster(serviceName, clusterName string) (*PredictRule, error) {
 	var predictRule PredictRule
-	if err := clients.DBClient.Where("service_name = ? and cluster_name = ? ", serviceName, clusterName).First(&predictRule).Error; err != nil {
-		logger.GetLogger().Error("GetPredictRuleByServiceNameAndClusterName from db", zap.Error(err))
+	if err := clients.DBClient.Scopes(notDeleted).Where("service_name = ? and cluster_name = ?", serviceName, clusterName).First(&predictRule).Error; err != nil {
+		logger.GetLogger().Error("GetPredictRuleByServiceCluster from db", zap.Error(err))
 		return nil, err
 	}
 	return &predictRule, nil
 }
 
 func ListPredictRules(serviceName, clusterName string, pageNumber int, pageSize int) ([]*PredictRule, int, error) {
-	theClient := clients.DBClient.Model(&PredictRule{}).Where("service_name = ?", serviceName)
+	theClient := clients.DBClient.Model(&PredictRule{}).Scopes(notDeleted).Where("service_name = ?", serviceName)
 	if clusterName != "" {
 		theClient.Where("cluster_name = ?", clusterName)
 	}
@@ -99,8 +470,69 @@ func ListPredictRules(serviceName, clusterName string, pageNumber int, pageSize
 	return predictRules, int(total), nil
 }
 
+//RuleFilter 按条件筛选规则，为空的条件不参与筛选
+type RuleFilter struct {
+	ServiceName string  `json:"service_name"`
+	ClusterName string  `json:"cluster_name"`
+	MetricName  string  `json:"metric_name"`
+	Status      string  `json:"status"`
+	Ids         []int64 `json:"ids"`
+	//MetricBackendAlias 只查询使用该指标后端别名的规则
+	MetricBackendAlias string `json:"metric_backend_alias"`
+}
+
+//ListPredictRulesByFilter 查询符合 filter 的所有规则，按 id 升序
+func ListPredictRulesByFilter(filter RuleFilter) ([]*PredictRule, error) {
+	theClient := clients.DBClient.Model(&PredictRule{}).Scopes(notDeleted)
+	if filter.ServiceName != "" {
+		theClient.Where("service_name = ?", filter.ServiceName)
+	}
+	if filter.ClusterName != "" {
+		theClient.Where("cluster_name = ?", filter.ClusterName)
+	}
+	if filter.MetricName != "" {
+		theClient.Where("metric_name = ?", filter.MetricName)
+	}
+	if filter.Status != "" {
+		theClient.Where("status = ?", filter.Status)
+	}
+	if filter.MetricBackendAlias != "" {
+		theClient.Where("metric_backend_alias = ?", filter.MetricBackendAlias)
+	}
+	if len(filter.Ids) > 0 {
+		theClient.Where("id in ?", filter.Ids)
+	}
+	var predictRules []*PredictRule
+	if err := theClient.Order("id").Find(&predictRules).Error; err != nil {
+		logger.GetLogger().Error("ListPredictRulesByFilter from db", zap.Error(err))
+		return nil, err
+	}
+	return predictRules, nil
+}
+
+//ListPredictRulesByMetric 查询使用指定指标的所有规则
+func ListPredictRulesByMetric(metricName string) ([]*PredictRule, error) {
+	var predictRules []*PredictRule
+	if err := clients.DBClient.Scopes(notDeleted).Where("metric_name = ?", metricName).Order("id desc").Find(&predictRules).Error; err != nil {
+		logger.GetLogger().Error("ListPredictRulesByMetric from db", zap.Error(err))
+		return nil, err
+	}
+	return predictRules, nil
+}
+
+//ListEnabledMetricNames 查询已启用规则使用的所有指标名称，去重
+func ListEnabledMetricNames() ([]string, error) {
+	var metricNames []string
+	if err := clients.DBClient.Model(&PredictRule{}).Scopes(notDeleted).Distinct("metric_name").Where("status = ?", consts.RuleStatusEnable).
+		Order("metric_name").Pluck("metric_name", &metricNames).Error; err != nil {
+		logger.GetLogger().Error("ListEnabledMetricNames from db", zap.Error(err))
+		return nil, err
+	}
+	return metricNames, nil
+}
+
 func ListAllPredictRules() ([]*PredictRule, error) {
-	theClient := clients.DBClient.Model(&PredictRule{})
+	theClient := clients.DBClient.Model(&PredictRule{}).Scopes(notDeleted)
 	var predictRules []*PredictRule
 	if err := theClient.Find(&predictRules).Error; err != nil {
 		logger.GetLogger().Error("ListAllPredictRules from db", zap.Error(err))
@@ -110,9 +542,64 @@ func ListAllPredictRules() ([]*PredictRule, error) {
 }
 
 func UpdatePredictRuleStatusById(id int64, status string) error {
-	if err := clients.DBClient.Model(&PredictRule{}).Where("id", id).Update("status", status).Error; err != nil {
+	if err := clients.DBClient.Model(&PredictRule{}).Scopes(notDeleted).Where("id", id).Update("status", status).Error; err != nil {
 		logger.GetLogger().Error("UpdatePredictRuleStatusById from write db", zap.Error(err))
 		return err
 	}
 	return nil
 }
+
+//IncrementRuleErrorCount 规则连续失败次数加1并记录失败原因
+func IncrementRuleErrorCount(ruleID int64, msg string) error {
+	if err := clients.DBClient.Model(&PredictRule{}).Where("id", ruleID).Updates(map[string]interface{}{
+		"error_count":   gorm.Expr("error_count + 1"),
+		"error_message": truncateErrorMessage(msg),
+	}).Error; err != nil {
+		logger.GetLogger().Error("IncrementRuleErrorCount from write db", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+//ResetRuleErrorCount 清零规则连续失败次数和失败原因
+func ResetRuleErrorCount(ruleID int64) error {
+	if err := clients.DBClient.Model(&PredictRule{}).Where("id", ruleID).Updates(map[string]interface{}{
+		"error_count":   0,
+		"error_message": "",
+	}).Error; err != nil {
+		logger.GetLogger().Error("ResetRuleErrorCount from write db", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+//maxErrorMessageLength error_message 列的长度
+const maxErrorMessageLength = 1024
+
+func truncateErrorMessage(msg string) string {
+	if len(msg) <= maxErrorMessageLength {
+		return msg
+	}
+	return msg[:maxErrorMessageLength]
+}
+
+//UpdatePredictRuleCalibration 更新校准得到的 benchmark_qps 和校准时间
+func UpdatePredictRuleCalibration(id int64, benchmarkQps int, calibratedAt time.Time) error {
+	if err := clients.DBClient.Model(&PredictRule{}).Where("id", id).Updates(map[string]interface{}{
+		"benchmark_qps": benchmarkQps,
+		"calibrated_at": calibratedAt,
+	}).Error; err != nil {
+		logger.GetLogger().Error("UpdatePredictRuleCalibration from write db", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+//UpdatePredictRuleBenchmarkQps 更新规则的 benchmark_qps
+func UpdatePredictRuleBenchmarkQps(id int64, benchmarkQps int) error {
+	if err := clients.DBClient.Model(&PredictRule{}).Where("id", id).Update("benchmark_qps", benchmarkQps).Error; err != nil {
+		logger.GetLogger().Error("UpdatePredictRuleBenchmarkQps from write db", zap.Error(err))
+		return err
+	}
+	return nil
+}
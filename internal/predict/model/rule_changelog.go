@@ -0,0 +1,97 @@
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/logger"
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+//RuleChange 规则一个字段的一次修改
+type RuleChange struct {
+	Id int64 `json:"id"`
+	//RuleId 关联 predict_rules.id，PurgeDeletedRules 物理删除规则后修改记录仍然保留，rule_id 为0
+	RuleId int64 `json:"rule_id"`
+	//ChangedBy 发起修改的用户，未知时为空
+	ChangedBy string `json:"changed_by"`
+	ChangedAt int64  `json:"changed_at"`
+	//FieldName 修改的列名，与规则的 json 字段名相同
+	FieldName string `json:"field_name"`
+	OldValue  string `json:"old_value"`
+	NewValue  string `json:"new_value"`
+}
+
+func (RuleChange) TableName() string {
+	return "rule_changelogs"
+}
+
+//ListRuleChangelog 按时间倒序查询规则最近 limit 条修改记录
+func ListRuleChangelog(ruleID int64, limit int) ([]*RuleChange, error) {
+	var changes []*RuleChange
+	if err := clients.DBClient.Where("rule_id = ?", ruleID).Order("id desc").Limit(limit).Find(&changes).Error; err != nil {
+		logger.GetLogger().Error("ListRuleChangelog from db", zap.Error(err))
+		return nil, err
+	}
+	return changes, nil
+}
+
+//ruleChanges 逐个比较 updateColumns 中的列，返回 after 相对 before 修改过的字段
+func ruleChanges(before, after *PredictRule, changedBy string, changedAt time.Time) []*RuleChange {
+	beforeColumns := updateColumns(before)
+	afterColumns := updateColumns(after)
+	columns := make([]string, 0, len(afterColumns))
+	for column := range afterColumns {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	var changes []*RuleChange
+	for _, column := range columns {
+		oldValue := formatColumnValue(beforeColumns[column])
+		newValue := formatColumnValue(afterColumns[column])
+		if oldValue == newValue {
+			continue
+		}
+		changes = append(changes, &RuleChange{
+			RuleId:    after.Id,
+			ChangedBy: changedBy,
+			ChangedAt: changedAt.Unix(),
+			FieldName: column,
+			OldValue:  oldValue,
+			NewValue:  newValue,
+		})
+	}
+	return changes
+}
+
+//formatColumnValue 列的文本形式，空指针为空字符串，实现 driver.Valuer 的列使用写入数据库的值
+func formatColumnValue(value interface{}) string {
+	if id, ok := value.(*int64); ok {
+		if id == nil {
+			return ""
+		}
+		return strconv.FormatInt(*id, 10)
+	}
+	if valuer, ok := value.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil || v == nil {
+			return ""
+		}
+		return fmt.Sprint(v)
+	}
+	return fmt.Sprint(value)
+}
+
+//createRuleChanges 在事务 tx 中写入修改记录
+func createRuleChanges(tx *gorm.DB, changes []*RuleChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	return tx.Create(&changes).Error
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/logger"
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+//ScalingEvent 持久化的扩缩容事件，用于生成历史冗余度报告
+type ScalingEvent struct {
+	Id int64 `json:"id"`
+	//RuleId 关联 predict_rules.id，规则软删除和 PurgeDeletedRules 物理删除后事件仍然保留，物理删除后为0
+	RuleId               int64   `json:"rule_id"`
+	ServiceName          string  `json:"service_name"`
+	ClusterName          string  `json:"cluster_name"`
+	Action               string  `json:"action"`
+	Count                int     `json:"count"`
+	InstanceCount        int     `json:"instance_count"`
+	Redundancy           float64 `json:"redundancy"`
+	EstimatedCostPerHour float64 `json:"estimated_cost_per_hour"`
+	Timestamp            int64   `json:"timestamp"`
+}
+
+func (ScalingEvent) TableName() string {
+	return "scaling_events"
+}
+
+//CreateScalingEvent 保存扩缩容事件，并在同一个事务中更新规则的 last_scaled_at
+func CreateScalingEvent(scalingEvent *ScalingEvent) error {
+	err := clients.DBClient.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(scalingEvent).Error; err != nil {
+			return err
+		}
+		return tx.Exec("UPDATE predict_rules SET last_scaled_at=? WHERE id=?", time.Unix(scalingEvent.Timestamp, 0), scalingEvent.RuleId).Error
+	})
+	if err != nil {
+		logger.GetLogger().Error("CreateScalingEvent from db", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+//ListScalingEvents 查询服务集群在 [begin, end) 内的扩缩容事件，按时间排序
+func ListScalingEvents(serviceName, clusterName string, begin, end int64) ([]*ScalingEvent, error) {
+	var scalingEvents []*ScalingEvent
+	if err := clients.DBClient.Where("service_name = ? and cluster_name = ? and timestamp >= ? and timestamp < ?", serviceName, clusterName, begin, end).
+		Order("timestamp, id").Find(&scalingEvents).Error; err != nil {
+		logger.GetLogger().Error("ListScalingEvents from db", zap.Error(err))
+		return nil, err
+	}
+	return scalingEvents, nil
+}
+
+//DeleteScalingEventsBefore 删除 timestamp 早于 before 的扩缩容事件，最多删除 limit 行，返回删除的行数
+func DeleteScalingEventsBefore(before int64, limit int) (int64, error) {
+	result := clients.DBClient.Exec("DELETE FROM scaling_events WHERE timestamp < ? LIMIT ?", before, limit)
+	if result.Error != nil {
+		logger.GetLogger().Error("DeleteScalingEventsBefore from db", zap.Int64("before", before), zap.Error(result.Error))
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+//ScalingEventPublisher 将扩缩容事件保存到 scaling_events 表，实现 event.EventPublisher
+type ScalingEventPublisher struct{}
+
+//NewScalingEventPublisher 新建 ScalingEventPublisher
+func NewScalingEventPublisher() *ScalingEventPublisher {
+	return &ScalingEventPublisher{}
+}
+
+//Publish 实现 event.EventPublisher 接口
+func (p *ScalingEventPublisher) Publish(ctx context.Context, e *event.ScalingEvent) error {
+	return CreateScalingEvent(&ScalingEvent{
+		RuleId:               e.RuleId,
+		ServiceName:          e.ServiceName,
+		ClusterName:          e.ClusterName,
+		Action:               e.Action,
+		Count:                e.Count,
+		InstanceCount:        e.InstanceCount,
+		Redundancy:           e.Redundancy,
+		EstimatedCostPerHour: e.EstimatedCostPerHour,
+		Timestamp:            e.Timestamp,
+	})
+}
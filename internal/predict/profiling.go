@@ -0,0 +1,112 @@
+package predict
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"runtime"
+	rpprof "runtime/pprof"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/logger"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"go.uber.org/zap"
+)
+
+//cpuProfileLock 同一时间只能采集一个 CPU profile
+var cpuProfileLock sync.Mutex
+
+//StartProfiling 按 profiling_addr 和 profile_output_path 配置启动 pprof 服务和 SIGUSR1 CPU profile，都未配置时什么也不做
+func StartProfiling(ctx context.Context, param *config.Param) error {
+	if param.ProfilingAddr != "" {
+		if _, err := StartProfilingServer(ctx, param.ProfilingAddr); err != nil {
+			return err
+		}
+	}
+	if param.ProfileOutputPath != "" {
+		WatchProfileSignal(ctx, param.ProfileDuration.Duration, param.ProfileOutputPath)
+	}
+	return nil
+}
+
+//StartProfilingServer 在 addr 上提供 net/http/pprof，ctx 结束时关闭，返回实际监听的地址
+func StartProfilingServer(ctx context.Context, addr string) (net.Addr, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen profiling address %s failed , %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	server := &http.Server{Handler: mux}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.GetLogger().Error("profiling server stopped", zap.String("addr", addr), zap.Error(err))
+		}
+	}()
+	logger.GetLogger().Info("profiling server started", zap.String("addr", listener.Addr().String()))
+	return listener.Addr(), nil
+}
+
+//WatchProfileSignal 收到 SIGUSR1 时采集 duration 时长的 CPU profile 并写入 outputPath，未收到信号时没有额外开销
+func WatchProfileSignal(ctx context.Context, duration time.Duration, outputPath string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				go func() {
+					if err := WriteCPUProfile(ctx, duration, outputPath); err != nil {
+						logger.GetLogger().Error("write cpu profile failed", zap.String("file", outputPath), zap.Error(err))
+					}
+				}()
+			}
+		}
+	}()
+}
+
+//WriteCPUProfile 采集 duration 时长的 CPU profile 并写入 outputPath，已有采集在进行时返回错误
+func WriteCPUProfile(ctx context.Context, duration time.Duration, outputPath string) error {
+	if !cpuProfileLock.TryLock() {
+		return errors.New("cpu profile is already running")
+	}
+	defer cpuProfileLock.Unlock()
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := rpprof.StartCPUProfile(file); err != nil {
+		return err
+	}
+	logger.GetLogger().Info("cpu profile started", zap.String("file", outputPath), zap.Duration("duration", duration),
+		zap.Int("goroutines", runtime.NumGoroutine()))
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+	rpprof.StopCPUProfile()
+	logger.GetLogger().Info("cpu profile written", zap.String("file", outputPath))
+	return nil
+}
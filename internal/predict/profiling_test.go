@@ -0,0 +1,55 @@
+package predict_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/predict"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Profiling", func() {
+	var ctx context.Context
+	var cancel context.CancelFunc
+
+	ginkgo.BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+	})
+
+	ginkgo.AfterEach(func() {
+		cancel()
+	})
+
+	ginkgo.It("serves pprof handlers on the profiling address", func() {
+		addr, err := predict.StartProfilingServer(ctx, "127.0.0.1:0")
+		gomega.Expect(err).To(gomega.BeNil())
+		resp, err := http.Get("http://" + addr.String() + "/debug/pprof/goroutine?debug=1")
+		gomega.Expect(err).To(gomega.BeNil())
+		defer resp.Body.Close()
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK))
+	})
+
+	ginkgo.It("writes a cpu profile on SIGUSR1", func() {
+		dir, err := ioutil.TempDir("", "cudgx-profile")
+		gomega.Expect(err).To(gomega.BeNil())
+		defer os.RemoveAll(dir)
+		outputPath := filepath.Join(dir, "cpu.pprof")
+
+		predict.WatchProfileSignal(ctx, 100*time.Millisecond, outputPath)
+		gomega.Expect(syscall.Kill(os.Getpid(), syscall.SIGUSR1)).To(gomega.BeNil())
+
+		gomega.Eventually(func() int64 {
+			info, err := os.Stat(outputPath)
+			if err != nil {
+				return 0
+			}
+			return info.Size()
+		}, 2*time.Second).Should(gomega.BeNumerically(">", 0))
+	})
+})
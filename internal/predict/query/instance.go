@@ -2,6 +2,8 @@ package query
 
 import (
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/galaxy-future/cudgx/internal/clients"
 	"github.com/galaxy-future/cudgx/internal/predict/consts"
@@ -19,3 +21,65 @@ func InstanceCountByQPS(serviceName, clusterName string, begin, end int64) (samp
 
 	return queryClusterSamples(sqlContent)
 }
+
+//instanceMetricWindow GetInstanceMetrics 计算实例平均 QPS 的时间范围
+const instanceMetricWindow = 5 * time.Minute
+
+//InstanceMetric 实例最近 instanceMetricWindow 内的平均 QPS
+type InstanceMetric struct {
+	Ip  string
+	Qps float64
+}
+
+//GetInstanceMetrics 查询服务集群各实例最近的平均 QPS，按 ip 排序
+func GetInstanceMetrics(serviceName, clusterName string) ([]InstanceMetric, error) {
+	if Reader == nil {
+		return nil, fmt.Errorf("metric reader is not initialized")
+	}
+	end := time.Now().Unix()
+	res, err := Reader.QueryRange(InstanceQPSPromQL(serviceName, clusterName), end-int64(instanceMetricWindow/time.Second), end, consts.StepDuration)
+	if err != nil {
+		return nil, err
+	}
+	var metrics []InstanceMetric
+	for _, sampleStream := range res.Data {
+		ip, ok := sampleStream.Metric[serviceHostLabel]
+		if !ok || len(sampleStream.Values) == 0 {
+			continue
+		}
+		var sum float64
+		for _, value := range sampleStream.Values {
+			sum += float64(value.Value)
+		}
+		metrics = append(metrics, InstanceMetric{Ip: string(ip), Qps: sum / float64(len(sampleStream.Values))})
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Ip < metrics[j].Ip })
+	return metrics, nil
+}
+
+//serviceHostLabel 指标中实例 ip 的 label
+const serviceHostLabel = "serviceHost"
+
+//InstanceQPSPromQL 构造按实例查询服务集群 QPS 的PromQL
+func InstanceQPSPromQL(serviceName, clusterName string) string {
+	return fmt.Sprintf("sum(%s{serviceName='%s',clusterName='%s'}) by(%s)", consts.QPSMetricsName, serviceName, clusterName, serviceHostLabel)
+}
+
+//LowestQpsInstances 返回 QPS 最低的 count 个实例 ip，QPS 相同时按 ip 排序
+func LowestQpsInstances(metrics []InstanceMetric, count int) []string {
+	sorted := append([]InstanceMetric(nil), metrics...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Qps != sorted[j].Qps {
+			return sorted[i].Qps < sorted[j].Qps
+		}
+		return sorted[i].Ip < sorted[j].Ip
+	})
+	if count > len(sorted) {
+		count = len(sorted)
+	}
+	ips := make([]string, 0, count)
+	for _, metric := range sorted[:count] {
+		ips = append(ips, metric.Ip)
+	}
+	return ips
+}
@@ -0,0 +1,21 @@
+package query_test
+
+import (
+	"github.com/galaxy-future/cudgx/internal/predict/query"
+	"github.com/onsi/ginkgo/extensions/table"
+	"github.com/onsi/gomega"
+)
+
+var _ = table.DescribeTable("LowestQpsInstances",
+	func(count int, expected []string) {
+		metrics := []query.InstanceMetric{
+			{Ip: "10.0.0.1", Qps: 30},
+			{Ip: "10.0.0.2", Qps: 10},
+			{Ip: "10.0.0.3", Qps: 20},
+			{Ip: "10.0.0.4", Qps: 10},
+		}
+		gomega.Expect(query.LowestQpsInstances(metrics, count)).To(gomega.Equal(expected))
+	},
+	table.Entry("lowest first, ties by ip", 3, []string{"10.0.0.2", "10.0.0.4", "10.0.0.3"}),
+	table.Entry("count above instance count", 10, []string{"10.0.0.2", "10.0.0.4", "10.0.0.3", "10.0.0.1"}),
+)
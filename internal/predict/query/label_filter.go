@@ -0,0 +1,39 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+//LabelFilter 查询指标时额外的 label 等值过滤条件
+type LabelFilter struct {
+	Label string
+	Value string
+}
+
+type labelFiltersKey struct{}
+
+//WithLabelFilter 返回附加了 label 过滤条件的 ctx，AverageMetricFromReader 构造 PromQL 时使用
+func WithLabelFilter(ctx context.Context, label, value string) context.Context {
+	existing := LabelFiltersFromContext(ctx)
+	// 复制一份，避免修改父 ctx 中的过滤条件
+	filters := make([]LabelFilter, 0, len(existing)+1)
+	filters = append(append(filters, existing...), LabelFilter{Label: label, Value: value})
+	return context.WithValue(ctx, labelFiltersKey{}, filters)
+}
+
+//LabelFiltersFromContext 读取 ctx 中的 label 过滤条件
+func LabelFiltersFromContext(ctx context.Context) []LabelFilter {
+	filters, _ := ctx.Value(labelFiltersKey{}).([]LabelFilter)
+	return filters
+}
+
+//labelMatchers 将过滤条件转换为附加在 selector 后面的 PromQL label matcher
+func labelMatchers(filters []LabelFilter) string {
+	var builder strings.Builder
+	for _, filter := range filters {
+		builder.WriteString(fmt.Sprintf(",%s='%s'", filter.Label, filter.Value))
+	}
+	return builder.String()
+}
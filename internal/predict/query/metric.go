@@ -1,7 +1,12 @@
 package query
 
 import (
+	"context"
 	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/galaxy-future/cudgx/common/victoriametrics"
 	"github.com/galaxy-future/cudgx/internal/clients"
@@ -53,14 +58,86 @@ func InstanceCountByMetric(serviceName, clusterName, metricName string, begin, e
 
 //AverageMetricByVM 查询服务/集群的平均Metric值
 func AverageMetricByVM(serviceName, clusterName, metricName string, begin, end int64) (samples []ClusterSample, err error) {
-	promeQL := fmt.Sprintf("sum(%s{serviceName='%s',clusterName='%s'})/count(%s{serviceName='%s',clusterName='%s'}) by(metricName,serviceName,clusterName)", metricName, serviceName, clusterName, metricName, serviceName, clusterName)
-	res, err := Reader.QueryRange(promeQL, begin, end, consts.StepDuration)
+	return AverageMetricByMode(context.Background(), consts.MetricQueryModeRaw, serviceName, clusterName, metricName, begin, end)
+}
+
+//AverageMetricByMode 按查询方式查询服务/集群的平均Metric值，recording_rule 模式下 metricName 为记录规则名称
+func AverageMetricByMode(ctx context.Context, mode, serviceName, clusterName, metricName string, begin, end int64) (samples []ClusterSample, err error) {
+	return AverageMetricFromReader(ctx, Reader, nil, mode, serviceName, clusterName, metricName, begin, end)
+}
+
+//AverageMetricFromReader 使用指定的 Reader 查询服务/集群的平均Metric值，params 为后端特有的额外查询参数
+func AverageMetricFromReader(ctx context.Context, reader *victoriametrics.Reader, params url.Values, mode, serviceName, clusterName, metricName string, begin, end int64) (samples []ClusterSample, err error) {
+	return AverageMetricFromReaderWithStep(ctx, reader, params, mode, serviceName, clusterName, metricName, begin, end, consts.StepDuration)
+}
+
+//AverageMetricFromReaderWithStep 按 step 采样间隔查询服务/集群的平均Metric值
+func AverageMetricFromReaderWithStep(ctx context.Context, reader *victoriametrics.Reader, params url.Values, mode, serviceName, clusterName, metricName string, begin, end int64, step time.Duration) (samples []ClusterSample, err error) {
+	if reader == nil {
+		return nil, fmt.Errorf("metric reader is not initialized")
+	}
+	promeQL, err := AverageMetricPromQLWithLabels(mode, serviceName, clusterName, metricName, LabelFiltersFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	res, err := reader.QueryRangeWithParams(ctx, promeQL, begin, end, step, params)
 	if err != nil {
 		return nil, err
 	}
 	return convertSamples(res), nil
 }
 
+//AverageMetricPromQL 构造查询服务/集群平均Metric值的PromQL
+//raw 模式下对原始指标求平均，recording_rule 模式下直接读取已预聚合的记录规则
+func AverageMetricPromQL(mode, serviceName, clusterName, metricName string) (string, error) {
+	return AverageMetricPromQLWithLabels(mode, serviceName, clusterName, metricName, nil)
+}
+
+//AverageMetricPromQLWithLabels 构造查询服务/集群平均Metric值的PromQL，selector 中附加 filters 中的 label 条件
+func AverageMetricPromQLWithLabels(mode, serviceName, clusterName, metricName string, filters []LabelFilter) (string, error) {
+	matchers := fmt.Sprintf("serviceName='%s',clusterName='%s'%s", serviceName, clusterName, labelMatchers(filters))
+	switch mode {
+	case "", consts.MetricQueryModeRaw:
+		return fmt.Sprintf("sum(%s{%s})/count(%s{%s}) by(metricName,serviceName,clusterName)", metricName, matchers, metricName, matchers), nil
+	case consts.MetricQueryModeRecordingRule:
+		if metricName == "" {
+			return "", fmt.Errorf("recording rule metric name is empty")
+		}
+		return fmt.Sprintf("%s{%s}", metricName, matchers), nil
+	default:
+		return "", fmt.Errorf("unknown metric query mode : %s", mode)
+	}
+}
+
+//AverageInstanceMetricFromReader 按实例 ip 查询只带 instance label 的指标的平均值，结果的 clusterName 为 clusterName
+func AverageInstanceMetricFromReader(ctx context.Context, reader *victoriametrics.Reader, params url.Values, clusterName, metricName string, instanceIps []string, begin, end int64) (samples []ClusterSample, err error) {
+	if reader == nil {
+		return nil, fmt.Errorf("metric reader is not initialized")
+	}
+	promeQL, err := InstanceAverageMetricPromQL(clusterName, metricName, instanceIps)
+	if err != nil {
+		return nil, err
+	}
+	res, err := reader.QueryRangeWithParams(ctx, promeQL, begin, end, consts.StepDuration, params)
+	if err != nil {
+		return nil, err
+	}
+	return convertSamples(res), nil
+}
+
+//InstanceAverageMetricPromQL 构造按实例 ip 查询平均Metric值的PromQL，instance label 可以带端口
+func InstanceAverageMetricPromQL(clusterName, metricName string, instanceIps []string) (string, error) {
+	if len(instanceIps) == 0 {
+		return "", fmt.Errorf("instance ip list is empty")
+	}
+	quoted := make([]string, 0, len(instanceIps))
+	for _, ip := range instanceIps {
+		quoted = append(quoted, strings.ReplaceAll(regexp.QuoteMeta(ip), `\`, `\\`))
+	}
+	selector := fmt.Sprintf("%s{instance=~'(%s)(:[0-9]+)?'}", metricName, strings.Join(quoted, "|"))
+	return fmt.Sprintf("label_replace(sum(%s)/count(%s), 'clusterName', '%s', '', '')", selector, selector, clusterName), nil
+}
+
 //TotalMetricByVM 查询集群Metric
 func TotalMetricByVM(serviceName, clusterName, metricName string, begin, end int64) (samples []ClusterSample, err error) {
 	promeQL := fmt.Sprintf("sum(%s{serviceName='%s',clusterName='%s'}) by(metricName,serviceName,clusterName)", metricName, serviceName, clusterName)
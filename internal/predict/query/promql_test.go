@@ -0,0 +1,63 @@
+package query_test
+
+import (
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/query"
+	"github.com/onsi/ginkgo/extensions/table"
+	"github.com/onsi/gomega"
+)
+
+var _ = table.DescribeTable("AverageMetricPromQL",
+	func(mode, metricName, expected string, expectErr bool) {
+		promQL, err := query.AverageMetricPromQL(mode, "gf.cudgx.pi", "default", metricName)
+		if expectErr {
+			gomega.Expect(err).NotTo(gomega.BeNil())
+			return
+		}
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(promQL).To(gomega.Equal(expected))
+	},
+	table.Entry("raw", consts.MetricQueryModeRaw, "qps",
+		"sum(qps{serviceName='gf.cudgx.pi',clusterName='default'})/count(qps{serviceName='gf.cudgx.pi',clusterName='default'}) by(metricName,serviceName,clusterName)", false),
+	table.Entry("empty mode falls back to raw", "", "qps",
+		"sum(qps{serviceName='gf.cudgx.pi',clusterName='default'})/count(qps{serviceName='gf.cudgx.pi',clusterName='default'}) by(metricName,serviceName,clusterName)", false),
+	table.Entry("recording rule", consts.MetricQueryModeRecordingRule, "job:qps:rate5m",
+		"job:qps:rate5m{serviceName='gf.cudgx.pi',clusterName='default'}", false),
+	table.Entry("recording rule without metric name", consts.MetricQueryModeRecordingRule, "", "", true),
+	table.Entry("unknown mode", "rate", "qps", "", true),
+)
+
+var _ = table.DescribeTable("AverageMetricPromQLWithLabels",
+	func(mode, metricName, expected string) {
+		filters := []query.LabelFilter{{Label: "color", Value: "blue"}}
+		promQL, err := query.AverageMetricPromQLWithLabels(mode, "gf.cudgx.pi", "prod-blue", metricName, filters)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(promQL).To(gomega.Equal(expected))
+	},
+	table.Entry("raw", consts.MetricQueryModeRaw, "qps",
+		"sum(qps{serviceName='gf.cudgx.pi',clusterName='prod-blue',color='blue'})/count(qps{serviceName='gf.cudgx.pi',clusterName='prod-blue',color='blue'}) by(metricName,serviceName,clusterName)"),
+	table.Entry("recording rule", consts.MetricQueryModeRecordingRule, "job:qps:rate5m",
+		"job:qps:rate5m{serviceName='gf.cudgx.pi',clusterName='prod-blue',color='blue'}"),
+)
+
+var _ = table.DescribeTable("InstanceAverageMetricPromQL",
+	func(instanceIps []string, expected string, expectErr bool) {
+		promQL, err := query.InstanceAverageMetricPromQL("default", "qps", instanceIps)
+		if expectErr {
+			gomega.Expect(err).NotTo(gomega.BeNil())
+			return
+		}
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(promQL).To(gomega.Equal(expected))
+	},
+	table.Entry("instances", []string{"10.0.0.1", "10.0.0.2"},
+		`label_replace(sum(qps{instance=~'(10\\.0\\.0\\.1|10\\.0\\.0\\.2)(:[0-9]+)?'})/count(qps{instance=~'(10\\.0\\.0\\.1|10\\.0\\.0\\.2)(:[0-9]+)?'}), 'clusterName', 'default', '', '')`, false),
+	table.Entry("no instances", nil, "", true),
+)
+
+var _ = table.DescribeTable("InstanceQPSPromQL",
+	func(serviceName, clusterName, expected string) {
+		gomega.Expect(query.InstanceQPSPromQL(serviceName, clusterName)).To(gomega.Equal(expected))
+	},
+	table.Entry("grouped by serviceHost", "gf.cudgx.pi", "default", "sum(qps{serviceName='gf.cudgx.pi',clusterName='default'}) by(serviceHost)"),
+)
@@ -0,0 +1,23 @@
+package query
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+)
+
+type metricQueryModeKey struct{}
+
+//WithMetricQueryMode 返回指定指标查询方式的 ctx，指标后端查询原始指标值时按 mode 构造 PromQL，见 consts.MetricQueryModeRaw
+func WithMetricQueryMode(ctx context.Context, mode string) context.Context {
+	return context.WithValue(ctx, metricQueryModeKey{}, mode)
+}
+
+//MetricQueryModeFromContext 读取 ctx 中的指标查询方式，未设置时为 consts.MetricQueryModeRaw
+func MetricQueryModeFromContext(ctx context.Context) string {
+	mode, _ := ctx.Value(metricQueryModeKey{}).(string)
+	if mode == "" {
+		return consts.MetricQueryModeRaw
+	}
+	return mode
+}
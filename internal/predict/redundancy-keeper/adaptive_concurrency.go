@@ -0,0 +1,75 @@
+package redundancy_keeper
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	//fastTickConcurrencyRatio 调度耗时低于周期的该比例时增加并发数
+	fastTickConcurrencyRatio = 0.5
+	//slowTickConcurrencyRatio 调度耗时超过周期的该比例时减少并发数
+	slowTickConcurrencyRatio = 0.8
+	//defaultAdaptiveConcurrencyMaxFactor 未配置 AdaptiveConcurrencyMax 时，上限为 rule_concurrency 的倍数
+	defaultAdaptiveConcurrencyMaxFactor = 4
+)
+
+//AdjustConcurrency 根据一轮调度的耗时计算下一轮的并发数：耗时低于 period 的一半时加1，超过 period 的80%时减1，结果限定在 [min, max] 之间
+func AdjustConcurrency(current, min, max int, elapsed, period time.Duration) int {
+	next := current
+	switch {
+	case float64(elapsed) < fastTickConcurrencyRatio*float64(period):
+		next++
+	case float64(elapsed) > slowTickConcurrencyRatio*float64(period):
+		next--
+	}
+	if max > 0 && next > max {
+		next = max
+	}
+	if next < min {
+		next = min
+	}
+	return next
+}
+
+//concurrencyBounds AdaptiveConcurrency 的上下限，未配置时下限为1，上限为 rule_concurrency 的4倍
+func (keeper *ScheduleXRedundancyKeeper) concurrencyBounds() (int, int) {
+	min, max := keeper.AdaptiveConcurrencyMin, keeper.AdaptiveConcurrencyMax
+	if min <= 0 {
+		min = 1
+	}
+	if max <= 0 {
+		max = defaultAdaptiveConcurrencyMaxFactor * keeper.ruleConcurrency
+	}
+	if max < min {
+		max = min
+	}
+	return min, max
+}
+
+//observeTickConcurrency 开启 AdaptiveConcurrency 时根据调度耗时调整 concurrencyLock 的容量。
+//schedule 开始时取出 concurrencyLock，正在运行的规则仍然释放旧的 channel，替换后旧 channel 中的占用不会丢失，也不会计入新的 channel
+func (keeper *ScheduleXRedundancyKeeper) observeTickConcurrency(elapsed time.Duration) {
+	period := keeper.scheduleDuration()
+	keeper.lock.Lock()
+	defer keeper.lock.Unlock()
+	if !keeper.AdaptiveConcurrency || period <= 0 {
+		return
+	}
+	current := cap(keeper.concurrencyLock)
+	min, max := keeper.concurrencyBounds()
+	next := AdjustConcurrency(current, min, max, elapsed, period)
+	if next == current {
+		return
+	}
+	keeper.concurrencyLock = make(chan struct{}, next)
+	keeper.logger.Info("adjust rule concurrency", zap.Int("from", current), zap.Int("to", next), zap.Duration("elapsed", elapsed), zap.Duration("schedule_duration", period))
+}
+
+//ruleConcurrencyInUse 当前生效的规则并发数
+func (keeper *ScheduleXRedundancyKeeper) ruleConcurrencyInUse() int {
+	keeper.lock.RLock()
+	defer keeper.lock.RUnlock()
+	return cap(keeper.concurrencyLock)
+}
@@ -0,0 +1,74 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/types"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("AdaptiveConcurrency", func() {
+	ginkgo.It("increases on fast ticks and decreases on slow ticks", func() {
+		gomega.Expect(redundancy_keeper.AdjustConcurrency(10, 1, 40, 20*time.Second, time.Minute)).To(gomega.Equal(11))
+		gomega.Expect(redundancy_keeper.AdjustConcurrency(10, 1, 40, 50*time.Second, time.Minute)).To(gomega.Equal(9))
+		gomega.Expect(redundancy_keeper.AdjustConcurrency(10, 1, 40, 40*time.Second, time.Minute)).To(gomega.Equal(10))
+	})
+
+	ginkgo.It("respects the bounds", func() {
+		gomega.Expect(redundancy_keeper.AdjustConcurrency(40, 1, 40, time.Second, time.Minute)).To(gomega.Equal(40))
+		gomega.Expect(redundancy_keeper.AdjustConcurrency(2, 2, 40, time.Hour, time.Minute)).To(gomega.Equal(2))
+	})
+
+	ginkgo.It("adjusts the rule concurrency of the keeper after each tick", func() {
+		param := &config.Param{
+			RunDuration:            types.Duration{Duration: time.Minute},
+			RuleConcurrency:        2,
+			MinimalSampleCount:     1,
+			RunOnce:                true,
+			AdaptiveConcurrency:    true,
+			AdaptiveConcurrencyMax: 3,
+		}
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(param,
+			redundancy_keeper.WithScaler(&inFlightScaler{}),
+			redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return nil, nil }),
+		)).To(gomega.Succeed())
+		for i := 0; i < 2; i++ {
+			redundancy_keeper.Start(context.Background())
+		}
+		stats, err := redundancy_keeper.GetStats()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(stats.RuleConcurrency).To(gomega.Equal(3))
+
+		// 配置的并发数不变，热加载不会重置自动调整的结果
+		current, err := redundancy_keeper.GetParam()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(current.RuleConcurrency).To(gomega.Equal(2))
+		_, err = redundancy_keeper.Reload(current)
+		gomega.Expect(err).To(gomega.BeNil())
+		stats, _ = redundancy_keeper.GetStats()
+		gomega.Expect(stats.RuleConcurrency).To(gomega.Equal(3))
+
+		current.AdaptiveConcurrency = false
+		_, err = redundancy_keeper.Reload(current)
+		gomega.Expect(err).To(gomega.BeNil())
+		stats, _ = redundancy_keeper.GetStats()
+		gomega.Expect(stats.RuleConcurrency).To(gomega.Equal(2))
+	})
+
+	ginkgo.It("keeps the configured rule concurrency when disabled", func() {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RunDuration: types.Duration{Duration: time.Minute}, RuleConcurrency: 2, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(&inFlightScaler{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return nil, nil }),
+		)).To(gomega.Succeed())
+		redundancy_keeper.Start(context.Background())
+		stats, err := redundancy_keeper.GetStats()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(stats.RuleConcurrency).To(gomega.Equal(2))
+	})
+})
@@ -0,0 +1,19 @@
+package redundancy_keeper
+
+//middleWindow 只保留查询范围 [begin, end) 正中间 window 秒的采集点，window 不小于查询范围或时间戳与值数量不一致时保留全部
+func middleWindow(timestamps []int64, values []float64, begin, end, window int64) ([]int64, []float64) {
+	if window <= 0 || window >= end-begin || len(timestamps) != len(values) {
+		return timestamps, values
+	}
+	start := begin + (end-begin-window)/2
+	stop := start + window
+	var windowTimestamps []int64
+	var windowValues []float64
+	for i, timestamp := range timestamps {
+		if timestamp >= start && timestamp < stop {
+			windowTimestamps = append(windowTimestamps, timestamp)
+			windowValues = append(windowValues, values[i])
+		}
+	}
+	return windowTimestamps, windowValues
+}
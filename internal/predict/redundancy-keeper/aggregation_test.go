@@ -0,0 +1,78 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//noisyEdgesBackend 每秒一个点，查询范围首尾20秒的冗余度为0.5，中间为2
+type noisyEdgesBackend struct{}
+
+func (noisyEdgesBackend) QueryRedundancy(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+	cluster := &service.ClusterRedundancySeries{ClusterName: clusterName}
+	for timestamp := begin; timestamp < end; timestamp++ {
+		cluster.Timestamps = append(cluster.Timestamps, timestamp)
+		if timestamp < begin+20 || timestamp >= end-20 {
+			cluster.Values = append(cluster.Values, 0.5)
+		} else {
+			cluster.Values = append(cluster.Values, 2)
+		}
+	}
+	return &service.RedundancySeries{ServiceName: serviceName, MetricName: metricName, Clusters: []*service.ClusterRedundancySeries{cluster}}, nil
+}
+
+func (noisyEdgesBackend) QueryRawMetric(ctx context.Context, serviceName, clusterName, metricName string, start, end int64, step int) ([]float64, error) {
+	return nil, service.ErrRawMetricUnsupported
+}
+
+var _ = ginkgo.Describe("MetricAggregationWindowSeconds", func() {
+	var rule *model.PredictRule
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:               1000,
+			ServiceName:      "aggregation",
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 50,
+			ExecuteRatio:     100,
+			Status:           "enable",
+		}
+	})
+
+	run := func() *redundancy_keeper.ScheduleSummary {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(&inFlightScaler{}),
+			redundancy_keeper.WithMetricBackend(noisyEdgesBackend{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		return redundancy_keeper.Start(context.Background())
+	}
+
+	ginkgo.It("uses the whole lookback by default", func() {
+		summary := run()
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+	})
+
+	ginkgo.It("only aggregates the middle of the lookback", func() {
+		rule.MetricAggregationWindowSeconds = 10
+		summary := run()
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(0))
+
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		// lookback_duration 默认60s，metric_send_duration 默认5s，查询范围55秒
+		gomega.Expect(explain.Trace.Steps).To(gomega.ContainElement("aggregated 10 of 55 samples in the middle 10s"))
+		gomega.Expect(*explain.Trace.Redundancy).To(gomega.Equal(2.0))
+	})
+})
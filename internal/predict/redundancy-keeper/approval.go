@@ -0,0 +1,350 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"go.uber.org/zap"
+)
+
+//ErrApprovalNotPending 只能确认或拒绝等待确认的扩缩容
+var ErrApprovalNotPending = errors.New("approval is not pending")
+
+//ErrApprovalExpired 超过 approval_timeout_minutes 后不能再确认
+var ErrApprovalExpired = errors.New("approval is expired")
+
+//ApprovalStore 持久化等待人工确认的扩缩容
+type ApprovalStore interface {
+	CreatePendingApproval(approval *model.PendingApproval) error
+	GetPendingApprovalById(id int64) (*model.PendingApproval, error)
+	//GetOpenPendingApprovalByRuleId 规则等待确认或已确认未执行的记录，没有时返回 nil
+	GetOpenPendingApprovalByRuleId(ruleID int64) (*model.PendingApproval, error)
+	ListPendingApprovalsByStatus(status string) ([]*model.PendingApproval, error)
+	//TransitPendingApprovalStatus 只有当前状态为 from 时才改为 to，返回是否修改成功
+	TransitPendingApprovalStatus(id int64, from, to, approver, message string) (bool, error)
+}
+
+//modelApprovalStore 保存在 pending_approvals 表中
+type modelApprovalStore struct{}
+
+func (modelApprovalStore) CreatePendingApproval(approval *model.PendingApproval) error {
+	return model.CreatePendingApproval(approval)
+}
+
+func (modelApprovalStore) GetPendingApprovalById(id int64) (*model.PendingApproval, error) {
+	return model.GetPendingApprovalById(id)
+}
+
+func (modelApprovalStore) GetOpenPendingApprovalByRuleId(ruleID int64) (*model.PendingApproval, error) {
+	return model.GetOpenPendingApprovalByRuleId(ruleID)
+}
+
+func (modelApprovalStore) ListPendingApprovalsByStatus(status string) ([]*model.PendingApproval, error) {
+	return model.ListPendingApprovalsByStatus(status)
+}
+
+func (modelApprovalStore) TransitPendingApprovalStatus(id int64, from, to, approver, message string) (bool, error) {
+	return model.TransitPendingApprovalStatus(id, from, to, approver, message)
+}
+
+//ApprovalNotifier 扩缩容等待人工确认时发送通知
+type ApprovalNotifier interface {
+	NotifyApprovalRequested(ctx context.Context, e *event.ApprovalRequestedEvent) error
+}
+
+//WithApprovalStore 指定保存待确认扩缩容的 ApprovalStore，为空时保存到数据库
+func WithApprovalStore(store ApprovalStore) Option {
+	return func(keeper *ScheduleXRedundancyKeeper) {
+		if store != nil {
+			keeper.approvals = store
+		}
+	}
+}
+
+//WithApprovalNotifier 增加一个待确认通知的接收方，可以多次指定
+func WithApprovalNotifier(notifier ApprovalNotifier) Option {
+	return func(keeper *ScheduleXRedundancyKeeper) {
+		if notifier != nil {
+			keeper.approvalNotifiers = append(keeper.approvalNotifiers, notifier)
+		}
+	}
+}
+
+//requiresReview 开启 review_required 的规则单次变更超过 review_threshold 时需要人工确认
+func requiresReview(rule *model.PredictRule, count int) bool {
+	return rule.ReviewRequired && count > rule.ReviewThreshold
+}
+
+//requestApproval 创建待确认记录并发送通知，规则已有未完成的确认时不再重复创建
+func (keeper *ScheduleXRedundancyKeeper) requestApproval(ctx context.Context, rule *model.PredictRule, action string, count, currentCount int, redundancy float64, trace *RuleTrace) error {
+	open, err := keeper.approvals.GetOpenPendingApprovalByRuleId(rule.Id)
+	if err != nil {
+		return fmt.Errorf("query pending approval failed , %w", err)
+	}
+	if open != nil {
+		trace.finish(TraceOutcomeSkipped, "%s of %d instances exceeds review_threshold %d, approval %d is %s", action, count, rule.ReviewThreshold, open.Id, open.Status)
+		return nil
+	}
+	now := keeper.now()
+	approval := &model.PendingApproval{
+		RuleId:        rule.Id,
+		ServiceName:   rule.ServiceName,
+		ClusterName:   rule.ClusterName,
+		Action:        action,
+		Count:         count,
+		InstanceCount: currentCount,
+		Redundancy:    redundancy,
+		Status:        consts.ApprovalStatusPending,
+		ExpireAt:      now.Add(keeper.ApprovalTimeout).Unix(),
+		CreatedTime:   now.Unix(),
+	}
+	if err := keeper.approvals.CreatePendingApproval(approval); err != nil {
+		return fmt.Errorf("create pending approval failed , %w", err)
+	}
+	keeper.notifyApprovalRequested(ctx, approval)
+	trace.finish(TraceOutcomeSkipped, "%s of %d instances exceeds review_threshold %d, waiting for approval %d", action, count, rule.ReviewThreshold, approval.Id)
+	return nil
+}
+
+func (keeper *ScheduleXRedundancyKeeper) notifyApprovalRequested(ctx context.Context, approval *model.PendingApproval) {
+	e := &event.ApprovalRequestedEvent{
+		ApprovalId:    approval.Id,
+		RuleId:        approval.RuleId,
+		ServiceName:   approval.ServiceName,
+		ClusterName:   approval.ClusterName,
+		ScaleAction:   approval.Action,
+		Count:         approval.Count,
+		InstanceCount: approval.InstanceCount,
+		Redundancy:    approval.Redundancy,
+		ExpireAt:      approval.ExpireAt,
+		Timestamp:     approval.CreatedTime,
+	}
+	if keeper.ApprovalBaseURL != "" {
+		base := strings.TrimRight(keeper.ApprovalBaseURL, "/")
+		e.ApproveURL = fmt.Sprintf("%s/api/v1/cudgx/approvals/%d/approve", base, approval.Id)
+		e.RejectURL = fmt.Sprintf("%s/api/v1/cudgx/approvals/%d/reject", base, approval.Id)
+	}
+	for _, notifier := range keeper.approvalNotifiers {
+		if err := notifier.NotifyApprovalRequested(ctx, e); err != nil {
+			keeper.logger.Error("notify approval requested failed", zap.Int64("approval_id", approval.Id), zap.Error(err))
+		}
+	}
+}
+
+//ApprovePendingAction 确认扩缩容，由后台在下次检查时执行
+func (keeper *ScheduleXRedundancyKeeper) ApprovePendingAction(approvalID int64, approver string) error {
+	return keeper.reviewPendingAction(approvalID, approver, consts.ApprovalStatusApproved)
+}
+
+//RejectPendingAction 拒绝扩缩容，规则下次超过阈值时重新发起确认
+func (keeper *ScheduleXRedundancyKeeper) RejectPendingAction(approvalID int64, approver string) error {
+	return keeper.reviewPendingAction(approvalID, approver, consts.ApprovalStatusRejected)
+}
+
+func (keeper *ScheduleXRedundancyKeeper) reviewPendingAction(approvalID int64, approver, status string) error {
+	if approver == "" {
+		return errors.New("approver can not be empty")
+	}
+	approval, err := keeper.approvals.GetPendingApprovalById(approvalID)
+	if err != nil {
+		return err
+	}
+	if approval.Status != consts.ApprovalStatusPending {
+		return fmt.Errorf("%w, approval %d is %s", ErrApprovalNotPending, approvalID, approval.Status)
+	}
+	if keeper.now().Unix() > approval.ExpireAt {
+		if _, err := keeper.approvals.TransitPendingApprovalStatus(approvalID, consts.ApprovalStatusPending, consts.ApprovalStatusExpired, "", ""); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w, approval %d expired at %s", ErrApprovalExpired, approvalID, time.Unix(approval.ExpireAt, 0).Format(time.RFC3339))
+	}
+	ok, err := keeper.approvals.TransitPendingApprovalStatus(approvalID, consts.ApprovalStatusPending, status, approver, "")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w, approval %d was reviewed by others", ErrApprovalNotPending, approvalID)
+	}
+	keeper.logger.Info("pending action reviewed", zap.Int64("approval_id", approvalID), zap.String("status", status), zap.String("approver", approver))
+	return nil
+}
+
+//ListPendingApprovals 查询指定状态的扩缩容确认记录
+func (keeper *ScheduleXRedundancyKeeper) ListPendingApprovals(status string) ([]*model.PendingApproval, error) {
+	return keeper.approvals.ListPendingApprovalsByStatus(status)
+}
+
+//ExecuteApprovedActions 将过期的确认置为 expired，重新检查并执行已确认的扩缩容
+func (keeper *ScheduleXRedundancyKeeper) ExecuteApprovedActions(ctx context.Context) error {
+	pending, err := keeper.approvals.ListPendingApprovalsByStatus(consts.ApprovalStatusPending)
+	if err != nil {
+		return err
+	}
+	now := keeper.now().Unix()
+	for _, approval := range pending {
+		if now > approval.ExpireAt {
+			if _, err := keeper.approvals.TransitPendingApprovalStatus(approval.Id, consts.ApprovalStatusPending, consts.ApprovalStatusExpired, "", ""); err != nil {
+				return err
+			}
+		}
+	}
+
+	approved, err := keeper.approvals.ListPendingApprovalsByStatus(consts.ApprovalStatusApproved)
+	if err != nil {
+		return err
+	}
+	if len(approved) == 0 {
+		return nil
+	}
+	rules, err := keeper.listRules()
+	if err != nil {
+		return err
+	}
+	rulesById := make(map[int64]*model.PredictRule, len(rules))
+	for _, rule := range rules {
+		rulesById[rule.Id] = rule
+	}
+	for _, approval := range approved {
+		if err := keeper.executeApproval(ctx, approval, rulesById[approval.RuleId]); err != nil {
+			keeper.logger.Error("execute approved action failed", zap.Int64("approval_id", approval.Id), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+//executeApproval 执行前重新检查规则状态、调度状态和实例数上下限，检查不通过时记录原因，不再执行
+func (keeper *ScheduleXRedundancyKeeper) executeApproval(ctx context.Context, approval *model.PendingApproval, rule *model.PredictRule) error {
+	if rule == nil || rule.Status != consts.RuleStatusEnable {
+		return keeper.failApproval(approval, consts.ApprovalStatusApproved, "rule is not enabled")
+	}
+	if rule.MultiClusterMode {
+		// 多集群规则在发起确认时选定的集群上执行
+		rule = clusterRule(rule, approval.ClusterName)
+	}
+	canSchedule, err := keeper.scalerFor(rule).CanServiceSchedule(ctx, rule.ServiceName, rule.ClusterName)
+	if err != nil {
+		return fmt.Errorf("query service schedule failed , %w", err)
+	}
+	if !canSchedule {
+		// schedulx 调度结束后再执行
+		return nil
+	}
+	//clusterCount 执行扩缩容的集群的实例数，多集群规则的 currentCount 为所有集群的实例总数
+	var currentCount, clusterCount int
+	if rule.MultiClusterMode {
+		// 实例数上下限按所有集群的实例总数检查
+		var counts map[string]int
+		if counts, currentCount, err = keeper.clusterInstanceCounts(ctx, rule); err != nil {
+			return err
+		}
+		clusterCount = counts[rule.ClusterName]
+	} else if currentCount, err = keeper.scalerFor(rule).GetServiceInstanceCount(ctx, rule.ServiceName, rule.ClusterName); err != nil {
+		return fmt.Errorf("query service instance count failed , %w", err)
+	} else {
+		clusterCount = currentCount
+	}
+	count := approval.Count
+	if approval.Action == event.ActionScaleUp && currentCount+count > rule.MaxInstanceCount {
+		count = rule.MaxInstanceCount - currentCount
+	}
+	if approval.Action == event.ActionScaleDown && currentCount-count < rule.MinInstanceCount {
+		count = currentCount - rule.MinInstanceCount
+	}
+	if approval.Action == event.ActionScaleDown {
+		count = min(count, clusterCount)
+	}
+	if count <= 0 {
+		return keeper.failApproval(approval, consts.ApprovalStatusApproved, fmt.Sprintf("%s is not needed with %d instances", approval.Action, currentCount))
+	}
+
+	// 先改为 executed，避免多个实例重复执行
+	ok, err := keeper.approvals.TransitPendingApprovalStatus(approval.Id, consts.ApprovalStatusApproved, consts.ApprovalStatusExecuted, "", "")
+	if err != nil || !ok {
+		return err
+	}
+	key := idempotencyKey(rule.ServiceName, rule.ClusterName, count, approval.Id)
+	trace := &RuleTrace{RuleId: rule.Id, ServiceName: rule.ServiceName, ClusterName: rule.ClusterName, Timestamp: keeper.now().Unix()}
+	if approval.Action == event.ActionScaleUp {
+		keeper.recordExpansion(rule, keeper.now())
+		err = keeper.scalerFor(rule).ExpandService(ctx, rule.ServiceName, rule.ClusterName, count, key)
+	} else {
+		err = keeper.shrinkService(ctx, rule, count, key, trace)
+	}
+	if err != nil {
+		return keeper.failApproval(approval, consts.ApprovalStatusExecuted, err.Error())
+	}
+	keeper.publishScalingEvent(ctx, rule, approval.Action, count, clusterCount, approval.Redundancy)
+	if approval.Action == event.ActionScaleDown {
+		keeper.verifyShrinkLater(ctx, rule, count, clusterCount)
+	}
+	keeper.logger.Info("approved action executed", zap.Int64("approval_id", approval.Id), zap.String("action", approval.Action),
+		zap.String("service", rule.ServiceName), zap.String("cluster", rule.ClusterName), zap.Int("count", count))
+	return nil
+}
+
+func (keeper *ScheduleXRedundancyKeeper) failApproval(approval *model.PendingApproval, from, message string) error {
+	_, err := keeper.approvals.TransitPendingApprovalStatus(approval.Id, from, consts.ApprovalStatusFailed, "", message)
+	return err
+}
+
+//startApprovalExecutor 每隔 consts.ApprovalCheckInterval 执行一次 ExecuteApprovedActions，直到 ctx 结束
+func (keeper *ScheduleXRedundancyKeeper) startApprovalExecutor(ctx context.Context) {
+	ticker := time.NewTicker(consts.ApprovalCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := keeper.ExecuteApprovedActions(ctx); err != nil {
+				keeper.logger.Error("execute approved actions failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+//ApprovePendingAction 确认扩缩容
+func ApprovePendingAction(approvalID int64, approver string) error {
+	if redundancyKeeper == nil {
+		return errors.New("redundancy keeper is not initialized")
+	}
+	return redundancyKeeper.ApprovePendingAction(approvalID, approver)
+}
+
+//RejectPendingAction 拒绝扩缩容
+func RejectPendingAction(approvalID int64, approver string) error {
+	if redundancyKeeper == nil {
+		return errors.New("redundancy keeper is not initialized")
+	}
+	return redundancyKeeper.RejectPendingAction(approvalID, approver)
+}
+
+//ListPendingApprovals 查询指定状态的扩缩容确认记录
+func ListPendingApprovals(status string) ([]*model.PendingApproval, error) {
+	if redundancyKeeper == nil {
+		return nil, errors.New("redundancy keeper is not initialized")
+	}
+	return redundancyKeeper.ListPendingApprovals(status)
+}
+
+//ExecuteApprovedActions 重新检查并执行已确认的扩缩容
+func ExecuteApprovedActions(ctx context.Context) error {
+	if redundancyKeeper == nil {
+		return errors.New("redundancy keeper is not initialized")
+	}
+	return redundancyKeeper.ExecuteApprovedActions(ctx)
+}
+
+//StartApprovalExecutor 定期执行已确认的扩缩容，直到 ctx 结束
+func StartApprovalExecutor(ctx context.Context) {
+	if redundancyKeeper == nil {
+		return
+	}
+	redundancyKeeper.startApprovalExecutor(ctx)
+}
@@ -0,0 +1,218 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//memoryApprovalStore 在内存中保存待确认的扩缩容
+type memoryApprovalStore struct {
+	lock      sync.Mutex
+	approvals []*model.PendingApproval
+}
+
+func (store *memoryApprovalStore) CreatePendingApproval(approval *model.PendingApproval) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	approval.Id = int64(len(store.approvals) + 1)
+	store.approvals = append(store.approvals, approval)
+	return nil
+}
+
+func (store *memoryApprovalStore) GetPendingApprovalById(id int64) (*model.PendingApproval, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for _, approval := range store.approvals {
+		if approval.Id == id {
+			copied := *approval
+			return &copied, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (store *memoryApprovalStore) GetOpenPendingApprovalByRuleId(ruleID int64) (*model.PendingApproval, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for _, approval := range store.approvals {
+		if approval.RuleId == ruleID && (approval.Status == consts.ApprovalStatusPending || approval.Status == consts.ApprovalStatusApproved) {
+			copied := *approval
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (store *memoryApprovalStore) ListPendingApprovalsByStatus(status string) ([]*model.PendingApproval, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	var approvals []*model.PendingApproval
+	for _, approval := range store.approvals {
+		if approval.Status == status {
+			copied := *approval
+			approvals = append(approvals, &copied)
+		}
+	}
+	return approvals, nil
+}
+
+func (store *memoryApprovalStore) TransitPendingApprovalStatus(id int64, from, to, approver, message string) (bool, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for _, approval := range store.approvals {
+		if approval.Id == id && approval.Status == from {
+			approval.Status = to
+			approval.Message = message
+			if approver != "" {
+				approval.Approver = approver
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+//recordingApprovalNotifier 记录收到的确认通知
+type recordingApprovalNotifier struct {
+	events []*event.ApprovalRequestedEvent
+}
+
+func (notifier *recordingApprovalNotifier) NotifyApprovalRequested(ctx context.Context, e *event.ApprovalRequestedEvent) error {
+	notifier.events = append(notifier.events, e)
+	return nil
+}
+
+var _ = ginkgo.Describe("ReviewRequired", func() {
+	var rule *model.PredictRule
+	var scaler *inFlightScaler
+	var store *memoryApprovalStore
+	var notifier *recordingApprovalNotifier
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:               1100,
+			ServiceName:      "review",
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 50,
+			ExecuteRatio:     100,
+			ReviewRequired:   true,
+			ReviewThreshold:  20,
+			Status:           consts.RuleStatusEnable,
+		}
+		scaler = &inFlightScaler{}
+		store = &memoryApprovalStore{}
+		notifier = &recordingApprovalNotifier{}
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true, ApprovalBaseURL: "http://cudgx-api/"},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+			redundancy_keeper.WithApprovalStore(store),
+			redundancy_keeper.WithApprovalNotifier(notifier),
+		)).To(gomega.Succeed())
+	})
+
+	ginkgo.It("waits for approval instead of scaling above the threshold", func() {
+		summary := redundancy_keeper.Start(context.Background())
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(0))
+		gomega.Expect(scaler.expanded).To(gomega.Equal(0))
+		gomega.Expect(store.approvals).To(gomega.HaveLen(1))
+		gomega.Expect(store.approvals[0].Action).To(gomega.Equal(event.ActionScaleUp))
+		gomega.Expect(store.approvals[0].Count).To(gomega.Equal(30))
+		gomega.Expect(store.approvals[0].Status).To(gomega.Equal(consts.ApprovalStatusPending))
+		gomega.Expect(notifier.events).To(gomega.HaveLen(1))
+		gomega.Expect(notifier.events[0].ApproveURL).To(gomega.Equal("http://cudgx-api/api/v1/cudgx/approvals/1/approve"))
+
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(explain.Reason).To(gomega.Equal("skipped: scale_up of 30 instances exceeds review_threshold 20, waiting for approval 1"))
+
+		// 未确认前不重复发起
+		redundancy_keeper.Start(context.Background())
+		gomega.Expect(store.approvals).To(gomega.HaveLen(1))
+		gomega.Expect(notifier.events).To(gomega.HaveLen(1))
+	})
+
+	ginkgo.It("scales directly within the threshold", func() {
+		rule.ReviewThreshold = 30
+		summary := redundancy_keeper.Start(context.Background())
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(store.approvals).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("executes the action once approved", func() {
+		redundancy_keeper.Start(context.Background())
+		gomega.Expect(redundancy_keeper.ApprovePendingAction(1, "")).NotTo(gomega.BeNil())
+		gomega.Expect(redundancy_keeper.ApprovePendingAction(1, "alice")).To(gomega.BeNil())
+		gomega.Expect(redundancy_keeper.ExecuteApprovedActions(context.Background())).To(gomega.BeNil())
+		gomega.Expect(scaler.expanded).To(gomega.Equal(1))
+		gomega.Expect(store.approvals[0].Status).To(gomega.Equal(consts.ApprovalStatusExecuted))
+		gomega.Expect(store.approvals[0].Approver).To(gomega.Equal("alice"))
+
+		err := redundancy_keeper.RejectPendingAction(1, "bob")
+		gomega.Expect(errors.Is(err, redundancy_keeper.ErrApprovalNotPending)).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("does not execute rejected or expired actions", func() {
+		redundancy_keeper.Start(context.Background())
+		gomega.Expect(redundancy_keeper.RejectPendingAction(1, "bob")).To(gomega.BeNil())
+
+		redundancy_keeper.Start(context.Background())
+		gomega.Expect(store.approvals).To(gomega.HaveLen(2))
+		store.approvals[1].ExpireAt = 1
+		err := redundancy_keeper.ApprovePendingAction(2, "alice")
+		gomega.Expect(errors.Is(err, redundancy_keeper.ErrApprovalExpired)).To(gomega.BeTrue())
+
+		gomega.Expect(redundancy_keeper.ExecuteApprovedActions(context.Background())).To(gomega.BeNil())
+		gomega.Expect(scaler.expanded).To(gomega.Equal(0))
+		gomega.Expect(store.approvals[0].Status).To(gomega.Equal(consts.ApprovalStatusRejected))
+		gomega.Expect(store.approvals[1].Status).To(gomega.Equal(consts.ApprovalStatusExpired))
+	})
+
+	ginkgo.It("verifies an approved shrink like an automatic one", func() {
+		rule.ReviewThreshold = 0
+		rule.VerifyShrinkAfterSeconds = 1
+		verifications := &recordingShrinkVerificationNotifier{}
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricBackend(highRedundancyBackend{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+			redundancy_keeper.WithApprovalStore(store),
+			redundancy_keeper.WithShrinkVerificationNotifier(verifications),
+		)).To(gomega.Succeed())
+		redundancy_keeper.Start(context.Background())
+		gomega.Expect(store.approvals).To(gomega.HaveLen(1))
+		gomega.Expect(store.approvals[0].Action).To(gomega.Equal(event.ActionScaleDown))
+		gomega.Expect(redundancy_keeper.ApprovePendingAction(1, "alice")).To(gomega.BeNil())
+		gomega.Expect(redundancy_keeper.ExecuteApprovedActions(context.Background())).To(gomega.BeNil())
+		gomega.Expect(store.approvals[0].Status).To(gomega.Equal(consts.ApprovalStatusExecuted))
+
+		// inFlightScaler 缩容后实例数仍为10
+		gomega.Eventually(verifications.received, 3*time.Second).Should(gomega.HaveLen(1))
+		gomega.Expect(verifications.received()[0].InstanceCountBefore).To(gomega.Equal(10))
+	})
+
+	ginkgo.It("reduces the approved count to max_instance_count", func() {
+		redundancy_keeper.Start(context.Background())
+		gomega.Expect(redundancy_keeper.ApprovePendingAction(1, "alice")).To(gomega.BeNil())
+		rule.MaxInstanceCount = 10
+		gomega.Expect(redundancy_keeper.ExecuteApprovedActions(context.Background())).To(gomega.BeNil())
+		gomega.Expect(scaler.expanded).To(gomega.Equal(0))
+		gomega.Expect(store.approvals[0].Status).To(gomega.Equal(consts.ApprovalStatusFailed))
+		gomega.Expect(store.approvals[0].Message).To(gomega.Equal("scale_up is not needed with 10 instances"))
+	})
+})
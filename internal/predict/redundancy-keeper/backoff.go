@@ -0,0 +1,121 @@
+package redundancy_keeper
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	//slowTickRatio 调度耗时超过周期的该比例视为慢
+	slowTickRatio = 0.8
+	//slowTicksToBackoff 连续多少次慢调度后周期翻倍
+	slowTicksToBackoff = 3
+	//fastTicksToRecover 连续多少次快调度后周期减半
+	fastTicksToRecover = 10
+)
+
+//ScheduleBackoff 根据每次调度的耗时自动调整调度周期，避免上一轮未结束时下一轮已经触发
+type ScheduleBackoff struct {
+	lock      sync.Mutex
+	base      time.Duration
+	min       time.Duration
+	max       time.Duration
+	current   time.Duration
+	slowTicks int
+	fastTicks int
+}
+
+//NewScheduleBackoff 新建 ScheduleBackoff，初始周期为 base 并限定在 [min, max] 之间
+func NewScheduleBackoff(base, min, max time.Duration) *ScheduleBackoff {
+	backoff := &ScheduleBackoff{base: base, min: min, max: max}
+	backoff.Reset()
+	return backoff
+}
+
+//Duration 当前生效的调度周期
+func (backoff *ScheduleBackoff) Duration() time.Duration {
+	backoff.lock.Lock()
+	defer backoff.lock.Unlock()
+	return backoff.current
+}
+
+//Reset 恢复为配置的调度周期
+func (backoff *ScheduleBackoff) Reset() {
+	backoff.lock.Lock()
+	defer backoff.lock.Unlock()
+	backoff.current = backoff.clamp(backoff.base)
+	backoff.slowTicks = 0
+	backoff.fastTicks = 0
+}
+
+//Observe 记录一次调度耗时，返回调整后的周期以及周期是否发生变化
+func (backoff *ScheduleBackoff) Observe(elapsed time.Duration) (time.Duration, bool) {
+	backoff.lock.Lock()
+	defer backoff.lock.Unlock()
+	if float64(elapsed) > slowTickRatio*float64(backoff.current) {
+		backoff.slowTicks++
+		backoff.fastTicks = 0
+		if backoff.slowTicks >= slowTicksToBackoff {
+			backoff.slowTicks = 0
+			return backoff.adjust(backoff.current * 2)
+		}
+		return backoff.current, false
+	}
+	backoff.fastTicks++
+	backoff.slowTicks = 0
+	if backoff.fastTicks >= fastTicksToRecover {
+		backoff.fastTicks = 0
+		return backoff.adjust(backoff.current / 2)
+	}
+	return backoff.current, false
+}
+
+func (backoff *ScheduleBackoff) adjust(duration time.Duration) (time.Duration, bool) {
+	duration = backoff.clamp(duration)
+	if duration == backoff.current {
+		return backoff.current, false
+	}
+	backoff.current = duration
+	return duration, true
+}
+
+func (backoff *ScheduleBackoff) clamp(duration time.Duration) time.Duration {
+	if backoff.min > 0 && duration < backoff.min {
+		return backoff.min
+	}
+	if backoff.max > 0 && duration > backoff.max {
+		return backoff.max
+	}
+	return duration
+}
+
+//resetBackoff 按当前配置重建自动调整状态，恢复为配置的调度周期
+func (keeper *ScheduleXRedundancyKeeper) resetBackoff() {
+	keeper.lock.Lock()
+	defer keeper.lock.Unlock()
+	if !keeper.BackoffScheduleDuration {
+		keeper.backoff = nil
+		return
+	}
+	keeper.backoff = NewScheduleBackoff(keeper.ScheduleDuration, keeper.MinScheduleDuration, keeper.MaxScheduleDuration)
+}
+
+//observeScheduleElapsed 记录一次调度耗时，调度周期变化时返回 true
+func (keeper *ScheduleXRedundancyKeeper) observeScheduleElapsed(elapsed time.Duration) bool {
+	keeper.lock.RLock()
+	backoff := keeper.backoff
+	keeper.lock.RUnlock()
+	if backoff == nil {
+		return false
+	}
+	previous := backoff.Duration()
+	duration, changed := backoff.Observe(elapsed)
+	if !changed {
+		return false
+	}
+	keeper.metrics.ScheduleDuration.Set(duration.Seconds())
+	keeper.logger.Info("adjust schedule duration", zap.Duration("from", previous), zap.Duration("to", duration), zap.Duration("elapsed", elapsed))
+	return true
+}
@@ -0,0 +1,65 @@
+package redundancy_keeper_test
+
+import (
+	"time"
+
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("ScheduleBackoff", func() {
+	var backoff *redundancy_keeper.ScheduleBackoff
+
+	ginkgo.BeforeEach(func() {
+		backoff = redundancy_keeper.NewScheduleBackoff(time.Minute, 30*time.Second, 4*time.Minute)
+	})
+
+	ginkgo.It("doubles after 3 consecutive slow ticks", func() {
+		for i := 0; i < 2; i++ {
+			_, changed := backoff.Observe(55 * time.Second)
+			gomega.Expect(changed).To(gomega.BeFalse())
+		}
+		duration, changed := backoff.Observe(55 * time.Second)
+		gomega.Expect(changed).To(gomega.BeTrue())
+		gomega.Expect(duration).To(gomega.Equal(2 * time.Minute))
+	})
+
+	ginkgo.It("starts counting again after a fast tick", func() {
+		backoff.Observe(55 * time.Second)
+		backoff.Observe(55 * time.Second)
+		backoff.Observe(time.Second)
+		_, changed := backoff.Observe(55 * time.Second)
+		gomega.Expect(changed).To(gomega.BeFalse())
+		gomega.Expect(backoff.Duration()).To(gomega.Equal(time.Minute))
+	})
+
+	ginkgo.It("halves after 10 consecutive fast ticks and respects the bounds", func() {
+		for i := 0; i < 9; i++ {
+			backoff.Observe(time.Second)
+		}
+		duration, changed := backoff.Observe(time.Second)
+		gomega.Expect(changed).To(gomega.BeTrue())
+		gomega.Expect(duration).To(gomega.Equal(30 * time.Second))
+
+		for i := 0; i < 10; i++ {
+			_, changed = backoff.Observe(time.Second)
+		}
+		gomega.Expect(changed).To(gomega.BeFalse())
+		gomega.Expect(backoff.Duration()).To(gomega.Equal(30 * time.Second))
+
+		for i := 0; i < 12; i++ {
+			backoff.Observe(time.Hour)
+		}
+		gomega.Expect(backoff.Duration()).To(gomega.Equal(4 * time.Minute))
+	})
+
+	ginkgo.It("resets to the base duration", func() {
+		for i := 0; i < 3; i++ {
+			backoff.Observe(time.Hour)
+		}
+		gomega.Expect(backoff.Duration()).To(gomega.Equal(2 * time.Minute))
+		backoff.Reset()
+		gomega.Expect(backoff.Duration()).To(gomega.Equal(time.Minute))
+	})
+})
@@ -0,0 +1,182 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"runtime"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/stats"
+	"github.com/galaxy-future/cudgx/common/types"
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/clients/testutil"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"go.uber.org/zap"
+)
+
+const (
+	//benchmarkInitialInstances 合成规则的服务集群初始实例数
+	benchmarkInitialInstances = 20
+	//benchmarkRuleConcurrency RunBenchmark 并行执行的规则数
+	benchmarkRuleConcurrency = 10
+	//benchmarkTickDuration 每轮调度推进的模拟时间
+	benchmarkTickDuration = time.Minute
+)
+
+//BenchmarkResult RunBenchmark 的测量结果
+type BenchmarkResult struct {
+	Ticks             int     `json:"ticks"`
+	P50TickDurationMs float64 `json:"p50_tick_duration_ms"`
+	P99TickDurationMs float64 `json:"p99_tick_duration_ms"`
+	//RulesPerSecond 每秒执行完成的规则数
+	RulesPerSecond float64 `json:"rules_per_second"`
+	//TotalScalingCalls 模拟 schedulx 收到的扩容和缩容请求数
+	TotalScalingCalls int64 `json:"total_scaling_calls"`
+	//RuleErrors 执行失败的规则次数，不为0时耗时可能偏低
+	RuleErrors int `json:"rule_errors"`
+	//MemAllocMB 运行期间分配的堆内存
+	MemAllocMB float64 `json:"mem_alloc_mb"`
+}
+
+//BenchmarkSchedule 用1000条合成规则执行 b.N 轮调度，go test -bench Schedule 报告每轮耗时的分位数和每秒执行的规则数
+func BenchmarkSchedule(b *testing.B) {
+	result := RunBenchmark(context.Background(), 1000, 0, b.N)
+	b.ReportMetric(result.P50TickDurationMs, "p50-ms/tick")
+	b.ReportMetric(result.P99TickDurationMs, "p99-ms/tick")
+	b.ReportMetric(result.RulesPerSecond, "rules/s")
+	b.ReportMetric(float64(result.TotalScalingCalls)/float64(result.Ticks), "scaling-calls/tick")
+}
+
+//RunBenchmark 用 numRules 条合成规则和模拟的 schedulx 服务连续执行调度 duration，且至少执行 minTicks 轮（至少一轮），测量包含 HTTP 客户端、
+//缓存和 singleflight 在内的每轮调度耗时；每轮调度推进一分钟的模拟时间，流量按正弦波动使规则持续扩缩容。
+//bridgx/schedulx 客户端会指向模拟服务且结束后不恢复；numRules 不大于0时返回 nil
+func RunBenchmark(ctx context.Context, numRules int, duration time.Duration, minTicks int) *BenchmarkResult {
+	if numRules <= 0 {
+		return nil
+	}
+	server := testutil.NewSchedulxServer(benchmarkInitialInstances)
+	defer server.Close()
+	clients.InitializeBridgxClient(server.URL)
+	clients.InitializeSchedulxClient(server.URL)
+
+	rules := benchmarkRules(numRules)
+	// 基准测试的调度不计入进程的指标，nil Registerer 不会返回错误
+	metrics, _ := NewMetricsBundle(nil)
+	start := time.Now()
+	var tick atomic.Int64
+	keeper := newRedundancyKeeper(&config.Param{
+		RunDuration:        types.Duration{Duration: benchmarkTickDuration},
+		RuleConcurrency:    benchmarkRuleConcurrency,
+		MinimalSampleCount: 1,
+	},
+		WithMetricsBundle(metrics),
+		WithLogger(zap.NewNop()),
+		WithRuleErrorStore(discardRuleErrorStore{}),
+		WithRuleLister(func() ([]*model.PredictRule, error) { return rules, nil }),
+		WithMetricBackend(&benchmarkMetricBackend{server: server}),
+	)
+	keeper.clusterTypeScalers = nil
+	keeper.now = func() time.Time { return start.Add(time.Duration(tick.Load()) * benchmarkTickDuration) }
+	keeper.publish = func(*event.ScalingEvent) {}
+
+	var memBefore, memAfter runtime.MemStats
+	runtime.ReadMemStats(&memBefore)
+	result := &BenchmarkResult{}
+	var durations []float64
+	var evaluated int
+	for {
+		tick.Add(1)
+		tickBegin := time.Now()
+		summary, err := keeper.schedule()
+		durations = append(durations, float64(time.Since(tickBegin).Microseconds())/1000)
+		if err == nil {
+			evaluated += summary.RulesEvaluated
+			result.RuleErrors += summary.RulesErrored
+		}
+		if (time.Since(start) >= duration && len(durations) >= minTicks) || ctx.Err() != nil {
+			break
+		}
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&memAfter)
+
+	slices.Sort(durations)
+	result.Ticks = len(durations)
+	result.P50TickDurationMs = stats.Quantile(durations, 0.5)
+	result.P99TickDurationMs = stats.Quantile(durations, 0.99)
+	result.RulesPerSecond = float64(evaluated) / elapsed.Seconds()
+	result.TotalScalingCalls = server.ScalingCalls()
+	result.MemAllocMB = float64(memAfter.TotalAlloc-memBefore.TotalAlloc) / 1024 / 1024
+	return result
+}
+
+//benchmarkRules 每条规则对应一个独立的服务集群
+func benchmarkRules(numRules int) []*model.PredictRule {
+	rules := make([]*model.PredictRule, 0, numRules)
+	for i := 0; i < numRules; i++ {
+		rules = append(rules, &model.PredictRule{
+			Id:               int64(i + 1),
+			Name:             fmt.Sprintf("benchmark-%d", i),
+			ServiceName:      fmt.Sprintf("benchmark.service.%d", i),
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 10 * benchmarkInitialInstances,
+			ExecuteRatio:     100,
+			Status:           consts.RuleStatusEnable,
+		})
+	}
+	return rules
+}
+
+//benchmarkMetricBackend 按模拟服务的实例数和正弦波动的流量计算冗余度，各服务的波动相位不同
+type benchmarkMetricBackend struct {
+	server *testutil.SchedulxServer
+}
+
+func (backend *benchmarkMetricBackend) QueryRedundancy(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(serviceName))
+	phase := float64(hash.Sum32()%360) * math.Pi / 180
+	// 流量在初始实例冗余度为2的流量上下50%波动，每轮调度相位推进0.5，周期约12轮
+	qps := benchmark * benchmarkInitialInstances / 2 * (1 + 0.5*math.Sin(float64(end)/benchmarkTickDuration.Seconds()/2+phase))
+	redundancy := benchmark * float64(backend.server.InstanceCount(serviceName, clusterName)) / qps
+	cluster := &service.ClusterRedundancySeries{ClusterName: clusterName}
+	for timestamp := begin; timestamp < end; timestamp += 10 {
+		cluster.Timestamps = append(cluster.Timestamps, timestamp)
+		cluster.Values = append(cluster.Values, redundancy)
+	}
+	return &service.RedundancySeries{ServiceName: serviceName, MetricName: metricName, Clusters: []*service.ClusterRedundancySeries{cluster}}, nil
+}
+
+//QueryRawMetric 模拟的流量只在换算冗余度时按 benchmark 计算，不支持查询原始指标值
+func (backend *benchmarkMetricBackend) QueryRawMetric(ctx context.Context, serviceName, clusterName, metricName string, start, end int64, step int) ([]float64, error) {
+	return nil, service.ErrRawMetricUnsupported
+}
+
+//discardRuleErrorStore 不保存规则的连续失败次数，RunBenchmark 不访问数据库
+type discardRuleErrorStore struct{}
+
+func (discardRuleErrorStore) IncrementRuleErrorCount(ruleID int64, msg string) error {
+	return nil
+}
+
+func (discardRuleErrorStore) ResetRuleErrorCount(ruleID int64) error {
+	return nil
+}
+
+func (discardRuleErrorStore) UpdatePredictRuleStatusById(ruleID int64, status string) error {
+	return nil
+}
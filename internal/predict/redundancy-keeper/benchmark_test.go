@@ -0,0 +1,29 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"time"
+
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("RunBenchmark", func() {
+	ginkgo.It("schedules synthetic rules against the mock schedulx until the duration ends", func() {
+		result := redundancy_keeper.RunBenchmark(context.Background(), 20, 300*time.Millisecond, 0)
+		gomega.Expect(result).NotTo(gomega.BeNil())
+		gomega.Expect(result.Ticks).To(gomega.BeNumerically(">", 1))
+		gomega.Expect(result.RuleErrors).To(gomega.Equal(0))
+		gomega.Expect(result.TotalScalingCalls).To(gomega.BeNumerically(">", 0))
+		gomega.Expect(result.P99TickDurationMs).To(gomega.BeNumerically(">=", result.P50TickDurationMs))
+		gomega.Expect(result.RulesPerSecond).To(gomega.BeNumerically(">", 0))
+		gomega.Expect(result.MemAllocMB).To(gomega.BeNumerically(">", 0))
+	})
+
+	ginkgo.It("runs one round when the duration has already passed", func() {
+		result := redundancy_keeper.RunBenchmark(context.Background(), 1, 0, 0)
+		gomega.Expect(result.Ticks).To(gomega.Equal(1))
+		gomega.Expect(redundancy_keeper.RunBenchmark(context.Background(), 0, time.Second, 0)).To(gomega.BeNil())
+	})
+})
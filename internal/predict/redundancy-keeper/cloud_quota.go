@@ -0,0 +1,43 @@
+package redundancy_keeper
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"go.uber.org/zap"
+)
+
+//cloudQuotaGetter 能查询云厂商剩余配额的 Scaler
+type cloudQuotaGetter interface {
+	GetCloudQuotaRemaining(ctx context.Context, clusterName string) (int, error)
+}
+
+func (schedulxScaler) GetCloudQuotaRemaining(ctx context.Context, clusterName string) (int, error) {
+	return clients.GetCloudQuotaRemaining(ctx, clusterName)
+}
+
+//applyCloudQuota 开启 check_quota_before_expand 的规则扩容前查询剩余配额，配额不足 count 时减少到剩余配额，返回0时跳过扩容；
+//配额耗尽时 schedulx 仍可能返回扩容成功但实例一直不就绪。查询失败或 Scaler 不支持时按原扩容数扩容
+func (keeper *ScheduleXRedundancyKeeper) applyCloudQuota(ctx context.Context, rule *model.PredictRule, count int, trace *RuleTrace) int {
+	getter, ok := keeper.scalerFor(rule).(cloudQuotaGetter)
+	if !ok {
+		return count
+	}
+	remaining, err := getter.GetCloudQuotaRemaining(ctx, rule.ClusterName)
+	if err != nil {
+		keeper.loggerFor(ctx).Warn("query cloud quota failed, expand without quota check", zap.String("service", rule.ServiceName),
+			zap.String("cluster", rule.ClusterName), zap.Error(err))
+		trace.step("query cloud quota failed: %v", err)
+		return count
+	}
+	if remaining >= count {
+		return count
+	}
+	remaining = max(remaining, 0)
+	keeper.loggerFor(ctx).Warn("cloud quota is not enough, reduce expand count", zap.String("service", rule.ServiceName),
+		zap.String("cluster", rule.ClusterName), zap.Int("count", count), zap.Int("quota_remaining", remaining))
+	keeper.metrics.ExpandQuotaConstrained.WithLabelValues(rule.ServiceName, rule.ClusterName).Inc()
+	trace.step("cloud quota remaining %d is less than %d, expand count %d -> %d", remaining, count, count, remaining)
+	return remaining
+}
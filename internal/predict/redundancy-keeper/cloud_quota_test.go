@@ -0,0 +1,118 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+//cloudQuotaScaler 返回固定的云厂商剩余配额，记录扩容的实例数和配额查询次数
+type cloudQuotaScaler struct {
+	inFlightScaler
+	remaining   int
+	err         error
+	quotaCalls  int
+	expandCount int
+}
+
+func (scaler *cloudQuotaScaler) ExpandService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	scaler.expandCount += count
+	return nil
+}
+
+func (scaler *cloudQuotaScaler) GetCloudQuotaRemaining(ctx context.Context, clusterName string) (int, error) {
+	scaler.quotaCalls++
+	return scaler.remaining, scaler.err
+}
+
+var _ = ginkgo.Describe("CloudQuota", func() {
+	var rule *model.PredictRule
+	var scaler *cloudQuotaScaler
+	var bundle *redundancy_keeper.MetricsBundle
+
+	start := func() *redundancy_keeper.ScheduleSummary {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricsBundle(bundle),
+			redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		return redundancy_keeper.Start(context.Background())
+	}
+
+	constrained := func() float64 {
+		return testutil.ToFloat64(bundle.ExpandQuotaConstrained.WithLabelValues(rule.ServiceName, rule.ClusterName))
+	}
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:                     3000,
+			ServiceName:            "cloud.quota",
+			ClusterName:            "default",
+			MetricName:             "qps",
+			BenchmarkQps:           100,
+			MinRedundancy:          150,
+			MaxRedundancy:          250,
+			MinInstanceCount:       1,
+			MaxInstanceCount:       100,
+			ExecuteRatio:           100,
+			CheckQuotaBeforeExpand: true,
+			Status:                 consts.RuleStatusEnable,
+		}
+		scaler = &cloudQuotaScaler{remaining: 100}
+		var err error
+		bundle, err = redundancy_keeper.NewMetricsBundle(prometheus.NewRegistry())
+		gomega.Expect(err).To(gomega.BeNil())
+	})
+
+	ginkgo.It("expands the full count when the quota is enough", func() {
+		summary := start()
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(scaler.expandCount).To(gomega.Equal(30))
+		gomega.Expect(constrained()).To(gomega.Equal(float64(0)))
+	})
+
+	ginkgo.It("reduces the expand count to the remaining quota", func() {
+		scaler.remaining = 12
+		summary := start()
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(scaler.expandCount).To(gomega.Equal(12))
+		gomega.Expect(constrained()).To(gomega.Equal(float64(1)))
+
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(explain.Trace.Steps).To(gomega.ContainElement("cloud quota remaining 12 is less than 30, expand count 30 -> 12"))
+	})
+
+	ginkgo.It("skips the expansion when the quota is exhausted", func() {
+		scaler.remaining = 0
+		summary := start()
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(0))
+		gomega.Expect(scaler.expandCount).To(gomega.Equal(0))
+		gomega.Expect(constrained()).To(gomega.Equal(float64(1)))
+
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(explain.Reason).To(gomega.Equal("skipped: redundancy=0.50 below min=1.50 but cloud quota of cluster default is exhausted"))
+	})
+
+	ginkgo.It("expands without the check when the query fails or the rule does not enable it", func() {
+		scaler.err = errors.New("quota service unavailable")
+		start()
+		gomega.Expect(scaler.expandCount).To(gomega.Equal(30))
+
+		rule.CheckQuotaBeforeExpand = false
+		scaler = &cloudQuotaScaler{}
+		start()
+		gomega.Expect(scaler.expandCount).To(gomega.Equal(30))
+		gomega.Expect(scaler.quotaCalls).To(gomega.Equal(0))
+	})
+})
@@ -0,0 +1,140 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//inFlightScaler 记录同一时刻最多有多少个 schedulx 请求在执行
+type inFlightScaler struct {
+	lock        sync.Mutex
+	inFlight    int
+	maxInFlight int
+	expanded    int
+}
+
+func (scaler *inFlightScaler) call() {
+	scaler.lock.Lock()
+	scaler.inFlight++
+	if scaler.inFlight > scaler.maxInFlight {
+		scaler.maxInFlight = scaler.inFlight
+	}
+	scaler.lock.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	scaler.lock.Lock()
+	scaler.inFlight--
+	scaler.lock.Unlock()
+}
+
+func (scaler *inFlightScaler) CanServiceSchedule(ctx context.Context, serviceName, clusterName string) (bool, error) {
+	scaler.call()
+	return true, nil
+}
+
+func (scaler *inFlightScaler) GetServiceInstanceCount(ctx context.Context, serviceName, clusterName string) (int, error) {
+	scaler.call()
+	return 10, nil
+}
+
+func (scaler *inFlightScaler) GetServiceInstanceIps(ctx context.Context, serviceName, clusterName string) ([]string, error) {
+	scaler.call()
+	return nil, nil
+}
+
+func (scaler *inFlightScaler) ExpandService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	scaler.call()
+	scaler.lock.Lock()
+	scaler.expanded++
+	scaler.lock.Unlock()
+	return nil
+}
+
+func (scaler *inFlightScaler) ExpandServiceAndWait(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	return scaler.ExpandService(ctx, serviceName, clusterName, count, idempotencyKey)
+}
+
+func (scaler *inFlightScaler) GradualExpandService(ctx context.Context, serviceName, clusterName string, totalCount int, batchSize int, batchInterval time.Duration, idempotencyKey string) error {
+	return scaler.ExpandService(ctx, serviceName, clusterName, totalCount, idempotencyKey)
+}
+
+func (scaler *inFlightScaler) ShrinkService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	scaler.call()
+	return nil
+}
+
+//lowRedundancyBackend 所有集群的冗余度都是0.5
+type lowRedundancyBackend struct{}
+
+func (lowRedundancyBackend) QueryRedundancy(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+	return &service.RedundancySeries{
+		ServiceName: serviceName,
+		MetricName:  metricName,
+		Clusters:    []*service.ClusterRedundancySeries{{ClusterName: clusterName, Timestamps: []int64{begin}, Values: []float64{0.5}}},
+	}, nil
+}
+
+func (lowRedundancyBackend) QueryRawMetric(ctx context.Context, serviceName, clusterName, metricName string, start, end int64, step int) ([]float64, error) {
+	return nil, service.ErrRawMetricUnsupported
+}
+
+var _ = ginkgo.Describe("RuleConcurrencyPerService", func() {
+	var rules []*model.PredictRule
+
+	ginkgo.BeforeEach(func() {
+		rules = nil
+		for i := 0; i < 10; i++ {
+			rules = append(rules, &model.PredictRule{
+				Id:               int64(100 + i),
+				ServiceName:      "busy",
+				ClusterName:      fmt.Sprintf("cluster-%d", i),
+				MetricName:       "qps",
+				BenchmarkQps:     100,
+				MinRedundancy:    150,
+				MaxRedundancy:    250,
+				MinInstanceCount: 1,
+				MaxInstanceCount: 50,
+				ExecuteRatio:     100,
+				Status:           "enable",
+			})
+		}
+	})
+
+	run := func(concurrencyPerService int) (*inFlightScaler, *redundancy_keeper.ScheduleSummary) {
+		scaler := &inFlightScaler{}
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{
+			RuleConcurrency:           10,
+			RuleConcurrencyPerService: concurrencyPerService,
+			MinimalSampleCount:        1,
+			RunOnce:                   true,
+		},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return rules, nil }),
+		)).To(gomega.Succeed())
+		return scaler, redundancy_keeper.Start(context.Background())
+	}
+
+	ginkgo.It("limits in-flight schedulx calls of one service", func() {
+		scaler, summary := run(2)
+		gomega.Expect(summary.RulesEvaluated).To(gomega.Equal(10))
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(10))
+		gomega.Expect(summary.ExitCode()).To(gomega.Equal(0))
+		gomega.Expect(scaler.expanded).To(gomega.Equal(10))
+		gomega.Expect(scaler.maxInFlight).To(gomega.Equal(2))
+	})
+
+	ginkgo.It("only applies RuleConcurrency when unset", func() {
+		scaler, summary := run(0)
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(10))
+		gomega.Expect(scaler.maxInFlight).To(gomega.BeNumerically(">", 2))
+	})
+})
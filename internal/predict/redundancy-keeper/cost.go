@@ -0,0 +1,119 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"go.uber.org/zap"
+)
+
+//CostEstimator 估算集群单个实例每小时的成本
+type CostEstimator interface {
+	EstimateCostPerInstance(ctx context.Context, clusterName string) (float64, error)
+}
+
+//StaticCostEstimator 使用配置中每个集群的实例小时成本
+type StaticCostEstimator struct {
+	costPerInstanceHour map[string]float64
+}
+
+//NewStaticCostEstimator 新建 StaticCostEstimator，costPerInstanceHour 为 集群名 -> 单实例每小时成本
+func NewStaticCostEstimator(costPerInstanceHour map[string]float64) *StaticCostEstimator {
+	costs := make(map[string]float64, len(costPerInstanceHour))
+	for clusterName, cost := range costPerInstanceHour {
+		costs[clusterName] = cost
+	}
+	return &StaticCostEstimator{costPerInstanceHour: costs}
+}
+
+//EstimateCostPerInstance 返回集群单实例每小时成本，未配置的集群返回错误
+func (estimator *StaticCostEstimator) EstimateCostPerInstance(ctx context.Context, clusterName string) (float64, error) {
+	cost, ok := estimator.costPerInstanceHour[clusterName]
+	if !ok {
+		return 0, fmt.Errorf("no cost configured for cluster %s", clusterName)
+	}
+	return cost, nil
+}
+
+//WithCostEstimator 指定估算扩缩容成本的 CostEstimator，为空时不估算
+func WithCostEstimator(estimator CostEstimator) Option {
+	return func(keeper *ScheduleXRedundancyKeeper) {
+		keeper.costEstimator = estimator
+	}
+}
+
+//ServiceCostSummary 服务因扩缩容带来的每小时成本变化
+type ServiceCostSummary struct {
+	ServiceName string `json:"service_name"`
+	//EstimatedCostDeltaPerHour 自进程启动以来累计的每小时成本变化，缩容为负数
+	EstimatedCostDeltaPerHour float64 `json:"estimated_cost_delta_per_hour"`
+}
+
+//costSummary 按服务累计的每小时成本变化
+type costSummary struct {
+	lock   sync.Mutex
+	deltas map[string]float64
+}
+
+func (summary *costSummary) add(serviceName string, delta float64) {
+	summary.lock.Lock()
+	defer summary.lock.Unlock()
+	if summary.deltas == nil {
+		summary.deltas = make(map[string]float64)
+	}
+	summary.deltas[serviceName] += delta
+}
+
+func (summary *costSummary) list() []*ServiceCostSummary {
+	summary.lock.Lock()
+	defer summary.lock.Unlock()
+	result := make([]*ServiceCostSummary, 0, len(summary.deltas))
+	for serviceName, delta := range summary.deltas {
+		result = append(result, &ServiceCostSummary{ServiceName: serviceName, EstimatedCostDeltaPerHour: delta})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ServiceName < result[j].ServiceName })
+	return result
+}
+
+//estimateCost 估算本次扩缩容带来的每小时成本变化，缩容为负数；无法估算时返回0
+func (keeper *ScheduleXRedundancyKeeper) estimateCost(ctx context.Context, clusterName, action string, count int) float64 {
+	if keeper.costEstimator == nil || count == 0 {
+		return 0
+	}
+	costPerInstance, err := keeper.costEstimator.EstimateCostPerInstance(ctx, clusterName)
+	if err != nil {
+		keeper.loggerFor(ctx).Debug("estimate cost failed", zap.String("cluster", clusterName), zap.Error(err))
+		return 0
+	}
+	cost := float64(count) * costPerInstance
+	if action == event.ActionScaleDown {
+		cost = -cost
+	}
+	return cost
+}
+
+//recordCost 累计服务的成本变化
+func (keeper *ScheduleXRedundancyKeeper) recordCost(serviceName string, cost float64) {
+	if cost == 0 {
+		return
+	}
+	keeper.costs.add(serviceName, cost)
+	keeper.metrics.CostDelta.WithLabelValues(serviceName).Add(cost)
+}
+
+//CostSummary 各服务因扩缩容带来的每小时成本变化
+func (keeper *ScheduleXRedundancyKeeper) CostSummary() []*ServiceCostSummary {
+	return keeper.costs.list()
+}
+
+//GetCostSummary 各服务因扩缩容带来的每小时成本变化
+func GetCostSummary() ([]*ServiceCostSummary, error) {
+	if redundancyKeeper == nil {
+		return nil, errors.New("redundancy keeper is not initialized")
+	}
+	return redundancyKeeper.CostSummary(), nil
+}
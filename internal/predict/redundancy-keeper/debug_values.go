@@ -0,0 +1,41 @@
+package redundancy_keeper
+
+import (
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"go.uber.org/zap"
+)
+
+//debugLogMaxValues debug_log_metric_values 每个序列最多输出的冗余度个数，避免日志过长
+const debugLogMaxValues = 100
+
+func (keeper *ScheduleXRedundancyKeeper) debugLogMetricValues() bool {
+	keeper.lock.RLock()
+	defer keeper.lock.RUnlock()
+	return keeper.DebugLogMetricValues
+}
+
+//truncateDebugValues 复制前 debugLogMaxValues 个冗余度，原序列随后会被原地排序
+func truncateDebugValues(values []float64) []float64 {
+	return append([]float64{}, values[:min(len(values), debugLogMaxValues)]...)
+}
+
+//logMetricValues 在 DEBUG 日志中输出按时间顺序的冗余度、去掉异常值后排序的冗余度以及中位数在排序序列中的位置；
+//排序的冗余度超过 debugLogMaxValues 个时只输出中位数附近的部分，sorted_offset 为第一个值的位置；
+//个数为偶数时中位数是 selected_index 和下一个值的平均
+func logMetricValues(log *zap.Logger, rule *model.PredictRule, rawValues, sorted []float64, median float64) {
+	if entry := log.Check(zap.DebugLevel, "metric values"); entry != nil {
+		selected := (len(sorted) - 1) / 2
+		offset := max(0, min(selected-debugLogMaxValues/2, len(sorted)-debugLogMaxValues))
+		entry.Write(
+			zap.Int64("rule_id", rule.Id),
+			zap.String("service", rule.ServiceName),
+			zap.String("cluster", rule.ClusterName),
+			zap.Float64s("values", rawValues),
+			zap.Float64s("sorted_values", sorted[offset:min(len(sorted), offset+debugLogMaxValues)]),
+			zap.Int("sorted_offset", offset),
+			zap.Int("sample_count", len(sorted)),
+			zap.Int("selected_index", selected),
+			zap.Float64("median", median),
+		)
+	}
+}
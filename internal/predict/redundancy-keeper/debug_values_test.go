@@ -0,0 +1,91 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var _ = ginkgo.Describe("DebugLogMetricValues", func() {
+	var rule *model.PredictRule
+	var values []float64
+
+	start := func(level zapcore.Level, debug bool) *observer.ObservedLogs {
+		core, logs := observer.New(level)
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true, DebugLogMetricValues: debug},
+			redundancy_keeper.WithLogger(zap.New(core)),
+			redundancy_keeper.WithScaler(&inFlightScaler{}),
+			redundancy_keeper.WithMetricBackend(service.MetricBackendFunc(func(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+				timestamps := make([]int64, len(values))
+				for i := range timestamps {
+					timestamps[i] = begin + int64(i)
+				}
+				return &service.RedundancySeries{
+					ServiceName: serviceName,
+					MetricName:  metricName,
+					Clusters:    []*service.ClusterRedundancySeries{{ClusterName: clusterName, Timestamps: timestamps, Values: append([]float64{}, values...)}},
+				}, nil
+			})),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		redundancy_keeper.Start(context.Background())
+		return logs.FilterMessage("metric values")
+	}
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:               3200,
+			ServiceName:      "debug.values",
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 100,
+			ExecuteRatio:     100,
+			Status:           consts.RuleStatusEnable,
+		}
+		values = []float64{2.2, 1.8, 2.0}
+	})
+
+	ginkgo.It("logs the raw and sorted values with the median index", func() {
+		logs := start(zapcore.DebugLevel, true)
+		gomega.Expect(logs.Len()).To(gomega.Equal(1))
+		fields := logs.All()[0].ContextMap()
+		gomega.Expect(fields).To(gomega.HaveKeyWithValue("values", []interface{}{2.2, 1.8, 2.0}))
+		gomega.Expect(fields).To(gomega.HaveKeyWithValue("sorted_values", []interface{}{1.8, 2.0, 2.2}))
+		gomega.Expect(fields).To(gomega.HaveKeyWithValue("selected_index", int64(1)))
+		gomega.Expect(fields).To(gomega.HaveKeyWithValue("median", 2.0))
+	})
+
+	ginkgo.It("truncates long series to the values around the median", func() {
+		values = make([]float64, 301)
+		for i := range values {
+			values[i] = float64(i) / 100
+		}
+		logs := start(zapcore.DebugLevel, true)
+		gomega.Expect(logs.Len()).To(gomega.Equal(1))
+		fields := logs.All()[0].ContextMap()
+		gomega.Expect(fields["values"]).To(gomega.HaveLen(100))
+		sorted := fields["sorted_values"].([]interface{})
+		gomega.Expect(sorted).To(gomega.HaveLen(100))
+		gomega.Expect(fields).To(gomega.HaveKeyWithValue("sorted_offset", int64(100)))
+		gomega.Expect(fields).To(gomega.HaveKeyWithValue("selected_index", int64(150)))
+		gomega.Expect(sorted[50]).To(gomega.Equal(1.5))
+	})
+
+	ginkgo.It("does not log when the flag is off or the level is above debug", func() {
+		gomega.Expect(start(zapcore.DebugLevel, false).Len()).To(gomega.Equal(0))
+		gomega.Expect(start(zapcore.InfoLevel, true).Len()).To(gomega.Equal(0))
+	})
+})
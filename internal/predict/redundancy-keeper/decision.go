@@ -0,0 +1,119 @@
+package redundancy_keeper
+
+import (
+	"math"
+	"slices"
+
+	"github.com/galaxy-future/cudgx/common/stats"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+)
+
+//maxInstanceChange 单次调度最多变更的实例数
+const maxInstanceChange = 30
+
+//withinRedundancyRange 冗余度在 min_redundancy 和 max_redundancy 之间时不需要调度
+func withinRedundancyRange(rule *model.PredictRule, redundancy float64) bool {
+	return int(redundancy*100) < rule.MaxRedundancy && int(redundancy*100) > rule.MinRedundancy
+}
+
+//rateOfChangeTooHigh 按时间顺序排列的 values 首尾变化率超过 max_rate_of_change_percent 时返回 true，此时回查窗口内的数据已经过时
+func rateOfChangeTooHigh(rule *model.PredictRule, values []float64) (rateOfChange float64, tooHigh bool) {
+	rateOfChange = rateOfChangePercent(values)
+	return rateOfChange, rule.MaxRateOfChangePercent > 0 && math.Abs(rateOfChange) > rule.MaxRateOfChangePercent
+}
+
+//belowMinQPSThreshold 按 benchmark 和冗余度估算的总QPS低于 min_qps_threshold 时返回 true，流量接近0时冗余度没有意义
+func belowMinQPSThreshold(rule *model.PredictRule, benchmark float64, instanceCount int, redundancy float64) (totalQPS float64, below bool) {
+	if rule.MinQPSThreshold <= 0 {
+		return 0, false
+	}
+	totalQPS = estimateTotalQPS(benchmark, instanceCount, redundancy)
+	return totalQPS, totalQPS < rule.MinQPSThreshold
+}
+
+//expectedInstanceChange 冗余度回到 min_redundancy 和 max_redundancy 中间值需要的实例数，以及按 execute_ratio 计算的变更数，正数扩容、负数缩容
+func expectedInstanceChange(rule *model.PredictRule, redundancy float64, currentCount int) (expectCount, countToChange int) {
+	midRedundancy := float64((rule.MaxRedundancy+rule.MinRedundancy)/2) / 100.0
+	expectCount = int(midRedundancy / redundancy * float64(currentCount))
+	countToChange = int(math.Ceil(float64((expectCount-currentCount)*rule.ExecuteRatio) / 100.0))
+	return expectCount, countToChange
+}
+
+//clampInstanceChange 按 max_instance_count、min_instance_count、max_expand_percent、max_shrink_percent 和单次变更上限限制变更数，
+//返回不带符号的数量，不大于0表示本轮不能再变更。单次变更上限为规则的 max_scale_up_absolute/max_scale_down_absolute，
+//未配置时为 maxInstanceChange；规则配置的上限使数量减少时 limitField 为该字段名
+func clampInstanceChange(rule *model.PredictRule, countToChange, currentCount int) (count int, limitField string) {
+	count = clampRelativeInstanceChange(rule, countToChange, currentCount)
+	field, limit := "max_scale_down_absolute", rule.MaxScaleDownAbsolute
+	if countToChange > 0 {
+		field, limit = "max_scale_up_absolute", rule.MaxScaleUpAbsolute
+	}
+	if limit <= 0 {
+		return min(count, maxInstanceChange), ""
+	}
+	if count > limit {
+		return limit, field
+	}
+	return count, ""
+}
+
+//clampRelativeInstanceChange 按 max_instance_count、min_instance_count、max_expand_percent 和 max_shrink_percent 限制变更数，
+//返回不带符号的数量，不包含单次变更上限
+func clampRelativeInstanceChange(rule *model.PredictRule, countToChange, currentCount int) int {
+	if countToChange > 0 {
+		if currentCount+countToChange > rule.MaxInstanceCount {
+			countToChange = rule.MaxInstanceCount - currentCount
+		}
+		if rule.MaxExpandPercent > 0 {
+			countToChange = min(countToChange, int(float64(currentCount)*rule.MaxExpandPercent/100.0))
+		}
+		return countToChange
+	}
+	countToChange = -countToChange
+	if currentCount-countToChange < rule.MinInstanceCount {
+		countToChange = currentCount - rule.MinInstanceCount
+	}
+	if rule.MaxShrinkPercent > 0 {
+		countToChange = min(countToChange, int(float64(currentCount)*rule.MaxShrinkPercent/100.0))
+	}
+	return countToChange
+}
+
+//DecideScale 只根据冗余度和当前实例数计算 scheduleRule 的扩缩容决定，不查询指标也不调用 schedulx。
+//action 为 event.ActionScaleUp 或 event.ActionScaleDown，不需要调度或已经达到实例数上下限时为空
+func DecideScale(rule *model.PredictRule, redundancy float64, currentCount int) (action string, count int) {
+	if withinRedundancyRange(rule, redundancy) {
+		return "", 0
+	}
+	_, countToChange := expectedInstanceChange(rule, redundancy, currentCount)
+	if countToChange == 0 {
+		return "", 0
+	}
+	count, _ = clampInstanceChange(rule, countToChange, currentCount)
+	if count <= 0 {
+		return "", 0
+	}
+	if countToChange > 0 {
+		return event.ActionScaleUp, count
+	}
+	return event.ActionScaleDown, count
+}
+
+//DecideScaleForSeries 按 scheduleRule 的顺序检查 benchmark_qps、max_rate_of_change_percent、取中位数、检查 min_qps_threshold 后调用 DecideScale，
+//series 为按时间顺序排列的冗余度采样点。不去除异常值，也不处理 forecast_horizon_seconds 和 recovery 等依赖调度参数或历史状态的逻辑
+func DecideScaleForSeries(rule *model.PredictRule, series []float64, currentCount int) (action string, count int) {
+	if rule.BenchmarkQps <= 0 || len(series) == 0 {
+		return "", 0
+	}
+	if _, tooHigh := rateOfChangeTooHigh(rule, series); tooHigh {
+		return "", 0
+	}
+	values := slices.Clone(series)
+	slices.Sort(values)
+	redundancy := stats.Median(values)
+	if _, below := belowMinQPSThreshold(rule, float64(rule.BenchmarkQps), currentCount, redundancy); below {
+		return "", 0
+	}
+	return DecideScale(rule, redundancy, currentCount)
+}
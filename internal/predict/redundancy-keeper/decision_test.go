@@ -0,0 +1,88 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("DecideScale", func() {
+	newRule := func(modify func(rule *model.PredictRule)) *model.PredictRule {
+		rule := &model.PredictRule{
+			ServiceName:      "gf.cudgx.decision",
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 1000,
+			ExecuteRatio:     100,
+		}
+		modify(rule)
+		return rule
+	}
+
+	// 100 个实例冗余度为10时需要缩容到20个，冗余度为0.5时需要扩容到400个
+	table.DescribeTable("limits the change by execute_ratio, percent limits and absolute limits",
+		func(redundancy float64, modify func(rule *model.PredictRule), expectAction string, expectCount int) {
+			action, count := redundancy_keeper.DecideScale(newRule(modify), redundancy, 100)
+			gomega.Expect(action).To(gomega.Equal(expectAction))
+			gomega.Expect(count).To(gomega.Equal(expectCount))
+		},
+		table.Entry("shrink falls back to the per tick cap of 30", 10.0, func(rule *model.PredictRule) {}, event.ActionScaleDown, 30),
+		table.Entry("shrink below the cap follows execute_ratio", 10.0, func(rule *model.PredictRule) { rule.ExecuteRatio = 20 }, event.ActionScaleDown, 16),
+		table.Entry("max_scale_down_absolute replaces the per tick cap", 10.0, func(rule *model.PredictRule) { rule.MaxScaleDownAbsolute = 50 }, event.ActionScaleDown, 50),
+		table.Entry("max_scale_down_absolute applies after execute_ratio", 10.0, func(rule *model.PredictRule) {
+			rule.ExecuteRatio = 20
+			rule.MaxScaleDownAbsolute = 5
+		}, event.ActionScaleDown, 5),
+		table.Entry("max_shrink_percent below max_scale_down_absolute", 10.0, func(rule *model.PredictRule) {
+			rule.MaxShrinkPercent = 10
+			rule.MaxScaleDownAbsolute = 50
+		}, event.ActionScaleDown, 10),
+		table.Entry("max_scale_up_absolute does not limit shrink", 10.0, func(rule *model.PredictRule) { rule.MaxScaleUpAbsolute = 5 }, event.ActionScaleDown, 30),
+		table.Entry("expand falls back to the per tick cap of 30", 0.5, func(rule *model.PredictRule) {}, event.ActionScaleUp, 30),
+		table.Entry("max_scale_up_absolute replaces the per tick cap", 0.5, func(rule *model.PredictRule) { rule.MaxScaleUpAbsolute = 100 }, event.ActionScaleUp, 100),
+		table.Entry("max_expand_percent below max_scale_up_absolute", 0.5, func(rule *model.PredictRule) {
+			rule.MaxExpandPercent = 20
+			rule.MaxScaleUpAbsolute = 100
+		}, event.ActionScaleUp, 20),
+		table.Entry("execute_ratio below max_scale_up_absolute", 0.5, func(rule *model.PredictRule) {
+			rule.ExecuteRatio = 10
+			rule.MaxScaleUpAbsolute = 50
+		}, event.ActionScaleUp, 30),
+		table.Entry("max_instance_count below max_scale_up_absolute", 0.5, func(rule *model.PredictRule) {
+			rule.MaxInstanceCount = 120
+			rule.MaxScaleUpAbsolute = 50
+		}, event.ActionScaleUp, 20),
+		table.Entry("max_scale_down_absolute does not limit expand", 0.5, func(rule *model.PredictRule) { rule.MaxScaleDownAbsolute = 5 }, event.ActionScaleUp, 30),
+	)
+
+	ginkgo.It("applies max_scale_up_absolute when the keeper scales", func() {
+		rule := newRule(func(rule *model.PredictRule) {
+			rule.Id = 4450
+			rule.Status = consts.RuleStatusEnable
+			rule.MaxScaleUpAbsolute = 50
+		})
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(&fixedCountScaler{count: 100}),
+			redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		summary := redundancy_keeper.Start(context.Background())
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(explain.Reason).To(gomega.HaveSuffix("added 50 instances"))
+		gomega.Expect(explain.Trace.Steps).To(gomega.ContainElement(gomega.ContainSubstring("change reduced to 50 by max_scale_up_absolute")))
+	})
+})
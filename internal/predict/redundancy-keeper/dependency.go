@@ -0,0 +1,40 @@
+package redundancy_keeper
+
+import (
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+)
+
+//dependencyLevels 按 DependsOnRuleID 对规则分层：没有依赖或依赖的规则不在 rules 中的为第0层，
+//依赖第 N-1 层规则的为第 N 层，同一层内保持 rules 中的顺序；依赖链形成环的规则放在 cyclic 中
+func dependencyLevels(rules []*model.PredictRule) (levels [][]*model.PredictRule, cyclic []*model.PredictRule) {
+	ruleIDs := make(map[int64]bool, len(rules))
+	for _, rule := range rules {
+		ruleIDs[rule.Id] = true
+	}
+	levelOf := make(map[int64]int, len(rules))
+	pending := rules
+	for level := 0; len(pending) > 0; level++ {
+		var current, next []*model.PredictRule
+		for _, rule := range pending {
+			if rule.DependsOnRuleID == nil || !ruleIDs[*rule.DependsOnRuleID] {
+				current = append(current, rule)
+				continue
+			}
+			if dependencyLevel, ok := levelOf[*rule.DependsOnRuleID]; ok && dependencyLevel < level {
+				current = append(current, rule)
+				continue
+			}
+			next = append(next, rule)
+		}
+		// 剩下的规则都依赖于彼此，依赖链形成了环
+		if len(current) == 0 {
+			return levels, next
+		}
+		for _, rule := range current {
+			levelOf[rule.Id] = level
+		}
+		levels = append(levels, current)
+		pending = next
+	}
+	return levels, nil
+}
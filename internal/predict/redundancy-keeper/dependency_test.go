@@ -0,0 +1,94 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"sync"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//orderScaler 记录扩容服务的顺序
+type orderScaler struct {
+	inFlightScaler
+	orderLock sync.Mutex
+	order     []string
+}
+
+func (scaler *orderScaler) ExpandService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	scaler.call()
+	scaler.orderLock.Lock()
+	scaler.order = append(scaler.order, serviceName)
+	scaler.orderLock.Unlock()
+	return nil
+}
+
+var _ = ginkgo.Describe("DependsOnRuleID", func() {
+	newRule := func(id int64, serviceName string, dependsOn *int64) *model.PredictRule {
+		return &model.PredictRule{
+			Id:               id,
+			ServiceName:      serviceName,
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 100,
+			ExecuteRatio:     100,
+			DependsOnRuleID:  dependsOn,
+			Status:           consts.RuleStatusEnable,
+		}
+	}
+	ruleID := func(id int64) *int64 { return &id }
+
+	start := func(rules []*model.PredictRule) *orderScaler {
+		scaler := &orderScaler{}
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 4, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return rules, nil }),
+		)).To(gomega.Succeed())
+		summary := redundancy_keeper.Start(context.Background())
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(len(rules)))
+		return scaler
+	}
+
+	ginkgo.It("schedules a 3-level chain level by level", func() {
+		for i := 0; i < 5; i++ {
+			scaler := start([]*model.PredictRule{
+				newRule(1303, "app", ruleID(1302)),
+				newRule(1302, "cache", ruleID(1301)),
+				newRule(1301, "db", nil),
+			})
+			gomega.Expect(scaler.order).To(gomega.Equal([]string{"db", "cache", "app"}))
+		}
+	})
+
+	ginkgo.It("runs rules of the same level concurrently", func() {
+		scaler := start([]*model.PredictRule{
+			newRule(1311, "db", nil),
+			newRule(1312, "app-a", ruleID(1311)),
+			newRule(1313, "app-b", ruleID(1311)),
+			newRule(1314, "standalone", nil),
+		})
+		gomega.Expect(scaler.order).To(gomega.HaveLen(4))
+		gomega.Expect(scaler.order[:2]).To(gomega.ConsistOf("db", "standalone"))
+		gomega.Expect(scaler.order[2:]).To(gomega.ConsistOf("app-a", "app-b"))
+		gomega.Expect(scaler.maxInFlight).To(gomega.BeNumerically(">", 1))
+	})
+
+	ginkgo.It("treats a dependency on a rule outside this round as satisfied and schedules cycles last", func() {
+		scaler := start([]*model.PredictRule{
+			newRule(1321, "cycle-a", ruleID(1322)),
+			newRule(1322, "cycle-b", ruleID(1321)),
+			newRule(1323, "orphan", ruleID(9999)),
+		})
+		gomega.Expect(scaler.order).To(gomega.HaveLen(3))
+		gomega.Expect(scaler.order[0]).To(gomega.Equal("orphan"))
+	})
+})
@@ -0,0 +1,123 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"go.uber.org/zap"
+)
+
+//distributedLockReleaseTimeout 释放和续期分布式锁的超时时间，规则的 ctx 可能已经结束，释放时不使用
+const distributedLockReleaseTimeout = 2 * time.Second
+
+//DistributedLockRenewInterval 规则执行期间续期分布式锁的间隔，需要小于 consts.DistributedLockTTL
+var DistributedLockRenewInterval = consts.DistributedLockTTL / 3
+
+//DistributedLock 多个 keeper 实例之间的互斥锁，clients.RedisDistributedLock 实现了该接口
+type DistributedLock interface {
+	//Lock 在 ctx 结束前获取 key 的锁，ttl 后自动过期，返回释放时使用的 token；锁被其他实例持有时返回 clients.ErrLockNotAcquired
+	Lock(ctx context.Context, key string, ttl time.Duration) (string, error)
+	//Extend 把 Lock 获取的锁的过期时间重置为 ttl，锁已过期或被其他实例持有时返回 clients.ErrLockNotHeld
+	Extend(ctx context.Context, key, token string, ttl time.Duration) error
+	//Unlock 释放 Lock 获取的锁
+	Unlock(ctx context.Context, key, token string) error
+}
+
+//WithDistributedLock 高可用部署多个 keeper 实例时，执行规则前获取服务集群的分布式锁，为空时不加锁
+func WithDistributedLock(lock DistributedLock) Option {
+	return func(keeper *ScheduleXRedundancyKeeper) {
+		if lock != nil {
+			keeper.distributedLock = lock
+		}
+	}
+}
+
+//newDistributedLock 按 distributed_lock_backend 创建分布式锁，未配置时返回 nil
+func newDistributedLock(param *config.Param) (DistributedLock, error) {
+	switch param.DistributedLockBackend {
+	case "":
+		return nil, nil
+	case consts.DistributedLockBackendRedis:
+		lock, err := clients.NewRedisDistributedLock(param.RedisAddr)
+		if err != nil {
+			return nil, err
+		}
+		return lock, nil
+	}
+	return nil, fmt.Errorf("unknown distributed_lock_backend %s", param.DistributedLockBackend)
+}
+
+//distributedLockKey 同一服务集群的规则在所有 keeper 实例间使用同一把锁
+func distributedLockKey(serviceName, clusterName string) string {
+	return fmt.Sprintf("cudgx:lock:%s/%s", serviceName, clusterName)
+}
+
+//acquireRuleLock 最多等待 consts.DistributedLockWait 获取服务集群的分布式锁，获取不到时本轮跳过规则，不计为规则失败，
+//由持有锁的实例执行。锁的过期时间为 consts.DistributedLockTTL，规则执行期间每隔 DistributedLockRenewInterval 续期；
+//返回的 lockCtx 在锁丢失时取消，规则需要使用 lockCtx 执行，避免锁过期后与其他实例同时扩缩容。release 停止续期并释放锁
+func (keeper *ScheduleXRedundancyKeeper) acquireRuleLock(ctx context.Context, serviceName, clusterName string, trace *RuleTrace) (lockCtx context.Context, release func(), acquired bool) {
+	key := distributedLockKey(serviceName, clusterName)
+	waitCtx, cancelWait := context.WithTimeout(ctx, consts.DistributedLockWait)
+	defer cancelWait()
+	token, err := keeper.distributedLock.Lock(waitCtx, key, consts.DistributedLockTTL)
+	if err != nil {
+		if errors.Is(err, clients.ErrLockNotAcquired) {
+			trace.finish(TraceOutcomeSkipped, "distributed lock %s is held by another keeper", key)
+			return nil, nil, false
+		}
+		keeper.loggerFor(ctx).Warn("acquire distributed lock failed, skip this round", zap.String("service", serviceName),
+			zap.String("cluster", clusterName), zap.Error(err))
+		trace.finish(TraceOutcomeSkipped, "acquire distributed lock %s failed: %v", key, err)
+		return nil, nil, false
+	}
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		keeper.renewRuleLock(lockCtx, cancel, key, token)
+	}()
+	return lockCtx, func() {
+		cancel(nil)
+		<-done
+		releaseCtx, cancelRelease := context.WithTimeout(context.WithoutCancel(ctx), distributedLockReleaseTimeout)
+		defer cancelRelease()
+		if err := keeper.distributedLock.Unlock(releaseCtx, key, token); err != nil {
+			// 释放失败时锁在 TTL 后自动过期
+			keeper.loggerFor(ctx).Warn("release distributed lock failed", zap.String("key", key), zap.Error(err))
+		}
+	}, true
+}
+
+//renewRuleLock 在 lockCtx 结束前定期续期锁；锁已被其他实例持有，或距离上次续期成功已超过 TTL 时取消 lockCtx
+func (keeper *ScheduleXRedundancyKeeper) renewRuleLock(lockCtx context.Context, cancel context.CancelCauseFunc, key, token string) {
+	ticker := time.NewTicker(DistributedLockRenewInterval)
+	defer ticker.Stop()
+	renewedAt := time.Now()
+	for {
+		select {
+		case <-lockCtx.Done():
+			return
+		case <-ticker.C:
+		}
+		renewCtx, cancelRenew := context.WithTimeout(lockCtx, distributedLockReleaseTimeout)
+		err := keeper.distributedLock.Extend(renewCtx, key, token, consts.DistributedLockTTL)
+		cancelRenew()
+		if err == nil {
+			renewedAt = time.Now()
+			continue
+		}
+		if lockCtx.Err() != nil {
+			return
+		}
+		keeper.loggerFor(lockCtx).Warn("renew distributed lock failed", zap.String("key", key), zap.Error(err))
+		if errors.Is(err, clients.ErrLockNotHeld) || time.Since(renewedAt) >= consts.DistributedLockTTL {
+			cancel(fmt.Errorf("distributed lock %s lost, %w", key, err))
+			return
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//recordingDistributedLock 记录加锁、续期和释放的 key，held 中的 key 视为被其他实例持有；extendErr 不为空时续期失败
+type recordingDistributedLock struct {
+	lock      sync.Mutex
+	held      map[string]bool
+	err       error
+	extendErr error
+	locked    []string
+	extended  int
+	unlocked  []string
+	ttl       time.Duration
+}
+
+func (lock *recordingDistributedLock) Lock(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	lock.lock.Lock()
+	defer lock.lock.Unlock()
+	if lock.err != nil {
+		return "", lock.err
+	}
+	if lock.held[key] {
+		return "", clients.ErrLockNotAcquired
+	}
+	lock.locked = append(lock.locked, key)
+	lock.ttl = ttl
+	return "token-" + key, nil
+}
+
+func (lock *recordingDistributedLock) Extend(ctx context.Context, key, token string, ttl time.Duration) error {
+	lock.lock.Lock()
+	defer lock.lock.Unlock()
+	lock.extended++
+	return lock.extendErr
+}
+
+func (lock *recordingDistributedLock) extendCount() int {
+	lock.lock.Lock()
+	defer lock.lock.Unlock()
+	return lock.extended
+}
+
+//slowExpandScaler 扩容需要 delay，ctx 先结束时扩容失败
+type slowExpandScaler struct {
+	inFlightScaler
+	delay time.Duration
+}
+
+func (scaler *slowExpandScaler) ExpandService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(scaler.delay):
+	}
+	return scaler.inFlightScaler.ExpandService(ctx, serviceName, clusterName, count, idempotencyKey)
+}
+
+func (lock *recordingDistributedLock) Unlock(ctx context.Context, key, token string) error {
+	gomega.Expect(token).To(gomega.Equal("token-" + key))
+	lock.lock.Lock()
+	defer lock.lock.Unlock()
+	lock.unlocked = append(lock.unlocked, key)
+	return nil
+}
+
+var _ = ginkgo.Describe("DistributedLock", func() {
+	var rule *model.PredictRule
+	var scaler redundancy_keeper.Scaler
+
+	start := func(param *config.Param, opts ...redundancy_keeper.Option) *redundancy_keeper.ScheduleSummary {
+		opts = append([]redundancy_keeper.Option{redundancy_keeper.WithScaler(scaler)}, opts...)
+		opts = append(opts,
+			redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(param, opts...)).To(gomega.Succeed())
+		return redundancy_keeper.Start(context.Background())
+	}
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:               3100,
+			ServiceName:      "distributed.lock",
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 100,
+			ExecuteRatio:     100,
+			Status:           consts.RuleStatusEnable,
+		}
+		scaler = &inFlightScaler{}
+	})
+
+	ginkgo.AfterEach(func() {
+		redundancy_keeper.DistributedLockRenewInterval = consts.DistributedLockTTL / 3
+	})
+
+	ginkgo.It("runs the rule while holding the service cluster lock and releases it afterwards", func() {
+		lock := &recordingDistributedLock{}
+		summary := start(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true}, redundancy_keeper.WithDistributedLock(lock))
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(lock.locked).To(gomega.Equal([]string{"cudgx:lock:distributed.lock/default"}))
+		gomega.Expect(lock.unlocked).To(gomega.Equal(lock.locked))
+		gomega.Expect(lock.ttl).To(gomega.Equal(consts.DistributedLockTTL))
+	})
+
+	ginkgo.It("skips the rule without an error when another keeper holds the lock", func() {
+		lock := &recordingDistributedLock{held: map[string]bool{"cudgx:lock:distributed.lock/default": true}}
+		summary := start(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true}, redundancy_keeper.WithDistributedLock(lock))
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(0))
+		gomega.Expect(summary.RulesErrored).To(gomega.Equal(0))
+		gomega.Expect(scaler.(*inFlightScaler).expanded).To(gomega.Equal(0))
+		gomega.Expect(lock.unlocked).To(gomega.BeEmpty())
+
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(explain.Reason).To(gomega.ContainSubstring("held by another keeper"))
+	})
+
+	ginkgo.It("skips the rule when the lock backend is unavailable", func() {
+		lock := &recordingDistributedLock{err: errors.New("connection refused")}
+		summary := start(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true}, redundancy_keeper.WithDistributedLock(lock))
+		gomega.Expect(summary.RulesErrored).To(gomega.Equal(0))
+		gomega.Expect(scaler.(*inFlightScaler).expanded).To(gomega.Equal(0))
+
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(explain.Reason).To(gomega.ContainSubstring("connection refused"))
+	})
+
+	ginkgo.It("uses redis when distributed_lock_backend is redis", func() {
+		server, err := miniredis.Run()
+		gomega.Expect(err).To(gomega.BeNil())
+		defer server.Close()
+		other, err := clients.NewRedisDistributedLock(server.Addr())
+		gomega.Expect(err).To(gomega.BeNil())
+		token, err := other.Lock(context.Background(), "cudgx:lock:distributed.lock/default", time.Minute)
+		gomega.Expect(err).To(gomega.BeNil())
+
+		param := &config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true,
+			DistributedLockBackend: consts.DistributedLockBackendRedis, RedisAddr: server.Addr()}
+		summary := start(param)
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(0))
+		gomega.Expect(scaler.(*inFlightScaler).expanded).To(gomega.Equal(0))
+
+		gomega.Expect(other.Unlock(context.Background(), "cudgx:lock:distributed.lock/default", token)).To(gomega.Succeed())
+		summary = start(param)
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(server.Exists("cudgx:lock:distributed.lock/default")).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("renews the lock while a slow scale is still running", func() {
+		redundancy_keeper.DistributedLockRenewInterval = 10 * time.Millisecond
+		slow := &slowExpandScaler{delay: 100 * time.Millisecond}
+		scaler = slow
+		lock := &recordingDistributedLock{}
+		summary := start(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true}, redundancy_keeper.WithDistributedLock(lock))
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(lock.extendCount()).To(gomega.BeNumerically(">=", 3))
+		gomega.Expect(lock.unlocked).To(gomega.Equal(lock.locked))
+	})
+
+	ginkgo.It("cancels the rule when the lock is taken over during a scale", func() {
+		redundancy_keeper.DistributedLockRenewInterval = 10 * time.Millisecond
+		slow := &slowExpandScaler{delay: time.Second}
+		scaler = slow
+		lock := &recordingDistributedLock{extendErr: clients.ErrLockNotHeld}
+		summary := start(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithDistributedLock(lock), redundancy_keeper.WithRuleErrorStore(newMemoryRuleErrorStore()))
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(0))
+		gomega.Expect(summary.RulesErrored).To(gomega.Equal(1))
+		gomega.Expect(slow.expanded).To(gomega.Equal(0))
+	})
+
+	ginkgo.It("rejects an unknown backend or a redis backend without an address", func() {
+		err := redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true,
+			DistributedLockBackend: "etcd"})
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("distributed_lock_backend")))
+		err = redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true,
+			DistributedLockBackend: consts.DistributedLockBackendRedis})
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("redis_addr")))
+	})
+})
@@ -0,0 +1,190 @@
+package redundancy_keeper
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//ruleTraceHistorySize 每条规则保留最近多少次执行记录
+const ruleTraceHistorySize = 10
+
+const (
+	TraceOutcomeScaledUp   = "scaled_up"
+	TraceOutcomeScaledDown = "scaled_down"
+	TraceOutcomeSkipped    = "skipped"
+	TraceOutcomeFailed     = "failed"
+)
+
+//ErrRuleNotEvaluated 规则还没有被执行过
+var ErrRuleNotEvaluated = errors.New("rule has not been evaluated")
+
+//RuleTrace 规则一次执行的调试记录
+type RuleTrace struct {
+	RuleId      int64  `json:"rule_id"`
+	ServiceName string `json:"service_name"`
+	ClusterName string `json:"cluster_name"`
+	Timestamp   int64  `json:"timestamp"`
+	//Outcome 执行结果，参见 TraceOutcome* 常量
+	Outcome string `json:"outcome"`
+	//Reason 执行结果的说明
+	Reason string `json:"reason"`
+	//Redundancy 本次用于判断的冗余度，为中位数或 forecast_horizon_seconds 的预测值，未计算时为空
+	Redundancy *float64 `json:"redundancy,omitempty"`
+	//Steps 执行过程中的关键数据
+	Steps []string `json:"steps"`
+}
+
+func (trace *RuleTrace) step(format string, args ...interface{}) {
+	trace.Steps = append(trace.Steps, fmt.Sprintf(format, args...))
+}
+
+//finish 记录执行结果，只有第一次调用生效
+func (trace *RuleTrace) finish(outcome, format string, args ...interface{}) {
+	if trace.Outcome != "" {
+		return
+	}
+	trace.Outcome = outcome
+	trace.Reason = outcome + ": " + fmt.Sprintf(format, args...)
+}
+
+//ExplainResult 规则最近一次执行的结果
+type ExplainResult struct {
+	RuleId int64 `json:"rule_id"`
+	//Reason 最近一次执行结果的说明，例如 "skipped: insufficient samples (5 of 30 required)"
+	Reason string `json:"reason"`
+	//Trace 最近一次执行的调试记录
+	Trace *RuleTrace `json:"trace"`
+	//History 最近几次执行的调试记录，按时间倒序
+	History []*RuleTrace `json:"history"`
+}
+
+//traceRing 固定容量的执行记录环形缓冲
+type traceRing struct {
+	entries []*RuleTrace
+	next    int
+}
+
+func (ring *traceRing) add(trace *RuleTrace) {
+	if len(ring.entries) < ruleTraceHistorySize {
+		ring.entries = append(ring.entries, trace)
+		ring.next = len(ring.entries) % ruleTraceHistorySize
+		return
+	}
+	ring.entries[ring.next] = trace
+	ring.next = (ring.next + 1) % ruleTraceHistorySize
+}
+
+//newestFirst 按时间倒序返回所有记录
+func (ring *traceRing) newestFirst() []*RuleTrace {
+	result := make([]*RuleTrace, 0, len(ring.entries))
+	for i := 1; i <= len(ring.entries); i++ {
+		index := (ring.next - i + len(ring.entries)) % len(ring.entries)
+		result = append(result, ring.entries[index])
+	}
+	return result
+}
+
+//ruleTraces 各规则的执行记录
+type ruleTraces struct {
+	lock  sync.Mutex
+	rings map[int64]*traceRing
+}
+
+func (traces *ruleTraces) record(trace *RuleTrace) {
+	traces.lock.Lock()
+	defer traces.lock.Unlock()
+	if traces.rings == nil {
+		traces.rings = make(map[int64]*traceRing)
+	}
+	ring, ok := traces.rings[trace.RuleId]
+	if !ok {
+		ring = &traceRing{}
+		traces.rings[trace.RuleId] = ring
+	}
+	ring.add(trace)
+}
+
+func (traces *ruleTraces) history(ruleID int64) []*RuleTrace {
+	traces.lock.Lock()
+	defer traces.lock.Unlock()
+	ring, ok := traces.rings[ruleID]
+	if !ok {
+		return nil
+	}
+	return ring.newestFirst()
+}
+
+//latest 规则最近一次执行记录，未执行过时返回 nil
+func (traces *ruleTraces) latest(ruleID int64) *RuleTrace {
+	history := traces.history(ruleID)
+	if len(history) == 0 {
+		return nil
+	}
+	return history[0]
+}
+
+//latestRedundancies 每条规则最近一次计算出冗余度的执行记录
+func (traces *ruleTraces) latestRedundancies() []*RuleTrace {
+	traces.lock.Lock()
+	defer traces.lock.Unlock()
+	result := make([]*RuleTrace, 0, len(traces.rings))
+	for _, ring := range traces.rings {
+		for _, trace := range ring.newestFirst() {
+			if trace.Redundancy != nil {
+				result = append(result, trace)
+				break
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].RuleId < result[j].RuleId })
+	return result
+}
+
+//finishTrace 结束执行记录并保存，err 不为空且未记录结果时视为失败
+func (keeper *ScheduleXRedundancyKeeper) finishTrace(trace *RuleTrace, err error) {
+	if err != nil {
+		trace.finish(TraceOutcomeFailed, "%s", strings.TrimSpace(err.Error()))
+	}
+	trace.finish(TraceOutcomeSkipped, "no samples for cluster %s", trace.ClusterName)
+	previous := keeper.traces.latest(trace.RuleId)
+	keeper.traces.record(trace)
+	keeper.publishTrace(previous, trace)
+}
+
+//Explain 返回规则最近一次执行的原因说明和调试记录，规则未执行过时返回 ErrRuleNotEvaluated
+func (keeper *ScheduleXRedundancyKeeper) Explain(ruleID int64) (*ExplainResult, error) {
+	history := keeper.traces.history(ruleID)
+	if len(history) == 0 {
+		return nil, ErrRuleNotEvaluated
+	}
+	return &ExplainResult{
+		RuleId:  ruleID,
+		Reason:  history[0].Reason,
+		Trace:   history[0],
+		History: history,
+	}, nil
+}
+
+//LatestRedundancies 每条规则最近一次计算出冗余度的执行记录，按规则id排序
+func (keeper *ScheduleXRedundancyKeeper) LatestRedundancies() []*RuleTrace {
+	return keeper.traces.latestRedundancies()
+}
+
+//LatestRedundancies 每条规则最近一次计算出冗余度的执行记录
+func LatestRedundancies() ([]*RuleTrace, error) {
+	if redundancyKeeper == nil {
+		return nil, errors.New("redundancy keeper is not initialized")
+	}
+	return redundancyKeeper.LatestRedundancies(), nil
+}
+
+//Explain 返回规则最近一次执行的原因说明和调试记录
+func Explain(ruleID int64) (*ExplainResult, error) {
+	if redundancyKeeper == nil {
+		return nil, errors.New("redundancy keeper is not initialized")
+	}
+	return redundancyKeeper.Explain(ruleID)
+}
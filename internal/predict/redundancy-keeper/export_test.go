@@ -0,0 +1,4 @@
+package redundancy_keeper
+
+//RemoveOutliers 导出 removeOutliers 供外部测试包使用
+var RemoveOutliers = removeOutliers
@@ -0,0 +1,44 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"go.uber.org/zap"
+)
+
+//errMetricBackendsUnavailable 主指标后端和备用指标后端都查询失败，规则跳过本轮
+var errMetricBackendsUnavailable = errors.New("primary and fallback metric backends both failed")
+
+//WithFallbackMetricBackend 指定主指标后端查询失败时使用的备用后端，例如 Thanos 或本地的指标缓存；为空时不重试
+func WithFallbackMetricBackend(backend service.MetricBackend) Option {
+	return func(keeper *ScheduleXRedundancyKeeper) {
+		if backend != nil {
+			keeper.fallbackMetricBackend = backend
+		}
+	}
+}
+
+//queryRedundancy 查询规则的冗余度，主指标后端失败且 ctx 未结束时改为查询备用后端
+func (keeper *ScheduleXRedundancyKeeper) queryRedundancy(ctx context.Context, rule *model.PredictRule, begin, end int64) (*service.RedundancySeries, error) {
+	metricBackend, err := keeper.metricBackendFor(rule)
+	if err != nil {
+		return nil, err
+	}
+	series, err := keeper.queryBackend(ctx, metricBackend, rule, begin, end)
+	// 超时后备用后端也没有时间查询，交给调用方按超时处理
+	if err == nil || keeper.fallbackMetricBackend == nil || ctx.Err() != nil {
+		return series, err
+	}
+	keeper.metrics.MetricBackendFallbacks.WithLabelValues(rule.ServiceName, rule.ClusterName).Inc()
+	keeper.loggerFor(ctx).Warn("query primary metric backend failed, fall back", zap.String("service", rule.ServiceName),
+		zap.String("cluster", rule.ClusterName), zap.Error(err))
+	series, fallbackErr := keeper.queryBackend(ctx, keeper.fallbackMetricBackend, rule, begin, end)
+	if fallbackErr != nil {
+		return nil, fmt.Errorf("%w , primary: %v , fallback: %v", errMetricBackendsUnavailable, err, fallbackErr)
+	}
+	return series, nil
+}
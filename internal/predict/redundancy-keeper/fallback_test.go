@@ -0,0 +1,86 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("WithFallbackMetricBackend", func() {
+	rule := &model.PredictRule{
+		Id:               1500,
+		ServiceName:      "gf.cudgx.fallback",
+		ClusterName:      "default",
+		MetricName:       "qps",
+		BenchmarkQps:     100,
+		MinRedundancy:    150,
+		MaxRedundancy:    250,
+		MinInstanceCount: 1,
+		MaxInstanceCount: 20,
+		ExecuteRatio:     100,
+		Status:           consts.RuleStatusEnable,
+	}
+	var primaryCalls, fallbackCalls int
+	failingBackend := func(calls *int) service.MetricBackend {
+		return service.MetricBackendFunc(func(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+			*calls++
+			return nil, errors.New("prometheus is unavailable")
+		})
+	}
+	countingBackend := func(calls *int) service.MetricBackend {
+		return service.MetricBackendFunc(func(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+			*calls++
+			return lowRedundancyBackend{}.QueryRedundancy(ctx, serviceName, clusterName, metricName, mode, benchmark, begin, end, trimmedSecond)
+		})
+	}
+	start := func(primary, fallback service.MetricBackend) (*inFlightScaler, *redundancy_keeper.ScheduleSummary) {
+		scaler := &inFlightScaler{}
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricBackend(primary),
+			redundancy_keeper.WithFallbackMetricBackend(fallback),
+			redundancy_keeper.WithRuleErrorStore(newMemoryRuleErrorStore()),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		return scaler, redundancy_keeper.Start(context.Background())
+	}
+
+	ginkgo.BeforeEach(func() {
+		primaryCalls, fallbackCalls = 0, 0
+	})
+
+	ginkgo.It("does not query the fallback backend when the primary backend works", func() {
+		_, summary := start(countingBackend(&primaryCalls), countingBackend(&fallbackCalls))
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(primaryCalls).To(gomega.Equal(1))
+		gomega.Expect(fallbackCalls).To(gomega.Equal(0))
+	})
+
+	ginkgo.It("scales with the fallback backend when the primary backend fails", func() {
+		scaler, summary := start(failingBackend(&primaryCalls), countingBackend(&fallbackCalls))
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(scaler.expanded).To(gomega.BeNumerically(">", 0))
+		gomega.Expect(fallbackCalls).To(gomega.Equal(1))
+	})
+
+	ginkgo.It("skips the rule when both backends fail", func() {
+		_, summary := start(failingBackend(&primaryCalls), failingBackend(&fallbackCalls))
+		gomega.Expect(summary.RulesErrored).To(gomega.Equal(0))
+		gomega.Expect(summary.RulesEvaluated).To(gomega.Equal(1))
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(explain.Reason).To(gomega.Equal("skipped: primary and fallback metric backends both failed"))
+	})
+
+	ginkgo.It("fails the rule as before without a fallback backend", func() {
+		_, summary := start(failingBackend(&primaryCalls), nil)
+		gomega.Expect(summary.RulesErrored).To(gomega.Equal(1))
+	})
+})
@@ -0,0 +1,25 @@
+package redundancy_keeper
+
+import (
+	"math"
+
+	"github.com/galaxy-future/cudgx/common/stats"
+)
+
+//forecastRedundancy 对按时间顺序的冗余度做线性回归，外推 at 时刻的冗余度；
+//点数不足、时间戳与值数量不一致或结果不是正数时返回 false
+func forecastRedundancy(timestamps []int64, values []float64, at int64) (float64, bool) {
+	if len(values) < 2 || len(timestamps) != len(values) {
+		return 0, false
+	}
+	x := make([]float64, len(timestamps))
+	for i, timestamp := range timestamps {
+		x[i] = float64(timestamp)
+	}
+	slope, intercept := stats.LinearRegression(x, values)
+	forecast := slope*float64(at) + intercept
+	if math.IsNaN(forecast) || forecast <= 0 {
+		return 0, false
+	}
+	return forecast, true
+}
@@ -0,0 +1,74 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//decliningRedundancyBackend 每10秒一个点，冗余度每秒下降0.005，end 时为2
+type decliningRedundancyBackend struct{}
+
+func (decliningRedundancyBackend) QueryRedundancy(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+	cluster := &service.ClusterRedundancySeries{ClusterName: clusterName}
+	for timestamp := begin; timestamp <= end; timestamp += 10 {
+		cluster.Timestamps = append(cluster.Timestamps, timestamp)
+		cluster.Values = append(cluster.Values, 2+0.005*float64(end-timestamp))
+	}
+	return &service.RedundancySeries{ServiceName: serviceName, MetricName: metricName, Clusters: []*service.ClusterRedundancySeries{cluster}}, nil
+}
+
+func (decliningRedundancyBackend) QueryRawMetric(ctx context.Context, serviceName, clusterName, metricName string, start, end int64, step int) ([]float64, error) {
+	return nil, service.ErrRawMetricUnsupported
+}
+
+var _ = ginkgo.Describe("ForecastHorizonSeconds", func() {
+	var rule *model.PredictRule
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:               600,
+			ServiceName:      "forecast",
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 50,
+			ExecuteRatio:     100,
+			Status:           "enable",
+		}
+	})
+
+	run := func() *redundancy_keeper.ScheduleSummary {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(&inFlightScaler{}),
+			redundancy_keeper.WithMetricBackend(decliningRedundancyBackend{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		return redundancy_keeper.Start(context.Background())
+	}
+
+	ginkgo.It("keeps the median within range without a forecast", func() {
+		summary := run()
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(0))
+	})
+
+	ginkgo.It("scales up on the extrapolated redundancy", func() {
+		rule.ForecastHorizonSeconds = 120
+		summary := run()
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		// metric_send_duration 默认5s，end 为5秒前，预测值为 2-0.005*(120+5)
+		gomega.Expect(*explain.Trace.Redundancy).To(gomega.BeNumerically("~", 1.375, 1e-6))
+		gomega.Expect(explain.Trace.Steps).To(gomega.ContainElement("forecast redundancy 1.38 in 120s"))
+	})
+})
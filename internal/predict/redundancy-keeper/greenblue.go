@@ -0,0 +1,39 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+)
+
+//activeColorGetter 支持查询蓝绿部署当前生效颜色的 Scaler，green_blue_mode 的规则需要
+type activeColorGetter interface {
+	GetActiveColor(ctx context.Context, serviceName string) (string, error)
+}
+
+func (schedulxScaler) GetActiveColor(ctx context.Context, serviceName string) (string, error) {
+	return clients.GetActiveColorWithContext(ctx, serviceName)
+}
+
+//activeColorRule 返回只作用于当前生效颜色集群的规则副本，集群名为 cluster_name-颜色，例如 prod-blue
+func (keeper *ScheduleXRedundancyKeeper) activeColorRule(ctx context.Context, rule *model.PredictRule) (*model.PredictRule, string, error) {
+	getter, ok := keeper.scalerFor(rule).(activeColorGetter)
+	if !ok {
+		return nil, "", errors.New("scaler does not support green blue mode")
+	}
+	color, err := getter.GetActiveColor(ctx, rule.ServiceName)
+	if err != nil {
+		return nil, "", fmt.Errorf("query active color failed , %w", err)
+	}
+	return colorRule(rule, color), color, nil
+}
+
+//colorRule 返回只作用于 color 集群的规则副本
+func colorRule(rule *model.PredictRule, color string) *model.PredictRule {
+	colored := *rule
+	colored.ClusterName = fmt.Sprintf("%s-%s", rule.ClusterName, color)
+	return &colored
+}
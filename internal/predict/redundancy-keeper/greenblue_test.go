@@ -0,0 +1,93 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"github.com/galaxy-future/cudgx/internal/predict/query"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//colorScaler 当前生效颜色为 blue，记录扩容的集群
+type colorScaler struct {
+	inFlightScaler
+	expandedClusters []string
+}
+
+func (scaler *colorScaler) GetActiveColor(ctx context.Context, serviceName string) (string, error) {
+	return "blue", nil
+}
+
+func (scaler *colorScaler) ExpandService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	scaler.expandedClusters = append(scaler.expandedClusters, clusterName)
+	return scaler.inFlightScaler.ExpandService(ctx, serviceName, clusterName, count, idempotencyKey)
+}
+
+//filterRecordingBackend 记录查询的集群和 label 过滤条件，冗余度为0.5
+type filterRecordingBackend struct {
+	clusters []string
+	filters  []query.LabelFilter
+}
+
+func (backend *filterRecordingBackend) QueryRedundancy(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+	backend.clusters = append(backend.clusters, clusterName)
+	backend.filters = append(backend.filters, query.LabelFiltersFromContext(ctx)...)
+	return lowRedundancyBackend{}.QueryRedundancy(ctx, serviceName, clusterName, metricName, mode, benchmark, begin, end, trimmedSecond)
+}
+
+func (backend *filterRecordingBackend) QueryRawMetric(ctx context.Context, serviceName, clusterName, metricName string, start, end int64, step int) ([]float64, error) {
+	return nil, service.ErrRawMetricUnsupported
+}
+
+var _ = ginkgo.Describe("GreenBlueMode", func() {
+	var rule *model.PredictRule
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:               500,
+			ServiceName:      "bluegreen",
+			ClusterName:      "prod",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 50,
+			ExecuteRatio:     100,
+			GreenBlueMode:    true,
+			ColorMetricLabel: "color",
+			Status:           "enable",
+		}
+	})
+
+	run := func(scaler redundancy_keeper.Scaler, backend service.MetricBackend) *redundancy_keeper.ScheduleSummary {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricBackend(backend),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+			redundancy_keeper.WithRuleErrorStore(newMemoryRuleErrorStore()),
+		)).To(gomega.Succeed())
+		return redundancy_keeper.Start(context.Background())
+	}
+
+	ginkgo.It("queries and scales only the active color cluster", func() {
+		scaler := &colorScaler{}
+		backend := &filterRecordingBackend{}
+		summary := run(scaler, backend)
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(backend.clusters).To(gomega.Equal([]string{"prod-blue"}))
+		gomega.Expect(backend.filters).To(gomega.Equal([]query.LabelFilter{{Label: "color", Value: "blue"}}))
+		gomega.Expect(scaler.expandedClusters).To(gomega.Equal([]string{"prod-blue"}))
+	})
+
+	ginkgo.It("fails the rule when the scaler can not query the active color", func() {
+		scaler := &inFlightScaler{}
+		summary := run(scaler, &filterRecordingBackend{})
+		gomega.Expect(summary.RulesErrored).To(gomega.Equal(1))
+		gomega.Expect(scaler.expanded).To(gomega.Equal(0))
+	})
+})
@@ -0,0 +1,29 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+)
+
+//healthyInstanceIps 健康检查通过的实例 ip，healthy_instances_only 的规则只按这些 ip 查询指标，并按健康实例数计算扩缩容
+func (keeper *ScheduleXRedundancyKeeper) healthyInstanceIps(ctx context.Context, rule *model.PredictRule) ([]string, error) {
+	lister, ok := keeper.scalerFor(rule).(instanceLister)
+	if !ok {
+		return nil, errors.New("scaler does not support healthy_instances_only")
+	}
+	instances, err := lister.GetServiceInstanceList(ctx, rule.ServiceName, rule.ClusterName)
+	if err != nil {
+		return nil, fmt.Errorf("query service instance list failed , %w", err)
+	}
+	var ips []string
+	for _, instance := range instances {
+		if instance.Status == consts.InstanceStatusHealthy && instance.IP != "" {
+			ips = append(ips, instance.IP)
+		}
+	}
+	return ips, nil
+}
@@ -0,0 +1,108 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//healthScaler 返回带健康状态的实例列表，记录缩容的实例数
+type healthScaler struct {
+	inFlightScaler
+	instances []clients.InstanceInfo
+	shrunk    int
+}
+
+func (scaler *healthScaler) GetServiceInstanceList(ctx context.Context, serviceName, clusterName string) ([]clients.InstanceInfo, error) {
+	return scaler.instances, nil
+}
+
+func (scaler *healthScaler) ShrinkService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	scaler.shrunk += count
+	return nil
+}
+
+//instanceRedundancyBackend 按实例查询时返回固定的冗余度，记录查询的实例 ip
+type instanceRedundancyBackend struct {
+	redundancy float64
+	queried    *[]string
+}
+
+func (backend instanceRedundancyBackend) QueryRedundancy(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+	return nil, service.ErrInstanceScopeUnsupported
+}
+
+func (backend instanceRedundancyBackend) QueryRawMetric(ctx context.Context, serviceName, clusterName, metricName string, start, end int64, step int) ([]float64, error) {
+	return nil, service.ErrRawMetricUnsupported
+}
+
+func (backend instanceRedundancyBackend) QueryInstanceRedundancy(ctx context.Context, serviceName, clusterName, metricName string, instanceIps []string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+	*backend.queried = append(*backend.queried, instanceIps...)
+	return &service.RedundancySeries{
+		ServiceName: serviceName,
+		MetricName:  metricName,
+		Clusters:    []*service.ClusterRedundancySeries{{ClusterName: clusterName, Timestamps: []int64{begin}, Values: []float64{backend.redundancy}}},
+	}, nil
+}
+
+var _ = ginkgo.Describe("HealthyInstancesOnly", func() {
+	var rule *model.PredictRule
+	var scaler *healthScaler
+	var queried []string
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:                   1300,
+			ServiceName:          "health",
+			ClusterName:          "default",
+			MetricName:           "qps",
+			MetricScope:          consts.MetricScopeInstance,
+			BenchmarkQps:         100,
+			MinRedundancy:        150,
+			MaxRedundancy:        250,
+			MinInstanceCount:     1,
+			MaxInstanceCount:     20,
+			ExecuteRatio:         100,
+			HealthyInstancesOnly: true,
+			Status:               consts.RuleStatusEnable,
+		}
+		scaler = &healthScaler{}
+		for i, status := range []string{"healthy", "healthy", "healthy", "healthy", "unhealthy", "healthy", "healthy", "healthy", "unhealthy", "healthy"} {
+			scaler.instances = append(scaler.instances, clients.InstanceInfo{IP: fmt.Sprintf("10.0.0.%d", i), InstanceID: fmt.Sprintf("i-%d", i), Status: status})
+		}
+		queried = nil
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricBackend(instanceRedundancyBackend{redundancy: 3.0, queried: &queried}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+	})
+
+	ginkgo.It("queries only the healthy instances and sizes by the healthy count", func() {
+		summary := redundancy_keeper.Start(context.Background())
+		gomega.Expect(summary.RulesScaledDown).To(gomega.Equal(1))
+		gomega.Expect(queried).To(gomega.Equal([]string{"10.0.0.0", "10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.5", "10.0.0.6", "10.0.0.7", "10.0.0.9"}))
+		// 按8个健康实例计算 int(2.0/3.0*8)=5，按10个实例计算会缩容4个
+		gomega.Expect(scaler.shrunk).To(gomega.Equal(3))
+	})
+
+	ginkgo.It("skips the round when no instance is healthy", func() {
+		for i := range scaler.instances {
+			scaler.instances[i].Status = "unhealthy"
+		}
+		summary := redundancy_keeper.Start(context.Background())
+		gomega.Expect(summary.RulesScaledDown).To(gomega.Equal(0))
+		gomega.Expect(queried).To(gomega.BeEmpty())
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(explain.Reason).To(gomega.Equal("skipped: no healthy instances"))
+	})
+})
@@ -0,0 +1,72 @@
+package redundancy_keeper
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+//Heartbeat 记录keeper最近一次成功调度的时间，用于存活/就绪探针
+type Heartbeat struct {
+	lastBeat    atomic.Int64
+	rulesLoaded atomic.Bool
+	tickerFired atomic.Bool
+}
+
+//NewHeartbeat 新建 Heartbeat，并以当前时间作为初始心跳，避免首个调度周期内被判定为不存活
+func NewHeartbeat(now time.Time) *Heartbeat {
+	heartbeat := &Heartbeat{}
+	heartbeat.lastBeat.Store(now.UnixNano())
+	return heartbeat
+}
+
+//Beat 记录一次成功调度
+func (heartbeat *Heartbeat) Beat(now time.Time) {
+	heartbeat.lastBeat.Store(now.UnixNano())
+}
+
+//LastBeat 最近一次心跳时间
+func (heartbeat *Heartbeat) LastBeat() time.Time {
+	return time.Unix(0, heartbeat.lastBeat.Load())
+}
+
+//MarkRulesLoaded 标记已完成规则加载
+func (heartbeat *Heartbeat) MarkRulesLoaded() {
+	heartbeat.rulesLoaded.Store(true)
+}
+
+//MarkTickerFired 标记调度 ticker 已触发
+func (heartbeat *Heartbeat) MarkTickerFired() {
+	heartbeat.tickerFired.Store(true)
+}
+
+//IsAlive 最近一次心跳在 multiplier 个调度周期之内
+func (heartbeat *Heartbeat) IsAlive(now time.Time, scheduleDuration time.Duration, multiplier float64) bool {
+	threshold := time.Duration(float64(scheduleDuration) * multiplier)
+	return now.Sub(heartbeat.LastBeat()) <= threshold
+}
+
+//IsReady 已完成首次规则加载且 ticker 至少触发过一次
+func (heartbeat *Heartbeat) IsReady() bool {
+	return heartbeat.rulesLoaded.Load() && heartbeat.tickerFired.Load()
+}
+
+//IsAlive keeper 是否存活，未初始化时视为不存活；开启 BackoffScheduleDuration 时按实际生效的调度周期判断
+func IsAlive() bool {
+	if redundancyKeeper == nil {
+		return false
+	}
+	keeper := redundancyKeeper
+	scheduleDuration := keeper.scheduleDuration()
+	keeper.lock.RLock()
+	multiplier := keeper.LivenessThresholdMultiplier
+	keeper.lock.RUnlock()
+	return keeper.heartbeat.IsAlive(keeper.now(), scheduleDuration, multiplier)
+}
+
+//IsReady keeper 是否就绪
+func IsReady() bool {
+	if redundancyKeeper == nil {
+		return false
+	}
+	return redundancyKeeper.heartbeat.IsReady()
+}
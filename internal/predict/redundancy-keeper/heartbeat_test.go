@@ -0,0 +1,52 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/types"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Heartbeat", func() {
+	start := time.Unix(1640000000, 0)
+
+	ginkgo.It("is alive within the threshold", func() {
+		heartbeat := redundancy_keeper.NewHeartbeat(start)
+		gomega.Expect(heartbeat.IsAlive(start.Add(119*time.Second), time.Minute, 2)).To(gomega.BeTrue())
+		gomega.Expect(heartbeat.IsAlive(start.Add(121*time.Second), time.Minute, 2)).To(gomega.BeFalse())
+
+		heartbeat.Beat(start.Add(100 * time.Second))
+		gomega.Expect(heartbeat.IsAlive(start.Add(200*time.Second), time.Minute, 2)).To(gomega.BeTrue())
+		gomega.Expect(heartbeat.IsAlive(start.Add(200*time.Second), time.Minute, 1.5)).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("is ready after rules loaded and ticker fired", func() {
+		heartbeat := redundancy_keeper.NewHeartbeat(start)
+		gomega.Expect(heartbeat.IsReady()).To(gomega.BeFalse())
+		heartbeat.MarkTickerFired()
+		gomega.Expect(heartbeat.IsReady()).To(gomega.BeFalse())
+		heartbeat.MarkRulesLoaded()
+		gomega.Expect(heartbeat.IsReady()).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("uses the backed off schedule duration for liveness", func() {
+		slowLister := func() ([]*model.PredictRule, error) {
+			time.Sleep(45 * time.Millisecond)
+			return nil, nil
+		}
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RunDuration: types.Duration{Duration: 50 * time.Millisecond},
+			MaxScheduleDuration: types.Duration{Duration: time.Second}, BackoffScheduleDuration: true, LivenessThresholdMultiplier: 2,
+			RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true}, redundancy_keeper.WithRuleLister(slowLister))).To(gomega.Succeed())
+		// 连续3次慢调度后周期从50ms翻倍为100ms，存活阈值从100ms变为200ms
+		for i := 0; i < 3; i++ {
+			redundancy_keeper.Start(context.Background())
+		}
+		time.Sleep(130 * time.Millisecond)
+		gomega.Expect(redundancy_keeper.IsAlive()).To(gomega.BeTrue())
+	})
+})
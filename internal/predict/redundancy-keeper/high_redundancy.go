@@ -0,0 +1,87 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"go.uber.org/zap"
+)
+
+//HighRedundancyNotifier 冗余度超过规则的 alert_on_high_redundancy 时发送通知
+type HighRedundancyNotifier interface {
+	NotifyHighRedundancy(ctx context.Context, e *event.HighRedundancyEvent) error
+}
+
+//WithHighRedundancyNotifier 增加一个冗余度过高通知的接收方，可以多次指定
+func WithHighRedundancyNotifier(notifier HighRedundancyNotifier) Option {
+	return func(keeper *ScheduleXRedundancyKeeper) {
+		if notifier != nil {
+			keeper.highRedundancyNotifiers = append(keeper.highRedundancyNotifiers, notifier)
+		}
+	}
+}
+
+//alertHistory 记录各规则最近一次冗余度过高告警的时间，用于限制告警频率
+type alertHistory struct {
+	lock sync.Mutex
+	//lastAlertAt 规则id/集群 -> 最近一次告警时间
+	lastAlertAt map[string]time.Time
+}
+
+//tryAlert 距离上次告警超过 cooldown 时记录本次告警并返回 true
+func (history *alertHistory) tryAlert(key string, now time.Time, cooldown time.Duration) bool {
+	history.lock.Lock()
+	defer history.lock.Unlock()
+	if last, ok := history.lastAlertAt[key]; ok && now.Sub(last) < cooldown {
+		return false
+	}
+	if history.lastAlertAt == nil {
+		history.lastAlertAt = make(map[string]time.Time)
+	}
+	history.lastAlertAt[key] = now
+	return true
+}
+
+//alertCooldown 规则两次冗余度过高告警的最小间隔
+func alertCooldown(rule *model.PredictRule) time.Duration {
+	if rule.AlertCooldownMinutes > 0 {
+		return time.Duration(rule.AlertCooldownMinutes) * time.Minute
+	}
+	return consts.DefaultAlertCooldownMinutes * time.Minute
+}
+
+//checkHighRedundancy 冗余度超过 alert_on_high_redundancy 时打印告警日志并发送通知，每条规则每个 alert_cooldown_minutes 最多告警一次。
+//冗余度过高不影响本轮调度，服务会按 max_redundancy 逐步缩容
+func (keeper *ScheduleXRedundancyKeeper) checkHighRedundancy(ctx context.Context, rule *model.PredictRule, redundancy float64, currentCount int, now time.Time, trace *RuleTrace) {
+	if rule.AlertOnHighRedundancy <= 0 || redundancy <= rule.AlertOnHighRedundancy {
+		return
+	}
+	key := strconv.FormatInt(rule.Id, 10) + "/" + rule.ClusterName
+	if !keeper.alerts.tryAlert(key, now, alertCooldown(rule)) {
+		return
+	}
+	keeper.metrics.HighRedundancyAlerts.WithLabelValues(rule.ServiceName, rule.ClusterName).Inc()
+	trace.step("redundancy %.2f exceeds alert_on_high_redundancy %.2f, alert sent", redundancy, rule.AlertOnHighRedundancy)
+	keeper.loggerFor(ctx).Warn("redundancy is too high, service may be over-provisioned", zap.String("service", rule.ServiceName),
+		zap.String("cluster", rule.ClusterName), zap.Float64("redundancy", redundancy), zap.Float64("alert_on_high_redundancy", rule.AlertOnHighRedundancy),
+		zap.Int("instance_count", currentCount))
+	e := &event.HighRedundancyEvent{
+		RuleId:        rule.Id,
+		ServiceName:   rule.ServiceName,
+		ClusterName:   rule.ClusterName,
+		Redundancy:    redundancy,
+		Threshold:     rule.AlertOnHighRedundancy,
+		InstanceCount: currentCount,
+		Timestamp:     now.Unix(),
+	}
+	for _, notifier := range keeper.highRedundancyNotifiers {
+		if err := notifier.NotifyHighRedundancy(ctx, e); err != nil {
+			keeper.loggerFor(ctx).Error("notify high redundancy failed", zap.Int64("rule_id", rule.Id), zap.Error(err))
+		}
+	}
+}
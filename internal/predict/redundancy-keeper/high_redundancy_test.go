@@ -0,0 +1,91 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"sync"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//recordingHighRedundancyNotifier 记录收到的冗余度过高通知
+type recordingHighRedundancyNotifier struct {
+	lock   sync.Mutex
+	events []*event.HighRedundancyEvent
+}
+
+func (notifier *recordingHighRedundancyNotifier) NotifyHighRedundancy(ctx context.Context, e *event.HighRedundancyEvent) error {
+	notifier.lock.Lock()
+	defer notifier.lock.Unlock()
+	notifier.events = append(notifier.events, e)
+	return nil
+}
+
+var _ = ginkgo.Describe("AlertOnHighRedundancy", func() {
+	var rule *model.PredictRule
+	var notifier *recordingHighRedundancyNotifier
+
+	start := func(redundancy float64) *redundancy_keeper.ScheduleSummary {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(&inFlightScaler{}),
+			redundancy_keeper.WithMetricBackend(service.MetricBackendFunc(func(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+				return &service.RedundancySeries{
+					ServiceName: serviceName,
+					MetricName:  metricName,
+					Clusters:    []*service.ClusterRedundancySeries{{ClusterName: clusterName, Timestamps: []int64{begin}, Values: []float64{redundancy}}},
+				}, nil
+			})),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+			redundancy_keeper.WithHighRedundancyNotifier(notifier),
+		)).To(gomega.Succeed())
+		return redundancy_keeper.Start(context.Background())
+	}
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:                    1500,
+			ServiceName:           "over-provisioned",
+			ClusterName:           "default",
+			MetricName:            "qps",
+			BenchmarkQps:          100,
+			MinRedundancy:         150,
+			MaxRedundancy:         250,
+			MinInstanceCount:      1,
+			MaxInstanceCount:      20,
+			ExecuteRatio:          100,
+			AlertOnHighRedundancy: 3,
+			Status:                consts.RuleStatusEnable,
+		}
+		notifier = &recordingHighRedundancyNotifier{}
+	})
+
+	ginkgo.It("notifies and still shrinks the service when redundancy exceeds the threshold", func() {
+		summary := start(4)
+		gomega.Expect(summary.RulesScaledDown).To(gomega.Equal(1))
+		gomega.Expect(notifier.events).To(gomega.HaveLen(1))
+		gomega.Expect(notifier.events[0].RuleId).To(gomega.Equal(rule.Id))
+		gomega.Expect(notifier.events[0].Redundancy).To(gomega.Equal(4.0))
+		gomega.Expect(notifier.events[0].Threshold).To(gomega.Equal(3.0))
+		gomega.Expect(notifier.events[0].InstanceCount).To(gomega.Equal(10))
+	})
+
+	ginkgo.It("does not notify again within alert_cooldown_minutes", func() {
+		start(4)
+		gomega.Expect(redundancy_keeper.Start(context.Background()).RulesScaledDown).To(gomega.Equal(1))
+		gomega.Expect(notifier.events).To(gomega.HaveLen(1))
+	})
+
+	ginkgo.It("does not notify at or below the threshold or when disabled", func() {
+		start(3)
+		gomega.Expect(notifier.events).To(gomega.BeEmpty())
+		rule.AlertOnHighRedundancy = 0
+		start(10)
+		gomega.Expect(notifier.events).To(gomega.BeEmpty())
+	})
+})
@@ -0,0 +1,73 @@
+package redundancy_keeper_test
+
+import (
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/types"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("InitRedundancyKeeper", func() {
+	validParam := func() *config.Param {
+		return &config.Param{RunDuration: types.Duration{Duration: time.Minute}, RuleConcurrency: 1, MinimalSampleCount: 1}
+	}
+
+	ginkgo.It("accepts a valid param", func() {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(validParam())).To(gomega.Succeed())
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true})).To(gomega.Succeed())
+	})
+
+	ginkgo.It("rejects a nil param", func() {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(nil)).To(gomega.MatchError(gomega.ContainSubstring("param is required")))
+	})
+
+	ginkgo.It("names every zero or invalid field", func() {
+		cases := map[string]func(param *config.Param){
+			"run_duration should be positive":            func(param *config.Param) { param.RunDuration.Duration = 0 },
+			"rule_concurrency should be positive":        func(param *config.Param) { param.RuleConcurrency = 0 },
+			"rule_concurrency_per_service can not be":    func(param *config.Param) { param.RuleConcurrencyPerService = -1 },
+			"minimal_sample_count should be positive":    func(param *config.Param) { param.MinimalSampleCount = 0 },
+			"lookback_duration can not be negative":      func(param *config.Param) { param.LookbackDuration.Duration = -time.Second },
+			"metric_send_duration can not be negative":   func(param *config.Param) { param.MetricSendDuration.Duration = -time.Second },
+			"metric_query_timeout can not be negative":   func(param *config.Param) { param.MetricQueryTimeout.Duration = -time.Second },
+			"schedule_cache_ttl can not be negative":     func(param *config.Param) { param.ScheduleCacheTTL.Duration = -time.Second },
+			"min_schedule_duration can not be negative":  func(param *config.Param) { param.MinScheduleDuration.Duration = -time.Second },
+			"max_schedule_duration can not be negative":  func(param *config.Param) { param.MaxScheduleDuration.Duration = -time.Second },
+			"liveness_threshold_multiplier can not be":   func(param *config.Param) { param.LivenessThresholdMultiplier = -1 },
+			"error_threshold_for_disable can not be":     func(param *config.Param) { param.ErrorThresholdForDisable = -1 },
+			"approval_timeout_minutes can not be":        func(param *config.Param) { param.ApprovalTimeoutMinutes = -1 },
+			"max_watch_connections can not be negative":  func(param *config.Param) { param.MaxWatchConnections = -1 },
+			"metrics_retention_days can not be negative": func(param *config.Param) { param.MetricsRetentionDays = -1 },
+			"prune_interval can not be negative":         func(param *config.Param) { param.PruneInterval.Duration = -time.Second },
+		}
+		for message, modify := range cases {
+			param := validParam()
+			modify(param)
+			gomega.Expect(redundancy_keeper.InitRedundancyKeeper(param)).To(gomega.MatchError(gomega.ContainSubstring(message)))
+		}
+	})
+
+	ginkgo.It("reports all invalid fields at once and keeps the previous keeper", func() {
+		previous := validParam()
+		previous.RuleConcurrency = 7
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(previous)).To(gomega.Succeed())
+
+		err := redundancy_keeper.InitRedundancyKeeper(&config.Param{})
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("run_duration")))
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("rule_concurrency")))
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("minimal_sample_count")))
+
+		param, err := redundancy_keeper.GetParam()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(param.RuleConcurrency).To(gomega.Equal(7))
+	})
+
+	ginkgo.It("rejects an invalid param on reload", func() {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(validParam())).To(gomega.Succeed())
+		_, err := redundancy_keeper.Reload(&config.Param{RunDuration: types.Duration{Duration: time.Minute}, MinimalSampleCount: 1})
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("rule_concurrency should be positive")))
+	})
+})
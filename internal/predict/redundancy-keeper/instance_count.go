@@ -0,0 +1,21 @@
+package redundancy_keeper
+
+import (
+	"fmt"
+
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+)
+
+//ValidateInstanceCount 检查查询到的实例数是否可信：小于0不合法，为0时服务可能已下线，
+//超过 MaxInstanceCount 的两倍时数据可能过期或有误；不可信时本轮不做扩缩容判断
+func ValidateInstanceCount(count int, rule *model.PredictRule) error {
+	switch {
+	case count < 0:
+		return fmt.Errorf("instance count %d is invalid", count)
+	case count == 0:
+		return fmt.Errorf("instance count is 0, service might be down")
+	case rule.MaxInstanceCount > 0 && count > rule.MaxInstanceCount*2:
+		return fmt.Errorf("instance count %d exceeds twice the max_instance_count %d, data might be stale or wrong", count, rule.MaxInstanceCount)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package redundancy_keeper
+
+import (
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+)
+
+//applyInstanceCountMultipliers 处于 instance_count_multiplier_schedule 的时间段内时，返回放大最小、最大实例数后的规则副本；
+//最大实例数至少为最小实例数
+func applyInstanceCountMultipliers(rule *model.PredictRule, now time.Time, trace *RuleTrace) *model.PredictRule {
+	minMultiplier, maxMultiplier, ok := rule.InstanceCountMultiplierSchedule.Active(now)
+	if !ok {
+		return rule
+	}
+	scheduled := *rule
+	if minMultiplier > 0 {
+		scheduled.MinInstanceCount = int(float64(rule.MinInstanceCount) * minMultiplier)
+	}
+	if maxMultiplier > 0 {
+		scheduled.MaxInstanceCount = int(float64(rule.MaxInstanceCount) * maxMultiplier)
+	}
+	if scheduled.MaxInstanceCount < scheduled.MinInstanceCount {
+		scheduled.MaxInstanceCount = scheduled.MinInstanceCount
+	}
+	trace.step("instance count multiplier schedule active, min_instance_count %d -> %d, max_instance_count %d -> %d",
+		rule.MinInstanceCount, scheduled.MinInstanceCount, rule.MaxInstanceCount, scheduled.MaxInstanceCount)
+	return &scheduled
+}
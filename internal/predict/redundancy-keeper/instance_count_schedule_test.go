@@ -0,0 +1,88 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//alwaysActive 下一次结束总是早于下一次开始，始终处于时间段内
+var alwaysActive = model.InstanceCountMultiplier{StartCron: "0 0 1 1 *", EndCron: "* * * * *"}
+
+//neverActive 下一次开始总是早于下一次结束，始终不在时间段内
+var neverActive = model.InstanceCountMultiplier{StartCron: "* * * * *", EndCron: "0 0 1 1 *"}
+
+var _ = ginkgo.Describe("InstanceCountMultiplierSchedule", func() {
+	var rule *model.PredictRule
+	var scaler *multiClusterScaler
+
+	start := func() {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricBackend(service.MetricBackendFunc(func(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+				return &service.RedundancySeries{
+					ServiceName: serviceName,
+					MetricName:  metricName,
+					Clusters:    []*service.ClusterRedundancySeries{{ClusterName: clusterName, Timestamps: []int64{begin}, Values: []float64{4}}},
+				}, nil
+			})),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		redundancy_keeper.Start(context.Background())
+	}
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:               1600,
+			ServiceName:      "batch-job",
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 4,
+			MaxInstanceCount: 20,
+			ExecuteRatio:     100,
+			Status:           consts.RuleStatusEnable,
+		}
+		scaler = &multiClusterScaler{expanded: map[string]int{}, shrunk: map[string]int{}}
+	})
+
+	ginkgo.It("shrinks down to min_instance_count outside the schedule", func() {
+		rule.InstanceCountMultiplierSchedule = model.InstanceCountMultipliers{withMultipliers(neverActive, 2, 0)}
+		start()
+		// 冗余度4.0回到2.0需要5台
+		gomega.Expect(scaler.shrunk).To(gomega.Equal(map[string]int{"default": 5}))
+	})
+
+	ginkgo.It("raises min_instance_count with the largest active multiplier", func() {
+		rule.InstanceCountMultiplierSchedule = model.InstanceCountMultipliers{
+			withMultipliers(alwaysActive, 1.5, 0),
+			withMultipliers(alwaysActive, 2, 0),
+			withMultipliers(neverActive, 2.5, 0),
+		}
+		start()
+		gomega.Expect(scaler.shrunk).To(gomega.Equal(map[string]int{"default": 2}))
+
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(explain.Trace.Steps).To(gomega.ContainElement("instance count multiplier schedule active, min_instance_count 4 -> 8, max_instance_count 20 -> 20"))
+	})
+
+	ginkgo.It("rejects invalid cron expressions", func() {
+		rule.InstanceCountMultiplierSchedule = model.InstanceCountMultipliers{{StartCron: "every night", EndCron: "* * * * *"}}
+		gomega.Expect(rule.Validate()).To(gomega.MatchError(gomega.ContainSubstring("start_cron")))
+	})
+})
+
+func withMultipliers(multiplier model.InstanceCountMultiplier, minMultiplier, maxMultiplier float64) model.InstanceCountMultiplier {
+	multiplier.MinMultiplier = minMultiplier
+	multiplier.MaxMultiplier = maxMultiplier
+	return multiplier
+}
@@ -0,0 +1,66 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//fixedCountScaler 返回固定的实例数
+type fixedCountScaler struct {
+	inFlightScaler
+	count int
+}
+
+func (scaler *fixedCountScaler) GetServiceInstanceCount(ctx context.Context, serviceName, clusterName string) (int, error) {
+	return scaler.count, nil
+}
+
+var _ = ginkgo.Describe("ValidateInstanceCount", func() {
+	rule := &model.PredictRule{
+		Id:               1400,
+		ServiceName:      "gf.cudgx.count",
+		ClusterName:      "default",
+		MetricName:       "qps",
+		BenchmarkQps:     100,
+		MinRedundancy:    150,
+		MaxRedundancy:    250,
+		MinInstanceCount: 1,
+		MaxInstanceCount: 20,
+		ExecuteRatio:     100,
+		Status:           consts.RuleStatusEnable,
+	}
+
+	ginkgo.It("rejects negative, zero and abnormally high counts", func() {
+		gomega.Expect(redundancy_keeper.ValidateInstanceCount(-1, rule)).NotTo(gomega.BeNil())
+		gomega.Expect(redundancy_keeper.ValidateInstanceCount(0, rule)).NotTo(gomega.BeNil())
+		gomega.Expect(redundancy_keeper.ValidateInstanceCount(41, rule)).NotTo(gomega.BeNil())
+		gomega.Expect(redundancy_keeper.ValidateInstanceCount(1, rule)).To(gomega.BeNil())
+		gomega.Expect(redundancy_keeper.ValidateInstanceCount(40, rule)).To(gomega.BeNil())
+	})
+
+	ginkgo.It("skips the scaling decision when the count is invalid", func() {
+		for count, reason := range map[int]string{
+			0:  "skipped: instance count is 0, service might be down",
+			50: "skipped: instance count 50 exceeds twice the max_instance_count 20, data might be stale or wrong",
+		} {
+			scaler := &fixedCountScaler{count: count}
+			gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+				redundancy_keeper.WithScaler(scaler),
+				redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+				redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+			)).To(gomega.Succeed())
+			summary := redundancy_keeper.Start(context.Background())
+			gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(0))
+			gomega.Expect(scaler.expanded).To(gomega.Equal(0))
+			explain, err := redundancy_keeper.Explain(rule.Id)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(explain.Reason).To(gomega.Equal(reason))
+		}
+	})
+})
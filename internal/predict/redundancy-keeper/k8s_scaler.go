@@ -0,0 +1,55 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+)
+
+//K8sScaler 通过 Kubernetes scale 子资源调整 Deployment/StatefulSet 的副本数，clusterName 为 namespace/name，
+//serviceName 只用于指标查询，不参与扩缩容
+type K8sScaler struct {
+	//Resource clients.K8sResourceDeployments 或 clients.K8sResourceStatefulSets
+	Resource string
+}
+
+//CanServiceSchedule 上一次修改的副本数还没有全部创建时不能调度
+func (scaler K8sScaler) CanServiceSchedule(ctx context.Context, serviceName, clusterName string) (bool, error) {
+	scale, err := clients.GetK8sScale(ctx, scaler.Resource, clusterName)
+	if err != nil {
+		return false, err
+	}
+	return scale.Status.Replicas == scale.Spec.Replicas, nil
+}
+
+func (scaler K8sScaler) GetServiceInstanceCount(ctx context.Context, serviceName, clusterName string) (int, error) {
+	scale, err := clients.GetK8sScale(ctx, scaler.Resource, clusterName)
+	if err != nil {
+		return 0, err
+	}
+	return scale.Status.Replicas, nil
+}
+
+//GetServiceInstanceIps scale 子资源不包含 Pod 信息，k8s 集群不支持 metric_scope 为 instance 的规则
+func (scaler K8sScaler) GetServiceInstanceIps(ctx context.Context, serviceName, clusterName string) ([]string, error) {
+	return nil, errors.New("k8s scaler does not support instance ips")
+}
+
+//ExpandService 副本数的更新携带 resourceVersion，不需要 idempotencyKey
+func (scaler K8sScaler) ExpandService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	return clients.ChangeK8sReplicas(ctx, scaler.Resource, clusterName, count)
+}
+
+func (scaler K8sScaler) ExpandServiceAndWait(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	return clients.ExpandK8sAndWait(ctx, scaler.Resource, clusterName, count)
+}
+
+func (scaler K8sScaler) GradualExpandService(ctx context.Context, serviceName, clusterName string, totalCount int, batchSize int, batchInterval time.Duration, idempotencyKey string) error {
+	return clients.GradualExpandK8s(ctx, scaler.Resource, clusterName, totalCount, batchSize, batchInterval)
+}
+
+func (scaler K8sScaler) ShrinkService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	return clients.ChangeK8sReplicas(ctx, scaler.Resource, clusterName, -count)
+}
@@ -0,0 +1,75 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("K8sScaler", func() {
+	var server *httptest.Server
+	var updated []clients.K8sScale
+	var schedulx *inFlightScaler
+
+	ginkgo.BeforeEach(func() {
+		updated = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/apis/apps/v1/namespaces/prod/deployments/api/scale" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.Method == http.MethodPut {
+				var scale clients.K8sScale
+				gomega.Expect(json.NewDecoder(r.Body).Decode(&scale)).To(gomega.Succeed())
+				updated = append(updated, scale)
+			}
+			_, _ = w.Write([]byte(`{"apiVersion":"autoscaling/v1","kind":"Scale","metadata":{"name":"api","namespace":"prod","resourceVersion":"42"},` +
+				`"spec":{"replicas":10},"status":{"replicas":10,"selector":"app=api"}}`))
+		}))
+		gomega.Expect(clients.InitializeK8sClient(server.URL, "", "")).To(gomega.Succeed())
+
+		rule := &model.PredictRule{
+			Id:               2100,
+			ServiceName:      "api",
+			ClusterName:      "prod/api",
+			ClusterType:      consts.ClusterTypeK8sDeployment,
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 20,
+			ExecuteRatio:     100,
+			Status:           consts.RuleStatusEnable,
+		}
+		schedulx = &inFlightScaler{}
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(schedulx),
+			redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+	})
+
+	ginkgo.AfterEach(func() {
+		server.Close()
+		gomega.Expect(clients.InitializeK8sClient("", "", "")).To(gomega.Succeed())
+	})
+
+	ginkgo.It("scales k8s_deployment rules through the scale subresource instead of schedulx", func() {
+		summary := redundancy_keeper.Start(context.Background())
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(schedulx.expanded).To(gomega.Equal(0))
+		gomega.Expect(updated).To(gomega.HaveLen(1))
+		gomega.Expect(updated[0].Metadata.ResourceVersion).To(gomega.Equal("42"))
+		gomega.Expect(updated[0].Spec.Replicas).To(gomega.Equal(20))
+	})
+})
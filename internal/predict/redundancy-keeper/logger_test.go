@@ -0,0 +1,39 @@
+package redundancy_keeper_test
+
+import (
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/types"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var _ = ginkgo.Describe("WithLogger", func() {
+	param := &config.Param{RunDuration: types.Duration{Duration: time.Minute}, RuleConcurrency: 1, MinimalSampleCount: 1}
+
+	ginkgo.It("writes keeper logs to the injected logger", func() {
+		core, logs := observer.New(zapcore.InfoLevel)
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(param, redundancy_keeper.WithLogger(zap.New(core)))).To(gomega.Succeed())
+
+		gomega.Expect(redundancy_keeper.SetRuleOverride(&model.PredictRule{Id: 9, MaxInstanceCount: 10}, redundancy_keeper.RuleOverride{MaxInstanceCount: intPtr(5)})).To(gomega.BeNil())
+		gomega.Expect(redundancy_keeper.ClearRuleOverride(9)).To(gomega.BeNil())
+
+		entries := logs.All()
+		gomega.Expect(entries).To(gomega.HaveLen(2))
+		gomega.Expect(entries[0].Message).To(gomega.Equal("set rule override"))
+		gomega.Expect(entries[0].ContextMap()).To(gomega.HaveKeyWithValue("rule_id", int64(9)))
+		gomega.Expect(entries[1].Message).To(gomega.Equal("clear rule override"))
+	})
+
+	ginkgo.It("falls back to the global logger when nil", func() {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(param, redundancy_keeper.WithLogger(nil))).To(gomega.Succeed())
+		gomega.Expect(redundancy_keeper.SetRuleOverride(&model.PredictRule{Id: 9, MaxInstanceCount: 10}, redundancy_keeper.RuleOverride{MaxInstanceCount: intPtr(5)})).To(gomega.BeNil())
+		gomega.Expect(redundancy_keeper.ClearRuleOverride(9)).To(gomega.BeNil())
+	})
+})
@@ -0,0 +1,51 @@
+package redundancy_keeper
+
+import (
+	"fmt"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+)
+
+//WithMetricBackendAliases 指定 metric_backend_alias 对应的指标后端，与 param 的 metric_backends 合并，同名时后指定的生效
+func WithMetricBackendAliases(backends map[string]service.MetricBackend) Option {
+	return func(keeper *ScheduleXRedundancyKeeper) {
+		if len(backends) == 0 {
+			return
+		}
+		if keeper.aliasMetricBackends == nil {
+			keeper.aliasMetricBackends = make(map[string]service.MetricBackend, len(backends))
+		}
+		for alias, backend := range backends {
+			if backend != nil {
+				keeper.aliasMetricBackends[alias] = backend
+			}
+		}
+	}
+}
+
+//newAliasMetricBackends 按 metric_backends 配置创建各别名的指标后端
+func newAliasMetricBackends(configs map[string]config.MetricBackendConfig) (map[string]service.MetricBackend, error) {
+	backends := make(map[string]service.MetricBackend, len(configs))
+	for alias, backendConfig := range configs {
+		backend, err := service.NewMetricBackendFromConfig(backendConfig)
+		if err != nil {
+			return nil, fmt.Errorf("metric_backends %s : %w", alias, err)
+		}
+		backends[alias] = backend
+	}
+	return backends, nil
+}
+
+//metricBackendFor 规则查询冗余度使用的指标后端，metric_backend_alias 为空时使用主后端，别名未配置时返回错误
+func (keeper *ScheduleXRedundancyKeeper) metricBackendFor(rule *model.PredictRule) (service.MetricBackend, error) {
+	if rule.MetricBackendAlias == "" {
+		return keeper.metricBackend, nil
+	}
+	backend, ok := keeper.aliasMetricBackends[rule.MetricBackendAlias]
+	if !ok {
+		return nil, fmt.Errorf("metric backend alias %s is not configured", rule.MetricBackendAlias)
+	}
+	return backend, nil
+}
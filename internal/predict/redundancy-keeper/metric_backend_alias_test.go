@@ -0,0 +1,74 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("WithMetricBackendAliases", func() {
+	var rule *model.PredictRule
+	var primaryCalls, aliasCalls int
+	countingBackend := func(calls *int) service.MetricBackend {
+		return service.MetricBackendFunc(func(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+			*calls++
+			return lowRedundancyBackend{}.QueryRedundancy(ctx, serviceName, clusterName, metricName, mode, benchmark, begin, end, trimmedSecond)
+		})
+	}
+	start := func() *redundancy_keeper.ScheduleSummary {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(&inFlightScaler{}),
+			redundancy_keeper.WithMetricBackend(countingBackend(&primaryCalls)),
+			redundancy_keeper.WithMetricBackendAliases(map[string]service.MetricBackend{"team-a": countingBackend(&aliasCalls)}),
+			redundancy_keeper.WithRuleErrorStore(newMemoryRuleErrorStore()),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		return redundancy_keeper.Start(context.Background())
+	}
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:               4300,
+			ServiceName:      "gf.cudgx.alias",
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 20,
+			ExecuteRatio:     100,
+			Status:           consts.RuleStatusEnable,
+		}
+		primaryCalls, aliasCalls = 0, 0
+	})
+
+	ginkgo.It("queries the primary backend when metric_backend_alias is empty", func() {
+		summary := start()
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(primaryCalls).To(gomega.Equal(1))
+		gomega.Expect(aliasCalls).To(gomega.Equal(0))
+	})
+
+	ginkgo.It("queries the backend of metric_backend_alias", func() {
+		rule.MetricBackendAlias = "team-a"
+		summary := start()
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(primaryCalls).To(gomega.Equal(0))
+		gomega.Expect(aliasCalls).To(gomega.Equal(1))
+	})
+
+	ginkgo.It("fails the rule when metric_backend_alias is not configured", func() {
+		rule.MetricBackendAlias = "team-b"
+		summary := start()
+		gomega.Expect(summary.RulesErrored).To(gomega.Equal(1))
+		gomega.Expect(primaryCalls).To(gomega.Equal(0))
+		gomega.Expect(aliasCalls).To(gomega.Equal(0))
+	})
+})
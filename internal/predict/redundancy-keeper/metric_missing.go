@@ -0,0 +1,81 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"go.uber.org/zap"
+)
+
+//MetricMissingNotifier 规则的 metric_missing_action 为 alert_only 且没有指标数据时发送通知
+type MetricMissingNotifier interface {
+	NotifyMetricMissing(ctx context.Context, e *event.MetricMissingEvent) error
+}
+
+//WithMetricMissingNotifier 增加一个没有指标数据通知的接收方，可以多次指定
+func WithMetricMissingNotifier(notifier MetricMissingNotifier) Option {
+	return func(keeper *ScheduleXRedundancyKeeper) {
+		if notifier != nil {
+			keeper.metricMissingNotifiers = append(keeper.metricMissingNotifiers, notifier)
+		}
+	}
+}
+
+//handleMetricMissing 回查窗口内没有指标数据时按 metric_missing_action 处理；返回 false 时按 skip 处理，与没有足够采集点相同
+func (keeper *ScheduleXRedundancyKeeper) handleMetricMissing(ctx context.Context, plugins []Plugin, rule *model.PredictRule, currentCount int, now time.Time, trace *RuleTrace) (bool, error) {
+	switch rule.MetricMissingAction {
+	case consts.MetricMissingActionScaleToMin:
+		return true, keeper.scaleToCount(ctx, plugins, rule, rule.MinInstanceCount, currentCount, now, trace)
+	case consts.MetricMissingActionScaleToMax:
+		return true, keeper.scaleToCount(ctx, plugins, rule, rule.MaxInstanceCount, currentCount, now, trace)
+	case consts.MetricMissingActionAlertOnly:
+		if keeper.alertMetricMissing(ctx, rule, currentCount, now) {
+			trace.finish(TraceOutcomeSkipped, "no metric data, alert sent")
+		} else {
+			trace.finish(TraceOutcomeSkipped, "no metric data, alert suppressed within alert_cooldown_minutes")
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+//scaleToCount 没有指标数据时把实例数调整到 target，仍受 max_expand_percent/max_shrink_percent、插件和人工确认的限制
+func (keeper *ScheduleXRedundancyKeeper) scaleToCount(ctx context.Context, plugins []Plugin, rule *model.PredictRule, target, currentCount int, now time.Time, trace *RuleTrace) error {
+	trace.step("no metric data, metric_missing_action %s changes instance count %d -> %d", rule.MetricMissingAction, currentCount, target)
+	if target == currentCount {
+		trace.finish(TraceOutcomeSkipped, "no metric data, already at %d instances", currentCount)
+		return nil
+	}
+	keeper.loggerFor(ctx).Warn("no metric data, scale by metric_missing_action", zap.String("service", rule.ServiceName), zap.String("cluster", rule.ClusterName),
+		zap.String("metric_missing_action", rule.MetricMissingAction), zap.Int("from", currentCount), zap.Int("to", target))
+	// 没有冗余度，扩缩容事件中的冗余度记录为0
+	return keeper.scale(ctx, plugins, rule, target-currentCount, currentCount, 0, now, trace)
+}
+
+//alertMetricMissing 打印告警日志并发送通知，每条规则每个 alert_cooldown_minutes 最多告警一次，已告警时返回 true
+func (keeper *ScheduleXRedundancyKeeper) alertMetricMissing(ctx context.Context, rule *model.PredictRule, currentCount int, now time.Time) bool {
+	key := consts.MetricMissingActionAlertOnly + "/" + strconv.FormatInt(rule.Id, 10) + "/" + rule.ClusterName
+	if !keeper.alerts.tryAlert(key, now, alertCooldown(rule)) {
+		return false
+	}
+	keeper.loggerFor(ctx).Warn("no metric data", zap.String("service", rule.ServiceName), zap.String("cluster", rule.ClusterName),
+		zap.String("metric", rule.MetricName), zap.Int("instance_count", currentCount))
+	e := &event.MetricMissingEvent{
+		RuleId:        rule.Id,
+		ServiceName:   rule.ServiceName,
+		ClusterName:   rule.ClusterName,
+		MetricName:    rule.MetricName,
+		InstanceCount: currentCount,
+		Timestamp:     now.Unix(),
+	}
+	for _, notifier := range keeper.metricMissingNotifiers {
+		if err := notifier.NotifyMetricMissing(ctx, e); err != nil {
+			keeper.loggerFor(ctx).Error("notify metric missing failed", zap.Int64("rule_id", rule.Id), zap.Error(err))
+		}
+	}
+	return true
+}
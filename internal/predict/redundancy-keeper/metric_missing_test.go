@@ -0,0 +1,116 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"sync"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+type recordingMetricMissingNotifier struct {
+	lock   sync.Mutex
+	events []*event.MetricMissingEvent
+}
+
+func (notifier *recordingMetricMissingNotifier) NotifyMetricMissing(ctx context.Context, e *event.MetricMissingEvent) error {
+	notifier.lock.Lock()
+	defer notifier.lock.Unlock()
+	notifier.events = append(notifier.events, e)
+	return nil
+}
+
+var _ = ginkgo.Describe("MetricMissingAction", func() {
+	var rule *model.PredictRule
+	var scaler *multiClusterScaler
+	var notifier *recordingMetricMissingNotifier
+	var clusters []*service.ClusterRedundancySeries
+
+	start := func() *redundancy_keeper.ScheduleSummary {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricBackend(service.MetricBackendFunc(func(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+				return &service.RedundancySeries{ServiceName: serviceName, MetricName: metricName, Clusters: clusters}, nil
+			})),
+			redundancy_keeper.WithMetricMissingNotifier(notifier),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		return redundancy_keeper.Start(context.Background())
+	}
+	reason := func() string {
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		return explain.Reason
+	}
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:               4320,
+			ServiceName:      "gf.cudgx.missing",
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 2,
+			MaxInstanceCount: 20,
+			ExecuteRatio:     100,
+			Status:           consts.RuleStatusEnable,
+		}
+		scaler = &multiClusterScaler{expanded: map[string]int{}, shrunk: map[string]int{}}
+		notifier = &recordingMetricMissingNotifier{}
+		clusters = []*service.ClusterRedundancySeries{{ClusterName: "default"}}
+	})
+
+	ginkgo.It("skips the round by default", func() {
+		summary := start()
+		gomega.Expect(summary.RulesEvaluated).To(gomega.Equal(1))
+		gomega.Expect(reason()).To(gomega.Equal("skipped: insufficient samples (0 of 1 required)"))
+		gomega.Expect(scaler.expanded).To(gomega.BeEmpty())
+		gomega.Expect(scaler.shrunk).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("expands to max_instance_count with scale_to_max", func() {
+		rule.MetricMissingAction = consts.MetricMissingActionScaleToMax
+		summary := start()
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(scaler.expanded).To(gomega.Equal(map[string]int{"default": 10}))
+	})
+
+	ginkgo.It("shrinks to min_instance_count with scale_to_min when the cluster is not returned", func() {
+		rule.MetricMissingAction = consts.MetricMissingActionScaleToMin
+		clusters = nil
+		summary := start()
+		gomega.Expect(summary.RulesScaledDown).To(gomega.Equal(1))
+		gomega.Expect(scaler.shrunk).To(gomega.Equal(map[string]int{"default": 8}))
+	})
+
+	ginkgo.It("only sends an alert with alert_only", func() {
+		rule.MetricMissingAction = consts.MetricMissingActionAlertOnly
+		start()
+		gomega.Expect(reason()).To(gomega.Equal("skipped: no metric data, alert sent"))
+		gomega.Expect(notifier.events).To(gomega.HaveLen(1))
+		gomega.Expect(notifier.events[0].RuleId).To(gomega.Equal(rule.Id))
+		gomega.Expect(notifier.events[0].InstanceCount).To(gomega.Equal(10))
+		gomega.Expect(scaler.expanded).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("does not apply the action when samples are returned", func() {
+		rule.MetricMissingAction = consts.MetricMissingActionScaleToMax
+		clusters = []*service.ClusterRedundancySeries{{ClusterName: "default", Timestamps: []int64{1}, Values: []float64{2}}}
+		start()
+		gomega.Expect(reason()).To(gomega.Equal("skipped: redundancy=2.00 within min=1.50 and max=2.50"))
+		gomega.Expect(scaler.expanded).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("rejects an unknown metric_missing_action", func() {
+		rule.MetricMissingAction = "scale_to_zero"
+		gomega.Expect(rule.Validate()).To(gomega.MatchError(gomega.ContainSubstring("metric_missing_action")))
+	})
+})
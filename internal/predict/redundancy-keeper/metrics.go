@@ -0,0 +1,133 @@
+package redundancy_keeper
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//MetricsBundle redundancy keeper 的所有 Prometheus 指标
+type MetricsBundle struct {
+	//ScheduleDuration 当前生效的调度间隔
+	ScheduleDuration prometheus.Gauge
+	//MetricQueryTimeouts 冗余度查询超时的次数
+	MetricQueryTimeouts *prometheus.CounterVec
+	//InstanceCountValidationFailures 实例数不可信而跳过的次数
+	InstanceCountValidationFailures *prometheus.CounterVec
+	//OutliersRemoved 计算冗余度前剔除的异常值数量
+	OutliersRemoved *prometheus.CounterVec
+	//MetricBackendFallbacks 主指标后端失败后使用备用后端的次数
+	MetricBackendFallbacks *prometheus.CounterVec
+	//HighRedundancyAlerts 冗余度过高告警的次数
+	HighRedundancyAlerts *prometheus.CounterVec
+	//CostDelta 扩缩容累计带来的每小时成本变化
+	CostDelta *prometheus.GaugeVec
+	//PrunedEvents 超过保留时间被删除的扩缩容事件数
+	PrunedEvents prometheus.Counter
+	//PartialExpands schedulx 容量不足、减少实例数后扩容成功的次数
+	PartialExpands *prometheus.CounterVec
+	//ShrinkVerificationFailures 缩容成功后实例数没有减少的次数
+	ShrinkVerificationFailures *prometheus.CounterVec
+	//ExpandQuotaConstrained 云厂商剩余配额不足、减少扩容数或跳过扩容的次数
+	ExpandQuotaConstrained *prometheus.CounterVec
+	//RecoveryModeEntries 服务不可用、进入恢复模式的次数
+	RecoveryModeEntries *prometheus.CounterVec
+}
+
+//defaultMetrics 注册到 prometheus.DefaultRegisterer，没有通过 WithMetricsBundle 指定时 keeper 使用
+var defaultMetrics = func() *MetricsBundle {
+	bundle, err := NewMetricsBundle(prometheus.DefaultRegisterer)
+	if err != nil {
+		panic(err)
+	}
+	return bundle
+}()
+
+//NewMetricsBundle 创建 keeper 的所有指标并注册到 reg，reg 为空时不注册；
+//测试中使用独立的 prometheus.NewRegistry 可以避免重复注册
+func NewMetricsBundle(reg prometheus.Registerer) (*MetricsBundle, error) {
+	bundle := &MetricsBundle{
+		ScheduleDuration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "cudgx_schedule_duration_seconds",
+			Help: "Current effective schedule duration of the redundancy keeper.",
+		}),
+		MetricQueryTimeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cudgx_metric_query_timeouts_total",
+			Help: "Number of redundancy queries that exceeded the metric query timeout.",
+		}, []string{"service", "cluster"}),
+		InstanceCountValidationFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cudgx_instance_count_validation_failures_total",
+			Help: "Number of scheduling rounds skipped because the queried instance count looked invalid.",
+		}, []string{"service", "cluster"}),
+		OutliersRemoved: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cudgx_outliers_removed_total",
+			Help: "Number of metric samples removed as outliers before the redundancy is calculated.",
+		}, []string{"method", "service", "cluster"}),
+		MetricBackendFallbacks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cudgx_metric_backend_fallback_total",
+			Help: "Number of redundancy queries retried on the fallback metric backend after the primary backend failed.",
+		}, []string{"service", "cluster"}),
+		HighRedundancyAlerts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cudgx_high_redundancy_alerts_total",
+			Help: "Number of alerts sent because the redundancy exceeded alert_on_high_redundancy",
+		}, []string{"service", "cluster"}),
+		CostDelta: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cudgx_estimated_cost_delta_per_hour",
+			Help: "Accumulated estimated hourly cost change caused by scaling actions since process start.",
+		}, []string{"service"}),
+		PrunedEvents: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "cudgx_pruned_events_total",
+			Help: "Number of scaling events deleted because they are older than metrics_retention_days.",
+		}),
+		PartialExpands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cudgx_partial_expand_total",
+			Help: "Number of expansions that succeeded with a reduced count after schedulx reported capacity unavailable.",
+		}, []string{"service", "cluster"}),
+		ShrinkVerificationFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cudgx_shrink_verification_failed_total",
+			Help: "Number of shrinks after which the instance count decreased by less than half of the requested count.",
+		}, []string{"service", "cluster"}),
+		ExpandQuotaConstrained: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cudgx_expand_quota_constrained_total",
+			Help: "Number of expansions reduced or skipped because the remaining cloud quota was less than the requested count.",
+		}, []string{"service", "cluster"}),
+		RecoveryModeEntries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cudgx_recovery_mode_entries_total",
+			Help: "Number of times a service entered recovery mode because its metric per instance dropped below recovery_threshold.",
+		}, []string{"service", "cluster"}),
+	}
+	if reg == nil {
+		return bundle, nil
+	}
+	for _, collector := range bundle.Collectors() {
+		if err := reg.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return bundle, nil
+}
+
+//Collectors bundle 中的所有指标
+func (bundle *MetricsBundle) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		bundle.ScheduleDuration,
+		bundle.MetricQueryTimeouts,
+		bundle.InstanceCountValidationFailures,
+		bundle.OutliersRemoved,
+		bundle.MetricBackendFallbacks,
+		bundle.HighRedundancyAlerts,
+		bundle.CostDelta,
+		bundle.PrunedEvents,
+		bundle.PartialExpands,
+		bundle.ShrinkVerificationFailures,
+		bundle.ExpandQuotaConstrained,
+		bundle.RecoveryModeEntries,
+	}
+}
+
+//WithMetricsBundle 指定 keeper 使用的指标，为空时使用注册到 prometheus.DefaultRegisterer 的指标
+func WithMetricsBundle(bundle *MetricsBundle) Option {
+	return func(keeper *ScheduleXRedundancyKeeper) {
+		if bundle != nil {
+			keeper.metrics = bundle
+		}
+	}
+}
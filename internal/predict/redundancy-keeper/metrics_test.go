@@ -0,0 +1,106 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/types"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+//blockingBackend 查询一直阻塞到 ctx 结束
+type blockingBackend struct{}
+
+func (blockingBackend) QueryRedundancy(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingBackend) QueryRawMetric(ctx context.Context, serviceName, clusterName, metricName string, start, end int64, step int) ([]float64, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+var _ = ginkgo.Describe("MetricsBundle", func() {
+	ginkgo.It("registers all collectors on the given registry once", func() {
+		registry := prometheus.NewRegistry()
+		bundle, err := redundancy_keeper.NewMetricsBundle(registry)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(bundle.Collectors()).To(gomega.HaveLen(12))
+
+		_, err = redundancy_keeper.NewMetricsBundle(registry)
+		gomega.Expect(err).NotTo(gomega.BeNil())
+	})
+
+	ginkgo.It("reports the metrics of scheduleRule to the keeper's bundle", func() {
+		bundle, err := redundancy_keeper.NewMetricsBundle(prometheus.NewRegistry())
+		gomega.Expect(err).To(gomega.BeNil())
+		rule := &model.PredictRule{
+			Id:               2200,
+			ServiceName:      "gf.cudgx.metrics",
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 2,
+			ExecuteRatio:     100,
+			Status:           consts.RuleStatusEnable,
+		}
+		// 实例数10超过 max_instance_count 的两倍
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RunDuration: types.Duration{Duration: time.Minute}, RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(&inFlightScaler{}),
+			redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+			redundancy_keeper.WithMetricsBundle(bundle),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		redundancy_keeper.Start(context.Background())
+		gomega.Expect(testutil.ToFloat64(bundle.InstanceCountValidationFailures.WithLabelValues("gf.cudgx.metrics", "default"))).To(gomega.Equal(1.0))
+		gomega.Expect(testutil.ToFloat64(bundle.ScheduleDuration)).To(gomega.Equal(60.0))
+	})
+
+	ginkgo.It("skips the rule and counts the timeout when the metric query blocks past metric_query_timeout", func() {
+		bundle, err := redundancy_keeper.NewMetricsBundle(prometheus.NewRegistry())
+		gomega.Expect(err).To(gomega.BeNil())
+		rule := &model.PredictRule{
+			Id:               2201,
+			ServiceName:      "gf.cudgx.slow",
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 50,
+			ExecuteRatio:     100,
+			Status:           consts.RuleStatusEnable,
+		}
+		scaler := &inFlightScaler{}
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true,
+			MetricQueryTimeout: types.Duration{Duration: 50 * time.Millisecond}},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricBackend(blockingBackend{}),
+			redundancy_keeper.WithMetricsBundle(bundle),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		summary := redundancy_keeper.Start(context.Background())
+		gomega.Expect(summary.RulesEvaluated).To(gomega.Equal(1))
+		gomega.Expect(summary.RulesErrored).To(gomega.Equal(0))
+		gomega.Expect(summary.RulesScaledUp + summary.RulesScaledDown).To(gomega.Equal(0))
+		gomega.Expect(scaler.expanded).To(gomega.Equal(0))
+		gomega.Expect(testutil.ToFloat64(bundle.MetricQueryTimeouts.WithLabelValues("gf.cudgx.slow", "default"))).To(gomega.Equal(1.0))
+
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(explain.Reason).To(gomega.Equal("skipped: metric query timeout after 50ms"))
+	})
+})
@@ -0,0 +1,225 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/stats"
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"go.uber.org/zap"
+)
+
+//clusterInstanceCounter 能按集群查询服务实例数的 Scaler，multi_cluster_mode 的规则需要
+type clusterInstanceCounter interface {
+	GetServiceInstanceCountByCluster(ctx context.Context, serviceName string) (map[string]int, error)
+}
+
+func (schedulxScaler) GetServiceInstanceCountByCluster(ctx context.Context, serviceName string) (map[string]int, error) {
+	return clients.GetServiceInstanceCountByCluster(ctx, serviceName)
+}
+
+//clusterRedundancy 资源池中一个集群的实例数和冗余度
+type clusterRedundancy struct {
+	clusterName   string
+	instanceCount int
+	redundancy    float64
+}
+
+//clusterRule 返回只作用于 clusterName 的规则副本
+func clusterRule(rule *model.PredictRule, clusterName string) *model.PredictRule {
+	copied := *rule
+	copied.ClusterName = clusterName
+	return &copied
+}
+
+//scheduleMultiClusterRule 把服务的所有集群作为一个资源池，按实例数加权平均各集群的冗余度后判断是否扩缩容，
+//扩容冗余度最低的集群，缩容冗余度最高的集群；min_instance_count 和 max_instance_count 按所有集群的实例总数检查
+func (keeper *ScheduleXRedundancyKeeper) scheduleMultiClusterRule(ctx, queryCtx context.Context, plugins []Plugin, rule *model.PredictRule, begin, end int64, now time.Time, trace *RuleTrace) error {
+	counts, currentCount, err := keeper.clusterInstanceCounts(ctx, rule)
+	if err != nil {
+		return err
+	}
+	clusterNames := make([]string, 0, len(counts))
+	for clusterName := range counts {
+		clusterNames = append(clusterNames, clusterName)
+	}
+	sort.Strings(clusterNames)
+	trace.step("current instance count %d in %d clusters", currentCount, len(clusterNames))
+	// 资源池按实例总数检查，单个集群没有实例是正常的
+	if err := ValidateInstanceCount(currentCount, rule); err != nil {
+		keeper.metrics.InstanceCountValidationFailures.WithLabelValues(rule.ServiceName, rule.ClusterName).Inc()
+		keeper.loggerFor(ctx).Warn("instance count is invalid, skip this round", zap.String("service", rule.ServiceName), zap.Int("clusters", len(clusterNames)), zap.Error(err))
+		trace.finish(TraceOutcomeSkipped, "%s", err)
+		return nil
+	}
+	if err := keeper.onInstanceCounted(ctx, plugins, rule, currentCount); err != nil {
+		return err
+	}
+
+	var pool []clusterRedundancy
+	for _, clusterName := range clusterNames {
+		// 没有实例的集群没有指标，加权时权重也为0
+		if counts[clusterName] == 0 {
+			continue
+		}
+		redundancy, skipReason, err := keeper.clusterRedundancy(ctx, queryCtx, plugins, clusterRule(rule, clusterName), begin, end, now)
+		if err != nil {
+			return err
+		}
+		// 任一集群的冗余度不可信时加权结果也不可信，跳过本轮
+		if skipReason != "" {
+			trace.finish(TraceOutcomeSkipped, "cluster %s %s", clusterName, skipReason)
+			return nil
+		}
+		trace.step("cluster %s redundancy %.2f with %d instances", clusterName, redundancy, counts[clusterName])
+		pool = append(pool, clusterRedundancy{clusterName: clusterName, instanceCount: counts[clusterName], redundancy: redundancy})
+	}
+	if len(pool) == 0 {
+		trace.finish(TraceOutcomeSkipped, "no cluster has running instances")
+		return nil
+	}
+
+	var weighted, totalQPS float64
+	for _, cluster := range pool {
+		weighted += cluster.redundancy * float64(cluster.instanceCount)
+		totalQPS += estimateTotalQPS(float64(rule.BenchmarkQps), cluster.instanceCount, cluster.redundancy)
+	}
+	redundancy := weighted / float64(currentCount)
+	trace.step("weighted redundancy %.2f", redundancy)
+	trace.Redundancy = &redundancy
+
+	if rule.MinQPSThreshold > 0 && totalQPS < rule.MinQPSThreshold {
+		trace.finish(TraceOutcomeSkipped, "total qps %.2f below min_qps_threshold %.2f", totalQPS, rule.MinQPSThreshold)
+		return nil
+	}
+	keeper.checkHighRedundancy(ctx, rule, redundancy, currentCount, now, trace)
+
+	withinRange := withinRedundancyRange(rule, redundancy)
+	if err := keeper.onThresholdChecked(ctx, plugins, rule, redundancy, withinRange); err != nil {
+		return err
+	}
+	decisionRedundancy, skipReason := keeper.applyScalePolicy(rule, redundancy, withinRange, trace)
+	if withinRange {
+		trace.finish(TraceOutcomeSkipped, "redundancy=%.2f within min=%.2f and max=%.2f", redundancy, float64(rule.MinRedundancy)/100, float64(rule.MaxRedundancy)/100)
+		return nil
+	}
+	if skipReason != "" {
+		trace.finish(TraceOutcomeSkipped, "%s", skipReason)
+		return nil
+	}
+	redundancy = decisionRedundancy
+
+	expectCount, countToChange := expectedInstanceChange(rule, redundancy, currentCount)
+	trace.step("expected instance count %d, change %d with execute_ratio %d%%", expectCount, countToChange, rule.ExecuteRatio)
+	if countToChange == 0 {
+		trace.finish(TraceOutcomeSkipped, "redundancy=%.2f needs no instance change", redundancy)
+		return nil
+	}
+
+	target := pool[0]
+	for _, cluster := range pool[1:] {
+		if (countToChange > 0 && cluster.redundancy < target.redundancy) || (countToChange < 0 && cluster.redundancy > target.redundancy) {
+			target = cluster
+		}
+	}
+	trace.ClusterName = target.clusterName
+	trace.step("scale cluster %s with redundancy %.2f", target.clusterName, target.redundancy)
+	targetRule := clusterRule(rule, target.clusterName)
+
+	canSchedule, err := keeper.scalerFor(targetRule).CanServiceSchedule(ctx, targetRule.ServiceName, targetRule.ClusterName)
+	if err != nil {
+		return fmt.Errorf("query service schedule failed , %w", err)
+	}
+	if !canSchedule {
+		trace.finish(TraceOutcomeSkipped, "service is being scheduled by schedulx")
+		return nil
+	}
+	if keeper.requireWarmCache() {
+		warm, err := keeper.isServiceCacheWarm(ctx, targetRule)
+		if err != nil {
+			return err
+		}
+		if !warm {
+			trace.finish(TraceOutcomeSkipped, "GetServiceByIp cache is cold")
+			return nil
+		}
+	}
+	// scale 按目标集群的实例数检查和记录，资源池的实例数上下限换算为目标集群的上下限，单个集群最多缩容到0台
+	countToChange = max(countToChange, -target.instanceCount)
+	targetRule.MinInstanceCount = max(target.instanceCount-(currentCount-rule.MinInstanceCount), 0)
+	targetRule.MaxInstanceCount = target.instanceCount + rule.MaxInstanceCount - currentCount
+	return keeper.scale(ctx, plugins, targetRule, countToChange, target.instanceCount, redundancy, now, trace)
+}
+
+//clusterRedundancy 查询一个集群的冗余度，按规则配置聚合、去除异常值后取中位数或预测值；数据不足以判断时返回跳过原因
+func (keeper *ScheduleXRedundancyKeeper) clusterRedundancy(ctx, queryCtx context.Context, plugins []Plugin, rule *model.PredictRule, begin, end int64, now time.Time) (float64, string, error) {
+	series, err := keeper.queryRedundancy(queryCtx, rule, begin, end)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(queryCtx.Err(), context.DeadlineExceeded) {
+			keeper.metrics.MetricQueryTimeouts.WithLabelValues(rule.ServiceName, rule.ClusterName).Inc()
+			keeper.loggerFor(ctx).Warn("query redundancy timeout, skip this round", zap.String("service", rule.ServiceName),
+				zap.String("cluster", rule.ClusterName), zap.Duration("timeout", keeper.metricQueryTimeout()))
+			return 0, fmt.Sprintf("metric query timeout after %s", keeper.metricQueryTimeout()), nil
+		}
+		if errors.Is(err, errMetricBackendsUnavailable) {
+			keeper.loggerFor(ctx).Warn("all metric backends failed, skip this round", zap.String("service", rule.ServiceName),
+				zap.String("cluster", rule.ClusterName), zap.Error(err))
+			return 0, errMetricBackendsUnavailable.Error(), nil
+		}
+		return 0, "", err
+	}
+	if err := keeper.onMetricQueried(ctx, plugins, rule, series); err != nil {
+		return 0, "", err
+	}
+	_, _, minimalSampleCount := keeper.sampleWindow()
+	for _, cluster := range series.Clusters {
+		if cluster.ClusterName != rule.ClusterName {
+			continue
+		}
+		if window := int64(rule.MetricAggregationWindowSeconds); window > 0 && window < end-begin {
+			cluster.Timestamps, cluster.Values = middleWindow(cluster.Timestamps, cluster.Values, begin, end, window)
+		}
+		if len(cluster.Values) < minimalSampleCount {
+			return 0, fmt.Sprintf("insufficient samples (%d of %d required)", len(cluster.Values), minimalSampleCount), nil
+		}
+		if rateOfChange, tooHigh := rateOfChangeTooHigh(rule, cluster.Values); tooHigh {
+			return 0, fmt.Sprintf("rate_of_change_too_high, redundancy changed %.2f%% exceeds max_rate_of_change_percent %.2f", rateOfChange, rule.MaxRateOfChangePercent), nil
+		}
+		if rule.ForecastHorizonSeconds > 0 {
+			if forecast, ok := forecastRedundancy(cluster.Timestamps, cluster.Values, now.Unix()+int64(rule.ForecastHorizonSeconds)); ok {
+				return forecast, "", nil
+			}
+		}
+		values := append([]float64(nil), cluster.Values...)
+		slices.Sort(values)
+		outlierRemovalMethod := keeper.outlierRemovalMethod()
+		values = removeOutliers(outlierRemovalMethod, values)
+		if len(values) == 0 {
+			return 0, fmt.Sprintf("all samples removed as outliers (%s)", outlierRemovalMethod), nil
+		}
+		return stats.Median(values), "", nil
+	}
+	return 0, fmt.Sprintf("insufficient samples (0 of %d required)", minimalSampleCount), nil
+}
+
+//clusterInstanceCounts 服务各集群的实例数和所有集群的实例总数
+func (keeper *ScheduleXRedundancyKeeper) clusterInstanceCounts(ctx context.Context, rule *model.PredictRule) (map[string]int, int, error) {
+	counter, ok := keeper.scalerFor(rule).(clusterInstanceCounter)
+	if !ok {
+		return nil, 0, errors.New("scaler does not support multi cluster mode")
+	}
+	counts, err := counter.GetServiceInstanceCountByCluster(ctx, rule.ServiceName)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query service instance count by cluster failed , %w", err)
+	}
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	return counts, total, nil
+}
@@ -0,0 +1,156 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//multiClusterScaler 按集群返回实例数，记录每次扩缩容的集群和数量
+type multiClusterScaler struct {
+	inFlightScaler
+	counts   map[string]int
+	expanded map[string]int
+	shrunk   map[string]int
+}
+
+func (scaler *multiClusterScaler) GetServiceInstanceCountByCluster(ctx context.Context, serviceName string) (map[string]int, error) {
+	return scaler.counts, nil
+}
+
+func (scaler *multiClusterScaler) ExpandService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	scaler.expanded[clusterName] += count
+	return nil
+}
+
+func (scaler *multiClusterScaler) ShrinkService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	scaler.shrunk[clusterName] += count
+	return nil
+}
+
+//clusterCountScaler 按集群返回 GetServiceInstanceCount 的实例数，缩容后实例数不变
+type clusterCountScaler struct {
+	*multiClusterScaler
+}
+
+func (scaler clusterCountScaler) GetServiceInstanceCount(ctx context.Context, serviceName, clusterName string) (int, error) {
+	return scaler.counts[clusterName], nil
+}
+
+var _ = ginkgo.Describe("MultiClusterMode", func() {
+	var rule *model.PredictRule
+	var scaler *multiClusterScaler
+	var redundancies map[string]float64
+	var queried []string
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:               1200,
+			ServiceName:      "pool",
+			ClusterName:      "prod-us",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 60,
+			ExecuteRatio:     100,
+			MultiClusterMode: true,
+			Status:           consts.RuleStatusEnable,
+		}
+		scaler = &multiClusterScaler{
+			counts:   map[string]int{"prod-us": 10, "prod-eu": 30, "prod-empty": 0},
+			expanded: map[string]int{},
+			shrunk:   map[string]int{},
+		}
+		queried = nil
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricBackend(service.MetricBackendFunc(func(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+				queried = append(queried, clusterName)
+				return &service.RedundancySeries{
+					ServiceName: serviceName,
+					MetricName:  metricName,
+					Clusters:    []*service.ClusterRedundancySeries{{ClusterName: clusterName, Timestamps: []int64{begin}, Values: []float64{redundancies[clusterName]}}},
+				}, nil
+			})),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+	})
+
+	ginkgo.It("weights the redundancy of each cluster by its instance count", func() {
+		// prod-us 单独计算低于 min_redundancy，加权后 (1.0*10+2.0*30)/40=1.75 在范围内
+		redundancies = map[string]float64{"prod-us": 1.0, "prod-eu": 2.0}
+		summary := redundancy_keeper.Start(context.Background())
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(0))
+		gomega.Expect(queried).To(gomega.Equal([]string{"prod-eu", "prod-us"}))
+
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(explain.Reason).To(gomega.Equal("skipped: redundancy=1.75 within min=1.50 and max=2.50"))
+	})
+
+	ginkgo.It("expands the cluster with the lowest redundancy up to max_instance_count of the pool", func() {
+		redundancies = map[string]float64{"prod-us": 0.5, "prod-eu": 1.0}
+		summary := redundancy_keeper.Start(context.Background())
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(scaler.expanded).To(gomega.Equal(map[string]int{"prod-us": 20}))
+	})
+
+	ginkgo.It("shrinks the cluster with the highest redundancy", func() {
+		redundancies = map[string]float64{"prod-us": 3.0, "prod-eu": 4.0}
+		summary := redundancy_keeper.Start(context.Background())
+		gomega.Expect(summary.RulesScaledDown).To(gomega.Equal(1))
+		gomega.Expect(scaler.shrunk).To(gomega.Equal(map[string]int{"prod-eu": 19}))
+	})
+
+	ginkgo.It("shrinks at most the instances of the target cluster and verifies its own count", func() {
+		// 资源池需要缩容约26台，冗余度最高的 prod-eu 只有5台
+		scaler.counts = map[string]int{"prod-us": 30, "prod-eu": 5}
+		redundancies = map[string]float64{"prod-us": 4.0, "prod-eu": 10.0}
+		rule.VerifyShrinkAfterSeconds = 1
+		notifier := &recordingShrinkVerificationNotifier{}
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(clusterCountScaler{scaler}),
+			redundancy_keeper.WithShrinkVerificationNotifier(notifier),
+			redundancy_keeper.WithMetricBackend(service.MetricBackendFunc(func(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+				return &service.RedundancySeries{
+					ServiceName: serviceName,
+					MetricName:  metricName,
+					Clusters:    []*service.ClusterRedundancySeries{{ClusterName: clusterName, Timestamps: []int64{begin}, Values: []float64{redundancies[clusterName]}}},
+				}, nil
+			})),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		summary := redundancy_keeper.Start(context.Background())
+		gomega.Expect(summary.RulesScaledDown).To(gomega.Equal(1))
+		gomega.Expect(scaler.shrunk).To(gomega.Equal(map[string]int{"prod-eu": 5}))
+
+		// 缩容后 prod-eu 的实例数没有减少，校验按 prod-eu 缩容前的实例数比较
+		gomega.Eventually(notifier.received, 3*time.Second).Should(gomega.HaveLen(1))
+		e := notifier.received()[0]
+		gomega.Expect(e.ClusterName).To(gomega.Equal("prod-eu"))
+		gomega.Expect(e.InstanceCountBefore).To(gomega.Equal(5))
+		gomega.Expect(e.InstanceCountAfter).To(gomega.Equal(5))
+	})
+
+	ginkgo.It("skips the round when one cluster has insufficient samples", func() {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 2, RunOnce: true},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		summary := redundancy_keeper.Start(context.Background())
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(0))
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(explain.Reason).To(gomega.Equal("skipped: cluster prod-eu insufficient samples (1 of 2 required)"))
+	})
+})
@@ -0,0 +1,65 @@
+package redundancy_keeper
+
+import (
+	"math"
+
+	"github.com/galaxy-future/cudgx/common/stats"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+)
+
+//removeOutliers 根据method剔除已排序序列中的异常值，返回剔除后的序列
+func removeOutliers(method string, sorted []float64) []float64 {
+	switch method {
+	case consts.OutlierRemovalIQR:
+		return removeOutliersByIQR(sorted)
+	case consts.OutlierRemovalZScore:
+		return removeOutliersByZScore(sorted)
+	default:
+		return sorted
+	}
+}
+
+//removeOutliersByIQR 剔除 [Q1-1.5*IQR, Q3+1.5*IQR] 之外的值，四分位数在相邻值之间线性插值
+func removeOutliersByIQR(sorted []float64) []float64 {
+	if len(sorted) < 4 {
+		return sorted
+	}
+	q1 := stats.Quantile(sorted, 0.25)
+	q3 := stats.Quantile(sorted, 0.75)
+	iqr := q3 - q1
+	lower, upper := q1-1.5*iqr, q3+1.5*iqr
+	values := make([]float64, 0, len(sorted))
+	for _, value := range sorted {
+		if value >= lower && value <= upper {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+//removeOutliersByZScore 剔除 |z| > 3 的值
+func removeOutliersByZScore(sorted []float64) []float64 {
+	if len(sorted) < 2 {
+		return sorted
+	}
+	var sum float64
+	for _, value := range sorted {
+		sum += value
+	}
+	mean := sum / float64(len(sorted))
+	var variance float64
+	for _, value := range sorted {
+		variance += (value - mean) * (value - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(sorted)))
+	if stddev == 0 {
+		return sorted
+	}
+	values := make([]float64, 0, len(sorted))
+	for _, value := range sorted {
+		if math.Abs(value-mean)/stddev <= 3 {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,29 @@
+package redundancy_keeper_test
+
+import (
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("RemoveOutliers", func() {
+	// 十个1和一个100：均值10，标准差约28.5，100的 z 约为3.16
+	spiked := []float64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 100}
+
+	table.DescribeTable("removes values outside the bounds of the method",
+		func(method string, sorted []float64, expected []float64) {
+			gomega.Expect(redundancy_keeper.RemoveOutliers(method, sorted)).To(gomega.Equal(expected))
+		},
+		// Q1=1.2、Q3=1.6，上界为2.2
+		table.Entry("iqr drops a single spike", consts.OutlierRemovalIQR, []float64{1.0, 1.1, 1.2, 1.3, 1.4, 1.5, 1.6, 1.7, 20}, []float64{1.0, 1.1, 1.2, 1.3, 1.4, 1.5, 1.6, 1.7}),
+		// Q1=1.75、Q3=3.25，区间为[-0.5, 5.5]
+		table.Entry("iqr interpolates the quartiles and keeps values inside", consts.OutlierRemovalIQR, []float64{1, 2, 3, 4}, []float64{1, 2, 3, 4}),
+		table.Entry("iqr keeps fewer than 4 values", consts.OutlierRemovalIQR, []float64{1, 2, 100}, []float64{1, 2, 100}),
+		table.Entry("zscore drops a single spike", consts.OutlierRemovalZScore, spiked, spiked[:10]),
+		table.Entry("zscore keeps values with zero stddev", consts.OutlierRemovalZScore, []float64{2, 2, 2, 2}, []float64{2, 2, 2, 2}),
+		table.Entry("zscore keeps a single value", consts.OutlierRemovalZScore, []float64{5}, []float64{5}),
+		table.Entry("none keeps every value", consts.OutlierRemovalNone, spiked, spiked),
+	)
+})
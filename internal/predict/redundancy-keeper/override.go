@@ -0,0 +1,144 @@
+package redundancy_keeper
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"go.uber.org/zap"
+)
+
+//RuleOverride 规则的临时覆盖值，仅保存在内存中，进程重启后失效；为空的字段沿用数据库中的值
+type RuleOverride struct {
+	BenchmarkQps     *int `json:"benchmark_qps,omitempty"`
+	MinRedundancy    *int `json:"min_redundancy,omitempty"`
+	MaxRedundancy    *int `json:"max_redundancy,omitempty"`
+	MinInstanceCount *int `json:"min_instance_count,omitempty"`
+	MaxInstanceCount *int `json:"max_instance_count,omitempty"`
+	ExecuteRatio     *int `json:"execute_ratio,omitempty"`
+}
+
+//Apply 返回合并覆盖值后的规则副本
+func (override *RuleOverride) Apply(rule *model.PredictRule) *model.PredictRule {
+	merged := *rule
+	if override.BenchmarkQps != nil {
+		merged.BenchmarkQps = *override.BenchmarkQps
+	}
+	if override.MinRedundancy != nil {
+		merged.MinRedundancy = *override.MinRedundancy
+	}
+	if override.MaxRedundancy != nil {
+		merged.MaxRedundancy = *override.MaxRedundancy
+	}
+	if override.MinInstanceCount != nil {
+		merged.MinInstanceCount = *override.MinInstanceCount
+	}
+	if override.MaxInstanceCount != nil {
+		merged.MaxInstanceCount = *override.MaxInstanceCount
+	}
+	if override.ExecuteRatio != nil {
+		merged.ExecuteRatio = *override.ExecuteRatio
+	}
+	return &merged
+}
+
+func (override *RuleOverride) validate() error {
+	fields := map[string]*int{
+		"benchmark_qps":      override.BenchmarkQps,
+		"min_redundancy":     override.MinRedundancy,
+		"max_redundancy":     override.MaxRedundancy,
+		"min_instance_count": override.MinInstanceCount,
+		"max_instance_count": override.MaxInstanceCount,
+		"execute_ratio":      override.ExecuteRatio,
+	}
+	empty := true
+	for name, value := range fields {
+		if value == nil {
+			continue
+		}
+		empty = false
+		if *value < 0 {
+			return fmt.Errorf("%s 不能小于0", name)
+		}
+	}
+	if empty {
+		return errors.New("至少需要覆盖一个字段")
+	}
+	return nil
+}
+
+//validateMerged 校验覆盖值合并到 rule 之后的上下限，只覆盖下限或上限时也不能与数据库中的另一端冲突
+func (override *RuleOverride) validateMerged(rule *model.PredictRule) error {
+	merged := override.Apply(rule)
+	if merged.MinInstanceCount > merged.MaxInstanceCount {
+		return fmt.Errorf("覆盖后 min_instance_count %d 不能大于 max_instance_count %d", merged.MinInstanceCount, merged.MaxInstanceCount)
+	}
+	if merged.MinRedundancy > merged.MaxRedundancy {
+		return fmt.Errorf("覆盖后 min_redundancy %d 不能大于 max_redundancy %d", merged.MinRedundancy, merged.MaxRedundancy)
+	}
+	return nil
+}
+
+//SetRuleOverride 设置规则的临时覆盖值，rule 为数据库中的规则，用于校验合并后的规则
+func (keeper *ScheduleXRedundancyKeeper) SetRuleOverride(rule *model.PredictRule, override RuleOverride) error {
+	if err := override.validate(); err != nil {
+		return err
+	}
+	if err := override.validateMerged(rule); err != nil {
+		return err
+	}
+	keeper.overrides.Store(rule.Id, &override)
+	keeper.logger.Info("set rule override", zap.Int64("rule_id", rule.Id), zap.Any("override", &override))
+	return nil
+}
+
+//ClearRuleOverride 清除规则的临时覆盖值
+func (keeper *ScheduleXRedundancyKeeper) ClearRuleOverride(ruleID int64) error {
+	if _, ok := keeper.overrides.LoadAndDelete(ruleID); !ok {
+		return fmt.Errorf("规则 %d 没有覆盖值", ruleID)
+	}
+	keeper.logger.Info("clear rule override", zap.Int64("rule_id", ruleID))
+	return nil
+}
+
+//GetRuleOverride 获取规则的临时覆盖值
+func (keeper *ScheduleXRedundancyKeeper) GetRuleOverride(ruleID int64) (*RuleOverride, bool) {
+	value, ok := keeper.overrides.Load(ruleID)
+	if !ok {
+		return nil, false
+	}
+	return value.(*RuleOverride), true
+}
+
+//applyRuleOverride 将覆盖值合并到数据库中的规则上
+func (keeper *ScheduleXRedundancyKeeper) applyRuleOverride(rule *model.PredictRule) *model.PredictRule {
+	override, ok := keeper.GetRuleOverride(rule.Id)
+	if !ok {
+		return rule
+	}
+	return override.Apply(rule)
+}
+
+//SetRuleOverride 设置规则的临时覆盖值，rule 为数据库中的规则
+func SetRuleOverride(rule *model.PredictRule, override RuleOverride) error {
+	if redundancyKeeper == nil {
+		return errors.New("redundancy keeper is not initialized")
+	}
+	return redundancyKeeper.SetRuleOverride(rule, override)
+}
+
+//ClearRuleOverride 清除规则的临时覆盖值
+func ClearRuleOverride(ruleID int64) error {
+	if redundancyKeeper == nil {
+		return errors.New("redundancy keeper is not initialized")
+	}
+	return redundancyKeeper.ClearRuleOverride(ruleID)
+}
+
+//GetRuleOverride 获取规则的临时覆盖值
+func GetRuleOverride(ruleID int64) (*RuleOverride, bool) {
+	if redundancyKeeper == nil {
+		return nil, false
+	}
+	return redundancyKeeper.GetRuleOverride(ruleID)
+}
@@ -0,0 +1,72 @@
+package redundancy_keeper_test
+
+import (
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/types"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+func intPtr(value int) *int {
+	return &value
+}
+
+var _ = ginkgo.Describe("RuleOverride", func() {
+	rule := &model.PredictRule{
+		Id:               7,
+		BenchmarkQps:     100,
+		MinRedundancy:    150,
+		MaxRedundancy:    250,
+		MinInstanceCount: 2,
+		MaxInstanceCount: 10,
+		ExecuteRatio:     50,
+	}
+
+	ginkgo.BeforeEach(func() {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RunDuration: types.Duration{Duration: time.Minute}, RuleConcurrency: 1, MinimalSampleCount: 1})).To(gomega.Succeed())
+	})
+
+	ginkgo.It("merges only the overridden fields", func() {
+		override := redundancy_keeper.RuleOverride{MinInstanceCount: intPtr(20), MaxInstanceCount: intPtr(40)}
+		merged := override.Apply(rule)
+		gomega.Expect(merged.MinInstanceCount).To(gomega.Equal(20))
+		gomega.Expect(merged.MaxInstanceCount).To(gomega.Equal(40))
+		gomega.Expect(merged.BenchmarkQps).To(gomega.Equal(100))
+		gomega.Expect(merged.ExecuteRatio).To(gomega.Equal(50))
+		gomega.Expect(rule.MinInstanceCount).To(gomega.Equal(2))
+	})
+
+	ginkgo.It("stores and clears overrides", func() {
+		err := redundancy_keeper.SetRuleOverride(rule, redundancy_keeper.RuleOverride{MaxInstanceCount: intPtr(30)})
+		gomega.Expect(err).To(gomega.BeNil())
+		override, ok := redundancy_keeper.GetRuleOverride(rule.Id)
+		gomega.Expect(ok).To(gomega.BeTrue())
+		gomega.Expect(*override.MaxInstanceCount).To(gomega.Equal(30))
+
+		gomega.Expect(redundancy_keeper.ClearRuleOverride(rule.Id)).To(gomega.BeNil())
+		_, ok = redundancy_keeper.GetRuleOverride(rule.Id)
+		gomega.Expect(ok).To(gomega.BeFalse())
+		gomega.Expect(redundancy_keeper.ClearRuleOverride(rule.Id)).NotTo(gomega.BeNil())
+	})
+
+	ginkgo.It("rejects invalid overrides", func() {
+		gomega.Expect(redundancy_keeper.SetRuleOverride(rule, redundancy_keeper.RuleOverride{})).NotTo(gomega.BeNil())
+		gomega.Expect(redundancy_keeper.SetRuleOverride(rule, redundancy_keeper.RuleOverride{MinInstanceCount: intPtr(-1)})).NotTo(gomega.BeNil())
+		gomega.Expect(redundancy_keeper.SetRuleOverride(rule, redundancy_keeper.RuleOverride{MinInstanceCount: intPtr(5), MaxInstanceCount: intPtr(4)})).NotTo(gomega.BeNil())
+	})
+
+	ginkgo.It("validates the override merged with the stored rule", func() {
+		err := redundancy_keeper.SetRuleOverride(rule, redundancy_keeper.RuleOverride{MinInstanceCount: intPtr(20)})
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("max_instance_count 10")))
+		err = redundancy_keeper.SetRuleOverride(rule, redundancy_keeper.RuleOverride{MaxRedundancy: intPtr(100)})
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("min_redundancy 150")))
+		_, ok := redundancy_keeper.GetRuleOverride(rule.Id)
+		gomega.Expect(ok).To(gomega.BeFalse())
+
+		gomega.Expect(redundancy_keeper.SetRuleOverride(rule, redundancy_keeper.RuleOverride{MinInstanceCount: intPtr(20), MaxInstanceCount: intPtr(40)})).To(gomega.BeNil())
+	})
+})
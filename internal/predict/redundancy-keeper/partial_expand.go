@@ -0,0 +1,38 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"go.uber.org/zap"
+)
+
+//expandReducingOnCapacity 扩容 count 个实例，schedulx 容量不足时把扩容数依次减半重试，
+//直到 consts.MinPartialExpand 仍失败时返回错误；返回实际扩容的实例数
+func (keeper *ScheduleXRedundancyKeeper) expandReducingOnCapacity(ctx context.Context, rule *model.PredictRule, count int, now time.Time, trace *RuleTrace) (int, error) {
+	serviceName := rule.ServiceName
+	clusterName := rule.ClusterName
+	requested := count
+	for {
+		// 扩容数包含在 idempotency key 中，减少后的请求不会被当作重复请求
+		err := keeper.scalerFor(rule).ExpandService(ctx, serviceName, clusterName, count, idempotencyKey(serviceName, clusterName, count, now.Unix()))
+		if err == nil {
+			if count < requested {
+				keeper.metrics.PartialExpands.WithLabelValues(serviceName, clusterName).Inc()
+			}
+			return count, nil
+		}
+		if !errors.Is(err, clients.ErrCapacityUnavailable) || count <= consts.MinPartialExpand {
+			return 0, err
+		}
+		reduced := max(count/2, consts.MinPartialExpand)
+		keeper.loggerFor(ctx).Warn("schedulx capacity unavailable, retry expand with reduced count", zap.String("service", serviceName),
+			zap.String("cluster", clusterName), zap.Int("requested", requested), zap.Int("count", count), zap.Int("reduced", reduced), zap.Error(err))
+		trace.step("capacity unavailable for %d instances, retry with %d", count, reduced)
+		count = reduced
+	}
+}
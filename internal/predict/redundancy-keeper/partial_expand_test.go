@@ -0,0 +1,98 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+//capacityScaler 扩容数超过 capacity 时返回 clients.ErrCapacityUnavailable，记录每次请求的扩容数
+type capacityScaler struct {
+	inFlightScaler
+	capacity int
+	err      error
+	requests []int
+}
+
+func (scaler *capacityScaler) ExpandService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	scaler.requests = append(scaler.requests, count)
+	if scaler.err != nil {
+		return scaler.err
+	}
+	if count > scaler.capacity {
+		return fmt.Errorf("%w , count:%d", clients.ErrCapacityUnavailable, count)
+	}
+	return nil
+}
+
+var _ = ginkgo.Describe("PartialExpand", func() {
+	var rule *model.PredictRule
+	var scaler *capacityScaler
+	var metrics *redundancy_keeper.MetricsBundle
+
+	start := func() *redundancy_keeper.ScheduleSummary {
+		var err error
+		metrics, err = redundancy_keeper.NewMetricsBundle(nil)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricsBundle(metrics),
+			redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+			redundancy_keeper.WithRuleErrorStore(newMemoryRuleErrorStore()),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		return redundancy_keeper.Start(context.Background())
+	}
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:               1400,
+			ServiceName:      "capacity",
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 100,
+			ExecuteRatio:     100,
+			Status:           consts.RuleStatusEnable,
+		}
+		scaler = &capacityScaler{capacity: 5}
+	})
+
+	ginkgo.It("halves the count until schedulx has capacity", func() {
+		summary := start()
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(scaler.requests).To(gomega.Equal([]int{30, 15, 7, 3}))
+		gomega.Expect(testutil.ToFloat64(metrics.PartialExpands.WithLabelValues("capacity", "default"))).To(gomega.Equal(1.0))
+
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(explain.Reason).To(gomega.Equal("scaled_up: redundancy=0.50 below min=1.50, added 3 of 30 instances because schedulx capacity is unavailable"))
+	})
+
+	ginkgo.It("fails after one instance is still unavailable", func() {
+		scaler.capacity = 0
+		summary := start()
+		gomega.Expect(summary.RulesErrored).To(gomega.Equal(1))
+		gomega.Expect(scaler.requests).To(gomega.Equal([]int{30, 15, 7, 3, 1}))
+		gomega.Expect(testutil.ToFloat64(metrics.PartialExpands.WithLabelValues("capacity", "default"))).To(gomega.Equal(0.0))
+	})
+
+	ginkgo.It("does not retry other expand failures", func() {
+		scaler.err = errors.New("internal error")
+		summary := start()
+		gomega.Expect(summary.RulesErrored).To(gomega.Equal(1))
+		gomega.Expect(scaler.requests).To(gomega.Equal([]int{30}))
+	})
+})
@@ -0,0 +1,119 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+)
+
+//ErrSkipScaling OnScaleDecided 返回该错误时不执行本次扩缩容
+var ErrSkipScaling = errors.New("skip scaling")
+
+//Plugin scheduleRule 的扩展，按需实现 MetricQueriedPlugin、InstanceCountedPlugin、
+//ThresholdCheckedPlugin、ScaleDecidedPlugin 中的方法；同一规则的插件按注册顺序依次调用
+type Plugin interface {
+	Name() string
+}
+
+//MetricQueriedPlugin 查询到冗余度指标后调用，可以修改 series
+type MetricQueriedPlugin interface {
+	OnMetricQueried(ctx context.Context, rule *model.PredictRule, series *service.RedundancySeries) error
+}
+
+//InstanceCountedPlugin 查询到当前实例数后调用
+type InstanceCountedPlugin interface {
+	OnInstanceCounted(ctx context.Context, rule *model.PredictRule, currentCount int) error
+}
+
+//ThresholdCheckedPlugin 冗余度中位数与 min/max 冗余度比较后调用，withinRange 表示不需要扩缩容
+type ThresholdCheckedPlugin interface {
+	OnThresholdChecked(ctx context.Context, rule *model.PredictRule, redundancy float64, withinRange bool) error
+}
+
+//ScaleDecidedPlugin 确定扩缩容数量后、调用 schedulx 之前调用，返回 ErrSkipScaling 时不扩缩容
+type ScaleDecidedPlugin interface {
+	OnScaleDecided(ctx context.Context, rule *model.PredictRule, decision ScaleDecision) error
+}
+
+//ScaleDecision 本次扩缩容的决定
+type ScaleDecision struct {
+	//Action 参见 event.ActionScaleUp/event.ActionScaleDown
+	Action       string
+	Count        int
+	CurrentCount int
+	Redundancy   float64
+}
+
+//RegisterPlugin 注册插件，对之后开始的 scheduleRule 生效
+func (keeper *ScheduleXRedundancyKeeper) RegisterPlugin(p Plugin) {
+	keeper.lock.Lock()
+	defer keeper.lock.Unlock()
+	keeper.plugins = append(keeper.plugins, p)
+}
+
+func (keeper *ScheduleXRedundancyKeeper) registeredPlugins() []Plugin {
+	keeper.lock.RLock()
+	defer keeper.lock.RUnlock()
+	return keeper.plugins
+}
+
+func (keeper *ScheduleXRedundancyKeeper) onMetricQueried(ctx context.Context, plugins []Plugin, rule *model.PredictRule, series *service.RedundancySeries) error {
+	for _, p := range plugins {
+		if hook, ok := p.(MetricQueriedPlugin); ok {
+			if err := hook.OnMetricQueried(ctx, rule, series); err != nil {
+				return fmt.Errorf("plugin %s OnMetricQueried failed , %w", p.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+func (keeper *ScheduleXRedundancyKeeper) onInstanceCounted(ctx context.Context, plugins []Plugin, rule *model.PredictRule, currentCount int) error {
+	for _, p := range plugins {
+		if hook, ok := p.(InstanceCountedPlugin); ok {
+			if err := hook.OnInstanceCounted(ctx, rule, currentCount); err != nil {
+				return fmt.Errorf("plugin %s OnInstanceCounted failed , %w", p.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+func (keeper *ScheduleXRedundancyKeeper) onThresholdChecked(ctx context.Context, plugins []Plugin, rule *model.PredictRule, redundancy float64, withinRange bool) error {
+	for _, p := range plugins {
+		if hook, ok := p.(ThresholdCheckedPlugin); ok {
+			if err := hook.OnThresholdChecked(ctx, rule, redundancy, withinRange); err != nil {
+				return fmt.Errorf("plugin %s OnThresholdChecked failed , %w", p.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+//onScaleDecided 依次调用 OnScaleDecided，有插件返回 ErrSkipScaling 时 skippedBy 为该插件名称
+func (keeper *ScheduleXRedundancyKeeper) onScaleDecided(ctx context.Context, plugins []Plugin, rule *model.PredictRule, decision ScaleDecision) (skippedBy string, err error) {
+	for _, p := range plugins {
+		if hook, ok := p.(ScaleDecidedPlugin); ok {
+			err := hook.OnScaleDecided(ctx, rule, decision)
+			if errors.Is(err, ErrSkipScaling) {
+				return p.Name(), nil
+			}
+			if err != nil {
+				return "", fmt.Errorf("plugin %s OnScaleDecided failed , %w", p.Name(), err)
+			}
+		}
+	}
+	return "", nil
+}
+
+//RegisterPlugin 注册插件
+func RegisterPlugin(p Plugin) error {
+	if redundancyKeeper == nil {
+		return errors.New("redundancy keeper is not initialized")
+	}
+	redundancyKeeper.RegisterPlugin(p)
+	return nil
+}
@@ -0,0 +1,108 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//recordingPlugin 记录被调用的方法，skip 为 true 时跳过扩缩容
+type recordingPlugin struct {
+	name      string
+	skip      bool
+	calls     []string
+	decisions []redundancy_keeper.ScaleDecision
+}
+
+func (p *recordingPlugin) Name() string {
+	return p.name
+}
+
+func (p *recordingPlugin) OnMetricQueried(ctx context.Context, rule *model.PredictRule, series *service.RedundancySeries) error {
+	p.calls = append(p.calls, "OnMetricQueried")
+	return nil
+}
+
+func (p *recordingPlugin) OnInstanceCounted(ctx context.Context, rule *model.PredictRule, currentCount int) error {
+	p.calls = append(p.calls, "OnInstanceCounted")
+	return nil
+}
+
+func (p *recordingPlugin) OnThresholdChecked(ctx context.Context, rule *model.PredictRule, redundancy float64, withinRange bool) error {
+	p.calls = append(p.calls, "OnThresholdChecked")
+	return nil
+}
+
+func (p *recordingPlugin) OnScaleDecided(ctx context.Context, rule *model.PredictRule, decision redundancy_keeper.ScaleDecision) error {
+	p.calls = append(p.calls, "OnScaleDecided")
+	p.decisions = append(p.decisions, decision)
+	if p.skip {
+		return redundancy_keeper.ErrSkipScaling
+	}
+	return nil
+}
+
+//namedPlugin 只实现 Plugin，不实现任何可选方法
+type namedPlugin string
+
+func (p namedPlugin) Name() string {
+	return string(p)
+}
+
+var _ = ginkgo.Describe("Plugin", func() {
+	rule := &model.PredictRule{
+		Id:               300,
+		ServiceName:      "plugin",
+		ClusterName:      "default",
+		MetricName:       "qps",
+		BenchmarkQps:     100,
+		MinRedundancy:    150,
+		MaxRedundancy:    250,
+		MinInstanceCount: 1,
+		MaxInstanceCount: 50,
+		ExecuteRatio:     100,
+		Status:           "enable",
+	}
+
+	run := func(plugins ...redundancy_keeper.Plugin) *inFlightScaler {
+		scaler := &inFlightScaler{}
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		for _, p := range plugins {
+			gomega.Expect(redundancy_keeper.RegisterPlugin(p)).To(gomega.BeNil())
+		}
+		redundancy_keeper.Start(context.Background())
+		return scaler
+	}
+
+	ginkgo.It("calls plugins in order at each stage", func() {
+		first := &recordingPlugin{name: "first"}
+		second := &recordingPlugin{name: "second"}
+		scaler := run(first, namedPlugin("noop"), second)
+		gomega.Expect(first.calls).To(gomega.Equal([]string{"OnMetricQueried", "OnInstanceCounted", "OnThresholdChecked", "OnScaleDecided"}))
+		gomega.Expect(second.calls).To(gomega.Equal(first.calls))
+		gomega.Expect(first.decisions).To(gomega.Equal([]redundancy_keeper.ScaleDecision{{Action: event.ActionScaleUp, Count: 30, CurrentCount: 10, Redundancy: 0.5}}))
+		gomega.Expect(scaler.expanded).To(gomega.Equal(1))
+	})
+
+	ginkgo.It("suppresses scaling when a plugin returns ErrSkipScaling", func() {
+		skipping := &recordingPlugin{name: "freeze", skip: true}
+		after := &recordingPlugin{name: "after"}
+		scaler := run(skipping, after)
+		gomega.Expect(scaler.expanded).To(gomega.Equal(0))
+		gomega.Expect(after.decisions).To(gomega.BeEmpty())
+
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(explain.Reason).To(gomega.Equal("skipped: scale up of 30 instances skipped by plugin freeze"))
+	})
+})
@@ -0,0 +1,85 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"go.uber.org/zap"
+)
+
+//ErrRetentionNotConfigured 未配置 metrics_retention_days 时不清理扩缩容事件
+var ErrRetentionNotConfigured = errors.New("metrics_retention_days is not configured")
+
+//WithScalingEventPruner 指定删除过期扩缩容事件的方式，为空时从数据库删除
+func WithScalingEventPruner(pruneScalingEvents func(before int64, limit int) (int64, error)) Option {
+	return func(keeper *ScheduleXRedundancyKeeper) {
+		if pruneScalingEvents != nil {
+			keeper.pruneScalingEvents = pruneScalingEvents
+		}
+	}
+}
+
+//PruneNow 删除早于 MetricsRetentionDays 的扩缩容事件，每次最多删除 consts.PruneBatchSize 行，
+//两次删除之间间隔 consts.PruneBatchInterval 避免长时间锁表；返回删除的行数
+func (keeper *ScheduleXRedundancyKeeper) PruneNow(ctx context.Context) (int64, error) {
+	if keeper.MetricsRetentionDays <= 0 {
+		return 0, ErrRetentionNotConfigured
+	}
+	before := keeper.now().AddDate(0, 0, -keeper.MetricsRetentionDays).Unix()
+	var total int64
+	for {
+		pruned, err := keeper.pruneScalingEvents(before, consts.PruneBatchSize)
+		total += pruned
+		keeper.metrics.PrunedEvents.Add(float64(pruned))
+		if err != nil {
+			return total, err
+		}
+		if pruned < consts.PruneBatchSize {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return total, ctx.Err()
+		case <-time.After(consts.PruneBatchInterval):
+		}
+	}
+	keeper.logger.Info("pruned scaling events", zap.Int("retention_days", keeper.MetricsRetentionDays), zap.Int64("pruned", total))
+	return total, nil
+}
+
+//startPruner 按 PruneInterval 周期清理过期的扩缩容事件，直到 ctx 结束
+func (keeper *ScheduleXRedundancyKeeper) startPruner(ctx context.Context) {
+	if keeper.MetricsRetentionDays <= 0 {
+		return
+	}
+	ticker := time.NewTicker(keeper.PruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := keeper.PruneNow(ctx); err != nil {
+				keeper.logger.Error("prune scaling events failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+//PruneNow 立即清理过期的扩缩容事件，返回删除的行数
+func PruneNow(ctx context.Context) (int64, error) {
+	if redundancyKeeper == nil {
+		return 0, errors.New("redundancy keeper is not initialized")
+	}
+	return redundancyKeeper.PruneNow(ctx)
+}
+
+//StartScalingEventPruner 按 prune_interval 周期清理过期的扩缩容事件，未配置 metrics_retention_days 时直接返回
+func StartScalingEventPruner(ctx context.Context) {
+	if redundancyKeeper == nil {
+		return
+	}
+	redundancyKeeper.startPruner(ctx)
+}
@@ -0,0 +1,64 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("PruneNow", func() {
+	var remaining int64
+	var befores []int64
+	var failAfter int
+
+	initKeeper := func(retentionDays int) {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true, MetricsRetentionDays: retentionDays},
+			redundancy_keeper.WithScalingEventPruner(func(before int64, limit int) (int64, error) {
+				befores = append(befores, before)
+				if failAfter > 0 && len(befores) > failAfter {
+					return 0, errors.New("lock wait timeout")
+				}
+				pruned := min(remaining, int64(limit))
+				remaining -= pruned
+				return pruned, nil
+			}),
+		)).To(gomega.Succeed())
+	}
+
+	ginkgo.BeforeEach(func() {
+		remaining, befores, failAfter = 0, nil, 0
+	})
+
+	ginkgo.It("deletes expired events in batches until a batch is not full", func() {
+		remaining = 2*consts.PruneBatchSize + 300
+		initKeeper(30)
+		pruned, err := redundancy_keeper.PruneNow(context.Background())
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(pruned).To(gomega.Equal(int64(2*consts.PruneBatchSize + 300)))
+		gomega.Expect(befores).To(gomega.HaveLen(3))
+		gomega.Expect(befores[0]).To(gomega.BeNumerically("~", time.Now().AddDate(0, 0, -30).Unix(), 5))
+		gomega.Expect(befores[1]).To(gomega.Equal(befores[0]))
+	})
+
+	ginkgo.It("returns the events pruned before a failed batch", func() {
+		remaining = 3 * consts.PruneBatchSize
+		failAfter = 1
+		initKeeper(30)
+		pruned, err := redundancy_keeper.PruneNow(context.Background())
+		gomega.Expect(err).To(gomega.MatchError("lock wait timeout"))
+		gomega.Expect(pruned).To(gomega.Equal(int64(consts.PruneBatchSize)))
+	})
+
+	ginkgo.It("refuses to prune without metrics_retention_days", func() {
+		initKeeper(0)
+		_, err := redundancy_keeper.PruneNow(context.Background())
+		gomega.Expect(err).To(gomega.Equal(redundancy_keeper.ErrRetentionNotConfigured))
+		gomega.Expect(befores).To(gomega.BeEmpty())
+	})
+})
@@ -0,0 +1,58 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/stats"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"go.uber.org/zap"
+)
+
+//handleRecovery 单机指标中位数低于 RecoveryThreshold 时认为服务不可用，进入恢复模式并直接扩容到 MaxInstanceCount，
+//忽略 ExecuteRatio 和单次扩容上限。扩容仍然经过 scale 执行插件和云配额检查，但服务不可用时不能等待人工确认，所以不需要确认。
+//返回 true 表示本轮已由恢复模式处理。
+func (keeper *ScheduleXRedundancyKeeper) handleRecovery(ctx context.Context, plugins []Plugin, rule *model.PredictRule, sorted []float64, currentCount int, now time.Time, trace *RuleTrace) (bool, error) {
+	if rule.RecoveryThreshold <= 0 || len(sorted) == 0 {
+		return false, nil
+	}
+	// 冗余度 = benchmark / 单机指标，指标为0时冗余度为+Inf
+	var metricPerInstance float64
+	median := stats.Median(sorted)
+	if median > 0 && !math.IsInf(median, 1) {
+		metricPerInstance = float64(rule.BenchmarkQps) / median
+	}
+
+	log := keeper.loggerFor(ctx)
+	_, recovering := keeper.recoveringRules.Load(rule.Id)
+	if recovering {
+		if metricPerInstance > float64(rule.MinRedundancy)/100.0*float64(rule.BenchmarkQps) {
+			keeper.recoveringRules.Delete(rule.Id)
+			log.Error("service exit recovery mode", zap.String("service", rule.ServiceName),
+				zap.String("cluster", rule.ClusterName), zap.Float64("metric_per_instance", metricPerInstance))
+			return false, nil
+		}
+	} else {
+		if metricPerInstance >= rule.RecoveryThreshold {
+			return false, nil
+		}
+		keeper.recoveringRules.Store(rule.Id, struct{}{})
+		keeper.metrics.RecoveryModeEntries.WithLabelValues(rule.ServiceName, rule.ClusterName).Inc()
+		log.Error("service enter recovery mode", zap.String("service", rule.ServiceName),
+			zap.String("cluster", rule.ClusterName), zap.Float64("metric_per_instance", metricPerInstance),
+			zap.Float64("recovery_threshold", rule.RecoveryThreshold))
+	}
+
+	countToChange := rule.MaxInstanceCount - currentCount
+	if countToChange <= 0 {
+		trace.finish(TraceOutcomeSkipped, "in recovery mode, already at max_instance_count %d", rule.MaxInstanceCount)
+		return true, nil
+	}
+	recoveryRule := *rule
+	recoveryRule.MaxExpandPercent = 0
+	recoveryRule.MaxScaleUpAbsolute = 0
+	recoveryRule.ReviewRequired = false
+	trace.step("in recovery mode with metric per instance %.2f, expanding to max_instance_count %d", metricPerInstance, rule.MaxInstanceCount)
+	return true, keeper.scale(ctx, plugins, &recoveryRule, countToChange, currentCount, median, now, trace)
+}
@@ -0,0 +1,100 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = ginkgo.Describe("Recovery", func() {
+	var rule *model.PredictRule
+	var scaler *cloudQuotaScaler
+	var bundle *redundancy_keeper.MetricsBundle
+
+	// highRedundancyBackend 的冗余度为5，单机指标为 100 / 5 = 20
+	start := func(plugins ...redundancy_keeper.Plugin) *redundancy_keeper.ScheduleSummary {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricsBundle(bundle),
+			redundancy_keeper.WithMetricBackend(highRedundancyBackend{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		for _, p := range plugins {
+			gomega.Expect(redundancy_keeper.RegisterPlugin(p)).To(gomega.BeNil())
+		}
+		return redundancy_keeper.Start(context.Background())
+	}
+
+	entries := func() float64 {
+		return testutil.ToFloat64(bundle.RecoveryModeEntries.WithLabelValues(rule.ServiceName, rule.ClusterName))
+	}
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:                 3100,
+			ServiceName:        "recovery",
+			ClusterName:        "default",
+			MetricName:         "qps",
+			BenchmarkQps:       100,
+			MinRedundancy:      150,
+			MaxRedundancy:      600,
+			MinInstanceCount:   1,
+			MaxInstanceCount:   35,
+			MaxExpandPercent:   50,
+			MaxScaleUpAbsolute: 5,
+			ExecuteRatio:       10,
+			ReviewRequired:     true,
+			ReviewThreshold:    1,
+			RecoveryThreshold:  30,
+			Status:             consts.RuleStatusEnable,
+		}
+		scaler = &cloudQuotaScaler{remaining: 100}
+		var err error
+		bundle, err = redundancy_keeper.NewMetricsBundle(prometheus.NewRegistry())
+		gomega.Expect(err).To(gomega.BeNil())
+	})
+
+	ginkgo.It("expands to max_instance_count ignoring step limits and review when the metric per instance is below the threshold", func() {
+		summary := start()
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(scaler.expandCount).To(gomega.Equal(25))
+		gomega.Expect(entries()).To(gomega.Equal(float64(1)))
+
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(explain.Trace.Steps).To(gomega.ContainElement("in recovery mode with metric per instance 20.00, expanding to max_instance_count 35"))
+	})
+
+	ginkgo.It("does nothing when the metric per instance is above the threshold", func() {
+		rule.RecoveryThreshold = 10
+		summary := start()
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(0))
+		gomega.Expect(scaler.expandCount).To(gomega.Equal(0))
+		gomega.Expect(entries()).To(gomega.Equal(float64(0)))
+	})
+
+	ginkgo.It("still applies the cloud quota", func() {
+		rule.CheckQuotaBeforeExpand = true
+		scaler.remaining = 12
+		start()
+		gomega.Expect(scaler.expandCount).To(gomega.Equal(12))
+		gomega.Expect(entries()).To(gomega.Equal(float64(1)))
+	})
+
+	ginkgo.It("still calls the plugins", func() {
+		start(&recordingPlugin{name: "freeze", skip: true})
+		gomega.Expect(scaler.expandCount).To(gomega.Equal(0))
+		gomega.Expect(entries()).To(gomega.Equal(float64(1)))
+
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(explain.Reason).To(gomega.Equal("skipped: scale up of 25 instances skipped by plugin freeze"))
+	})
+})
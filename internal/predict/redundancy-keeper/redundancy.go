@@ -2,17 +2,26 @@ package redundancy_keeper
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"math"
-	"sort"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/galaxy-future/cudgx/common/logger"
+	"github.com/galaxy-future/cudgx/common/stats"
 	"github.com/galaxy-future/cudgx/internal/clients"
 	"github.com/galaxy-future/cudgx/internal/predict/config"
 	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
 	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"github.com/galaxy-future/cudgx/internal/predict/query"
 	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/google/uuid"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -23,145 +32,859 @@ var (
 //ScheduleXRedundancyKeeper 负责保持服务的冗余度
 type ScheduleXRedundancyKeeper struct {
 	ScheduleDuration time.Duration
-	concurrencyLock  chan struct{}
+	//ruleConcurrency 配置的并发数，开启 AdaptiveConcurrency 时 concurrencyLock 的容量可能与之不同
+	ruleConcurrency int
+	concurrencyLock chan struct{}
+	//RuleConcurrencyPerService 同一服务最多并行运行的规则数量，0表示不限制
+	RuleConcurrencyPerService int `json:"rule_concurrency_per_service"`
+	//serviceLocks 服务名 -> 该服务的并发控制 channel，在 schedule 中按需创建
+	serviceLocks *sync.Map
 	//MinimalSampleCount 参与判断中最少的指标点数，
 	MinimalSampleCount int `json:"minimal_sample_count"`
 	//LookbackDuration 回查多久
 	LookbackDuration time.Duration `json:"lookback_duration"`
 	//MetricSendDuration 指标传输所需时间，在这段时间内的指标是不准确的
 	MetricSendDuration time.Duration `json:"metric_send_duration"`
+	//OutlierRemovalMethod 计算中位数前剔除异常值的方法，none/iqr/zscore
+	OutlierRemovalMethod string `json:"outlier_removal_method"`
+	//MetricQueryTimeout 单次查询冗余度的超时时间
+	MetricQueryTimeout time.Duration `json:"metric_query_timeout"`
+	//PerRuleDeadline 单条规则一次执行的最长时间，参见 ruleDeadline
+	PerRuleDeadline time.Duration `json:"per_rule_deadline"`
+	//ScheduleCacheTTL 服务集群调度状态的缓存时间，不大于0时为调度周期的一半
+	ScheduleCacheTTL time.Duration `json:"schedule_cache_ttl"`
+	//QuotaCacheTTL 云厂商剩余配额的缓存时间，不大于0时为 consts.DefaultQuotaCacheTTL
+	QuotaCacheTTL time.Duration `json:"quota_cache_ttl"`
+	//LivenessThresholdMultiplier 超过多少个调度周期没有成功调度视为不存活
+	LivenessThresholdMultiplier float64 `json:"liveness_threshold_multiplier"`
+	//BackoffScheduleDuration 是否根据调度耗时自动调整调度周期
+	BackoffScheduleDuration bool          `json:"backoff_schedule_duration"`
+	MinScheduleDuration     time.Duration `json:"min_schedule_duration"`
+	MaxScheduleDuration     time.Duration `json:"max_schedule_duration"`
+	//AdaptiveConcurrency 是否根据调度耗时自动调整并发数
+	AdaptiveConcurrency    bool `json:"adaptive_concurrency"`
+	AdaptiveConcurrencyMin int  `json:"adaptive_concurrency_min"`
+	AdaptiveConcurrencyMax int  `json:"adaptive_concurrency_max"`
+	//ErrorThresholdForDisable 规则连续失败多少次后置为 error 状态，0表示不自动禁用
+	ErrorThresholdForDisable int `json:"error_threshold_for_disable"`
+	//RequireWarmCache 服务实例的 GetServiceByIp 缓存未命中时跳过本轮
+	RequireWarmCache bool `json:"require_warm_cache"`
+	//StickyShrinkEnabled 缩容时优先选择最近扩容加入的实例
+	StickyShrinkEnabled bool `json:"sticky_shrink_enabled"`
+	//ApprovalTimeout 待确认的扩缩容超过该时间未确认则过期
+	ApprovalTimeout time.Duration `json:"approval_timeout"`
+	//ApprovalBaseURL 生成确认和拒绝链接使用的 API 地址
+	ApprovalBaseURL string `json:"approval_base_url"`
+	//MaxWatchConnections 最多同时监听规则状态的连接数，0表示不限制
+	MaxWatchConnections int `json:"max_watch_connections"`
+	//MetricsRetentionDays 扩缩容事件的保留天数，0表示不清理
+	MetricsRetentionDays int `json:"metrics_retention_days"`
+	//PruneInterval 清理过期扩缩容事件的周期
+	PruneInterval time.Duration `json:"prune_interval"`
+	//RunOnce 只执行一轮调度后返回
+	RunOnce bool `json:"run_once"`
+	//DebugLogMetricValues 在 DEBUG 日志中输出计算中位数的冗余度
+	DebugLogMetricValues bool `json:"debug_log_metric_values"`
+	//backoff 开启 BackoffScheduleDuration 时的实际调度周期
+	backoff *ScheduleBackoff
+	//recoveringRules 处于恢复模式的规则
+	recoveringRules sync.Map
+	//overrides 规则的临时覆盖值
+	overrides sync.Map
+	//activeRules 正在执行 scheduleRule 的规则id
+	activeRules sync.Map
+	//lock 保护可热加载的参数
+	lock sync.RWMutex
+	//reloaded 调度周期变化时通知 Start 重置 ticker
+	reloaded chan struct{}
+	//heartbeat 最近一次成功调度
+	heartbeat *Heartbeat
+	//costs 按服务累计的成本变化
+	costs costSummary
+	//expansions 开启 StickyShrinkEnabled 时扩容加入的实例
+	expansions expansionHistory
+	//traces 各规则最近几次执行的调试记录
+	traces ruleTraces
+	//alerts 各规则最近一次冗余度过高告警的时间
+	alerts alertHistory
+	//scalePolicies 各规则 scale_up_policy/scale_down_policy 的状态
+	scalePolicies scalePolicyStates
+	//watchers 规则状态的监听者
+	watchers ruleWatchers
+	//counters 运行统计，参见 Stats
+	counters keeperCounters
+	//startedAt keeper 创建的时间
+	startedAt time.Time
+	//metrics keeper 上报的 Prometheus 指标，参见 WithMetricsBundle
+	metrics *MetricsBundle
+
+	scaler Scaler
+	//clusterTypeScalers cluster_type -> Scaler，不在其中的 cluster_type（包括 schedulx）使用 scaler
+	clusterTypeScalers map[string]Scaler
+	metricBackend      service.MetricBackend
+	//aliasMetricBackends metric_backend_alias -> 指标后端，参见 WithMetricBackendAliases
+	aliasMetricBackends map[string]service.MetricBackend
+	//fallbackMetricBackend metricBackend 查询失败时使用的指标后端，为空时不重试
+	fallbackMetricBackend service.MetricBackend
+	listRules             func() ([]*model.PredictRule, error)
+	ruleErrors            RuleErrorStore
+	approvals             ApprovalStore
+	//approvalNotifiers 扩缩容等待确认时的通知接收方
+	approvalNotifiers []ApprovalNotifier
+	//highRedundancyNotifiers 冗余度过高时的通知接收方
+	highRedundancyNotifiers []HighRedundancyNotifier
+	//metricMissingNotifiers 没有指标数据时的通知接收方
+	metricMissingNotifiers []MetricMissingNotifier
+	//shrinkVerificationNotifiers 缩容校验失败时的通知接收方
+	shrinkVerificationNotifiers []ShrinkVerificationNotifier
+	//listScalingEvents 生成冗余度报告时查询扩缩容事件
+	listScalingEvents func(serviceName, clusterName string, begin, end int64) ([]*model.ScalingEvent, error)
+	//pruneScalingEvents 删除 timestamp 早于 before 的扩缩容事件，最多删除 limit 行
+	pruneScalingEvents func(before int64, limit int) (int64, error)
+	//plugins 按注册顺序保存的插件
+	plugins []Plugin
+	publish func(e *event.ScalingEvent)
+	now     func() time.Time
+	logger  *zap.Logger
+	//tracer 创建 scheduleRule span，为空时使用 otel 的全局 TracerProvider，参见 WithTracerProvider
+	tracer        oteltrace.Tracer
+	costEstimator CostEstimator
+	//distributedLock 多实例部署时互斥执行同一服务集群的规则，为空时不加锁，参见 WithDistributedLock
+	distributedLock DistributedLock
+}
+
+//Option 初始化 keeper 时的可选项
+type Option func(keeper *ScheduleXRedundancyKeeper)
+
+//WithLogger 指定 keeper 使用的日志，为空时使用全局日志
+func WithLogger(l *zap.Logger) Option {
+	return func(keeper *ScheduleXRedundancyKeeper) {
+		if l != nil {
+			keeper.logger = l
+		}
+	}
+}
+
+//WithMetricBackend 指定 keeper 查询冗余度的指标后端，为空时使用 service.DefaultMetricBackend；
+//metric_scope 为 instance 的规则要求后端实现 service.InstanceMetricBackend
+func WithMetricBackend(backend service.MetricBackend) Option {
+	return func(keeper *ScheduleXRedundancyKeeper) {
+		if backend != nil {
+			keeper.metricBackend = backend
+		}
+	}
+}
+
+//WithScaler 指定 keeper 扩缩容使用的 Scaler，为空时通过 schedulx 扩缩容
+func WithScaler(scaler Scaler) Option {
+	return func(keeper *ScheduleXRedundancyKeeper) {
+		if scaler != nil {
+			keeper.scaler = scaler
+		}
+	}
+}
+
+//WithClusterTypeScaler 指定 cluster_type 为 clusterType 的规则扩缩容使用的 Scaler，为空时忽略
+func WithClusterTypeScaler(clusterType string, scaler Scaler) Option {
+	return func(keeper *ScheduleXRedundancyKeeper) {
+		if scaler != nil {
+			keeper.clusterTypeScalers[clusterType] = scaler
+		}
+	}
+}
+
+//WithRuleLister 指定每轮调度加载规则的方式，为空时从数据库加载
+func WithRuleLister(listRules func() ([]*model.PredictRule, error)) Option {
+	return func(keeper *ScheduleXRedundancyKeeper) {
+		if listRules != nil {
+			keeper.listRules = listRules
+		}
+	}
 }
 
-func InitRedundancyKeeper(param *config.Param) {
-	redundancyKeeper = &ScheduleXRedundancyKeeper{
-		ScheduleDuration:   param.RunDuration.Duration,
-		concurrencyLock:    make(chan struct{}, param.RuleConcurrency),
-		MinimalSampleCount: param.MinimalSampleCount,
-		LookbackDuration:   param.LookbackDuration.Duration,
-		MetricSendDuration: param.MetricSendDuration.Duration,
+//InitRedundancyKeeper 校验参数后创建 redundancy keeper，参数不合法时返回错误且不替换已有的 keeper
+func InitRedundancyKeeper(param *config.Param, opts ...Option) error {
+	if err := param.Validate(); err != nil {
+		return fmt.Errorf("invalid redundancy keeper param : %w", err)
+	}
+	aliasBackends, err := newAliasMetricBackends(param.MetricBackends)
+	if err != nil {
+		return err
 	}
+	distributedLock, err := newDistributedLock(param)
+	if err != nil {
+		return err
+	}
+	redundancyKeeper = newRedundancyKeeper(param, append([]Option{WithMetricBackendAliases(aliasBackends), WithDistributedLock(distributedLock)}, opts...)...)
+	model.SetRuleActiveChecker(redundancyKeeper.IsRuleActive)
+	clients.SetScheduleCacheTTL(redundancyKeeper.scheduleCacheTTL())
+	clients.SetInstanceCountCacheTTL(redundancyKeeper.instanceCountCacheTTL())
+	clients.SetQuotaCacheTTL(redundancyKeeper.quotaCacheTTL())
+	clients.SetCacheStatsRefreshDuration(redundancyKeeper.scheduleDuration())
+	redundancyKeeper.metrics.ScheduleDuration.Set(redundancyKeeper.scheduleDuration().Seconds())
+	return nil
 }
 
-func Start(ctx context.Context) {
-	ticker := time.NewTicker(redundancyKeeper.ScheduleDuration)
+func newRedundancyKeeper(param *config.Param, opts ...Option) *ScheduleXRedundancyKeeper {
+	keeper := &ScheduleXRedundancyKeeper{
+		ScheduleDuration:            param.RunDuration.Duration,
+		ruleConcurrency:             param.RuleConcurrency,
+		concurrencyLock:             make(chan struct{}, param.RuleConcurrency),
+		RuleConcurrencyPerService:   param.RuleConcurrencyPerService,
+		serviceLocks:                &sync.Map{},
+		MinimalSampleCount:          param.MinimalSampleCount,
+		LookbackDuration:            param.LookbackDuration.Duration,
+		MetricSendDuration:          param.MetricSendDuration.Duration,
+		OutlierRemovalMethod:        param.OutlierRemovalMethod,
+		MetricQueryTimeout:          param.MetricQueryTimeout.Duration,
+		PerRuleDeadline:             param.PerRuleDeadline.Duration,
+		ScheduleCacheTTL:            param.ScheduleCacheTTL.Duration,
+		QuotaCacheTTL:               param.QuotaCacheTTL.Duration,
+		LivenessThresholdMultiplier: param.LivenessThresholdMultiplier,
+		BackoffScheduleDuration:     param.BackoffScheduleDuration,
+		MinScheduleDuration:         param.MinScheduleDuration.Duration,
+		MaxScheduleDuration:         param.MaxScheduleDuration.Duration,
+		AdaptiveConcurrency:         param.AdaptiveConcurrency,
+		AdaptiveConcurrencyMin:      param.AdaptiveConcurrencyMin,
+		AdaptiveConcurrencyMax:      param.AdaptiveConcurrencyMax,
+		ErrorThresholdForDisable:    param.ErrorThresholdForDisable,
+		RequireWarmCache:            param.RequireWarmCache,
+		StickyShrinkEnabled:         param.StickyShrinkEnabled,
+		ApprovalTimeout:             time.Duration(param.ApprovalTimeoutMinutes) * time.Minute,
+		ApprovalBaseURL:             param.ApprovalBaseURL,
+		MaxWatchConnections:         param.MaxWatchConnections,
+		MetricsRetentionDays:        param.MetricsRetentionDays,
+		PruneInterval:               param.PruneInterval.Duration,
+		RunOnce:                     param.RunOnce,
+		DebugLogMetricValues:        param.DebugLogMetricValues,
+		heartbeat:                   NewHeartbeat(time.Now()),
+		metrics:                     defaultMetrics,
+		scaler:                      schedulxScaler{},
+		clusterTypeScalers: map[string]Scaler{
+			consts.ClusterTypeK8sDeployment:  K8sScaler{Resource: clients.K8sResourceDeployments},
+			consts.ClusterTypeK8sStatefulSet: K8sScaler{Resource: clients.K8sResourceStatefulSets},
+		},
+		metricBackend:      service.DefaultMetricBackend,
+		listRules:          model.ListAllPredictRules,
+		ruleErrors:         modelRuleErrorStore{},
+		approvals:          modelApprovalStore{},
+		listScalingEvents:  model.ListScalingEvents,
+		pruneScalingEvents: model.DeleteScalingEventsBefore,
+		publish:            event.Publish,
+		now:                time.Now,
+		reloaded:           make(chan struct{}, 1),
+		logger:             logger.GetLogger(),
+	}
+	if keeper.ApprovalTimeout <= 0 {
+		keeper.ApprovalTimeout = consts.DefaultApprovalTimeoutMinutes * time.Minute
+	}
+	if keeper.PruneInterval <= 0 {
+		keeper.PruneInterval = consts.DefaultPruneInterval
+	}
+	if keeper.PerRuleDeadline <= 0 {
+		keeper.PerRuleDeadline = consts.DefaultPerRuleDeadline
+	}
+	for _, opt := range opts {
+		opt(keeper)
+	}
+	keeper.startedAt = keeper.now()
+	keeper.resetBackoff()
+	return keeper
+}
+
+//Start 按调度周期执行规则直到 ctx 结束；RunOnce 为 true 时只执行一轮并返回本轮的统计
+func Start(ctx context.Context) *ScheduleSummary {
+	if redundancyKeeper.RunOnce {
+		summary, _ := redundancyKeeper.runSchedule()
+		return summary
+	}
+	ticker := time.NewTicker(redundancyKeeper.scheduleDuration())
+	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
-			break
+			return nil
+		case <-redundancyKeeper.reloaded:
+			ticker.Reset(redundancyKeeper.scheduleDuration())
 		case <-ticker.C:
-			err := redundancyKeeper.schedule()
-			if err != nil {
-				logger.GetLogger().Error("failed schedule rules", zap.Error(err))
+			if _, changed := redundancyKeeper.runSchedule(); changed {
+				ticker.Reset(redundancyKeeper.scheduleDuration())
 			}
 		}
 	}
 }
 
-func (keeper *ScheduleXRedundancyKeeper) schedule() error {
-	rules, err := model.ListAllPredictRules()
+//runSchedule 执行一轮调度，循环和 run_once 模式共用；changed 表示调度周期是否因耗时被调整
+func (keeper *ScheduleXRedundancyKeeper) runSchedule() (summary *ScheduleSummary, changed bool) {
+	keeper.heartbeat.MarkTickerFired()
+	begin := time.Now()
+	summary, err := keeper.schedule()
 	if err != nil {
-		return err
+		keeper.logger.Error("failed schedule rules", zap.Error(err))
+		summary = &ScheduleSummary{Error: err.Error()}
 	}
+	elapsed := time.Since(begin)
+	keeper.counters.recordTick(elapsed, err)
+	keeper.observeTickConcurrency(elapsed)
+	return summary, keeper.observeScheduleElapsed(elapsed)
+}
 
+func (keeper *ScheduleXRedundancyKeeper) schedule() (*ScheduleSummary, error) {
+	rules, err := keeper.listRules()
+	if err != nil {
+		return nil, err
+	}
+	keeper.heartbeat.MarkRulesLoaded()
+
+	// 同一轮调度的所有 schedulx 请求使用同一个 request id
+	requestID := uuid.NewString()
+	ctx := clients.WithRequestID(context.Background(), requestID)
+
+	keeper.lock.RLock()
+	perRuleDeadline := keeper.PerRuleDeadline
+	keeper.lock.RUnlock()
+	prefetchCtx, cancelPrefetch := context.WithTimeout(ctx, perRuleDeadline)
+	keeper.prefetchServiceSchedule(prefetchCtx, rules)
+	cancelPrefetch()
+
+	keeper.lock.RLock()
+	concurrencyLock := keeper.concurrencyLock
+	concurrencyPerService, serviceLocks := keeper.RuleConcurrencyPerService, keeper.serviceLocks
+	keeper.lock.RUnlock()
+	var enabledRules []*model.PredictRule
 	for _, rule := range rules {
-		if rule.Status != consts.RuleStatusEnable {
+		if rule.Status == consts.RuleStatusEnable {
+			enabledRules = append(enabledRules, rule)
+		}
+	}
+	levels, cyclic := dependencyLevels(enabledRules)
+	if len(cyclic) > 0 {
+		ruleIDs := make([]int64, 0, len(cyclic))
+		for _, rule := range cyclic {
+			ruleIDs = append(ruleIDs, rule.Id)
+		}
+		keeper.logger.Warn("rule dependencies form a cycle, schedule them after all other rules", zap.String("request_id", requestID), zap.Int64s("rule_ids", ruleIDs))
+		levels = append(levels, cyclic)
+	}
+
+	summary := &ScheduleSummary{}
+	// 依赖的规则在前一层，前一层的规则全部结束后才开始下一层
+	for _, levelRules := range levels {
+		var wg sync.WaitGroup
+		for _, rule := range levelRules {
+			wg.Add(1)
+			go func(theRule *model.PredictRule) {
+				defer wg.Done()
+				// 先占用服务的并发数，避免等待同一服务的规则占满 RuleConcurrency
+				if concurrencyPerService > 0 {
+					lock, _ := serviceLocks.LoadOrStore(theRule.ServiceName, make(chan struct{}, concurrencyPerService))
+					serviceLock := lock.(chan struct{})
+					serviceLock <- struct{}{}
+					defer func() { <-serviceLock }()
+				}
+				concurrencyLock <- struct{}{}
+				defer func() { <-concurrencyLock }()
+				// 占用并发数后才开始计时，等待并发数的时间不计入
+				deadline := ruleDeadline(theRule, perRuleDeadline)
+				ruleCtx, cancel := context.WithTimeout(ctx, deadline)
+				err := keeper.scheduleRule(ruleCtx, keeper.applyRuleOverride(theRule))
+				if errors.Is(ruleCtx.Err(), context.DeadlineExceeded) {
+					keeper.logger.Warn("rule exceeded per_rule_deadline, blocking calls are cancelled", zap.String("request_id", requestID), zap.Int64("rule_id", theRule.Id),
+						zap.String("service", theRule.ServiceName), zap.String("cluster", theRule.ClusterName), zap.Duration("deadline", deadline))
+				}
+				cancel()
+				if err != nil {
+					keeper.logger.Error("failed to schedule service", zap.String("request_id", requestID), zap.String("service", theRule.ServiceName), zap.String("cluster", theRule.ClusterName), zap.Error(err))
+				}
+				if recordErr := keeper.RecordRuleError(theRule, err); recordErr != nil {
+					keeper.logger.Error("failed to record rule error", zap.Int64("rule_id", theRule.Id), zap.Error(recordErr))
+				}
+				trace := keeper.traces.latest(theRule.Id)
+				summary.add(trace, err)
+				keeper.counters.recordRule(trace, err)
+			}(rule)
+		}
+		// 等待所有规则结束，调度耗时才能反映是否与下一轮重叠
+		wg.Wait()
+	}
+	keeper.heartbeat.Beat(keeper.now())
+	return summary, nil
+}
+
+//prefetchServiceSchedule 批量查询启用规则的调度状态并缓存，避免每条规则单独请求 schedulx
+func (keeper *ScheduleXRedundancyKeeper) prefetchServiceSchedule(ctx context.Context, rules []*model.PredictRule) {
+	checker, ok := keeper.scaler.(batchScheduleChecker)
+	if !ok {
+		return
+	}
+	var pairs []clients.ServiceClusterPair
+	for _, rule := range rules {
+		// 其他类型集群的规则不由 keeper.scaler 扩缩容
+		if _, ok := keeper.clusterTypeScalers[rule.ClusterType]; ok || rule.Status != consts.RuleStatusEnable {
 			continue
 		}
-		keeper.concurrencyLock <- struct{}{}
-		go func(theRule *model.PredictRule) {
-			defer func() {
-				<-keeper.concurrencyLock
-			}()
-			err := scheduleRule(theRule)
-			if err != nil {
-				logger.GetLogger().Error("failed to schedule service", zap.String("service", theRule.ServiceName), zap.String("cluster", theRule.ClusterName), zap.Error(err))
-			}
-		}(rule)
+		pairs = append(pairs, clients.ServiceClusterPair{ServiceName: rule.ServiceName, ClusterName: rule.ClusterName})
+	}
+	if len(pairs) == 0 {
+		return
+	}
+	if _, err := checker.BatchCanServiceSchedule(ctx, pairs); err != nil {
+		keeper.loggerFor(ctx).Warn("batch query service schedule failed, fall back to per rule query", zap.Int("count", len(pairs)), zap.Error(err))
 	}
-	return nil
 }
 
-func scheduleRule(rule *model.PredictRule) error {
-	const lookbackDuration = time.Minute
-	const metricsSendDuration = 5 * time.Second
-	const minSampleCount = lookbackDuration - 30*time.Second
+//queryBackend 从 metricBackend 查询规则的冗余度，metric_scope 为 instance 时先查询实例 ip 再按 ip 查询指标，healthy_instances_only 时只查询健康实例
+func (keeper *ScheduleXRedundancyKeeper) queryBackend(ctx context.Context, metricBackend service.MetricBackend, rule *model.PredictRule, begin, end int64) (*service.RedundancySeries, error) {
+	benchmark := float64(rule.BenchmarkQps)
+	if rule.MetricScope != consts.MetricScopeInstance {
+		return metricBackend.QueryRedundancy(ctx, rule.ServiceName, rule.ClusterName, rule.QueryMetricName(), rule.MetricQueryMode, benchmark, begin, end, consts.DefaultTrimmedSecond)
+	}
+	backend, ok := metricBackend.(service.InstanceMetricBackend)
+	if !ok {
+		return nil, service.ErrInstanceScopeUnsupported
+	}
+	var instanceIps []string
+	var err error
+	if rule.HealthyInstancesOnly {
+		instanceIps, err = keeper.healthyInstanceIps(ctx, rule)
+	} else {
+		instanceIps, err = keeper.scalerFor(rule).GetServiceInstanceIps(ctx, rule.ServiceName, rule.ClusterName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query service instance ips failed , %w", err)
+	}
+	if len(instanceIps) == 0 {
+		return &service.RedundancySeries{ServiceName: rule.ServiceName, MetricName: rule.MetricName}, nil
+	}
+	return backend.QueryInstanceRedundancy(ctx, rule.ServiceName, rule.ClusterName, rule.MetricName, instanceIps, benchmark, begin, end, consts.DefaultTrimmedSecond)
+}
+
+//expandAndWait 扩容并等待实例就绪，最多等待 ReadinessTimeoutSeconds，未配置时为 consts.DefaultReadinessTimeout
+func (keeper *ScheduleXRedundancyKeeper) expandAndWait(ctx context.Context, rule *model.PredictRule, count int, idempotencyKey string) error {
+	timeout := time.Duration(rule.ReadinessTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = consts.DefaultReadinessTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return keeper.scalerFor(rule).ExpandServiceAndWait(ctx, rule.ServiceName, rule.ClusterName, count, idempotencyKey)
+}
+
+//IsRuleActive 规则当前是否正在执行 scheduleRule
+func (keeper *ScheduleXRedundancyKeeper) IsRuleActive(ruleID int64) bool {
+	_, ok := keeper.activeRules.Load(ruleID)
+	return ok
+}
+
+func (keeper *ScheduleXRedundancyKeeper) scheduleRule(ctx context.Context, rule *model.PredictRule) (err error) {
+	keeper.activeRules.Store(rule.Id, struct{}{})
+	defer keeper.activeRules.Delete(rule.Id)
+	keeper.counters.runningRules.Add(1)
+	defer keeper.counters.runningRules.Add(-1)
+	ctx, span := keeper.startRuleSpan(ctx, rule)
+	defer func() {
+		endRuleSpan(span, err)
+	}()
+	if rule.GreenBlueMode && rule.TrafficSplitSource == "" {
+		var color string
+		rule, color, err = keeper.activeColorRule(ctx, rule)
+		if err != nil {
+			return err
+		}
+		if rule.ColorMetricLabel != "" {
+			ctx = query.WithLabelFilter(ctx, rule.ColorMetricLabel, color)
+		}
+	}
 	serviceName := rule.ServiceName
 	clusterName := rule.ClusterName
-	metricName := rule.MetricName
 	benchmark := rule.BenchmarkQps
 
-	series, err := service.QueryRedundancy(serviceName, clusterName, metricName, float64(benchmark), time.Now().Add(-1*lookbackDuration).Unix(), time.Now().Add(-1*metricsSendDuration).Unix(), consts.DefaultTrimmedSecond)
+	log := keeper.loggerFor(ctx)
+
+	now := keeper.now()
+	lookbackDuration, metricSendDuration, minimalSampleCount := keeper.sampleWindow()
+	trace := &RuleTrace{RuleId: rule.Id, ServiceName: serviceName, ClusterName: clusterName, Timestamp: now.Unix()}
+	defer func() {
+		keeper.finishTrace(trace, err)
+		recordRuleTrace(span, trace)
+	}()
+	if keeper.distributedLock != nil {
+		lockCtx, release, acquired := keeper.acquireRuleLock(ctx, serviceName, clusterName, trace)
+		if !acquired {
+			return nil
+		}
+		defer release()
+		ctx = lockCtx
+	}
+	plugins := keeper.registeredPlugins()
+	rule = applyInstanceCountMultipliers(rule, now, trace)
+	if benchmark <= 0 {
+		// benchmark_qps 为0时冗余度恒为0，等待校准
+		trace.finish(TraceOutcomeSkipped, "benchmark_qps is not calibrated")
+		return nil
+	}
+	rule = keeper.applyServiceMaxInstances(ctx, rule, trace)
+
+	queryCtx := ctx
+	if timeout := keeper.metricQueryTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		queryCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	begin, end := now.Add(-1*lookbackDuration).Unix(), now.Add(-1*metricSendDuration).Unix()
+	if rule.MultiClusterMode {
+		return keeper.scheduleMultiClusterRule(ctx, queryCtx, plugins, rule, begin, end, now, trace)
+	}
+	if rule.TrafficSplitSource != "" {
+		return keeper.scheduleTrafficSplitRule(ctx, queryCtx, plugins, rule, begin, end, now, trace)
+	}
+	series, err := keeper.queryRedundancy(queryCtx, rule, begin, end)
 	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(queryCtx.Err(), context.DeadlineExceeded) {
+			// 指标后端过慢时跳过本轮，避免占满 RuleConcurrency
+			keeper.metrics.MetricQueryTimeouts.WithLabelValues(serviceName, clusterName).Inc()
+			log.Warn("query redundancy timeout, skip this round", zap.String("service", serviceName),
+				zap.String("cluster", clusterName), zap.Duration("timeout", keeper.metricQueryTimeout()))
+			trace.finish(TraceOutcomeSkipped, "metric query timeout after %s", keeper.metricQueryTimeout())
+			return nil
+		}
+		if errors.Is(err, errMetricBackendsUnavailable) {
+			log.Warn("all metric backends failed, skip this round", zap.String("service", serviceName), zap.String("cluster", clusterName), zap.Error(err))
+			trace.finish(TraceOutcomeSkipped, "%s", errMetricBackendsUnavailable)
+			return nil
+		}
+		return err
+	}
+	if err := keeper.onMetricQueried(ctx, plugins, rule, series); err != nil {
 		return err
 	}
 
-	canSchedule, err := clients.CanServiceSchedule(serviceName, clusterName)
+	canSchedule, err := keeper.scalerFor(rule).CanServiceSchedule(ctx, serviceName, clusterName)
 	if err != nil {
 		return fmt.Errorf("query service schedule failed , %w", err)
 	}
 	if !canSchedule {
+		trace.finish(TraceOutcomeSkipped, "service is being scheduled by schedulx")
 		return nil
 	}
+	if keeper.requireWarmCache() {
+		warm, err := keeper.isServiceCacheWarm(ctx, rule)
+		if err != nil {
+			return err
+		}
+		if !warm {
+			log.Warn("service cache is cold, skip this round", zap.String("service", serviceName), zap.String("cluster", clusterName))
+			trace.finish(TraceOutcomeSkipped, "GetServiceByIp cache is cold")
+			return nil
+		}
+	}
 
-	currentCount, err := clients.GetServiceInstanceCount(serviceName, clusterName)
+	currentCount, err := keeper.scalerFor(rule).GetServiceInstanceCount(ctx, serviceName, clusterName)
 	if err != nil {
 		return fmt.Errorf("query service instance count failed , %w", err)
 	}
+	trace.step("current instance count %d", currentCount)
+	if err := ValidateInstanceCount(currentCount, rule); err != nil {
+		keeper.metrics.InstanceCountValidationFailures.WithLabelValues(serviceName, clusterName).Inc()
+		log.Warn("instance count is invalid, skip this round", zap.String("service", serviceName), zap.String("cluster", clusterName), zap.Error(err))
+		trace.finish(TraceOutcomeSkipped, "%s", err)
+		return nil
+	}
+	if err := keeper.onInstanceCounted(ctx, plugins, rule, currentCount); err != nil {
+		return err
+	}
+	// 不健康实例的指标没有参与冗余度计算，按健康实例数估算流量和需要的实例数，实例数上下限仍按实例总数检查
+	capacityCount := currentCount
+	if rule.HealthyInstancesOnly {
+		healthyIps, err := keeper.healthyInstanceIps(ctx, rule)
+		if err != nil {
+			return err
+		}
+		capacityCount = len(healthyIps)
+		trace.step("healthy instance count %d", capacityCount)
+		if capacityCount == 0 {
+			trace.finish(TraceOutcomeSkipped, "no healthy instances")
+			return nil
+		}
+	}
 
+	metricFound := false
 	for _, cluster := range series.Clusters {
 		if cluster.ClusterName != clusterName {
 			continue
 		}
+		metricFound = true
+		trace.step("queried %d samples", len(cluster.Values))
+		if len(cluster.Values) == 0 {
+			if handled, err := keeper.handleMetricMissing(ctx, plugins, rule, currentCount, now, trace); handled || err != nil {
+				return err
+			}
+		}
+		if window := int64(rule.MetricAggregationWindowSeconds); window > 0 && window < end-begin {
+			// 回查窗口首尾的点可能还在指标发送窗口内，只用中间的点
+			queried := len(cluster.Values)
+			cluster.Timestamps, cluster.Values = middleWindow(cluster.Timestamps, cluster.Values, begin, end, window)
+			trace.step("aggregated %d of %d samples in the middle %ds", len(cluster.Values), queried, window)
+		}
 		// 没有足够的采集点
-		if len(cluster.Values) < int(minSampleCount.Seconds()) {
+		if len(cluster.Values) < minimalSampleCount {
+			trace.finish(TraceOutcomeSkipped, "insufficient samples (%d of %d required)", len(cluster.Values), minimalSampleCount)
 			continue
 		}
-		sort.Float64s(cluster.Values)
+		// 排序前按时间顺序计算首尾变化率
+		rateOfChange, changeTooHigh := rateOfChangeTooHigh(rule, cluster.Values)
+		var forecast float64
+		var forecasted bool
+		if rule.ForecastHorizonSeconds > 0 {
+			forecast, forecasted = forecastRedundancy(cluster.Timestamps, cluster.Values, now.Unix()+int64(rule.ForecastHorizonSeconds))
+		}
+		var rawValues []float64
+		if keeper.debugLogMetricValues() && log.Core().Enabled(zap.DebugLevel) {
+			rawValues = truncateDebugValues(cluster.Values)
+		}
+		slices.Sort(cluster.Values)
 
-		// 取中间数
-		redundancy := cluster.Values[len(cluster.Values)/2]
+		recovered, err := keeper.handleRecovery(ctx, plugins, rule, cluster.Values, currentCount, now, trace)
+		if err != nil {
+			return err
+		}
+		if recovered {
+			continue
+		}
 
-		//不需要调度
-		if int(redundancy*100) < rule.MaxRedundancy && int(redundancy*100) > rule.MinRedundancy {
+		// 流量剧烈变化时回查窗口内的数据已经过时，跳过本轮
+		if changeTooHigh {
+			log.Info("rate_of_change_too_high", zap.String("service", serviceName), zap.String("cluster", clusterName),
+				zap.Float64("rate_of_change_percent", rateOfChange), zap.Float64("max_rate_of_change_percent", rule.MaxRateOfChangePercent))
+			trace.finish(TraceOutcomeSkipped, "rate_of_change_too_high, redundancy changed %.2f%% exceeds max_rate_of_change_percent %.2f", rateOfChange, rule.MaxRateOfChangePercent)
 			continue
 		}
 
-		//取冗余度的中间数
-		midRedundancy := float64((rule.MaxRedundancy+rule.MinRedundancy)/2) / 100.0
+		outlierRemovalMethod := keeper.outlierRemovalMethod()
+		values := removeOutliers(outlierRemovalMethod, cluster.Values)
+		if removed := len(cluster.Values) - len(values); removed > 0 {
+			keeper.metrics.OutliersRemoved.WithLabelValues(outlierRemovalMethod, serviceName, clusterName).Add(float64(removed))
+			trace.step("removed %d outliers (%s)", removed, outlierRemovalMethod)
+		}
+		if len(values) == 0 {
+			trace.finish(TraceOutcomeSkipped, "all samples removed as outliers (%s)", outlierRemovalMethod)
+			continue
+		}
+
+		// 取中位数
+		redundancy := stats.Median(values)
+		trace.step("median redundancy %.2f", redundancy)
+		if rawValues != nil {
+			logMetricValues(log, rule, rawValues, values, redundancy)
+		}
+		if rule.ForecastHorizonSeconds > 0 {
+			if forecasted {
+				redundancy = forecast
+				trace.step("forecast redundancy %.2f in %ds", redundancy, rule.ForecastHorizonSeconds)
+			} else {
+				trace.step("can not forecast redundancy, use median")
+			}
+		}
+		trace.Redundancy = &redundancy
 
-		expectCount := int(midRedundancy / redundancy * float64(currentCount))
+		// 流量接近0时冗余度没有意义，跳过以免夜间来回扩缩容
+		if totalQPS, below := belowMinQPSThreshold(rule, float64(benchmark), capacityCount, redundancy); below {
+			log.Debug("total qps below threshold, skip scaling", zap.String("service", serviceName),
+				zap.String("cluster", clusterName), zap.Float64("total_qps", totalQPS), zap.Float64("min_qps_threshold", rule.MinQPSThreshold))
+			trace.finish(TraceOutcomeSkipped, "total qps %.2f below min_qps_threshold %.2f", totalQPS, rule.MinQPSThreshold)
+			continue
+		}
+		keeper.checkHighRedundancy(ctx, rule, redundancy, currentCount, now, trace)
 
-		diff := expectCount - currentCount
+		//不需要调度
+		withinRange := withinRedundancyRange(rule, redundancy)
+		if err := keeper.onThresholdChecked(ctx, plugins, rule, redundancy, withinRange); err != nil {
+			return err
+		}
+		decisionRedundancy, skipReason := keeper.applyScalePolicy(rule, redundancy, withinRange, trace)
+		if withinRange {
+			trace.finish(TraceOutcomeSkipped, "redundancy=%.2f within min=%.2f and max=%.2f", redundancy, float64(rule.MinRedundancy)/100, float64(rule.MaxRedundancy)/100)
+			continue
+		}
+		if skipReason != "" {
+			trace.finish(TraceOutcomeSkipped, "%s", skipReason)
+			continue
+		}
+		redundancy = decisionRedundancy
 
-		countToChange := int(math.Ceil(float64(diff*rule.ExecuteRatio) / 100.0))
+		//冗余度回到 min 和 max 的中间数
+		expectCount, countToChange := expectedInstanceChange(rule, redundancy, capacityCount)
+		trace.step("expected instance count %d, change %d with execute_ratio %d%%", expectCount, countToChange, rule.ExecuteRatio)
 
 		if countToChange == 0 {
+			trace.finish(TraceOutcomeSkipped, "redundancy=%.2f needs no instance change", redundancy)
 			continue
 		}
-		if countToChange > 0 {
-			if currentCount+countToChange > rule.MaxInstanceCount {
-				countToChange = rule.MaxInstanceCount - currentCount
-			}
-			if countToChange > 30 {
-				countToChange = 30
+		if err := keeper.scale(ctx, plugins, rule, countToChange, currentCount, redundancy, now, trace); err != nil {
+			return err
+		}
+	}
+	if !metricFound {
+		_, err := keeper.handleMetricMissing(ctx, plugins, rule, currentCount, now, trace)
+		return err
+	}
+	return nil
+}
+
+//scale 按 countToChange 扩缩容 rule 的服务集群，实例数上下限按 currentCount 检查
+func (keeper *ScheduleXRedundancyKeeper) scale(ctx context.Context, plugins []Plugin, rule *model.PredictRule, countToChange, currentCount int, redundancy float64, now time.Time, trace *RuleTrace) error {
+	serviceName := rule.ServiceName
+	clusterName := rule.ClusterName
+	expand := countToChange > 0
+	// 恢复模式的扩容按 critical 告警发布，见 handleRecovery
+	expandAction := event.ActionScaleUp
+	if _, recovering := keeper.recoveringRules.Load(rule.Id); recovering {
+		expandAction = event.ActionRecoveryScaleUp
+	}
+	countToChange, limitField := clampInstanceChange(rule, countToChange, currentCount)
+	if limitField != "" {
+		keeper.loggerFor(ctx).Info("instance change constrained by absolute limit", zap.String("service", serviceName),
+			zap.String("cluster", clusterName), zap.String("limit_field", limitField), zap.Int("limit", countToChange))
+		trace.step("change reduced to %d by %s", countToChange, limitField)
+	}
+	if expand {
+		if countToChange <= 0 && currentCount < rule.MaxInstanceCount {
+			trace.finish(TraceOutcomeSkipped, "redundancy=%.2f below min=%.2f but max_expand_percent %.2f%% of %d instances rounds down to 0", redundancy, float64(rule.MinRedundancy)/100, rule.MaxExpandPercent, currentCount)
+			return nil
+		}
+		if countToChange <= 0 {
+			trace.finish(TraceOutcomeSkipped, "redundancy=%.2f below min=%.2f but already at max_instance_count %d", redundancy, float64(rule.MinRedundancy)/100, rule.MaxInstanceCount)
+			return nil
+		}
+		if rule.CheckQuotaBeforeExpand {
+			countToChange = keeper.applyCloudQuota(ctx, rule, countToChange, trace)
+			if countToChange <= 0 {
+				trace.finish(TraceOutcomeSkipped, "redundancy=%.2f below min=%.2f but cloud quota of cluster %s is exhausted", redundancy, float64(rule.MinRedundancy)/100, clusterName)
+				return nil
 			}
-			err := clients.ExpandService(serviceName, clusterName, countToChange)
+		}
+		skippedBy, err := keeper.onScaleDecided(ctx, plugins, rule, ScaleDecision{Action: event.ActionScaleUp, Count: countToChange, CurrentCount: currentCount, Redundancy: redundancy})
+		if err != nil {
+			return err
+		}
+		if skippedBy != "" {
+			trace.finish(TraceOutcomeSkipped, "scale up of %d instances skipped by plugin %s", countToChange, skippedBy)
+			return nil
+		}
+		if requiresReview(rule, countToChange) {
+			return keeper.requestApproval(ctx, rule, event.ActionScaleUp, countToChange, currentCount, redundancy, trace)
+		}
+		keeper.recordExpansion(rule, now)
+		if rule.UseGradualExpand {
+			err := keeper.scalerFor(rule).GradualExpandService(ctx, serviceName, clusterName, countToChange, rule.GradualBatchSize, consts.GradualExpandBatchInterval, idempotencyKey(serviceName, clusterName, countToChange, now.Unix()))
 			if err != nil {
-				return fmt.Errorf("expand service failed , %w", err)
-			}
-		} else {
-			countToChange = int(math.Abs(float64(countToChange)))
-			if currentCount-countToChange < rule.MinInstanceCount {
-				countToChange = currentCount - rule.MinInstanceCount
+				var partialErr *clients.PartialExpandError
+				if errors.As(err, &partialErr) && partialErr.Expanded > 0 {
+					keeper.publishScalingEvent(ctx, rule, expandAction, partialErr.Expanded, currentCount, redundancy)
+					trace.finish(TraceOutcomeFailed, "gradual expand aborted after adding %d of %d instances, %v", partialErr.Expanded, countToChange, partialErr.Err)
+				}
+				return fmt.Errorf("gradual expand service failed , %w", err)
 			}
-			if countToChange > 30 {
-				countToChange = 30
+			keeper.publishScalingEvent(ctx, rule, expandAction, countToChange, currentCount, redundancy)
+			trace.finish(TraceOutcomeScaledUp, "redundancy=%.2f below min=%.2f, added %d instances gradually", redundancy, float64(rule.MinRedundancy)/100, countToChange)
+			return nil
+		}
+		if rule.UseExpandAndWait {
+			err := keeper.expandAndWait(ctx, rule, countToChange, idempotencyKey(serviceName, clusterName, countToChange, now.Unix()))
+			if errors.Is(err, clients.ErrExpandNotReady) {
+				keeper.publishScalingEvent(ctx, rule, expandAction, countToChange, currentCount, redundancy)
+				trace.finish(TraceOutcomeFailed, "redundancy=%.2f below min=%.2f, added %d instances but they are not ready, %v", redundancy, float64(rule.MinRedundancy)/100, countToChange, err)
 			}
-			err := clients.ShrinkService(serviceName, clusterName, countToChange)
 			if err != nil {
-				return fmt.Errorf("shrink service failed , %w", err)
+				return fmt.Errorf("expand service and wait failed , %w", err)
 			}
+			keeper.publishScalingEvent(ctx, rule, expandAction, countToChange, currentCount, redundancy)
+			trace.finish(TraceOutcomeScaledUp, "redundancy=%.2f below min=%.2f, added %d instances and they are ready", redundancy, float64(rule.MinRedundancy)/100, countToChange)
+			return nil
+		}
+		expanded, err := keeper.expandReducingOnCapacity(ctx, rule, countToChange, now, trace)
+		if err != nil {
+			return fmt.Errorf("expand service failed , %w", err)
 		}
+		keeper.publishScalingEvent(ctx, rule, expandAction, expanded, currentCount, redundancy)
+		if expanded < countToChange {
+			trace.finish(TraceOutcomeScaledUp, "redundancy=%.2f below min=%.2f, added %d of %d instances because schedulx capacity is unavailable", redundancy, float64(rule.MinRedundancy)/100, expanded, countToChange)
+			return nil
+		}
+		trace.finish(TraceOutcomeScaledUp, "redundancy=%.2f below min=%.2f, added %d instances", redundancy, float64(rule.MinRedundancy)/100, countToChange)
+	} else {
+		if countToChange <= 0 && currentCount > rule.MinInstanceCount {
+			trace.finish(TraceOutcomeSkipped, "redundancy=%.2f above max=%.2f but max_shrink_percent %.2f%% of %d instances rounds down to 0", redundancy, float64(rule.MaxRedundancy)/100, rule.MaxShrinkPercent, currentCount)
+			return nil
+		}
+		if countToChange <= 0 {
+			trace.finish(TraceOutcomeSkipped, "redundancy=%.2f above max=%.2f but already at min_instance_count %d", redundancy, float64(rule.MaxRedundancy)/100, rule.MinInstanceCount)
+			return nil
+		}
+		skippedBy, err := keeper.onScaleDecided(ctx, plugins, rule, ScaleDecision{Action: event.ActionScaleDown, Count: countToChange, CurrentCount: currentCount, Redundancy: redundancy})
+		if err != nil {
+			return err
+		}
+		if skippedBy != "" {
+			trace.finish(TraceOutcomeSkipped, "scale down of %d instances skipped by plugin %s", countToChange, skippedBy)
+			return nil
+		}
+		if requiresReview(rule, countToChange) {
+			return keeper.requestApproval(ctx, rule, event.ActionScaleDown, countToChange, currentCount, redundancy, trace)
+		}
+		err = keeper.shrinkService(ctx, rule, countToChange, idempotencyKey(serviceName, clusterName, countToChange, now.Unix()), trace)
+		if err != nil {
+			return fmt.Errorf("shrink service failed , %w", err)
+		}
+		keeper.publishScalingEvent(ctx, rule, event.ActionScaleDown, countToChange, currentCount, redundancy)
+		keeper.verifyShrinkLater(ctx, rule, countToChange, currentCount)
+		trace.finish(TraceOutcomeScaledDown, "redundancy=%.2f above max=%.2f, removed %d instances", redundancy, float64(rule.MaxRedundancy)/100, countToChange)
 	}
 	return nil
 }
+
+//loggerFor 带上 ctx 中 request id 的日志
+func (keeper *ScheduleXRedundancyKeeper) loggerFor(ctx context.Context) *zap.Logger {
+	if requestID := clients.RequestIDFromContext(ctx); requestID != "" {
+		return keeper.logger.With(zap.String("request_id", requestID))
+	}
+	return keeper.logger
+}
+
+//idempotencyKey 同一轮调度中同一服务集群的相同扩缩容请求使用相同的 key
+func idempotencyKey(serviceName, clusterName string, countToChange int, tickTimestamp int64) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s%s%d%d", serviceName, clusterName, countToChange, tickTimestamp)))
+	return hex.EncodeToString(sum[:])
+}
+
+//rateOfChangePercent 按时间排列的冗余度首尾变化百分比，首个值不大于0时返回0
+func rateOfChangePercent(values []float64) float64 {
+	if len(values) < 2 || values[0] <= 0 {
+		return 0
+	}
+	return (values[len(values)-1] - values[0]) / values[0] * 100
+}
+
+//estimateTotalQPS 根据冗余度估算集群总QPS，冗余度 = benchmark / 单机QPS
+func estimateTotalQPS(benchmark float64, instanceCount int, redundancy float64) float64 {
+	if redundancy <= 0 || math.IsInf(redundancy, 1) || math.IsNaN(redundancy) {
+		return 0
+	}
+	return benchmark * float64(instanceCount) / redundancy
+}
+
+func (keeper *ScheduleXRedundancyKeeper) publishScalingEvent(ctx context.Context, rule *model.PredictRule, action string, count, currentCount int, redundancy float64) {
+	cost := keeper.estimateCost(ctx, rule.ClusterName, action, count)
+	keeper.recordCost(rule.ServiceName, cost)
+	keeper.publish(&event.ScalingEvent{
+		RuleId:               rule.Id,
+		ServiceName:          rule.ServiceName,
+		ClusterName:          rule.ClusterName,
+		Action:               action,
+		Count:                count,
+		InstanceCount:        currentCount,
+		Redundancy:           redundancy,
+		EstimatedCostPerHour: cost,
+		Timestamp:            keeper.now().Unix(),
+	})
+}
@@ -0,0 +1,255 @@
+package redundancy_keeper
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/types"
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+)
+
+//Reload 热加载调度参数并清零运行统计，返回发生变化的参数
+func (keeper *ScheduleXRedundancyKeeper) Reload(param *config.Param) []string {
+	keeper.counters.reset()
+	var changes []string
+	keeper.lock.Lock()
+	durationChanged := keeper.ScheduleDuration != param.RunDuration.Duration
+	if durationChanged {
+		changes = append(changes, fmt.Sprintf("run_duration: %s -> %s", keeper.ScheduleDuration, param.RunDuration.Duration))
+		keeper.ScheduleDuration = param.RunDuration.Duration
+	}
+	if keeper.ruleConcurrency != param.RuleConcurrency {
+		changes = append(changes, fmt.Sprintf("rule_concurrency: %d -> %d", keeper.ruleConcurrency, param.RuleConcurrency))
+		keeper.ruleConcurrency = param.RuleConcurrency
+		// 正在运行的规则仍然释放旧的 channel，自动调整的并发数从新的配置重新开始
+		keeper.concurrencyLock = make(chan struct{}, param.RuleConcurrency)
+	}
+	if keeper.RuleConcurrencyPerService != param.RuleConcurrencyPerService {
+		changes = append(changes, fmt.Sprintf("rule_concurrency_per_service: %d -> %d", keeper.RuleConcurrencyPerService, param.RuleConcurrencyPerService))
+		keeper.RuleConcurrencyPerService = param.RuleConcurrencyPerService
+		// 正在运行的规则仍然释放旧的 channel
+		keeper.serviceLocks = &sync.Map{}
+	}
+	if keeper.MinimalSampleCount != param.MinimalSampleCount {
+		changes = append(changes, fmt.Sprintf("minimal_sample_count: %d -> %d", keeper.MinimalSampleCount, param.MinimalSampleCount))
+		keeper.MinimalSampleCount = param.MinimalSampleCount
+	}
+	if keeper.LookbackDuration != param.LookbackDuration.Duration {
+		changes = append(changes, fmt.Sprintf("lookback_duration: %s -> %s", keeper.LookbackDuration, param.LookbackDuration.Duration))
+		keeper.LookbackDuration = param.LookbackDuration.Duration
+	}
+	if keeper.MetricSendDuration != param.MetricSendDuration.Duration {
+		changes = append(changes, fmt.Sprintf("metric_send_duration: %s -> %s", keeper.MetricSendDuration, param.MetricSendDuration.Duration))
+		keeper.MetricSendDuration = param.MetricSendDuration.Duration
+	}
+	if keeper.OutlierRemovalMethod != param.OutlierRemovalMethod {
+		changes = append(changes, fmt.Sprintf("outlier_removal_method: %s -> %s", keeper.OutlierRemovalMethod, param.OutlierRemovalMethod))
+		keeper.OutlierRemovalMethod = param.OutlierRemovalMethod
+	}
+	if keeper.MetricQueryTimeout != param.MetricQueryTimeout.Duration {
+		changes = append(changes, fmt.Sprintf("metric_query_timeout: %s -> %s", keeper.MetricQueryTimeout, param.MetricQueryTimeout.Duration))
+		keeper.MetricQueryTimeout = param.MetricQueryTimeout.Duration
+	}
+	if perRuleDeadline := param.PerRuleDeadline.Duration; perRuleDeadline > 0 && keeper.PerRuleDeadline != perRuleDeadline {
+		changes = append(changes, fmt.Sprintf("per_rule_deadline: %s -> %s", keeper.PerRuleDeadline, perRuleDeadline))
+		keeper.PerRuleDeadline = perRuleDeadline
+	}
+	if keeper.ScheduleCacheTTL != param.ScheduleCacheTTL.Duration {
+		changes = append(changes, fmt.Sprintf("schedule_cache_ttl: %s -> %s", keeper.ScheduleCacheTTL, param.ScheduleCacheTTL.Duration))
+		keeper.ScheduleCacheTTL = param.ScheduleCacheTTL.Duration
+	}
+	if keeper.QuotaCacheTTL != param.QuotaCacheTTL.Duration {
+		changes = append(changes, fmt.Sprintf("quota_cache_ttl: %s -> %s", keeper.QuotaCacheTTL, param.QuotaCacheTTL.Duration))
+		keeper.QuotaCacheTTL = param.QuotaCacheTTL.Duration
+	}
+	if keeper.LivenessThresholdMultiplier != param.LivenessThresholdMultiplier {
+		changes = append(changes, fmt.Sprintf("liveness_threshold_multiplier: %v -> %v", keeper.LivenessThresholdMultiplier, param.LivenessThresholdMultiplier))
+		keeper.LivenessThresholdMultiplier = param.LivenessThresholdMultiplier
+	}
+	if keeper.BackoffScheduleDuration != param.BackoffScheduleDuration {
+		changes = append(changes, fmt.Sprintf("backoff_schedule_duration: %v -> %v", keeper.BackoffScheduleDuration, param.BackoffScheduleDuration))
+		keeper.BackoffScheduleDuration = param.BackoffScheduleDuration
+		durationChanged = true
+	}
+	if keeper.MinScheduleDuration != param.MinScheduleDuration.Duration {
+		changes = append(changes, fmt.Sprintf("min_schedule_duration: %s -> %s", keeper.MinScheduleDuration, param.MinScheduleDuration.Duration))
+		keeper.MinScheduleDuration = param.MinScheduleDuration.Duration
+		durationChanged = true
+	}
+	if keeper.MaxScheduleDuration != param.MaxScheduleDuration.Duration {
+		changes = append(changes, fmt.Sprintf("max_schedule_duration: %s -> %s", keeper.MaxScheduleDuration, param.MaxScheduleDuration.Duration))
+		keeper.MaxScheduleDuration = param.MaxScheduleDuration.Duration
+		durationChanged = true
+	}
+	if keeper.AdaptiveConcurrency != param.AdaptiveConcurrency {
+		changes = append(changes, fmt.Sprintf("adaptive_concurrency: %v -> %v", keeper.AdaptiveConcurrency, param.AdaptiveConcurrency))
+		keeper.AdaptiveConcurrency = param.AdaptiveConcurrency
+		if !keeper.AdaptiveConcurrency && cap(keeper.concurrencyLock) != keeper.ruleConcurrency {
+			keeper.concurrencyLock = make(chan struct{}, keeper.ruleConcurrency)
+		}
+	}
+	if keeper.AdaptiveConcurrencyMin != param.AdaptiveConcurrencyMin {
+		changes = append(changes, fmt.Sprintf("adaptive_concurrency_min: %d -> %d", keeper.AdaptiveConcurrencyMin, param.AdaptiveConcurrencyMin))
+		keeper.AdaptiveConcurrencyMin = param.AdaptiveConcurrencyMin
+	}
+	if keeper.AdaptiveConcurrencyMax != param.AdaptiveConcurrencyMax {
+		changes = append(changes, fmt.Sprintf("adaptive_concurrency_max: %d -> %d", keeper.AdaptiveConcurrencyMax, param.AdaptiveConcurrencyMax))
+		keeper.AdaptiveConcurrencyMax = param.AdaptiveConcurrencyMax
+	}
+	if keeper.ErrorThresholdForDisable != param.ErrorThresholdForDisable {
+		changes = append(changes, fmt.Sprintf("error_threshold_for_disable: %d -> %d", keeper.ErrorThresholdForDisable, param.ErrorThresholdForDisable))
+		keeper.ErrorThresholdForDisable = param.ErrorThresholdForDisable
+	}
+	if keeper.RequireWarmCache != param.RequireWarmCache {
+		changes = append(changes, fmt.Sprintf("require_warm_cache: %v -> %v", keeper.RequireWarmCache, param.RequireWarmCache))
+		keeper.RequireWarmCache = param.RequireWarmCache
+	}
+	if keeper.StickyShrinkEnabled != param.StickyShrinkEnabled {
+		changes = append(changes, fmt.Sprintf("sticky_shrink_enabled: %v -> %v", keeper.StickyShrinkEnabled, param.StickyShrinkEnabled))
+		keeper.StickyShrinkEnabled = param.StickyShrinkEnabled
+	}
+	if keeper.MaxWatchConnections != param.MaxWatchConnections {
+		changes = append(changes, fmt.Sprintf("max_watch_connections: %d -> %d", keeper.MaxWatchConnections, param.MaxWatchConnections))
+		keeper.MaxWatchConnections = param.MaxWatchConnections
+	}
+	if keeper.DebugLogMetricValues != param.DebugLogMetricValues {
+		changes = append(changes, fmt.Sprintf("debug_log_metric_values: %v -> %v", keeper.DebugLogMetricValues, param.DebugLogMetricValues))
+		keeper.DebugLogMetricValues = param.DebugLogMetricValues
+	}
+	keeper.lock.Unlock()
+
+	if durationChanged {
+		keeper.resetBackoff()
+		select {
+		case keeper.reloaded <- struct{}{}:
+		default:
+		}
+	}
+	return changes
+}
+
+//Param 当前生效的调度参数
+func (keeper *ScheduleXRedundancyKeeper) Param() *config.Param {
+	keeper.lock.RLock()
+	defer keeper.lock.RUnlock()
+	return &config.Param{
+		RunDuration:                 types.Duration{Duration: keeper.ScheduleDuration},
+		RuleConcurrency:             keeper.ruleConcurrency,
+		RuleConcurrencyPerService:   keeper.RuleConcurrencyPerService,
+		MinimalSampleCount:          keeper.MinimalSampleCount,
+		LookbackDuration:            types.Duration{Duration: keeper.LookbackDuration},
+		MetricSendDuration:          types.Duration{Duration: keeper.MetricSendDuration},
+		OutlierRemovalMethod:        keeper.OutlierRemovalMethod,
+		MetricQueryTimeout:          types.Duration{Duration: keeper.MetricQueryTimeout},
+		PerRuleDeadline:             types.Duration{Duration: keeper.PerRuleDeadline},
+		ScheduleCacheTTL:            types.Duration{Duration: keeper.ScheduleCacheTTL},
+		QuotaCacheTTL:               types.Duration{Duration: keeper.QuotaCacheTTL},
+		LivenessThresholdMultiplier: keeper.LivenessThresholdMultiplier,
+		BackoffScheduleDuration:     keeper.BackoffScheduleDuration,
+		MinScheduleDuration:         types.Duration{Duration: keeper.MinScheduleDuration},
+		MaxScheduleDuration:         types.Duration{Duration: keeper.MaxScheduleDuration},
+		AdaptiveConcurrency:         keeper.AdaptiveConcurrency,
+		AdaptiveConcurrencyMin:      keeper.AdaptiveConcurrencyMin,
+		AdaptiveConcurrencyMax:      keeper.AdaptiveConcurrencyMax,
+		ErrorThresholdForDisable:    keeper.ErrorThresholdForDisable,
+		RequireWarmCache:            keeper.RequireWarmCache,
+		StickyShrinkEnabled:         keeper.StickyShrinkEnabled,
+		MaxWatchConnections:         keeper.MaxWatchConnections,
+		RunOnce:                     keeper.RunOnce,
+		DebugLogMetricValues:        keeper.DebugLogMetricValues,
+	}
+}
+
+//scheduleDuration 当前生效的调度周期，开启 BackoffScheduleDuration 时可能与配置不同
+func (keeper *ScheduleXRedundancyKeeper) scheduleDuration() time.Duration {
+	keeper.lock.RLock()
+	defer keeper.lock.RUnlock()
+	if keeper.backoff != nil {
+		return keeper.backoff.Duration()
+	}
+	return keeper.ScheduleDuration
+}
+
+//scheduleCacheTTL 服务集群调度状态的缓存时间，未配置时为调度周期的一半
+func (keeper *ScheduleXRedundancyKeeper) scheduleCacheTTL() time.Duration {
+	keeper.lock.RLock()
+	ttl := keeper.ScheduleCacheTTL
+	keeper.lock.RUnlock()
+	if ttl > 0 {
+		return ttl
+	}
+	return keeper.scheduleDuration() / 2
+}
+
+//quotaCacheTTL 云厂商剩余配额的缓存时间，未配置时为 consts.DefaultQuotaCacheTTL
+func (keeper *ScheduleXRedundancyKeeper) quotaCacheTTL() time.Duration {
+	keeper.lock.RLock()
+	defer keeper.lock.RUnlock()
+	if keeper.QuotaCacheTTL > 0 {
+		return keeper.QuotaCacheTTL
+	}
+	return consts.DefaultQuotaCacheTTL
+}
+
+//instanceCountCacheTTL 服务集群实例数的缓存时间，略短于调度周期，保证每轮调度最多查询一次 schedulx
+func (keeper *ScheduleXRedundancyKeeper) instanceCountCacheTTL() time.Duration {
+	return keeper.scheduleDuration() * 9 / 10
+}
+
+func (keeper *ScheduleXRedundancyKeeper) metricQueryTimeout() time.Duration {
+	keeper.lock.RLock()
+	defer keeper.lock.RUnlock()
+	return keeper.MetricQueryTimeout
+}
+
+//sampleWindow 回查时长、指标传输时间以及最少指标点数，未配置时使用默认值
+func (keeper *ScheduleXRedundancyKeeper) sampleWindow() (lookback, metricSend time.Duration, minimalSampleCount int) {
+	keeper.lock.RLock()
+	defer keeper.lock.RUnlock()
+	lookback, metricSend, minimalSampleCount = keeper.LookbackDuration, keeper.MetricSendDuration, keeper.MinimalSampleCount
+	if lookback <= 0 {
+		lookback = consts.DefaultLookbackDuration
+	}
+	if metricSend <= 0 {
+		metricSend = consts.DefaultMetricSendDuration
+	}
+	if minimalSampleCount <= 0 {
+		minimalSampleCount = consts.DefaultPredictMinCount
+	}
+	return lookback, metricSend, minimalSampleCount
+}
+
+func (keeper *ScheduleXRedundancyKeeper) outlierRemovalMethod() string {
+	keeper.lock.RLock()
+	defer keeper.lock.RUnlock()
+	return keeper.OutlierRemovalMethod
+}
+
+//Reload 热加载调度参数并清零运行统计，返回发生变化的参数
+func Reload(param *config.Param) ([]string, error) {
+	if redundancyKeeper == nil {
+		return nil, errors.New("redundancy keeper is not initialized")
+	}
+	if err := param.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid redundancy keeper param : %w", err)
+	}
+	changes := redundancyKeeper.Reload(param)
+	clients.SetScheduleCacheTTL(redundancyKeeper.scheduleCacheTTL())
+	clients.SetInstanceCountCacheTTL(redundancyKeeper.instanceCountCacheTTL())
+	clients.SetQuotaCacheTTL(redundancyKeeper.quotaCacheTTL())
+	clients.SetCacheStatsRefreshDuration(redundancyKeeper.scheduleDuration())
+	redundancyKeeper.metrics.ScheduleDuration.Set(redundancyKeeper.scheduleDuration().Seconds())
+	return changes, nil
+}
+
+//GetParam 当前生效的调度参数
+func GetParam() (*config.Param, error) {
+	if redundancyKeeper == nil {
+		return nil, errors.New("redundancy keeper is not initialized")
+	}
+	return redundancyKeeper.Param(), nil
+}
@@ -0,0 +1,183 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+)
+
+//reportQueryWindow 生成报告时单次查询指标的时间范围，按秒采样时不超过 Prometheus 单次查询 11000 个点的限制
+const reportQueryWindow = 3 * time.Hour
+
+//ErrRuleNotFound 服务集群没有扩缩容规则
+var ErrRuleNotFound = errors.New("rule not found")
+
+//RedundancyReport 服务集群一段时间内的冗余度报告，用于调整 min_redundancy/max_redundancy
+type RedundancyReport struct {
+	ServiceName string `json:"service_name"`
+	ClusterName string `json:"cluster_name"`
+	Start       int64  `json:"start"`
+	End         int64  `json:"end"`
+	//MinRedundancy/MaxRedundancy 规则当前的冗余度区间
+	MinRedundancy float64 `json:"min_redundancy"`
+	MaxRedundancy float64 `json:"max_redundancy"`
+	//AverageRedundancy 实际冗余度的平均值
+	AverageRedundancy float64 `json:"average_redundancy"`
+	//InBandSeconds 冗余度在区间内的时长
+	InBandSeconds int64 `json:"in_band_seconds"`
+	//OutOfBandSeconds 冗余度在区间外的时长
+	OutOfBandSeconds int64 `json:"out_of_band_seconds"`
+	//InstanceHours 根据扩缩容事件还原的实例数计算的实例小时数
+	InstanceHours float64 `json:"instance_hours"`
+	//ScaleActions 扩缩容次数
+	ScaleActions int `json:"scale_actions"`
+	//EstimatedCost 按 InstanceHours 估算的成本，未配置 CostEstimator 时为空
+	EstimatedCost *float64 `json:"estimated_cost,omitempty"`
+}
+
+//WithScalingEventLister 指定生成报告时查询扩缩容事件的方式，为空时从数据库查询
+func WithScalingEventLister(listScalingEvents func(serviceName, clusterName string, begin, end int64) ([]*model.ScalingEvent, error)) Option {
+	return func(keeper *ScheduleXRedundancyKeeper) {
+		if listScalingEvents != nil {
+			keeper.listScalingEvents = listScalingEvents
+		}
+	}
+}
+
+//GenerateRedundancyReport 根据 [start, end) 内的扩缩容事件和指标生成冗余度报告
+func (keeper *ScheduleXRedundancyKeeper) GenerateRedundancyReport(ctx context.Context, serviceName, clusterName string, start, end time.Time) (*RedundancyReport, error) {
+	if !start.Before(end) {
+		return nil, fmt.Errorf("start %s should be before end %s", start, end)
+	}
+	rule, err := keeper.findRule(serviceName, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	events, err := keeper.listScalingEvents(serviceName, clusterName, start.Unix(), end.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("list scaling events failed , %w", err)
+	}
+	report := &RedundancyReport{
+		ServiceName:   serviceName,
+		ClusterName:   clusterName,
+		Start:         start.Unix(),
+		End:           end.Unix(),
+		MinRedundancy: float64(rule.MinRedundancy) / 100,
+		MaxRedundancy: float64(rule.MaxRedundancy) / 100,
+		ScaleActions:  len(events),
+	}
+
+	initialCount := 0
+	if len(events) > 0 {
+		initialCount = events[0].InstanceCount
+	} else {
+		// 期间没有扩缩容，实例数与当前相同
+		initialCount, err = keeper.scalerFor(rule).GetServiceInstanceCount(ctx, serviceName, clusterName)
+		if err != nil {
+			return nil, fmt.Errorf("query service instance count failed , %w", err)
+		}
+	}
+	report.InstanceHours = instanceHours(initialCount, events, start.Unix(), end.Unix())
+
+	if err := keeper.summarizeRedundancy(ctx, rule, start, end, report); err != nil {
+		return nil, err
+	}
+
+	if keeper.costEstimator != nil {
+		costPerInstance, err := keeper.costEstimator.EstimateCostPerInstance(ctx, clusterName)
+		if err == nil {
+			cost := costPerInstance * report.InstanceHours
+			report.EstimatedCost = &cost
+		}
+	}
+	return report, nil
+}
+
+//findRule 查找服务集群的规则
+func (keeper *ScheduleXRedundancyKeeper) findRule(serviceName, clusterName string) (*model.PredictRule, error) {
+	rules, err := keeper.listRules()
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if rule.ServiceName == serviceName && rule.ClusterName == clusterName {
+			return rule, nil
+		}
+	}
+	return nil, fmt.Errorf("%w for %s/%s", ErrRuleNotFound, serviceName, clusterName)
+}
+
+//summarizeRedundancy 分段查询实际冗余度，计算平均值以及在区间内外的时长
+func (keeper *ScheduleXRedundancyKeeper) summarizeRedundancy(ctx context.Context, rule *model.PredictRule, start, end time.Time, report *RedundancyReport) error {
+	var timestamps []int64
+	var values []float64
+	for begin := start; begin.Before(end); begin = begin.Add(reportQueryWindow) {
+		windowEnd := begin.Add(reportQueryWindow)
+		if windowEnd.After(end) {
+			windowEnd = end
+		}
+		series, err := keeper.queryRedundancy(ctx, rule, begin.Unix(), windowEnd.Unix())
+		if err != nil {
+			return fmt.Errorf("query redundancy failed , %w", err)
+		}
+		for _, cluster := range series.Clusters {
+			if cluster.ClusterName != rule.ClusterName {
+				continue
+			}
+			timestamps = append(timestamps, cluster.Timestamps...)
+			values = append(values, cluster.Values...)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	var sum float64
+	for i, value := range values {
+		sum += value
+		// 每个点代表到下一个点之前的时间，最后一个点沿用前一个间隔
+		var duration int64
+		if i+1 < len(timestamps) {
+			duration = timestamps[i+1] - timestamps[i]
+		} else if i > 0 {
+			duration = timestamps[i] - timestamps[i-1]
+		}
+		if value > report.MinRedundancy && value < report.MaxRedundancy {
+			report.InBandSeconds += duration
+		} else {
+			report.OutOfBandSeconds += duration
+		}
+	}
+	report.AverageRedundancy = sum / float64(len(values))
+	return nil
+}
+
+//instanceHours 根据扩缩容事件还原 [begin, end) 内的实例数并计算实例小时数
+func instanceHours(initialCount int, events []*model.ScalingEvent, begin, end int64) float64 {
+	count := initialCount
+	cursor := begin
+	var instanceSeconds int64
+	for _, e := range events {
+		instanceSeconds += int64(count) * (e.Timestamp - cursor)
+		cursor = e.Timestamp
+		if e.Action == event.ActionScaleDown {
+			count = e.InstanceCount - e.Count
+		} else {
+			count = e.InstanceCount + e.Count
+		}
+	}
+	instanceSeconds += int64(count) * (end - cursor)
+	return float64(instanceSeconds) / 3600
+}
+
+//GenerateRedundancyReport 根据 [start, end) 内的扩缩容事件和指标生成冗余度报告
+func GenerateRedundancyReport(serviceName, clusterName string, start, end time.Time) (*RedundancyReport, error) {
+	if redundancyKeeper == nil {
+		return nil, errors.New("redundancy keeper is not initialized")
+	}
+	return redundancyKeeper.GenerateRedundancyReport(context.Background(), serviceName, clusterName, start, end)
+}
@@ -0,0 +1,94 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//stepRedundancyBackend 每10分钟一个点，switchAt 之前冗余度为2，之后为1
+type stepRedundancyBackend struct {
+	switchAt int64
+}
+
+func (backend stepRedundancyBackend) QueryRedundancy(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+	cluster := &service.ClusterRedundancySeries{ClusterName: clusterName}
+	for timestamp := begin; timestamp < end; timestamp += 600 {
+		cluster.Timestamps = append(cluster.Timestamps, timestamp)
+		if timestamp < backend.switchAt {
+			cluster.Values = append(cluster.Values, 2)
+		} else {
+			cluster.Values = append(cluster.Values, 1)
+		}
+	}
+	return &service.RedundancySeries{ServiceName: serviceName, MetricName: metricName, Clusters: []*service.ClusterRedundancySeries{cluster}}, nil
+}
+
+func (backend stepRedundancyBackend) QueryRawMetric(ctx context.Context, serviceName, clusterName, metricName string, start, end int64, step int) ([]float64, error) {
+	return nil, service.ErrRawMetricUnsupported
+}
+
+var _ = ginkgo.Describe("GenerateRedundancyReport", func() {
+	start := time.Unix(1640000000, 0)
+	end := start.Add(6 * time.Hour)
+	rule := &model.PredictRule{
+		Id:            400,
+		ServiceName:   "report",
+		ClusterName:   "default",
+		MetricName:    "qps",
+		BenchmarkQps:  100,
+		MinRedundancy: 150,
+		MaxRedundancy: 250,
+		Status:        "enable",
+	}
+
+	initKeeper := func(events []*model.ScalingEvent) {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(&inFlightScaler{}),
+			redundancy_keeper.WithMetricBackend(stepRedundancyBackend{switchAt: start.Add(3 * time.Hour).Unix()}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+			redundancy_keeper.WithScalingEventLister(func(serviceName, clusterName string, begin, end int64) ([]*model.ScalingEvent, error) {
+				return events, nil
+			}),
+			redundancy_keeper.WithCostEstimator(redundancy_keeper.NewStaticCostEstimator(map[string]float64{"default": 0.5})),
+		)).To(gomega.Succeed())
+	}
+
+	ginkgo.It("reconstructs the instance timeline from scaling events", func() {
+		initKeeper([]*model.ScalingEvent{
+			{Action: event.ActionScaleUp, InstanceCount: 10, Count: 10, Timestamp: start.Add(time.Hour).Unix()},
+			{Action: event.ActionScaleDown, InstanceCount: 20, Count: 5, Timestamp: start.Add(4 * time.Hour).Unix()},
+		})
+		report, err := redundancy_keeper.GenerateRedundancyReport("report", "default", start, end)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(report.ScaleActions).To(gomega.Equal(2))
+		// 10台1小时 + 20台3小时 + 15台2小时
+		gomega.Expect(report.InstanceHours).To(gomega.BeNumerically("~", 100))
+		gomega.Expect(*report.EstimatedCost).To(gomega.BeNumerically("~", 50))
+		gomega.Expect(report.AverageRedundancy).To(gomega.BeNumerically("~", 1.5))
+		gomega.Expect(report.InBandSeconds).To(gomega.Equal(int64(3 * 3600)))
+		gomega.Expect(report.OutOfBandSeconds).To(gomega.Equal(int64(3 * 3600)))
+	})
+
+	ginkgo.It("uses the current instance count without scaling events", func() {
+		initKeeper(nil)
+		report, err := redundancy_keeper.GenerateRedundancyReport("report", "default", start, end)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(report.ScaleActions).To(gomega.Equal(0))
+		gomega.Expect(report.InstanceHours).To(gomega.BeNumerically("~", 60))
+	})
+
+	ginkgo.It("returns ErrRuleNotFound for clusters without a rule", func() {
+		initKeeper(nil)
+		_, err := redundancy_keeper.GenerateRedundancyReport("report", "other", start, end)
+		gomega.Expect(errors.Is(err, redundancy_keeper.ErrRuleNotFound)).To(gomega.BeTrue())
+	})
+})
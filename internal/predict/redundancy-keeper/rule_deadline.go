@@ -0,0 +1,29 @@
+package redundancy_keeper
+
+import (
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+)
+
+//ruleDeadline 规则一次执行的最长时间：use_expand_and_wait 的规则加上等待实例就绪的时间，
+//use_gradual_expand 的规则加上扩容到 max_instance_count 所需批次的间隔，避免正常的等待被取消
+func ruleDeadline(rule *model.PredictRule, perRuleDeadline time.Duration) time.Duration {
+	deadline := perRuleDeadline
+	if rule.UseExpandAndWait {
+		readinessTimeout := time.Duration(rule.ReadinessTimeoutSeconds) * time.Second
+		if readinessTimeout <= 0 {
+			readinessTimeout = consts.DefaultReadinessTimeout
+		}
+		deadline += readinessTimeout
+	}
+	if rule.UseGradualExpand {
+		batches := 1
+		if rule.GradualBatchSize > 0 && rule.MaxInstanceCount > rule.GradualBatchSize {
+			batches = (rule.MaxInstanceCount + rule.GradualBatchSize - 1) / rule.GradualBatchSize
+		}
+		deadline += time.Duration(batches) * consts.GradualExpandBatchInterval
+	}
+	return deadline
+}
@@ -0,0 +1,58 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/types"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//hangingScaler 查询实例数时一直不返回，直到 ctx 结束
+type hangingScaler struct {
+	inFlightScaler
+}
+
+func (scaler *hangingScaler) GetServiceInstanceCount(ctx context.Context, serviceName, clusterName string) (int, error) {
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+var _ = ginkgo.Describe("PerRuleDeadline", func() {
+	ginkgo.It("cancels the blocking calls of a rule after the deadline", func() {
+		rules := []*model.PredictRule{
+			{Id: 4340, ServiceName: "gf.cudgx.hang", ClusterName: "default", MetricName: "qps", BenchmarkQps: 100, MinRedundancy: 150, MaxRedundancy: 250,
+				MinInstanceCount: 1, MaxInstanceCount: 20, ExecuteRatio: 100, Status: consts.RuleStatusEnable},
+			{Id: 4341, ServiceName: "gf.cudgx.hang", ClusterName: "canary", MetricName: "qps", BenchmarkQps: 100, MinRedundancy: 150, MaxRedundancy: 250,
+				MinInstanceCount: 1, MaxInstanceCount: 20, ExecuteRatio: 100, Status: consts.RuleStatusEnable},
+		}
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true,
+			PerRuleDeadline: types.Duration{Duration: 100 * time.Millisecond}},
+			redundancy_keeper.WithScaler(&hangingScaler{}),
+			redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+			redundancy_keeper.WithRuleErrorStore(newMemoryRuleErrorStore()),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return rules, nil }),
+		)).To(gomega.Succeed())
+
+		// 卡住的规则超时后释放并发数，第二条规则不会一直等待
+		begin := time.Now()
+		summary := redundancy_keeper.Start(context.Background())
+		gomega.Expect(time.Since(begin)).To(gomega.BeNumerically("<", 2*time.Second))
+		gomega.Expect(summary.RulesErrored).To(gomega.Equal(2))
+		explain, err := redundancy_keeper.Explain(rules[1].Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(explain.Reason).To(gomega.ContainSubstring("context deadline exceeded"))
+	})
+
+	ginkgo.It("defaults to 30s", func() {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true})).To(gomega.Succeed())
+		param, err := redundancy_keeper.GetParam()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(param.PerRuleDeadline.Duration).To(gomega.Equal(consts.DefaultPerRuleDeadline))
+	})
+})
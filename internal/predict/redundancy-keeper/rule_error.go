@@ -0,0 +1,74 @@
+package redundancy_keeper
+
+import (
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"go.uber.org/zap"
+)
+
+//RuleErrorStore 持久化规则的连续失败次数和状态
+type RuleErrorStore interface {
+	IncrementRuleErrorCount(ruleID int64, msg string) error
+	ResetRuleErrorCount(ruleID int64) error
+	UpdatePredictRuleStatusById(ruleID int64, status string) error
+}
+
+//modelRuleErrorStore 将连续失败次数保存在 predict_rules 表中
+type modelRuleErrorStore struct{}
+
+func (modelRuleErrorStore) IncrementRuleErrorCount(ruleID int64, msg string) error {
+	return model.IncrementRuleErrorCount(ruleID, msg)
+}
+
+func (modelRuleErrorStore) ResetRuleErrorCount(ruleID int64) error {
+	return model.ResetRuleErrorCount(ruleID)
+}
+
+func (modelRuleErrorStore) UpdatePredictRuleStatusById(ruleID int64, status string) error {
+	return model.UpdatePredictRuleStatusById(ruleID, status)
+}
+
+//WithRuleErrorStore 指定保存规则连续失败次数的 RuleErrorStore，为空时保存到数据库
+func WithRuleErrorStore(store RuleErrorStore) Option {
+	return func(keeper *ScheduleXRedundancyKeeper) {
+		if store != nil {
+			keeper.ruleErrors = store
+		}
+	}
+}
+
+//RecordRuleError 记录规则本轮执行结果，err 为空时清零连续失败次数；
+//连续失败次数达到 ErrorThresholdForDisable 时将规则置为 error 状态，不再参与调度
+func (keeper *ScheduleXRedundancyKeeper) RecordRuleError(rule *model.PredictRule, err error) error {
+	if err == nil {
+		if rule.ErrorCount == 0 {
+			return nil
+		}
+		if resetErr := keeper.ruleErrors.ResetRuleErrorCount(rule.Id); resetErr != nil {
+			return resetErr
+		}
+		keeper.publishErrorCount(rule.Id, 0)
+		return nil
+	}
+	if recordErr := keeper.ruleErrors.IncrementRuleErrorCount(rule.Id, err.Error()); recordErr != nil {
+		return recordErr
+	}
+	keeper.publishErrorCount(rule.Id, rule.ErrorCount+1)
+	threshold := keeper.errorThresholdForDisable()
+	if threshold <= 0 || rule.ErrorCount+1 < threshold {
+		return nil
+	}
+	keeper.logger.Warn("rule failed too many times, disable it", zap.Int64("rule_id", rule.Id), zap.String("service", rule.ServiceName),
+		zap.String("cluster", rule.ClusterName), zap.Int("error_count", rule.ErrorCount+1), zap.Error(err))
+	if err := keeper.ruleErrors.UpdatePredictRuleStatusById(rule.Id, consts.RuleStatusError); err != nil {
+		return err
+	}
+	keeper.PublishRuleStatus(&RuleStatus{RuleId: rule.Id, Status: consts.RuleStatusError, Timestamp: keeper.now().Unix()})
+	return nil
+}
+
+func (keeper *ScheduleXRedundancyKeeper) errorThresholdForDisable() int {
+	keeper.lock.RLock()
+	defer keeper.lock.RUnlock()
+	return keeper.ErrorThresholdForDisable
+}
@@ -0,0 +1,120 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//failingScaler 查询调度状态总是失败
+type failingScaler struct {
+	inFlightScaler
+}
+
+func (scaler *failingScaler) CanServiceSchedule(ctx context.Context, serviceName, clusterName string) (bool, error) {
+	return false, errors.New("service not found")
+}
+
+//memoryRuleErrorStore 在内存中记录连续失败次数
+type memoryRuleErrorStore struct {
+	lock     sync.Mutex
+	counts   map[int64]int
+	messages map[int64]string
+	statuses map[int64]string
+}
+
+func newMemoryRuleErrorStore() *memoryRuleErrorStore {
+	return &memoryRuleErrorStore{counts: map[int64]int{}, messages: map[int64]string{}, statuses: map[int64]string{}}
+}
+
+func (store *memoryRuleErrorStore) IncrementRuleErrorCount(ruleID int64, msg string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.counts[ruleID]++
+	store.messages[ruleID] = msg
+	return nil
+}
+
+func (store *memoryRuleErrorStore) ResetRuleErrorCount(ruleID int64) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.counts[ruleID] = 0
+	store.messages[ruleID] = ""
+	return nil
+}
+
+func (store *memoryRuleErrorStore) UpdatePredictRuleStatusById(ruleID int64, status string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.statuses[ruleID] = status
+	return nil
+}
+
+var _ = ginkgo.Describe("RecordRuleError", func() {
+	var store *memoryRuleErrorStore
+	var rule *model.PredictRule
+
+	ginkgo.BeforeEach(func() {
+		store = newMemoryRuleErrorStore()
+		rule = &model.PredictRule{
+			Id:               200,
+			ServiceName:      "broken",
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 50,
+			ExecuteRatio:     100,
+			Status:           consts.RuleStatusEnable,
+		}
+	})
+
+	run := func(scaler redundancy_keeper.Scaler) *redundancy_keeper.ScheduleSummary {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{
+			RuleConcurrency:          1,
+			MinimalSampleCount:       1,
+			ErrorThresholdForDisable: 3,
+			RunOnce:                  true,
+		},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+			redundancy_keeper.WithRuleErrorStore(store),
+		)).To(gomega.Succeed())
+		return redundancy_keeper.Start(context.Background())
+	}
+
+	ginkgo.It("records the error without disabling below the threshold", func() {
+		rule.ErrorCount = 1
+		summary := run(&failingScaler{})
+		gomega.Expect(summary.RulesErrored).To(gomega.Equal(1))
+		gomega.Expect(summary.ExitCode()).To(gomega.Equal(1))
+		gomega.Expect(store.counts[rule.Id]).To(gomega.Equal(1))
+		gomega.Expect(store.messages[rule.Id]).To(gomega.ContainSubstring("service not found"))
+		gomega.Expect(store.statuses).NotTo(gomega.HaveKey(rule.Id))
+	})
+
+	ginkgo.It("disables the rule once the threshold is reached", func() {
+		rule.ErrorCount = 2
+		run(&failingScaler{})
+		gomega.Expect(store.statuses).To(gomega.HaveKeyWithValue(rule.Id, consts.RuleStatusError))
+	})
+
+	ginkgo.It("resets the error count after a successful run", func() {
+		rule.ErrorCount = 2
+		store.counts[rule.Id] = 2
+		summary := run(&inFlightScaler{})
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(store.counts[rule.Id]).To(gomega.Equal(0))
+		gomega.Expect(store.statuses).NotTo(gomega.HaveKey(rule.Id))
+	})
+})
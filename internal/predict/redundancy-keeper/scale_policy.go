@@ -0,0 +1,88 @@
+package redundancy_keeper
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+)
+
+//scalePolicyState 一条规则在一个集群上的扩缩容策略状态
+type scalePolicyState struct {
+	//ema 冗余度的指数移动平均，observed 为 false 时还没有值
+	ema      float64
+	observed bool
+	//belowTicks/aboveTicks 冗余度连续低于 min_redundancy/高于 max_redundancy 的轮数
+	belowTicks int
+	aboveTicks int
+}
+
+//scalePolicyStates 规则id/集群 -> 扩缩容策略状态，仅保存在内存中
+type scalePolicyStates struct {
+	lock   sync.Mutex
+	states map[string]*scalePolicyState
+}
+
+//applyScalePolicy 按扩容或缩容方向的策略判断本轮是否扩缩容，返回用于计算实例数的冗余度，不执行时返回跳过原因。
+//缩容还需要冗余度连续 shrink_consecutive_ticks 轮高于 max_redundancy。
+//每轮都会更新指数移动平均；冗余度回到范围内或策略生效后清零连续轮数
+func (keeper *ScheduleXRedundancyKeeper) applyScalePolicy(rule *model.PredictRule, redundancy float64, withinRange bool, trace *RuleTrace) (float64, string) {
+	states := &keeper.scalePolicies
+	states.lock.Lock()
+	defer states.lock.Unlock()
+	key := strconv.FormatInt(rule.Id, 10) + "/" + rule.ClusterName
+	if states.states == nil {
+		states.states = make(map[string]*scalePolicyState)
+	}
+	state, ok := states.states[key]
+	if !ok {
+		state = &scalePolicyState{}
+		states.states[key] = state
+	}
+	if state.observed {
+		state.ema = consts.ScalePolicyEMAAlpha*redundancy + (1-consts.ScalePolicyEMAAlpha)*state.ema
+	} else {
+		state.ema, state.observed = redundancy, true
+	}
+	if withinRange {
+		state.belowTicks, state.aboveTicks = 0, 0
+		return redundancy, ""
+	}
+
+	scaleUp := int(redundancy*100) <= rule.MinRedundancy
+	policy, ticks := rule.ScaleDownPolicy, &state.aboveTicks
+	if scaleUp {
+		policy, ticks = rule.ScaleUpPolicy, &state.belowTicks
+		state.aboveTicks = 0
+	} else {
+		state.belowTicks = 0
+	}
+	effective := redundancy
+	if policy == consts.ScalePolicySmoothed {
+		trace.step("smoothed redundancy %.2f", state.ema)
+		smoothedScaleUp := int(state.ema*100) <= rule.MinRedundancy
+		if withinRedundancyRange(rule, state.ema) || smoothedScaleUp != scaleUp {
+			*ticks = 0
+			return redundancy, fmt.Sprintf("redundancy=%.2f out of range but smoothed redundancy %.2f is not", redundancy, state.ema)
+		}
+		effective = state.ema
+	}
+	required := 0
+	if policy == consts.ScalePolicyConsecutive {
+		required = rule.ConsecutiveTicksRequired
+	}
+	if !scaleUp && rule.ShrinkConsecutiveTicks > required {
+		// shrink_consecutive_ticks 只约束缩容，与 consecutive 策略同时配置时取较大的轮数
+		required = rule.ShrinkConsecutiveTicks
+	}
+	if required > 0 {
+		*ticks++
+		if *ticks < required {
+			return redundancy, fmt.Sprintf("redundancy=%.2f out of range for %d of %d consecutive ticks", redundancy, *ticks, required)
+		}
+		*ticks = 0
+	}
+	return effective, ""
+}
@@ -0,0 +1,127 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("ScalePolicy", func() {
+	var rule *model.PredictRule
+	var redundancy float64
+
+	tick := func(value float64) (*redundancy_keeper.ScheduleSummary, string) {
+		redundancy = value
+		summary := redundancy_keeper.Start(context.Background())
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		return summary, explain.Reason
+	}
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:                       1800,
+			ServiceName:              "policy",
+			ClusterName:              "default",
+			MetricName:               "qps",
+			BenchmarkQps:             100,
+			MinRedundancy:            150,
+			MaxRedundancy:            250,
+			MinInstanceCount:         1,
+			MaxInstanceCount:         40,
+			ExecuteRatio:             100,
+			ScaleUpPolicy:            consts.ScalePolicyConsecutive,
+			ScaleDownPolicy:          consts.ScalePolicySmoothed,
+			ConsecutiveTicksRequired: 3,
+			Status:                   consts.RuleStatusEnable,
+		}
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(&inFlightScaler{}),
+			redundancy_keeper.WithMetricBackend(service.MetricBackendFunc(func(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+				return &service.RedundancySeries{
+					ServiceName: serviceName,
+					MetricName:  metricName,
+					Clusters:    []*service.ClusterRedundancySeries{{ClusterName: clusterName, Timestamps: []int64{begin}, Values: []float64{redundancy}}},
+				}, nil
+			})),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+	})
+
+	ginkgo.It("scales up only after consecutive_ticks_required ticks below min_redundancy", func() {
+		summary, reason := tick(0.5)
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(0))
+		gomega.Expect(reason).To(gomega.Equal("skipped: redundancy=0.50 out of range for 1 of 3 consecutive ticks"))
+		_, reason = tick(0.5)
+		gomega.Expect(reason).To(gomega.Equal("skipped: redundancy=0.50 out of range for 2 of 3 consecutive ticks"))
+		summary, _ = tick(0.5)
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+
+		// 生效后重新计数
+		_, reason = tick(0.5)
+		gomega.Expect(reason).To(gomega.Equal("skipped: redundancy=0.50 out of range for 1 of 3 consecutive ticks"))
+	})
+
+	ginkgo.It("resets the consecutive ticks when redundancy re-enters the range", func() {
+		tick(0.5)
+		tick(0.5)
+		tick(2)
+		_, reason := tick(0.5)
+		gomega.Expect(reason).To(gomega.Equal("skipped: redundancy=0.50 out of range for 1 of 3 consecutive ticks"))
+	})
+
+	ginkgo.It("scales down only when the smoothed redundancy is above max_redundancy", func() {
+		tick(2)
+		summary, reason := tick(3)
+		gomega.Expect(summary.RulesScaledDown).To(gomega.Equal(0))
+		gomega.Expect(reason).To(gomega.Equal("skipped: redundancy=3.00 out of range but smoothed redundancy 2.30 is not"))
+		// 0.3*3+0.7*2.3=2.51
+		summary, reason = tick(3)
+		gomega.Expect(summary.RulesScaledDown).To(gomega.Equal(1))
+		gomega.Expect(reason).To(gomega.HavePrefix("scaled_down: redundancy=2.51 above max=2.50"))
+	})
+
+	ginkgo.It("shrinks on the first tick when shrink_consecutive_ticks is 1", func() {
+		rule.ScaleDownPolicy = ""
+		rule.ShrinkConsecutiveTicks = 1
+		summary, _ := tick(3)
+		gomega.Expect(summary.RulesScaledDown).To(gomega.Equal(1))
+	})
+
+	ginkgo.It("shrinks only after shrink_consecutive_ticks ticks above max_redundancy", func() {
+		rule.ScaleDownPolicy = ""
+		rule.ShrinkConsecutiveTicks = 3
+		summary, reason := tick(3)
+		gomega.Expect(summary.RulesScaledDown).To(gomega.Equal(0))
+		gomega.Expect(reason).To(gomega.Equal("skipped: redundancy=3.00 out of range for 1 of 3 consecutive ticks"))
+		_, reason = tick(3)
+		gomega.Expect(reason).To(gomega.Equal("skipped: redundancy=3.00 out of range for 2 of 3 consecutive ticks"))
+		summary, _ = tick(3)
+		gomega.Expect(summary.RulesScaledDown).To(gomega.Equal(1))
+
+		// 冗余度回到范围内后重新计数
+		tick(3)
+		tick(2)
+		_, reason = tick(3)
+		gomega.Expect(reason).To(gomega.Equal("skipped: redundancy=3.00 out of range for 1 of 3 consecutive ticks"))
+	})
+
+	ginkgo.It("does not delay scale up with shrink_consecutive_ticks", func() {
+		rule.ScaleUpPolicy = ""
+		rule.ShrinkConsecutiveTicks = 3
+		summary, _ := tick(0.5)
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+	})
+
+	ginkgo.It("scales immediately without a policy", func() {
+		rule.ScaleUpPolicy = ""
+		summary, _ := tick(0.5)
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+	})
+})
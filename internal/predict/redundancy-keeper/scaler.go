@@ -0,0 +1,70 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+)
+
+//Scaler 负责查询、变更服务集群实例数，schedulx 集群使用 schedulxScaler，k8s 集群使用 K8sScaler
+type Scaler interface {
+	CanServiceSchedule(ctx context.Context, serviceName, clusterName string) (bool, error)
+	GetServiceInstanceCount(ctx context.Context, serviceName, clusterName string) (int, error)
+	//GetServiceInstanceIps 运行中实例的内网 ip，用于 metric_scope 为 instance 的规则
+	GetServiceInstanceIps(ctx context.Context, serviceName, clusterName string) ([]string, error)
+	ExpandService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error
+	//ExpandServiceAndWait 扩容并等待实例数增加 count，ctx 结束前未就绪时返回 clients.ErrExpandNotReady
+	ExpandServiceAndWait(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error
+	GradualExpandService(ctx context.Context, serviceName, clusterName string, totalCount int, batchSize int, batchInterval time.Duration, idempotencyKey string) error
+	ShrinkService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error
+}
+
+//scalerFor 规则扩缩容使用的 Scaler，按规则的 cluster_type 选择
+func (keeper *ScheduleXRedundancyKeeper) scalerFor(rule *model.PredictRule) Scaler {
+	if scaler, ok := keeper.clusterTypeScalers[rule.ClusterType]; ok {
+		return scaler
+	}
+	return keeper.scaler
+}
+
+//batchScheduleChecker 支持一次查询多个服务集群调度状态的 Scaler
+type batchScheduleChecker interface {
+	BatchCanServiceSchedule(ctx context.Context, pairs []clients.ServiceClusterPair) (map[clients.ServiceClusterPair]bool, error)
+}
+
+//schedulxScaler 通过schedulx进行扩缩容
+type schedulxScaler struct{}
+
+func (schedulxScaler) CanServiceSchedule(ctx context.Context, serviceName, clusterName string) (bool, error) {
+	return clients.CanServiceScheduleShared(ctx, serviceName, clusterName)
+}
+
+func (schedulxScaler) BatchCanServiceSchedule(ctx context.Context, pairs []clients.ServiceClusterPair) (map[clients.ServiceClusterPair]bool, error) {
+	return clients.BatchCanServiceScheduleWithContext(ctx, pairs)
+}
+
+func (schedulxScaler) GetServiceInstanceCount(ctx context.Context, serviceName, clusterName string) (int, error) {
+	return clients.GetServiceInstanceCountWithContext(ctx, serviceName, clusterName)
+}
+
+func (schedulxScaler) GetServiceInstanceIps(ctx context.Context, serviceName, clusterName string) ([]string, error) {
+	return clients.GetServiceInstanceIpsWithContext(ctx, serviceName, clusterName)
+}
+
+func (schedulxScaler) ExpandService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	return clients.ExpandServiceWithContext(ctx, serviceName, clusterName, count, idempotencyKey)
+}
+
+func (schedulxScaler) ExpandServiceAndWait(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	return clients.ExpandServiceAndWait(ctx, serviceName, clusterName, count, nil, idempotencyKey)
+}
+
+func (schedulxScaler) GradualExpandService(ctx context.Context, serviceName, clusterName string, totalCount int, batchSize int, batchInterval time.Duration, idempotencyKey string) error {
+	return clients.GradualExpandService(ctx, serviceName, clusterName, totalCount, batchSize, batchInterval, idempotencyKey)
+}
+
+func (schedulxScaler) ShrinkService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	return clients.ShrinkServiceWithContext(ctx, serviceName, clusterName, count, idempotencyKey, clients.ShrinkOptions{})
+}
@@ -0,0 +1,50 @@
+package redundancy_keeper
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"go.uber.org/zap"
+)
+
+//maxInstancesGetter 能查询实例数硬上限的 Scaler，硬上限一般由云厂商配额决定
+type maxInstancesGetter interface {
+	GetServiceMaxInstances(ctx context.Context, serviceName, clusterName string) (int, error)
+}
+
+func (schedulxScaler) GetServiceMaxInstances(ctx context.Context, serviceName, clusterName string) (int, error) {
+	return clients.GetServiceMaxInstances(ctx, serviceName, clusterName)
+}
+
+//applyServiceMaxInstances 取 max_instance_count 和 schedulx 实例数硬上限中较小的作为本轮的最大实例数；
+//查询失败或没有硬上限时沿用规则的配置。multi_cluster_mode 和按流量比例扩缩容的规则跨多个集群，不使用单个集群的硬上限
+func (keeper *ScheduleXRedundancyKeeper) applyServiceMaxInstances(ctx context.Context, rule *model.PredictRule, trace *RuleTrace) *model.PredictRule {
+	if rule.MultiClusterMode || rule.TrafficSplitSource != "" {
+		return rule
+	}
+	getter, ok := keeper.scalerFor(rule).(maxInstancesGetter)
+	if !ok {
+		return rule
+	}
+	hardCap, err := getter.GetServiceMaxInstances(ctx, rule.ServiceName, rule.ClusterName)
+	if err != nil {
+		keeper.loggerFor(ctx).Warn("query schedulx max instances failed, use max_instance_count of the rule", zap.String("service", rule.ServiceName),
+			zap.String("cluster", rule.ClusterName), zap.Error(err))
+		trace.step("query schedulx max instances failed: %v", err)
+		return rule
+	}
+	if hardCap <= 0 || hardCap >= rule.MaxInstanceCount {
+		return rule
+	}
+	keeper.loggerFor(ctx).Warn("schedulx max instances is lower than max_instance_count, consider updating the rule", zap.String("service", rule.ServiceName),
+		zap.String("cluster", rule.ClusterName), zap.Int("schedulx_max_instances", hardCap), zap.Int("max_instance_count", rule.MaxInstanceCount))
+	capped := *rule
+	capped.MaxInstanceCount = hardCap
+	if capped.MinInstanceCount > hardCap {
+		capped.MinInstanceCount = hardCap
+	}
+	trace.step("schedulx max instances %d is lower than max_instance_count, max_instance_count %d -> %d, min_instance_count %d -> %d",
+		hardCap, rule.MaxInstanceCount, capped.MaxInstanceCount, rule.MinInstanceCount, capped.MinInstanceCount)
+	return &capped
+}
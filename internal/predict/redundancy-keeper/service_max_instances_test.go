@@ -0,0 +1,83 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//quotaScaler 返回固定的实例数硬上限，记录扩容的实例数
+type quotaScaler struct {
+	inFlightScaler
+	maxInstances int
+	err          error
+	expandCount  int
+}
+
+func (scaler *quotaScaler) ExpandService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	scaler.expandCount += count
+	return nil
+}
+
+func (scaler *quotaScaler) GetServiceMaxInstances(ctx context.Context, serviceName, clusterName string) (int, error) {
+	return scaler.maxInstances, scaler.err
+}
+
+var _ = ginkgo.Describe("ServiceMaxInstances", func() {
+	var rule *model.PredictRule
+	var scaler *quotaScaler
+
+	start := func() *redundancy_keeper.ScheduleSummary {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		return redundancy_keeper.Start(context.Background())
+	}
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:               1600,
+			ServiceName:      "quota",
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 100,
+			ExecuteRatio:     100,
+			Status:           consts.RuleStatusEnable,
+		}
+		scaler = &quotaScaler{}
+	})
+
+	ginkgo.It("expands up to the schedulx hard cap when it is lower than max_instance_count", func() {
+		scaler.maxInstances = 25
+		summary := start()
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(scaler.expandCount).To(gomega.Equal(15))
+
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(explain.Trace.Steps).To(gomega.ContainElement("schedulx max instances 25 is lower than max_instance_count, max_instance_count 100 -> 25, min_instance_count 1 -> 1"))
+	})
+
+	ginkgo.It("uses max_instance_count when there is no hard cap or the query fails", func() {
+		summary := start()
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(scaler.expandCount).To(gomega.Equal(30))
+
+		scaler = &quotaScaler{maxInstances: 25, err: errors.New("quota service unavailable")}
+		summary = start()
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(scaler.expandCount).To(gomega.Equal(30))
+	})
+})
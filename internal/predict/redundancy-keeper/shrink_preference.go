@@ -0,0 +1,39 @@
+package redundancy_keeper
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+)
+
+//preferredShrinker 支持指定缩容实例选择方式的 Scaler
+type preferredShrinker interface {
+	ShrinkServiceWithPreference(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey, preference string) error
+}
+
+func (scaler schedulxScaler) ShrinkServiceWithPreference(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey, preference string) error {
+	return scaler.ShrinkServiceWithOptions(ctx, serviceName, clusterName, count, idempotencyKey, clients.ShrinkOptions{Preference: preference})
+}
+
+//shrinkService 按规则的 shrink_preference 缩容，Scaler 不支持时由 Scaler 自己决定缩容哪些实例；
+//开启 StickyShrinkEnabled 时优先缩容最近扩容加入的实例
+func (keeper *ScheduleXRedundancyKeeper) shrinkService(ctx context.Context, rule *model.PredictRule, count int, idempotencyKey string, trace *RuleTrace) error {
+	preference := rule.ShrinkPreference
+	if preferred := keeper.stickyShrinkInstances(ctx, rule, count, trace); len(preferred) > 0 {
+		trace.step("sticky shrink prefers %d recently added instances", len(preferred))
+		shrinker := keeper.scalerFor(rule).(optionsShrinker)
+		return shrinker.ShrinkServiceWithOptions(ctx, rule.ServiceName, rule.ClusterName, count, idempotencyKey, clients.ShrinkOptions{Preference: preference, PreferredInstanceIDs: preferred})
+	}
+	if preference == "" || preference == consts.ShrinkPreferenceDefault {
+		return keeper.scalerFor(rule).ShrinkService(ctx, rule.ServiceName, rule.ClusterName, count, idempotencyKey)
+	}
+	shrinker, ok := keeper.scalerFor(rule).(preferredShrinker)
+	if !ok {
+		trace.step("scaler does not support shrink preference %s, use default", preference)
+		return keeper.scalerFor(rule).ShrinkService(ctx, rule.ServiceName, rule.ClusterName, count, idempotencyKey)
+	}
+	trace.step("shrink preference %s", preference)
+	return shrinker.ShrinkServiceWithPreference(ctx, rule.ServiceName, rule.ClusterName, count, idempotencyKey, preference)
+}
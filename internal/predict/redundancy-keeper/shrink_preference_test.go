@@ -0,0 +1,90 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//highRedundancyBackend 所有集群的冗余度都是5
+type highRedundancyBackend struct{}
+
+func (highRedundancyBackend) QueryRedundancy(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+	return &service.RedundancySeries{
+		ServiceName: serviceName,
+		MetricName:  metricName,
+		Clusters:    []*service.ClusterRedundancySeries{{ClusterName: clusterName, Timestamps: []int64{begin}, Values: []float64{5}}},
+	}, nil
+}
+
+func (highRedundancyBackend) QueryRawMetric(ctx context.Context, serviceName, clusterName, metricName string, start, end int64, step int) ([]float64, error) {
+	return nil, service.ErrRawMetricUnsupported
+}
+
+//preferenceScaler 记录缩容时指定的实例选择方式
+type preferenceScaler struct {
+	inFlightScaler
+	shrunk      int
+	preferences []string
+}
+
+func (scaler *preferenceScaler) ShrinkService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	scaler.shrunk++
+	return nil
+}
+
+func (scaler *preferenceScaler) ShrinkServiceWithPreference(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey, preference string) error {
+	scaler.preferences = append(scaler.preferences, preference)
+	return nil
+}
+
+var _ = ginkgo.Describe("ShrinkPreference", func() {
+	var rule *model.PredictRule
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:               900,
+			ServiceName:      "shrink",
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 50,
+			ExecuteRatio:     100,
+			Status:           consts.RuleStatusEnable,
+		}
+	})
+
+	run := func() *preferenceScaler {
+		scaler := &preferenceScaler{}
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricBackend(highRedundancyBackend{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		summary := redundancy_keeper.Start(context.Background())
+		gomega.Expect(summary.RulesScaledDown).To(gomega.Equal(1))
+		return scaler
+	}
+
+	ginkgo.It("lets the scaler choose by default", func() {
+		scaler := run()
+		gomega.Expect(scaler.shrunk).To(gomega.Equal(1))
+		gomega.Expect(scaler.preferences).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("passes the rule preference to the scaler", func() {
+		rule.ShrinkPreference = consts.ShrinkPreferenceLowestQps
+		scaler := run()
+		gomega.Expect(scaler.shrunk).To(gomega.Equal(0))
+		gomega.Expect(scaler.preferences).To(gomega.Equal([]string{consts.ShrinkPreferenceLowestQps}))
+	})
+})
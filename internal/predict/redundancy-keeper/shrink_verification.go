@@ -0,0 +1,70 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"go.uber.org/zap"
+)
+
+//ShrinkVerificationNotifier 缩容成功后实例数没有减少时发送通知
+type ShrinkVerificationNotifier interface {
+	NotifyShrinkVerificationFailed(ctx context.Context, e *event.ShrinkVerificationFailedEvent) error
+}
+
+//WithShrinkVerificationNotifier 增加一个缩容校验失败通知的接收方，可以多次指定
+func WithShrinkVerificationNotifier(notifier ShrinkVerificationNotifier) Option {
+	return func(keeper *ScheduleXRedundancyKeeper) {
+		if notifier != nil {
+			keeper.shrinkVerificationNotifiers = append(keeper.shrinkVerificationNotifiers, notifier)
+		}
+	}
+}
+
+//verifyShrinkLater 规则设置了 verify_shrink_after_seconds 时，等待后在后台查询实例数，不阻塞本轮调度；
+//ctx 结束不影响校验，只沿用其中的 request id
+func (keeper *ScheduleXRedundancyKeeper) verifyShrinkLater(ctx context.Context, rule *model.PredictRule, count, countBefore int) {
+	if rule.VerifyShrinkAfterSeconds <= 0 {
+		return
+	}
+	scaler := keeper.scalerFor(rule)
+	e := &event.ShrinkVerificationFailedEvent{
+		RuleId:              rule.Id,
+		ServiceName:         rule.ServiceName,
+		ClusterName:         rule.ClusterName,
+		Count:               count,
+		InstanceCountBefore: countBefore,
+	}
+	ctx = context.WithoutCancel(ctx)
+	time.AfterFunc(time.Duration(rule.VerifyShrinkAfterSeconds)*time.Second, func() {
+		verifyCtx, cancel := context.WithTimeout(ctx, consts.DefaultSchedulxTimeoutMs*time.Millisecond)
+		defer cancel()
+		keeper.verifyShrink(verifyCtx, scaler, e)
+	})
+}
+
+//verifyShrink 实例数减少不到缩容数的一半时打印告警日志、计数并发送通知
+func (keeper *ScheduleXRedundancyKeeper) verifyShrink(ctx context.Context, scaler Scaler, e *event.ShrinkVerificationFailedEvent) {
+	countAfter, err := scaler.GetServiceInstanceCount(ctx, e.ServiceName, e.ClusterName)
+	if err != nil {
+		keeper.loggerFor(ctx).Error("query instance count for shrink verification failed", zap.String("service", e.ServiceName),
+			zap.String("cluster", e.ClusterName), zap.Error(err))
+		return
+	}
+	if float64(e.InstanceCountBefore-countAfter) >= float64(e.Count)/2 {
+		return
+	}
+	e.InstanceCountAfter = countAfter
+	e.Timestamp = keeper.now().Unix()
+	keeper.loggerFor(ctx).Warn("instance count did not decrease after shrink", zap.String("service", e.ServiceName), zap.String("cluster", e.ClusterName),
+		zap.Int("count", e.Count), zap.Int("before", e.InstanceCountBefore), zap.Int("after", countAfter))
+	keeper.metrics.ShrinkVerificationFailures.WithLabelValues(e.ServiceName, e.ClusterName).Inc()
+	for _, notifier := range keeper.shrinkVerificationNotifiers {
+		if err := notifier.NotifyShrinkVerificationFailed(ctx, e); err != nil {
+			keeper.loggerFor(ctx).Error("notify shrink verification failed", zap.Int64("rule_id", e.RuleId), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+//protectedShrinkScaler 缩容总是返回成功，只实际释放 removable 个实例
+type protectedShrinkScaler struct {
+	inFlightScaler
+	lock      sync.Mutex
+	count     int
+	removable int
+	shrunk    int
+}
+
+func (scaler *protectedShrinkScaler) GetServiceInstanceCount(ctx context.Context, serviceName, clusterName string) (int, error) {
+	scaler.lock.Lock()
+	defer scaler.lock.Unlock()
+	return scaler.count, nil
+}
+
+func (scaler *protectedShrinkScaler) ShrinkService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	scaler.lock.Lock()
+	defer scaler.lock.Unlock()
+	scaler.shrunk += count
+	scaler.count -= min(count, scaler.removable)
+	return nil
+}
+
+type recordingShrinkVerificationNotifier struct {
+	lock   sync.Mutex
+	events []*event.ShrinkVerificationFailedEvent
+}
+
+func (notifier *recordingShrinkVerificationNotifier) NotifyShrinkVerificationFailed(ctx context.Context, e *event.ShrinkVerificationFailedEvent) error {
+	notifier.lock.Lock()
+	defer notifier.lock.Unlock()
+	notifier.events = append(notifier.events, e)
+	return nil
+}
+
+func (notifier *recordingShrinkVerificationNotifier) received() []*event.ShrinkVerificationFailedEvent {
+	notifier.lock.Lock()
+	defer notifier.lock.Unlock()
+	return notifier.events
+}
+
+var _ = ginkgo.Describe("ShrinkVerification", func() {
+	var rule *model.PredictRule
+	var scaler *protectedShrinkScaler
+	var notifier *recordingShrinkVerificationNotifier
+	var bundle *redundancy_keeper.MetricsBundle
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:                       2900,
+			ServiceName:              "gf.cudgx.protected",
+			ClusterName:              "default",
+			MetricName:               "qps",
+			BenchmarkQps:             100,
+			MinRedundancy:            150,
+			MaxRedundancy:            250,
+			MinInstanceCount:         1,
+			MaxInstanceCount:         20,
+			ExecuteRatio:             100,
+			VerifyShrinkAfterSeconds: 1,
+			Status:                   consts.RuleStatusEnable,
+		}
+		scaler = &protectedShrinkScaler{count: 10}
+		notifier = &recordingShrinkVerificationNotifier{}
+		var err error
+		bundle, err = redundancy_keeper.NewMetricsBundle(prometheus.NewRegistry())
+		gomega.Expect(err).To(gomega.BeNil())
+	})
+
+	start := func() {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricsBundle(bundle),
+			redundancy_keeper.WithShrinkVerificationNotifier(notifier),
+			redundancy_keeper.WithMetricBackend(service.MetricBackendFunc(func(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+				return &service.RedundancySeries{
+					ServiceName: serviceName,
+					MetricName:  metricName,
+					Clusters:    []*service.ClusterRedundancySeries{{ClusterName: clusterName, Timestamps: []int64{begin}, Values: []float64{4.0}}},
+				}, nil
+			})),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		summary := redundancy_keeper.Start(context.Background())
+		gomega.Expect(summary.RulesScaledDown).To(gomega.Equal(1))
+	}
+
+	ginkgo.It("alerts when the instance count does not decrease after shrink", func() {
+		start()
+		gomega.Eventually(notifier.received, 3*time.Second).Should(gomega.HaveLen(1))
+		e := notifier.received()[0]
+		gomega.Expect(e.RuleId).To(gomega.Equal(rule.Id))
+		gomega.Expect(e.Count).To(gomega.Equal(scaler.shrunk))
+		gomega.Expect(e.InstanceCountBefore).To(gomega.Equal(10))
+		gomega.Expect(e.InstanceCountAfter).To(gomega.Equal(10))
+		gomega.Expect(testutil.ToFloat64(bundle.ShrinkVerificationFailures.WithLabelValues(rule.ServiceName, rule.ClusterName))).To(gomega.Equal(float64(1)))
+	})
+
+	ginkgo.It("accepts a shrink that removed at least half of the instances", func() {
+		scaler.removable = 100
+		start()
+		gomega.Consistently(notifier.received, 1500*time.Millisecond).Should(gomega.BeEmpty())
+		gomega.Expect(testutil.ToFloat64(bundle.ShrinkVerificationFailures.WithLabelValues(rule.ServiceName, rule.ClusterName))).To(gomega.Equal(float64(0)))
+	})
+
+	ginkgo.It("does not verify when verify_shrink_after_seconds is 0", func() {
+		rule.VerifyShrinkAfterSeconds = 0
+		start()
+		gomega.Consistently(notifier.received, 1500*time.Millisecond).Should(gomega.BeEmpty())
+	})
+})
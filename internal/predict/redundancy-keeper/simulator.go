@@ -0,0 +1,251 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/types"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+)
+
+//TimeSeriesPoint 流量记录中的一个点
+type TimeSeriesPoint struct {
+	//Timestamp 时间戳，单位秒
+	Timestamp int64 `json:"timestamp"`
+	//QPS 服务总QPS
+	QPS float64 `json:"qps"`
+}
+
+//ScaleAction 模拟过程中发生的一次扩缩容
+type ScaleAction struct {
+	Timestamp     int64   `json:"timestamp"`
+	Action        string  `json:"action"`
+	Count         int     `json:"count"`
+	InstanceCount int     `json:"instance_count"`
+	Redundancy    float64 `json:"redundancy"`
+	//EstimatedCostPerHour 本次变更带来的每小时成本变化
+	EstimatedCostPerHour float64 `json:"estimated_cost_per_hour"`
+}
+
+//SimulationResult 模拟结果，Timestamps/InstanceCounts/Redundancies 按下标一一对应
+type SimulationResult struct {
+	Timestamps     []int64        `json:"timestamps"`
+	InstanceCounts []int          `json:"instance_counts"`
+	Redundancies   []float64      `json:"redundancies"`
+	Actions        []*ScaleAction `json:"actions"`
+	//Traces 每次调度的调试记录
+	Traces []*RuleTrace `json:"traces"`
+}
+
+//LoadTestSimulator 将记录的流量逐秒回放给 scheduleRule，使用内存中的实例数代替 schedulx，
+//用于演示规则在特定流量下的表现
+type LoadTestSimulator struct {
+	Rule  *model.PredictRule
+	Trace []TimeSeriesPoint
+	//Param 调度参数，为空时每60秒调度一次
+	Param *config.Param
+	//InitialInstanceCount 初始实例数
+	InitialInstanceCount int
+	//CostEstimator 估算每次扩缩容的成本，为空时不估算
+	CostEstimator CostEstimator
+}
+
+//Run 执行模拟
+func (simulator *LoadTestSimulator) Run() (*SimulationResult, error) {
+	if simulator.Rule == nil {
+		return nil, errors.New("rule is required")
+	}
+	if simulator.Rule.BenchmarkQps <= 0 {
+		return nil, errors.New("benchmark qps should be greater than 0")
+	}
+	if len(simulator.Trace) == 0 {
+		return nil, errors.New("trace is empty")
+	}
+	if simulator.InitialInstanceCount <= 0 {
+		return nil, errors.New("initial instance count should be greater than 0")
+	}
+
+	param := simulator.Param
+	if param == nil {
+		param = &config.Param{RunDuration: types.Duration{Duration: 60 * time.Second}}
+	}
+	if param.RunDuration.Duration < time.Second {
+		return nil, fmt.Errorf("run duration %s is too short", param.RunDuration.Duration)
+	}
+	tickSeconds := int64(param.RunDuration.Duration.Seconds())
+
+	trace := make([]TimeSeriesPoint, len(simulator.Trace))
+	copy(trace, simulator.Trace)
+	sort.Slice(trace, func(i, j int) bool { return trace[i].Timestamp < trace[j].Timestamp })
+
+	state := &simulatedScaler{
+		count:   simulator.InitialInstanceCount,
+		history: make(map[int64]int, len(trace)),
+	}
+	qpsByTimestamp := make(map[int64]float64, len(trace))
+	for _, point := range trace {
+		qpsByTimestamp[point.Timestamp] = point.QPS
+	}
+
+	result := &SimulationResult{}
+	var current int64
+	// 模拟的扩缩容不计入进程的指标
+	metrics, err := NewMetricsBundle(nil)
+	if err != nil {
+		return nil, err
+	}
+	keeper := newRedundancyKeeper(param, WithCostEstimator(simulator.CostEstimator), WithMetricsBundle(metrics))
+	keeper.scaler = state
+	keeper.clusterTypeScalers = nil
+	keeper.now = func() time.Time { return time.Unix(current, 0) }
+	keeper.metricBackend = &simulatedMetricBackend{scaler: state, qpsByTimestamp: qpsByTimestamp}
+	keeper.publish = func(e *event.ScalingEvent) {
+		result.Actions = append(result.Actions, &ScaleAction{
+			Timestamp:            e.Timestamp,
+			Action:               e.Action,
+			Count:                e.Count,
+			InstanceCount:        e.InstanceCount,
+			Redundancy:           e.Redundancy,
+			EstimatedCostPerHour: e.EstimatedCostPerHour,
+		})
+	}
+
+	// 模拟时假设需要人工确认的扩缩容都会被立即确认
+	rule := *simulator.Rule
+	rule.ReviewRequired = false
+	// 模拟的指标来自 trace，不查询规则指定的指标后端
+	rule.MetricBackendAlias = ""
+	start := trace[0].Timestamp
+	for _, point := range trace {
+		current = point.Timestamp
+		state.history[current] = state.count
+		if current > start && (current-start)%tickSeconds == 0 {
+			if err := keeper.scheduleRule(context.Background(), &rule); err != nil {
+				return nil, fmt.Errorf("simulate at %d failed , %w", current, err)
+			}
+			if explain, err := keeper.Explain(simulator.Rule.Id); err == nil {
+				result.Traces = append(result.Traces, explain.Trace)
+			}
+		}
+		result.Timestamps = append(result.Timestamps, current)
+		result.InstanceCounts = append(result.InstanceCounts, state.count)
+		result.Redundancies = append(result.Redundancies, redundancyOf(float64(simulator.Rule.BenchmarkQps), state.history[current], point.QPS))
+	}
+	return result, nil
+}
+
+//redundancyOf 冗余度 = benchmark * 实例数 / 总QPS
+func redundancyOf(benchmark float64, instanceCount int, qps float64) float64 {
+	if qps <= 0 {
+		return math.Inf(1)
+	}
+	return benchmark * float64(instanceCount) / qps
+}
+
+//simulatedScaler 使用内存中的实例数模拟扩缩容
+type simulatedScaler struct {
+	count int
+	//history 每秒的实例数
+	history map[int64]int
+}
+
+//simulatedMetricBackend 根据记录的流量和模拟的实例数计算冗余度
+type simulatedMetricBackend struct {
+	scaler         *simulatedScaler
+	qpsByTimestamp map[int64]float64
+}
+
+func (backend *simulatedMetricBackend) QueryRedundancy(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+	cluster := &service.ClusterRedundancySeries{ClusterName: clusterName}
+	for timestamp := begin; timestamp < end; timestamp++ {
+		qps, ok := backend.qpsByTimestamp[timestamp]
+		if !ok {
+			continue
+		}
+		count, ok := backend.scaler.history[timestamp]
+		if !ok {
+			continue
+		}
+		cluster.Timestamps = append(cluster.Timestamps, timestamp)
+		cluster.Values = append(cluster.Values, redundancyOf(benchmark, count, qps))
+	}
+	return &service.RedundancySeries{
+		ServiceName: serviceName,
+		MetricName:  metricName,
+		Clusters:    []*service.ClusterRedundancySeries{cluster},
+	}, nil
+}
+
+//QueryRawMetric 每个采样点的平均指标值 = 记录的流量 / 模拟的实例数，没有流量或实例数记录的采样点为 NaN
+func (backend *simulatedMetricBackend) QueryRawMetric(ctx context.Context, serviceName, clusterName, metricName string, start, end int64, step int) ([]float64, error) {
+	if step <= 0 {
+		step = 1
+	}
+	var values []float64
+	for timestamp := start; timestamp <= end; timestamp += int64(step) {
+		qps, ok := backend.qpsByTimestamp[timestamp]
+		count, counted := backend.scaler.history[timestamp]
+		if !ok || !counted {
+			values = append(values, math.NaN())
+			continue
+		}
+		values = append(values, qps/float64(count))
+	}
+	return values, nil
+}
+
+//QueryInstanceRedundancy 记录的流量是服务集群的总流量，与按服务查询结果相同
+func (backend *simulatedMetricBackend) QueryInstanceRedundancy(ctx context.Context, serviceName, clusterName, metricName string, instanceIps []string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+	return backend.QueryRedundancy(ctx, serviceName, clusterName, metricName, consts.MetricQueryModeRaw, benchmark, begin, end, trimmedSecond)
+}
+
+func (scaler *simulatedScaler) GetServiceInstanceIps(ctx context.Context, serviceName, clusterName string) ([]string, error) {
+	ips := make([]string, 0, scaler.count)
+	for i := 0; i < scaler.count; i++ {
+		ips = append(ips, fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+	}
+	return ips, nil
+}
+
+func (scaler *simulatedScaler) CanServiceSchedule(ctx context.Context, serviceName, clusterName string) (bool, error) {
+	return true, nil
+}
+
+func (scaler *simulatedScaler) GetServiceInstanceCount(ctx context.Context, serviceName, clusterName string) (int, error) {
+	return scaler.count, nil
+}
+
+func (scaler *simulatedScaler) ExpandService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	if count <= 0 {
+		return errors.New("实例数应大于0")
+	}
+	scaler.count += count
+	return nil
+}
+
+func (scaler *simulatedScaler) ExpandServiceAndWait(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	return scaler.ExpandService(ctx, serviceName, clusterName, count, idempotencyKey)
+}
+
+func (scaler *simulatedScaler) GradualExpandService(ctx context.Context, serviceName, clusterName string, totalCount int, batchSize int, batchInterval time.Duration, idempotencyKey string) error {
+	return scaler.ExpandService(ctx, serviceName, clusterName, totalCount, idempotencyKey)
+}
+
+func (scaler *simulatedScaler) ShrinkService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	if count <= 0 {
+		return errors.New("实例数应大于0")
+	}
+	if count > scaler.count {
+		return fmt.Errorf("cannot shrink %d instances from %d", count, scaler.count)
+	}
+	scaler.count -= count
+	return nil
+}
@@ -0,0 +1,372 @@
+package redundancy_keeper_test
+
+import (
+	"strings"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/types"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+func buildTrace(start int64, segments ...[2]float64) []redundancy_keeper.TimeSeriesPoint {
+	var trace []redundancy_keeper.TimeSeriesPoint
+	timestamp := start
+	for _, segment := range segments {
+		for i := 0; i < int(segment[0]); i++ {
+			trace = append(trace, redundancy_keeper.TimeSeriesPoint{Timestamp: timestamp, QPS: segment[1]})
+			timestamp++
+		}
+	}
+	return trace
+}
+
+var _ = ginkgo.Describe("LoadTestSimulator", func() {
+	var rule *model.PredictRule
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:               1,
+			ServiceName:      "sale",
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 5,
+			MaxInstanceCount: 50,
+			ExecuteRatio:     100,
+		}
+	})
+
+	ginkgo.It("expands during a flash sale spike and shrinks afterwards", func() {
+		simulator := &redundancy_keeper.LoadTestSimulator{
+			Rule:                 rule,
+			Trace:                buildTrace(1640000000, [2]float64{180, 500}, [2]float64{300, 2000}, [2]float64{300, 500}),
+			InitialInstanceCount: 10,
+		}
+		result, err := simulator.Run()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result.Timestamps).To(gomega.HaveLen(780))
+		gomega.Expect(result.InstanceCounts).To(gomega.HaveLen(780))
+		gomega.Expect(result.Redundancies).To(gomega.HaveLen(780))
+
+		// 高峰前不调度
+		gomega.Expect(result.InstanceCounts[179]).To(gomega.Equal(10))
+		// 高峰期间扩容到 40 台
+		gomega.Expect(result.InstanceCounts[479]).To(gomega.Equal(40))
+		gomega.Expect(result.Redundancies[479]).To(gomega.BeNumerically("~", 2.0))
+		// 高峰过后缩容
+		gomega.Expect(result.InstanceCounts[779]).To(gomega.Equal(10))
+
+		gomega.Expect(result.Actions).NotTo(gomega.BeEmpty())
+		gomega.Expect(result.Actions[0].Action).To(gomega.Equal(event.ActionScaleUp))
+		gomega.Expect(result.Actions[0].Count).To(gomega.Equal(30))
+		gomega.Expect(result.Actions[len(result.Actions)-1].Action).To(gomega.Equal(event.ActionScaleDown))
+	})
+
+	ginkgo.It("scales instance scoped rules on the same spike", func() {
+		instanceRule := *rule
+		instanceRule.MetricScope = consts.MetricScopeInstance
+		simulator := &redundancy_keeper.LoadTestSimulator{
+			Rule:                 &instanceRule,
+			Trace:                buildTrace(1640000000, [2]float64{180, 500}, [2]float64{300, 2000}),
+			InitialInstanceCount: 10,
+		}
+		result, err := simulator.Run()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result.InstanceCounts[479]).To(gomega.Equal(40))
+		gomega.Expect(result.Actions).NotTo(gomega.BeEmpty())
+		gomega.Expect(result.Actions[0].Action).To(gomega.Equal(event.ActionScaleUp))
+	})
+
+	ginkgo.It("never goes beyond max instance count", func() {
+		rule.MaxInstanceCount = 20
+		simulator := &redundancy_keeper.LoadTestSimulator{
+			Rule:                 rule,
+			Trace:                buildTrace(1640000000, [2]float64{120, 500}, [2]float64{300, 4000}),
+			InitialInstanceCount: 10,
+		}
+		result, err := simulator.Run()
+		gomega.Expect(err).To(gomega.BeNil())
+		for _, count := range result.InstanceCounts {
+			gomega.Expect(count).To(gomega.BeNumerically("<=", 20))
+		}
+		gomega.Expect(result.InstanceCounts[len(result.InstanceCounts)-1]).To(gomega.Equal(20))
+	})
+
+	ginkgo.It("rejects an empty trace", func() {
+		simulator := &redundancy_keeper.LoadTestSimulator{Rule: rule, InitialInstanceCount: 10}
+		_, err := simulator.Run()
+		gomega.Expect(err).NotTo(gomega.BeNil())
+	})
+})
+
+var _ = ginkgo.Describe("MinQPSThreshold", func() {
+	var rule *model.PredictRule
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:               2,
+			ServiceName:      "night",
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 50,
+			ExecuteRatio:     100,
+			MinQPSThreshold:  100,
+		}
+	})
+
+	ginkgo.It("skips scaling while total qps is below the threshold", func() {
+		simulator := &redundancy_keeper.LoadTestSimulator{
+			Rule:                 rule,
+			Trace:                buildTrace(1640000000, [2]float64{600, 20}),
+			InitialInstanceCount: 10,
+		}
+		result, err := simulator.Run()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result.Actions).To(gomega.BeEmpty())
+		gomega.Expect(result.InstanceCounts[len(result.InstanceCounts)-1]).To(gomega.Equal(10))
+	})
+
+	ginkgo.It("resumes scaling once total qps is above the threshold", func() {
+		simulator := &redundancy_keeper.LoadTestSimulator{
+			Rule:                 rule,
+			Trace:                buildTrace(1640000000, [2]float64{300, 20}, [2]float64{300, 200}),
+			InitialInstanceCount: 10,
+		}
+		result, err := simulator.Run()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result.InstanceCounts[299]).To(gomega.Equal(10))
+		gomega.Expect(result.Actions).NotTo(gomega.BeEmpty())
+		gomega.Expect(result.Actions[0].Timestamp).To(gomega.BeNumerically(">=", int64(1640000300)))
+		gomega.Expect(result.Actions[0].Action).To(gomega.Equal(event.ActionScaleDown))
+		gomega.Expect(result.InstanceCounts[len(result.InstanceCounts)-1]).To(gomega.BeNumerically("<", 10))
+	})
+
+	ginkgo.It("scales as usual when the threshold is disabled", func() {
+		rule.MinQPSThreshold = 0
+		simulator := &redundancy_keeper.LoadTestSimulator{
+			Rule:                 rule,
+			Trace:                buildTrace(1640000000, [2]float64{300, 20}),
+			InitialInstanceCount: 10,
+		}
+		result, err := simulator.Run()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result.Actions).NotTo(gomega.BeEmpty())
+	})
+})
+
+var _ = ginkgo.Describe("Cost estimate", func() {
+	rule := &model.PredictRule{
+		Id:               3,
+		ServiceName:      "cost",
+		ClusterName:      "default",
+		MetricName:       "qps",
+		BenchmarkQps:     100,
+		MinRedundancy:    150,
+		MaxRedundancy:    250,
+		MinInstanceCount: 5,
+		MaxInstanceCount: 50,
+		ExecuteRatio:     100,
+	}
+
+	ginkgo.It("records positive cost for scale up and negative for scale down", func() {
+		simulator := &redundancy_keeper.LoadTestSimulator{
+			Rule:                 rule,
+			Trace:                buildTrace(1640000000, [2]float64{600, 500}, [2]float64{600, 2000}, [2]float64{900, 500}),
+			InitialInstanceCount: 10,
+			CostEstimator:        redundancy_keeper.NewStaticCostEstimator(map[string]float64{"default": 0.5}),
+		}
+		result, err := simulator.Run()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result.Actions).NotTo(gomega.BeEmpty())
+		for _, action := range result.Actions {
+			expected := float64(action.Count) * 0.5
+			if action.Action == event.ActionScaleDown {
+				expected = -expected
+			}
+			gomega.Expect(action.EstimatedCostPerHour).To(gomega.Equal(expected))
+		}
+	})
+
+	ginkgo.It("reports zero cost for clusters without a configured cost", func() {
+		simulator := &redundancy_keeper.LoadTestSimulator{
+			Rule:                 rule,
+			Trace:                buildTrace(1640000000, [2]float64{600, 2000}),
+			InitialInstanceCount: 10,
+			CostEstimator:        redundancy_keeper.NewStaticCostEstimator(map[string]float64{"other": 0.5}),
+		}
+		result, err := simulator.Run()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result.Actions).NotTo(gomega.BeEmpty())
+		gomega.Expect(result.Actions[0].EstimatedCostPerHour).To(gomega.BeZero())
+	})
+})
+
+var _ = ginkgo.Describe("Explain", func() {
+	rule := &model.PredictRule{
+		Id:               4,
+		ServiceName:      "explain",
+		ClusterName:      "default",
+		MetricName:       "qps",
+		BenchmarkQps:     100,
+		MinRedundancy:    150,
+		MaxRedundancy:    250,
+		MinInstanceCount: 1,
+		MaxInstanceCount: 50,
+		ExecuteRatio:     100,
+	}
+
+	ginkgo.It("explains a scale up after a traffic spike", func() {
+		simulator := &redundancy_keeper.LoadTestSimulator{
+			Rule:                 rule,
+			Trace:                buildTrace(1640000000, [2]float64{600, 2000}),
+			InitialInstanceCount: 10,
+		}
+		result, err := simulator.Run()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result.Traces).NotTo(gomega.BeEmpty())
+		gomega.Expect(result.Traces[0].Outcome).To(gomega.Equal(redundancy_keeper.TraceOutcomeScaledUp))
+		gomega.Expect(result.Traces[0].Reason).To(gomega.HavePrefix("scaled_up: redundancy=0.50 below min=1.50"))
+		gomega.Expect(result.Traces[0].Steps).NotTo(gomega.BeEmpty())
+	})
+
+	ginkgo.It("explains a scale up that waited for readiness", func() {
+		waiting := *rule
+		waiting.UseExpandAndWait = true
+		simulator := &redundancy_keeper.LoadTestSimulator{
+			Rule:                 &waiting,
+			Trace:                buildTrace(1640000000, [2]float64{600, 2000}),
+			InitialInstanceCount: 10,
+		}
+		result, err := simulator.Run()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result.Actions).NotTo(gomega.BeEmpty())
+		gomega.Expect(result.Traces[0].Reason).To(gomega.HaveSuffix("and they are ready"))
+	})
+
+	ginkgo.It("explains a skip while redundancy is within range", func() {
+		simulator := &redundancy_keeper.LoadTestSimulator{
+			Rule:                 rule,
+			Trace:                buildTrace(1640000000, [2]float64{600, 500}),
+			InitialInstanceCount: 10,
+		}
+		result, err := simulator.Run()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result.Traces).NotTo(gomega.BeEmpty())
+		for _, trace := range result.Traces {
+			gomega.Expect(trace.Reason).To(gomega.HavePrefix("skipped: redundancy=2.00 within"))
+		}
+	})
+
+	ginkgo.It("explains a skip below the qps threshold", func() {
+		quiet := *rule
+		quiet.MinQPSThreshold = 100
+		simulator := &redundancy_keeper.LoadTestSimulator{
+			Rule:                 &quiet,
+			Trace:                buildTrace(1640000000, [2]float64{600, 20}),
+			InitialInstanceCount: 10,
+		}
+		result, err := simulator.Run()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result.Traces).NotTo(gomega.BeEmpty())
+		gomega.Expect(result.Traces[0].Reason).To(gomega.HavePrefix("skipped: total qps"))
+	})
+})
+
+var _ = ginkgo.Describe("MinimalSampleCount", func() {
+	rule := &model.PredictRule{
+		Id:               5,
+		ServiceName:      "samples",
+		ClusterName:      "default",
+		MetricName:       "qps",
+		BenchmarkQps:     100,
+		MinRedundancy:    150,
+		MaxRedundancy:    250,
+		MinInstanceCount: 1,
+		MaxInstanceCount: 50,
+		ExecuteRatio:     100,
+	}
+	// 回查35s、指标传输5s，每次调度只有30个指标点
+	param := func(minimalSampleCount int) *config.Param {
+		return &config.Param{
+			RunDuration:        types.Duration{Duration: time.Minute},
+			LookbackDuration:   types.Duration{Duration: 35 * time.Second},
+			MetricSendDuration: types.Duration{Duration: 5 * time.Second},
+			MinimalSampleCount: minimalSampleCount,
+		}
+	}
+
+	ginkgo.It("skips rules with fewer samples than MinimalSampleCount", func() {
+		simulator := &redundancy_keeper.LoadTestSimulator{
+			Rule:                 rule,
+			Trace:                buildTrace(1640000000, [2]float64{600, 2000}),
+			Param:                param(50),
+			InitialInstanceCount: 10,
+		}
+		result, err := simulator.Run()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result.Actions).To(gomega.BeEmpty())
+		gomega.Expect(result.Traces).NotTo(gomega.BeEmpty())
+		gomega.Expect(result.Traces[0].Reason).To(gomega.Equal("skipped: insufficient samples (30 of 50 required)"))
+	})
+
+	ginkgo.It("scales once the samples reach MinimalSampleCount", func() {
+		simulator := &redundancy_keeper.LoadTestSimulator{
+			Rule:                 rule,
+			Trace:                buildTrace(1640000000, [2]float64{600, 2000}),
+			Param:                param(30),
+			InitialInstanceCount: 10,
+		}
+		result, err := simulator.Run()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result.Actions).NotTo(gomega.BeEmpty())
+	})
+})
+
+var _ = ginkgo.Describe("MaxRateOfChangePercent", func() {
+	rule := &model.PredictRule{
+		Id:                     6,
+		ServiceName:            "flash",
+		ClusterName:            "default",
+		MetricName:             "qps",
+		BenchmarkQps:           100,
+		MinRedundancy:          150,
+		MaxRedundancy:          250,
+		MinInstanceCount:       1,
+		MaxInstanceCount:       50,
+		ExecuteRatio:           100,
+		MaxRateOfChangePercent: 50,
+	}
+
+	ginkgo.It("skips scaling while the lookback window catches a ramp up", func() {
+		simulator := &redundancy_keeper.LoadTestSimulator{
+			Rule:                 rule,
+			Trace:                buildTrace(1640000000, [2]float64{180, 500}, [2]float64{600, 2000}),
+			InitialInstanceCount: 10,
+		}
+		result, err := simulator.Run()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result.Traces).NotTo(gomega.BeEmpty())
+		var skipped int
+		for _, trace := range result.Traces {
+			if strings.HasPrefix(trace.Reason, "skipped: rate_of_change_too_high") {
+				skipped++
+			}
+		}
+		gomega.Expect(skipped).To(gomega.BeNumerically(">", 0))
+		// 窗口内全部是高峰流量后恢复扩容
+		gomega.Expect(result.Actions).NotTo(gomega.BeEmpty())
+		gomega.Expect(result.Actions[0].Action).To(gomega.Equal(event.ActionScaleUp))
+	})
+})
@@ -0,0 +1,103 @@
+package redundancy_keeper
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+//KeeperStats keeper 的运行统计，计数从启动或最近一次 Reload 开始
+type KeeperStats struct {
+	TotalTicks          int64 `json:"total_ticks"`
+	TotalRulesEvaluated int64 `json:"total_rules_evaluated"`
+	TotalScaleUps       int64 `json:"total_scale_ups"`
+	TotalScaleDowns     int64 `json:"total_scale_downs"`
+	//TotalErrors 规则执行失败和整轮调度失败的次数
+	TotalErrors  int64 `json:"total_errors"`
+	TotalSkipped int64 `json:"total_skipped"`
+	//CurrentlyRunningRules 正在执行 scheduleRule 的规则数
+	CurrentlyRunningRules int64 `json:"currently_running_rules"`
+	//RuleConcurrency 当前最多并行执行的规则数，开启 adaptive_concurrency 时随调度耗时变化
+	RuleConcurrency int `json:"rule_concurrency"`
+	//UptimeSeconds keeper 创建以来的秒数，Reload 不会重置
+	UptimeSeconds      int64 `json:"uptime_seconds"`
+	LastTickDurationMs int64 `json:"last_tick_duration_ms"`
+}
+
+//keeperCounters 使用 atomic 计数，读取时不需要加锁
+type keeperCounters struct {
+	totalTicks          atomic.Int64
+	totalRulesEvaluated atomic.Int64
+	totalScaleUps       atomic.Int64
+	totalScaleDowns     atomic.Int64
+	totalErrors         atomic.Int64
+	totalSkipped        atomic.Int64
+	runningRules        atomic.Int64
+	lastTickDurationMs  atomic.Int64
+}
+
+//recordRule 统计一条规则的执行结果，与 ScheduleSummary.add 的分类一致
+func (counters *keeperCounters) recordRule(trace *RuleTrace, err error) {
+	counters.totalRulesEvaluated.Add(1)
+	if err != nil {
+		counters.totalErrors.Add(1)
+		return
+	}
+	if trace == nil {
+		return
+	}
+	switch trace.Outcome {
+	case TraceOutcomeScaledUp:
+		counters.totalScaleUps.Add(1)
+	case TraceOutcomeScaledDown:
+		counters.totalScaleDowns.Add(1)
+	case TraceOutcomeFailed:
+		counters.totalErrors.Add(1)
+	case TraceOutcomeSkipped:
+		counters.totalSkipped.Add(1)
+	}
+}
+
+//recordTick 统计一轮调度
+func (counters *keeperCounters) recordTick(elapsed time.Duration, err error) {
+	counters.totalTicks.Add(1)
+	counters.lastTickDurationMs.Store(elapsed.Milliseconds())
+	if err != nil {
+		counters.totalErrors.Add(1)
+	}
+}
+
+//reset 清零累计计数，正在运行的规则数不变
+func (counters *keeperCounters) reset() {
+	counters.totalTicks.Store(0)
+	counters.totalRulesEvaluated.Store(0)
+	counters.totalScaleUps.Store(0)
+	counters.totalScaleDowns.Store(0)
+	counters.totalErrors.Store(0)
+	counters.totalSkipped.Store(0)
+	counters.lastTickDurationMs.Store(0)
+}
+
+//Stats 当前的运行统计
+func (keeper *ScheduleXRedundancyKeeper) Stats() KeeperStats {
+	return KeeperStats{
+		TotalTicks:            keeper.counters.totalTicks.Load(),
+		TotalRulesEvaluated:   keeper.counters.totalRulesEvaluated.Load(),
+		TotalScaleUps:         keeper.counters.totalScaleUps.Load(),
+		TotalScaleDowns:       keeper.counters.totalScaleDowns.Load(),
+		TotalErrors:           keeper.counters.totalErrors.Load(),
+		TotalSkipped:          keeper.counters.totalSkipped.Load(),
+		CurrentlyRunningRules: keeper.counters.runningRules.Load(),
+		RuleConcurrency:       keeper.ruleConcurrencyInUse(),
+		UptimeSeconds:         int64(keeper.now().Sub(keeper.startedAt).Seconds()),
+		LastTickDurationMs:    keeper.counters.lastTickDurationMs.Load(),
+	}
+}
+
+//GetStats keeper 当前的运行统计
+func GetStats() (KeeperStats, error) {
+	if redundancyKeeper == nil {
+		return KeeperStats{}, errors.New("redundancy keeper is not initialized")
+	}
+	return redundancyKeeper.Stats(), nil
+}
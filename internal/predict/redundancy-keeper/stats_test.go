@@ -0,0 +1,64 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("KeeperStats", func() {
+	var rules []*model.PredictRule
+	var listErr error
+
+	ginkgo.BeforeEach(func() {
+		listErr = nil
+		rules = []*model.PredictRule{
+			{Id: 1300, ServiceName: "stats", ClusterName: "default", MetricName: "qps", BenchmarkQps: 100, MinRedundancy: 150, MaxRedundancy: 250,
+				MinInstanceCount: 1, MaxInstanceCount: 50, ExecuteRatio: 100, Status: consts.RuleStatusEnable},
+			{Id: 1301, ServiceName: "stats", ClusterName: "uncalibrated", MetricName: "qps", Status: consts.RuleStatusEnable},
+		}
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(&inFlightScaler{}),
+			redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return rules, listErr }),
+		)).To(gomega.Succeed())
+	})
+
+	ginkgo.It("counts every tick and rule outcome", func() {
+		const ticks = 3
+		for i := 0; i < ticks; i++ {
+			redundancy_keeper.Start(context.Background())
+		}
+		listErr = errors.New("db is down")
+		redundancy_keeper.Start(context.Background())
+
+		stats, err := redundancy_keeper.GetStats()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(stats.TotalTicks).To(gomega.Equal(int64(ticks + 1)))
+		gomega.Expect(stats.TotalRulesEvaluated).To(gomega.Equal(int64(2 * ticks)))
+		gomega.Expect(stats.TotalScaleUps).To(gomega.Equal(int64(ticks)))
+		gomega.Expect(stats.TotalSkipped).To(gomega.Equal(int64(ticks)))
+		gomega.Expect(stats.TotalErrors).To(gomega.Equal(int64(1)))
+		gomega.Expect(stats.TotalScaleDowns).To(gomega.BeZero())
+		gomega.Expect(stats.CurrentlyRunningRules).To(gomega.BeZero())
+	})
+
+	ginkgo.It("resets the counters on reload", func() {
+		redundancy_keeper.Start(context.Background())
+		param, err := redundancy_keeper.GetParam()
+		gomega.Expect(err).To(gomega.BeNil())
+		_, err = redundancy_keeper.Reload(param)
+		gomega.Expect(err).To(gomega.BeNil())
+
+		stats, err := redundancy_keeper.GetStats()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(stats.TotalTicks).To(gomega.BeZero())
+		gomega.Expect(stats.TotalRulesEvaluated).To(gomega.BeZero())
+	})
+})
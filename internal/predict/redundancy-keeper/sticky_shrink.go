@@ -0,0 +1,148 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"github.com/galaxy-future/cudgx/internal/predict/query"
+)
+
+//instanceLister 能查询实例创建时间的 Scaler，StickyShrinkEnabled 需要
+type instanceLister interface {
+	GetServiceInstanceList(ctx context.Context, serviceName, clusterName string) ([]clients.InstanceInfo, error)
+}
+
+//optionsShrinker 支持指定优先缩容实例的 Scaler
+type optionsShrinker interface {
+	ShrinkServiceWithOptions(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string, opts clients.ShrinkOptions) error
+}
+
+func (schedulxScaler) GetServiceInstanceList(ctx context.Context, serviceName, clusterName string) ([]clients.InstanceInfo, error) {
+	return clients.GetServiceInstanceList(ctx, serviceName, clusterName)
+}
+
+func (schedulxScaler) ShrinkServiceWithOptions(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string, opts clients.ShrinkOptions) error {
+	if opts.Preference == consts.ShrinkPreferenceLowestQps && len(opts.InstanceIps) == 0 {
+		metrics, err := query.GetInstanceMetrics(serviceName, clusterName)
+		if err != nil {
+			return fmt.Errorf("query instance metrics failed , %w", err)
+		}
+		opts.InstanceIps = query.LowestQpsInstances(metrics, count)
+	}
+	return clients.ShrinkServiceWithContext(ctx, serviceName, clusterName, count, idempotencyKey, opts)
+}
+
+//expansionHistory 记录 keeper 扩容加入的实例。扩容接口不返回新实例，扩容时只记录时间，
+//缩容前查询实例列表，把该时间之后创建的实例补充到 expansionOrder
+type expansionHistory struct {
+	lock sync.Mutex
+	//pending 服务集群 -> 还没有补充新实例的最早一次扩容时间
+	pending map[string]time.Time
+	//expansionOrder 服务集群 -> 扩容加入且仍在运行的实例，按创建时间从早到晚排列
+	expansionOrder map[string][]clients.InstanceInfo
+}
+
+func expansionKey(serviceName, clusterName string) string {
+	return serviceName + "/" + clusterName
+}
+
+//recordExpansion 记录一次扩容，at 应早于扩容请求发出的时间
+func (history *expansionHistory) recordExpansion(serviceName, clusterName string, at time.Time) {
+	history.lock.Lock()
+	defer history.lock.Unlock()
+	if history.pending == nil {
+		history.pending = make(map[string]time.Time)
+	}
+	key := expansionKey(serviceName, clusterName)
+	if since, ok := history.pending[key]; ok && since.Before(at) {
+		return
+	}
+	history.pending[key] = at
+}
+
+//mostRecentlyAdded 根据当前运行的实例更新扩容记录，返回最近扩容加入的最多 count 个实例 id，最新的在前
+func (history *expansionHistory) mostRecentlyAdded(serviceName, clusterName string, running []clients.InstanceInfo, count int) []string {
+	history.lock.Lock()
+	defer history.lock.Unlock()
+	if history.expansionOrder == nil {
+		history.expansionOrder = make(map[string][]clients.InstanceInfo)
+	}
+	key := expansionKey(serviceName, clusterName)
+
+	tracked := make(map[string]bool, len(history.expansionOrder[key]))
+	for _, instance := range history.expansionOrder[key] {
+		tracked[instance.InstanceID] = true
+	}
+	runningIds := make(map[string]bool, len(running))
+	for _, instance := range running {
+		runningIds[instance.InstanceID] = true
+	}
+
+	// 已经缩容或被其它方式删除的实例不再保留
+	var order []clients.InstanceInfo
+	for _, instance := range history.expansionOrder[key] {
+		if runningIds[instance.InstanceID] {
+			order = append(order, instance)
+		}
+	}
+	if since, ok := history.pending[key]; ok {
+		for _, instance := range running {
+			if !tracked[instance.InstanceID] && !instance.CreatedAt.IsZero() && !instance.CreatedAt.Before(since) {
+				order = append(order, instance)
+			}
+		}
+		delete(history.pending, key)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return order[i].CreatedAt.Before(order[j].CreatedAt)
+	})
+	history.expansionOrder[key] = order
+
+	var ids []string
+	for i := len(order) - 1; i >= 0 && len(ids) < count; i-- {
+		ids = append(ids, order[i].InstanceID)
+	}
+	return ids
+}
+
+//recordExpansion 开启 StickyShrinkEnabled 时记录服务集群的一次扩容
+func (keeper *ScheduleXRedundancyKeeper) recordExpansion(rule *model.PredictRule, at time.Time) {
+	if !keeper.stickyShrinkEnabled() {
+		return
+	}
+	keeper.expansions.recordExpansion(rule.ServiceName, rule.ClusterName, at)
+}
+
+//stickyShrinkInstances 最近扩容加入的实例 id；未开启、Scaler 不支持或查询失败时返回空，由原有方式选择缩容实例
+func (keeper *ScheduleXRedundancyKeeper) stickyShrinkInstances(ctx context.Context, rule *model.PredictRule, count int, trace *RuleTrace) []string {
+	if !keeper.stickyShrinkEnabled() {
+		return nil
+	}
+	lister, ok := keeper.scalerFor(rule).(instanceLister)
+	if !ok {
+		trace.step("scaler does not support instance list, skip sticky shrink")
+		return nil
+	}
+	if _, ok := keeper.scalerFor(rule).(optionsShrinker); !ok {
+		trace.step("scaler does not support preferred instances, skip sticky shrink")
+		return nil
+	}
+	running, err := lister.GetServiceInstanceList(ctx, rule.ServiceName, rule.ClusterName)
+	if err != nil {
+		trace.step("query instance list failed, skip sticky shrink, %v", err)
+		return nil
+	}
+	return keeper.expansions.mostRecentlyAdded(rule.ServiceName, rule.ClusterName, running, count)
+}
+
+func (keeper *ScheduleXRedundancyKeeper) stickyShrinkEnabled() bool {
+	keeper.lock.RLock()
+	defer keeper.lock.RUnlock()
+	return keeper.StickyShrinkEnabled
+}
@@ -0,0 +1,111 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//stickyScaler 扩容时按当前时间创建实例，记录缩容时优先选择的实例
+type stickyScaler struct {
+	inFlightScaler
+	instances []clients.InstanceInfo
+	shrunk    int
+	preferred [][]string
+}
+
+func (scaler *stickyScaler) ExpandService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	for i := 0; i < count; i++ {
+		id := fmt.Sprintf("new-%d", len(scaler.instances))
+		scaler.instances = append(scaler.instances, clients.InstanceInfo{InstanceID: id, CreatedAt: time.Now().Add(time.Duration(i) * time.Second)})
+	}
+	return scaler.inFlightScaler.ExpandService(ctx, serviceName, clusterName, count, idempotencyKey)
+}
+
+func (scaler *stickyScaler) ShrinkService(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string) error {
+	scaler.shrunk++
+	return nil
+}
+
+func (scaler *stickyScaler) GetServiceInstanceList(ctx context.Context, serviceName, clusterName string) ([]clients.InstanceInfo, error) {
+	return scaler.instances, nil
+}
+
+func (scaler *stickyScaler) ShrinkServiceWithOptions(ctx context.Context, serviceName, clusterName string, count int, idempotencyKey string, opts clients.ShrinkOptions) error {
+	scaler.preferred = append(scaler.preferred, opts.PreferredInstanceIDs)
+	return nil
+}
+
+var _ = ginkgo.Describe("StickyShrink", func() {
+	var rule *model.PredictRule
+	var scaler *stickyScaler
+	var backend service.MetricBackend
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:               1000,
+			ServiceName:      "sticky",
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 12,
+			ExecuteRatio:     100,
+			Status:           consts.RuleStatusEnable,
+		}
+		scaler = &stickyScaler{}
+		for i := 0; i < 10; i++ {
+			scaler.instances = append(scaler.instances, clients.InstanceInfo{InstanceID: fmt.Sprintf("old-%d", i), CreatedAt: time.Now().Add(-time.Hour)})
+		}
+		backend = lowRedundancyBackend{}
+	})
+
+	initKeeper := func(enabled bool) {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true, StickyShrinkEnabled: enabled},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricBackend(service.MetricBackendFunc(func(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+				return backend.QueryRedundancy(ctx, serviceName, clusterName, metricName, mode, benchmark, begin, end, trimmedSecond)
+			})),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+	}
+
+	expandThenShrink := func() {
+		gomega.Expect(redundancy_keeper.Start(context.Background()).RulesScaledUp).To(gomega.Equal(1))
+		backend = highRedundancyBackend{}
+		gomega.Expect(redundancy_keeper.Start(context.Background()).RulesScaledDown).To(gomega.Equal(1))
+	}
+
+	ginkgo.It("prefers the instances added by the last expansion, newest first", func() {
+		initKeeper(true)
+		expandThenShrink()
+		gomega.Expect(scaler.shrunk).To(gomega.Equal(0))
+		gomega.Expect(scaler.preferred).To(gomega.Equal([][]string{{"new-11", "new-10"}}))
+	})
+
+	ginkgo.It("forgets instances that are no longer running", func() {
+		initKeeper(true)
+		expandThenShrink()
+		scaler.instances = scaler.instances[:11]
+		gomega.Expect(redundancy_keeper.Start(context.Background()).RulesScaledDown).To(gomega.Equal(1))
+		gomega.Expect(scaler.preferred).To(gomega.Equal([][]string{{"new-11", "new-10"}, {"new-10"}}))
+	})
+
+	ginkgo.It("lets the scaler choose when disabled", func() {
+		initKeeper(false)
+		expandThenShrink()
+		gomega.Expect(scaler.shrunk).To(gomega.Equal(1))
+		gomega.Expect(scaler.preferred).To(gomega.BeEmpty())
+	})
+})
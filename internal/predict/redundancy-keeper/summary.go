@@ -0,0 +1,45 @@
+package redundancy_keeper
+
+import "sync"
+
+//ScheduleSummary 一轮调度的统计，run_once 模式下退出前输出
+type ScheduleSummary struct {
+	RulesEvaluated  int `json:"rules_evaluated"`
+	RulesScaledUp   int `json:"rules_scaled_up"`
+	RulesScaledDown int `json:"rules_scaled_down"`
+	RulesErrored    int `json:"rules_errored"`
+	//Error 加载规则失败等导致整轮调度失败的原因
+	Error string `json:"error,omitempty"`
+
+	lock sync.Mutex
+}
+
+//add 统计一条规则的执行结果
+func (summary *ScheduleSummary) add(trace *RuleTrace, err error) {
+	summary.lock.Lock()
+	defer summary.lock.Unlock()
+	summary.RulesEvaluated++
+	if err != nil {
+		summary.RulesErrored++
+		return
+	}
+	if trace == nil {
+		return
+	}
+	switch trace.Outcome {
+	case TraceOutcomeScaledUp:
+		summary.RulesScaledUp++
+	case TraceOutcomeScaledDown:
+		summary.RulesScaledDown++
+	case TraceOutcomeFailed:
+		summary.RulesErrored++
+	}
+}
+
+//ExitCode 所有规则都执行成功时返回0，否则返回1
+func (summary *ScheduleSummary) ExitCode() int {
+	if summary.Error != "" || summary.RulesErrored > 0 {
+		return 1
+	}
+	return 0
+}
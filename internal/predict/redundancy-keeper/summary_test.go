@@ -0,0 +1,69 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("ScheduleSummary", func() {
+	table.DescribeTable("ExitCode",
+		func(summary *redundancy_keeper.ScheduleSummary, expected int) {
+			gomega.Expect(summary.ExitCode()).To(gomega.Equal(expected))
+		},
+		table.Entry("no errors", &redundancy_keeper.ScheduleSummary{RulesEvaluated: 3, RulesScaledUp: 1, RulesScaledDown: 1}, 0),
+		table.Entry("rule errors", &redundancy_keeper.ScheduleSummary{RulesEvaluated: 3, RulesScaledUp: 1, RulesErrored: 1}, 1),
+		table.Entry("nothing scheduled", &redundancy_keeper.ScheduleSummary{}, 0),
+		table.Entry("failed to load rules", &redundancy_keeper.ScheduleSummary{Error: "db is down"}, 1),
+	)
+
+	ginkgo.It("returns the summary of the round in run_once mode", func() {
+		newRule := func(id int64, serviceName string, maxInstanceCount int) *model.PredictRule {
+			return &model.PredictRule{
+				Id:               id,
+				ServiceName:      serviceName,
+				ClusterName:      "default",
+				MetricName:       "qps",
+				BenchmarkQps:     100,
+				MinRedundancy:    150,
+				MaxRedundancy:    250,
+				MinInstanceCount: 1,
+				MaxInstanceCount: maxInstanceCount,
+				ExecuteRatio:     100,
+				Status:           consts.RuleStatusEnable,
+			}
+		}
+		// 两条规则冗余度都低于下限，实例数已经达到上限的规则跳过
+		rules := []*model.PredictRule{newRule(3200, "summary.expand", 50), newRule(3201, "summary.full", 10)}
+		var listErr error
+		start := func() *redundancy_keeper.ScheduleSummary {
+			gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+				redundancy_keeper.WithScaler(&inFlightScaler{}),
+				redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+				redundancy_keeper.WithRuleErrorStore(newMemoryRuleErrorStore()),
+				redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return rules, listErr }),
+			)).To(gomega.Succeed())
+			return redundancy_keeper.Start(context.Background())
+		}
+
+		summary := start()
+		gomega.Expect(summary.RulesEvaluated).To(gomega.Equal(2))
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(summary.RulesScaledDown).To(gomega.Equal(0))
+		gomega.Expect(summary.RulesErrored).To(gomega.Equal(0))
+		gomega.Expect(summary.ExitCode()).To(gomega.Equal(0))
+
+		listErr = errors.New("db is down")
+		summary = start()
+		gomega.Expect(summary.RulesEvaluated).To(gomega.Equal(0))
+		gomega.Expect(summary.Error).To(gomega.ContainSubstring("db is down"))
+		gomega.Expect(summary.ExitCode()).To(gomega.Equal(1))
+	})
+})
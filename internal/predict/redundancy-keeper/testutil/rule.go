@@ -0,0 +1,28 @@
+//Package testutil 不依赖数据库、schedulx 和指标后端，在单元测试中检查规则配置的扩缩容行为
+package testutil
+
+import (
+	"errors"
+
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+)
+
+//TestRule 是单元测试规则配置的推荐方式：series 为按时间顺序排列的冗余度采样点，currentInstances 为当前实例数，
+//使用调度时相同的 redundancy_keeper.DecideScaleForSeries 检查 max_rate_of_change_percent、取中位数、检查 min_qps_threshold，
+//再比较冗余度阈值并按 execute_ratio 和实例数上下限计算变更数，没有任何副作用。
+//action 为 event.ActionScaleUp 或 event.ActionScaleDown，不需要调度时为空。
+//不覆盖 forecast_horizon_seconds、异常值去除和 recovery 等依赖调度参数或历史状态的逻辑
+func TestRule(rule *model.PredictRule, series []float64, currentInstances int) (action string, countToChange int, err error) {
+	if rule == nil {
+		return "", 0, errors.New("rule is required")
+	}
+	if len(series) == 0 {
+		return "", 0, errors.New("series is empty")
+	}
+	if currentInstances < 0 {
+		return "", 0, errors.New("current instances can not be negative")
+	}
+	action, countToChange = redundancy_keeper.DecideScaleForSeries(rule, series, currentInstances)
+	return action, countToChange, nil
+}
@@ -0,0 +1,99 @@
+package testutil_test
+
+import (
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper/testutil"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("TestRule", func() {
+	var rule *model.PredictRule
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 2,
+			MaxInstanceCount: 50,
+			ExecuteRatio:     100,
+		}
+	})
+
+	ginkgo.It("scales up to the middle redundancy when below min_redundancy", func() {
+		action, count, err := testutil.TestRule(rule, []float64{1.1, 0.9, 1.0}, 10)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(action).To(gomega.Equal(event.ActionScaleUp))
+		gomega.Expect(count).To(gomega.Equal(10))
+	})
+
+	ginkgo.It("scales down with execute_ratio and stops at min_instance_count", func() {
+		rule.ExecuteRatio = 50
+		action, count, err := testutil.TestRule(rule, []float64{4}, 10)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(action).To(gomega.Equal(event.ActionScaleDown))
+		gomega.Expect(count).To(gomega.Equal(2))
+
+		action, count, err = testutil.TestRule(rule, []float64{20}, 3)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(action).To(gomega.Equal(event.ActionScaleDown))
+		gomega.Expect(count).To(gomega.Equal(1))
+	})
+
+	ginkgo.It("does nothing within the redundancy range or at max_instance_count", func() {
+		action, count, err := testutil.TestRule(rule, []float64{2}, 10)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(action).To(gomega.BeEmpty())
+		gomega.Expect(count).To(gomega.Equal(0))
+
+		action, _, err = testutil.TestRule(rule, []float64{0.5}, 50)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(action).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("caps each change by max_expand_percent and max_shrink_percent", func() {
+		rule.MaxExpandPercent = 50
+		action, count, err := testutil.TestRule(rule, []float64{0.5}, 10)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(action).To(gomega.Equal(event.ActionScaleUp))
+		gomega.Expect(count).To(gomega.Equal(5))
+
+		rule.MaxShrinkPercent = 20
+		action, count, err = testutil.TestRule(rule, []float64{20}, 200)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(action).To(gomega.Equal(event.ActionScaleDown))
+		gomega.Expect(count).To(gomega.Equal(30))
+		action, count, err = testutil.TestRule(rule, []float64{20}, 100)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(action).To(gomega.Equal(event.ActionScaleDown))
+		gomega.Expect(count).To(gomega.Equal(20))
+
+		action, _, err = testutil.TestRule(rule, []float64{20}, 4)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(action).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("skips when the rate of change or the total qps is out of bounds", func() {
+		rule.MaxRateOfChangePercent = 50
+		action, _, err := testutil.TestRule(rule, []float64{1.0, 0.4}, 10)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(action).To(gomega.BeEmpty())
+
+		rule.MaxRateOfChangePercent = 0
+		rule.MinQPSThreshold = 1000
+		action, _, err = testutil.TestRule(rule, []float64{4}, 10)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(action).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("rejects invalid input", func() {
+		_, _, err := testutil.TestRule(nil, []float64{1}, 10)
+		gomega.Expect(err).NotTo(gomega.BeNil())
+		_, _, err = testutil.TestRule(rule, nil, 10)
+		gomega.Expect(err).NotTo(gomega.BeNil())
+		_, _, err = testutil.TestRule(rule, []float64{1}, -1)
+		gomega.Expect(err).NotTo(gomega.BeNil())
+	})
+})
@@ -0,0 +1,54 @@
+package redundancy_keeper
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//tracerName keeper 创建的 span 的 instrumentation 名称
+const tracerName = "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+
+//WithTracerProvider 指定 keeper 创建 span 使用的 TracerProvider，为空时使用 otel 的全局 TracerProvider
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(keeper *ScheduleXRedundancyKeeper) {
+		if provider != nil {
+			keeper.tracer = provider.Tracer(tracerName)
+		}
+	}
+}
+
+//startRuleSpan 为一次规则执行创建 scheduleRule span
+func (keeper *ScheduleXRedundancyKeeper) startRuleSpan(ctx context.Context, rule *model.PredictRule) (context.Context, trace.Span) {
+	tracer := keeper.tracer
+	if tracer == nil {
+		tracer = otel.Tracer(tracerName)
+	}
+	return tracer.Start(ctx, "scheduleRule", trace.WithAttributes(
+		attribute.Int64("cudgx.rule_id", rule.Id),
+		attribute.String("cudgx.service_name", rule.ServiceName),
+		attribute.String("cudgx.cluster_name", rule.ClusterName),
+		attribute.String("cudgx.metric_name", rule.MetricName),
+	))
+}
+
+//recordRuleTrace 把执行结果和冗余度记录到 span
+func recordRuleTrace(span trace.Span, ruleTrace *RuleTrace) {
+	span.SetAttributes(attribute.String("cudgx.outcome", ruleTrace.Outcome))
+	if ruleTrace.Redundancy != nil {
+		span.SetAttributes(attribute.Float64("cudgx.redundancy", *ruleTrace.Redundancy))
+	}
+}
+
+//endRuleSpan 规则执行失败时记录错误，结束 span
+func endRuleSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,75 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var _ = ginkgo.Describe("Tracing", func() {
+	var exporter *tracetest.InMemoryExporter
+	var rule *model.PredictRule
+
+	ginkgo.BeforeEach(func() {
+		exporter = tracetest.NewInMemoryExporter()
+		rule = &model.PredictRule{
+			Id:               1500,
+			ServiceName:      "traced",
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 100,
+			ExecuteRatio:     100,
+			Status:           consts.RuleStatusEnable,
+		}
+	})
+
+	start := func(scaler redundancy_keeper.Scaler) {
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithTracerProvider(provider),
+			redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+			redundancy_keeper.WithRuleErrorStore(newMemoryRuleErrorStore()),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		redundancy_keeper.Start(context.Background())
+	}
+
+	ginkgo.It("records a scheduleRule span with the rule and its outcome", func() {
+		start(&inFlightScaler{})
+		spans := exporter.GetSpans()
+		gomega.Expect(spans).To(gomega.HaveLen(1))
+		gomega.Expect(spans[0].Name).To(gomega.Equal("scheduleRule"))
+		gomega.Expect(spans[0].Attributes).To(gomega.ContainElements(
+			attribute.Int64("cudgx.rule_id", 1500),
+			attribute.String("cudgx.service_name", "traced"),
+			attribute.String("cudgx.cluster_name", "default"),
+			attribute.String("cudgx.metric_name", "qps"),
+			attribute.String("cudgx.outcome", "scaled_up"),
+			attribute.Float64("cudgx.redundancy", 0.5),
+		))
+		gomega.Expect(spans[0].Status.Code).To(gomega.Equal(codes.Unset))
+	})
+
+	ginkgo.It("marks the span as failed when the rule errors", func() {
+		start(&capacityScaler{capacity: 0})
+		spans := exporter.GetSpans()
+		gomega.Expect(spans).To(gomega.HaveLen(1))
+		gomega.Expect(spans[0].Attributes).To(gomega.ContainElement(attribute.String("cudgx.outcome", "failed")))
+		gomega.Expect(spans[0].Status.Code).To(gomega.Equal(codes.Error))
+		gomega.Expect(spans[0].Events).To(gomega.HaveLen(1))
+	})
+})
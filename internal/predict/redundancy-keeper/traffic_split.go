@@ -0,0 +1,162 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"github.com/galaxy-future/cudgx/internal/predict/query"
+	"go.uber.org/zap"
+)
+
+//trafficSplitGetter 支持查询蓝绿集群流量比例的 Scaler，设置了 traffic_split_source 的规则需要
+type trafficSplitGetter interface {
+	GetTrafficSplit(ctx context.Context, serviceName string) (map[string]float64, error)
+}
+
+func (schedulxScaler) GetTrafficSplit(ctx context.Context, serviceName string) (map[string]float64, error) {
+	return clients.GetTrafficSplit(ctx, serviceName)
+}
+
+//colorCluster 蓝绿部署中一个颜色集群的流量比例、实例数和冗余度
+type colorCluster struct {
+	color         string
+	rule          *model.PredictRule
+	percentage    float64
+	instanceCount int
+	redundancy    float64
+}
+
+//scheduleTrafficSplitRule 按实例数加权平均各颜色集群的冗余度，计算冗余度回到中间值需要的实例总数，
+//再按流量比例分配到各颜色集群；冗余度在范围内时实例总数不变，只按流量比例调整分布。
+//流量比例为0的集群交给发布流程处理，不扩缩容
+func (keeper *ScheduleXRedundancyKeeper) scheduleTrafficSplitRule(ctx, queryCtx context.Context, plugins []Plugin, rule *model.PredictRule, begin, end int64, now time.Time, trace *RuleTrace) error {
+	getter, ok := keeper.scalerFor(rule).(trafficSplitGetter)
+	if !ok {
+		return errors.New("scaler does not support traffic split scaling")
+	}
+	split, err := getter.GetTrafficSplit(ctx, rule.ServiceName)
+	if err != nil {
+		return fmt.Errorf("query traffic split failed , %w", err)
+	}
+	colors := make([]string, 0, len(split))
+	for color := range split {
+		colors = append(colors, color)
+	}
+	sort.Strings(colors)
+
+	var clusters []*colorCluster
+	currentCount := 0
+	var totalPercentage float64
+	for _, color := range colors {
+		colored := colorRule(rule, color)
+		count, err := keeper.scalerFor(rule).GetServiceInstanceCount(ctx, colored.ServiceName, colored.ClusterName)
+		if err != nil {
+			return fmt.Errorf("query service instance count failed , %w", err)
+		}
+		trace.step("cluster %s traffic %.2f%% with %d instances", colored.ClusterName, split[color], count)
+		clusters = append(clusters, &colorCluster{color: color, rule: colored, percentage: split[color], instanceCount: count})
+		currentCount += count
+		totalPercentage += split[color]
+	}
+	if err := ValidateInstanceCount(currentCount, rule); err != nil {
+		keeper.metrics.InstanceCountValidationFailures.WithLabelValues(rule.ServiceName, rule.ClusterName).Inc()
+		keeper.loggerFor(ctx).Warn("instance count is invalid, skip this round", zap.String("service", rule.ServiceName), zap.Int("clusters", len(clusters)), zap.Error(err))
+		trace.finish(TraceOutcomeSkipped, "%s", err)
+		return nil
+	}
+	if err := keeper.onInstanceCounted(ctx, plugins, rule, currentCount); err != nil {
+		return err
+	}
+
+	var weighted float64
+	for _, cluster := range clusters {
+		// 没有实例的集群没有指标，加权时权重也为0
+		if cluster.instanceCount == 0 {
+			continue
+		}
+		clusterCtx, clusterQueryCtx := ctx, queryCtx
+		if rule.ColorMetricLabel != "" {
+			clusterCtx = query.WithLabelFilter(ctx, rule.ColorMetricLabel, cluster.color)
+			clusterQueryCtx = query.WithLabelFilter(queryCtx, rule.ColorMetricLabel, cluster.color)
+		}
+		redundancy, skipReason, err := keeper.clusterRedundancy(clusterCtx, clusterQueryCtx, plugins, cluster.rule, begin, end, now)
+		if err != nil {
+			return err
+		}
+		if skipReason != "" {
+			trace.finish(TraceOutcomeSkipped, "cluster %s %s", cluster.rule.ClusterName, skipReason)
+			return nil
+		}
+		trace.step("cluster %s redundancy %.2f", cluster.rule.ClusterName, redundancy)
+		cluster.redundancy = redundancy
+		weighted += redundancy * float64(cluster.instanceCount)
+	}
+	redundancy := weighted / float64(currentCount)
+	trace.step("weighted redundancy %.2f", redundancy)
+	trace.Redundancy = &redundancy
+	keeper.checkHighRedundancy(ctx, rule, redundancy, currentCount, now, trace)
+
+	withinRange := withinRedundancyRange(rule, redundancy)
+	if err := keeper.onThresholdChecked(ctx, plugins, rule, redundancy, withinRange); err != nil {
+		return err
+	}
+	expectTotal := currentCount
+	if !withinRange {
+		expectTotal, _ = expectedInstanceChange(rule, redundancy, currentCount)
+	}
+	expectTotal = max(rule.MinInstanceCount, min(expectTotal, rule.MaxInstanceCount))
+	trace.step("expected total instance count %d", expectTotal)
+
+	outcome := TraceOutcomeSkipped
+	var reasons []string
+	for _, cluster := range clusters {
+		if cluster.percentage == 0 {
+			continue
+		}
+		// 有流量的集群至少保留1台
+		target := max(1, int(math.Round(float64(expectTotal)*cluster.percentage/totalPercentage)))
+		countToChange := int(math.Ceil(float64((target-cluster.instanceCount)*rule.ExecuteRatio) / 100.0))
+		if countToChange == 0 {
+			reasons = append(reasons, fmt.Sprintf("cluster %s already has %d of %d instances", cluster.rule.ClusterName, cluster.instanceCount, target))
+			continue
+		}
+		trace.step("cluster %s target %d instances, change %d with execute_ratio %d%%", cluster.rule.ClusterName, target, countToChange, rule.ExecuteRatio)
+		clusterTrace := &RuleTrace{}
+		if err := keeper.scaleColorCluster(ctx, plugins, cluster, countToChange, now, clusterTrace); err != nil {
+			return err
+		}
+		reasons = append(reasons, fmt.Sprintf("cluster %s %s", cluster.rule.ClusterName, clusterTrace.Reason))
+		// 同时有扩容和缩容时以扩容为准
+		if clusterTrace.Outcome == TraceOutcomeScaledUp || (clusterTrace.Outcome == TraceOutcomeScaledDown && outcome == TraceOutcomeSkipped) {
+			outcome = clusterTrace.Outcome
+		}
+	}
+	if len(reasons) == 0 {
+		trace.finish(TraceOutcomeSkipped, "no cluster receives traffic")
+		return nil
+	}
+	trace.finish(outcome, "traffic split %s", strings.Join(reasons, "; "))
+	return nil
+}
+
+//scaleColorCluster 扩缩容一个颜色集群，实例数上限为规则的 max_instance_count，下限为1
+func (keeper *ScheduleXRedundancyKeeper) scaleColorCluster(ctx context.Context, plugins []Plugin, cluster *colorCluster, countToChange int, now time.Time, trace *RuleTrace) error {
+	canSchedule, err := keeper.scalerFor(cluster.rule).CanServiceSchedule(ctx, cluster.rule.ServiceName, cluster.rule.ClusterName)
+	if err != nil {
+		return fmt.Errorf("query service schedule failed , %w", err)
+	}
+	if !canSchedule {
+		trace.finish(TraceOutcomeSkipped, "service is being scheduled by schedulx")
+		return nil
+	}
+	clusterRule := *cluster.rule
+	clusterRule.MinInstanceCount = 1
+	return keeper.scale(ctx, plugins, &clusterRule, countToChange, cluster.instanceCount, cluster.redundancy, now, trace)
+}
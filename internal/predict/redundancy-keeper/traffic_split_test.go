@@ -0,0 +1,100 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//splitScaler 返回固定的流量比例和各颜色集群的实例数，记录每个集群扩缩容的数量
+type splitScaler struct {
+	multiClusterScaler
+	split map[string]float64
+}
+
+func (scaler *splitScaler) GetTrafficSplit(ctx context.Context, serviceName string) (map[string]float64, error) {
+	return scaler.split, nil
+}
+
+func (scaler *splitScaler) GetServiceInstanceCount(ctx context.Context, serviceName, clusterName string) (int, error) {
+	return scaler.counts[clusterName], nil
+}
+
+var _ = ginkgo.Describe("TrafficSplitSource", func() {
+	var rule *model.PredictRule
+	var scaler *splitScaler
+	var redundancies map[string]float64
+
+	start := func() *redundancy_keeper.ScheduleSummary {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricBackend(service.MetricBackendFunc(func(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+				return &service.RedundancySeries{
+					ServiceName: serviceName,
+					MetricName:  metricName,
+					Clusters:    []*service.ClusterRedundancySeries{{ClusterName: clusterName, Timestamps: []int64{begin}, Values: []float64{redundancies[clusterName]}}},
+				}, nil
+			})),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		return redundancy_keeper.Start(context.Background())
+	}
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:                 1700,
+			ServiceName:        "rollout",
+			ClusterName:        "prod",
+			MetricName:         "qps",
+			BenchmarkQps:       100,
+			MinRedundancy:      150,
+			MaxRedundancy:      250,
+			MinInstanceCount:   1,
+			MaxInstanceCount:   50,
+			ExecuteRatio:       100,
+			GreenBlueMode:      true,
+			TrafficSplitSource: consts.TrafficSplitSourceSchedulx,
+			Status:             consts.RuleStatusEnable,
+		}
+		scaler = &splitScaler{multiClusterScaler: multiClusterScaler{expanded: map[string]int{}, shrunk: map[string]int{}}}
+	})
+
+	ginkgo.It("moves instances to follow the traffic split when redundancy is within range", func() {
+		scaler.split = map[string]float64{"blue": 70, "green": 30}
+		scaler.counts = map[string]int{"prod-blue": 10, "prod-green": 0}
+		redundancies = map[string]float64{"prod-blue": 2}
+		summary := start()
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(scaler.shrunk).To(gomega.Equal(map[string]int{"prod-blue": 3}))
+		gomega.Expect(scaler.expanded).To(gomega.Equal(map[string]int{"prod-green": 3}))
+	})
+
+	ginkgo.It("splits the expected total instance count by traffic percentage", func() {
+		// (1.0*10+1.0*10)/20=1.0，回到2.0需要40台
+		scaler.split = map[string]float64{"blue": 25, "green": 75}
+		scaler.counts = map[string]int{"prod-blue": 10, "prod-green": 10}
+		redundancies = map[string]float64{"prod-blue": 1, "prod-green": 1}
+		start()
+		gomega.Expect(scaler.shrunk).To(gomega.BeEmpty())
+		gomega.Expect(scaler.expanded).To(gomega.Equal(map[string]int{"prod-green": 20}))
+
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(explain.Reason).To(gomega.HavePrefix("scaled_up: traffic split cluster prod-blue already has 10 of 10 instances; cluster prod-green scaled_up: "))
+	})
+
+	ginkgo.It("leaves clusters without traffic to the rollout", func() {
+		scaler.split = map[string]float64{"blue": 100, "green": 0}
+		scaler.counts = map[string]int{"prod-blue": 10, "prod-green": 5}
+		redundancies = map[string]float64{"prod-blue": 2, "prod-green": 2}
+		start()
+		gomega.Expect(scaler.shrunk).To(gomega.BeEmpty())
+		gomega.Expect(scaler.expanded).To(gomega.Equal(map[string]int{"prod-blue": 5}))
+	})
+})
@@ -0,0 +1,46 @@
+package redundancy_keeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+)
+
+//serviceCacheChecker 支持查询实例 ip 的服务信息是否来自缓存的 Scaler，require_warm_cache 需要
+type serviceCacheChecker interface {
+	IsServiceCacheWarm(ctx context.Context, ip string) (bool, error)
+}
+
+func (schedulxScaler) IsServiceCacheWarm(ctx context.Context, ip string) (bool, error) {
+	_, fromCache, err := clients.GetServiceByIpWithCacheStatus(ctx, ip)
+	return fromCache, err
+}
+
+//isServiceCacheWarm 用服务集群的第一个实例 ip 检查 GetServiceByIp 缓存，集群没有实例时视为已预热
+func (keeper *ScheduleXRedundancyKeeper) isServiceCacheWarm(ctx context.Context, rule *model.PredictRule) (bool, error) {
+	checker, ok := keeper.scalerFor(rule).(serviceCacheChecker)
+	if !ok {
+		return false, errors.New("scaler does not support require_warm_cache")
+	}
+	ips, err := keeper.scalerFor(rule).GetServiceInstanceIps(ctx, rule.ServiceName, rule.ClusterName)
+	if err != nil {
+		return false, fmt.Errorf("query service instance ips failed , %w", err)
+	}
+	if len(ips) == 0 {
+		return true, nil
+	}
+	warm, err := checker.IsServiceCacheWarm(ctx, ips[0])
+	if err != nil {
+		return false, fmt.Errorf("query service by ip failed , %w", err)
+	}
+	return warm, nil
+}
+
+func (keeper *ScheduleXRedundancyKeeper) requireWarmCache() bool {
+	keeper.lock.RLock()
+	defer keeper.lock.RUnlock()
+	return keeper.RequireWarmCache
+}
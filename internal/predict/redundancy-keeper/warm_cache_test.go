@@ -0,0 +1,76 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//cacheScaler 集群只有一个实例，warm 表示该实例的服务信息是否来自缓存
+type cacheScaler struct {
+	inFlightScaler
+	warm       bool
+	checkedIps []string
+}
+
+func (scaler *cacheScaler) GetServiceInstanceIps(ctx context.Context, serviceName, clusterName string) ([]string, error) {
+	return []string{"10.0.0.1"}, nil
+}
+
+func (scaler *cacheScaler) IsServiceCacheWarm(ctx context.Context, ip string) (bool, error) {
+	scaler.checkedIps = append(scaler.checkedIps, ip)
+	return scaler.warm, nil
+}
+
+var _ = ginkgo.Describe("RequireWarmCache", func() {
+	rule := &model.PredictRule{
+		Id:               700,
+		ServiceName:      "cache",
+		ClusterName:      "default",
+		MetricName:       "qps",
+		BenchmarkQps:     100,
+		MinRedundancy:    150,
+		MaxRedundancy:    250,
+		MinInstanceCount: 1,
+		MaxInstanceCount: 50,
+		ExecuteRatio:     100,
+		Status:           "enable",
+	}
+
+	run := func(scaler redundancy_keeper.Scaler, requireWarmCache bool) *redundancy_keeper.ScheduleSummary {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{RuleConcurrency: 1, MinimalSampleCount: 1, RunOnce: true, RequireWarmCache: requireWarmCache},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+		)).To(gomega.Succeed())
+		return redundancy_keeper.Start(context.Background())
+	}
+
+	ginkgo.It("skips scaling while the cache is cold", func() {
+		scaler := &cacheScaler{}
+		summary := run(scaler, true)
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(0))
+		gomega.Expect(scaler.expanded).To(gomega.Equal(0))
+		gomega.Expect(scaler.checkedIps).To(gomega.Equal([]string{"10.0.0.1"}))
+
+		explain, err := redundancy_keeper.Explain(rule.Id)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(explain.Reason).To(gomega.Equal("skipped: GetServiceByIp cache is cold"))
+	})
+
+	ginkgo.It("scales once the cache is warm", func() {
+		summary := run(&cacheScaler{warm: true}, true)
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+	})
+
+	ginkgo.It("does not check the cache by default", func() {
+		scaler := &cacheScaler{}
+		summary := run(scaler, false)
+		gomega.Expect(summary.RulesScaledUp).To(gomega.Equal(1))
+		gomega.Expect(scaler.checkedIps).To(gomega.BeEmpty())
+	})
+})
@@ -0,0 +1,162 @@
+package redundancy_keeper
+
+import (
+	"errors"
+	"sync"
+)
+
+//watchBufferSize 每个监听者缓存的状态增量数，读取过慢时丢弃新的增量
+const watchBufferSize = 64
+
+//ErrTooManyWatchers 监听规则状态的连接数已达到 MaxWatchConnections
+var ErrTooManyWatchers = errors.New("too many rule status watchers")
+
+//RuleStatus 规则的实时状态；作为增量推送时只包含 rule_id 和变化的字段
+type RuleStatus struct {
+	RuleId      int64  `json:"rule_id"`
+	ServiceName string `json:"service_name,omitempty"`
+	ClusterName string `json:"cluster_name,omitempty"`
+	//Status 规则状态，参见 consts.RuleStatus* 常量
+	Status     string `json:"status,omitempty"`
+	ErrorCount *int   `json:"error_count,omitempty"`
+	//Outcome/Reason/Redundancy 最近一次执行的结果，参见 RuleTrace
+	Outcome    string   `json:"outcome,omitempty"`
+	Reason     string   `json:"reason,omitempty"`
+	Redundancy *float64 `json:"redundancy,omitempty"`
+	Timestamp  int64    `json:"timestamp,omitempty"`
+}
+
+//ruleWatchers 规则状态的监听者
+type ruleWatchers struct {
+	lock     sync.Mutex
+	channels map[chan *RuleStatus]struct{}
+}
+
+func (watchers *ruleWatchers) add(limit int) (chan *RuleStatus, error) {
+	watchers.lock.Lock()
+	defer watchers.lock.Unlock()
+	if limit > 0 && len(watchers.channels) >= limit {
+		return nil, ErrTooManyWatchers
+	}
+	if watchers.channels == nil {
+		watchers.channels = map[chan *RuleStatus]struct{}{}
+	}
+	ch := make(chan *RuleStatus, watchBufferSize)
+	watchers.channels[ch] = struct{}{}
+	return ch, nil
+}
+
+func (watchers *ruleWatchers) remove(ch chan *RuleStatus) {
+	watchers.lock.Lock()
+	defer watchers.lock.Unlock()
+	if _, ok := watchers.channels[ch]; ok {
+		delete(watchers.channels, ch)
+		close(ch)
+	}
+}
+
+func (watchers *ruleWatchers) broadcast(status *RuleStatus) {
+	watchers.lock.Lock()
+	defer watchers.lock.Unlock()
+	for ch := range watchers.channels {
+		select {
+		case ch <- status:
+		default:
+		}
+	}
+}
+
+//WatchRuleStatus 监听规则状态的增量，调用返回的 cancel 后停止监听并关闭 channel；
+//监听者达到 MaxWatchConnections 时返回 ErrTooManyWatchers
+func (keeper *ScheduleXRedundancyKeeper) WatchRuleStatus() (<-chan *RuleStatus, func(), error) {
+	ch, err := keeper.watchers.add(keeper.maxWatchConnections())
+	if err != nil {
+		return nil, nil, err
+	}
+	var once sync.Once
+	return ch, func() { once.Do(func() { keeper.watchers.remove(ch) }) }, nil
+}
+
+//ListRuleStatus 所有规则的完整状态，包含最近一次执行的结果
+func (keeper *ScheduleXRedundancyKeeper) ListRuleStatus() ([]*RuleStatus, error) {
+	rules, err := keeper.listRules()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*RuleStatus, 0, len(rules))
+	for _, rule := range rules {
+		errorCount := rule.ErrorCount
+		status := &RuleStatus{
+			RuleId:      rule.Id,
+			ServiceName: rule.ServiceName,
+			ClusterName: rule.ClusterName,
+			Status:      rule.Status,
+			ErrorCount:  &errorCount,
+		}
+		if trace := keeper.traces.latest(rule.Id); trace != nil {
+			status.Outcome = trace.Outcome
+			status.Reason = trace.Reason
+			status.Redundancy = trace.Redundancy
+			status.Timestamp = trace.Timestamp
+		}
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+//PublishRuleStatus 向所有监听者推送规则状态的增量
+func (keeper *ScheduleXRedundancyKeeper) PublishRuleStatus(status *RuleStatus) {
+	keeper.watchers.broadcast(status)
+}
+
+//publishTrace 执行结果变化或发生扩缩容时推送增量
+func (keeper *ScheduleXRedundancyKeeper) publishTrace(previous, trace *RuleTrace) {
+	scaled := trace.Outcome == TraceOutcomeScaledUp || trace.Outcome == TraceOutcomeScaledDown
+	if previous != nil && previous.Outcome == trace.Outcome && !scaled {
+		return
+	}
+	keeper.PublishRuleStatus(&RuleStatus{
+		RuleId:      trace.RuleId,
+		ServiceName: trace.ServiceName,
+		ClusterName: trace.ClusterName,
+		Outcome:     trace.Outcome,
+		Reason:      trace.Reason,
+		Redundancy:  trace.Redundancy,
+		Timestamp:   trace.Timestamp,
+	})
+}
+
+//publishErrorCount 推送规则连续失败次数的变化
+func (keeper *ScheduleXRedundancyKeeper) publishErrorCount(ruleID int64, errorCount int) {
+	keeper.PublishRuleStatus(&RuleStatus{RuleId: ruleID, ErrorCount: &errorCount, Timestamp: keeper.now().Unix()})
+}
+
+func (keeper *ScheduleXRedundancyKeeper) maxWatchConnections() int {
+	keeper.lock.RLock()
+	defer keeper.lock.RUnlock()
+	return keeper.MaxWatchConnections
+}
+
+//WatchRuleStatus 监听规则状态的增量，见 ScheduleXRedundancyKeeper.WatchRuleStatus
+func WatchRuleStatus() (<-chan *RuleStatus, func(), error) {
+	if redundancyKeeper == nil {
+		return nil, nil, errors.New("redundancy keeper is not initialized")
+	}
+	return redundancyKeeper.WatchRuleStatus()
+}
+
+//ListRuleStatus 所有规则的完整状态
+func ListRuleStatus() ([]*RuleStatus, error) {
+	if redundancyKeeper == nil {
+		return nil, errors.New("redundancy keeper is not initialized")
+	}
+	return redundancyKeeper.ListRuleStatus()
+}
+
+//PublishRuleStatus 向所有监听者推送规则状态的增量，keeper 未初始化时忽略
+func PublishRuleStatus(status *RuleStatus) {
+	if redundancyKeeper == nil {
+		return
+	}
+	redundancyKeeper.PublishRuleStatus(status)
+}
@@ -0,0 +1,122 @@
+package redundancy_keeper_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("WatchRuleStatus", func() {
+	var rule *model.PredictRule
+
+	ginkgo.BeforeEach(func() {
+		rule = &model.PredictRule{
+			Id:               800,
+			ServiceName:      "watch",
+			ClusterName:      "default",
+			MetricName:       "qps",
+			BenchmarkQps:     100,
+			MinRedundancy:    150,
+			MaxRedundancy:    250,
+			MinInstanceCount: 1,
+			MaxInstanceCount: 50,
+			ExecuteRatio:     100,
+			Status:           consts.RuleStatusEnable,
+		}
+	})
+
+	initKeeper := func(scaler redundancy_keeper.Scaler) {
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{
+			RuleConcurrency:          1,
+			MinimalSampleCount:       1,
+			ErrorThresholdForDisable: 1,
+			MaxWatchConnections:      1,
+			RunOnce:                  true,
+		},
+			redundancy_keeper.WithScaler(scaler),
+			redundancy_keeper.WithMetricBackend(lowRedundancyBackend{}),
+			redundancy_keeper.WithRuleLister(func() ([]*model.PredictRule, error) { return []*model.PredictRule{rule}, nil }),
+			redundancy_keeper.WithRuleErrorStore(newMemoryRuleErrorStore()),
+		)).To(gomega.Succeed())
+	}
+
+	drain := func(updates <-chan *redundancy_keeper.RuleStatus) []*redundancy_keeper.RuleStatus {
+		var result []*redundancy_keeper.RuleStatus
+		for {
+			select {
+			case status := <-updates:
+				result = append(result, status)
+			default:
+				return result
+			}
+		}
+	}
+
+	ginkgo.It("pushes a patch when a scale fires", func() {
+		initKeeper(&inFlightScaler{})
+		updates, cancel, err := redundancy_keeper.WatchRuleStatus()
+		gomega.Expect(err).To(gomega.BeNil())
+		defer cancel()
+
+		redundancy_keeper.Start(context.Background())
+		patches := drain(updates)
+		gomega.Expect(patches).To(gomega.HaveLen(1))
+		gomega.Expect(patches[0].RuleId).To(gomega.Equal(rule.Id))
+		gomega.Expect(patches[0].Outcome).To(gomega.Equal(redundancy_keeper.TraceOutcomeScaledUp))
+
+		statuses, err := redundancy_keeper.ListRuleStatus()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(statuses).To(gomega.HaveLen(1))
+		gomega.Expect(statuses[0].Status).To(gomega.Equal(consts.RuleStatusEnable))
+		gomega.Expect(*statuses[0].ErrorCount).To(gomega.Equal(0))
+		gomega.Expect(statuses[0].Outcome).To(gomega.Equal(redundancy_keeper.TraceOutcomeScaledUp))
+		gomega.Expect(*statuses[0].Redundancy).To(gomega.Equal(0.5))
+	})
+
+	ginkgo.It("pushes the error count and the disabled status", func() {
+		initKeeper(&failingScaler{})
+		updates, cancel, err := redundancy_keeper.WatchRuleStatus()
+		gomega.Expect(err).To(gomega.BeNil())
+		defer cancel()
+
+		redundancy_keeper.Start(context.Background())
+		patches := drain(updates)
+		gomega.Expect(patches).To(gomega.HaveLen(3))
+		gomega.Expect(patches[0].Outcome).To(gomega.Equal(redundancy_keeper.TraceOutcomeFailed))
+		gomega.Expect(*patches[1].ErrorCount).To(gomega.Equal(1))
+		gomega.Expect(patches[2].Status).To(gomega.Equal(consts.RuleStatusError))
+	})
+
+	ginkgo.It("limits concurrent watchers", func() {
+		initKeeper(&inFlightScaler{})
+		_, cancel, err := redundancy_keeper.WatchRuleStatus()
+		gomega.Expect(err).To(gomega.BeNil())
+
+		_, _, err = redundancy_keeper.WatchRuleStatus()
+		gomega.Expect(errors.Is(err, redundancy_keeper.ErrTooManyWatchers)).To(gomega.BeTrue())
+
+		cancel()
+		cancel()
+		updates, cancel, err := redundancy_keeper.WatchRuleStatus()
+		gomega.Expect(err).To(gomega.BeNil())
+		cancel()
+		_, ok := <-updates
+		gomega.Expect(ok).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("publishes status changes from outside the keeper", func() {
+		initKeeper(&inFlightScaler{})
+		updates, cancel, err := redundancy_keeper.WatchRuleStatus()
+		gomega.Expect(err).To(gomega.BeNil())
+		defer cancel()
+
+		redundancy_keeper.PublishRuleStatus(&redundancy_keeper.RuleStatus{RuleId: rule.Id, Status: consts.RuleStatusDisable})
+		gomega.Expect(drain(updates)).To(gomega.Equal([]*redundancy_keeper.RuleStatus{{RuleId: rule.Id, Status: consts.RuleStatusDisable}}))
+	})
+})
@@ -0,0 +1,61 @@
+package predict
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/galaxy-future/cudgx/common/logger"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"go.uber.org/zap"
+)
+
+//WatchConfig 收到 SIGHUP 时重新读取配置文件并热加载调度参数，其余配置仍需重启生效
+func WatchConfig(ctx context.Context, configFile string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				if err := ReloadConfig(configFile); err != nil {
+					logger.GetLogger().Error("reload config failed, keep current config", zap.String("file", configFile), zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+//ReloadConfig 重新读取配置文件，校验后热加载调度参数，最近一次拉取的远程配置仍然覆盖配置文件中的值
+func ReloadConfig(configFile string) error {
+	theConfig, err := config.LoadConfig(configFile)
+	if err != nil {
+		return err
+	}
+	if theConfig.Predict == nil {
+		theConfig.Predict = &config.Param{}
+	}
+	if err := normalizeParam(theConfig.Predict); err != nil {
+		return err
+	}
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	applyLastRemoteConfig(theConfig.Predict)
+	if err := ValidateParam(theConfig.Predict); err != nil {
+		return err
+	}
+	changes, err := redundancy_keeper.Reload(theConfig.Predict)
+	if err != nil {
+		return err
+	}
+	if predictor != nil {
+		predictor.config = theConfig.Predict
+	}
+	logger.GetLogger().Info("config reloaded", zap.String("file", configFile), zap.Strings("changes", changes))
+	return nil
+}
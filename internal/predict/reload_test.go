@@ -0,0 +1,65 @@
+package predict_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/types"
+	"github.com/galaxy-future/cudgx/internal/predict"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("WatchConfig", func() {
+	var configFile string
+	var cancel context.CancelFunc
+
+	ginkgo.BeforeEach(func() {
+		dir, err := ioutil.TempDir("", "cudgx-reload")
+		gomega.Expect(err).To(gomega.BeNil())
+		configFile = filepath.Join(dir, "api.json")
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(&config.Param{
+			RunDuration:        types.Duration{Duration: time.Minute},
+			RuleConcurrency:    10,
+			MinimalSampleCount: 1,
+		})).To(gomega.Succeed())
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		predict.WatchConfig(ctx, configFile)
+	})
+
+	ginkgo.AfterEach(func() {
+		cancel()
+		_ = os.RemoveAll(filepath.Dir(configFile))
+	})
+
+	currentParam := func() *config.Param {
+		param, err := redundancy_keeper.GetParam()
+		gomega.Expect(err).To(gomega.BeNil())
+		return param
+	}
+
+	ginkgo.It("reloads the schedule duration on SIGHUP", func() {
+		err := ioutil.WriteFile(configFile, []byte(`{"param":{"run_duration":"30s","rule_concurrency":5}}`), 0644)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(syscall.Kill(os.Getpid(), syscall.SIGHUP)).To(gomega.BeNil())
+
+		gomega.Eventually(func() time.Duration {
+			return currentParam().RunDuration.Duration
+		}, time.Second).Should(gomega.Equal(30 * time.Second))
+		gomega.Expect(currentParam().RuleConcurrency).To(gomega.Equal(5))
+	})
+
+	ginkgo.It("keeps the current config when the new one is invalid", func() {
+		err := ioutil.WriteFile(configFile, []byte(`{"param":{"run_duration":"30s","outlier_removal_method":"unknown"}}`), 0644)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(predict.ReloadConfig(configFile)).NotTo(gomega.BeNil())
+		gomega.Expect(currentParam().RunDuration.Duration).To(gomega.Equal(time.Minute))
+	})
+})
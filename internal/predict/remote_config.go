@@ -0,0 +1,173 @@
+package predict
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/logger"
+	"github.com/galaxy-future/cudgx/common/types"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"go.uber.org/zap"
+)
+
+//RemoteConfig 远程配置服务返回的调度参数覆盖值，为空的字段使用配置文件中的值
+type RemoteConfig struct {
+	RunDuration        *types.Duration `json:"run_duration"`
+	LookbackDuration   *types.Duration `json:"lookback_duration"`
+	MetricSendDuration *types.Duration `json:"metric_send_duration"`
+	MinimalSampleCount *int            `json:"minimal_sample_count"`
+	RuleConcurrency    *int            `json:"rule_concurrency"`
+}
+
+//apply 用覆盖值替换 param 中对应的字段
+func (remote *RemoteConfig) apply(param *config.Param) {
+	if remote == nil {
+		return
+	}
+	if remote.RunDuration != nil {
+		param.RunDuration = *remote.RunDuration
+	}
+	if remote.LookbackDuration != nil {
+		param.LookbackDuration = *remote.LookbackDuration
+	}
+	if remote.MetricSendDuration != nil {
+		param.MetricSendDuration = *remote.MetricSendDuration
+	}
+	if remote.MinimalSampleCount != nil {
+		param.MinimalSampleCount = *remote.MinimalSampleCount
+	}
+	if remote.RuleConcurrency != nil {
+		param.RuleConcurrency = *remote.RuleConcurrency
+	}
+}
+
+var (
+	remoteConfigClient = &http.Client{Timeout: 5 * time.Second}
+	//reloadLock 串行执行 SIGHUP 和远程配置触发的热加载
+	reloadLock sync.Mutex
+	//remoteBaseParam 配置文件中的调度参数，不包含远程配置的覆盖值，为空时没有拉取远程配置
+	remoteBaseParam *config.Param
+	//lastRemoteConfig 最近一次成功获取且校验通过的远程配置，配置服务不可用时沿用，SIGHUP 重新加载配置文件后仍然生效
+	lastRemoteConfig *RemoteConfig
+	//remoteConfigWatcher 每次 WatchRemoteConfig 加1，旧的拉取停止时不清除新的拉取的状态
+	remoteConfigWatcher int
+)
+
+//WatchRemoteConfig 按 remote_config_refresh_interval 周期拉取 param 中 remote_config_url 的调度参数直到 ctx 结束，
+//覆盖值与配置文件中的参数合并后热加载；remote_config_url 为空时直接返回
+func WatchRemoteConfig(ctx context.Context, param *config.Param) {
+	if param == nil || param.RemoteConfigURL == "" {
+		return
+	}
+	url, interval := param.RemoteConfigURL, param.RemoteConfigRefreshInterval.Duration
+	if interval <= 0 {
+		interval = consts.DefaultRemoteConfigRefreshInterval
+	}
+	reloadLock.Lock()
+	base := *param
+	remoteBaseParam, lastRemoteConfig = &base, nil
+	remoteConfigWatcher++
+	watcher := remoteConfigWatcher
+	reloadLock.Unlock()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := refreshRemoteConfig(ctx, url); err != nil {
+				logger.GetLogger().Warn("refresh remote config failed, keep last known good config", zap.String("url", url), zap.Error(err))
+			}
+			select {
+			case <-ctx.Done():
+				// 停止拉取后重新加载配置文件时不再使用远程配置
+				reloadLock.Lock()
+				if watcher == remoteConfigWatcher {
+					remoteBaseParam, lastRemoteConfig = nil, nil
+				}
+				reloadLock.Unlock()
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+//refreshRemoteConfig 拉取一次远程调度参数，与配置文件中的参数合并校验后，有变化时热加载；失败时不修改当前参数
+func refreshRemoteConfig(ctx context.Context, url string) error {
+	remote, err := fetchRemoteConfig(ctx, url)
+	if err != nil {
+		return err
+	}
+
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	merged := *remoteBaseParam
+	remote.apply(&merged)
+	if err := normalizeParam(&merged); err != nil {
+		return err
+	}
+	if err := ValidateParam(&merged); err != nil {
+		return err
+	}
+	lastRemoteConfig = remote
+	current, err := redundancy_keeper.GetParam()
+	if err != nil {
+		return err
+	}
+	if !remoteConfigChanged(current, &merged) {
+		return nil
+	}
+	changes, err := redundancy_keeper.Reload(&merged)
+	if err != nil {
+		return err
+	}
+	if predictor != nil {
+		predictor.config = &merged
+	}
+	logger.GetLogger().Info("remote config reloaded", zap.String("url", url), zap.Strings("changes", changes))
+	return nil
+}
+
+//applyLastRemoteConfig 重新加载配置文件时记录新的配置文件参数，并合并最近一次的远程配置，调用方需持有 reloadLock
+func applyLastRemoteConfig(param *config.Param) {
+	if remoteBaseParam == nil {
+		return
+	}
+	base := *param
+	remoteBaseParam = &base
+	lastRemoteConfig.apply(param)
+}
+
+//remoteConfigChanged 远程配置支持的参数是否与当前生效的不同，没有变化时不热加载，避免每次拉取都清零运行统计
+func remoteConfigChanged(current, merged *config.Param) bool {
+	return current.RunDuration != merged.RunDuration ||
+		current.LookbackDuration != merged.LookbackDuration ||
+		current.MetricSendDuration != merged.MetricSendDuration ||
+		current.MinimalSampleCount != merged.MinimalSampleCount ||
+		current.RuleConcurrency != merged.RuleConcurrency
+}
+
+func fetchRemoteConfig(ctx context.Context, url string) (*RemoteConfig, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := remoteConfigClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	var remote RemoteConfig
+	if err := json.NewDecoder(resp.Body).Decode(&remote); err != nil {
+		return nil, fmt.Errorf("decode remote config failed , %w", err)
+	}
+	return &remote, nil
+}
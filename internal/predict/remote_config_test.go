@@ -0,0 +1,129 @@
+package predict_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/types"
+	"github.com/galaxy-future/cudgx/internal/predict"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	redundancy_keeper "github.com/galaxy-future/cudgx/internal/predict/redundancy-keeper"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("WatchRemoteConfig", func() {
+	var server *httptest.Server
+	var lock sync.Mutex
+	var body string
+	var status int
+	var requests int
+	var cancel context.CancelFunc
+	var param *config.Param
+
+	respond := func(newStatus int, newBody string) {
+		lock.Lock()
+		defer lock.Unlock()
+		status, body = newStatus, newBody
+	}
+
+	requested := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return requests
+	}
+
+	currentParam := func() *config.Param {
+		param, err := redundancy_keeper.GetParam()
+		gomega.Expect(err).To(gomega.BeNil())
+		return param
+	}
+
+	ginkgo.BeforeEach(func() {
+		requests = 0
+		respond(http.StatusOK, `{}`)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			defer lock.Unlock()
+			requests++
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+		}))
+		param = &config.Param{
+			RunDuration:                 types.Duration{Duration: time.Minute},
+			RuleConcurrency:             10,
+			MinimalSampleCount:          1,
+			LookbackDuration:            types.Duration{Duration: 5 * time.Minute},
+			MetricSendDuration:          types.Duration{Duration: 5 * time.Second},
+			RemoteConfigURL:             server.URL,
+			RemoteConfigRefreshInterval: types.Duration{Duration: 20 * time.Millisecond},
+		}
+		gomega.Expect(redundancy_keeper.InitRedundancyKeeper(param)).To(gomega.Succeed())
+	})
+
+	ginkgo.AfterEach(func() {
+		cancel()
+		server.Close()
+	})
+
+	start := func() {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		predict.WatchRemoteConfig(ctx, param)
+	}
+
+	ginkgo.It("reloads the overridden parameters", func() {
+		respond(http.StatusOK, `{"run_duration":"30s","rule_concurrency":5,"lookback_duration":"10m"}`)
+		start()
+		gomega.Eventually(func() time.Duration {
+			return currentParam().RunDuration.Duration
+		}, time.Second).Should(gomega.Equal(30 * time.Second))
+		gomega.Expect(currentParam().RuleConcurrency).To(gomega.Equal(5))
+		gomega.Expect(currentParam().LookbackDuration.Duration).To(gomega.Equal(10 * time.Minute))
+		gomega.Expect(currentParam().MetricSendDuration.Duration).To(gomega.Equal(5 * time.Second))
+	})
+
+	ginkgo.It("keeps the last known good config when the config service is unreachable", func() {
+		respond(http.StatusOK, `{"run_duration":"30s"}`)
+		start()
+		gomega.Eventually(func() time.Duration {
+			return currentParam().RunDuration.Duration
+		}, time.Second).Should(gomega.Equal(30 * time.Second))
+
+		respond(http.StatusInternalServerError, `unavailable`)
+		polled := requested()
+		gomega.Eventually(requested, time.Second).Should(gomega.BeNumerically(">", polled+2))
+		gomega.Expect(currentParam().RunDuration.Duration).To(gomega.Equal(30 * time.Second))
+	})
+
+	ginkgo.It("ignores an invalid remote config", func() {
+		respond(http.StatusOK, `{"rule_concurrency":-1}`)
+		start()
+		gomega.Eventually(requested, time.Second).Should(gomega.BeNumerically(">", 2))
+		gomega.Expect(currentParam().RuleConcurrency).To(gomega.Equal(10))
+	})
+
+	ginkgo.It("applies the remote config on top of a reloaded config file", func() {
+		respond(http.StatusOK, `{"run_duration":"30s"}`)
+		start()
+		gomega.Eventually(func() time.Duration {
+			return currentParam().RunDuration.Duration
+		}, time.Second).Should(gomega.Equal(30 * time.Second))
+
+		dir, err := ioutil.TempDir("", "cudgx-remote-config")
+		gomega.Expect(err).To(gomega.BeNil())
+		defer os.RemoveAll(dir)
+		configFile := filepath.Join(dir, "api.json")
+		err = ioutil.WriteFile(configFile, []byte(`{"param":{"run_duration":"2m","rule_concurrency":3}}`), 0644)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(predict.ReloadConfig(configFile)).To(gomega.Succeed())
+		gomega.Expect(currentParam().RunDuration.Duration).To(gomega.Equal(30 * time.Second))
+		gomega.Expect(currentParam().RuleConcurrency).To(gomega.Equal(3))
+	})
+})
@@ -0,0 +1,357 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/victoriametrics"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/query"
+)
+
+//victoriaMetricsLatencyOffset VictoriaMetrics 默认隐藏最近30s的数据，调度只回查1分钟，需要缩短
+const victoriaMetricsLatencyOffset = time.Second
+
+//MetricBackend 冗余度指标后端，mode 为指标查询方式，见 consts.MetricQueryModeRaw
+type MetricBackend interface {
+	QueryRedundancy(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*RedundancySeries, error)
+	//QueryRawMetric 查询未除以 benchmark 的原始平均指标值，[start, end] 内每 step 秒一个值，没有数据的采样点为 NaN；
+	//step 不大于0时为 consts.StepDuration，指标查询方式由 query.WithMetricQueryMode 指定；不支持时返回 ErrRawMetricUnsupported
+	QueryRawMetric(ctx context.Context, serviceName, clusterName, metricName string, start, end int64, step int) ([]float64, error)
+}
+
+//InstanceMetricBackend 支持按实例 ip 查询只带 instance label 的指标的后端，用于 metric_scope 为 instance 的规则
+type InstanceMetricBackend interface {
+	QueryInstanceRedundancy(ctx context.Context, serviceName, clusterName, metricName string, instanceIps []string, benchmark float64, begin, end int64, trimmedSecond int64) (*RedundancySeries, error)
+}
+
+//ErrInstanceScopeUnsupported 指标后端不支持按实例查询
+var ErrInstanceScopeUnsupported = errors.New("metric backend does not support instance scope")
+
+//ErrRawMetricUnsupported 指标后端不支持查询原始指标值
+var ErrRawMetricUnsupported = errors.New("metric backend does not support raw metric query")
+
+//MetricBackendFunc 将函数适配为 MetricBackend
+type MetricBackendFunc func(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*RedundancySeries, error)
+
+//QueryRedundancy 查询系统冗余度
+func (f MetricBackendFunc) QueryRedundancy(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*RedundancySeries, error) {
+	return f(ctx, serviceName, clusterName, metricName, mode, benchmark, begin, end, trimmedSecond)
+}
+
+//QueryRawMetric 函数只能查询冗余度，返回 ErrRawMetricUnsupported
+func (f MetricBackendFunc) QueryRawMetric(ctx context.Context, serviceName, clusterName, metricName string, start, end int64, step int) ([]float64, error) {
+	return nil, ErrRawMetricUnsupported
+}
+
+var (
+	metricBackendLock sync.RWMutex
+	metricBackend     MetricBackend
+)
+
+//NewMetricBackend 按名称创建指标后端
+func NewMetricBackend(name string, reader *victoriametrics.Reader) (MetricBackend, error) {
+	switch name {
+	case "", consts.MetricBackendPrometheus:
+		return &PrometheusBackend{Reader: reader}, nil
+	case consts.MetricBackendVictoriaMetrics:
+		return &VictoriaMetricsBackend{Reader: reader}, nil
+	default:
+		return nil, fmt.Errorf("unknown metric backend : %s", name)
+	}
+}
+
+//NewMetricBackendFromConfig 按 metric_backends 中一个别名的配置创建指标后端
+func NewMetricBackendFromConfig(backendConfig config.MetricBackendConfig) (MetricBackend, error) {
+	reader := victoriametrics.NewReader(&victoriametrics.Config{Reader: victoriametrics.Reader{
+		VmUrl:       backendConfig.URL,
+		BearerToken: backendConfig.BearerToken,
+		Username:    backendConfig.Username,
+		Password:    backendConfig.Password,
+	}})
+	return NewMetricBackend(backendConfig.Backend, reader)
+}
+
+//NewMetricBackendByParam 按 backend 创建查询 reader 的主指标后端，multi_backend_aliases 不为空时返回同时查询主后端和这些别名后端的 MultiBackend
+func NewMetricBackendByParam(param *config.Param, reader *victoriametrics.Reader) (MetricBackend, error) {
+	backend, err := NewMetricBackend(param.Backend, reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(param.MultiBackendAliases) == 0 {
+		return backend, nil
+	}
+	multiBackend := &MultiBackend{Backends: []MetricBackend{backend}}
+	for _, alias := range param.MultiBackendAliases {
+		backendConfig, ok := param.MetricBackends[alias]
+		if !ok {
+			return nil, fmt.Errorf("multi_backend_aliases %s is not configured in metric_backends", alias)
+		}
+		member, err := NewMetricBackendFromConfig(backendConfig)
+		if err != nil {
+			return nil, fmt.Errorf("metric_backends %s : %w", alias, err)
+		}
+		multiBackend.Backends = append(multiBackend.Backends, member)
+	}
+	return multiBackend, nil
+}
+
+//SetMetricBackend 设置 QueryRedundancy 使用的指标后端
+func SetMetricBackend(backend MetricBackend) {
+	metricBackendLock.Lock()
+	defer metricBackendLock.Unlock()
+	metricBackend = backend
+}
+
+//getMetricBackend 未设置时使用 query.Reader 作为 Prometheus 后端
+func getMetricBackend() MetricBackend {
+	metricBackendLock.RLock()
+	defer metricBackendLock.RUnlock()
+	if metricBackend == nil {
+		return &PrometheusBackend{Reader: query.Reader}
+	}
+	return metricBackend
+}
+
+//DefaultMetricBackend 使用 SetMetricBackend 设置的后端，查询冗余度时与 QueryRedundancyByMode 一致
+var DefaultMetricBackend = defaultMetricBackend{}
+
+type defaultMetricBackend struct{}
+
+//QueryRedundancy 查询系统冗余度
+func (defaultMetricBackend) QueryRedundancy(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*RedundancySeries, error) {
+	return QueryRedundancyByMode(ctx, serviceName, clusterName, metricName, mode, benchmark, begin, end, trimmedSecond)
+}
+
+//QueryInstanceRedundancy 按实例 ip 查询系统冗余度
+func (defaultMetricBackend) QueryInstanceRedundancy(ctx context.Context, serviceName, clusterName, metricName string, instanceIps []string, benchmark float64, begin, end int64, trimmedSecond int64) (*RedundancySeries, error) {
+	backend, ok := getMetricBackend().(InstanceMetricBackend)
+	if !ok {
+		return nil, ErrInstanceScopeUnsupported
+	}
+	return backend.QueryInstanceRedundancy(ctx, serviceName, clusterName, metricName, instanceIps, benchmark, begin, end, trimmedSecond)
+}
+
+//QueryRawMetric 查询原始平均指标值
+func (defaultMetricBackend) QueryRawMetric(ctx context.Context, serviceName, clusterName, metricName string, start, end int64, step int) ([]float64, error) {
+	return getMetricBackend().QueryRawMetric(ctx, serviceName, clusterName, metricName, start, end, step)
+}
+
+//PrometheusBackend 通过 Prometheus query_range API 查询
+type PrometheusBackend struct {
+	Reader *victoriametrics.Reader
+}
+
+//QueryRedundancy 查询系统冗余度，冗余度由 QueryRawMetric 查询的原始指标值换算
+func (backend *PrometheusBackend) QueryRedundancy(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*RedundancySeries, error) {
+	return QueryRedundancyFromRawMetric(ctx, backend, serviceName, clusterName, metricName, mode, benchmark, begin, end, 0, trimmedSecond)
+}
+
+//QueryRawMetric 查询原始平均指标值
+func (backend *PrometheusBackend) QueryRawMetric(ctx context.Context, serviceName, clusterName, metricName string, start, end int64, step int) ([]float64, error) {
+	samples, err := query.AverageMetricFromReaderWithStep(ctx, backend.Reader, nil, query.MetricQueryModeFromContext(ctx), serviceName, clusterName, metricName, start, end, rawMetricStep(step))
+	if err != nil {
+		return nil, err
+	}
+	return sampleValues(samples, start, end, rawMetricStep(step)), nil
+}
+
+//QueryInstanceRedundancy 按实例 ip 查询系统冗余度
+func (backend *PrometheusBackend) QueryInstanceRedundancy(ctx context.Context, serviceName, clusterName, metricName string, instanceIps []string, benchmark float64, begin, end int64, trimmedSecond int64) (*RedundancySeries, error) {
+	samples, err := query.AverageInstanceMetricFromReader(ctx, backend.Reader, nil, clusterName, metricName, instanceIps, begin, end)
+	if err != nil {
+		return nil, err
+	}
+	return samples2RedundancySeries(samples, serviceName, metricName, benchmark, trimmedSecond), nil
+}
+
+//VictoriaMetricsBackend 通过 VictoriaMetrics 查询，关闭结果缓存并缩短 latency_offset 以读取最新的数据点
+type VictoriaMetricsBackend struct {
+	Reader *victoriametrics.Reader
+}
+
+//QueryRedundancy 查询系统冗余度，冗余度由 QueryRawMetric 查询的原始指标值换算
+func (backend *VictoriaMetricsBackend) QueryRedundancy(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*RedundancySeries, error) {
+	return QueryRedundancyFromRawMetric(ctx, backend, serviceName, clusterName, metricName, mode, benchmark, begin, end, 0, trimmedSecond)
+}
+
+//QueryRawMetric 查询原始平均指标值
+func (backend *VictoriaMetricsBackend) QueryRawMetric(ctx context.Context, serviceName, clusterName, metricName string, start, end int64, step int) ([]float64, error) {
+	samples, err := query.AverageMetricFromReaderWithStep(ctx, backend.Reader, victoriaMetricsParams(), query.MetricQueryModeFromContext(ctx), serviceName, clusterName, metricName, start, end, rawMetricStep(step))
+	if err != nil {
+		return nil, err
+	}
+	return sampleValues(samples, start, end, rawMetricStep(step)), nil
+}
+
+//QueryInstanceRedundancy 按实例 ip 查询系统冗余度
+func (backend *VictoriaMetricsBackend) QueryInstanceRedundancy(ctx context.Context, serviceName, clusterName, metricName string, instanceIps []string, benchmark float64, begin, end int64, trimmedSecond int64) (*RedundancySeries, error) {
+	samples, err := query.AverageInstanceMetricFromReader(ctx, backend.Reader, victoriaMetricsParams(), clusterName, metricName, instanceIps, begin, end)
+	if err != nil {
+		return nil, err
+	}
+	return samples2RedundancySeries(samples, serviceName, metricName, benchmark, trimmedSecond), nil
+}
+
+//rawMetricStep 秒数转换为查询的采样间隔，不大于0时为 consts.StepDuration
+func rawMetricStep(step int) time.Duration {
+	if step <= 0 {
+		return consts.StepDuration
+	}
+	return time.Duration(step) * time.Second
+}
+
+//sampleValues 把查询结果按时间对齐到 [start, end] 内每 step 一个的采样点，没有数据的采样点为 NaN，同一采样点有多个值时取最大值
+func sampleValues(samples []query.ClusterSample, start, end int64, step time.Duration) []float64 {
+	if end < start {
+		return nil
+	}
+	stepSeconds := int64(step / time.Second)
+	values := make([]float64, (end-start)/stepSeconds+1)
+	for i := range values {
+		values[i] = math.NaN()
+	}
+	for _, sample := range samples {
+		if sample.Timestamp < start {
+			continue
+		}
+		i := (sample.Timestamp - start + stepSeconds/2) / stepSeconds
+		if i >= int64(len(values)) {
+			continue
+		}
+		if math.IsNaN(values[i]) || sample.Value > values[i] {
+			values[i] = sample.Value
+		}
+	}
+	return values
+}
+
+//QueryRedundancyFromRawMetric 调用 backend.QueryRawMetric 按 mode 查询原始平均指标值，并换算为冗余度 = benchmark / 平均指标值，
+//step 为 QueryRawMetric 的采样间隔秒数，没有数据的采样点不出现在结果中
+func QueryRedundancyFromRawMetric(ctx context.Context, backend MetricBackend, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, step int, trimmedSecond int64) (*RedundancySeries, error) {
+	values, err := backend.QueryRawMetric(query.WithMetricQueryMode(ctx, mode), serviceName, clusterName, metricName, begin, end, step)
+	if err != nil {
+		return nil, err
+	}
+	stepSeconds := int64(rawMetricStep(step) / time.Second)
+	samples := make([]query.ClusterSample, 0, len(values))
+	for i, value := range values {
+		if math.IsNaN(value) {
+			continue
+		}
+		samples = append(samples, query.ClusterSample{Timestamp: begin + int64(i)*stepSeconds, Value: value, ClusterName: clusterName})
+	}
+	return samples2RedundancySeries(samples, serviceName, metricName, benchmark, trimmedSecond), nil
+}
+
+func victoriaMetricsParams() url.Values {
+	params := url.Values{}
+	params.Set("nocache", "1")
+	params.Set("latency_offset", victoriaMetricsLatencyOffset.String())
+	return params
+}
+
+//MultiBackend 同时查询多个后端，返回最先成功的结果
+type MultiBackend struct {
+	Backends []MetricBackend
+}
+
+type backendResult[T any] struct {
+	value T
+	err   error
+}
+
+//QueryRedundancy 查询系统冗余度，全部后端失败时返回所有错误
+func (backend *MultiBackend) QueryRedundancy(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*RedundancySeries, error) {
+	queries := make([]func(ctx context.Context) (*RedundancySeries, error), 0, len(backend.Backends))
+	for _, theBackend := range backend.Backends {
+		theBackend := theBackend
+		queries = append(queries, func(ctx context.Context) (*RedundancySeries, error) {
+			return theBackend.QueryRedundancy(ctx, serviceName, clusterName, metricName, mode, benchmark, begin, end, trimmedSecond)
+		})
+	}
+	return firstSuccess(ctx, queries)
+}
+
+//QueryInstanceRedundancy 按实例 ip 查询系统冗余度，只查询支持按实例查询的后端
+func (backend *MultiBackend) QueryInstanceRedundancy(ctx context.Context, serviceName, clusterName, metricName string, instanceIps []string, benchmark float64, begin, end int64, trimmedSecond int64) (*RedundancySeries, error) {
+	var queries []func(ctx context.Context) (*RedundancySeries, error)
+	for _, theBackend := range backend.Backends {
+		instanceBackend, ok := theBackend.(InstanceMetricBackend)
+		if !ok {
+			continue
+		}
+		queries = append(queries, func(ctx context.Context) (*RedundancySeries, error) {
+			return instanceBackend.QueryInstanceRedundancy(ctx, serviceName, clusterName, metricName, instanceIps, benchmark, begin, end, trimmedSecond)
+		})
+	}
+	if len(queries) == 0 && len(backend.Backends) > 0 {
+		return nil, ErrInstanceScopeUnsupported
+	}
+	return firstSuccess(ctx, queries)
+}
+
+//QueryRawMetric 查询原始平均指标值，全部后端失败时返回所有错误
+func (backend *MultiBackend) QueryRawMetric(ctx context.Context, serviceName, clusterName, metricName string, start, end int64, step int) ([]float64, error) {
+	queries := make([]func(ctx context.Context) ([]float64, error), 0, len(backend.Backends))
+	for _, theBackend := range backend.Backends {
+		theBackend := theBackend
+		queries = append(queries, func(ctx context.Context) ([]float64, error) {
+			return theBackend.QueryRawMetric(ctx, serviceName, clusterName, metricName, start, end, step)
+		})
+	}
+	return firstSuccess(ctx, queries)
+}
+
+//firstSuccess 并发执行所有查询，返回最先成功的结果，全部失败时返回所有错误
+func firstSuccess[T any](ctx context.Context, queries []func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	if len(queries) == 0 {
+		return zero, errors.New("no metric backend configured")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan backendResult[T], len(queries))
+	for _, theQuery := range queries {
+		go func(theQuery func(ctx context.Context) (T, error)) {
+			value, err := theQuery(ctx)
+			results <- backendResult[T]{value: value, err: err}
+		}(theQuery)
+	}
+
+	var errs []error
+	for range queries {
+		result := <-results
+		if result.err == nil {
+			return result.value, nil
+		}
+		errs = append(errs, result.err)
+	}
+	return zero, errors.Join(errs...)
+}
+
+//samples2RedundancySeries 平均指标值转换为冗余度 = benchmark / 平均指标值
+func samples2RedundancySeries(samples []query.ClusterSample, serviceName, metricName string, benchmark float64, trimmedSecond int64) *RedundancySeries {
+	clusters := samples2ClusterSeries(samples, trimmedSecond)
+	for _, cluster := range clusters {
+		for i := range cluster.Values {
+			cluster.Values[i] = benchmark / cluster.Values[i]
+		}
+	}
+	series := &RedundancySeries{
+		ServiceName: serviceName,
+		MetricName:  metricName,
+	}
+
+	for _, cluster := range clusters {
+		series.Clusters = append(series.Clusters, cluster)
+	}
+	return series
+}
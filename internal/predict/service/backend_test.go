@@ -0,0 +1,162 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/galaxy-future/cudgx/common/victoriametrics"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/query"
+	"github.com/galaxy-future/cudgx/internal/predict/service"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+const queryRangeResponse = `{"status":"success","data":{"resultType":"matrix","result":[` +
+	`{"metric":{"clusterName":"default","serviceName":"gf.cudgx.pi"},"values":[[1640000000,"50"],[1640000001,"40"]]}]}}`
+
+var _ = ginkgo.Describe("MetricBackend", func() {
+	var server *httptest.Server
+	var form url.Values
+	var reader *victoriametrics.Reader
+
+	ginkgo.BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gomega.Expect(r.ParseForm()).To(gomega.BeNil())
+			form = r.PostForm
+			_, _ = w.Write([]byte(queryRangeResponse))
+		}))
+		reader = &victoriametrics.Reader{Client: server.Client(), VmUrl: server.URL}
+	})
+
+	ginkgo.AfterEach(func() {
+		server.Close()
+	})
+
+	ginkgo.It("converts average metric into redundancy", func() {
+		backend, err := service.NewMetricBackend(consts.MetricBackendPrometheus, reader)
+		gomega.Expect(err).To(gomega.BeNil())
+		series, err := backend.QueryRedundancy(context.Background(), "gf.cudgx.pi", "default", "qps", consts.MetricQueryModeRaw, 100, 1640000000, 1640000002, 1)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(series.Clusters).To(gomega.HaveLen(1))
+		gomega.Expect(series.Clusters[0].Values).To(gomega.Equal([]float64{2, 2.5}))
+		gomega.Expect(form.Get("nocache")).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("disables cache and latency offset for victoriametrics", func() {
+		backend, err := service.NewMetricBackend(consts.MetricBackendVictoriaMetrics, reader)
+		gomega.Expect(err).To(gomega.BeNil())
+		_, err = backend.QueryRedundancy(context.Background(), "gf.cudgx.pi", "default", "qps", consts.MetricQueryModeRaw, 100, 1640000000, 1640000002, 1)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(form.Get("nocache")).To(gomega.Equal("1"))
+		gomega.Expect(form.Get("latency_offset")).To(gomega.Equal("1s"))
+		gomega.Expect(form.Get("query")).To(gomega.ContainSubstring("qps{serviceName='gf.cudgx.pi',clusterName='default'}"))
+	})
+
+	ginkgo.It("queries raw metric values without dividing by benchmark", func() {
+		backend := &service.PrometheusBackend{Reader: reader}
+		values, err := backend.QueryRawMetric(context.Background(), "gf.cudgx.pi", "default", "qps", 1640000000, 1640000001, 1)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(values).To(gomega.Equal([]float64{50, 40}))
+		gomega.Expect(form.Get("step")).To(gomega.Equal("1"))
+		gomega.Expect(form.Get("query")).To(gomega.ContainSubstring("sum(qps{serviceName='gf.cudgx.pi',clusterName='default'})"))
+	})
+
+	ginkgo.It("fills steps without samples with NaN", func() {
+		backend := &service.PrometheusBackend{Reader: reader}
+		values, err := backend.QueryRawMetric(context.Background(), "gf.cudgx.pi", "default", "qps", 1640000000, 1640000002, 0)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(values).To(gomega.HaveLen(3))
+		gomega.Expect(values[:2]).To(gomega.Equal([]float64{50, 40}))
+		gomega.Expect(math.IsNaN(values[2])).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("queries raw metric values in the mode from ctx", func() {
+		backend := &service.VictoriaMetricsBackend{Reader: reader}
+		ctx := query.WithMetricQueryMode(context.Background(), consts.MetricQueryModeRecordingRule)
+		_, err := backend.QueryRawMetric(ctx, "gf.cudgx.pi", "default", "service:qps:avg", 1640000000, 1640000001, 0)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(form.Get("query")).To(gomega.Equal("service:qps:avg{serviceName='gf.cudgx.pi',clusterName='default'}"))
+	})
+
+	ginkgo.It("skips multi backend members without raw metric support", func() {
+		plain := service.MetricBackendFunc(func(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+			return nil, errors.New("not called")
+		})
+		backend := &service.MultiBackend{Backends: []service.MetricBackend{plain, &service.PrometheusBackend{Reader: reader}}}
+		values, err := backend.QueryRawMetric(context.Background(), "gf.cudgx.pi", "default", "qps", 1640000000, 1640000001, 0)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(values).To(gomega.HaveLen(2))
+
+		backend = &service.MultiBackend{Backends: []service.MetricBackend{plain}}
+		_, err = backend.QueryRawMetric(context.Background(), "gf.cudgx.pi", "default", "qps", 1640000000, 1640000002, 0)
+		gomega.Expect(errors.Is(err, service.ErrRawMetricUnsupported)).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("builds a multi backend from multi_backend_aliases", func() {
+		param := &config.Param{Backend: consts.MetricBackendPrometheus}
+		backend, err := service.NewMetricBackendByParam(param, reader)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(backend).To(gomega.BeAssignableToTypeOf(&service.PrometheusBackend{}))
+
+		param.MetricBackends = map[string]config.MetricBackendConfig{"secondary": {Backend: consts.MetricBackendVictoriaMetrics, URL: server.URL}}
+		param.MultiBackendAliases = []string{"secondary"}
+		down := &victoriametrics.Reader{Client: server.Client(), VmUrl: "http://127.0.0.1:1"}
+		backend, err = service.NewMetricBackendByParam(param, down)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(backend.(*service.MultiBackend).Backends).To(gomega.HaveLen(2))
+		series, err := backend.QueryRedundancy(context.Background(), "gf.cudgx.pi", "default", "qps", consts.MetricQueryModeRaw, 100, 1640000000, 1640000002, 1)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(series.Clusters[0].Values).To(gomega.Equal([]float64{2, 2.5}))
+
+		param.MultiBackendAliases = []string{"missing"}
+		_, err = service.NewMetricBackendByParam(param, reader)
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("multi_backend_aliases missing")))
+	})
+
+	ginkgo.It("rejects unknown backends", func() {
+		_, err := service.NewMetricBackend("graphite", reader)
+		gomega.Expect(err).NotTo(gomega.BeNil())
+	})
+
+	ginkgo.It("returns the first successful response of a multi backend", func() {
+		failed := service.MetricBackendFunc(func(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+			return nil, errors.New("backend down")
+		})
+		backend := &service.MultiBackend{Backends: []service.MetricBackend{failed, &service.PrometheusBackend{Reader: reader}}}
+		series, err := backend.QueryRedundancy(context.Background(), "gf.cudgx.pi", "default", "qps", consts.MetricQueryModeRaw, 100, 1640000000, 1640000002, 1)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(series.Clusters[0].Values).To(gomega.HaveLen(2))
+
+		backend = &service.MultiBackend{Backends: []service.MetricBackend{failed, failed}}
+		_, err = backend.QueryRedundancy(context.Background(), "gf.cudgx.pi", "default", "qps", consts.MetricQueryModeRaw, 100, 1640000000, 1640000002, 1)
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("backend down")))
+	})
+
+	ginkgo.It("queries instance scoped metrics by instance ip", func() {
+		backend := &service.PrometheusBackend{Reader: reader}
+		series, err := backend.QueryInstanceRedundancy(context.Background(), "gf.cudgx.pi", "default", "qps", []string{"10.0.0.1", "10.0.0.2"}, 100, 1640000000, 1640000002, 1)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(series.Clusters[0].Values).To(gomega.Equal([]float64{2, 2.5}))
+		gomega.Expect(form.Get("query")).To(gomega.ContainSubstring(`qps{instance=~'(10\\.0\\.0\\.1|10\\.0\\.0\\.2)(:[0-9]+)?'}`))
+	})
+
+	ginkgo.It("skips multi backend members without instance support", func() {
+		plain := service.MetricBackendFunc(func(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*service.RedundancySeries, error) {
+			return nil, errors.New("not called")
+		})
+		backend := &service.MultiBackend{Backends: []service.MetricBackend{plain, &service.PrometheusBackend{Reader: reader}}}
+		series, err := backend.QueryInstanceRedundancy(context.Background(), "gf.cudgx.pi", "default", "qps", []string{"10.0.0.1"}, 100, 1640000000, 1640000002, 1)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(series.Clusters[0].Values).To(gomega.HaveLen(2))
+
+		backend = &service.MultiBackend{Backends: []service.MetricBackend{plain}}
+		_, err = backend.QueryInstanceRedundancy(context.Background(), "gf.cudgx.pi", "default", "qps", []string{"10.0.0.1"}, 100, 1640000000, 1640000002, 1)
+		gomega.Expect(errors.Is(err, service.ErrInstanceScopeUnsupported)).To(gomega.BeTrue())
+	})
+})
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"github.com/galaxy-future/cudgx/internal/request"
+)
+
+//BulkUpdateResult 批量更新规则的结果
+type BulkUpdateResult struct {
+	DryRun bool `json:"dry_run"`
+	//Applied 修改是否已提交，试运行或有规则更新失败时为 false
+	Applied bool                `json:"applied"`
+	Results []*RuleUpdateResult `json:"results"`
+}
+
+//RuleUpdateResult 单个规则的更新结果
+type RuleUpdateResult struct {
+	RuleID  int64  `json:"rule_id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	//Rule 修改后的规则，更新失败时为空
+	Rule *model.PredictRule `json:"rule,omitempty"`
+}
+
+//BulkUpdateRules 在同一个事务中修改多个规则，任何一个规则校验失败时全部回滚并返回 model.ErrBulkUpdateRolledBack，
+//返回的结果中记录每个规则是否成功；dryRun 为 true 时只校验不提交。一次最多修改 consts.MaxBulkUpdateRules 个规则，changedBy 为发起修改的用户
+func BulkUpdateRules(updates []request.RuleUpdate, dryRun bool, changedBy string) (*BulkUpdateResult, error) {
+	if len(updates) == 0 {
+		return nil, errors.New("没有需要更新的规则")
+	}
+	if len(updates) > consts.MaxBulkUpdateRules {
+		return nil, fmt.Errorf("一次最多更新 %d 个规则", consts.MaxBulkUpdateRules)
+	}
+	fieldsByID := make(map[int64]map[string]interface{}, len(updates))
+	ids := make([]int64, 0, len(updates))
+	for _, update := range updates {
+		if _, ok := fieldsByID[update.RuleID]; ok {
+			return nil, fmt.Errorf("规则 %d 重复", update.RuleID)
+		}
+		fieldsByID[update.RuleID] = update.Fields
+		ids = append(ids, update.RuleID)
+	}
+
+	updated := make(map[int64]*model.PredictRule, len(updates))
+	failures, err := model.BulkUpdatePredictRules(ids, func(predictRule *model.PredictRule) error {
+		if err := applyRuleFields(predictRule, fieldsByID[predictRule.Id]); err != nil {
+			return err
+		}
+		updated[predictRule.Id] = predictRule
+		return nil
+	}, dryRun, changedBy)
+	if err != nil && !errors.Is(err, model.ErrBulkUpdateRolledBack) {
+		return nil, err
+	}
+
+	result := &BulkUpdateResult{DryRun: dryRun, Applied: err == nil && !dryRun}
+	for _, id := range ids {
+		ruleResult := &RuleUpdateResult{RuleID: id}
+		if failure, ok := failures[id]; ok {
+			ruleResult.Error = failure.Error()
+		} else {
+			ruleResult.Success = true
+			ruleResult.Rule = updated[id]
+		}
+		result.Results = append(result.Results, ruleResult)
+	}
+	return result, err
+}
+
+//applyRuleFields 将 fields 按 json 字段名写入规则，并做与单个更新相同的校验
+func applyRuleFields(predictRule *model.PredictRule, fields map[string]interface{}) error {
+	if len(fields) == 0 {
+		return errors.New("没有需要更新的字段")
+	}
+	for field := range fields {
+		if !model.IsUpdatableColumn(field) {
+			return fmt.Errorf("字段 %s 不能修改", field)
+		}
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, predictRule); err != nil {
+		return fmt.Errorf("字段类型错误: %w", err)
+	}
+
+	predictRule.MetricName = strings.ToLower(predictRule.MetricName)
+	if predictRule.MetricName != consts.QPSMetricsName && predictRule.MetricName != consts.LatencySectionFactorMetricsName {
+		return fmt.Errorf("指标名称错误: %s", predictRule.MetricName)
+	}
+	if predictRule.BenchmarkQps < 0 {
+		return fmt.Errorf("单机QPS不能小于0")
+	}
+	if predictRule.ReviewThreshold < 0 {
+		return fmt.Errorf("人工确认阈值不能小于0")
+	}
+	if predictRule.AlertOnHighRedundancy < 0 || predictRule.AlertCooldownMinutes < 0 {
+		return fmt.Errorf("冗余度告警阈值和告警间隔不能小于0")
+	}
+	if predictRule.MaxShrinkPercent < 0 || predictRule.MaxShrinkPercent > 100 || predictRule.MaxExpandPercent < 0 || predictRule.MaxExpandPercent > 100 {
+		return fmt.Errorf("单次扩缩容比例上限必须在0到100之间")
+	}
+	if predictRule.MultiClusterMode && predictRule.GreenBlueMode {
+		return fmt.Errorf("多集群模式不能与蓝绿部署同时开启")
+	}
+	if predictRule.TrafficSplitSource != "" && (predictRule.TrafficSplitSource != consts.TrafficSplitSourceSchedulx || !predictRule.GreenBlueMode) {
+		return fmt.Errorf("traffic_split_source 只能为 %s，且需要开启蓝绿部署", consts.TrafficSplitSourceSchedulx)
+	}
+	if predictRule.MetricQueryMode, err = normalizeMetricQueryMode(predictRule.MetricQueryMode, predictRule.RecordingRuleMetricName); err != nil {
+		return err
+	}
+	if predictRule.MetricScope, err = normalizeMetricScope(predictRule.MetricScope, predictRule.MetricQueryMode); err != nil {
+		return err
+	}
+	if predictRule.ShrinkPreference, err = normalizeShrinkPreference(predictRule.ShrinkPreference); err != nil {
+		return err
+	}
+	if predictRule.ScaleUpPolicy, predictRule.ScaleDownPolicy, err = normalizeScalePolicies(predictRule.ScaleUpPolicy, predictRule.ScaleDownPolicy, predictRule.ConsecutiveTicksRequired); err != nil {
+		return err
+	}
+	if predictRule.ClusterType, err = normalizeClusterType(predictRule.ClusterType); err != nil {
+		return err
+	}
+	return predictRule.Validate()
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+)
+
+//errMigratedRuleExists 目标实例上已存在同服务集群的规则
+var errMigratedRuleExists = errors.New("rule already exists")
+
+var (
+	migrationSourceURLLock sync.RWMutex
+	migrationSourceURL     string
+)
+
+//SetMigrationSourceURL 设置本服务的访问地址，导出的规则中记录为 source_url，导入方据此禁用本服务上的规则
+func SetMigrationSourceURL(url string) {
+	migrationSourceURLLock.Lock()
+	defer migrationSourceURLLock.Unlock()
+	migrationSourceURL = url
+}
+
+func getMigrationSourceURL() string {
+	migrationSourceURLLock.RLock()
+	defer migrationSourceURLLock.RUnlock()
+	return migrationSourceURL
+}
+
+//MigratedRule 迁移的规则，Id 为规则在来源实例上的 id
+type MigratedRule struct {
+	*model.PredictRule
+	//SourceURL 来源实例的访问地址，未配置 approval_base_url 时为空，此时导入方无法禁用来源规则
+	SourceURL string `json:"source_url"`
+}
+
+//MigrationResult 导入迁移规则的结果
+type MigrationResult struct {
+	Transferred int `json:"transferred"`
+	//Skipped 目标实例上已存在同服务集群的规则
+	Skipped int      `json:"skipped"`
+	Failed  int      `json:"failed"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+//ExportRulesForMigration 把符合 filter 的规则导出为 JSON 数组，保留规则的状态，用于 ImportRulesFromMigration 导入其他实例
+func ExportRulesForMigration(filter model.RuleFilter) ([]byte, error) {
+	predictRules, err := model.ListPredictRulesByFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	sourceURL := getMigrationSourceURL()
+	migrated := make([]MigratedRule, 0, len(predictRules))
+	for _, predictRule := range predictRules {
+		migrated = append(migrated, MigratedRule{PredictRule: predictRule, SourceURL: sourceURL})
+	}
+	return json.Marshal(migrated)
+}
+
+//ImportRulesFromMigration 在本实例上创建 ExportRulesForMigration 导出的规则，已存在同服务集群的规则时跳过；
+//disableSource 为 true 时创建成功后调用来源实例的管理接口禁用来源规则，禁用失败的规则记为失败，但已创建的规则不会回滚
+func ImportRulesFromMigration(data []byte, disableSource bool) (*MigrationResult, error) {
+	var migrated []MigratedRule
+	if err := json.Unmarshal(data, &migrated); err != nil {
+		return nil, fmt.Errorf("迁移数据格式错误, %w", err)
+	}
+	result := &MigrationResult{}
+	for _, rule := range migrated {
+		if rule.PredictRule == nil {
+			continue
+		}
+		if err := importMigratedRule(rule, disableSource); err != nil {
+			if errors.Is(err, errMigratedRuleExists) {
+				result.Skipped++
+				continue
+			}
+			result.Failed++
+			result.Errors = append(result.Errors, fmt.Sprintf("rule %d (%s/%s/%s): %s", rule.Id, rule.ServiceName, rule.ClusterName, rule.MetricName, err))
+			continue
+		}
+		result.Transferred++
+	}
+	return result, nil
+}
+
+//importMigratedRule 创建一条迁移的规则，目标实例上已存在时返回 errMigratedRuleExists
+func importMigratedRule(rule MigratedRule, disableSource bool) error {
+	if disableSource && rule.SourceURL == "" {
+		return fmt.Errorf("缺少 source_url，无法禁用来源规则")
+	}
+	predictRule := *rule.PredictRule
+	sourceRuleID := predictRule.Id
+	predictRule.Id = 0
+	predictRule.CreatedTime = time.Now().Unix()
+	if err := predictRule.Validate(); err != nil {
+		return err
+	}
+	if err := model.CreatePredictRule(&predictRule); err != nil {
+		if errors.Is(err, model.ErrRuleExists) {
+			return errMigratedRuleExists
+		}
+		return err
+	}
+	if disableSource {
+		if err := clients.DisableRemotePredictRule(context.Background(), rule.SourceURL, sourceRuleID); err != nil {
+			return fmt.Errorf("规则已创建为 %d，禁用来源规则失败, %w", predictRule.Id, err)
+		}
+	}
+	return nil
+}
@@ -1,74 +1,389 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 	"time"
 
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
 	"github.com/galaxy-future/cudgx/internal/predict/model"
 	"github.com/galaxy-future/cudgx/internal/request"
 )
 
-func CreatePredictRule(req *request.CreatePredictRuleRequest) error {
+//normalizeMetricQueryMode 校验指标查询方式，为空时使用 raw
+func normalizeMetricQueryMode(mode, recordingRuleMetricName string) (string, error) {
+	switch mode {
+	case "":
+		return consts.MetricQueryModeRaw, nil
+	case consts.MetricQueryModeRaw:
+		return mode, nil
+	case consts.MetricQueryModeRecordingRule:
+		if recordingRuleMetricName == "" {
+			return "", fmt.Errorf("recording_rule 模式下记录规则指标名称不能为空")
+		}
+		return mode, nil
+	default:
+		return "", fmt.Errorf("未知的指标查询方式: %s", mode)
+	}
+}
+
+//normalizeMetricScope 校验指标范围，为空时使用 service；instance 范围只支持 raw 查询方式
+func normalizeMetricScope(scope, metricQueryMode string) (string, error) {
+	switch scope {
+	case "":
+		return consts.MetricScopeService, nil
+	case consts.MetricScopeService:
+		return scope, nil
+	case consts.MetricScopeInstance:
+		if metricQueryMode != consts.MetricQueryModeRaw {
+			return "", fmt.Errorf("instance 指标范围只支持 raw 查询方式")
+		}
+		return scope, nil
+	default:
+		return "", fmt.Errorf("未知的指标范围: %s", scope)
+	}
+}
+
+//normalizeShrinkPreference 校验缩容实例选择方式，为空时使用 default
+func normalizeShrinkPreference(preference string) (string, error) {
+	switch preference {
+	case "":
+		return consts.ShrinkPreferenceDefault, nil
+	case consts.ShrinkPreferenceDefault, consts.ShrinkPreferenceOldest, consts.ShrinkPreferenceLowestQps:
+		return preference, nil
+	default:
+		return "", fmt.Errorf("未知的缩容实例选择方式: %s", preference)
+	}
+}
+
+//normalizeClusterType 校验集群类型，为空时使用 schedulx
+func normalizeClusterType(clusterType string) (string, error) {
+	switch clusterType {
+	case "":
+		return consts.ClusterTypeSchedulx, nil
+	case consts.ClusterTypeSchedulx, consts.ClusterTypeK8sDeployment, consts.ClusterTypeK8sStatefulSet:
+		return clusterType, nil
+	default:
+		return "", fmt.Errorf("未知的集群类型: %s", clusterType)
+	}
+}
+
+//normalizeScalePolicies 校验扩容、缩容策略，为空时使用 immediate；consecutive 策略需要 consecutive_ticks_required 大于0
+func normalizeScalePolicies(scaleUpPolicy, scaleDownPolicy string, consecutiveTicksRequired int) (string, string, error) {
+	policies := []string{scaleUpPolicy, scaleDownPolicy}
+	for i, policy := range policies {
+		switch policy {
+		case "":
+			policies[i] = consts.ScalePolicyImmediate
+		case consts.ScalePolicyImmediate, consts.ScalePolicySmoothed:
+		case consts.ScalePolicyConsecutive:
+			if consecutiveTicksRequired <= 0 {
+				return "", "", fmt.Errorf("扩缩容策略为 consecutive 时 consecutive_ticks_required 必须大于0")
+			}
+		default:
+			return "", "", fmt.Errorf("未知的扩缩容策略: %s", policy)
+		}
+	}
+	if consecutiveTicksRequired < 0 {
+		return "", "", fmt.Errorf("consecutive_ticks_required 不能小于0")
+	}
+	return policies[0], policies[1], nil
+}
+
+//CreatePredictRule 创建规则，benchmark_qps 为0时需要调用方校准，校准前 keeper 跳过该规则
+func CreatePredictRule(req *request.CreatePredictRuleRequest) (*model.PredictRule, error) {
+	if req.BenchmarkQps < 0 {
+		return nil, fmt.Errorf("单机QPS不能小于0")
+	}
+	if req.ReviewThreshold < 0 {
+		return nil, fmt.Errorf("人工确认阈值不能小于0")
+	}
+	if req.AlertOnHighRedundancy < 0 || req.AlertCooldownMinutes < 0 {
+		return nil, fmt.Errorf("冗余度告警阈值和告警间隔不能小于0")
+	}
+	if req.MaxShrinkPercent < 0 || req.MaxShrinkPercent > 100 || req.MaxExpandPercent < 0 || req.MaxExpandPercent > 100 {
+		return nil, fmt.Errorf("单次扩缩容比例上限必须在0到100之间")
+	}
+	if req.MultiClusterMode && req.GreenBlueMode {
+		return nil, fmt.Errorf("多集群模式不能与蓝绿部署同时开启")
+	}
+	if req.TrafficSplitSource != "" && (req.TrafficSplitSource != consts.TrafficSplitSourceSchedulx || !req.GreenBlueMode) {
+		return nil, fmt.Errorf("traffic_split_source 只能为 %s，且需要开启蓝绿部署", consts.TrafficSplitSourceSchedulx)
+	}
+	metricQueryMode, err := normalizeMetricQueryMode(req.MetricQueryMode, req.RecordingRuleMetricName)
+	if err != nil {
+		return nil, err
+	}
+	metricScope, err := normalizeMetricScope(req.MetricScope, metricQueryMode)
+	if err != nil {
+		return nil, err
+	}
+	shrinkPreference, err := normalizeShrinkPreference(req.ShrinkPreference)
+	if err != nil {
+		return nil, err
+	}
+	scaleUpPolicy, scaleDownPolicy, err := normalizeScalePolicies(req.ScaleUpPolicy, req.ScaleDownPolicy, req.ConsecutiveTicksRequired)
+	if err != nil {
+		return nil, err
+	}
+	clusterType, err := normalizeClusterType(req.ClusterType)
+	if err != nil {
+		return nil, err
+	}
 	predictRule := &model.PredictRule{
-		Id:               0,
-		Name:             req.Name,
-		ServiceName:      req.ServiceName,
-		ClusterName:      req.ClusterName,
-		MetricName:       strings.ToLower(req.MetricName),
-		BenchmarkQps:     req.BenchmarkQps,
-		MinRedundancy:    req.MinRedundancy,
-		MaxRedundancy:    req.MaxRedundancy,
-		MinInstanceCount: req.MinInstanceCount,
-		MaxInstanceCount: req.MaxInstanceCount,
-		ExecuteRatio:     req.ExecuteRatio,
-		Status:           req.Status,
-		CreatedTime:      time.Now().Unix(),
+		Id:                              0,
+		Name:                            req.Name,
+		ServiceName:                     req.ServiceName,
+		ClusterName:                     req.ClusterName,
+		MetricName:                      strings.ToLower(req.MetricName),
+		BenchmarkQps:                    req.BenchmarkQps,
+		MinRedundancy:                   req.MinRedundancy,
+		MaxRedundancy:                   req.MaxRedundancy,
+		MinInstanceCount:                req.MinInstanceCount,
+		MaxInstanceCount:                req.MaxInstanceCount,
+		ExecuteRatio:                    req.ExecuteRatio,
+		UseGradualExpand:                req.UseGradualExpand,
+		GradualBatchSize:                req.GradualBatchSize,
+		RecoveryThreshold:               req.RecoveryThreshold,
+		MetricQueryMode:                 metricQueryMode,
+		RecordingRuleMetricName:         req.RecordingRuleMetricName,
+		MinQPSThreshold:                 req.MinQPSThreshold,
+		UseExpandAndWait:                req.UseExpandAndWait,
+		ReadinessTimeoutSeconds:         req.ReadinessTimeoutSeconds,
+		MetricScope:                     metricScope,
+		MaxRateOfChangePercent:          req.MaxRateOfChangePercent,
+		BenchmarkAutoLearn:              req.BenchmarkAutoLearn,
+		BenchmarkAutoLearnThresholdPct:  req.BenchmarkAutoLearnThresholdPct,
+		AllowedServicePattern:           req.AllowedServicePattern,
+		AllowedClusterPattern:           req.AllowedClusterPattern,
+		GreenBlueMode:                   req.GreenBlueMode,
+		ColorMetricLabel:                req.ColorMetricLabel,
+		ForecastHorizonSeconds:          req.ForecastHorizonSeconds,
+		ShrinkPreference:                shrinkPreference,
+		MetricAggregationWindowSeconds:  req.MetricAggregationWindowSeconds,
+		ReviewRequired:                  req.ReviewRequired,
+		ReviewThreshold:                 req.ReviewThreshold,
+		MultiClusterMode:                req.MultiClusterMode,
+		MaxShrinkPercent:                req.MaxShrinkPercent,
+		MaxExpandPercent:                req.MaxExpandPercent,
+		DependsOnRuleID:                 req.DependsOnRuleID,
+		AlertOnHighRedundancy:           req.AlertOnHighRedundancy,
+		AlertCooldownMinutes:            req.AlertCooldownMinutes,
+		InstanceCountMultiplierSchedule: req.InstanceCountMultiplierSchedule,
+		TrafficSplitSource:              req.TrafficSplitSource,
+		ScaleUpPolicy:                   scaleUpPolicy,
+		ScaleDownPolicy:                 scaleDownPolicy,
+		ConsecutiveTicksRequired:        req.ConsecutiveTicksRequired,
+		ClusterType:                     clusterType,
+		HealthyInstancesOnly:            req.HealthyInstancesOnly,
+		MetricBackendAlias:              req.MetricBackendAlias,
+		MetricMissingAction:             req.MetricMissingAction,
+		NotifyOnCreation:                req.NotifyOnCreation,
+		NotifyOnDeletion:                req.NotifyOnDeletion,
+		VerifyShrinkAfterSeconds:        req.VerifyShrinkAfterSeconds,
+		CheckQuotaBeforeExpand:          req.CheckQuotaBeforeExpand,
+		ShrinkConsecutiveTicks:          req.ShrinkConsecutiveTicks,
+		MaxScaleUpAbsolute:              req.MaxScaleUpAbsolute,
+		MaxScaleDownAbsolute:            req.MaxScaleDownAbsolute,
+		Status:                          req.Status,
+		CreatedTime:                     time.Now().Unix(),
+	}
+	if err := predictRule.Validate(); err != nil {
+		return nil, err
 	}
 	if err := model.CreatePredictRule(predictRule); err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	notifyRuleLifecycle(event.ActionRuleEnabled, predictRule)
+	return predictRule, nil
+}
+
+//CloneRule 复制规则的扩缩容策略到新的服务集群，新规则为草稿状态，启用后才参与调度
+func CloneRule(sourceRuleID int64, targetServiceName, targetClusterName string) (*model.PredictRule, error) {
+	return cloneRule(sourceRuleID, targetServiceName, targetClusterName, func(predictRule *model.PredictRule) {
+		predictRule.Status = consts.RuleStatusDraft
+	})
+}
+
+//CreateRuleFromTemplate 服务自动发现时按模板规则为新的服务集群创建规则，新规则沿用模板规则的状态
+func CreateRuleFromTemplate(templateRuleID int64, serviceName, clusterName string) (*model.PredictRule, error) {
+	return cloneRule(templateRuleID, serviceName, clusterName, func(predictRule *model.PredictRule) {
+		predictRule.AutoDiscovered = true
+	})
+}
+
+//cloneRule 复制来源规则到新的服务集群，modify 在校验前修改新规则
+func cloneRule(sourceRuleID int64, targetServiceName, targetClusterName string, modify func(predictRule *model.PredictRule)) (*model.PredictRule, error) {
+	source, err := model.GetPredictRuleById(sourceRuleID)
+	if err != nil {
+		return nil, err
+	}
+	predictRule := *source
+	predictRule.Id = 0
+	predictRule.Name = fmt.Sprintf("%s_%s_%s", targetServiceName, targetClusterName, source.MetricName)
+	predictRule.ServiceName = targetServiceName
+	predictRule.ClusterName = targetClusterName
+	predictRule.ClonedFromRuleID = source.Id
+	predictRule.AutoDiscovered = false
+	predictRule.LastScaledAt = nil
+	predictRule.CreatedTime = time.Now().Unix()
+	modify(&predictRule)
+	if err := predictRule.Validate(); err != nil {
+		return nil, err
+	}
+	if err := model.CreatePredictRule(&predictRule); err != nil {
+		if errors.Is(err, model.ErrRuleExists) {
+			return nil, fmt.Errorf("服务 %s 集群 %s 已存在扩缩容规则, %w", targetServiceName, targetClusterName, err)
+		}
+		return nil, err
+	}
+	notifyRuleLifecycle(event.ActionRuleEnabled, &predictRule)
+	return &predictRule, nil
 }
 
+//DeletePredictRuleById 软删除规则，规则必须先禁用，见 model.SafeDeleteRules；删除后为开启 notify_on_deletion 的规则发送通知
 func DeletePredictRuleById(req *request.BatchDeletePredictRuleRequest) error {
-	if err := model.DeletePredictRuleById(req.Ids); err != nil {
+	var notifyRules []*model.PredictRule
+	for _, id := range req.Ids {
+		if rule, err := model.GetPredictRuleById(id); err == nil && rule.NotifyOnDeletion {
+			notifyRules = append(notifyRules, rule)
+		}
+	}
+	if err := model.SafeDeleteRules(req.Ids); err != nil {
 		return err
 	}
+	for _, rule := range notifyRules {
+		notifyRuleLifecycle(event.ActionRuleDeleted, rule)
+	}
 	return nil
 }
 
-func UpdatePredictRuleById(req *request.UpdatePredictRuleRequest) error {
-	if _, err := model.GetPredictRuleById(req.Id); err != nil {
+//UpdatePredictRuleById 更新规则，changedBy 记录在规则的修改记录中
+func UpdatePredictRuleById(req *request.UpdatePredictRuleRequest, changedBy string) error {
+	previous, err := model.GetPredictRuleById(req.Id)
+	if err != nil {
+		return err
+	}
+	if req.ReviewThreshold < 0 {
+		return fmt.Errorf("人工确认阈值不能小于0")
+	}
+	if req.AlertOnHighRedundancy < 0 || req.AlertCooldownMinutes < 0 {
+		return fmt.Errorf("冗余度告警阈值和告警间隔不能小于0")
+	}
+	if req.MaxShrinkPercent < 0 || req.MaxShrinkPercent > 100 || req.MaxExpandPercent < 0 || req.MaxExpandPercent > 100 {
+		return fmt.Errorf("单次扩缩容比例上限必须在0到100之间")
+	}
+	if req.MultiClusterMode && req.GreenBlueMode {
+		return fmt.Errorf("多集群模式不能与蓝绿部署同时开启")
+	}
+	if req.TrafficSplitSource != "" && (req.TrafficSplitSource != consts.TrafficSplitSourceSchedulx || !req.GreenBlueMode) {
+		return fmt.Errorf("traffic_split_source 只能为 %s，且需要开启蓝绿部署", consts.TrafficSplitSourceSchedulx)
+	}
+	metricQueryMode, err := normalizeMetricQueryMode(req.MetricQueryMode, req.RecordingRuleMetricName)
+	if err != nil {
+		return err
+	}
+	metricScope, err := normalizeMetricScope(req.MetricScope, metricQueryMode)
+	if err != nil {
+		return err
+	}
+	shrinkPreference, err := normalizeShrinkPreference(req.ShrinkPreference)
+	if err != nil {
+		return err
+	}
+	scaleUpPolicy, scaleDownPolicy, err := normalizeScalePolicies(req.ScaleUpPolicy, req.ScaleDownPolicy, req.ConsecutiveTicksRequired)
+	if err != nil {
+		return err
+	}
+	clusterType, err := normalizeClusterType(req.ClusterType)
+	if err != nil {
 		return err
 	}
 	predictRule := &model.PredictRule{
-		Id:               req.Id,
-		Name:             req.Name,
-		ServiceName:      req.ServiceName,
-		ClusterName:      req.ClusterName,
-		MetricName:       strings.ToLower(req.MetricName),
-		BenchmarkQps:     req.BenchmarkQps,
-		MinRedundancy:    req.MinRedundancy,
-		MaxRedundancy:    req.MaxRedundancy,
-		MinInstanceCount: req.MinInstanceCount,
-		MaxInstanceCount: req.MaxInstanceCount,
-		ExecuteRatio:     req.ExecuteRatio,
-		Status:           req.Status,
-	}
-	if err := model.UpdatePredictRule(predictRule); err != nil {
+		Id:                              req.Id,
+		Name:                            req.Name,
+		ServiceName:                     req.ServiceName,
+		ClusterName:                     req.ClusterName,
+		MetricName:                      strings.ToLower(req.MetricName),
+		BenchmarkQps:                    req.BenchmarkQps,
+		MinRedundancy:                   req.MinRedundancy,
+		MaxRedundancy:                   req.MaxRedundancy,
+		MinInstanceCount:                req.MinInstanceCount,
+		MaxInstanceCount:                req.MaxInstanceCount,
+		ExecuteRatio:                    req.ExecuteRatio,
+		UseGradualExpand:                req.UseGradualExpand,
+		GradualBatchSize:                req.GradualBatchSize,
+		RecoveryThreshold:               req.RecoveryThreshold,
+		MetricQueryMode:                 metricQueryMode,
+		RecordingRuleMetricName:         req.RecordingRuleMetricName,
+		MinQPSThreshold:                 req.MinQPSThreshold,
+		UseExpandAndWait:                req.UseExpandAndWait,
+		ReadinessTimeoutSeconds:         req.ReadinessTimeoutSeconds,
+		MetricScope:                     metricScope,
+		MaxRateOfChangePercent:          req.MaxRateOfChangePercent,
+		BenchmarkAutoLearn:              req.BenchmarkAutoLearn,
+		BenchmarkAutoLearnThresholdPct:  req.BenchmarkAutoLearnThresholdPct,
+		AllowedServicePattern:           req.AllowedServicePattern,
+		AllowedClusterPattern:           req.AllowedClusterPattern,
+		GreenBlueMode:                   req.GreenBlueMode,
+		ColorMetricLabel:                req.ColorMetricLabel,
+		ForecastHorizonSeconds:          req.ForecastHorizonSeconds,
+		ShrinkPreference:                shrinkPreference,
+		MetricAggregationWindowSeconds:  req.MetricAggregationWindowSeconds,
+		ReviewRequired:                  req.ReviewRequired,
+		ReviewThreshold:                 req.ReviewThreshold,
+		MultiClusterMode:                req.MultiClusterMode,
+		MaxShrinkPercent:                req.MaxShrinkPercent,
+		MaxExpandPercent:                req.MaxExpandPercent,
+		DependsOnRuleID:                 req.DependsOnRuleID,
+		AlertOnHighRedundancy:           req.AlertOnHighRedundancy,
+		AlertCooldownMinutes:            req.AlertCooldownMinutes,
+		InstanceCountMultiplierSchedule: req.InstanceCountMultiplierSchedule,
+		TrafficSplitSource:              req.TrafficSplitSource,
+		ScaleUpPolicy:                   scaleUpPolicy,
+		ScaleDownPolicy:                 scaleDownPolicy,
+		ConsecutiveTicksRequired:        req.ConsecutiveTicksRequired,
+		ClusterType:                     clusterType,
+		HealthyInstancesOnly:            req.HealthyInstancesOnly,
+		MetricBackendAlias:              req.MetricBackendAlias,
+		MetricMissingAction:             req.MetricMissingAction,
+		NotifyOnCreation:                req.NotifyOnCreation,
+		NotifyOnDeletion:                req.NotifyOnDeletion,
+		VerifyShrinkAfterSeconds:        req.VerifyShrinkAfterSeconds,
+		CheckQuotaBeforeExpand:          req.CheckQuotaBeforeExpand,
+		ShrinkConsecutiveTicks:          req.ShrinkConsecutiveTicks,
+		MaxScaleUpAbsolute:              req.MaxScaleUpAbsolute,
+		MaxScaleDownAbsolute:            req.MaxScaleDownAbsolute,
+		Status:                          req.Status,
+	}
+	if err := predictRule.Validate(); err != nil {
 		return err
 	}
+	if err := model.UpdatePredictRule(predictRule, changedBy); err != nil {
+		return err
+	}
+	notifyRuleEnabled(previous)
 	return nil
 }
 
 func GetPredictRuleByServiceNameAndClusterName(serviceName, clusterName string) (*model.PredictRule, error) {
-	predictRule, err := model.GetPredictRuleByServiceNameAndClusterName(serviceName, clusterName)
+	predictRule, err := model.GetPredictRuleByServiceCluster(serviceName, clusterName)
 	if err != nil {
 		return nil, err
 	}
 	return predictRule, nil
 }
 
+//ListRuleChangelog 按时间倒序查询规则最近 limit 条修改记录
+func ListRuleChangelog(ruleID int64, limit int) ([]*model.RuleChange, error) {
+	if _, err := model.GetPredictRuleById(ruleID); err != nil {
+		return nil, err
+	}
+	return model.ListRuleChangelog(ruleID, limit)
+}
+
 func GetPredictRuleById(id int64) (*model.PredictRule, error) {
 	predictRule, err := model.GetPredictRuleById(id)
 	if err != nil {
@@ -85,12 +400,36 @@ func ListPredictRules(serviceName, clusterName string, pageNumber int, pageSize
 	return predictRules, total, nil
 }
 
+func ListPredictRulesByMetric(metricName string) ([]*model.PredictRule, error) {
+	predictRules, err := model.ListPredictRulesByMetric(metricName)
+	if err != nil {
+		return nil, err
+	}
+	return predictRules, nil
+}
+
+func ListEnabledMetricNames() ([]string, error) {
+	metricNames, err := model.ListEnabledMetricNames()
+	if err != nil {
+		return nil, err
+	}
+	return metricNames, nil
+}
+
 func UpdatePredictRuleStatus(id int64, status string) error {
-	if _, err := model.GetPredictRuleById(id); err != nil {
+	previous, err := model.GetPredictRuleById(id)
+	if err != nil {
 		return err
 	}
 	if err := model.UpdatePredictRuleStatusById(id, status); err != nil {
 		return err
 	}
+	// 重新启用时清零连续失败次数，避免再失败一次就被自动禁用
+	if status == consts.RuleStatusEnable {
+		if err := model.ResetRuleErrorCount(id); err != nil {
+			return err
+		}
+		notifyRuleEnabled(previous)
+	}
 	return nil
 }
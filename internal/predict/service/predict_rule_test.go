@@ -1,6 +1,14 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
 	"github.com/galaxy-future/cudgx/internal/request"
 	"github.com/onsi/ginkgo"
 	"github.com/onsi/gomega"
@@ -23,7 +31,7 @@ var _ = ginkgo.Describe("PredictRule", func() {
 			Status:           "enable",
 		}
 		ginkgo.It("创建扩缩容规则", func() {
-			err := CreatePredictRule(pr)
+			_, err := CreatePredictRule(pr)
 			gomega.Expect(err).To(gomega.BeNil())
 		})
 		ginkgo.It("查询扩缩容集群列表", func() {
@@ -32,5 +40,198 @@ var _ = ginkgo.Describe("PredictRule", func() {
 			gomega.Expect(total > 0).To(gomega.BeTrue())
 			gomega.Expect(len(list) > 0).To(gomega.BeTrue())
 		})
+		ginkgo.It("按指标查询扩缩容规则", func() {
+			list, err := ListPredictRulesByMetric("qps")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(len(list) > 0).To(gomega.BeTrue())
+			for _, rule := range list {
+				gomega.Expect(rule.MetricName).To(gomega.Equal("qps"))
+			}
+		})
+		ginkgo.It("复制扩缩容规则", func() {
+			source, err := GetPredictRuleByServiceNameAndClusterName("gf.sample.service", "gf.cluster")
+			gomega.Expect(err).To(gomega.BeNil())
+			clone, err := CloneRule(source.Id, "gf.sample.service", "gf.cluster.clone")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(clone.Status).To(gomega.Equal(consts.RuleStatusDraft))
+			gomega.Expect(clone.ClonedFromRuleID).To(gomega.Equal(source.Id))
+			gomega.Expect(clone.BenchmarkQps).To(gomega.Equal(source.BenchmarkQps))
+			_, err = CloneRule(source.Id, "gf.sample.service", "gf.cluster.clone")
+			gomega.Expect(errors.Is(err, model.ErrRuleExists)).To(gomega.BeTrue())
+		})
+		ginkgo.It("记录最近一次扩缩容时间", func() {
+			source, err := GetPredictRuleByServiceNameAndClusterName("gf.sample.service", "gf.cluster")
+			gomega.Expect(err).To(gomega.BeNil())
+			err = model.NewScalingEventPublisher().Publish(context.Background(), &event.ScalingEvent{
+				RuleId:      source.Id,
+				ServiceName: source.ServiceName,
+				ClusterName: source.ClusterName,
+				Action:      event.ActionScaleUp,
+				Count:       2,
+				Timestamp:   1700000000,
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+			rule, err := GetPredictRuleById(source.Id)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(rule.LastScaledAt).NotTo(gomega.BeNil())
+			gomega.Expect(rule.LastScaledAt.Unix()).To(gomega.Equal(int64(1700000000)))
+			clone, err := GetPredictRuleByServiceNameAndClusterName("gf.sample.service", "gf.cluster.clone")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(clone.LastScaledAt).To(gomega.BeNil())
+		})
+		ginkgo.It("批量修改扩缩容规则", func() {
+			source, err := GetPredictRuleByServiceNameAndClusterName("gf.sample.service", "gf.cluster")
+			gomega.Expect(err).To(gomega.BeNil())
+			clone, err := GetPredictRuleByServiceNameAndClusterName("gf.sample.service", "gf.cluster.clone")
+			gomega.Expect(err).To(gomega.BeNil())
+			updates := []request.RuleUpdate{
+				{RuleID: source.Id, Fields: map[string]interface{}{"max_instance_count": 20}},
+				{RuleID: clone.Id, Fields: map[string]interface{}{"max_instance_count": 20}},
+			}
+
+			result, err := BulkUpdateRules(updates, true, "tester")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(result.Applied).To(gomega.BeFalse())
+			gomega.Expect(result.Results[1].Rule.MaxInstanceCount).To(gomega.Equal(20))
+			rule, err := GetPredictRuleById(source.Id)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(rule.MaxInstanceCount).To(gomega.Equal(10))
+
+			updates[1].Fields = map[string]interface{}{"created_time": 0}
+			result, err = BulkUpdateRules(updates, false, "tester")
+			gomega.Expect(errors.Is(err, model.ErrBulkUpdateRolledBack)).To(gomega.BeTrue())
+			gomega.Expect(result.Results[0].Success).To(gomega.BeTrue())
+			gomega.Expect(result.Results[1].Success).To(gomega.BeFalse())
+			rule, err = GetPredictRuleById(source.Id)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(rule.MaxInstanceCount).To(gomega.Equal(10))
+
+			updates[1].Fields = map[string]interface{}{"max_instance_count": 20}
+			result, err = BulkUpdateRules(updates, false, "tester")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(result.Applied).To(gomega.BeTrue())
+			rule, err = GetPredictRuleById(clone.Id)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(rule.MaxInstanceCount).To(gomega.Equal(20))
+
+			changes, err := ListRuleChangelog(clone.Id, 50)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(changes).To(gomega.HaveLen(1))
+			gomega.Expect(changes[0].FieldName).To(gomega.Equal("max_instance_count"))
+			gomega.Expect(changes[0].OldValue).To(gomega.Equal("10"))
+			gomega.Expect(changes[0].NewValue).To(gomega.Equal("20"))
+			gomega.Expect(changes[0].ChangedBy).To(gomega.Equal("tester"))
+		})
+		ginkgo.It("批量修改扩缩容规则数量有上限", func() {
+			var updates []request.RuleUpdate
+			for i := 0; i <= consts.MaxBulkUpdateRules; i++ {
+				updates = append(updates, request.RuleUpdate{RuleID: int64(i + 1), Fields: map[string]interface{}{"max_instance_count": 20}})
+			}
+			_, err := BulkUpdateRules(updates, false, "tester")
+			gomega.Expect(err).NotTo(gomega.BeNil())
+			_, err = BulkUpdateRules([]request.RuleUpdate{updates[0], updates[0]}, false, "tester")
+			gomega.Expect(err).NotTo(gomega.BeNil())
+		})
+		ginkgo.It("删除扩缩容规则前必须先禁用", func() {
+			source, err := GetPredictRuleByServiceNameAndClusterName("gf.sample.service", "gf.cluster")
+			gomega.Expect(err).To(gomega.BeNil())
+			err = DeletePredictRuleById(&request.BatchDeletePredictRuleRequest{Ids: []int64{source.Id}})
+			gomega.Expect(errors.Is(err, model.ErrRuleMustBeDisabledFirst)).To(gomega.BeTrue())
+			clone, err := GetPredictRuleByServiceNameAndClusterName("gf.sample.service", "gf.cluster.clone")
+			gomega.Expect(err).To(gomega.BeNil())
+			err = DeletePredictRuleById(&request.BatchDeletePredictRuleRequest{Ids: []int64{clone.Id}})
+			gomega.Expect(err).To(gomega.BeNil())
+		})
+		ginkgo.It("删除的扩缩容规则被软删除", func() {
+			_, err := GetPredictRuleByServiceNameAndClusterName("gf.sample.service", "gf.cluster.clone")
+			gomega.Expect(err).NotTo(gomega.BeNil())
+			deleted, err := model.ListDeletedRules(time.Now().Add(-time.Hour))
+			gomega.Expect(err).To(gomega.BeNil())
+			var clone *model.PredictRule
+			for _, rule := range deleted {
+				if rule.ServiceName == "gf.sample.service" && rule.ClusterName == "gf.cluster.clone" {
+					clone = rule
+				}
+			}
+			gomega.Expect(clone).NotTo(gomega.BeNil())
+			gomega.Expect(clone.DeletedAt).NotTo(gomega.BeNil())
+
+			// 已删除的规则不能再修改
+			err = model.UpdatePredictRule(&model.PredictRule{Id: clone.Id, ServiceName: clone.ServiceName, ClusterName: clone.ClusterName, MaxInstanceCount: 30}, "tester")
+			gomega.Expect(err).NotTo(gomega.BeNil())
+			gomega.Expect(model.UpdatePredictRuleStatusById(clone.Id, consts.RuleStatusEnable)).To(gomega.Succeed())
+			deleted, err = model.ListDeletedRules(time.Now().Add(-time.Hour))
+			gomega.Expect(err).To(gomega.BeNil())
+			for _, rule := range deleted {
+				if rule.Id == clone.Id {
+					gomega.Expect(rule.Status).To(gomega.Equal(clone.Status))
+					gomega.Expect(rule.MaxInstanceCount).To(gomega.Equal(clone.MaxInstanceCount))
+				}
+			}
+			gomega.Expect(model.SafeDeleteRules([]int64{clone.Id})).To(gomega.Succeed())
+		})
+		ginkgo.It("清理软删除的扩缩容规则时保留扩缩容事件和修改记录", func() {
+			deleted, err := model.ListDeletedRules(time.Now().Add(-time.Hour))
+			gomega.Expect(err).To(gomega.BeNil())
+			var clone *model.PredictRule
+			for _, rule := range deleted {
+				if rule.ServiceName == "gf.sample.service" && rule.ClusterName == "gf.cluster.clone" {
+					clone = rule
+				}
+			}
+			gomega.Expect(clone).NotTo(gomega.BeNil())
+			err = model.NewScalingEventPublisher().Publish(context.Background(), &event.ScalingEvent{
+				RuleId:      clone.Id,
+				ServiceName: clone.ServiceName,
+				ClusterName: clone.ClusterName,
+				Action:      event.ActionScaleDown,
+				Count:       1,
+				Timestamp:   1700000100,
+			})
+			gomega.Expect(err).To(gomega.BeNil())
+			changes, err := ListRuleChangelog(clone.Id, 50)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(changes).NotTo(gomega.BeEmpty())
+			var changeIds []int64
+			for _, change := range changes {
+				changeIds = append(changeIds, change.Id)
+			}
+
+			// 还没有超过保留时间的规则不被清理
+			gomega.Expect(model.PurgeDeletedRules(time.Hour)).To(gomega.Succeed())
+			deleted, err = model.ListDeletedRules(time.Now().Add(-time.Hour))
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(deleted).To(gomega.ContainElement(gomega.HaveField("Id", clone.Id)))
+
+			gomega.Expect(model.PurgeDeletedRules(0)).To(gomega.Succeed())
+			deleted, err = model.ListDeletedRules(time.Now().Add(-time.Hour))
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(deleted).NotTo(gomega.ContainElement(gomega.HaveField("Id", clone.Id)))
+
+			events, err := model.ListScalingEvents(clone.ServiceName, clone.ClusterName, 1700000000, 1700000200)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(events).To(gomega.HaveLen(1))
+			gomega.Expect(events[0].RuleId).To(gomega.Equal(int64(0)))
+			var kept []*model.RuleChange
+			gomega.Expect(clients.DBClient.Where("id IN ?", changeIds).Find(&kept).Error).To(gomega.BeNil())
+			gomega.Expect(kept).To(gomega.HaveLen(len(changeIds)))
+			for _, change := range kept {
+				gomega.Expect(change.RuleId).To(gomega.Equal(int64(0)))
+			}
+		})
+		ginkgo.It("查询已启用规则使用的指标", func() {
+			metricNames, err := ListEnabledMetricNames()
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(metricNames).To(gomega.ContainElement("qps"))
+		})
+		ginkgo.It("迁移扩缩容规则时跳过已存在的规则", func() {
+			data, err := ExportRulesForMigration(model.RuleFilter{ServiceName: "gf.sample.service", ClusterName: "gf.cluster"})
+			gomega.Expect(err).To(gomega.BeNil())
+			result, err := ImportRulesFromMigration(data, true)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(result.Transferred).To(gomega.Equal(0))
+			gomega.Expect(result.Skipped).To(gomega.Equal(1))
+			gomega.Expect(result.Failed).To(gomega.Equal(0))
+		})
 	})
 })
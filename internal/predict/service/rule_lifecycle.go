@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/logger"
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"go.uber.org/zap"
+)
+
+//ruleLifecycleNotifyTimeout 每个接收方发送一次通知的超时时间
+const ruleLifecycleNotifyTimeout = 5 * time.Second
+
+//RuleLifecycleNotifier 接收规则启用和删除的通知，event.WebhookPublisher 和 event.SlackNotifier 都实现了该接口
+type RuleLifecycleNotifier interface {
+	NotifyRuleLifecycle(ctx context.Context, e *event.RuleLifecycleEvent) error
+}
+
+var (
+	ruleLifecycleNotifiersLock sync.RWMutex
+	ruleLifecycleNotifiers     []RuleLifecycleNotifier
+)
+
+//AddRuleLifecycleNotifier 增加规则启用和删除通知的接收方，notifier 为 nil 时忽略
+func AddRuleLifecycleNotifier(notifier RuleLifecycleNotifier) {
+	if notifier == nil {
+		return
+	}
+	ruleLifecycleNotifiersLock.Lock()
+	defer ruleLifecycleNotifiersLock.Unlock()
+	ruleLifecycleNotifiers = append(ruleLifecycleNotifiers, notifier)
+}
+
+func getRuleLifecycleNotifiers() []RuleLifecycleNotifier {
+	ruleLifecycleNotifiersLock.RLock()
+	defer ruleLifecycleNotifiersLock.RUnlock()
+	return ruleLifecycleNotifiers
+}
+
+//notifyRuleEnabled previous 为修改前的规则，从其他状态变为启用时按修改后的配置发送通知
+func notifyRuleEnabled(previous *model.PredictRule) {
+	if previous.Status == consts.RuleStatusEnable {
+		return
+	}
+	rule, err := model.GetPredictRuleById(previous.Id)
+	if err != nil {
+		return
+	}
+	notifyRuleLifecycle(event.ActionRuleEnabled, rule)
+}
+
+//notifyRuleLifecycle 规则开启了 action 对应的 notify_on_creation 或 notify_on_deletion 时异步发送通知，
+//不阻塞规则的修改，发送失败只记录日志
+func notifyRuleLifecycle(action string, rule *model.PredictRule) {
+	switch action {
+	case event.ActionRuleEnabled:
+		if !rule.NotifyOnCreation || rule.Status != consts.RuleStatusEnable {
+			return
+		}
+	case event.ActionRuleDeleted:
+		if !rule.NotifyOnDeletion {
+			return
+		}
+	default:
+		return
+	}
+	notifiers := getRuleLifecycleNotifiers()
+	if len(notifiers) == 0 {
+		return
+	}
+	// 复制规则，调用方返回后继续修改规则不影响通知内容
+	config := *rule
+	e := &event.RuleLifecycleEvent{
+		Action:      action,
+		RuleId:      rule.Id,
+		ServiceName: rule.ServiceName,
+		ClusterName: rule.ClusterName,
+		Rule:        &config,
+		Timestamp:   time.Now().Unix(),
+	}
+	go func() {
+		for _, notifier := range notifiers {
+			ctx, cancel := context.WithTimeout(context.Background(), ruleLifecycleNotifyTimeout)
+			err := notifier.NotifyRuleLifecycle(ctx, e)
+			cancel()
+			if err != nil {
+				logger.GetLogger().Error("notify rule lifecycle failed", zap.Int64("rule_id", e.RuleId),
+					zap.String("action", action), zap.Error(err))
+			}
+		}
+	}()
+}
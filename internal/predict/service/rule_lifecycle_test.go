@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/galaxy-future/cudgx/internal/predict/consts"
+	"github.com/galaxy-future/cudgx/internal/predict/event"
+	"github.com/galaxy-future/cudgx/internal/predict/model"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+//recordingRuleLifecycleNotifier 记录收到的规则启用和删除通知
+type recordingRuleLifecycleNotifier struct {
+	lock   sync.Mutex
+	events []*event.RuleLifecycleEvent
+}
+
+func (notifier *recordingRuleLifecycleNotifier) NotifyRuleLifecycle(ctx context.Context, e *event.RuleLifecycleEvent) error {
+	notifier.lock.Lock()
+	defer notifier.lock.Unlock()
+	notifier.events = append(notifier.events, e)
+	return nil
+}
+
+func (notifier *recordingRuleLifecycleNotifier) received() []*event.RuleLifecycleEvent {
+	notifier.lock.Lock()
+	defer notifier.lock.Unlock()
+	return notifier.events
+}
+
+var _ = ginkgo.Describe("RuleLifecycleNotifier", func() {
+	var notifier *recordingRuleLifecycleNotifier
+	var rule *model.PredictRule
+
+	ginkgo.BeforeEach(func() {
+		ruleLifecycleNotifiersLock.Lock()
+		ruleLifecycleNotifiers = nil
+		ruleLifecycleNotifiersLock.Unlock()
+		notifier = &recordingRuleLifecycleNotifier{}
+		AddRuleLifecycleNotifier(notifier)
+		rule = &model.PredictRule{
+			Id:               7,
+			ServiceName:      "gf.cudgx.pi",
+			ClusterName:      "default",
+			MinRedundancy:    150,
+			NotifyOnCreation: true,
+			NotifyOnDeletion: true,
+			Status:           consts.RuleStatusEnable,
+		}
+	})
+
+	ginkgo.AfterEach(func() {
+		ruleLifecycleNotifiersLock.Lock()
+		ruleLifecycleNotifiers = nil
+		ruleLifecycleNotifiersLock.Unlock()
+	})
+
+	ginkgo.It("sends the rule config when an enabled rule has notify_on_creation", func() {
+		notifyRuleLifecycle(event.ActionRuleEnabled, rule)
+		gomega.Eventually(notifier.received).Should(gomega.HaveLen(1))
+		e := notifier.received()[0]
+		gomega.Expect(e.Action).To(gomega.Equal(event.ActionRuleEnabled))
+		gomega.Expect(e.RuleId).To(gomega.Equal(int64(7)))
+		gomega.Expect(e.Rule.(*model.PredictRule).MinRedundancy).To(gomega.Equal(150))
+	})
+
+	ginkgo.It("does not notify rules that are not enabled or did not opt in", func() {
+		rule.Status = consts.RuleStatusDraft
+		notifyRuleLifecycle(event.ActionRuleEnabled, rule)
+		rule.Status = consts.RuleStatusEnable
+		rule.NotifyOnCreation = false
+		notifyRuleLifecycle(event.ActionRuleEnabled, rule)
+		rule.NotifyOnDeletion = false
+		notifyRuleLifecycle(event.ActionRuleDeleted, rule)
+		gomega.Consistently(notifier.received).Should(gomega.BeEmpty())
+	})
+
+	ginkgo.It("sends deletions when the rule has notify_on_deletion", func() {
+		rule.Status = consts.RuleStatusDisable
+		notifyRuleLifecycle(event.ActionRuleDeleted, rule)
+		gomega.Eventually(notifier.received).Should(gomega.HaveLen(1))
+		gomega.Expect(notifier.received()[0].Action).To(gomega.Equal(event.ActionRuleDeleted))
+	})
+})
@@ -1,12 +1,19 @@
 package service
 
 import (
+	"context"
+
 	"github.com/galaxy-future/cudgx/internal/predict/consts"
 	"github.com/galaxy-future/cudgx/internal/predict/query"
 )
 
 //QueryRedundancy 查询系统冗余度
 func QueryRedundancy(serviceName, clusterName, metricName string, benchmark float64, begin, end int64, trimmedSecond int64) (*RedundancySeries, error) {
+	return QueryRedundancyByMode(context.Background(), serviceName, clusterName, metricName, consts.MetricQueryModeRaw, benchmark, begin, end, trimmedSecond)
+}
+
+//QueryRedundancyByMode 按指标查询方式查询系统冗余度，recording_rule 模式下 metricName 为记录规则名称，实际查询由 SetMetricBackend 设置的后端完成
+func QueryRedundancyByMode(ctx context.Context, serviceName, clusterName, metricName, mode string, benchmark float64, begin, end int64, trimmedSecond int64) (*RedundancySeries, error) {
 	//TODO 根绝trimmedSecond区分是否视图，还是redundancyKeeper定义有些模糊
 	if trimmedSecond != 1 {
 		series := cacheManager.getRedundancySeries(serviceName+clusterName, consts.MetricNameRedundancy, end)
@@ -14,25 +21,7 @@ func QueryRedundancy(serviceName, clusterName, metricName string, benchmark floa
 			return series, nil
 		}
 	}
-	samples, err := query.AverageMetricByVM(serviceName, clusterName, metricName, begin, end)
-	if err != nil {
-		return nil, err
-	}
-	clusters := samples2ClusterSeries(samples, trimmedSecond)
-	for _, cluster := range clusters {
-		for i := range cluster.Values {
-			cluster.Values[i] = benchmark / cluster.Values[i]
-		}
-	}
-	series := &RedundancySeries{
-		ServiceName: serviceName,
-		MetricName:  metricName,
-	}
-
-	for _, cluster := range clusters {
-		series.Clusters = append(series.Clusters, cluster)
-	}
-	return series, nil
+	return getMetricBackend().QueryRedundancy(ctx, serviceName, clusterName, metricName, mode, benchmark, begin, end, trimmedSecond)
 }
 
 //QueryServiceTotalMetric 查询指标数据
@@ -0,0 +1,12 @@
+package service
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/clients"
+)
+
+//GetServiceTopology 查询 schedulx 记录的服务上下游依赖关系，后续按依赖关系联动扩缩容
+func GetServiceTopology(ctx context.Context, serviceName string) (clients.ServiceTopology, error) {
+	return clients.GetServiceTopology(ctx, serviceName)
+}
@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/zipkin"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	BackendJaeger = "jaeger"
+	BackendZipkin = "zipkin"
+)
+
+//DefaultServiceName 配置未设置 service_name 时上报的服务名称
+const DefaultServiceName = "cudgx-api"
+
+//Config 调度链路追踪配置
+type Config struct {
+	//Backend 追踪后端，jaeger/zipkin
+	Backend string `json:"backend"`
+	//Endpoint span 上报地址，例如 jaeger 的 http://jaeger:14268/api/traces，zipkin 的 http://zipkin:9411/api/v2/spans
+	Endpoint string `json:"endpoint"`
+	//ServiceName 上报的服务名称，默认 cudgx-api
+	ServiceName string `json:"service_name"`
+	//SampleRate 采样比例，0到1之间，0表示全部采样
+	SampleRate float64 `json:"sample_rate"`
+}
+
+//Option NewTracerProvider 的可选项
+type Option func(options *options)
+
+type options struct {
+	sampleRate float64
+}
+
+//WithSampleRate 按 trace id 采样的比例，0到1之间，为0时全部采样；有父 span 时沿用父 span 的采样结果
+func WithSampleRate(rate float64) Option {
+	return func(options *options) {
+		options.sampleRate = rate
+	}
+}
+
+//NewTracerProvider 新建把 span 批量上报到 jaeger 或 zipkin 的 TracerProvider
+func NewTracerProvider(backend string, endpoint string, serviceName string, opts ...Option) (*trace.TracerProvider, error) {
+	if endpoint == "" {
+		return nil, errors.New("tracing endpoint is required")
+	}
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	options := &options{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.sampleRate < 0 || options.sampleRate > 1 {
+		return nil, fmt.Errorf("tracing sample rate %v must be between 0 and 1", options.sampleRate)
+	}
+	sampleRate := options.sampleRate
+	if sampleRate == 0 {
+		sampleRate = 1
+	}
+
+	var exporter trace.SpanExporter
+	var err error
+	switch backend {
+	case BackendJaeger:
+		exporter, err = jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(endpoint)))
+	case BackendZipkin:
+		exporter, err = zipkin.New(endpoint)
+	default:
+		return nil, fmt.Errorf("unsupported tracing backend %q, must be %s or %s", backend, BackendJaeger, BackendZipkin)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create %s exporter failed , %w", backend, err)
+	}
+	return trace.NewTracerProvider(
+		trace.WithBatcher(exporter),
+		trace.WithSampler(trace.ParentBased(trace.TraceIDRatioBased(sampleRate))),
+		trace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	), nil
+}
+
+//NewTracerProviderByConfig 按配置新建 TracerProvider
+func NewTracerProviderByConfig(config *Config) (*trace.TracerProvider, error) {
+	if config == nil {
+		return nil, errors.New("tracing config is required")
+	}
+	return NewTracerProvider(config.Backend, config.Endpoint, config.ServiceName, WithSampleRate(config.SampleRate))
+}
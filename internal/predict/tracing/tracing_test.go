@@ -0,0 +1,36 @@
+package tracing_test
+
+import (
+	"context"
+
+	"github.com/galaxy-future/cudgx/internal/predict/tracing"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("NewTracerProvider", func() {
+	ginkgo.It("creates providers for jaeger and zipkin", func() {
+		for backend, endpoint := range map[string]string{
+			tracing.BackendJaeger: "http://127.0.0.1:14268/api/traces",
+			tracing.BackendZipkin: "http://127.0.0.1:9411/api/v2/spans",
+		} {
+			provider, err := tracing.NewTracerProvider(backend, endpoint, "cudgx-test", tracing.WithSampleRate(0.5))
+			gomega.Expect(err).To(gomega.BeNil(), backend)
+			gomega.Expect(provider.Shutdown(context.Background())).To(gomega.Succeed())
+		}
+	})
+
+	ginkgo.It("rejects unknown backends and invalid sample rates", func() {
+		_, err := tracing.NewTracerProvider("datadog", "http://127.0.0.1:8126", "cudgx-test")
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("unsupported tracing backend")))
+
+		_, err = tracing.NewTracerProvider(tracing.BackendZipkin, "http://127.0.0.1:9411/api/v2/spans", "cudgx-test", tracing.WithSampleRate(1.5))
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("between 0 and 1")))
+
+		_, err = tracing.NewTracerProvider(tracing.BackendJaeger, "", "cudgx-test")
+		gomega.Expect(err).NotTo(gomega.BeNil())
+
+		_, err = tracing.NewTracerProviderByConfig(nil)
+		gomega.Expect(err).NotTo(gomega.BeNil())
+	})
+})
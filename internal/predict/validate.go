@@ -0,0 +1,49 @@
+package predict
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+)
+
+//ValidateParam 检查调度参数的必填项和字段之间的约束，返回所有不合法的字段
+func ValidateParam(param *config.Param) error {
+	if param == nil {
+		return errors.New("param is required")
+	}
+	var errs []error
+	if param.RunDuration.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("run_duration should be positive, got %s", param.RunDuration.Duration))
+	}
+	if param.RuleConcurrency <= 0 {
+		errs = append(errs, fmt.Errorf("rule_concurrency should be positive, got %d", param.RuleConcurrency))
+	}
+	if param.MinimalSampleCount <= 0 {
+		errs = append(errs, fmt.Errorf("minimal_sample_count should be positive, got %d", param.MinimalSampleCount))
+	}
+	if param.LookbackDuration.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("lookback_duration should be positive, got %s", param.LookbackDuration.Duration))
+	}
+	if param.LookbackDuration.Duration < param.MetricSendDuration.Duration {
+		errs = append(errs, fmt.Errorf("lookback_duration %s should not be less than metric_send_duration %s",
+			param.LookbackDuration.Duration, param.MetricSendDuration.Duration))
+	}
+	if _, err := regexp.Compile(param.AllowedServicePattern); err != nil {
+		errs = append(errs, fmt.Errorf("allowed_service_pattern is invalid : %w", err))
+	}
+	if _, err := regexp.Compile(param.AllowedClusterPattern); err != nil {
+		errs = append(errs, fmt.Errorf("allowed_cluster_pattern is invalid : %w", err))
+	}
+	for prefix, timeoutMs := range param.EndpointTimeoutsMs {
+		if !strings.HasPrefix(prefix, "/") {
+			errs = append(errs, fmt.Errorf("endpoint_timeouts_ms path %q should start with /", prefix))
+		}
+		if timeoutMs <= 0 {
+			errs = append(errs, fmt.Errorf("endpoint_timeouts_ms of %q should be positive, got %d", prefix, timeoutMs))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,62 @@
+package predict_test
+
+import (
+	"time"
+
+	"github.com/galaxy-future/cudgx/common/types"
+	"github.com/galaxy-future/cudgx/internal/predict"
+	"github.com/galaxy-future/cudgx/internal/predict/config"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("ValidateParam", func() {
+	validParam := func() *config.Param {
+		return &config.Param{
+			RunDuration:        types.Duration{Duration: time.Minute},
+			RuleConcurrency:    10,
+			MinimalSampleCount: 30,
+			LookbackDuration:   types.Duration{Duration: time.Minute},
+			MetricSendDuration: types.Duration{Duration: 5 * time.Second},
+		}
+	}
+
+	ginkgo.It("accepts a valid param", func() {
+		gomega.Expect(predict.ValidateParam(validParam())).To(gomega.Succeed())
+	})
+
+	table.DescribeTable("rejects invalid fields",
+		func(modify func(param *config.Param), messages ...string) {
+			param := validParam()
+			modify(param)
+			err := predict.ValidateParam(param)
+			gomega.Expect(err).To(gomega.HaveOccurred())
+			for _, message := range messages {
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring(message))
+			}
+		},
+		table.Entry("run_duration", func(param *config.Param) { param.RunDuration.Duration = 0 }, "run_duration"),
+		table.Entry("rule_concurrency", func(param *config.Param) { param.RuleConcurrency = 0 }, "rule_concurrency"),
+		table.Entry("minimal_sample_count", func(param *config.Param) { param.MinimalSampleCount = -1 }, "minimal_sample_count"),
+		table.Entry("lookback_duration", func(param *config.Param) {
+			param.LookbackDuration.Duration = 0
+			param.MetricSendDuration.Duration = 0
+		}, "lookback_duration should be positive"),
+		table.Entry("lookback_duration less than metric_send_duration", func(param *config.Param) {
+			param.LookbackDuration.Duration = time.Second
+		}, "should not be less than metric_send_duration"),
+		table.Entry("allowed_service_pattern", func(param *config.Param) { param.AllowedServicePattern = "svc-(" }, "allowed_service_pattern"),
+		table.Entry("allowed_cluster_pattern", func(param *config.Param) { param.AllowedClusterPattern = "[prod" }, "allowed_cluster_pattern"),
+		table.Entry("endpoint_timeouts_ms path", func(param *config.Param) {
+			param.EndpointTimeoutsMs = map[string]int{"api/v1/schedulx/instance/service": 1000}
+		}, "should start with /"),
+		table.Entry("endpoint_timeouts_ms timeout", func(param *config.Param) {
+			param.EndpointTimeoutsMs = map[string]int{"/api/v1/schedulx/instance/service": 0}
+		}, "endpoint_timeouts_ms", "should be positive"),
+		table.Entry("every invalid field", func(param *config.Param) {
+			*param = config.Param{MetricSendDuration: types.Duration{Duration: time.Second}}
+		},
+			"run_duration", "rule_concurrency", "minimal_sample_count", "lookback_duration should be positive", "should not be less than metric_send_duration"),
+	)
+})
@@ -1,32 +1,136 @@
 package request
 
+import "github.com/galaxy-future/cudgx/internal/predict/model"
+
 type CreatePredictRuleRequest struct {
-	Name             string `json:"name" binding:"required"`
-	ServiceName      string `json:"service_name" binding:"required"`
-	ClusterName      string `json:"cluster_name" binding:"required"`
-	MetricName       string `json:"metric_name" binding:"required"`
-	BenchmarkQps     int    `json:"benchmark_qps" binding:"required"`
-	MinRedundancy    int    `json:"min_redundancy" binding:"required"`
-	MaxRedundancy    int    `json:"max_redundancy" binding:"required"`
-	MinInstanceCount int    `json:"min_instance_count" binding:"required"`
-	MaxInstanceCount int    `json:"max_instance_count" binding:"required"`
-	ExecuteRatio     int    `json:"execute_ratio" binding:"required"`
-	Status           string `json:"status" binding:"required"`
+	Name                            string                         `json:"name" binding:"required"`
+	ServiceName                     string                         `json:"service_name" binding:"required"`
+	ClusterName                     string                         `json:"cluster_name" binding:"required"`
+	MetricName                      string                         `json:"metric_name" binding:"required"`
+	BenchmarkQps                    int                            `json:"benchmark_qps"`
+	MinRedundancy                   int                            `json:"min_redundancy" binding:"required"`
+	MaxRedundancy                   int                            `json:"max_redundancy" binding:"required"`
+	MinInstanceCount                int                            `json:"min_instance_count" binding:"required"`
+	MaxInstanceCount                int                            `json:"max_instance_count" binding:"required"`
+	ExecuteRatio                    int                            `json:"execute_ratio" binding:"required"`
+	UseGradualExpand                bool                           `json:"use_gradual_expand"`
+	GradualBatchSize                int                            `json:"gradual_batch_size"`
+	RecoveryThreshold               float64                        `json:"recovery_threshold"`
+	MetricQueryMode                 string                         `json:"metric_query_mode"`
+	RecordingRuleMetricName         string                         `json:"recording_rule_metric_name"`
+	MinQPSThreshold                 float64                        `json:"min_qps_threshold"`
+	UseExpandAndWait                bool                           `json:"use_expand_and_wait"`
+	ReadinessTimeoutSeconds         int                            `json:"readiness_timeout_seconds"`
+	MetricScope                     string                         `json:"metric_scope"`
+	MaxRateOfChangePercent          float64                        `json:"max_rate_of_change_percent"`
+	BenchmarkAutoLearn              bool                           `json:"benchmark_auto_learn"`
+	BenchmarkAutoLearnThresholdPct  float64                        `json:"benchmark_auto_learn_threshold_pct"`
+	AllowedServicePattern           string                         `json:"allowed_service_pattern"`
+	AllowedClusterPattern           string                         `json:"allowed_cluster_pattern"`
+	GreenBlueMode                   bool                           `json:"green_blue_mode"`
+	ColorMetricLabel                string                         `json:"color_metric_label"`
+	ForecastHorizonSeconds          int                            `json:"forecast_horizon_seconds"`
+	ShrinkPreference                string                         `json:"shrink_preference"`
+	MetricAggregationWindowSeconds  int                            `json:"metric_aggregation_window_seconds"`
+	ReviewRequired                  bool                           `json:"review_required"`
+	ReviewThreshold                 int                            `json:"review_threshold"`
+	MultiClusterMode                bool                           `json:"multi_cluster_mode"`
+	MaxShrinkPercent                float64                        `json:"max_shrink_percent"`
+	MaxExpandPercent                float64                        `json:"max_expand_percent"`
+	DependsOnRuleID                 *int64                         `json:"depends_on_rule_id"`
+	AlertOnHighRedundancy           float64                        `json:"alert_on_high_redundancy"`
+	AlertCooldownMinutes            int                            `json:"alert_cooldown_minutes"`
+	InstanceCountMultiplierSchedule model.InstanceCountMultipliers `json:"instance_count_multiplier_schedule"`
+	TrafficSplitSource              string                         `json:"traffic_split_source"`
+	ScaleUpPolicy                   string                         `json:"scale_up_policy"`
+	ScaleDownPolicy                 string                         `json:"scale_down_policy"`
+	ConsecutiveTicksRequired        int                            `json:"consecutive_ticks_required"`
+	ClusterType                     string                         `json:"cluster_type"`
+	HealthyInstancesOnly            bool                           `json:"healthy_instances_only"`
+	MetricBackendAlias              string                         `json:"metric_backend_alias"`
+	MetricMissingAction             string                         `json:"metric_missing_action"`
+	NotifyOnCreation                bool                           `json:"notify_on_creation"`
+	NotifyOnDeletion                bool                           `json:"notify_on_deletion"`
+	VerifyShrinkAfterSeconds        int                            `json:"verify_shrink_after_seconds"`
+	CheckQuotaBeforeExpand          bool                           `json:"check_quota_before_expand"`
+	ShrinkConsecutiveTicks          int                            `json:"shrink_consecutive_ticks"`
+	MaxScaleUpAbsolute              int                            `json:"max_scale_up_absolute"`
+	MaxScaleDownAbsolute            int                            `json:"max_scale_down_absolute"`
+	Status                          string                         `json:"status" binding:"required"`
 }
 
 type UpdatePredictRuleRequest struct {
-	Id               int64  `json:"id" binding:"required"`
-	Name             string `json:"name" binding:"required"`
-	ServiceName      string `json:"service_name" binding:"required"`
-	ClusterName      string `json:"cluster_name" binding:"required"`
-	MetricName       string `json:"metric_name" binding:"required"`
-	BenchmarkQps     int    `json:"benchmark_qps" binding:"required"`
-	MinRedundancy    int    `json:"min_redundancy" binding:"required"`
-	MaxRedundancy    int    `json:"max_redundancy" binding:"required"`
-	MinInstanceCount int    `json:"min_instance_count" binding:"required"`
-	MaxInstanceCount int    `json:"max_instance_count" binding:"required"`
-	ExecuteRatio     int    `json:"execute_ratio" binding:"required"`
-	Status           string `json:"status" binding:"required"`
+	Id                              int64                          `json:"id" binding:"required"`
+	Name                            string                         `json:"name" binding:"required"`
+	ServiceName                     string                         `json:"service_name" binding:"required"`
+	ClusterName                     string                         `json:"cluster_name" binding:"required"`
+	MetricName                      string                         `json:"metric_name" binding:"required"`
+	BenchmarkQps                    int                            `json:"benchmark_qps" binding:"required"`
+	MinRedundancy                   int                            `json:"min_redundancy" binding:"required"`
+	MaxRedundancy                   int                            `json:"max_redundancy" binding:"required"`
+	MinInstanceCount                int                            `json:"min_instance_count" binding:"required"`
+	MaxInstanceCount                int                            `json:"max_instance_count" binding:"required"`
+	ExecuteRatio                    int                            `json:"execute_ratio" binding:"required"`
+	UseGradualExpand                bool                           `json:"use_gradual_expand"`
+	GradualBatchSize                int                            `json:"gradual_batch_size"`
+	RecoveryThreshold               float64                        `json:"recovery_threshold"`
+	MetricQueryMode                 string                         `json:"metric_query_mode"`
+	RecordingRuleMetricName         string                         `json:"recording_rule_metric_name"`
+	MinQPSThreshold                 float64                        `json:"min_qps_threshold"`
+	UseExpandAndWait                bool                           `json:"use_expand_and_wait"`
+	ReadinessTimeoutSeconds         int                            `json:"readiness_timeout_seconds"`
+	MetricScope                     string                         `json:"metric_scope"`
+	MaxRateOfChangePercent          float64                        `json:"max_rate_of_change_percent"`
+	BenchmarkAutoLearn              bool                           `json:"benchmark_auto_learn"`
+	BenchmarkAutoLearnThresholdPct  float64                        `json:"benchmark_auto_learn_threshold_pct"`
+	AllowedServicePattern           string                         `json:"allowed_service_pattern"`
+	AllowedClusterPattern           string                         `json:"allowed_cluster_pattern"`
+	GreenBlueMode                   bool                           `json:"green_blue_mode"`
+	ColorMetricLabel                string                         `json:"color_metric_label"`
+	ForecastHorizonSeconds          int                            `json:"forecast_horizon_seconds"`
+	ShrinkPreference                string                         `json:"shrink_preference"`
+	MetricAggregationWindowSeconds  int                            `json:"metric_aggregation_window_seconds"`
+	ReviewRequired                  bool                           `json:"review_required"`
+	ReviewThreshold                 int                            `json:"review_threshold"`
+	MultiClusterMode                bool                           `json:"multi_cluster_mode"`
+	MaxShrinkPercent                float64                        `json:"max_shrink_percent"`
+	MaxExpandPercent                float64                        `json:"max_expand_percent"`
+	DependsOnRuleID                 *int64                         `json:"depends_on_rule_id"`
+	AlertOnHighRedundancy           float64                        `json:"alert_on_high_redundancy"`
+	AlertCooldownMinutes            int                            `json:"alert_cooldown_minutes"`
+	InstanceCountMultiplierSchedule model.InstanceCountMultipliers `json:"instance_count_multiplier_schedule"`
+	TrafficSplitSource              string                         `json:"traffic_split_source"`
+	ScaleUpPolicy                   string                         `json:"scale_up_policy"`
+	ScaleDownPolicy                 string                         `json:"scale_down_policy"`
+	ConsecutiveTicksRequired        int                            `json:"consecutive_ticks_required"`
+	ClusterType                     string                         `json:"cluster_type"`
+	HealthyInstancesOnly            bool                           `json:"healthy_instances_only"`
+	MetricBackendAlias              string                         `json:"metric_backend_alias"`
+	MetricMissingAction             string                         `json:"metric_missing_action"`
+	NotifyOnCreation                bool                           `json:"notify_on_creation"`
+	NotifyOnDeletion                bool                           `json:"notify_on_deletion"`
+	VerifyShrinkAfterSeconds        int                            `json:"verify_shrink_after_seconds"`
+	CheckQuotaBeforeExpand          bool                           `json:"check_quota_before_expand"`
+	ShrinkConsecutiveTicks          int                            `json:"shrink_consecutive_ticks"`
+	MaxScaleUpAbsolute              int                            `json:"max_scale_up_absolute"`
+	MaxScaleDownAbsolute            int                            `json:"max_scale_down_absolute"`
+	Status                          string                         `json:"status" binding:"required"`
+}
+
+type ClonePredictRuleRequest struct {
+	ServiceName string `json:"service_name" binding:"required"`
+	ClusterName string `json:"cluster_name" binding:"required"`
+}
+
+//RuleUpdate 批量更新中对单个规则的修改，Fields 的 key 为规则的 json 字段名
+type RuleUpdate struct {
+	RuleID int64                  `json:"rule_id" binding:"required"`
+	Fields map[string]interface{} `json:"fields" binding:"required"`
+}
+
+type BulkUpdateRulesRequest struct {
+	Updates []RuleUpdate `json:"updates" binding:"min=1,dive"`
+	DryRun  bool         `json:"dry_run"`
 }
 
 type BatchDeletePredictRuleRequest struct {
@@ -37,3 +141,8 @@ type EnableOrDisablePredictRuleRequest struct {
 	Id     int64  `json:"id" binding:"required"`
 	Status string `json:"status" binding:"required"`
 }
+
+//ReviewPendingActionRequest 确认或拒绝等待人工确认的扩缩容，配置认证时操作人为认证用户，Approver 可以为空
+type ReviewPendingActionRequest struct {
+	Approver string `json:"approver"`
+}